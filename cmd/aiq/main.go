@@ -9,6 +9,7 @@ import (
 	"github.com/aiq/aiq/internal/cli"
 	"github.com/aiq/aiq/internal/config"
 	"github.com/aiq/aiq/internal/prompt"
+	"github.com/aiq/aiq/internal/sandbox"
 	"github.com/aiq/aiq/internal/source"
 	"github.com/aiq/aiq/internal/sql"
 	"github.com/aiq/aiq/internal/ui"
@@ -16,6 +17,9 @@ import (
 )
 
 func main() {
+	// Run as the execute_command sandbox helper when started as one (does not return)
+	sandbox.Main()
+
 	// Handle version command flags before any initialization
 	for _, arg := range os.Args[1:] {
 		if arg == "-v" || arg == "--version" {
@@ -35,7 +39,19 @@ func main() {
 	_, err := prompt.NewLoader()
 	if err != nil {
 		// Log warning but don't fail - prompts will use fallback defaults
-		fmt.Printf("Warning: Failed to initialize prompts: %v. Using default prompts.\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize prompts: %v. Using default prompts.\n", err)
+	}
+
+	// Handle non-interactive subcommands (e.g. "aiq audit") before connection argument parsing
+	if len(os.Args) > 1 {
+		handled, err := cli.RunSubcommand(os.Args[1], os.Args[2:])
+		if handled {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 	}
 
 	// Parse database connection arguments first (before flag.Parse to avoid conflicts)
@@ -47,10 +63,17 @@ func main() {
 
 	// Parse session flag separately (only if no database args)
 	var sessionFile string
+	var files fileList
 	if dbArgs == nil {
 		flag.StringVar(&sessionFile, "s", "", "Path to session file to restore")
 		flag.StringVar(&sessionFile, "session", "", "Path to session file to restore")
+		flag.Var(&files, "f", "CSV, TSV, JSON or Parquet file (or directory of them) to chat with; repeatable")
+		flag.Var(&files, "file", "CSV, TSV, JSON or Parquet file (or directory of them) to chat with; repeatable")
 		flag.Parse()
+		if len(files) > 0 {
+			// aiq -f a.csv b.csv: further files may follow without -f
+			files = append(files, flag.Args()...)
+		}
 	} else {
 		// Parse session flag manually from args (for compatibility with database args)
 		for i, arg := range os.Args[1:] {
@@ -123,8 +146,38 @@ func main() {
 		return
 	}
 
+	// File args: load the files as a file source and chat with them
+	if len(files) > 0 {
+		sourceName, err := source.AddFileSource(files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create source: %v\n", err)
+			os.Exit(1)
+		}
+		ui.ShowInfo(fmt.Sprintf("Using file source '%s'.", sourceName))
+		if err := sql.RunSQLModeWithSource(sourceName, sessionFile, ""); err != nil {
+			if err == sql.ErrReturnToMenu {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// No database args, use normal flow
 	if err := cli.Run(sessionFile); err != nil {
 		os.Exit(1)
 	}
 }
+
+// fileList collects the values of the repeatable -f/--file flag
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
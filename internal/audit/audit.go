@@ -0,0 +1,420 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+const (
+	// ActiveFileName is the name of the audit log file currently being written
+	ActiveFileName = "audit.log"
+
+	// DefaultMaxSizeMB is the size at which the active audit log is rotated
+	DefaultMaxSizeMB = 10
+
+	// DefaultMaxFiles is the number of rotated audit log files kept on disk
+	DefaultMaxFiles = 10
+
+	// lockFileName is the file locked while appending, so processes sharing the log directory write one at a time
+	// It is separate from the log because the active log is renamed on rotation
+	lockFileName = "audit.lock"
+
+	// genesisHash is the prev_hash of the very first record in a chain
+	genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// Approval describes how an audited operation was approved
+type Approval string
+
+const (
+	// ApprovalAuto means the operation was assessed as low risk and executed without asking
+	ApprovalAuto Approval = "auto_approved"
+	// ApprovalConfirmed means the user explicitly confirmed the operation
+	ApprovalConfirmed Approval = "user_confirmed"
+	// ApprovalRejected means the user declined the operation, so it was not executed
+	ApprovalRejected Approval = "user_rejected"
+	// ApprovalDenied means a policy deny rule refused the operation, so it was not executed
+	ApprovalDenied Approval = "policy_denied"
+)
+
+// Record is a single audit log entry (one JSON line)
+type Record struct {
+	Seq          int64     `json:"seq"`
+	Timestamp    time.Time `json:"timestamp"`
+	OSUser       string    `json:"os_user"`
+	Source       string    `json:"source,omitempty"`
+	Database     string    `json:"database,omitempty"`
+	Tool         string    `json:"tool"`
+	Statement    string    `json:"statement"`
+	RiskLevel    string    `json:"risk_level"`
+	Approval     Approval  `json:"approval"`
+	DurationMs   int64     `json:"duration_ms"`
+	RowsAffected *int64    `json:"rows_affected,omitempty"`
+	RowsReturned *int64    `json:"rows_returned,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// computeHash returns the chain hash of a record
+// The hash covers every field except Hash itself, including PrevHash, which links records together
+func computeHash(rec Record) (string, error) {
+	rec.Hash = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// head is the chain state persisted next to the log so truncation of the newest records is detectable
+type head struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Logger appends hash-chained records to a rotating JSON Lines file
+// Several loggers, in this or other processes, may share a directory: each write holds a file lock and
+// continues the chain from the head on disk
+type Logger struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	lock     *os.File
+	file     *os.File
+	size     int64
+	lastSeq  int64
+	lastHash string
+}
+
+// NewLogger opens (or creates) the audit log in dir
+// maxSizeMB and maxFiles fall back to defaults when <= 0
+func NewLogger(dir string, maxSizeMB int, maxFiles int) (*Logger, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultMaxSizeMB
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit lock file: %w", err)
+	}
+	l := &Logger{
+		dir:      dir,
+		maxSize:  int64(maxSizeMB) * 1024 * 1024,
+		maxFiles: maxFiles,
+		lock:     lock,
+		lastHash: genesisHash,
+	}
+	if err := l.openActive(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// refresh catches up with writes made by other loggers since the last write; the caller holds the file lock
+// It resumes the chain from the persisted head, falling back to the last record on disk, and reopens the
+// active file if another logger rotated it
+func (l *Logger) refresh() error {
+	if h, err := readHead(l.dir); err == nil {
+		l.lastSeq = h.Seq
+		l.lastHash = h.Hash
+	} else if last, err := lastRecord(l.dir); err == nil && last != nil {
+		l.lastSeq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	current, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	if active, err := os.Stat(filepath.Join(l.dir, ActiveFileName)); err != nil || !os.SameFile(current, active) {
+		l.file.Close()
+		l.file = nil
+		return l.openActive()
+	}
+	l.size = current.Size()
+	return nil
+}
+
+// openActive opens the active log file in append mode
+func (l *Logger) openActive() error {
+	path := filepath.Join(l.dir, ActiveFileName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Write fills in sequence number, timestamp, OS user and chain hashes, then appends the record
+func (l *Logger) Write(rec Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return rec, fmt.Errorf("audit log is closed")
+	}
+
+	if err := lockFile(l.lock); err != nil {
+		return rec, fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlockFile(l.lock)
+	if err := l.refresh(); err != nil {
+		return rec, err
+	}
+
+	rec.Seq = l.lastSeq + 1
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+	if rec.OSUser == "" {
+		rec.OSUser = currentOSUser()
+	}
+	rec.PrevHash = l.lastHash
+	hash, err := computeHash(rec)
+	if err != nil {
+		return rec, fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	rec.Hash = hash
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return rec, fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return rec, err
+		}
+	}
+
+	if _, err := l.file.Write(line); err != nil {
+		return rec, fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return rec, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	l.size += int64(len(line))
+	l.lastSeq = rec.Seq
+	l.lastHash = rec.Hash
+
+	if err := writeHead(l.dir, head{Seq: rec.Seq, Hash: rec.Hash}); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// rotate renames the active file with a timestamp suffix and prunes old rotated files
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+
+	rotated := filepath.Join(l.dir, fmt.Sprintf("audit-%s.log", time.Now().UTC().Format("20060102150405.000000000")))
+	if err := os.Rename(filepath.Join(l.dir, ActiveFileName), rotated); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	files, err := rotatedFiles(l.dir)
+	if err == nil && len(files) > l.maxFiles {
+		for _, old := range files[:len(files)-l.maxFiles] {
+			os.Remove(old)
+		}
+	}
+
+	return l.openActive()
+}
+
+// Close closes the active log file
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	if lockErr := l.lock.Close(); err == nil {
+		err = lockErr
+	}
+	return err
+}
+
+// currentOSUser returns the name of the OS user running aiq
+func currentOSUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// headPath returns the path of the chain head file
+func headPath(dir string) string {
+	return filepath.Join(dir, "audit.head")
+}
+
+func readHead(dir string) (*head, error) {
+	data, err := os.ReadFile(headPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	var h head
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func writeHead(dir string, h head) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit head: %w", err)
+	}
+	if err := os.WriteFile(headPath(dir), data, 0600); err != nil {
+		return fmt.Errorf("failed to write audit head: %w", err)
+	}
+	return nil
+}
+
+// rotatedFiles returns rotated log files sorted from oldest to newest
+func rotatedFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// logFiles returns all audit log files in chain order (rotated files first, active file last)
+func logFiles(dir string) ([]string, error) {
+	files, err := rotatedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	active := filepath.Join(dir, ActiveFileName)
+	if _, err := os.Stat(active); err == nil {
+		files = append(files, active)
+	}
+	return files, nil
+}
+
+// readRecords calls fn for every record in the given file, in order
+// Lines that fail to parse are reported with a nil record so callers can flag them
+func readRecords(path string, fn func(lineNo int, rec *Record, raw string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+			fn(lineNo, nil, raw)
+			continue
+		}
+		fn(lineNo, &rec, raw)
+	}
+	return scanner.Err()
+}
+
+// lastRecord returns the newest record on disk, or nil if the log is empty
+func lastRecord(dir string) (*Record, error) {
+	files, err := logFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		var last *Record
+		if err := readRecords(files[i], func(_ int, rec *Record, _ string) {
+			if rec != nil {
+				last = rec
+			}
+		}); err != nil {
+			return nil, err
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+var (
+	defaultLogger   *Logger
+	defaultLoggerMu sync.Mutex
+)
+
+// GetLogDir returns the directory holding audit logs (~/.aiq/logs)
+func GetLogDir() (string, error) {
+	return config.GetLogsDir()
+}
+
+// Log writes a record to the default audit log (~/.aiq/logs/audit.log)
+// Rotation settings are read from the audit section of config.yaml
+func Log(rec Record) error {
+	defaultLoggerMu.Lock()
+	defer defaultLoggerMu.Unlock()
+
+	if defaultLogger == nil {
+		dir, err := GetLogDir()
+		if err != nil {
+			return fmt.Errorf("failed to get audit log directory: %w", err)
+		}
+		maxSizeMB, maxFiles := 0, 0
+		if cfg, err := config.Load(); err == nil {
+			maxSizeMB = cfg.Audit.MaxSizeMB
+			maxFiles = cfg.Audit.MaxFiles
+		}
+		logger, err := NewLogger(dir, maxSizeMB, maxFiles)
+		if err != nil {
+			return err
+		}
+		defaultLogger = logger
+	}
+
+	_, err := defaultLogger.Write(rec)
+	return err
+}
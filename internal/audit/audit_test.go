@@ -0,0 +1,291 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeTestRecords(t *testing.T, l *Logger, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		rows := int64(i)
+		_, err := l.Write(Record{
+			Source:       "prod",
+			Database:     "shop",
+			Tool:         "execute_sql",
+			Statement:    "SELECT * FROM orders",
+			RiskLevel:    "low",
+			Approval:     ApprovalAuto,
+			RowsReturned: &rows,
+		})
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+}
+
+// TestLogger_HashChain tests that records are chained and verify cleanly
+func TestLogger_HashChain(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	writeTestRecords(t, l, 3)
+	l.Close()
+
+	records, err := Query(dir, Filter{})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[0].PrevHash != genesisHash {
+		t.Errorf("Expected first record to start the chain")
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].PrevHash != records[i-1].Hash {
+			t.Errorf("Record %d does not link to record %d", i, i-1)
+		}
+		if records[i].Seq != records[i-1].Seq+1 {
+			t.Errorf("Expected consecutive sequence numbers, got %d after %d", records[i].Seq, records[i-1].Seq)
+		}
+	}
+	if records[0].OSUser == "" {
+		t.Error("Expected OS user to be filled in")
+	}
+
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if !result.OK() {
+		t.Errorf("Expected clean chain, got problems: %v", result.Problems)
+	}
+}
+
+// TestLogger_ResumesChain tests that a reopened logger continues the existing chain
+func TestLogger_ResumesChain(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	writeTestRecords(t, l, 2)
+	l.Close()
+
+	l, err = NewLogger(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	rec, err := l.Write(Record{Tool: "execute_command", Statement: "ls", RiskLevel: "low", Approval: ApprovalAuto})
+	if err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	l.Close()
+
+	if rec.Seq != 3 {
+		t.Errorf("Expected seq 3 after reopening, got %d", rec.Seq)
+	}
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if !result.OK() {
+		t.Errorf("Expected clean chain, got problems: %v", result.Problems)
+	}
+}
+
+// TestLogger_SharedDirectory tests that loggers sharing a directory, as separate aiq processes do, keep one chain
+func TestLogger_SharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	loggers := make([]*Logger, 2)
+	for i := range loggers {
+		l, err := NewLogger(dir, 0, 0)
+		if err != nil {
+			t.Fatalf("NewLogger() failed: %v", err)
+		}
+		defer l.Close()
+		loggers[i] = l
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range loggers {
+		wg.Add(1)
+		go func(l *Logger) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if _, err := l.Write(Record{Tool: "execute_sql", Statement: "SELECT 1", RiskLevel: "low", Approval: ApprovalAuto}); err != nil {
+					t.Errorf("Write() failed: %v", err)
+					return
+				}
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	records, err := Query(dir, Filter{})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(records) != 40 {
+		t.Fatalf("Expected 40 records, got %d", len(records))
+	}
+	for i, rec := range records {
+		if rec.Seq != int64(i+1) {
+			t.Errorf("Expected seq %d, got %d", i+1, rec.Seq)
+		}
+	}
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if !result.OK() {
+		t.Errorf("Expected clean chain, got problems: %v", result.Problems)
+	}
+}
+
+// TestVerify_DetectsTampering tests modification and deletion detection
+func TestVerify_DetectsTampering(t *testing.T) {
+	setup := func(t *testing.T) (string, []string) {
+		dir := t.TempDir()
+		l, err := NewLogger(dir, 0, 0)
+		if err != nil {
+			t.Fatalf("NewLogger() failed: %v", err)
+		}
+		writeTestRecords(t, l, 4)
+		l.Close()
+		data, err := os.ReadFile(filepath.Join(dir, ActiveFileName))
+		if err != nil {
+			t.Fatalf("Failed to read audit log: %v", err)
+		}
+		return dir, strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	rewrite := func(t *testing.T, dir string, lines []string) {
+		content := strings.Join(lines, "\n") + "\n"
+		if err := os.WriteFile(filepath.Join(dir, ActiveFileName), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to rewrite audit log: %v", err)
+		}
+	}
+
+	t.Run("detects modified record", func(t *testing.T) {
+		dir, lines := setup(t)
+		lines[1] = strings.Replace(lines[1], "SELECT * FROM orders", "SELECT 1", 1)
+		rewrite(t, dir, lines)
+
+		result, err := Verify(dir)
+		if err != nil {
+			t.Fatalf("Verify() failed: %v", err)
+		}
+		if result.OK() {
+			t.Error("Expected modification to be detected")
+		}
+	})
+
+	t.Run("detects deleted record", func(t *testing.T) {
+		dir, lines := setup(t)
+		rewrite(t, dir, append(lines[:1:1], lines[2:]...))
+
+		result, err := Verify(dir)
+		if err != nil {
+			t.Fatalf("Verify() failed: %v", err)
+		}
+		if result.OK() {
+			t.Error("Expected deletion to be detected")
+		}
+	})
+
+	t.Run("detects truncated tail", func(t *testing.T) {
+		dir, lines := setup(t)
+		rewrite(t, dir, lines[:3])
+
+		result, err := Verify(dir)
+		if err != nil {
+			t.Fatalf("Verify() failed: %v", err)
+		}
+		if result.OK() {
+			t.Error("Expected removal of newest record to be detected")
+		}
+	})
+}
+
+// TestLogger_Rotation tests rotation and retention of rotated files
+func TestLogger_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, 0, 2)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	// Force a tiny size limit so every write rotates
+	l.maxSize = 100
+	writeTestRecords(t, l, 5)
+	l.Close()
+
+	rotated, err := rotatedFiles(dir)
+	if err != nil {
+		t.Fatalf("rotatedFiles() failed: %v", err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("Expected 2 rotated files to be kept, got %d", len(rotated))
+	}
+
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if !result.OK() {
+		t.Errorf("Expected chain to verify across rotated files, got problems: %v", result.Problems)
+	}
+	if result.LastSeq != 5 {
+		t.Errorf("Expected last seq 5, got %d", result.LastSeq)
+	}
+}
+
+// TestQuery_Filter tests record filtering
+func TestQuery_Filter(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewLogger() failed: %v", err)
+	}
+	writeTestRecords(t, l, 2)
+	if _, err := l.Write(Record{Source: "staging", Tool: "execute_sql", Statement: "DELETE FROM users", RiskLevel: "high", Approval: ApprovalConfirmed, Error: "permission denied"}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	l.Close()
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected int
+	}{
+		{"no filter", Filter{}, 3},
+		{"by source", Filter{Source: "staging"}, 1},
+		{"by risk", Filter{RiskLevel: "HIGH"}, 1},
+		{"errors only", Filter{ErrorsOnly: true}, 1},
+		{"by statement substring", Filter{Contains: "orders"}, 2},
+		{"limit keeps newest", Filter{Limit: 1}, 1},
+		{"since in the future", Filter{Since: time.Now().Add(time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Query(dir, tt.filter)
+			if err != nil {
+				t.Fatalf("Query() failed: %v", err)
+			}
+			if len(records) != tt.expected {
+				t.Errorf("Expected %d records, got %d", tt.expected, len(records))
+			}
+		})
+	}
+
+	records, _ := Query(dir, Filter{Limit: 1})
+	if len(records) == 1 && records[0].Source != "staging" {
+		t.Errorf("Expected limit to keep the newest record")
+	}
+}
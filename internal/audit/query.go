@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Filter selects audit records for Query
+// Zero-valued fields do not filter
+type Filter struct {
+	Since      time.Time
+	Until      time.Time
+	Source     string
+	Database   string
+	Tool       string
+	RiskLevel  string
+	Approval   Approval
+	OSUser     string
+	ErrorsOnly bool
+	Contains   string // Case-insensitive substring match on the statement
+	Limit      int    // Keep only the newest N matching records
+}
+
+// Match reports whether a record satisfies the filter
+func (f Filter) Match(rec *Record) bool {
+	if !f.Since.IsZero() && rec.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && rec.Timestamp.After(f.Until) {
+		return false
+	}
+	if f.Source != "" && rec.Source != f.Source {
+		return false
+	}
+	if f.Database != "" && rec.Database != f.Database {
+		return false
+	}
+	if f.Tool != "" && rec.Tool != f.Tool {
+		return false
+	}
+	if f.RiskLevel != "" && !strings.EqualFold(rec.RiskLevel, f.RiskLevel) {
+		return false
+	}
+	if f.Approval != "" && rec.Approval != f.Approval {
+		return false
+	}
+	if f.OSUser != "" && rec.OSUser != f.OSUser {
+		return false
+	}
+	if f.ErrorsOnly && rec.Error == "" {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(strings.ToLower(rec.Statement), strings.ToLower(f.Contains)) {
+		return false
+	}
+	return true
+}
+
+// Query returns matching records from all audit log files in dir, oldest first
+func Query(dir string, filter Filter) ([]Record, error) {
+	files, err := logFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	var records []Record
+	for _, path := range files {
+		err := readRecords(path, func(_ int, rec *Record, _ string) {
+			if rec != nil && filter.Match(rec) {
+				records = append(records, *rec)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
+
+// VerifyResult summarizes a hash chain verification
+type VerifyResult struct {
+	Records  int
+	FirstSeq int64
+	LastSeq  int64
+	Problems []string
+}
+
+// OK reports whether the chain verified without problems
+func (r *VerifyResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify walks every audit log file in dir and checks the hash chain
+// It detects modified records (hash mismatch), removed or reordered records (broken prev_hash link
+// or sequence gap) and removed newest records (chain end does not match the persisted head)
+// The first retained record is trusted as the chain start, since rotation prunes old files by design
+func Verify(dir string) (*VerifyResult, error) {
+	files, err := logFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	result := &VerifyResult{}
+	var prev *Record
+	for _, path := range files {
+		name := filepath.Base(path)
+		err := readRecords(path, func(lineNo int, rec *Record, _ string) {
+			if rec == nil {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s:%d: unparseable record", name, lineNo))
+				return
+			}
+			result.Records++
+			if result.Records == 1 {
+				result.FirstSeq = rec.Seq
+			}
+			result.LastSeq = rec.Seq
+
+			expected, hashErr := computeHash(*rec)
+			if hashErr != nil || expected != rec.Hash {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s:%d: seq %d has been modified (hash mismatch)", name, lineNo, rec.Seq))
+			}
+			if prev != nil {
+				if rec.PrevHash != prev.Hash {
+					result.Problems = append(result.Problems, fmt.Sprintf("%s:%d: seq %d does not link to seq %d (records removed or reordered)", name, lineNo, rec.Seq, prev.Seq))
+				}
+				if rec.Seq != prev.Seq+1 {
+					result.Problems = append(result.Problems, fmt.Sprintf("%s:%d: sequence gap between %d and %d", name, lineNo, prev.Seq, rec.Seq))
+				}
+			} else if rec.Seq == 1 && rec.PrevHash != genesisHash {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s:%d: first record does not start a new chain", name, lineNo))
+			}
+			prev = rec
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	if h, err := readHead(dir); err == nil {
+		if prev == nil {
+			if h.Seq > 0 {
+				result.Problems = append(result.Problems, fmt.Sprintf("audit log is empty but head records seq %d", h.Seq))
+			}
+		} else if h.Seq != prev.Seq || h.Hash != prev.Hash {
+			result.Problems = append(result.Problems, fmt.Sprintf("chain ends at seq %d but head records seq %d (newest records removed)", prev.Seq, h.Seq))
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+const (
+	// DefaultMaxRows is the largest number of affected rows a snapshot captures
+	DefaultMaxRows = 10000
+
+	// DefaultMaxSizeMB is the largest size of captured values in a snapshot
+	DefaultMaxSizeMB = 10
+
+	// DefaultMaxSnapshots is the number of snapshots kept on disk
+	DefaultMaxSnapshots = 50
+)
+
+// ErrNotApplicable is returned by Capture for statements that do not need a backup (e.g. SELECT, INSERT)
+var ErrNotApplicable = errors.New("statement does not modify existing rows")
+
+// SkipError explains why a statement that needs a backup could not get one
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return e.Reason
+}
+
+// Snapshot is the before-image of the rows changed by one UPDATE, DELETE or TRUNCATE
+type Snapshot struct {
+	ID           string      `json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	Source       string      `json:"source,omitempty"`
+	Database     string      `json:"database,omitempty"`
+	DatabaseType string      `json:"database_type"`
+	Statement    string      `json:"statement"`
+	Verb         string      `json:"verb"`
+	Table        string      `json:"table"` // As written in the statement (quoting kept)
+	TableParts   []string    `json:"table_parts"`
+	PrimaryKey   []string    `json:"primary_key,omitempty"`
+	Columns      []string    `json:"columns"`
+	Rows         [][]*string `json:"rows"` // nil entries are NULL
+	RestoredAt   *time.Time  `json:"restored_at,omitempty"`
+}
+
+// Limits bound the size of a snapshot and the number kept on disk
+type Limits struct {
+	MaxRows      int
+	MaxBytes     int64
+	MaxSnapshots int
+}
+
+// LoadLimits reads limits from the backup section of config.yaml, using defaults for unset values
+// The second result is false when backups are disabled
+func LoadLimits() (Limits, bool) {
+	limits := Limits{MaxRows: DefaultMaxRows, MaxBytes: DefaultMaxSizeMB * 1024 * 1024, MaxSnapshots: DefaultMaxSnapshots}
+	cfg, err := config.Load()
+	if err != nil {
+		return limits, true
+	}
+	if cfg.Backup.MaxRows > 0 {
+		limits.MaxRows = cfg.Backup.MaxRows
+	}
+	if cfg.Backup.MaxSizeMB > 0 {
+		limits.MaxBytes = int64(cfg.Backup.MaxSizeMB) * 1024 * 1024
+	}
+	if cfg.Backup.MaxSnapshots > 0 {
+		limits.MaxSnapshots = cfg.Backup.MaxSnapshots
+	}
+	return limits, !cfg.Backup.Disabled
+}
+
+// Querier reads the rows to back up; *db.Connection and *db.Tx implement it
+type Querier interface {
+	QueryValues(ctx context.Context, sqlQuery string, maxRows int, maxBytes int64) (*db.ValueResult, error)
+	PrimaryKey(ctx context.Context, tableParts []string) ([]string, error)
+	DatabaseType() string
+}
+
+// Capture reads the rows a statement is about to change, using a SELECT derived from its WHERE clause
+// It returns ErrNotApplicable for statements that change no existing rows and a *SkipError when
+// the rows cannot be captured (multi-table statements, size limits, UPDATE without primary key)
+func Capture(ctx context.Context, q Querier, statement string, limits Limits) (*Snapshot, error) {
+	dialect := sqlparse.DialectForDatabaseType(q.DatabaseType())
+	statements := sqlparse.Parse(statement, dialect)
+	destructive := 0
+	for _, st := range statements {
+		switch st.Verb {
+		case "UPDATE", "DELETE", "TRUNCATE":
+			destructive++
+		}
+	}
+	if destructive == 0 {
+		return nil, ErrNotApplicable
+	}
+	if len(statements) != 1 {
+		return nil, &SkipError{Reason: "only single statements are backed up"}
+	}
+	target, ok := statements[0].WriteTarget()
+	if !ok {
+		return nil, &SkipError{Reason: "multi-table statements cannot be backed up"}
+	}
+
+	snap := &Snapshot{
+		DatabaseType: q.DatabaseType(),
+		Statement:    statement,
+		Verb:         target.Verb,
+		Table:        target.Table,
+		TableParts:   target.TableParts,
+	}
+	if target.Verb == "UPDATE" {
+		primaryKey, err := q.PrimaryKey(ctx, target.TableParts)
+		if err != nil {
+			return nil, &SkipError{Reason: fmt.Sprintf("failed to read primary key of %s: %v", target.Name(), err)}
+		}
+		if len(primaryKey) == 0 {
+			return nil, &SkipError{Reason: fmt.Sprintf("table %s has no primary key, so updated rows could not be restored", target.Name())}
+		}
+		snap.PrimaryKey = primaryKey
+	}
+
+	result, err := q.QueryValues(ctx, selectSQL(target), limits.MaxRows, limits.MaxBytes)
+	if err != nil {
+		return nil, &SkipError{Reason: fmt.Sprintf("failed to read affected rows: %v", err)}
+	}
+	if result.Truncated {
+		if limits.MaxRows > 0 && len(result.Rows) >= limits.MaxRows {
+			return nil, &SkipError{Reason: fmt.Sprintf("more than %d rows affected (backup.max_rows)", limits.MaxRows)}
+		}
+		return nil, &SkipError{Reason: fmt.Sprintf("affected rows exceed %d MB (backup.max_size_mb)", limits.MaxBytes/(1024*1024))}
+	}
+	snap.Columns = result.Columns
+	snap.Rows = result.Rows
+	if target.Verb == "UPDATE" && keyIndexes(snap.Columns, snap.PrimaryKey) == nil {
+		return nil, &SkipError{Reason: "primary key columns are missing from the captured rows"}
+	}
+	return snap, nil
+}
+
+// selectSQL selects the rows the statement changes with its own WHERE, ORDER BY and LIMIT
+func selectSQL(target *sqlparse.WriteTarget) string {
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if target.Alias != "" {
+		b.WriteString(target.Alias + ".* FROM " + target.Table + " " + target.Alias)
+	} else {
+		b.WriteString("* FROM " + target.Table)
+	}
+	if target.Where != "" {
+		b.WriteString(" WHERE " + target.Where)
+	}
+	if target.OrderBy != "" {
+		b.WriteString(" ORDER BY " + target.OrderBy)
+	}
+	if target.Limit != "" {
+		b.WriteString(" LIMIT " + target.Limit)
+	}
+	return b.String()
+}
+
+// keyIndexes returns the column index of each primary key column, or nil if one is missing
+func keyIndexes(columns, primaryKey []string) []int {
+	indexes := make([]int, len(primaryKey))
+	for i, key := range primaryKey {
+		indexes[i] = -1
+		for j, column := range columns {
+			if strings.EqualFold(column, key) {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return nil
+		}
+	}
+	return indexes
+}
+
+// GetBackupDir returns the directory holding snapshots (~/.aiq/backups)
+func GetBackupDir() (string, error) {
+	return config.GetBackupsDir()
+}
+
+// newID returns a sortable snapshot ID: creation time plus a random suffix
+func newID(t time.Time) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return t.Format("20060102-150405.000000")
+	}
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Save writes a snapshot to dir, assigning its ID and creation time, and removes the oldest
+// snapshots beyond maxSnapshots (0 keeps all)
+func Save(dir string, snap *Snapshot, maxSnapshots int) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if snap.ID == "" {
+		snap.CreatedAt = time.Now()
+		snap.ID = newID(snap.CreatedAt)
+	}
+	if err := write(dir, snap); err != nil {
+		return err
+	}
+	if maxSnapshots > 0 {
+		return prune(dir, maxSnapshots)
+	}
+	return nil
+}
+
+func write(dir string, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	path := filepath.Join(dir, snap.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a snapshot (used when the statement it was taken for did not run)
+func Delete(dir, id string) error {
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// MarkRestored records when a snapshot was restored
+func MarkRestored(dir string, snap *Snapshot) error {
+	now := time.Now()
+	snap.RestoredAt = &now
+	return write(dir, snap)
+}
+
+// Load reads a snapshot by ID
+func Load(dir, id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid snapshot id: %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot %s not found", id)
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", id, err)
+	}
+	return &snap, nil
+}
+
+// ids returns snapshot IDs in dir, oldest first
+func ids(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	var result []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		result = append(result, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// List returns the snapshots in dir, newest first
+func List(dir string) ([]*Snapshot, error) {
+	snapshotIDs, err := ids(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*Snapshot, 0, len(snapshotIDs))
+	for i := len(snapshotIDs) - 1; i >= 0; i-- {
+		snap, err := Load(dir, snapshotIDs[i])
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, nil
+}
+
+func prune(dir string, maxSnapshots int) error {
+	snapshotIDs, err := ids(dir)
+	if err != nil {
+		return err
+	}
+	for len(snapshotIDs) > maxSnapshots {
+		if err := Delete(dir, snapshotIDs[0]); err != nil {
+			return err
+		}
+		snapshotIDs = snapshotIDs[1:]
+	}
+	return nil
+}
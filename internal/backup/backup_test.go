@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// fakeQuerier records the SELECT used for the backup and returns canned rows
+type fakeQuerier struct {
+	dbType     string
+	primaryKey []string
+	result     *db.ValueResult
+	query      string
+}
+
+func (f *fakeQuerier) QueryValues(ctx context.Context, sqlQuery string, maxRows int, maxBytes int64) (*db.ValueResult, error) {
+	f.query = sqlQuery
+	result := *f.result
+	if maxRows > 0 && len(result.Rows) > maxRows {
+		result.Rows = result.Rows[:maxRows]
+		result.Truncated = true
+	}
+	return &result, nil
+}
+
+func (f *fakeQuerier) PrimaryKey(ctx context.Context, tableParts []string) ([]string, error) {
+	return f.primaryKey, nil
+}
+
+func (f *fakeQuerier) DatabaseType() string {
+	return f.dbType
+}
+
+// TestCapture tests the derived SELECT and skip reasons
+func TestCapture(t *testing.T) {
+	rows := &db.ValueResult{
+		Columns: []string{"id", "name"},
+		Rows:    [][]*string{{strPtr("1"), strPtr("a")}, {strPtr("2"), nil}},
+	}
+	limits := Limits{MaxRows: 10}
+
+	t.Run("delete uses the statement's where clause", func(t *testing.T) {
+		q := &fakeQuerier{dbType: "mysql", result: rows}
+		snap, err := Capture(context.Background(), q, "DELETE FROM users u WHERE u.active = 0 ORDER BY id LIMIT 5", limits)
+		if err != nil {
+			t.Fatalf("Capture() failed: %v", err)
+		}
+		if q.query != "SELECT u.* FROM users u WHERE u.active = 0 ORDER BY id LIMIT 5" {
+			t.Errorf("Unexpected backup query: %s", q.query)
+		}
+		if snap.Verb != "DELETE" || len(snap.Rows) != 2 {
+			t.Errorf("Unexpected snapshot: %+v", snap)
+		}
+	})
+
+	t.Run("select is not applicable", func(t *testing.T) {
+		q := &fakeQuerier{dbType: "mysql", result: rows}
+		if _, err := Capture(context.Background(), q, "SELECT * FROM users", limits); !errors.Is(err, ErrNotApplicable) {
+			t.Errorf("Expected ErrNotApplicable, got %v", err)
+		}
+	})
+
+	skips := []struct {
+		name string
+		sql  string
+		pk   []string
+	}{
+		{"update without primary key", "UPDATE users SET name = 'x' WHERE id = 1", nil},
+		{"multi-table delete", "DELETE a FROM a JOIN b ON a.id = b.id", nil},
+		{"several statements", "DELETE FROM a WHERE id = 1; DELETE FROM b WHERE id = 1", nil},
+	}
+	for _, tt := range skips {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{dbType: "mysql", primaryKey: tt.pk, result: rows}
+			var skip *SkipError
+			if _, err := Capture(context.Background(), q, tt.sql, limits); !errors.As(err, &skip) {
+				t.Errorf("Expected SkipError, got %v", err)
+			}
+		})
+	}
+
+	t.Run("row limit", func(t *testing.T) {
+		q := &fakeQuerier{dbType: "mysql", result: rows}
+		var skip *SkipError
+		if _, err := Capture(context.Background(), q, "TRUNCATE TABLE users", Limits{MaxRows: 1}); !errors.As(err, &skip) {
+			t.Errorf("Expected SkipError for row limit, got %v", err)
+		}
+	})
+}
+
+// TestCompensatingStatements tests the statements generated to undo a change
+func TestCompensatingStatements(t *testing.T) {
+	t.Run("delete is undone with inserts", func(t *testing.T) {
+		snap := &Snapshot{
+			DatabaseType: "mysql", Verb: "DELETE", TableParts: []string{"shop", "users"},
+			Columns: []string{"id", "name"},
+			Rows:    [][]*string{{strPtr("1"), strPtr("O'Brien")}, {strPtr("2"), nil}},
+		}
+		statements, err := snap.CompensatingStatements()
+		if err != nil {
+			t.Fatalf("CompensatingStatements() failed: %v", err)
+		}
+		expected := []string{
+			"INSERT INTO `shop`.`users` (`id`, `name`) VALUES ('1', 'O''Brien')",
+			"INSERT INTO `shop`.`users` (`id`, `name`) VALUES ('2', NULL)",
+		}
+		if !reflect.DeepEqual(statements, expected) {
+			t.Errorf("Expected %v, got %v", expected, statements)
+		}
+	})
+
+	t.Run("update is undone by primary key", func(t *testing.T) {
+		snap := &Snapshot{
+			DatabaseType: "postgresql", Verb: "UPDATE", TableParts: []string{"users"}, PrimaryKey: []string{"id"},
+			Columns: []string{"id", "name", "active"},
+			Rows:    [][]*string{{strPtr("7"), strPtr("bob"), strPtr("true")}},
+		}
+		statements, err := snap.CompensatingStatements()
+		if err != nil {
+			t.Fatalf("CompensatingStatements() failed: %v", err)
+		}
+		expected := []string{`UPDATE "users" SET "name" = 'bob', "active" = 'true' WHERE "id" = '7'`}
+		if !reflect.DeepEqual(statements, expected) {
+			t.Errorf("Expected %v, got %v", expected, statements)
+		}
+	})
+}
+
+// TestSaveListPrune tests snapshot storage and retention
+func TestSaveListPrune(t *testing.T) {
+	dir := t.TempDir()
+	var saved []string
+	for i := 0; i < 3; i++ {
+		snap := &Snapshot{ID: "2026010" + string(rune('1'+i)) + "-000000-aaaaaa", Verb: "DELETE"}
+		if err := Save(dir, snap, 2); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		saved = append(saved, snap.ID)
+	}
+	snapshots, err := List(dir)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != saved[2] || snapshots[1].ID != saved[1] {
+		t.Errorf("Expected the two newest snapshots newest first, got %d", len(snapshots))
+	}
+
+	if err := MarkRestored(dir, snapshots[0]); err != nil {
+		t.Fatalf("MarkRestored() failed: %v", err)
+	}
+	loaded, err := Load(dir, saved[2])
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if loaded.RestoredAt == nil {
+		t.Error("Expected RestoredAt to be set")
+	}
+	if _, err := Load(dir, "../x"); err == nil {
+		t.Error("Expected an error for an invalid id")
+	}
+}
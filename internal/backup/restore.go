@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// CompensatingStatements returns the statements that undo the snapshot's statement:
+// INSERTs for deleted or truncated rows, and UPDATEs by primary key setting updated rows back
+func (s *Snapshot) CompensatingStatements() ([]string, error) {
+	dialect := sqlparse.DialectForDatabaseType(s.DatabaseType)
+	table := sqlparse.QuoteQualified(s.TableParts, dialect)
+	quotedColumns := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		quotedColumns[i] = sqlparse.QuoteIdent(column, dialect)
+	}
+
+	statements := make([]string, 0, len(s.Rows))
+	switch s.Verb {
+	case "DELETE", "TRUNCATE":
+		for _, row := range s.Rows {
+			values := make([]string, len(row))
+			for i, value := range row {
+				values[i] = literal(value, dialect)
+			}
+			statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				table, strings.Join(quotedColumns, ", "), strings.Join(values, ", ")))
+		}
+	case "UPDATE":
+		keys := keyIndexes(s.Columns, s.PrimaryKey)
+		if keys == nil {
+			return nil, fmt.Errorf("snapshot %s has no usable primary key", s.ID)
+		}
+		isKey := make(map[int]bool, len(keys))
+		for _, idx := range keys {
+			isKey[idx] = true
+		}
+		for _, row := range s.Rows {
+			var assignments []string
+			for i, value := range row {
+				if !isKey[i] {
+					assignments = append(assignments, fmt.Sprintf("%s = %s", quotedColumns[i], literal(value, dialect)))
+				}
+			}
+			if len(assignments) == 0 {
+				continue
+			}
+			conditions := make([]string, len(keys))
+			for i, idx := range keys {
+				if row[idx] == nil {
+					return nil, fmt.Errorf("snapshot %s has a NULL primary key value", s.ID)
+				}
+				conditions[i] = fmt.Sprintf("%s = %s", quotedColumns[idx], literal(row[idx], dialect))
+			}
+			statements = append(statements, fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+				table, strings.Join(assignments, ", "), strings.Join(conditions, " AND ")))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported snapshot statement: %s", s.Verb)
+	}
+	return statements, nil
+}
+
+func literal(value *string, dialect sqlparse.Dialect) string {
+	if value == nil {
+		return "NULL"
+	}
+	return sqlparse.QuoteLiteral(*value, dialect)
+}
+
+// Restore runs the compensating statements in one transaction and returns the number of rows written
+// Nothing is changed if any statement fails
+func Restore(ctx context.Context, conn *db.Connection, snap *Snapshot) (int64, error) {
+	statements, err := snap.CompensatingStatements()
+	if err != nil {
+		return 0, err
+	}
+	tx, err := conn.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, statement := range statements {
+		n, err := tx.ExecuteNonQuery(ctx, statement)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to restore snapshot %s: %w", snap.ID, err)
+		}
+		total += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
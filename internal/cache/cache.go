@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+const (
+	// DefaultTTL is how long a cached result is reused
+	DefaultTTL = 5 * time.Minute
+
+	// DefaultMaxEntries is the number of results kept
+	DefaultMaxEntries = 200
+
+	// DefaultMaxSizeMB is the total size of cached results
+	DefaultMaxSizeMB = 64
+)
+
+// Options configure a Cache
+type Options struct {
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int64
+	Dir        string // When set, results are also stored as files in this directory
+}
+
+// Entry is a cached query result
+type Entry struct {
+	Scope    string     `json:"scope"` // Source and database the result was read from (see Invalidate)
+	Columns  []string   `json:"columns"`
+	Rows     [][]string `json:"rows"`
+	StoredAt time.Time  `json:"stored_at"`
+}
+
+// size estimates the memory an entry takes
+func (e *Entry) size() int64 {
+	n := int64(len(e.Scope))
+	for _, col := range e.Columns {
+		n += int64(len(col)) + 16
+	}
+	for _, row := range e.Rows {
+		n += 24
+		for _, cell := range row {
+			n += int64(len(cell)) + 16
+		}
+	}
+	return n
+}
+
+// Cache is a least-recently-used cache of query results with a TTL
+// It is safe for concurrent use
+type Cache struct {
+	opts Options
+
+	mu      sync.Mutex
+	order   *list.List               // Front is the most recently used
+	entries map[string]*list.Element // Key -> element holding an *item
+	bytes   int64
+}
+
+type item struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// New creates a cache; with a directory the results stored there by earlier sessions are loaded
+func New(opts Options) *Cache {
+	c := &Cache{opts: opts, order: list.New(), entries: make(map[string]*list.Element)}
+	if opts.Dir != "" {
+		c.load()
+	}
+	return c
+}
+
+var (
+	defaultCache *Cache
+	defaultOnce  sync.Once
+)
+
+// Default returns the cache configured in the cache section of config.yaml, using defaults for unset values
+// It returns nil unless caching is enabled (cache.enabled)
+func Default() *Cache {
+	defaultOnce.Do(func() {
+		opts := Options{TTL: DefaultTTL, MaxEntries: DefaultMaxEntries, MaxBytes: DefaultMaxSizeMB * 1024 * 1024}
+		cfg, err := config.Load()
+		if err != nil || !cfg.Cache.Enabled {
+			return
+		}
+		if cfg.Cache.TTLSeconds > 0 {
+			opts.TTL = time.Duration(cfg.Cache.TTLSeconds) * time.Second
+		}
+		if cfg.Cache.MaxEntries > 0 {
+			opts.MaxEntries = cfg.Cache.MaxEntries
+		}
+		if cfg.Cache.MaxSizeMB > 0 {
+			opts.MaxBytes = int64(cfg.Cache.MaxSizeMB) * 1024 * 1024
+		}
+		if cfg.Cache.Disk {
+			if dir, err := config.GetCacheDir(); err == nil {
+				opts.Dir = dir
+			}
+		}
+		defaultCache = New(opts)
+	})
+	return defaultCache
+}
+
+// TTL returns how long results are reused
+func (c *Cache) TTL() time.Duration {
+	return c.opts.TTL
+}
+
+// Get returns the unexpired result stored under key
+// On a miss in memory the cache directory is checked, so results stored by other sessions are reused
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		it := el.Value.(*item)
+		if c.expired(it.entry) {
+			c.remove(el)
+			return nil, false
+		}
+		c.order.MoveToFront(el)
+		return it.entry, true
+	}
+	if c.opts.Dir == "" {
+		return nil, false
+	}
+	entry, err := readEntry(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	if c.expired(entry) {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	c.add(key, entry, false)
+	return entry, true
+}
+
+// Put stores a result under key, replacing an earlier one
+// Results larger than the size bound are not stored
+func (c *Cache) Put(key string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	if c.opts.MaxBytes > 0 && entry.size() > c.opts.MaxBytes {
+		return
+	}
+	c.add(key, entry, true)
+}
+
+// Invalidate drops the results read from scope, e.g. after a statement changed its data
+func (c *Cache) Invalidate(scope string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*item).entry.Scope == scope {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Clear drops all results
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		c.remove(el)
+		el = next
+	}
+}
+
+// Len returns the number of results in memory
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) expired(entry *Entry) bool {
+	return c.opts.TTL > 0 && time.Since(entry.StoredAt) > c.opts.TTL
+}
+
+// add inserts an entry as the most recently used one and evicts entries over the bounds
+// persist writes the entry to the cache directory
+func (c *Cache) add(key string, entry *Entry, persist bool) {
+	it := &item{key: key, entry: entry, size: entry.size()}
+	c.entries[key] = c.order.PushFront(it)
+	c.bytes += it.size
+	if persist && c.opts.Dir != "" {
+		// The disk copy only saves a later session the query, so failing to write it is not an error
+		writeEntry(c.path(key), entry)
+	}
+	for c.order.Len() > 1 && ((c.opts.MaxEntries > 0 && c.order.Len() > c.opts.MaxEntries) || (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes)) {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from memory and the cache directory
+func (c *Cache) remove(el *list.Element) {
+	it := el.Value.(*item)
+	c.order.Remove(el)
+	delete(c.entries, it.key)
+	c.bytes -= it.size
+	if c.opts.Dir != "" {
+		os.Remove(c.path(it.key))
+	}
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.opts.Dir, key+".json")
+}
+
+// load reads the results in the cache directory, most recent last, dropping expired and unreadable files
+func (c *Cache) load() {
+	files, err := filepath.Glob(filepath.Join(c.opts.Dir, "*.json"))
+	if err != nil {
+		return
+	}
+	type stored struct {
+		key   string
+		entry *Entry
+	}
+	var valid []stored
+	for _, file := range files {
+		entry, err := readEntry(file)
+		if err != nil || c.expired(entry) {
+			os.Remove(file)
+			continue
+		}
+		valid = append(valid, stored{key: strings.TrimSuffix(filepath.Base(file), ".json"), entry: entry})
+	}
+	sort.Slice(valid, func(i, j int) bool { return valid[i].entry.StoredAt.Before(valid[j].entry.StoredAt) })
+	for _, s := range valid {
+		c.add(s.key, s.entry, false)
+	}
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func writeEntry(path string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Results may hold sensitive data, so only the user can read them
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// hashKey hashes the parts of a cache key into a file-name-safe string
+func hashKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
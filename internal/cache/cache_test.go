@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+func entry(scope string, rows ...string) *Entry {
+	e := &Entry{Scope: scope, Columns: []string{"v"}, StoredAt: time.Now()}
+	for _, r := range rows {
+		e.Rows = append(e.Rows, []string{r})
+	}
+	return e
+}
+
+func TestGetPut(t *testing.T) {
+	c := New(Options{TTL: time.Minute})
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get on empty cache returned an entry")
+	}
+	c.Put("k", entry("db", "1"))
+	got, ok := c.Get("k")
+	if !ok || got.Rows[0][0] != "1" {
+		t.Fatalf("Get = %v, %v; want stored entry", got, ok)
+	}
+	c.Put("k", entry("db", "2"))
+	if got, _ := c.Get("k"); got.Rows[0][0] != "2" || c.Len() != 1 {
+		t.Errorf("Put did not replace the entry (len %d)", c.Len())
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(Options{TTL: time.Minute})
+	old := entry("db", "1")
+	old.StoredAt = time.Now().Add(-2 * time.Minute)
+	c.Put("k", old)
+	if _, ok := c.Get("k"); ok {
+		t.Error("Get returned an expired entry")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expired entry kept, len = %d", c.Len())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := New(Options{MaxEntries: 2})
+	c.Put("a", entry("db", "1"))
+	c.Put("b", entry("db", "2"))
+	c.Get("a") // b is now the least recently used
+	c.Put("c", entry("db", "3"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+
+	small := entry("db", "x")
+	c = New(Options{MaxBytes: small.size() * 2})
+	c.Put("a", entry("db", "1"))
+	c.Put("b", entry("db", "2"))
+	c.Put("c", entry("db", "3"))
+	if c.Len() != 2 {
+		t.Errorf("len = %d over size bound, want 2", c.Len())
+	}
+	c.Put("big", entry("db", strings.Repeat("x", int(small.size()*3))))
+	if _, ok := c.Get("big"); ok {
+		t.Error("entry larger than the size bound was stored")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c := New(Options{})
+	c.Put("a", entry("src/db1", "1"))
+	c.Put("b", entry("src/db2", "2"))
+	c.Invalidate("src/db1")
+	if _, ok := c.Get("a"); ok {
+		t.Error("entry of invalidated scope kept")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("entry of other scope dropped")
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Errorf("len after Clear = %d", c.Len())
+	}
+}
+
+func TestDisk(t *testing.T) {
+	dir := t.TempDir()
+	c := New(Options{TTL: time.Minute, Dir: dir})
+	c.Put("a", entry("db", "1"))
+	c.Put("b", entry("db", "2"))
+
+	// A later session loads the stored results
+	reloaded := New(Options{TTL: time.Minute, Dir: dir})
+	if reloaded.Len() != 2 {
+		t.Fatalf("reloaded len = %d, want 2", reloaded.Len())
+	}
+	if got, ok := reloaded.Get("a"); !ok || got.Rows[0][0] != "1" {
+		t.Errorf("reloaded Get = %v, %v", got, ok)
+	}
+
+	// Results stored by another session after start are found on disk
+	c.Put("c", entry("db", "3"))
+	if _, ok := reloaded.Get("c"); !ok {
+		t.Error("result stored by another session not found")
+	}
+
+	reloaded.Invalidate("db")
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 0 {
+		t.Errorf("files left after Invalidate: %v", files)
+	}
+
+	// Expired files are removed on load
+	old := entry("db", "1")
+	old.StoredAt = time.Now().Add(-time.Hour)
+	writeEntry(filepath.Join(dir, "old.json"), old)
+	New(Options{TTL: time.Minute, Dir: dir})
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Error("expired file not removed")
+	}
+}
+
+func TestKey(t *testing.T) {
+	same := []string{
+		"SELECT id, name FROM users WHERE id = 1",
+		"select id, name\n  from users -- comment\n where id = 1;",
+		"Select  id,name From users Where id=1 /* c */ ;",
+	}
+	want := Key("s/db", same[0], sqlparse.DialectMySQL)
+	for _, sql := range same[1:] {
+		if got := Key("s/db", sql, sqlparse.DialectMySQL); got != want {
+			t.Errorf("Key(%q) differs from Key(%q)", sql, same[0])
+		}
+	}
+
+	different := []string{
+		"SELECT id, name FROM Users WHERE id = 1",
+		"SELECT id, name FROM users WHERE id = 2",
+		"SELECT id, name FROM users WHERE id = '1'",
+		"SELECT id, name FROM `users` WHERE id = 1",
+	}
+	for _, sql := range different {
+		if Key("s/db", sql, sqlparse.DialectMySQL) == want {
+			t.Errorf("Key(%q) equals Key(%q)", sql, same[0])
+		}
+	}
+	if Key("s/other", same[0], sqlparse.DialectMySQL) == want {
+		t.Error("keys of different scopes are equal")
+	}
+}
+
+// TestKeyContextKeywords tests that words that are keywords in some positions only have their case
+// folded there, so that tables and columns named like them keep their case
+func TestKeyContextKeywords(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"SELECT first FROM t", "SELECT FIRST FROM t", false},
+		{"SELECT * FROM row", "SELECT * FROM ROW", false},
+		{"SELECT next, last FROM t", "SELECT Next, Last FROM t", false},
+		{"SELECT current FROM t WHERE full = 1", "SELECT CURRENT FROM t WHERE FULL = 1", false},
+		{"SELECT a FROM rows ORDER BY a", "SELECT a FROM ROWS ORDER BY a", false},
+		{"SELECT a, CASE WHEN a > 0 THEN 1 end AS end FROM t", "SELECT a, CASE WHEN a > 0 THEN 1 END AS END FROM t", false},
+		{"SELECT a FROM t ORDER BY a desc nulls first", "SELECT a FROM t ORDER BY a DESC NULLS FIRST", true},
+		{"SELECT a FROM t ORDER BY a nulls last", "SELECT a FROM t ORDER BY a NULLS LAST", true},
+		{"SELECT a FROM t offset 10 rows fetch next 5 rows only", "SELECT a FROM t OFFSET 10 ROWS FETCH NEXT 5 ROWS ONLY", true},
+		{"SELECT a FROM t fetch first 1 row only", "SELECT a FROM t FETCH FIRST 1 ROW ONLY", true},
+		{
+			"SELECT sum(a) over (partition by b order by c rows between unbounded preceding and current row) FROM t",
+			"SELECT SUM(a) OVER (PARTITION BY b ORDER BY c ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) FROM t",
+			true,
+		},
+		{"SELECT case when a then 1 else 2 end FROM t", "SELECT CASE WHEN a THEN 1 ELSE 2 END FROM t", true},
+		{"with recursive r AS (SELECT 1) SELECT * FROM r", "WITH RECURSIVE r AS (SELECT 1) SELECT * FROM r", true},
+		{"SELECT * FROM a full outer join b ON a.id = b.id", "SELECT * FROM a FULL OUTER JOIN b ON a.id = b.id", true},
+	}
+	for _, tt := range tests {
+		for _, dialect := range sqlparse.Dialects {
+			if equal := Key("s/db", tt.a, dialect) == Key("s/db", tt.b, dialect); equal != tt.equal {
+				t.Errorf("%s: Key(%q) == Key(%q) is %v, want %v", dialect, tt.a, tt.b, equal, tt.equal)
+			}
+		}
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM orders", true},
+		{"WITH t AS (SELECT 1) SELECT * FROM t", true},
+		{"SELECT count(*) FROM a; SELECT count(*) FROM b", true},
+		{"SELECT * FROM orders WHERE created_at > NOW() - INTERVAL 1 DAY", false},
+		{"SELECT * FROM orders ORDER BY rand()", false},
+		{"SELECT * FROM orders WHERE d = CURRENT_DATE", false},
+		{"SELECT * FROM information_schema.tables", false},
+		{"SELECT * FROM orders FOR UPDATE", false},
+		{"SHOW TABLES", false},
+		{"UPDATE orders SET x = 1", false},
+		{"SELECT 1; DELETE FROM orders WHERE id = 1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Cacheable(tt.sql, sqlparse.DialectMySQL); got != tt.want {
+			t.Errorf("Cacheable(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
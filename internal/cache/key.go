@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// volatileFunctions return a different value on each call, so results using them are not cached
+var volatileFunctions = map[string]bool{
+	"NOW": true, "SYSDATE": true, "CURDATE": true, "CURTIME": true,
+	"CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"LOCALTIME": true, "LOCALTIMESTAMP": true, "UTC_DATE": true, "UTC_TIME": true, "UTC_TIMESTAMP": true,
+	"UNIX_TIMESTAMP": true, "CLOCK_TIMESTAMP": true, "STATEMENT_TIMESTAMP": true, "TIMEOFDAY": true,
+	"RAND": true, "RANDOM": true, "UUID": true, "UUID_SHORT": true, "GEN_RANDOM_UUID": true,
+	"NEXTVAL": true, "CURRVAL": true, "LASTVAL": true, "LAST_INSERT_ID": true, "FOUND_ROWS": true,
+	"CONNECTION_ID": true, "PG_BACKEND_PID": true, "SLEEP": true, "PG_SLEEP": true,
+}
+
+// systemSchemas hold server state rather than data, so reads of them are not cached
+var systemSchemas = []string{"information_schema.", "performance_schema.", "pg_catalog.", "mysql.", "sys."}
+
+// keywords are uppercased when normalizing SQL; other words keep their case, since identifiers may be case-sensitive
+// Only reserved words are listed: words that can also name a table or column are in contextKeywords
+var keywords = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "IS": true, "NULL": true, "LIKE": true, "ILIKE": true, "BETWEEN": true, "EXISTS": true,
+	"AS": true, "ON": true, "USING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"OUTER": true, "CROSS": true, "NATURAL": true, "GROUP": true, "BY": true, "HAVING": true,
+	"ORDER": true, "ASC": true, "DESC": true, "LIMIT": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"ALL": true, "WITH": true, "CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "TRUE": true,
+	"FALSE": true, "TABLE": true, "VALUES": true,
+}
+
+// contextKeywords are keywords that are also valid identifiers (e.g. a "first" column or a "row" table)
+// They are uppercased only where the neighbouring tokens show that they are used as keywords;
+// END is handled separately, as it closes a CASE
+var contextKeywords = map[string]func(prev, next sqlparse.Token) bool{
+	"NULLS":     func(prev, next sqlparse.Token) bool { return next.IsWord("FIRST") || next.IsWord("LAST") },
+	"FIRST":     func(prev, next sqlparse.Token) bool { return prev.IsWord("NULLS") || prev.IsWord("FETCH") },
+	"LAST":      func(prev, next sqlparse.Token) bool { return prev.IsWord("NULLS") },
+	"FETCH":     func(prev, next sqlparse.Token) bool { return next.IsWord("FIRST") || next.IsWord("NEXT") },
+	"NEXT":      func(prev, next sqlparse.Token) bool { return prev.IsWord("FETCH") },
+	"ROW":       func(prev, next sqlparse.Token) bool { return prev.IsWord("CURRENT") || rowCount(next) },
+	"ROWS":      func(prev, next sqlparse.Token) bool { return frameStart(next) || rowCount(next) },
+	"RANGE":     func(prev, next sqlparse.Token) bool { return frameStart(next) },
+	"ONLY":      func(prev, next sqlparse.Token) bool { return prev.IsWord("ROW") || prev.IsWord("ROWS") },
+	"CURRENT":   func(prev, next sqlparse.Token) bool { return next.IsWord("ROW") },
+	"UNBOUNDED": func(prev, next sqlparse.Token) bool { return next.IsWord("PRECEDING") || next.IsWord("FOLLOWING") },
+	"PRECEDING": func(prev, next sqlparse.Token) bool {
+		return prev.IsWord("UNBOUNDED") || prev.Kind == sqlparse.TokenNumber
+	},
+	"FOLLOWING": func(prev, next sqlparse.Token) bool {
+		return prev.IsWord("UNBOUNDED") || prev.Kind == sqlparse.TokenNumber
+	},
+	"OVER":      func(prev, next sqlparse.Token) bool { return prev.IsSymbol(")") },
+	"PARTITION": func(prev, next sqlparse.Token) bool { return next.IsWord("BY") },
+	"RECURSIVE": func(prev, next sqlparse.Token) bool { return prev.IsWord("WITH") },
+	"FULL":      func(prev, next sqlparse.Token) bool { return next.IsWord("JOIN") || next.IsWord("OUTER") },
+	"INTERVAL": func(prev, next sqlparse.Token) bool {
+		return next.Kind == sqlparse.TokenString || next.Kind == sqlparse.TokenNumber
+	},
+	"OFFSET": func(prev, next sqlparse.Token) bool {
+		return next.Kind == sqlparse.TokenNumber || next.IsSymbol("?") || next.IsSymbol("$")
+	},
+}
+
+// rowCount reports whether tok follows ROW or ROWS closing "OFFSET n ROWS" or "FETCH FIRST n ROWS ONLY"
+func rowCount(tok sqlparse.Token) bool {
+	return tok.IsWord("ONLY") || tok.IsWord("FETCH")
+}
+
+// frameStart reports whether tok starts the bounds of a window frame after ROWS or RANGE
+func frameStart(tok sqlparse.Token) bool {
+	return tok.IsWord("BETWEEN") || tok.IsWord("UNBOUNDED") || tok.IsWord("CURRENT") || tok.Kind == sqlparse.TokenNumber
+}
+
+// Cacheable reports whether the result of sql may be cached: every statement is a read-only
+// SELECT that uses no volatile function and reads no system schema
+func Cacheable(sql string, dialect sqlparse.Dialect) bool {
+	statements := sqlparse.Parse(sql, dialect)
+	if len(statements) == 0 {
+		return false
+	}
+	for _, st := range statements {
+		if !st.IsReadOnly() || !st.ReturnsRows() {
+			return false
+		}
+		switch st.Verb {
+		case "SELECT", "VALUES", "TABLE":
+		default:
+			// SHOW, DESCRIBE and EXPLAIN report server state, which is cheap to read and should be current
+			return false
+		}
+		for _, tok := range st.Tokens {
+			if tok.Kind == sqlparse.TokenWord && volatileFunctions[tok.Upper] {
+				return false
+			}
+		}
+		for _, table := range st.Tables {
+			lower := strings.ToLower(table)
+			for _, schema := range systemSchemas {
+				if strings.HasPrefix(lower, schema) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Key returns the cache key of sql run in scope (source and database)
+// The SQL is normalized first, so statements differing only in whitespace, comments,
+// keyword case or a trailing semicolon share a key
+func Key(scope, sql string, dialect sqlparse.Dialect) string {
+	return hashKey(scope, dialect.String(), normalize(sql, dialect))
+}
+
+// normalize rewrites sql as its tokens separated by single spaces, with keywords and function names uppercased
+func normalize(sql string, dialect sqlparse.Dialect) string {
+	tokens := sqlparse.Tokenize(sql, dialect)
+	for len(tokens) > 0 && tokens[len(tokens)-1].Kind == sqlparse.TokenSemicolon {
+		tokens = tokens[:len(tokens)-1]
+	}
+	parts := make([]string, len(tokens))
+	cases := 0 // CASE expressions not yet closed by END
+	for i, tok := range tokens {
+		switch tok.Kind {
+		case sqlparse.TokenWord:
+			// Out-of-range neighbours are zero tokens, which match no keyword or symbol
+			var prev, next sqlparse.Token
+			if i > 0 {
+				prev = tokens[i-1]
+			}
+			if i+1 < len(tokens) {
+				next = tokens[i+1]
+			}
+			keyword := keywords[tok.Upper] || next.IsSymbol("(")
+			if inContext, ok := contextKeywords[tok.Upper]; ok && inContext(prev, next) {
+				keyword = true
+			}
+			switch {
+			case tok.Upper == "CASE":
+				cases++
+			case tok.Upper == "END" && cases > 0:
+				cases--
+				keyword = true
+			}
+			if keyword {
+				parts[i] = tok.Upper
+			} else {
+				parts[i] = tok.Text
+			}
+		case sqlparse.TokenQuotedIdent:
+			parts[i] = sqlparse.QuoteIdent(tok.Text, dialect)
+		case sqlparse.TokenString:
+			parts[i] = sqlparse.QuoteLiteral(tok.Text, dialect)
+		default:
+			parts[i] = tok.Text
+		}
+	}
+	return strings.Join(parts, " ")
+}
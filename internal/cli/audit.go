@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/audit"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// RunAuditCommand implements "aiq audit [verify] [flags]"
+func RunAuditCommand(args []string) error {
+	if len(args) > 0 && args[0] == "verify" {
+		return runAuditVerify()
+	}
+
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	since := fs.String("since", "", "Only show records newer than a duration (e.g. 24h, 7d) or date (YYYY-MM-DD)")
+	until := fs.String("until", "", "Only show records older than a duration or date")
+	sourceName := fs.String("source", "", "Filter by source name")
+	database := fs.String("db", "", "Filter by database name")
+	toolName := fs.String("tool", "", "Filter by tool name (e.g. execute_sql, execute_command)")
+	risk := fs.String("risk", "", "Filter by risk level (low, high)")
+	approval := fs.String("approval", "", "Filter by approval (auto_approved, user_confirmed, user_rejected, policy_denied)")
+	osUser := fs.String("user", "", "Filter by OS user")
+	errorsOnly := fs.Bool("errors", false, "Only show records with errors")
+	grep := fs.String("grep", "", "Only show records whose statement contains this text")
+	limit := fs.Int("limit", 50, "Maximum number of records to show (newest kept, 0 for all)")
+	asJSON := fs.Bool("json", false, "Print raw JSON lines instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: aiq audit [verify] [flags]")
+		fmt.Fprintln(fs.Output(), "")
+		fmt.Fprintln(fs.Output(), "  verify    Check the audit log hash chain for tampering")
+		fmt.Fprintln(fs.Output(), "")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	filter := audit.Filter{
+		Source:     *sourceName,
+		Database:   *database,
+		Tool:       *toolName,
+		RiskLevel:  *risk,
+		Approval:   audit.Approval(*approval),
+		OSUser:     *osUser,
+		ErrorsOnly: *errorsOnly,
+		Contains:   *grep,
+		Limit:      *limit,
+	}
+	var err error
+	if *since != "" {
+		if filter.Since, err = parseAuditTime(*since); err != nil {
+			return fmt.Errorf("invalid --since value: %w", err)
+		}
+	}
+	if *until != "" {
+		if filter.Until, err = parseAuditTime(*until); err != nil {
+			return fmt.Errorf("invalid --until value: %w", err)
+		}
+	}
+
+	dir, err := audit.GetLogDir()
+	if err != nil {
+		return fmt.Errorf("failed to get audit log directory: %w", err)
+	}
+	records, err := audit.Query(dir, filter)
+	if err != nil {
+		return fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, rec := range records {
+			if err := encoder.Encode(rec); err != nil {
+				return fmt.Errorf("failed to encode audit record: %w", err)
+			}
+		}
+		return nil
+	}
+
+	if len(records) == 0 {
+		ui.ShowInfo("No audit records found.")
+		return nil
+	}
+
+	headers := []string{"Seq", "Time", "User", "Source", "Database", "Tool", "Risk", "Approval", "Duration", "Rows", "Statement", "Error"}
+	rows := make([][]string, 0, len(records))
+	for _, rec := range records {
+		rowCount := ""
+		if rec.RowsAffected != nil {
+			rowCount = fmt.Sprintf("%d affected", *rec.RowsAffected)
+		} else if rec.RowsReturned != nil {
+			rowCount = fmt.Sprintf("%d returned", *rec.RowsReturned)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(rec.Seq, 10),
+			rec.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			rec.OSUser,
+			rec.Source,
+			rec.Database,
+			rec.Tool,
+			rec.RiskLevel,
+			string(rec.Approval),
+			fmt.Sprintf("%dms", rec.DurationMs),
+			rowCount,
+			truncateAuditText(rec.Statement, 60),
+			truncateAuditText(rec.Error, 40),
+		})
+	}
+	ui.PrintTable(headers, rows)
+	fmt.Println(ui.HintText(fmt.Sprintf("%d record(s)", len(records))))
+	return nil
+}
+
+// runAuditVerify checks the hash chain and reports any problems found
+func runAuditVerify() error {
+	dir, err := audit.GetLogDir()
+	if err != nil {
+		return fmt.Errorf("failed to get audit log directory: %w", err)
+	}
+	result, err := audit.Verify(dir)
+	if err != nil {
+		return fmt.Errorf("failed to verify audit log: %w", err)
+	}
+
+	if result.Records == 0 && result.OK() {
+		ui.ShowInfo("Audit log is empty.")
+		return nil
+	}
+	if result.OK() {
+		ui.ShowSuccess(fmt.Sprintf("Audit log verified: %d record(s), seq %d-%d, chain intact.", result.Records, result.FirstSeq, result.LastSeq))
+		return nil
+	}
+
+	for _, problem := range result.Problems {
+		ui.ShowError(problem)
+	}
+	return fmt.Errorf("audit log verification failed: %d problem(s) found", len(result.Problems))
+}
+
+// parseAuditTime accepts a relative duration (30m, 24h, 7d) or an absolute date/time
+func parseAuditTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a duration (e.g. 24h, 7d) or a date (YYYY-MM-DD), got %q", value)
+}
+
+// truncateAuditText shortens text to max runes for table display, collapsing whitespace
+func truncateAuditText(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/aiq/aiq/internal/source"
+	"github.com/aiq/aiq/internal/sql"
+)
+
+// RunMCPCommand implements "aiq mcp serve"
+func RunMCPCommand(args []string) error {
+	if len(args) == 0 {
+		printMCPUsage()
+		return nil
+	}
+	switch args[0] {
+	case "serve":
+		return runMCPServe(args[1:])
+	case "-h", "--help", "help":
+		printMCPUsage()
+		return nil
+	default:
+		printMCPUsage()
+		return fmt.Errorf("unknown mcp command: %s", args[0])
+	}
+}
+
+func printMCPUsage() {
+	fmt.Println("Usage: aiq mcp serve --source <name> [--database <name>] [--read-only]")
+	fmt.Println()
+	fmt.Println("Serves the tools execute_sql, list_tables, describe_table and render_chart for a")
+	fmt.Println("configured source over the MCP stdio transport, for use by other agents and IDEs.")
+	fmt.Println("Calls follow the risk policy and are recorded in the audit log. Statements that need")
+	fmt.Println("confirmation are confirmed through the client (elicitation) or refused when the client")
+	fmt.Println("cannot ask the user; with --read-only they are always refused.")
+}
+
+// runMCPServe serves a source until the client closes stdin
+func runMCPServe(args []string) error {
+	fs := flag.NewFlagSet("mcp serve", flag.ContinueOnError)
+	// stdout carries the protocol, so help and errors go to stderr
+	fs.SetOutput(os.Stderr)
+	sourceName := fs.String("source", "", "Source to serve (see 'aiq' > source)")
+	database := fs.String("database", "", "Database to use instead of the source's default")
+	readOnly := fs.Bool("read-only", false, "Refuse every statement that requires confirmation")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if *sourceName == "" {
+		return fmt.Errorf("usage: aiq mcp serve --source <name> [--database <name>] [--read-only]")
+	}
+	src, err := source.GetSource(*sourceName)
+	if err != nil {
+		return fmt.Errorf("failed to load source: %w", err)
+	}
+
+	// Keep the protocol on the real stdout; UI messages printed while serving go to stderr
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = protocolOut }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return sql.ServeMCP(ctx, src, sql.MCPServeOptions{Database: *database, ReadOnly: *readOnly}, os.Stdin, protocolOut)
+}
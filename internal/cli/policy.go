@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// policyTestCall is one sample tool call in a --calls file
+type policyTestCall struct {
+	Tool string                 `yaml:"tool" json:"tool"`
+	Args map[string]interface{} `yaml:"args" json:"args"`
+}
+
+// RunPolicyCommand implements "aiq policy <test|show> [flags]"
+func RunPolicyCommand(args []string) error {
+	if len(args) == 0 {
+		printPolicyUsage()
+		return nil
+	}
+	switch args[0] {
+	case "test":
+		return runPolicyTest(args[1:])
+	case "show":
+		return runPolicyShow(args[1:])
+	case "-h", "--help", "help":
+		printPolicyUsage()
+		return nil
+	default:
+		printPolicyUsage()
+		return fmt.Errorf("unknown policy command: %s", args[0])
+	}
+}
+
+func printPolicyUsage() {
+	fmt.Println("Usage: aiq policy <command> [flags]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  test    Dry-run sample tool calls against the policy and show the decisions")
+	fmt.Println("  show    List the effective rules in evaluation order")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println(`  aiq policy test --sql "DELETE FROM orders" --source prod`)
+	fmt.Println(`  aiq policy test --command "rm -rf build" --policy ./draft-policy.yaml`)
+	fmt.Println(`  aiq policy test --url https://api.example.com/v1 --method POST`)
+	fmt.Println(`  aiq policy test --calls samples.yaml`)
+}
+
+// loadPolicyForCLI builds the policy from --policy (a draft file) or the installed policy.yaml
+func loadPolicyForCLI(policyPath, sourceName string) (*policy.Policy, string, error) {
+	if policyPath == "" {
+		path, err := config.GetPolicyFilePath()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get policy file path: %w", err)
+		}
+		policyPath = path
+	}
+	file, err := policy.LoadFile(policyPath)
+	if err != nil {
+		return nil, "", err
+	}
+	p, err := policy.New(file, sourceName)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid policy file %s: %w", policyPath, err)
+	}
+	if file == nil {
+		policyPath += " (not found, built-in defaults only)"
+	}
+	return p, policyPath, nil
+}
+
+// runPolicyTest evaluates sample tool calls and prints the decision for each
+func runPolicyTest(args []string) error {
+	fs := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "Policy file to test (default ~/.aiq/config/policy.yaml)")
+	sourceName := fs.String("source", "", "Apply the overrides for this source")
+	toolName := fs.String("tool", "", "Tool name for --args (e.g. execute_sql)")
+	argsJSON := fs.String("args", "", "Tool arguments as a JSON object")
+	sqlText := fs.String("sql", "", "Sample execute_sql statement")
+	command := fs.String("command", "", "Sample execute_command command line")
+	urlText := fs.String("url", "", "Sample http_request URL")
+	method := fs.String("method", "", "HTTP method for --url (default GET)")
+	path := fs.String("path", "", "Sample file_operations path")
+	operation := fs.String("operation", "read", "File operation for --path")
+	callsFile := fs.String("calls", "", "YAML or JSON file with a list of {tool, args} sample calls")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	var calls []policyTestCall
+	if *sqlText != "" {
+		calls = append(calls, policyTestCall{Tool: "execute_sql", Args: map[string]interface{}{"sql": *sqlText}})
+	}
+	if *command != "" {
+		calls = append(calls, policyTestCall{Tool: "execute_command", Args: map[string]interface{}{"command": *command}})
+	}
+	if *urlText != "" {
+		callArgs := map[string]interface{}{"url": *urlText}
+		if *method != "" {
+			callArgs["method"] = *method
+		}
+		calls = append(calls, policyTestCall{Tool: "http_request", Args: callArgs})
+	}
+	if *path != "" {
+		calls = append(calls, policyTestCall{Tool: "file_operations", Args: map[string]interface{}{"path": *path, "operation": *operation}})
+	}
+	if *argsJSON != "" {
+		if *toolName == "" {
+			return fmt.Errorf("--args requires --tool")
+		}
+		var callArgs map[string]interface{}
+		if err := json.Unmarshal([]byte(*argsJSON), &callArgs); err != nil {
+			return fmt.Errorf("invalid --args JSON: %w", err)
+		}
+		calls = append(calls, policyTestCall{Tool: *toolName, Args: callArgs})
+	}
+	if *callsFile != "" {
+		data, err := os.ReadFile(*callsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read calls file: %w", err)
+		}
+		var fileCalls []policyTestCall
+		// YAML is a superset of JSON, so one parser handles both formats
+		if err := yaml.Unmarshal(data, &fileCalls); err != nil {
+			return fmt.Errorf("failed to parse calls file: %w", err)
+		}
+		calls = append(calls, fileCalls...)
+	}
+	if len(calls) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no sample calls given")
+	}
+
+	p, loadedFrom, err := loadPolicyForCLI(*policyPath, *sourceName)
+	if err != nil {
+		return err
+	}
+	ui.ShowInfo(fmt.Sprintf("Policy: %s", loadedFrom))
+	if *sourceName != "" {
+		ui.ShowInfo(fmt.Sprintf("Source: %s", *sourceName))
+	}
+
+	headers := []string{"Tool", "Call", "Action", "Rule", "Origin", "Reason"}
+	rows := make([][]string, 0, len(calls))
+	for _, c := range calls {
+		if c.Args == nil {
+			c.Args = map[string]interface{}{}
+		}
+		decision := p.Evaluate(c.Tool, c.Args)
+		rule := decision.Rule
+		if !decision.Matched() {
+			rule = "-"
+		}
+		rows = append(rows, []string{
+			c.Tool,
+			truncateAuditText(describePolicyCall(c), 60),
+			string(decision.Action),
+			rule,
+			string(decision.Origin),
+			decision.Reason,
+		})
+	}
+	ui.PrintTable(headers, rows)
+	fmt.Println(ui.HintText("Built-in default rules can be overridden by an LLM-provided risk_level; user rules cannot."))
+	return nil
+}
+
+// describePolicyCall returns a short description of a sample call for display
+func describePolicyCall(c policyTestCall) string {
+	for _, key := range []string{"sql", "command"} {
+		if s, ok := c.Args[key].(string); ok {
+			return s
+		}
+	}
+	if u, ok := c.Args["url"].(string); ok {
+		method := "GET"
+		if m, ok := c.Args["method"].(string); ok && m != "" {
+			method = strings.ToUpper(m)
+		}
+		return method + " " + u
+	}
+	if p, ok := c.Args["path"].(string); ok {
+		op, _ := c.Args["operation"].(string)
+		return strings.TrimSpace(op + " " + p)
+	}
+	data, _ := json.Marshal(c.Args)
+	return string(data)
+}
+
+// runPolicyShow prints the effective rules in evaluation order
+func runPolicyShow(args []string) error {
+	fs := flag.NewFlagSet("policy show", flag.ContinueOnError)
+	policyPath := fs.String("policy", "", "Policy file to show (default ~/.aiq/config/policy.yaml)")
+	sourceName := fs.String("source", "", "Include the overrides for this source")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	p, loadedFrom, err := loadPolicyForCLI(*policyPath, *sourceName)
+	if err != nil {
+		return err
+	}
+	ui.ShowInfo(fmt.Sprintf("Policy: %s", loadedFrom))
+
+	headers := []string{"#", "Rule", "Origin", "Action", "Match", "Reason"}
+	var rows [][]string
+	for i, info := range p.Rules() {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", i+1),
+			info.Name,
+			string(info.Origin),
+			string(info.Rule.Action),
+			truncateAuditText(describePolicyRule(info.Rule), 70),
+			info.Rule.Reason,
+		})
+	}
+	ui.PrintTable(headers, rows)
+	fmt.Println(ui.HintText("Rules are evaluated top to bottom; the first match decides. Unmatched calls require confirmation."))
+	return nil
+}
+
+// describePolicyRule summarizes the criteria of a rule
+func describePolicyRule(rule policy.Rule) string {
+	var parts []string
+	add := func(name string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, strings.Join(values, ",")))
+		}
+	}
+	add("tools", rule.Tools)
+	add("sql_class", rule.SQLClasses)
+	add("statements", rule.Statements)
+	add("tables", rule.Tables)
+	add("commands", rule.Commands)
+	add("hosts", rule.Hosts)
+	add("methods", rule.Methods)
+	add("paths", rule.Paths)
+	add("operations", rule.Operations)
+	if len(parts) == 0 {
+		return "(all calls)"
+	}
+	return strings.Join(parts, " ")
+}
@@ -10,6 +10,23 @@ import (
 	"github.com/aiq/aiq/internal/ui"
 )
 
+// RunSubcommand dispatches non-interactive subcommands (e.g. "aiq audit", "aiq policy", "aiq secret", "aiq mcp")
+// Returns handled=false when name is not a known subcommand so the caller can continue normal startup
+func RunSubcommand(name string, args []string) (bool, error) {
+	switch name {
+	case "audit":
+		return true, RunAuditCommand(args)
+	case "policy":
+		return true, RunPolicyCommand(args)
+	case "secret":
+		return true, RunSecretCommand(args)
+	case "mcp":
+		return true, RunMCPCommand(args)
+	default:
+		return false, nil
+	}
+}
+
 // Run starts the main CLI application
 // sessionFile is optional path to a session file to restore
 func Run(sessionFile string) error {
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/aiq/aiq/internal/secrets"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// RunSecretCommand implements "aiq secret <set|list|delete>"
+func RunSecretCommand(args []string) error {
+	if len(args) == 0 {
+		printSecretUsage()
+		return nil
+	}
+	switch args[0] {
+	case "set":
+		if len(args) != 2 {
+			printSecretUsage()
+			return fmt.Errorf("usage: aiq secret set <name>")
+		}
+		return runSecretSet(args[1])
+	case "list":
+		names, err := secrets.List()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			ui.ShowInfo("No secrets stored")
+			return nil
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			printSecretUsage()
+			return fmt.Errorf("usage: aiq secret delete <name>")
+		}
+		if err := secrets.Delete(args[1]); err != nil {
+			return err
+		}
+		ui.ShowSuccess(fmt.Sprintf("Secret '%s' deleted", args[1]))
+		return nil
+	case "-h", "--help", "help":
+		printSecretUsage()
+		return nil
+	default:
+		printSecretUsage()
+		return fmt.Errorf("unknown secret command: %s", args[0])
+	}
+}
+
+func printSecretUsage() {
+	fmt.Println("Usage: aiq secret <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  set <name>      Store a secret (prompted, or read from stdin when piped)")
+	fmt.Println("  list            List stored secret names")
+	fmt.Println("  delete <name>   Remove a secret")
+	fmt.Println()
+	fmt.Println("Secrets are stored in ~/.aiq/config/secrets.yaml (mode 0600) and referenced from")
+	fmt.Println("config.yaml as ${secret:name}, e.g. in http.auth_profiles headers.")
+	fmt.Println("AIQ_SECRET_<NAME> environment variables override stored values.")
+}
+
+func runSecretSet(name string) error {
+	if err := secrets.ValidateName(name); err != nil {
+		return err
+	}
+	var value string
+	if readline.IsTerminal(int(os.Stdin.Fd())) {
+		input, err := ui.ShowPassword(fmt.Sprintf("Value for '%s'", name))
+		if err != nil {
+			return fmt.Errorf("cancelled")
+		}
+		value = input
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read secret from stdin: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value == "" {
+		return fmt.Errorf("secret value is empty")
+	}
+	if err := secrets.Set(name, value); err != nil {
+		return err
+	}
+	ui.ShowSuccess(fmt.Sprintf("Secret '%s' stored", name))
+	return nil
+}
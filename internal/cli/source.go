@@ -2,7 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/aiq/aiq/internal/db"
 	"github.com/aiq/aiq/internal/source"
@@ -78,6 +81,7 @@ func addSource() error {
 		{Label: "seekdb", Value: "seekdb"},
 		{Label: "MySQL", Value: "mysql"},
 		{Label: "PostgreSQL", Value: "postgresql"},
+		{Label: "Files (CSV/TSV/JSON/Parquet)", Value: "file"},
 	}
 	
 	dbType, err := ui.ShowMenu("Database Type", typeItems)
@@ -92,6 +96,21 @@ func addSource() error {
 	}
 	src.Name = name
 
+	if src.Type == source.DatabaseTypeFile {
+		files, err := promptFiles("")
+		if err != nil {
+			return err
+		}
+		src.Files = files
+		if err := source.Validate(src); err != nil {
+			return err
+		}
+		if err := db.TestConnection(src.DSN(), string(src.Type)); err != nil {
+			return err
+		}
+		return source.AddSource(src)
+	}
+
 	// Set default port based on database type
 	defaultPort := "3306"
 	if src.Type == source.DatabaseTypePostgreSQL {
@@ -177,6 +196,11 @@ func listSources() error {
 	rows := make([][]string, 0, len(sources))
 
 	for _, s := range sources {
+		if s.Type == source.DatabaseTypeFile {
+			// File sources show their files in place of the connection details
+			rows = append(rows, []string{s.Name, string(s.Type), s.Location(), "", "", ""})
+			continue
+		}
 		rows = append(rows, []string{
 			s.Name,
 			string(s.Type),
@@ -205,7 +229,7 @@ func removeSource() error {
 
 	items := make([]ui.MenuItem, 0, len(sources))
 	for _, s := range sources {
-		label := fmt.Sprintf("%s (%s/%s:%d/%s)", s.Name, s.Type, s.Host, s.Port, s.Database)
+		label := fmt.Sprintf("%s (%s/%s)", s.Name, s.Type, s.Location())
 		items = append(items, ui.MenuItem{Label: label, Value: s.Name})
 	}
 
@@ -239,7 +263,7 @@ func editSource() error {
 
 	items := make([]ui.MenuItem, 0, len(sources))
 	for _, s := range sources {
-		label := fmt.Sprintf("%s (%s/%s:%d/%s)", s.Name, s.Type, s.Host, s.Port, s.Database)
+		label := fmt.Sprintf("%s (%s/%s)", s.Name, s.Type, s.Location())
 		items = append(items, ui.MenuItem{Label: label, Value: s.Name})
 	}
 
@@ -272,6 +296,21 @@ func editSource() error {
 	}
 	updated.Name = name
 
+	if updated.Type == source.DatabaseTypeFile {
+		files, err := promptFiles(strings.Join(oldSource.Files, ", "))
+		if err != nil {
+			return err
+		}
+		updated.Files = files
+		if err := source.Validate(updated); err != nil {
+			return err
+		}
+		if err := db.TestConnection(updated.DSN(), string(updated.Type)); err != nil {
+			return err
+		}
+		return source.UpdateSource(selected, updated)
+	}
+
 	host, err := ui.ShowInput("Enter host", oldSource.Host)
 	if err != nil {
 		return fmt.Errorf("failed to get host: %w", err)
@@ -336,3 +375,27 @@ func editSource() error {
 
 	return source.UpdateSource(selected, updated)
 }
+
+// promptFiles asks for the files and directories of a file source, comma-separated, and checks they exist
+func promptFiles(defaultValue string) ([]string, error) {
+	input, err := ui.ShowInput("Enter files or directories (comma-separated)", defaultValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	var files []string
+	for _, part := range strings.Split(input, ",") {
+		path := strings.TrimSpace(part)
+		if path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		files = append(files, abs)
+	}
+	return files, nil
+}
@@ -2,7 +2,15 @@ package config
 
 // Config represents the application configuration
 type Config struct {
-	LLM LLMConfig `yaml:"llm"`
+	LLM     LLMConfig     `yaml:"llm"`
+	Audit   AuditConfig   `yaml:"audit,omitempty"`
+	Backup  BackupConfig  `yaml:"backup,omitempty"`
+	Cache   CacheConfig   `yaml:"cache,omitempty"`
+	History HistoryConfig `yaml:"history,omitempty"`
+	Sandbox SandboxConfig `yaml:"sandbox,omitempty"`
+	HTTP    HTTPConfig    `yaml:"http,omitempty"`
+	MCP     MCPConfig     `yaml:"mcp,omitempty"`
+	Tools   ToolsConfig   `yaml:"tools,omitempty"`
 }
 
 // LLMConfig represents LLM provider configuration
@@ -12,6 +20,106 @@ type LLMConfig struct {
 	Model  string `yaml:"model"`
 }
 
+// AuditConfig represents audit log settings
+// Zero values fall back to the defaults in the audit package
+type AuditConfig struct {
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"` // Rotate the active log file after it reaches this size
+	MaxFiles  int `yaml:"max_files,omitempty"`   // Number of rotated log files to keep
+}
+
+// BackupConfig represents before-image backup settings for destructive statements
+// Zero values fall back to the defaults in the backup package
+type BackupConfig struct {
+	Disabled     bool `yaml:"disabled,omitempty"`      // Skip backups entirely
+	MaxRows      int  `yaml:"max_rows,omitempty"`      // Skip the backup when more rows would be affected
+	MaxSizeMB    int  `yaml:"max_size_mb,omitempty"`   // Skip the backup when the affected rows exceed this size
+	MaxSnapshots int  `yaml:"max_snapshots,omitempty"` // Number of snapshots kept on disk (oldest are removed)
+}
+
+// CacheConfig represents query result cache settings
+// The cache is off unless enabled, since other clients may change the data behind cached results
+// Zero values fall back to the defaults in the cache package
+type CacheConfig struct {
+	Enabled    bool `yaml:"enabled,omitempty"`     // Reuse results of repeated read-only queries
+	TTLSeconds int  `yaml:"ttl_seconds,omitempty"` // Cached results older than this are run again
+	MaxEntries int  `yaml:"max_entries,omitempty"` // Number of results kept (least recently used are dropped)
+	MaxSizeMB  int  `yaml:"max_size_mb,omitempty"` // Total size of cached results; larger results are not cached
+	Disk       bool `yaml:"disk,omitempty"`        // Also keep results in ~/.aiq/cache so later sessions reuse them
+}
+
+// HistoryConfig represents chat prompt input history settings
+// Zero values fall back to the defaults in the history package
+type HistoryConfig struct {
+	Disabled    bool `yaml:"disabled,omitempty"`     // Do not save input history
+	MaxEntries  int  `yaml:"max_entries,omitempty"`  // Entries kept per source (oldest are dropped)
+	KeepSecrets bool `yaml:"keep_secrets,omitempty"` // Also save lines that look like they contain passwords or keys
+}
+
+// SandboxConfig represents execute_command sandbox settings
+// Zero values fall back to the defaults in the sandbox package
+type SandboxConfig struct {
+	Enabled           bool     `yaml:"enabled,omitempty"`            // Run execute_command commands in the sandbox
+	AllowlistOnly     bool     `yaml:"allowlist_only,omitempty"`     // Deny commands not listed in AllowedCommands
+	AllowedCommands   []string `yaml:"allowed_commands,omitempty"`   // Command names allowed when AllowlistOnly is set
+	EnvPassthrough    []string `yaml:"env_passthrough,omitempty"`    // Environment variables kept even if they look like secrets
+	WorkDir           string   `yaml:"work_dir,omitempty"`           // Directory commands are confined to (default ~/.aiq/sandbox)
+	ReadPaths         []string `yaml:"read_paths,omitempty"`         // Extra paths commands may read (Landlock)
+	WritePaths        []string `yaml:"write_paths,omitempty"`        // Extra paths commands may write (Landlock)
+	CPUSeconds        int      `yaml:"cpu_seconds,omitempty"`        // CPU time limit per command
+	MemoryMB          int      `yaml:"memory_mb,omitempty"`          // Address space limit per process
+	MaxOutputKB       int      `yaml:"max_output_kb,omitempty"`      // Command is killed when its output exceeds this size
+	MaxProcesses      int      `yaml:"max_processes,omitempty"`      // Process limit (RLIMIT_NPROC)
+	IsolateNetwork    bool     `yaml:"isolate_network,omitempty"`    // Run commands without network access (Linux)
+	DisableNamespaces bool     `yaml:"disable_namespaces,omitempty"` // Do not use Linux namespaces
+	DisableLandlock   bool     `yaml:"disable_landlock,omitempty"`   // Do not apply Landlock filesystem rules
+}
+
+// HTTPConfig represents http_request tool settings
+type HTTPConfig struct {
+	AllowHosts      []string                   `yaml:"allow_hosts,omitempty"`      // When set, only these hosts (globs like *.example.com) may be requested
+	DenyHosts       []string                   `yaml:"deny_hosts,omitempty"`       // Hosts that may never be requested
+	AllowedNetworks []string                   `yaml:"allowed_networks,omitempty"` // CIDRs of private addresses that may be reached (all others are blocked)
+	MaxResponseKB   int                        `yaml:"max_response_kb,omitempty"`  // Response bodies are truncated at this size
+	AuthProfiles    map[string]HTTPAuthProfile `yaml:"auth_profiles,omitempty"`    // Named credentials the LLM can select by name
+}
+
+// HTTPAuthProfile injects headers into requests to its hosts
+// Header values may reference secrets as ${secret:name}; the LLM only sees the profile name
+type HTTPAuthProfile struct {
+	Hosts   []string          `yaml:"hosts"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// ToolsConfig controls which tools are offered to the LLM
+type ToolsConfig struct {
+	Disabled         []string `yaml:"disabled,omitempty"`           // Tool names never offered or run (built-in, user-defined or MCP)
+	MaxIterations    int      `yaml:"max_iterations,omitempty"`     // LLM round trips per request before asking whether to go on (default 10)
+	MaxTurnSeconds   int      `yaml:"max_turn_seconds,omitempty"`   // Wall time per request before asking whether to go on (default 300)
+	MaxTurnTokens    int      `yaml:"max_turn_tokens,omitempty"`    // Tokens reported by the LLM per request before asking whether to go on (default unlimited)
+	MaxRepeatedCalls int      `yaml:"max_repeated_calls,omitempty"` // Identical calls (same tool and arguments) run per request (default 3)
+}
+
+// MCPConfig lists Model Context Protocol servers whose tools are offered to the LLM
+type MCPConfig struct {
+	Servers map[string]MCPServerConfig `yaml:"servers,omitempty"`
+}
+
+// MCPServerConfig describes one MCP server, launched over stdio (Command) or reached over streamable HTTP (URL)
+// Env and Headers values may reference secrets as ${secret:name}
+type MCPServerConfig struct {
+	Command           string            `yaml:"command,omitempty"`              // Executable to launch (stdio transport)
+	Args              []string          `yaml:"args,omitempty"`                 // Arguments for Command
+	Env               map[string]string `yaml:"env,omitempty"`                  // Extra environment variables for Command
+	Cwd               string            `yaml:"cwd,omitempty"`                  // Working directory for Command
+	URL               string            `yaml:"url,omitempty"`                  // Endpoint of a streamable HTTP server
+	Headers           map[string]string `yaml:"headers,omitempty"`              // Extra HTTP headers (e.g. Authorization)
+	Tools             []string          `yaml:"tools,omitempty"`                // When set, only these tools are offered
+	Risk              string            `yaml:"risk,omitempty"`                 // low runs tools without confirmation; high (default) asks
+	TrustReadOnlyHint bool              `yaml:"trust_read_only_hint,omitempty"` // Run tools annotated readOnlyHint without confirmation
+	Timeout           int               `yaml:"timeout,omitempty"`              // Seconds per tool call (default 60)
+	Disabled          bool              `yaml:"disabled,omitempty"`
+}
+
 // NewConfig creates a new empty configuration
 func NewConfig() *Config {
 	return &Config{
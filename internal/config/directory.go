@@ -17,10 +17,17 @@ const (
 	ToolsSubdir    = "tools"
 	PromptsSubdir  = "prompts"
 	BinSubdir      = "bin"
+	LogsSubdir     = "logs"
+	BackupsSubdir  = "backups"
+	SandboxSubdir  = "sandbox"
+	CacheSubdir    = "cache"
+	HistorySubdir  = "history"
 
 	// Config files
 	ConfigFile  = "config.yaml"
 	SourcesFile = "sources.yaml"
+	PolicyFile  = "policy.yaml"
+	SecretsFile = "secrets.yaml"
 )
 
 // GetBaseConfigDir returns the base configuration directory path (~/.aiq)
@@ -86,6 +93,51 @@ func GetBinDir() (string, error) {
 	return filepath.Join(baseDir, BinSubdir), nil
 }
 
+// GetLogsDir returns the logs subdirectory path (~/.aiq/logs)
+func GetLogsDir() (string, error) {
+	baseDir, err := GetBaseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, LogsSubdir), nil
+}
+
+// GetBackupsDir returns the backups subdirectory path (~/.aiq/backups)
+func GetBackupsDir() (string, error) {
+	baseDir, err := GetBaseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, BackupsSubdir), nil
+}
+
+// GetSandboxDir returns the sandbox working directory path (~/.aiq/sandbox)
+func GetSandboxDir() (string, error) {
+	baseDir, err := GetBaseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, SandboxSubdir), nil
+}
+
+// GetCacheDir returns the query result cache directory path (~/.aiq/cache)
+func GetCacheDir() (string, error) {
+	baseDir, err := GetBaseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, CacheSubdir), nil
+}
+
+// GetHistoryDir returns the input history directory path (~/.aiq/history)
+func GetHistoryDir() (string, error) {
+	baseDir, err := GetBaseConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, HistorySubdir), nil
+}
+
 // GetConfigFilePath returns the full path to the configuration file (~/.aiq/config/config.yaml)
 func GetConfigFilePath() (string, error) {
 	configDir, err := GetConfigDir()
@@ -104,6 +156,24 @@ func GetSourcesFilePath() (string, error) {
 	return filepath.Join(configDir, SourcesFile), nil
 }
 
+// GetPolicyFilePath returns the full path to the risk policy file (~/.aiq/config/policy.yaml)
+func GetPolicyFilePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, PolicyFile), nil
+}
+
+// GetSecretsFilePath returns the full path to the secrets file (~/.aiq/config/secrets.yaml)
+func GetSecretsFilePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, SecretsFile), nil
+}
+
 // EnsureDirectoryStructure creates all required subdirectories if they don't exist
 func EnsureDirectoryStructure() error {
 	dirs := []struct {
@@ -116,6 +186,9 @@ func EnsureDirectoryStructure() error {
 		{"tools", GetToolsDir},
 		{"prompts", GetPromptsDir},
 		{"bin", GetBinDir},
+		{"logs", GetLogsDir},
+		{"backups", GetBackupsDir},
+		{"sandbox", GetSandboxDir},
 	}
 
 	for _, dir := range dirs {
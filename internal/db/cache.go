@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/aiq/aiq/internal/cache"
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// resultCache is the query result cache of a connection and the scope (source and database) its results belong to
+type resultCache struct {
+	cache *cache.Cache
+	scope string
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context whose queries always run against the database
+// The fresh results still replace cached ones
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(noCacheKey{}).(bool)
+	return bypass
+}
+
+// SetCache makes ExecuteQuery reuse results of read-only queries stored in c under scope
+// Scope identifies the source and database; a nil cache turns caching off
+func (c *Connection) SetCache(rc *cache.Cache, scope string) {
+	if rc == nil {
+		c.cache = nil
+		return
+	}
+	c.cache = &resultCache{cache: rc, scope: scope}
+}
+
+// cachedQuery runs a query through the result cache
+// Queries in a session transaction may see uncommitted changes, so they neither read nor fill the cache;
+// file sources are already in memory
+func (c *Connection) cachedQuery(ctx context.Context, sqlQuery string) (*QueryResult, error) {
+	dialect := sqlparse.DialectForDatabaseType(c.dbType)
+	if c.cache == nil || c.files != nil || c.InTransaction() || !cache.Cacheable(sqlQuery, dialect) {
+		result, err := executeQuery(ctx, c.queryer(), sqlQuery)
+		if err == nil && c.cache != nil && !c.InTransaction() && !isReadOnly(sqlQuery, dialect) {
+			c.cache.invalidate()
+		}
+		return result, err
+	}
+
+	key := cache.Key(c.cache.scope, sqlQuery, dialect)
+	if !cacheBypassed(ctx) {
+		if entry, ok := c.cache.cache.Get(key); ok {
+			return &QueryResult{Columns: entry.Columns, Rows: entry.Rows, CachedAt: entry.StoredAt}, nil
+		}
+	}
+	result, err := executeQuery(ctx, c.db, sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.cache.Put(key, &cache.Entry{Scope: c.cache.scope, Columns: result.Columns, Rows: result.Rows, StoredAt: time.Now()})
+	return result, nil
+}
+
+// invalidate drops the cached results of the scope after its data may have changed
+func (rc *resultCache) invalidate() {
+	if rc != nil {
+		rc.cache.Invalidate(rc.scope)
+	}
+}
+
+// isReadOnly reports whether every statement of sql only reads data
+func isReadOnly(sql string, dialect sqlparse.Dialect) bool {
+	for _, st := range sqlparse.Parse(sql, dialect) {
+		if !st.IsReadOnly() {
+			return false
+		}
+	}
+	return true
+}
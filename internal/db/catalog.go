@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// TableIndex is an index listed by ListIndexes with the table it belongs to
+type TableIndex struct {
+	Table string
+	IndexInfo
+}
+
+// ListDatabases returns the names of the databases on the server, sorted by name
+// MySQL and seekdb list their schemas; PostgreSQL lists the databases that accept connections
+func (c *Connection) ListDatabases(ctx context.Context) ([]string, error) {
+	if c.files != nil {
+		return nil, fmt.Errorf("file sources have no databases: each loaded file is a table")
+	}
+	query := "SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA ORDER BY SCHEMA_NAME"
+	if c.dbType == "postgresql" {
+		query = "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname"
+	}
+	rows, err := c.queryer().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+	databases := []string{}
+	err = scanRows(rows, func() error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		databases = append(databases, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read databases: %w", err)
+	}
+	return databases, nil
+}
+
+// ListIndexes lists the indexes of a database whose name or table name matches filter, sorted by table
+// filter works as in SearchTables; file sources have no indexes
+// For MySQL an empty databaseName means the current database; PostgreSQL lists the current schema
+func (c *Connection) ListIndexes(ctx context.Context, databaseName, filter string) ([]TableIndex, error) {
+	if c.files != nil {
+		return []TableIndex{}, nil
+	}
+	pattern := likePattern(filter)
+	var query string
+	var args []interface{}
+	if c.dbType == "postgresql" {
+		query = `
+			SELECT t.relname, i.relname, ix.indisunique, ix.indisprimary, a.attname
+			FROM pg_index ix
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_class t ON t.oid = ix.indrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+			JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+			WHERE n.nspname = current_schema() AND (i.relname ILIKE $1 OR t.relname ILIKE $1)
+			ORDER BY t.relname, ix.indisprimary DESC, i.relname, k.ord
+		`
+		args = []interface{}{pattern}
+	} else {
+		query = `
+			SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE = 0, INDEX_NAME = 'PRIMARY', COLUMN_NAME
+			FROM INFORMATION_SCHEMA.STATISTICS
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE())
+				AND (LOWER(INDEX_NAME) LIKE LOWER(?) OR LOWER(TABLE_NAME) LIKE LOWER(?))
+			ORDER BY TABLE_NAME, INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX
+		`
+		args = []interface{}{databaseName, pattern, pattern}
+	}
+
+	rows, err := c.queryer().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	indexes := []TableIndex{}
+	err = scanRows(rows, func() error {
+		var index TableIndex
+		var column string
+		if err := rows.Scan(&index.Table, &index.Name, &index.Unique, &index.Primary, &column); err != nil {
+			return err
+		}
+		// Rows of an index are adjacent; each row adds one column
+		if n := len(indexes); n > 0 && indexes[n-1].Table == index.Table && indexes[n-1].Name == index.Name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, column)
+			return nil
+		}
+		index.Columns = []string{column}
+		indexes = append(indexes, index)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	return indexes, nil
+}
@@ -4,18 +4,36 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/aiq/aiq/internal/filedb"
 )
 
 // Connection represents a database connection
 type Connection struct {
-	db *sql.DB
+	db     *sql.DB
+	dbType string
+	files  *filedb.DB   // Tables of a file source, queried in memory
+	cache  *resultCache // Results of read-only queries (see SetCache)
+
+	tx   *Tx // Open session transaction (see Begin)
+	txMu sync.Mutex
 }
 
 // NewConnection creates a new database connection
 func NewConnection(dsn string, dbType string) (*Connection, error) {
+	if dbType == "file" {
+		// File sources load their files into memory; the DSN lists the paths
+		files, err := filedb.OpenDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open files: %w", err)
+		}
+		return NewFileConnection(files), nil
+	}
+
 	driverName := "mysql"
 	if dbType == "postgresql" {
 		driverName = "postgres"
@@ -43,17 +61,30 @@ func NewConnection(dsn string, dbType string) (*Connection, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Connection{db: db}, nil
+	return &Connection{db: db, dbType: dbType}, nil
 }
 
-// Close closes the database connection
+// NewFileConnection returns a connection querying tables already loaded into memory
+func NewFileConnection(files *filedb.DB) *Connection {
+	return &Connection{db: sql.OpenDB(files.Connector()), dbType: "file", files: files}
+}
+
+// Close closes the database connection, rolling back an open session transaction
 func (c *Connection) Close() error {
+	if c.InTransaction() {
+		c.Rollback()
+	}
 	if c.db != nil {
 		return c.db.Close()
 	}
 	return nil
 }
 
+// DatabaseType returns the source database type the connection was opened with (mysql, postgresql, seekdb, file)
+func (c *Connection) DatabaseType() string {
+	return c.dbType
+}
+
 // GetDB returns the underlying sql.DB instance
 func (c *Connection) GetDB() *sql.DB {
 	return c.db
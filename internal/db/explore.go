@@ -0,0 +1,413 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// TableSummary is a table or view listed by SearchTables
+type TableSummary struct {
+	Name    string
+	Type    string        // TABLE or VIEW
+	Rows    sql.NullInt64 // Row count estimated by the database statistics
+	Comment string
+}
+
+// TableDescription is the structure of a table returned by DescribeTable
+type TableDescription struct {
+	Name        string
+	Comment     string
+	Columns     []ColumnDetail
+	PrimaryKey  []string
+	Indexes     []IndexInfo
+	ForeignKeys []ForeignKeyInfo
+}
+
+// ColumnDetail describes a column with its full type and comment
+type ColumnDetail struct {
+	Name     string
+	Type     string // Full column type, e.g. varchar(255) or numeric(10,2)
+	Nullable bool
+	Default  sql.NullString
+	Extra    string // e.g. auto_increment (MySQL)
+	Comment  string
+}
+
+// IndexInfo describes an index
+type IndexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+// ForeignKeyInfo describes a foreign key constraint
+type ForeignKeyInfo struct {
+	Name              string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
+
+// SearchTables lists the tables and views of a database whose name matches filter, sorted by name
+// filter is a case-insensitive substring, or a pattern with * and ? wildcards; empty lists everything
+// For MySQL an empty databaseName means the current database; PostgreSQL lists the current schema
+// File sources list their files, with the row count and the file path as comment
+func (c *Connection) SearchTables(ctx context.Context, databaseName, filter string) ([]TableSummary, error) {
+	if c.files != nil {
+		return c.searchFileTables(filter), nil
+	}
+	pattern := likePattern(filter)
+	var query string
+	var args []interface{}
+	if c.dbType == "postgresql" {
+		query = `
+			SELECT c.relname,
+				CASE WHEN c.relkind IN ('v', 'm') THEN 'VIEW' ELSE 'TABLE' END,
+				CASE WHEN c.reltuples < 0 THEN NULL ELSE c.reltuples::bigint END,
+				COALESCE(obj_description(c.oid, 'pg_class'), '')
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND c.relname ILIKE $1
+			ORDER BY c.relname
+		`
+		args = []interface{}{pattern}
+	} else {
+		query = `
+			SELECT TABLE_NAME,
+				CASE WHEN TABLE_TYPE = 'VIEW' THEN 'VIEW' ELSE 'TABLE' END,
+				TABLE_ROWS,
+				COALESCE(TABLE_COMMENT, '')
+			FROM INFORMATION_SCHEMA.TABLES
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND LOWER(TABLE_NAME) LIKE LOWER(?)
+			ORDER BY TABLE_NAME
+		`
+		args = []interface{}{databaseName, pattern}
+	}
+
+	rows, err := c.queryer().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []TableSummary{}
+	for rows.Next() {
+		var t TableSummary
+		if err := rows.Scan(&t.Name, &t.Type, &t.Rows, &t.Comment); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+	return tables, nil
+}
+
+// likePattern converts a table filter to a LIKE pattern: wildcards are translated, a plain filter matches substrings
+func likePattern(filter string) string {
+	filter = strings.TrimSpace(filter)
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter)
+	if strings.ContainsAny(filter, "*?") {
+		return strings.NewReplacer("*", "%", "?", "_").Replace(escaped)
+	}
+	return "%" + escaped + "%"
+}
+
+// DescribeTable returns the columns, keys, indexes and comments of a table, failing when it does not exist
+// For MySQL an empty databaseName means the current database; PostgreSQL resolves the name on the search path
+func (c *Connection) DescribeTable(ctx context.Context, databaseName, tableName string) (*TableDescription, error) {
+	var desc *TableDescription
+	var err error
+	if c.files != nil {
+		desc, err = c.describeFileTable(tableName)
+	} else if c.dbType == "postgresql" {
+		desc, err = describePostgresTable(ctx, c.queryer(), tableName)
+	} else {
+		desc, err = describeMySQLTable(ctx, c.queryer(), databaseName, tableName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+	if len(desc.Columns) == 0 {
+		if databaseName == "" {
+			return nil, fmt.Errorf("table %s not found", tableName)
+		}
+		return nil, fmt.Errorf("table %s not found in database %s", tableName, databaseName)
+	}
+	for _, index := range desc.Indexes {
+		if index.Primary {
+			desc.PrimaryKey = index.Columns
+		}
+	}
+	return desc, nil
+}
+
+func describeMySQLTable(ctx context.Context, q queryer, databaseName, tableName string) (*TableDescription, error) {
+	desc := &TableDescription{Name: tableName}
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(TABLE_COMMENT, '') FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?
+	`, databaseName, tableName).Scan(&desc.Comment)
+	if err == sql.ErrNoRows {
+		return desc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COALESCE(EXTRA, ''), COALESCE(COLUMN_COMMENT, '')
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION
+	`, databaseName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var col ColumnDetail
+		var nullable string
+		if err := rows.Scan(&col.Name, &col.Type, &nullable, &col.Default, &col.Extra, &col.Comment); err != nil {
+			return err
+		}
+		col.Nullable = nullable == "YES"
+		desc.Columns = append(desc.Columns, col)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+		FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?
+		ORDER BY INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX
+	`, databaseName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var name, column string
+		var nonUnique int
+		if err := rows.Scan(&name, &nonUnique, &column); err != nil {
+			return err
+		}
+		desc.Indexes = addIndexColumn(desc.Indexes, IndexInfo{Name: name, Unique: nonUnique == 0, Primary: name == "PRIMARY"}, column)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT CONSTRAINT_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY CONSTRAINT_NAME, ORDINAL_POSITION
+	`, databaseName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var name, column, refTable, refColumn string
+		if err := rows.Scan(&name, &column, &refTable, &refColumn); err != nil {
+			return err
+		}
+		desc.ForeignKeys = addForeignKeyColumn(desc.ForeignKeys, name, refTable, column, refColumn)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func describePostgresTable(ctx context.Context, q queryer, tableName string) (*TableDescription, error) {
+	desc := &TableDescription{Name: tableName}
+	relation := sqlparse.QuoteQualified(strings.Split(tableName, "."), sqlparse.DialectPostgres)
+	if err := q.QueryRowContext(ctx, "SELECT COALESCE(obj_description($1::regclass, 'pg_class'), '')", relation).Scan(&desc.Comment); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+			pg_get_expr(d.adbin, d.adrelid), COALESCE(col_description(a.attrelid, a.attnum), '')
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`, relation)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var col ColumnDetail
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default, &col.Comment); err != nil {
+			return err
+		}
+		desc.Columns = append(desc.Columns, col)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT i.relname, ix.indisunique, ix.indisprimary, a.attname
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+		JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+		WHERE ix.indrelid = $1::regclass
+		ORDER BY ix.indisprimary DESC, i.relname, k.ord
+	`, relation)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var index IndexInfo
+		var column string
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Primary, &column); err != nil {
+			return err
+		}
+		desc.Indexes = addIndexColumn(desc.Indexes, index, column)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT con.conname, a.attname, ref.relname, af.attname
+		FROM pg_constraint con
+		JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, fattnum, ord) ON true
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		JOIN pg_class ref ON ref.oid = con.confrelid
+		JOIN pg_attribute af ON af.attrelid = con.confrelid AND af.attnum = k.fattnum
+		WHERE con.conrelid = $1::regclass AND con.contype = 'f'
+		ORDER BY con.conname, k.ord
+	`, relation)
+	if err != nil {
+		return nil, err
+	}
+	err = scanRows(rows, func() error {
+		var name, column, refTable, refColumn string
+		if err := rows.Scan(&name, &column, &refTable, &refColumn); err != nil {
+			return err
+		}
+		desc.ForeignKeys = addForeignKeyColumn(desc.ForeignKeys, name, refTable, column, refColumn)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// scanRows calls scan for each row and closes rows
+func scanRows(rows *sql.Rows, scan func() error) error {
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// addIndexColumn appends a column to the last index when it has the same name, otherwise starts a new index
+func addIndexColumn(indexes []IndexInfo, index IndexInfo, column string) []IndexInfo {
+	if n := len(indexes); n > 0 && indexes[n-1].Name == index.Name {
+		indexes[n-1].Columns = append(indexes[n-1].Columns, column)
+		return indexes
+	}
+	index.Columns = []string{column}
+	return append(indexes, index)
+}
+
+// addForeignKeyColumn appends a column pair to the last foreign key when it has the same name, otherwise starts a new one
+func addForeignKeyColumn(keys []ForeignKeyInfo, name, refTable, column, refColumn string) []ForeignKeyInfo {
+	if n := len(keys); n > 0 && keys[n-1].Name == name {
+		keys[n-1].Columns = append(keys[n-1].Columns, column)
+		keys[n-1].ReferencedColumns = append(keys[n-1].ReferencedColumns, refColumn)
+		return keys
+	}
+	return append(keys, ForeignKeyInfo{Name: name, Columns: []string{column}, ReferencedTable: refTable, ReferencedColumns: []string{refColumn}})
+}
+
+// ColumnValueCount is a distinct value of a column and how many rows have it
+type ColumnValueCount struct {
+	Value sql.NullString
+	Count int64
+}
+
+// ColumnStats summarizes the values of a column
+type ColumnStats struct {
+	Distinct int64              // Number of distinct non-NULL values
+	Nulls    int64              // Number of rows where the column is NULL
+	Values   []ColumnValueCount // Most frequent values, most frequent first
+}
+
+// SampleRows returns up to limit rows of a table; columns limits the selected columns (all when empty)
+// tableName may be qualified with a schema or database (schema.table)
+func (c *Connection) SampleRows(ctx context.Context, tableName string, columns []string, limit int) (*QueryResult, error) {
+	dialect := sqlparse.DialectForDatabaseType(c.dbType)
+	selectList := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = sqlparse.QuoteIdent(column, dialect)
+		}
+		selectList = strings.Join(quoted, ", ")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s LIMIT %d", selectList, sqlparse.QuoteQualified(strings.Split(tableName, "."), dialect), limit)
+	result, err := c.ExecuteQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample table %s: %w", tableName, err)
+	}
+	return result, nil
+}
+
+// ColumnValues returns the number of distinct and NULL values of a column and its limit most frequent values
+func (c *Connection) ColumnValues(ctx context.Context, tableName, column string, limit int) (*ColumnStats, error) {
+	dialect := sqlparse.DialectForDatabaseType(c.dbType)
+	table := sqlparse.QuoteQualified(strings.Split(tableName, "."), dialect)
+	col := sqlparse.QuoteIdent(column, dialect)
+	q := c.queryer()
+
+	stats := &ColumnStats{}
+	query := fmt.Sprintf("SELECT COUNT(DISTINCT %s), COUNT(*) - COUNT(%s) FROM %s", col, col, table)
+	if err := q.QueryRowContext(ctx, query).Scan(&stats.Distinct, &stats.Nulls); err != nil {
+		return nil, fmt.Errorf("failed to count values of %s.%s: %w", tableName, column, err)
+	}
+
+	// Values are compared as text so every column type can be returned
+	valueExpr := fmt.Sprintf("CAST(%s AS CHAR)", col)
+	if c.dbType == "postgresql" || c.dbType == "file" {
+		valueExpr = fmt.Sprintf("CAST(%s AS TEXT)", col)
+	}
+	query = fmt.Sprintf("SELECT %s, COUNT(*) FROM %s WHERE %s IS NOT NULL GROUP BY %s ORDER BY COUNT(*) DESC, 1 LIMIT %d", valueExpr, table, col, col, limit)
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query values of %s.%s: %w", tableName, column, err)
+	}
+	err = scanRows(rows, func() error {
+		var v ColumnValueCount
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return err
+		}
+		stats.Values = append(stats.Values, v)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values of %s.%s: %w", tableName, column, err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// searchFileTables lists the tables of a file source matching filter (see SearchTables)
+// File sources have no catalog to query, so the metadata comes from the loaded files
+func (c *Connection) searchFileTables(filter string) []TableSummary {
+	pattern := strings.ToLower(likePattern(filter))
+	tables := []TableSummary{}
+	for _, t := range c.files.Tables() {
+		if !matchLike(strings.ToLower(t.Name), pattern) {
+			continue
+		}
+		tables = append(tables, TableSummary{
+			Name:    t.Name,
+			Type:    "TABLE",
+			Rows:    sql.NullInt64{Int64: int64(len(t.Rows)), Valid: true},
+			Comment: t.Path,
+		})
+	}
+	return tables
+}
+
+// matchLike matches a LIKE pattern from likePattern, translated to a path.Match pattern
+func matchLike(name, pattern string) bool {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(`\` + string(pattern[i]))
+			}
+		case '%':
+			b.WriteByte('*')
+		case '_':
+			b.WriteByte('?')
+		case '*', '?', '[', ']':
+			b.WriteString(`\` + string(ch))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	matched, _ := path.Match(b.String(), name)
+	return matched
+}
+
+// describeFileTable describes a table of a file source with its inferred column types
+func (c *Connection) describeFileTable(tableName string) (*TableDescription, error) {
+	t, ok := c.files.Table(fileTableName(tableName))
+	if !ok {
+		return &TableDescription{Name: tableName}, nil
+	}
+	desc := &TableDescription{Name: t.Name, Comment: fmt.Sprintf("Loaded from %s (%d rows)", t.Path, len(t.Rows))}
+	for _, col := range t.Columns {
+		desc.Columns = append(desc.Columns, ColumnDetail{Name: col.Name, Type: col.Type, Nullable: col.Nullable})
+	}
+	return desc, nil
+}
+
+// fileTableInfo returns the columns of a file source table for GetSchema
+func (c *Connection) fileTableInfo(tableName string) (*TableInfo, error) {
+	t, ok := c.files.Table(tableName)
+	if !ok {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	info := &TableInfo{Name: t.Name, Columns: make([]ColumnInfo, 0, len(t.Columns))}
+	for _, col := range t.Columns {
+		nullable := "NO"
+		if col.Nullable {
+			nullable = "YES"
+		}
+		info.Columns = append(info.Columns, ColumnInfo{Name: col.Name, DataType: col.Type, IsNullable: nullable})
+	}
+	return info, nil
+}
+
+// fileTableName strips quotes and a schema qualifier from a table name, which file sources do not have
+func fileTableName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.Trim(name, "\"`")
+}
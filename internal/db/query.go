@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -9,17 +10,67 @@ import (
 
 // QueryResult represents a query result
 type QueryResult struct {
-	Columns []string
-	Rows    [][]string
+	Columns      []string
+	Rows         [][]string
+	RowsAffected int64     // Rows changed by a non-query statement (INSERT, UPDATE, DELETE, etc.)
+	CachedAt     time.Time // When the result was stored in the result cache; zero for a fresh result
+}
+
+// queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // ExecuteQuery executes a SQL query and returns the results
+// Inside a session transaction (see Begin) the query runs in the transaction
+// With a result cache (see SetCache) read-only queries may return a stored result with CachedAt set
 func (c *Connection) ExecuteQuery(ctx context.Context, sqlQuery string) (*QueryResult, error) {
+	return c.cachedQuery(ctx, sqlQuery)
+}
+
+// ExecuteNonQuery executes a non-query SQL statement (INSERT, UPDATE, DELETE, etc.)
+func (c *Connection) ExecuteNonQuery(ctx context.Context, sqlQuery string) (int64, error) {
+	return c.executeNonQuery(ctx, sqlQuery)
+}
+
+// ExecuteQueryArgs executes a parameterized SQL query with bound arguments
+// Placeholders use the driver syntax (? for MySQL, $1 for PostgreSQL); see Placeholder
+func (c *Connection) ExecuteQueryArgs(ctx context.Context, sqlQuery string, args ...interface{}) (*QueryResult, error) {
+	return executeQuery(ctx, c.queryer(), sqlQuery, args...)
+}
+
+// ExecuteNonQueryArgs executes a parameterized non-query SQL statement with bound arguments
+func (c *Connection) ExecuteNonQueryArgs(ctx context.Context, sqlQuery string, args ...interface{}) (int64, error) {
+	return c.executeNonQuery(ctx, sqlQuery, args...)
+}
+
+// executeNonQuery runs a statement and drops the cached results it may have made stale
+// Inside a session transaction they are dropped when it commits
+func (c *Connection) executeNonQuery(ctx context.Context, sqlQuery string, args ...interface{}) (int64, error) {
+	inTx := c.InTransaction()
+	rowsAffected, err := executeNonQuery(ctx, c.queryer(), sqlQuery, args...)
+	if err == nil && !inTx {
+		c.cache.invalidate()
+	}
+	return rowsAffected, err
+}
+
+// Placeholder returns the bind placeholder for the n-th argument (1-based) in the connection's SQL dialect
+func (c *Connection) Placeholder(n int) string {
+	if c.dbType == "postgresql" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func executeQuery(ctx context.Context, q queryer, sqlQuery string, args ...interface{}) (*QueryResult, error) {
 	// Set timeout
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	rows, err := c.db.QueryContext(queryCtx, sqlQuery)
+	rows, err := q.QueryContext(queryCtx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
@@ -107,12 +158,11 @@ func (c *Connection) ExecuteQuery(ctx context.Context, sqlQuery string) (*QueryR
 	return result, nil
 }
 
-// ExecuteNonQuery executes a non-query SQL statement (INSERT, UPDATE, DELETE, etc.)
-func (c *Connection) ExecuteNonQuery(ctx context.Context, sqlQuery string) (int64, error) {
+func executeNonQuery(ctx context.Context, q queryer, sqlQuery string, args ...interface{}) (int64, error) {
 	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	result, err := c.db.ExecContext(queryCtx, sqlQuery)
+	result, err := q.ExecContext(queryCtx, sqlQuery, args...)
 	if err != nil {
 		return 0, fmt.Errorf("query execution failed: %w", err)
 	}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	"github.com/aiq/aiq/internal/sqlparse"
 )
 
 // TableInfo represents table information
@@ -29,7 +31,36 @@ type Schema struct {
 
 // GetSchema fetches the database schema
 func (c *Connection) GetSchema(ctx context.Context, databaseName string) (*Schema, error) {
-	// Get all tables
+	tableNames, err := c.ListTables(ctx, databaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get columns for each table
+	schema := &Schema{
+		Tables: make([]TableInfo, 0, len(tableNames)),
+	}
+
+	for _, tableName := range tableNames {
+		tableInfo, err := c.getTableInfo(ctx, databaseName, tableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get info for table %s: %w", tableName, err)
+		}
+		schema.Tables = append(schema.Tables, *tableInfo)
+	}
+
+	return schema, nil
+}
+
+// ListTables returns the names of the tables in a database, sorted by name
+func (c *Connection) ListTables(ctx context.Context, databaseName string) ([]string, error) {
+	if c.files != nil {
+		tableNames := []string{}
+		for _, t := range c.files.Tables() {
+			tableNames = append(tableNames, t.Name)
+		}
+		return tableNames, nil
+	}
 	tablesQuery := "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME"
 	rows, err := c.db.QueryContext(ctx, tablesQuery, databaseName)
 	if err != nil {
@@ -37,7 +68,7 @@ func (c *Connection) GetSchema(ctx context.Context, databaseName string) (*Schem
 	}
 	defer rows.Close()
 
-	var tableNames []string
+	tableNames := []string{}
 	for rows.Next() {
 		var tableName string
 		if err := rows.Scan(&tableName); err != nil {
@@ -49,24 +80,13 @@ func (c *Connection) GetSchema(ctx context.Context, databaseName string) (*Schem
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating tables: %w", err)
 	}
-
-	// Get columns for each table
-	schema := &Schema{
-		Tables: make([]TableInfo, 0, len(tableNames)),
-	}
-
-	for _, tableName := range tableNames {
-		tableInfo, err := c.getTableInfo(ctx, databaseName, tableName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get info for table %s: %w", tableName, err)
-		}
-		schema.Tables = append(schema.Tables, *tableInfo)
-	}
-
-	return schema, nil
+	return tableNames, nil
 }
 
 func (c *Connection) getTableInfo(ctx context.Context, databaseName, tableName string) (*TableInfo, error) {
+	if c.files != nil {
+		return c.fileTableInfo(tableName)
+	}
 	query := `
 		SELECT 
 			COLUMN_NAME,
@@ -132,3 +152,91 @@ func (s *Schema) FormatSchema() string {
 
 	return builder.String()
 }
+
+// PrimaryKey returns the primary key columns of a table in key order (empty when the table has none)
+// tableParts is the unquoted table name, optionally qualified with a schema/database
+func (c *Connection) PrimaryKey(ctx context.Context, tableParts []string) ([]string, error) {
+	return primaryKey(ctx, c.db, c.dbType, tableParts)
+}
+
+func primaryKey(ctx context.Context, q queryer, dbType string, tableParts []string) ([]string, error) {
+	if len(tableParts) == 0 {
+		return nil, fmt.Errorf("table name is required")
+	}
+	if dbType == "file" {
+		// Files have no keys
+		return nil, nil
+	}
+	var rows *sql.Rows
+	var err error
+	if dbType == "postgresql" {
+		query := `
+			SELECT a.attname
+			FROM pg_index i
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+			WHERE i.indrelid = $1::regclass AND i.indisprimary
+			ORDER BY array_position(i.indkey::int2[], a.attnum)
+		`
+		rows, err = q.QueryContext(ctx, query, sqlparse.QuoteQualified(tableParts, sqlparse.DialectPostgres))
+	} else {
+		schema, table := splitTableParts(tableParts)
+		query := `
+			SELECT COLUMN_NAME
+			FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+			WHERE TABLE_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+			ORDER BY ORDINAL_POSITION
+		`
+		rows, err = q.QueryContext(ctx, query, schema, table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query primary key: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to scan primary key column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating primary key columns: %w", err)
+	}
+	return columns, nil
+}
+
+// isTransactional reports whether the table's storage engine supports transactions
+// PostgreSQL tables always do; for MySQL the engine is looked up (MyISAM, MEMORY etc. cannot roll back)
+// A table whose engine cannot be determined is assumed to be transactional
+func isTransactional(ctx context.Context, q queryer, dbType string, tableParts []string) (bool, error) {
+	if dbType == "postgresql" || dbType == "file" || len(tableParts) == 0 {
+		return true, nil
+	}
+	schema, table := splitTableParts(tableParts)
+	query := `
+		SELECT e.TRANSACTIONS
+		FROM INFORMATION_SCHEMA.TABLES t
+		JOIN INFORMATION_SCHEMA.ENGINES e ON e.ENGINE = t.ENGINE
+		WHERE t.TABLE_SCHEMA = COALESCE(?, DATABASE()) AND t.TABLE_NAME = ?
+	`
+	var transactions sql.NullString
+	err := q.QueryRowContext(ctx, query, schema, table).Scan(&transactions)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query table engine: %w", err)
+	}
+	return !transactions.Valid || strings.EqualFold(transactions.String, "YES"), nil
+}
+
+// splitTableParts returns the schema (NULL when unqualified) and table name of a MySQL table reference
+func splitTableParts(tableParts []string) (sql.NullString, string) {
+	table := tableParts[len(tableParts)-1]
+	if len(tableParts) > 1 {
+		return sql.NullString{String: tableParts[len(tableParts)-2], Valid: true}, table
+	}
+	return sql.NullString{}, table
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoTransaction is returned when committing or rolling back without an open session transaction
+var ErrNoTransaction = errors.New("no transaction is open")
+
+// ErrTransactionOpen is returned when beginning a session transaction while one is already open
+var ErrTransactionOpen = errors.New("a transaction is already open")
+
+// Tx is a transaction on a connection pinned from the pool
+// All statements of the transaction run on the same server session until Commit or Rollback
+// A nested Tx is a savepoint inside an open transaction: Commit releases it and Rollback rolls back to it
+type Tx struct {
+	conn       *sql.Conn
+	tx         *sql.Tx
+	dbType     string
+	startedAt  time.Time
+	savepoints int          // Counter for generated savepoint names (root transaction only)
+	parent     *Tx          // Enclosing transaction of a nested Tx
+	savepoint  string       // Savepoint name of a nested Tx
+	cache      *resultCache // Result cache of the connection, invalidated on commit
+}
+
+// BeginTx starts a unit of work that can be committed or rolled back
+// Without a session transaction a connection is pinned and a transaction started on it;
+// ctx must stay valid for its life, since canceling ctx rolls it back
+// With an open session transaction (see Begin) a savepoint inside it is returned instead,
+// so the new unit of work sees the transaction's changes and stays part of it
+func (c *Connection) BeginTx(ctx context.Context) (*Tx, error) {
+	c.txMu.Lock()
+	session := c.tx
+	c.txMu.Unlock()
+	if session != nil {
+		return session.nested(ctx)
+	}
+	return c.beginTx(ctx)
+}
+
+func (c *Connection) beginTx(ctx context.Context) (*Tx, error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{conn: conn, tx: tx, dbType: c.dbType, startedAt: time.Now(), cache: c.cache}, nil
+}
+
+// DatabaseType returns the database type of the connection the transaction runs on
+func (t *Tx) DatabaseType() string {
+	return t.dbType
+}
+
+// StartedAt returns when the transaction began
+func (t *Tx) StartedAt() time.Time {
+	return t.startedAt
+}
+
+// ExecuteQuery executes a SQL query inside the transaction and returns the results
+func (t *Tx) ExecuteQuery(ctx context.Context, sqlQuery string) (*QueryResult, error) {
+	return executeQuery(ctx, t.tx, sqlQuery)
+}
+
+// ExecuteNonQuery executes a non-query SQL statement inside the transaction
+func (t *Tx) ExecuteNonQuery(ctx context.Context, sqlQuery string) (int64, error) {
+	return executeNonQuery(ctx, t.tx, sqlQuery)
+}
+
+// nested creates a savepoint in the transaction
+func (t *Tx) nested(ctx context.Context) (*Tx, error) {
+	name := t.nextSavepoint()
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	return &Tx{conn: t.conn, tx: t.tx, dbType: t.dbType, startedAt: time.Now(), parent: t, savepoint: name, cache: t.cache}, nil
+}
+
+// nextSavepoint returns a savepoint name unique within the root transaction
+func (t *Tx) nextSavepoint() string {
+	root := t
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.savepoints++
+	return fmt.Sprintf("aiq_sp_%d", root.savepoints)
+}
+
+// IsNested reports whether the Tx is a savepoint inside an enclosing transaction
+func (t *Tx) IsNested() bool {
+	return t.parent != nil
+}
+
+// isolated runs fn under a savepoint so that a failing statement leaves the transaction usable
+// PostgreSQL aborts the whole transaction on any error otherwise
+func (t *Tx) isolated(ctx context.Context, fn func() error) error {
+	name := t.nextSavepoint()
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w (failed to roll back to savepoint: %v)", err, rbErr)
+		}
+		return err
+	}
+	if _, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// QueryIsolated runs a query under a savepoint so that its failure leaves the transaction usable
+func (t *Tx) QueryIsolated(ctx context.Context, sqlQuery string) (*QueryResult, error) {
+	var result *QueryResult
+	err := t.isolated(ctx, func() error {
+		var err error
+		result, err = executeQuery(ctx, t.tx, sqlQuery)
+		return err
+	})
+	return result, err
+}
+
+// PrimaryKey returns the primary key columns of a table, read inside the transaction under a savepoint
+func (t *Tx) PrimaryKey(ctx context.Context, tableParts []string) ([]string, error) {
+	var columns []string
+	err := t.isolated(ctx, func() error {
+		var err error
+		columns, err = primaryKey(ctx, t.tx, t.dbType, tableParts)
+		return err
+	})
+	return columns, err
+}
+
+// IsTransactional reports whether changes to the table can be rolled back, read inside the transaction under a savepoint
+func (t *Tx) IsTransactional(ctx context.Context, tableParts []string) (bool, error) {
+	var transactional bool
+	err := t.isolated(ctx, func() error {
+		var err error
+		transactional, err = isTransactional(ctx, t.tx, t.dbType, tableParts)
+		return err
+	})
+	return transactional, err
+}
+
+// Commit commits the transaction and returns the pinned connection to the pool
+// For a nested Tx the savepoint is released and its changes become part of the enclosing transaction
+// Committing a root transaction drops the cached results of its scope (see Connection.SetCache)
+func (t *Tx) Commit() error {
+	if t.parent != nil {
+		if _, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		return nil
+	}
+	defer t.conn.Close()
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	t.cache.invalidate()
+	return nil
+}
+
+// Rollback rolls the transaction back and returns the pinned connection to the pool
+// For a nested Tx only the changes made since its savepoint are undone
+func (t *Tx) Rollback() error {
+	if t.parent != nil {
+		if _, err := t.tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint); err != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %w", err)
+		}
+		return nil
+	}
+	defer t.conn.Close()
+	if err := t.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("failed to roll back transaction: %w", err)
+	}
+	return nil
+}
+
+// Begin opens a session transaction on a pinned connection
+// Until Commit or Rollback, ExecuteQuery and ExecuteNonQuery run inside it
+func (c *Connection) Begin() error {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	if c.tx != nil {
+		return ErrTransactionOpen
+	}
+	// The session transaction outlives any single request, so it is not bound to a request context
+	tx, err := c.beginTx(context.Background())
+	if err != nil {
+		return err
+	}
+	c.tx = tx
+	return nil
+}
+
+// Commit commits the session transaction
+func (c *Connection) Commit() error {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	if c.tx == nil {
+		return ErrNoTransaction
+	}
+	tx := c.tx
+	c.tx = nil
+	return tx.Commit()
+}
+
+// Rollback rolls back the session transaction
+func (c *Connection) Rollback() error {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	if c.tx == nil {
+		return ErrNoTransaction
+	}
+	tx := c.tx
+	c.tx = nil
+	return tx.Rollback()
+}
+
+// Transaction returns the open session transaction, or nil
+func (c *Connection) Transaction() *Tx {
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+	return c.tx
+}
+
+// InTransaction reports whether a session transaction is open
+func (c *Connection) InTransaction() bool {
+	return c.Transaction() != nil
+}
+
+// queryer returns the session transaction if one is open, otherwise the connection pool
+func (c *Connection) queryer() queryer {
+	if tx := c.Transaction(); tx != nil {
+		return tx.tx
+	}
+	return c.db
+}
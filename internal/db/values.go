@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ValueResult holds query results with values kept exact enough to be written back
+// NULL is a nil entry (unlike QueryResult, where it is the string "NULL")
+type ValueResult struct {
+	Columns   []string
+	Rows      [][]*string
+	Size      int64 // Approximate size of the values in bytes
+	Truncated bool  // More rows or bytes were available than the limits allowed
+}
+
+// QueryValues runs a query and returns at most maxRows rows and roughly maxBytes of values
+// A limit of 0 means unlimited; Truncated reports whether a limit was hit
+// Inside a session transaction (see Begin) the query runs in the transaction
+func (c *Connection) QueryValues(ctx context.Context, sqlQuery string, maxRows int, maxBytes int64) (*ValueResult, error) {
+	return queryValues(ctx, c.queryer(), c.dbType, sqlQuery, maxRows, maxBytes)
+}
+
+// QueryValues runs a query inside the transaction under a savepoint (see Connection.QueryValues)
+func (t *Tx) QueryValues(ctx context.Context, sqlQuery string, maxRows int, maxBytes int64) (*ValueResult, error) {
+	var result *ValueResult
+	err := t.isolated(ctx, func() error {
+		var err error
+		result, err = queryValues(ctx, t.tx, t.dbType, sqlQuery, maxRows, maxBytes)
+		return err
+	})
+	return result, err
+}
+
+func queryValues(ctx context.Context, q queryer, dbType string, sqlQuery string, maxRows int, maxBytes int64) (*ValueResult, error) {
+	rows, err := q.QueryContext(ctx, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	result := &ValueResult{Columns: columns, Rows: make([][]*string, 0)}
+
+	for rows.Next() {
+		if maxRows > 0 && len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make([]*string, len(columns))
+		for i, val := range values {
+			if val == nil {
+				continue
+			}
+			s := exactValue(val, dbType)
+			row[i] = &s
+			result.Size += int64(len(s))
+		}
+		if maxBytes > 0 && result.Size > maxBytes {
+			result.Truncated = true
+			break
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return result, nil
+}
+
+// exactValue converts a scanned value to a string literal the database parses back to the same value
+// Times keep fractional seconds, and the zone offset for PostgreSQL (MySQL DATETIME has no zone)
+func exactValue(val interface{}, dbType string) string {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if dbType == "postgresql" {
+			return v.Format("2006-01-02 15:04:05.999999-07:00")
+		}
+		return v.Format("2006-01-02 15:04:05.999999")
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,137 @@
+package filedb
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// errReadOnly is returned for statements that would change data
+var errReadOnly = fmt.Errorf("file sources are read-only: only SELECT queries are supported")
+
+// Connector returns a database/sql connector querying the loaded tables, for use with sql.OpenDB
+func (d *DB) Connector() driver.Connector {
+	return &connector{db: d}
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fileDriver{}
+}
+
+// fileDriver only exists to satisfy driver.Connector; file sources are opened through Connector
+type fileDriver struct{}
+
+func (fileDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("file sources must be opened with filedb.Open")
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported on file sources")
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = value(arg.Value)
+	}
+	r, err := newExecutor(c.db, params).run(q)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{result: r}, nil
+}
+
+func (c *conn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return nil, errReadOnly
+}
+
+// value converts a query argument to an engine value
+func value(v driver.Value) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05")
+	}
+	return v
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errReadOnly
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return s.conn.QueryContext(context.Background(), s.query, named)
+}
+
+type rows struct {
+	result *result
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	for i, v := range r.result.rows[r.next] {
+		// Floats are sent as text so callers formatting with %g do not switch to exponents
+		if f, ok := v.(float64); ok {
+			v = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		dest[i] = v
+	}
+	r.next++
+	return nil
+}
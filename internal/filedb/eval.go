@@ -0,0 +1,853 @@
+package filedb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// evalCtx is the row (or group of rows) expressions are evaluated against
+type evalCtx struct {
+	x       *executor
+	scope   *scope
+	row     []interface{}
+	group   [][]interface{} // Rows of the current group, when grouped
+	grouped bool
+	aliases map[string]expr // Select list aliases, usable where a column name is not found
+	expand  map[string]bool // Aliases being expanded, to stop self-reference
+}
+
+func (c *evalCtx) eval(e expr) (interface{}, error) {
+	switch n := e.(type) {
+	case *literal:
+		return n.value, nil
+	case *param:
+		if n.index >= len(c.x.params) {
+			return nil, fmt.Errorf("missing value for parameter %d", n.index+1)
+		}
+		return c.x.params[n.index], nil
+	case *slot:
+		return c.row[n.index], nil
+	case *colRef:
+		index, err := c.scope.lookup(n)
+		if err != nil {
+			if alias, ok := c.aliases[strings.ToLower(n.name)]; ok && n.table == "" && !c.expand[n.name] {
+				if c.expand == nil {
+					c.expand = map[string]bool{}
+				}
+				c.expand[n.name] = true
+				defer delete(c.expand, n.name)
+				return c.eval(alias)
+			}
+			return nil, err
+		}
+		return c.row[index], nil
+	case *unary:
+		v, err := c.eval(n.operand)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if n.op == "NOT" {
+			b, null := truth(v)
+			if null {
+				return nil, nil
+			}
+			return !b, nil
+		}
+		return arith("*", v, int64(-1))
+	case *binary:
+		return c.binary(n)
+	case *isNull:
+		v, err := c.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return (v == nil) != n.not, nil
+	case *inList:
+		return c.in(n)
+	case *between:
+		v, err := c.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		low, err := c.eval(n.low)
+		if err != nil {
+			return nil, err
+		}
+		high, err := c.eval(n.high)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || low == nil || high == nil {
+			return nil, nil
+		}
+		return (compare(v, low) >= 0 && compare(v, high) <= 0) != n.not, nil
+	case *caseExpr:
+		return c.caseExpr(n)
+	case *cast:
+		v, err := c.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return castValue(v, n.typ)
+	case *call:
+		if aggregates[n.name] {
+			return c.aggregate(n)
+		}
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			v, err := c.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callFunction(n.name, args)
+	case *subquery:
+		rows, err := c.x.subqueryRows(n)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 1 {
+			return nil, fmt.Errorf("subquery used as a value returned more than one row")
+		}
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		return rows[0][0], nil
+	}
+	return nil, fmt.Errorf("unsupported expression")
+}
+
+func (c *evalCtx) binary(n *binary) (interface{}, error) {
+	left, err := c.eval(n.left)
+	if err != nil {
+		return nil, err
+	}
+	// AND and OR use three-valued logic and skip the right side when the left decides
+	if n.op == "AND" || n.op == "OR" {
+		l, lNull := truth(left)
+		if !lNull && l == (n.op == "OR") {
+			return l, nil
+		}
+		right, err := c.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		r, rNull := truth(right)
+		if !rNull && r == (n.op == "OR") {
+			return r, nil
+		}
+		if lNull || rNull {
+			return nil, nil
+		}
+		return n.op == "AND", nil
+	}
+
+	right, err := c.eval(n.right)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	switch n.op {
+	case "=":
+		return compare(left, right) == 0, nil
+	case "!=":
+		return compare(left, right) != 0, nil
+	case "<":
+		return compare(left, right) < 0, nil
+	case "<=":
+		return compare(left, right) <= 0, nil
+	case ">":
+		return compare(left, right) > 0, nil
+	case ">=":
+		return compare(left, right) >= 0, nil
+	case "||":
+		return text(left) + text(right), nil
+	case "LIKE":
+		return like(text(left), text(right)) != n.not, nil
+	}
+	return arith(n.op, left, right)
+}
+
+func (c *evalCtx) in(n *inList) (interface{}, error) {
+	v, err := c.eval(n.operand)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	var values []interface{}
+	if n.sub != nil {
+		rows, err := c.x.subqueryRows(n.sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			values = append(values, row[0])
+		}
+	} else {
+		for _, item := range n.list {
+			value, err := c.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+	sawNull := false
+	for _, value := range values {
+		if value == nil {
+			sawNull = true
+		} else if compare(v, value) == 0 {
+			return !n.not, nil
+		}
+	}
+	if sawNull {
+		return nil, nil
+	}
+	return n.not, nil
+}
+
+func (c *evalCtx) caseExpr(n *caseExpr) (interface{}, error) {
+	var operand interface{}
+	if n.operand != nil {
+		v, err := c.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		operand = v
+	}
+	for _, w := range n.whens {
+		cond, err := c.eval(w.cond)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		if n.operand != nil {
+			matched = operand != nil && cond != nil && compare(operand, cond) == 0
+		} else {
+			matched, _ = truth(cond)
+		}
+		if matched {
+			return c.eval(w.result)
+		}
+	}
+	if n.orElse != nil {
+		return c.eval(n.orElse)
+	}
+	return nil, nil
+}
+
+// aggregate computes an aggregate function over the rows of the current group
+func (c *evalCtx) aggregate(n *call) (interface{}, error) {
+	if !c.grouped {
+		return nil, fmt.Errorf("aggregate function %s is not allowed here", n.name)
+	}
+	if n.star {
+		if n.name != "COUNT" {
+			return nil, fmt.Errorf("%s(*) is not valid; only COUNT(*) is", n.name)
+		}
+		return int64(len(c.group)), nil
+	}
+	if len(n.args) == 0 {
+		return nil, fmt.Errorf("%s needs an argument", n.name)
+	}
+
+	// Values of the argument, evaluated per row of the group; NULLs are ignored
+	inner := &evalCtx{x: c.x, scope: c.scope, aliases: c.aliases}
+	var values []interface{}
+	seen := map[string]bool{}
+	for _, row := range c.group {
+		inner.row = row
+		v, err := inner.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		if n.distinct {
+			k := key(v)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		values = append(values, v)
+	}
+
+	switch n.name {
+	case "COUNT":
+		return int64(len(values)), nil
+	case "SUM", "AVG":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		var sumInt int64
+		var sumFloat float64
+		allInt := true
+		for _, v := range values {
+			num, ok := number(v)
+			if !ok {
+				return nil, fmt.Errorf("%s of '%s': not a number", n.name, text(v))
+			}
+			if i, isInt := num.(int64); isInt {
+				// A sum that overflows BIGINT is returned as DOUBLE
+				if sumInt, isInt = addInt(sumInt, i); !isInt {
+					allInt = false
+				}
+				sumFloat += float64(i)
+			} else {
+				allInt = false
+				sumFloat += num.(float64)
+			}
+		}
+		if n.name == "AVG" {
+			return sumFloat / float64(len(values)), nil
+		}
+		if allInt {
+			return sumInt, nil
+		}
+		return sumFloat, nil
+	case "MIN", "MAX":
+		var best interface{}
+		for _, v := range values {
+			if best == nil || (n.name == "MIN" && compare(v, best) < 0) || (n.name == "MAX" && compare(v, best) > 0) {
+				best = v
+			}
+		}
+		return best, nil
+	default:
+		// STRING_AGG(x, sep) and GROUP_CONCAT(x [SEPARATOR sep]); the separator defaults to a comma
+		sep := ","
+		if len(n.args) > 1 {
+			v, err := inner.eval(n.args[1])
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				sep = text(v)
+			}
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = text(v)
+		}
+		return strings.Join(parts, sep), nil
+	}
+}
+
+// subqueryRows runs an uncorrelated subquery once and returns its rows
+func (x *executor) subqueryRows(s *subquery) ([][]interface{}, error) {
+	if !s.done {
+		r, err := x.run(s.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run subquery (correlated subqueries are not supported): %w", err)
+		}
+		if len(r.columns) != 1 {
+			return nil, fmt.Errorf("subquery must return one column, not %d", len(r.columns))
+		}
+		s.rows, s.done = r.rows, true
+	}
+	return s.rows, nil
+}
+
+// castValue converts a value to a SQL type; values that do not convert become NULL
+func castValue(v interface{}, typ string) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case "INT", "INTEGER", "BIGINT", "SMALLINT", "TINYINT", "SIGNED", "UNSIGNED", "INT4", "INT8", "HUGEINT":
+		n, ok := number(v)
+		if !ok {
+			return nil, nil
+		}
+		if f, isFloat := n.(float64); isFloat {
+			return int64(f), nil
+		}
+		return n, nil
+	case "DOUBLE", "FLOAT", "REAL", "DECIMAL", "NUMERIC", "FLOAT4", "FLOAT8", "NUMBER":
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, nil
+		}
+		return f, nil
+	case "CHAR", "VARCHAR", "TEXT", "STRING", "CHARACTER", "NVARCHAR":
+		return text(v), nil
+	case "BOOLEAN", "BOOL":
+		if s, ok := v.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "true", "t", "yes", "1":
+				return true, nil
+			case "false", "f", "no", "0":
+				return false, nil
+			}
+			return nil, nil
+		}
+		b, _ := truth(v)
+		return b, nil
+	case "DATE":
+		t, ok := parseTime(v)
+		if !ok {
+			return nil, nil
+		}
+		return t.Format("2006-01-02"), nil
+	case "TIMESTAMP", "DATETIME", "TIMESTAMPTZ":
+		t, ok := parseTime(v)
+		if !ok {
+			return nil, nil
+		}
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+	return nil, fmt.Errorf("unknown type %s in CAST", typ)
+}
+
+// parseTime reads a date or timestamp value
+func parseTime(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, true
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// callFunction evaluates a scalar function
+func callFunction(name string, args []interface{}) (interface{}, error) {
+	arity := func(min, max int) error {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			if min == max {
+				return fmt.Errorf("%s takes %d argument(s), got %d", name, min, len(args))
+			}
+			return fmt.Errorf("%s takes %d to %d arguments, got %d", name, min, max, len(args))
+		}
+		return nil
+	}
+
+	// Functions handling NULL arguments themselves
+	switch name {
+	case "COALESCE", "IFNULL", "NVL":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		if args[0] != nil && args[1] != nil && compare(args[0], args[1]) == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	case "IF", "IIF":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		if ok, _ := truth(args[0]); ok {
+			return args[1], nil
+		}
+		return args[2], nil
+	case "CONCAT":
+		var b strings.Builder
+		for _, arg := range args {
+			if arg != nil {
+				b.WriteString(text(arg))
+			}
+		}
+		return b.String(), nil
+	case "CONCAT_WS":
+		if err := arity(1, -1); err != nil || args[0] == nil {
+			return nil, err
+		}
+		var parts []string
+		for _, arg := range args[1:] {
+			if arg != nil {
+				parts = append(parts, text(arg))
+			}
+		}
+		return strings.Join(parts, text(args[0])), nil
+	case "GREATEST", "LEAST":
+		var best interface{}
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+			if best == nil || (name == "GREATEST" && compare(arg, best) > 0) || (name == "LEAST" && compare(arg, best) < 0) {
+				best = arg
+			}
+		}
+		return best, nil
+	case "CURRENT_DATE":
+		return time.Now().Format("2006-01-02"), nil
+	case "CURRENT_TIMESTAMP", "NOW":
+		return time.Now().Format("2006-01-02 15:04:05"), nil
+	}
+
+	for _, arg := range args {
+		if arg == nil {
+			return nil, nil
+		}
+	}
+
+	switch name {
+	// Numbers
+	case "ABS", "FLOOR", "CEIL", "CEILING", "SQRT", "LN", "EXP", "SIGN":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		n, ok := number(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s of '%s': not a number", name, text(args[0]))
+		}
+		if i, isInt := n.(int64); isInt && name != "SQRT" && name != "LN" && name != "EXP" {
+			switch name {
+			case "ABS":
+				if i == math.MinInt64 {
+					return -float64(i), nil
+				}
+				if i < 0 {
+					return -i, nil
+				}
+			case "SIGN":
+				switch {
+				case i > 0:
+					return int64(1), nil
+				case i < 0:
+					return int64(-1), nil
+				}
+			}
+			return i, nil
+		}
+		f, _ := toFloat(n)
+		switch name {
+		case "ABS":
+			return math.Abs(f), nil
+		case "FLOOR":
+			return math.Floor(f), nil
+		case "CEIL", "CEILING":
+			return math.Ceil(f), nil
+		case "SQRT":
+			if f < 0 {
+				return nil, nil
+			}
+			return math.Sqrt(f), nil
+		case "LN":
+			if f <= 0 {
+				return nil, nil
+			}
+			return math.Log(f), nil
+		case "EXP":
+			return math.Exp(f), nil
+		default:
+			return float64(sign(f)), nil
+		}
+	case "ROUND":
+		if err := arity(1, 2); err != nil {
+			return nil, err
+		}
+		n, ok := number(args[0])
+		if !ok {
+			return nil, fmt.Errorf("ROUND of '%s': not a number", text(args[0]))
+		}
+		digits := int64(0)
+		if len(args) == 2 {
+			d, ok := number(args[1])
+			if !ok {
+				return nil, fmt.Errorf("ROUND digits must be a number")
+			}
+			f, _ := toFloat(d)
+			digits = int64(f)
+		}
+		if i, isInt := n.(int64); isInt && digits >= 0 {
+			return i, nil
+		}
+		f, _ := toFloat(n)
+		scale := math.Pow(10, float64(digits))
+		return math.Round(f*scale) / scale, nil
+	case "POWER", "POW", "MOD":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		if name == "MOD" {
+			return arith("%", args[0], args[1])
+		}
+		x, ok1 := toFloat(args[0])
+		y, ok2 := toFloat(args[1])
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s needs numbers", name)
+		}
+		return math.Pow(x, y), nil
+
+	// Text
+	case "UPPER", "UCASE":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(text(args[0])), nil
+	case "LOWER", "LCASE":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToLower(text(args[0])), nil
+	case "LENGTH", "CHAR_LENGTH", "CHARACTER_LENGTH", "LEN":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return int64(len([]rune(text(args[0])))), nil
+	case "TRIM", "LTRIM", "RTRIM":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		s := text(args[0])
+		switch name {
+		case "LTRIM":
+			return strings.TrimLeft(s, " \t\r\n"), nil
+		case "RTRIM":
+			return strings.TrimRight(s, " \t\r\n"), nil
+		}
+		return strings.TrimSpace(s), nil
+	case "SUBSTR", "SUBSTRING":
+		if err := arity(2, 3); err != nil {
+			return nil, err
+		}
+		runes := []rune(text(args[0]))
+		start, ok := toFloat(args[1])
+		if !ok {
+			return nil, fmt.Errorf("%s start must be a number", name)
+		}
+		from := int(start) - 1
+		if from < 0 {
+			from = 0
+		}
+		if from > len(runes) {
+			return "", nil
+		}
+		to := len(runes)
+		if len(args) == 3 {
+			length, ok := toFloat(args[2])
+			if !ok {
+				return nil, fmt.Errorf("%s length must be a number", name)
+			}
+			if end := int(start) - 1 + int(length); end < to {
+				to = end
+			}
+		}
+		if to < from {
+			return "", nil
+		}
+		return string(runes[from:to]), nil
+	case "LEFT", "RIGHT":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		runes := []rune(text(args[0]))
+		f, ok := toFloat(args[1])
+		if !ok {
+			return nil, fmt.Errorf("%s length must be a number", name)
+		}
+		n := int(f)
+		if n < 0 {
+			n = 0
+		}
+		if n > len(runes) {
+			n = len(runes)
+		}
+		if name == "LEFT" {
+			return string(runes[:n]), nil
+		}
+		return string(runes[len(runes)-n:]), nil
+	case "REPLACE":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(text(args[0]), text(args[1]), text(args[2])), nil
+	case "INSTR", "STRPOS":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		s, sub := text(args[0]), text(args[1])
+		i := strings.Index(s, sub)
+		if i < 0 {
+			return int64(0), nil
+		}
+		return int64(len([]rune(s[:i])) + 1), nil
+	case "SPLIT_PART":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		parts := strings.Split(text(args[0]), text(args[1]))
+		n, ok := toFloat(args[2])
+		if !ok || n < 1 || int(n) > len(parts) {
+			return "", nil
+		}
+		return parts[int(n)-1], nil
+
+	// Dates
+	case "DATE":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return castValue(args[0], "DATE")
+	case "YEAR", "MONTH", "DAY", "DAYOFMONTH", "QUARTER", "HOUR", "MINUTE", "SECOND":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return datePart(name, args[0])
+	case "EXTRACT", "DATE_PART":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		return datePart(strings.ToUpper(text(args[0])), args[1])
+	case "DATE_TRUNC":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		return dateTrunc(strings.ToLower(text(args[0])), args[1])
+	case "DATE_FORMAT", "STRFTIME", "TO_CHAR":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		value, format := args[0], text(args[1])
+		if name == "STRFTIME" {
+			value, format = args[1], text(args[0])
+		}
+		t, ok := parseTime(value)
+		if !ok {
+			return nil, nil
+		}
+		return formatTime(name, t, format), nil
+	case "DATEDIFF":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		a, ok1 := parseTime(args[0])
+		b, ok2 := parseTime(args[1])
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		return int64(truncateDay(a).Sub(truncateDay(b)).Hours() / 24), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", name)
+}
+
+func sign(f float64) int {
+	switch {
+	case f > 0:
+		return 1
+	case f < 0:
+		return -1
+	}
+	return 0
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// datePart extracts a part of a date or timestamp (EXTRACT, YEAR(), ...)
+func datePart(part string, v interface{}) (interface{}, error) {
+	t, ok := parseTime(v)
+	if !ok {
+		return nil, nil
+	}
+	switch part {
+	case "YEAR":
+		return int64(t.Year()), nil
+	case "QUARTER":
+		return int64((int(t.Month())-1)/3 + 1), nil
+	case "MONTH":
+		return int64(t.Month()), nil
+	case "WEEK":
+		_, week := t.ISOWeek()
+		return int64(week), nil
+	case "DAY", "DAYOFMONTH":
+		return int64(t.Day()), nil
+	case "DOW", "DAYOFWEEK":
+		return int64(t.Weekday()), nil
+	case "DOY", "DAYOFYEAR":
+		return int64(t.YearDay()), nil
+	case "HOUR":
+		return int64(t.Hour()), nil
+	case "MINUTE":
+		return int64(t.Minute()), nil
+	case "SECOND":
+		return int64(t.Second()), nil
+	case "EPOCH":
+		return t.Unix(), nil
+	}
+	return nil, fmt.Errorf("unknown date part %s", part)
+}
+
+// dateTrunc truncates a date or timestamp to a unit; dates come back as YYYY-MM-DD
+func dateTrunc(unit string, v interface{}) (interface{}, error) {
+	t, ok := parseTime(v)
+	if !ok {
+		return nil, nil
+	}
+	switch unit {
+	case "year":
+		return fmt.Sprintf("%04d-01-01", t.Year()), nil
+	case "quarter":
+		return fmt.Sprintf("%04d-%02d-01", t.Year(), (int(t.Month())-1)/3*3+1), nil
+	case "month":
+		return fmt.Sprintf("%04d-%02d-01", t.Year(), t.Month()), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7 // Weeks start on Monday
+		return truncateDay(t).AddDate(0, 0, -offset).Format("2006-01-02"), nil
+	case "day":
+		return t.Format("2006-01-02"), nil
+	case "hour":
+		return t.Format("2006-01-02 15:00:00"), nil
+	case "minute":
+		return t.Format("2006-01-02 15:04:00"), nil
+	}
+	return nil, fmt.Errorf("unknown DATE_TRUNC unit '%s' (year, quarter, month, week, day, hour, minute)", unit)
+}
+
+// formatTime formats a time with MySQL DATE_FORMAT / SQLite STRFTIME (%Y-%m-%d) or
+// PostgreSQL TO_CHAR (YYYY-MM-DD) patterns
+func formatTime(function string, t time.Time, format string) string {
+	if function == "TO_CHAR" {
+		return strings.NewReplacer(
+			"YYYY", fmt.Sprintf("%04d", t.Year()),
+			"MM", fmt.Sprintf("%02d", t.Month()),
+			"DD", fmt.Sprintf("%02d", t.Day()),
+			"HH24", fmt.Sprintf("%02d", t.Hour()),
+			"MI", fmt.Sprintf("%02d", t.Minute()),
+			"SS", fmt.Sprintf("%02d", t.Second()),
+			"Q", strconv.Itoa((int(t.Month())-1)/3+1),
+		).Replace(format)
+	}
+	minute := "%i" // MySQL
+	if function == "STRFTIME" {
+		minute = "%M"
+	}
+	return strings.NewReplacer(
+		"%Y", fmt.Sprintf("%04d", t.Year()),
+		"%y", fmt.Sprintf("%02d", t.Year()%100),
+		"%m", fmt.Sprintf("%02d", t.Month()),
+		"%d", fmt.Sprintf("%02d", t.Day()),
+		"%H", fmt.Sprintf("%02d", t.Hour()),
+		minute, fmt.Sprintf("%02d", t.Minute()),
+		"%s", fmt.Sprintf("%02d", t.Second()),
+		"%S", fmt.Sprintf("%02d", t.Second()),
+		"%%", "%",
+	).Replace(format)
+}
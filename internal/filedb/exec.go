@@ -0,0 +1,709 @@
+package filedb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxJoinPairs caps the row pairs a join without an equality condition may compare
+const maxJoinPairs = 50000000
+
+// result is the output of a query
+type result struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// relation is an intermediate set of rows with the columns they hold
+type relation struct {
+	scope *scope
+	rows  [][]interface{}
+}
+
+type scopeCol struct {
+	table  string // Table name or alias the column is qualified with
+	name   string
+	hidden bool // Right-hand column of a USING join, only reachable qualified
+}
+
+// scope lists the columns of a relation for name resolution
+type scope struct {
+	cols []scopeCol
+}
+
+// find returns the index of a column and the number of matches (more than one is ambiguous)
+func (s *scope) find(table, name string) (int, int) {
+	index, matches := -1, 0
+	for i, col := range s.cols {
+		if col.hidden && table == "" {
+			continue
+		}
+		if strings.EqualFold(col.name, name) && (table == "" || strings.EqualFold(col.table, table)) {
+			if matches == 0 {
+				index = i
+			}
+			matches++
+		}
+	}
+	return index, matches
+}
+
+// lookup resolves a column reference, caching the result on the reference
+func (s *scope) lookup(ref *colRef) (int, error) {
+	if ref.scope == s {
+		return ref.index, nil
+	}
+	index, matches := s.find(ref.table, ref.name)
+	switch {
+	case matches == 0:
+		return -1, fmt.Errorf("unknown column %s", ref.label())
+	case matches > 1:
+		return -1, fmt.Errorf("column %s is ambiguous; qualify it with a table name", ref.label())
+	}
+	ref.scope, ref.index = s, index
+	return index, nil
+}
+
+func (ref *colRef) label() string {
+	if ref.table != "" && ref.table != usingLeft {
+		return ref.table + "." + ref.name
+	}
+	return ref.name
+}
+
+// slot is a column selected by * (by position, so duplicate names from joins stay distinct)
+type slot struct {
+	index int
+}
+
+// executor runs parsed queries against a DB
+type executor struct {
+	db     *DB
+	params []interface{}
+	ctes   map[string]*result // Common table expressions by lower-case name
+}
+
+func newExecutor(db *DB, params []interface{}) *executor {
+	return &executor{db: db, params: params, ctes: map[string]*result{}}
+}
+
+// run executes a query
+func (x *executor) run(q *query) (*result, error) {
+	for _, c := range q.with {
+		r, err := x.run(c.query)
+		if err != nil {
+			return nil, err
+		}
+		x.ctes[strings.ToLower(c.name)] = r
+	}
+	if len(q.cores) == 1 {
+		return x.core(q.cores[0], q.orderBy, q.limit, q.offset)
+	}
+
+	// UNION: ORDER BY and LIMIT apply to the combined rows
+	combined, err := x.core(q.cores[0], nil, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, core := range q.cores[1:] {
+		next, err := x.core(core, nil, -1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(next.columns) != len(combined.columns) {
+			return nil, fmt.Errorf("each UNION query must have the same number of columns (%d and %d)", len(combined.columns), len(next.columns))
+		}
+		combined.rows = append(combined.rows, next.rows...)
+		if !q.unionAll[i] {
+			combined.rows = distinct(combined.rows)
+		}
+	}
+
+	keys := make([][]interface{}, len(combined.rows))
+	var order []int
+	for _, item := range q.orderBy {
+		index, ok := outputIndex(item.expr, combined.columns)
+		if !ok {
+			return nil, fmt.Errorf("ORDER BY of a UNION must name an output column or position")
+		}
+		order = append(order, index)
+	}
+	for r, row := range combined.rows {
+		for _, index := range order {
+			keys[r] = append(keys[r], row[index])
+		}
+	}
+	combined.rows = sortRows(combined.rows, keys, q.orderBy)
+	combined.rows = window(combined.rows, q.limit, q.offset)
+	return combined, nil
+}
+
+// outputIndex resolves an ORDER BY item naming an output column by position (1-based) or name
+func outputIndex(e expr, columns []string) (int, bool) {
+	switch n := e.(type) {
+	case *literal:
+		if i, ok := n.value.(int64); ok && i >= 1 && int(i) <= len(columns) {
+			return int(i) - 1, true
+		}
+	case *colRef:
+		if n.table == "" {
+			for i, col := range columns {
+				if strings.EqualFold(col, n.name) {
+					return i, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// core executes one SELECT with its ORDER BY and LIMIT
+func (x *executor) core(c *selectCore, orderBy []orderItem, limit, offset int) (*result, error) {
+	rel, err := x.from(c.from)
+	if err != nil {
+		return nil, err
+	}
+
+	// * expands to the columns in scope
+	var items []selectItem
+	for _, item := range c.items {
+		if !item.star {
+			items = append(items, item)
+			continue
+		}
+		found := false
+		for i, col := range rel.scope.cols {
+			if (item.starTable == "" && !col.hidden) || strings.EqualFold(col.table, item.starTable) {
+				items = append(items, selectItem{expr: &slot{index: i}, text: col.name})
+				found = true
+			}
+		}
+		if !found && item.starTable != "" {
+			return nil, fmt.Errorf("unknown table %s in %s.*", item.starTable, item.starTable)
+		}
+	}
+	columns := make([]string, len(items))
+	aliases := map[string]expr{}
+	for i, item := range items {
+		columns[i] = item.text
+		if item.alias != "" {
+			columns[i] = item.alias
+			aliases[strings.ToLower(item.alias)] = item.expr
+		}
+	}
+
+	ctx := &evalCtx{x: x, scope: rel.scope, aliases: aliases}
+	rows := rel.rows
+	if c.where != nil {
+		if hasAggregate(c.where) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in WHERE; use HAVING")
+		}
+		filtered := make([][]interface{}, 0, len(rows))
+		for _, row := range rows {
+			ctx.row = row
+			v, err := ctx.eval(c.where)
+			if err != nil {
+				return nil, err
+			}
+			if ok, _ := truth(v); ok {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	// ORDER BY items name an output column (position or alias) or are evaluated per row or group
+	orderIndex := make([]int, len(orderBy))
+	for i, item := range orderBy {
+		orderIndex[i] = -1
+		index, ok := outputIndex(item.expr, columns)
+		if !ok {
+			continue
+		}
+		// Aliases win over source columns of the same name; other column names are evaluated
+		if ref, isRef := item.expr.(*colRef); isRef && aliases[strings.ToLower(ref.name)] == nil {
+			if _, matches := rel.scope.find("", ref.name); matches > 0 {
+				continue
+			}
+		}
+		orderIndex[i] = index
+	}
+
+	aggregate := len(c.groupBy) > 0 || c.having != nil
+	for _, item := range items {
+		aggregate = aggregate || hasAggregate(item.expr)
+	}
+	for i, item := range orderBy {
+		aggregate = aggregate || (orderIndex[i] < 0 && hasAggregate(item.expr))
+	}
+
+	var out [][]interface{}
+	var keys [][]interface{}
+	emit := func() error {
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := ctx.eval(item.expr)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+		var rowKeys []interface{}
+		for i, item := range orderBy {
+			if orderIndex[i] >= 0 {
+				rowKeys = append(rowKeys, values[orderIndex[i]])
+				continue
+			}
+			v, err := ctx.eval(item.expr)
+			if err != nil {
+				return err
+			}
+			rowKeys = append(rowKeys, v)
+		}
+		out = append(out, values)
+		keys = append(keys, rowKeys)
+		return nil
+	}
+
+	if !aggregate {
+		for _, row := range rows {
+			ctx.row = row
+			if err := emit(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		groups, err := x.group(ctx, c.groupBy, items, rows)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkGrouped(items, c.groupBy, rel.scope); err != nil {
+			return nil, err
+		}
+		ctx.grouped = true
+		for _, group := range groups {
+			ctx.group = group
+			ctx.row = make([]interface{}, len(rel.scope.cols))
+			if len(group) > 0 {
+				ctx.row = group[0]
+			}
+			if c.having != nil {
+				v, err := ctx.eval(c.having)
+				if err != nil {
+					return nil, err
+				}
+				if ok, _ := truth(v); !ok {
+					continue
+				}
+			}
+			if err := emit(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if c.distinct {
+		seen := map[string]bool{}
+		var dedupedRows, dedupedKeys [][]interface{}
+		for i, row := range out {
+			k := rowKey(row)
+			if !seen[k] {
+				seen[k] = true
+				dedupedRows = append(dedupedRows, row)
+				dedupedKeys = append(dedupedKeys, keys[i])
+			}
+		}
+		out, keys = dedupedRows, dedupedKeys
+	}
+	out = sortRows(out, keys, orderBy)
+	out = window(out, limit, offset)
+	if out == nil {
+		out = [][]interface{}{}
+	}
+	return &result{columns: columns, rows: out}, nil
+}
+
+// group splits rows by the GROUP BY values, keeping groups in order of first appearance
+// Without GROUP BY all rows form one group, even when there are none (SELECT COUNT(*) on no rows)
+// checkGrouped rejects selected columns that are neither grouped nor inside an aggregate function
+func checkGrouped(items []selectItem, groupBy []expr, s *scope) error {
+	grouped := map[int]bool{}
+	for _, e := range groupBy {
+		switch n := e.(type) {
+		case *literal:
+			// GROUP BY 1
+			if pos, ok := n.value.(int64); ok && pos >= 1 && int(pos) <= len(items) {
+				e = items[pos-1].expr
+			}
+		case *colRef:
+			// GROUP BY alias
+			if _, err := s.lookup(n); err != nil && n.table == "" {
+				for _, item := range items {
+					if strings.EqualFold(item.alias, n.name) {
+						e = item.expr
+					}
+				}
+			}
+		}
+		for _, index := range columnIndexes(e, s, false) {
+			grouped[index] = true
+		}
+	}
+	for _, item := range items {
+		for _, index := range columnIndexes(item.expr, s, true) {
+			if !grouped[index] {
+				return fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate function", s.cols[index].name)
+			}
+		}
+	}
+	return nil
+}
+
+// columnIndexes lists the scope columns an expression reads, optionally skipping aggregate arguments
+func columnIndexes(e expr, s *scope, skipAggregates bool) []int {
+	inAggregate := map[expr]bool{}
+	var indexes []int
+	walk(e, func(e expr) {
+		if inAggregate[e] {
+			return
+		}
+		switch n := e.(type) {
+		case *call:
+			if skipAggregates && aggregates[n.name] {
+				for _, arg := range n.args {
+					walk(arg, func(e expr) { inAggregate[e] = true })
+				}
+			}
+		case *slot:
+			indexes = append(indexes, n.index)
+		case *colRef:
+			if index, err := s.lookup(n); err == nil {
+				indexes = append(indexes, index)
+			}
+		}
+	})
+	return indexes
+}
+
+func (x *executor) group(ctx *evalCtx, groupBy []expr, items []selectItem, rows [][]interface{}) ([][][]interface{}, error) {
+	if len(groupBy) == 0 {
+		return [][][]interface{}{rows}, nil
+	}
+	// GROUP BY 1 names the first selected column
+	exprs := make([]expr, len(groupBy))
+	for i, e := range groupBy {
+		exprs[i] = e
+		if lit, ok := e.(*literal); ok {
+			if n, ok := lit.value.(int64); ok {
+				if n < 1 || int(n) > len(items) {
+					return nil, fmt.Errorf("GROUP BY position %d is not in the select list", n)
+				}
+				exprs[i] = items[n-1].expr
+			}
+		}
+		if hasAggregate(exprs[i]) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY")
+		}
+	}
+
+	index := map[string]int{}
+	var groups [][][]interface{}
+	values := make([]interface{}, len(exprs))
+	for _, row := range rows {
+		ctx.row = row
+		for i, e := range exprs {
+			v, err := ctx.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		k := rowKey(values)
+		g, ok := index[k]
+		if !ok {
+			g = len(groups)
+			index[k] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], row)
+	}
+	return groups, nil
+}
+
+// sortRows orders rows by their sort keys (stable, so ties keep their order)
+func sortRows(rows [][]interface{}, keys [][]interface{}, orderBy []orderItem) [][]interface{} {
+	if len(orderBy) == 0 {
+		return rows
+	}
+	indexes := make([]int, len(rows))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		ka, kb := keys[indexes[a]], keys[indexes[b]]
+		for i, item := range orderBy {
+			va, vb := ka[i], kb[i]
+			// NULLs sort first ascending and last descending unless NULLS FIRST/LAST says otherwise
+			if (va == nil) != (vb == nil) && item.nullsFirst != nil {
+				return (va == nil) == *item.nullsFirst
+			}
+			c := sortCompare(va, vb)
+			if item.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	sorted := make([][]interface{}, len(rows))
+	for i, index := range indexes {
+		sorted[i] = rows[index]
+	}
+	return sorted
+}
+
+// window applies OFFSET and LIMIT (-1 for none)
+func window(rows [][]interface{}, limit, offset int) [][]interface{} {
+	if offset > 0 {
+		if offset >= len(rows) {
+			return rows[:0]
+		}
+		rows = rows[offset:]
+	}
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+func distinct(rows [][]interface{}) [][]interface{} {
+	seen := map[string]bool{}
+	var out [][]interface{}
+	for _, row := range rows {
+		k := rowKey(row)
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// from builds the relation of a FROM clause; without FROM there is a single empty row
+func (x *executor) from(refs []tableRef) (*relation, error) {
+	if len(refs) == 0 {
+		return &relation{scope: &scope{}, rows: [][]interface{}{{}}}, nil
+	}
+	rel, err := x.source(refs[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs[1:] {
+		right, err := x.source(ref)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err = x.join(rel, right, ref); err != nil {
+			return nil, err
+		}
+	}
+	return rel, nil
+}
+
+// source returns the rows of a table, common table expression or subquery in FROM
+func (x *executor) source(ref tableRef) (*relation, error) {
+	var columns []string
+	var rows [][]interface{}
+	if ref.subquery != nil {
+		r, err := x.run(ref.subquery)
+		if err != nil {
+			return nil, err
+		}
+		columns, rows = r.columns, r.rows
+	} else if r, ok := x.ctes[strings.ToLower(ref.name)]; ok {
+		columns, rows = r.columns, r.rows
+	} else if t, ok := x.db.Table(ref.name); ok {
+		for _, col := range t.Columns {
+			columns = append(columns, col.Name)
+		}
+		rows = t.Rows
+	} else {
+		var names []string
+		for _, t := range x.db.Tables() {
+			names = append(names, t.Name)
+		}
+		return nil, fmt.Errorf("table %s not found (tables: %s)", ref.name, strings.Join(names, ", "))
+	}
+
+	s := &scope{cols: make([]scopeCol, len(columns))}
+	for i, name := range columns {
+		s.cols[i] = scopeCol{table: ref.label(), name: name}
+	}
+	return &relation{scope: s, rows: rows}, nil
+}
+
+// join combines two relations; equality conditions between the two sides use a hash join
+func (x *executor) join(left, right *relation, ref tableRef) (*relation, error) {
+	s := &scope{cols: append(append([]scopeCol{}, left.scope.cols...), right.scope.cols...)}
+	rel := &relation{scope: s}
+	width := len(s.cols)
+	combine := func(l, r []interface{}) []interface{} {
+		row := make([]interface{}, 0, width)
+		row = append(row, l...)
+		if r == nil {
+			return append(row, make([]interface{}, len(right.scope.cols))...)
+		}
+		return append(row, r...)
+	}
+
+	if ref.join == "cross" {
+		if len(left.rows)*len(right.rows) > maxJoinPairs {
+			return nil, fmt.Errorf("join of %d and %d rows is too large; join with a condition instead", len(left.rows), len(right.rows))
+		}
+		for _, l := range left.rows {
+			for _, r := range right.rows {
+				rel.rows = append(rel.rows, combine(l, r))
+			}
+		}
+		return rel, nil
+	}
+
+	// Columns of USING conditions come from the tables joined so far
+	var resolveErr error
+	walk(ref.on, func(e expr) {
+		if c, ok := e.(*colRef); ok && c.table == usingLeft {
+			index, matches := left.scope.find("", c.name)
+			if matches == 0 {
+				resolveErr = fmt.Errorf("column %s in USING is not in the joined tables", c.name)
+				return
+			}
+			c.table = left.scope.cols[index].table
+			if index, matches := right.scope.find("", c.name); matches > 0 {
+				s.cols[len(left.scope.cols)+index].hidden = true
+			}
+		}
+	})
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	if hasAggregate(ref.on) {
+		return nil, fmt.Errorf("aggregate functions are not allowed in ON")
+	}
+
+	ctx := &evalCtx{x: x, scope: s}
+	matches := func(row []interface{}) (bool, error) {
+		ctx.row = row
+		v, err := ctx.eval(ref.on)
+		if err != nil {
+			return false, err
+		}
+		ok, _ := truth(v)
+		return ok, nil
+	}
+
+	leftIndex, rightIndex, hashed := equiJoinColumns(ref.on, left.scope, right.scope)
+	if !hashed && len(left.rows)*len(right.rows) > maxJoinPairs {
+		return nil, fmt.Errorf("join of %d and %d rows is too large without an equality condition (a.col = b.col)", len(left.rows), len(right.rows))
+	}
+	var buckets map[string][]int
+	if hashed {
+		buckets = map[string][]int{}
+		for i, r := range right.rows {
+			if v := r[rightIndex]; v != nil {
+				k := hashKey(v)
+				buckets[k] = append(buckets[k], i)
+			}
+		}
+	}
+
+	for _, l := range left.rows {
+		matched := false
+		try := func(r []interface{}) error {
+			row := combine(l, r)
+			ok, err := matches(row)
+			if err != nil {
+				return err
+			}
+			if ok {
+				rel.rows = append(rel.rows, row)
+				matched = true
+			}
+			return nil
+		}
+		if hashed {
+			if v := l[leftIndex]; v != nil {
+				for _, i := range buckets[hashKey(v)] {
+					if err := try(right.rows[i]); err != nil {
+						return nil, err
+					}
+				}
+			}
+		} else {
+			for _, r := range right.rows {
+				if err := try(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !matched && ref.join == "left" {
+			rel.rows = append(rel.rows, combine(l, nil))
+		}
+	}
+	return rel, nil
+}
+
+// equiJoinColumns finds a condition of ON (at the top level of its ANDs) equating a column of
+// each side, returning the column positions in the left and right rows
+func equiJoinColumns(on expr, left, right *scope) (int, int, bool) {
+	var conjuncts []expr
+	var split func(e expr)
+	split = func(e expr) {
+		if b, ok := e.(*binary); ok && b.op == "AND" {
+			split(b.left)
+			split(b.right)
+			return
+		}
+		conjuncts = append(conjuncts, e)
+	}
+	split(on)
+
+	// column returns the position of a column reference resolving on one side only
+	column := func(e expr, s, other *scope) int {
+		ref, ok := e.(*colRef)
+		if !ok {
+			return -1
+		}
+		index, matches := s.find(ref.table, ref.name)
+		if _, otherMatches := other.find(ref.table, ref.name); matches != 1 || otherMatches != 0 {
+			return -1
+		}
+		return index
+	}
+	for _, c := range conjuncts {
+		b, ok := c.(*binary)
+		if !ok || b.op != "=" {
+			continue
+		}
+		if l, r := column(b.left, left, right), column(b.right, right, left); l >= 0 && r >= 0 {
+			return l, r, true
+		}
+		if l, r := column(b.right, left, right), column(b.left, right, left); l >= 0 && r >= 0 {
+			return l, r, true
+		}
+	}
+	return 0, 0, false
+}
+
+// hashKey buckets join values; text holding a number shares the number's bucket, since the join
+// condition compares them numerically (candidates are checked against the full condition)
+func hashKey(v interface{}) string {
+	if s, ok := v.(string); ok {
+		if n, ok := number(s); ok {
+			return key(n)
+		}
+	}
+	return key(v)
+}
@@ -0,0 +1,186 @@
+// Package filedb is a read-only SQL engine over local data files
+// CSV, TSV, JSON and Parquet files are loaded into memory as tables (one per file, named after it) and
+// queried with a SELECT subset through database/sql, so file sources work like database sources
+//
+// The engine is built on the sqlparse tokenizer instead of embedding SQLite or DuckDB: DuckDB and the
+// SQLite driver need cgo, which the cross-compiled release binaries cannot use, and the pure Go SQLite port
+// adds several megabytes to a binary that is mostly used against real databases. A SELECT-only engine is
+// also read-only by construction, and tokenizes statements the same way as the risk policy that checks them
+package filedb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Column types inferred from file contents
+const (
+	TypeBigint    = "BIGINT"
+	TypeDouble    = "DOUBLE"
+	TypeBoolean   = "BOOLEAN"
+	TypeDate      = "DATE"
+	TypeTimestamp = "TIMESTAMP"
+	TypeVarchar   = "VARCHAR"
+)
+
+// MaxFileBytes caps the size of a single loaded file, since tables are kept in memory
+const MaxFileBytes = 1 << 30
+
+// Column is a table column with its inferred type
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool // The file has empty or null values in the column
+}
+
+// Table is a file (or rows built with FromRows) loaded into memory
+// Values are nil (NULL), int64, float64, bool or string (text, dates and timestamps)
+type Table struct {
+	Name    string
+	Path    string
+	Format  string // csv, tsv, json or parquet; empty for FromRows
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// DB is a set of tables loaded from files
+type DB struct {
+	tables []*Table
+}
+
+// Open loads files as tables; a directory loads every supported file in it (not recursively)
+func Open(paths []string) (*DB, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files given")
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
+		}
+		found := false
+		for _, entry := range entries {
+			if !entry.IsDir() && Supported(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no CSV, TSV, JSON or Parquet files in directory %s", path)
+		}
+	}
+
+	d := &DB{}
+	names := map[string]bool{}
+	for _, file := range files {
+		t, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		t.Name = uniqueName(tableName(file), names)
+		d.tables = append(d.tables, t)
+	}
+	sort.Slice(d.tables, func(i, j int) bool { return d.tables[i].Name < d.tables[j].Name })
+	return d, nil
+}
+
+// OpenDSN loads the files of a file source DSN: paths separated by the OS path list separator
+func OpenDSN(dsn string) (*DB, error) {
+	return Open(filepath.SplitList(dsn))
+}
+
+// New returns a DB over tables built in memory, e.g. with FromRows
+func New(tables ...*Table) *DB {
+	d := &DB{tables: tables}
+	sort.Slice(d.tables, func(i, j int) bool { return d.tables[i].Name < d.tables[j].Name })
+	return d
+}
+
+// FromRows builds a table from rows of text, e.g. a query result, inferring column types as for files
+// Cells equal to null are NULL
+func FromRows(name string, header []string, rows [][]string, null string) *Table {
+	records := make([][]*string, len(rows))
+	for r, row := range rows {
+		record := make([]*string, len(row))
+		for c := range row {
+			if row[c] != null {
+				record[c] = &row[c]
+			}
+		}
+		records[r] = record
+	}
+	t := &Table{Name: name}
+	t.Columns, t.Rows = infer(columnNames(header), records)
+	return t
+}
+
+// Supported reports whether a file name has a supported extension
+func Supported(name string) bool {
+	_, ok := formatOf(name)
+	return ok
+}
+
+// Tables returns the tables sorted by name
+func (d *DB) Tables() []*Table {
+	return d.tables
+}
+
+// Table returns a table by name (case-insensitive)
+func (d *DB) Table(name string) (*Table, bool) {
+	for _, t := range d.tables {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// tableName derives a table name from a file name: the base name without extension, with
+// characters that would need quoting replaced by underscores
+func tableName(path string) string {
+	base := filepath.Base(path)
+	for {
+		ext := filepath.Ext(base)
+		if ext == "" || ext == base {
+			break
+		}
+		if _, ok := formats[strings.ToLower(ext)]; !ok && !strings.EqualFold(ext, ".gz") {
+			break
+		}
+		base = strings.TrimSuffix(base, ext)
+	}
+	var b strings.Builder
+	for _, ch := range strings.ToLower(base) {
+		if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') {
+			b.WriteRune(ch)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	name := strings.Trim(b.String(), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "t_" + name
+	}
+	return name
+}
+
+func uniqueName(name string, used map[string]bool) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[candidate] = true
+	return candidate
+}
@@ -0,0 +1,378 @@
+package filedb
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testDB loads a sales CSV, a regions JSON file and an events JSON Lines file
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "sales.csv", "month,region,revenue,units,zip\n"+
+		"2024-01-15,north,100.5,3,01234\n"+
+		"2024-01-20,south,80,2,02345\n"+
+		"2024-02-03,north,120,,01234\n"+
+		"2024-02-11,east,,1,09999\n"+
+		"2024-03-01,north,90.25,4,01234\n")
+	writeFile(t, dir, "regions.json", `[{"region": "north", "manager": "Ann", "active": true},
+		{"region": "south", "manager": "Bob", "active": false},
+		{"region": "west", "manager": null, "active": true}]`)
+	writeFile(t, dir, "events.jsonl", `{"id": 1, "at": "2024-01-01 10:00:00", "tags": ["a", "b"]}
+{"id": 2, "at": "2024-01-02T11:30:00"}
+`)
+	writeFile(t, dir, "notes.txt", "ignored")
+	d, err := Open([]string{dir})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return d
+}
+
+// runQuery runs a query through database/sql and returns the rows as text, NULL for nil
+func runQuery(d *DB, q string, args ...interface{}) ([]string, [][]string, error) {
+	conn := sql.OpenDB(d.Connector())
+	defer conn.Close()
+	rows, err := conn.Query(q, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	var out [][]string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				row[i] = "NULL"
+			} else {
+				row[i] = fmt.Sprintf("%v", v)
+			}
+		}
+		out = append(out, row)
+	}
+	return columns, out, rows.Err()
+}
+
+func TestOpen(t *testing.T) {
+	d := testDB(t)
+	var names []string
+	for _, table := range d.Tables() {
+		names = append(names, table.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"events", "regions", "sales"}) {
+		t.Fatalf("tables = %v", names)
+	}
+
+	sales, _ := d.Table("SALES")
+	want := []Column{
+		{Name: "month", Type: TypeDate},
+		{Name: "region", Type: TypeVarchar},
+		{Name: "revenue", Type: TypeDouble, Nullable: true},
+		{Name: "units", Type: TypeBigint, Nullable: true},
+		{Name: "zip", Type: TypeVarchar},
+	}
+	if !reflect.DeepEqual(sales.Columns, want) {
+		t.Errorf("sales columns = %+v", sales.Columns)
+	}
+	if len(sales.Rows) != 5 {
+		t.Errorf("sales rows = %d", len(sales.Rows))
+	}
+
+	regions, _ := d.Table("regions")
+	if regions.Columns[2].Type != TypeBoolean || !regions.Columns[1].Nullable {
+		t.Errorf("regions columns = %+v", regions.Columns)
+	}
+	events, _ := d.Table("events")
+	if events.Columns[1].Type != TypeTimestamp || events.Rows[0][2] != `["a","b"]` {
+		t.Errorf("events = %+v %v", events.Columns, events.Rows)
+	}
+}
+
+func TestOpenFormats(t *testing.T) {
+	dir := t.TempDir()
+	semicolon := writeFile(t, dir, "2024 Report.csv", "\ufeffname;amount\n\"a;b\";1,5\nc;2\n")
+	tsv := writeFile(t, dir, "data.tsv", "x\ty\n1\t2\n3\n")
+	wrapped := writeFile(t, dir, "wrapped.json", `{"data": [{"k": 1}, {"k": 2}]}`)
+
+	gzPath := filepath.Join(dir, "packed.csv.gz")
+	f, _ := os.Create(gzPath)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("a,a\n1,2\n"))
+	gz.Close()
+	f.Close()
+
+	d, err := Open([]string{semicolon, tsv, wrapped, gzPath})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	report, ok := d.Table("t_2024_report")
+	if !ok || report.Columns[0].Name != "name" || report.Rows[0][0] != "a;b" || report.Rows[0][1] != "1,5" {
+		t.Errorf("semicolon CSV = %+v", report)
+	}
+	data, _ := d.Table("data")
+	if data.Rows[1][1] != nil || data.Columns[1].Type != TypeBigint {
+		t.Errorf("ragged TSV = %+v", data)
+	}
+	w, _ := d.Table("wrapped")
+	if len(w.Rows) != 2 || w.Rows[1][0] != int64(2) {
+		t.Errorf("wrapped JSON = %+v", w)
+	}
+	packed, _ := d.Table("packed")
+	if packed.Columns[1].Name != "a_2" || packed.Rows[0][1] != int64(2) {
+		t.Errorf("gzip CSV = %+v", packed)
+	}
+
+	if _, err := Open([]string{filepath.Join(dir, "x.parquet")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	invalid := writeFile(t, dir, "x.parquet", "PAR1")
+	if _, err := Open([]string{invalid}); err == nil {
+		t.Error("expected an error for an invalid Parquet file")
+	}
+}
+
+type parquetOrder struct {
+	ID       int64            `parquet:"id"`
+	Customer string           `parquet:"customer"`
+	Amount   int64            `parquet:"amount,decimal(2:18)"`
+	Paid     bool             `parquet:"paid"`
+	Placed   time.Time        `parquet:"placed,timestamp(microsecond)"`
+	Shipped  int32            `parquet:"shipped,date"`
+	Note     *string          `parquet:"note,optional"`
+	Items    []string         `parquet:"items,list"`
+	Attrs    map[string]int64 `parquet:"attrs"`
+}
+
+func TestOpenParquet(t *testing.T) {
+	note := "gift"
+	placed := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "orders.parquet")
+	err := parquet.WriteFile(path, []parquetOrder{
+		{ID: 1, Customer: "ann", Amount: 1250, Paid: true, Placed: placed, Shipped: 19785, Note: &note, Items: []string{"a", "b"}, Attrs: map[string]int64{"qty": 2}},
+		{ID: 2, Customer: "bob", Amount: 399, Placed: placed.Add(time.Hour), Shipped: 19786},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := Open([]string{path})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	orders, _ := d.Table("orders")
+	want := []Column{
+		{Name: "id", Type: TypeBigint},
+		{Name: "customer", Type: TypeVarchar},
+		{Name: "amount", Type: TypeDouble},
+		{Name: "paid", Type: TypeBoolean},
+		{Name: "placed", Type: TypeTimestamp},
+		{Name: "shipped", Type: TypeDate},
+		{Name: "note", Type: TypeVarchar, Nullable: true},
+		{Name: "items", Type: TypeVarchar},
+		{Name: "attrs", Type: TypeVarchar},
+	}
+	if !reflect.DeepEqual(orders.Columns, want) || orders.Format != "parquet" {
+		t.Errorf("orders columns = %+v", orders.Columns)
+	}
+	wantRow := []interface{}{int64(1), "ann", 12.5, true, "2024-03-01 09:30:00", "2024-03-03", "gift", `["a","b"]`, `{"qty":2}`}
+	if !reflect.DeepEqual(orders.Rows[0], wantRow) {
+		t.Errorf("first row = %#v", orders.Rows[0])
+	}
+
+	_, rows, err := runQuery(d, "SELECT customer, amount FROM orders WHERE placed > '2024-03-01 10:00' AND note IS NULL")
+	if err != nil || !reflect.DeepEqual(rows, [][]string{{"bob", "3.99"}}) {
+		t.Errorf("query = %v, %v", rows, err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	d := testDB(t)
+	tests := []struct {
+		name    string
+		sql     string
+		args    []interface{}
+		columns []string
+		rows    [][]string
+	}{
+		{
+			name:    "where and order",
+			sql:     `SELECT region, revenue FROM sales WHERE revenue > 85 ORDER BY revenue DESC`,
+			columns: []string{"region", "revenue"},
+			rows:    [][]string{{"north", "120"}, {"north", "100.5"}, {"north", "90.25"}},
+		},
+		{
+			name:    "group by with aggregates and having",
+			sql:     `SELECT region, COUNT(*) AS n, SUM(units) total, ROUND(AVG(revenue), 1) avg_rev FROM sales GROUP BY region HAVING COUNT(*) > 0 ORDER BY n DESC, region`,
+			columns: []string{"region", "n", "total", "avg_rev"},
+			rows:    [][]string{{"north", "3", "7", "103.6"}, {"east", "1", "1", "NULL"}, {"south", "1", "2", "80"}},
+		},
+		{
+			name:    "aggregate without group by",
+			sql:     `SELECT COUNT(revenue), MIN(month), MAX(zip), COUNT(DISTINCT region) FROM sales`,
+			columns: []string{"COUNT(revenue)", "MIN(month)", "MAX(zip)", "COUNT(DISTINCT region)"},
+			rows:    [][]string{{"4", "2024-01-15", "09999", "3"}},
+		},
+		{
+			name:    "group by expression and ordinal",
+			sql:     `SELECT DATE_TRUNC('month', month) AS m, SUM(revenue) FROM sales GROUP BY 1 ORDER BY m`,
+			columns: []string{"m", "SUM(revenue)"},
+			rows:    [][]string{{"2024-01-01", "180.5"}, {"2024-02-01", "120"}, {"2024-03-01", "90.25"}},
+		},
+		{
+			name:    "group by alias",
+			sql:     `SELECT DATE_TRUNC('month', month) AS m, SUM(revenue) FROM sales GROUP BY m ORDER BY m`,
+			columns: []string{"m", "SUM(revenue)"},
+			rows:    [][]string{{"2024-01-01", "180.5"}, {"2024-02-01", "120"}, {"2024-03-01", "90.25"}},
+		},
+		{
+			name:    "inner join with aliases",
+			sql:     `SELECT s.month, r.manager FROM sales s JOIN regions r ON s.region = r.region WHERE r.active ORDER BY s.month`,
+			columns: []string{"month", "manager"},
+			rows:    [][]string{{"2024-01-15", "Ann"}, {"2024-02-03", "Ann"}, {"2024-03-01", "Ann"}},
+		},
+		{
+			name:    "left join using",
+			sql:     `SELECT region, manager FROM (SELECT DISTINCT region FROM sales) x LEFT JOIN regions USING (region) ORDER BY region`,
+			columns: []string{"region", "manager"},
+			rows:    [][]string{{"east", "NULL"}, {"north", "Ann"}, {"south", "Bob"}},
+		},
+		{
+			name:    "cte, union and limit",
+			sql:     `WITH r AS (SELECT region FROM regions) SELECT region FROM r UNION SELECT region FROM sales ORDER BY 1 LIMIT 3 OFFSET 1`,
+			columns: []string{"region"},
+			rows:    [][]string{{"north"}, {"south"}, {"west"}},
+		},
+		{
+			name:    "in subquery, between, like and case",
+			sql:     `SELECT zip, CASE WHEN units >= 3 THEN 'many' WHEN units IS NULL THEN 'unknown' ELSE 'few' END AS size FROM sales WHERE region IN (SELECT region FROM regions WHERE active) AND month BETWEEN '2024-01-01' AND '2024-02-28' AND zip LIKE '01%'`,
+			columns: []string{"zip", "size"},
+			rows:    [][]string{{"01234", "many"}, {"01234", "unknown"}},
+		},
+		{
+			name:    "scalar functions and parameters",
+			sql:     `SELECT UPPER(region) || '-' || EXTRACT(MONTH FROM month) AS k, COALESCE(revenue, 0) * 2 AS doubled, "zip"::int AS z FROM sales WHERE region = $1 AND units = $2`,
+			args:    []interface{}{"east", 1},
+			columns: []string{"k", "doubled", "z"},
+			rows:    [][]string{{"EAST-2", "0", "9999"}},
+		},
+		{
+			name:    "json lines with timestamps",
+			sql:     `SELECT id, YEAR(at), DATE(at) FROM events ORDER BY id DESC`,
+			columns: []string{"id", "YEAR(at)", "DATE(at)"},
+			rows:    [][]string{{"2", "2024", "2024-01-02"}, {"1", "2024", "2024-01-01"}},
+		},
+		{
+			name:    "star and scalar subquery",
+			sql:     `SELECT r.*, (SELECT COUNT(*) FROM sales) AS total FROM regions r WHERE manager IS NOT NULL ORDER BY region DESC`,
+			columns: []string{"region", "manager", "active", "total"},
+			rows:    [][]string{{"south", "Bob", "false", "5"}, {"north", "Ann", "true", "5"}},
+		},
+		{
+			name:    "nulls last and division",
+			sql:     `SELECT revenue / units FROM sales ORDER BY 1 NULLS LAST`,
+			columns: []string{"revenue / units"},
+			rows:    [][]string{{"22.5625"}, {"33.5"}, {"40"}, {"NULL"}, {"NULL"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := runQuery(d, tt.sql, tt.args...)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", columns, tt.columns)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
+
+// TestIntegerOverflow tests that integer arithmetic that would wrap around gives a DOUBLE instead
+func TestIntegerOverflow(t *testing.T) {
+	d := New(FromRows("big", []string{"n"}, [][]string{{"9223372036854775807"}, {"1"}}, "NULL"))
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`SELECT 9223372036854775807 + 1`, "9223372036854776000"},
+		{`SELECT -9223372036854775807 - 2`, "-9223372036854776000"},
+		{`SELECT 4294967296 * 4294967296`, "18446744073709552000"},
+		{`SELECT -(-9223372036854775807 - 1)`, "9223372036854776000"},
+		{`SELECT ABS(-9223372036854775807 - 1)`, "9223372036854776000"},
+		{`SELECT SUM(n) FROM big`, "9223372036854776000"},
+		{`SELECT 9223372036854775806 + 1`, "9223372036854775807"},
+		{`SELECT -9223372036854775807 - 1`, "-9223372036854775808"},
+		{`SELECT 3037000499 * 3037000499`, "9223372030926249001"},
+		{`SELECT -1 * 9223372036854775807`, "-9223372036854775807"},
+		{`SELECT SUM(n) FROM big WHERE n = 1`, "1"},
+	}
+	for _, tt := range tests {
+		_, rows, err := runQuery(d, tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if got := rows[0][0]; got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	d := testDB(t)
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`DELETE FROM sales`, "read-only"},
+		{`SELECT * FROM missing`, "table missing not found"},
+		{`SELECT nope FROM sales`, "nope"},
+		{`SELECT region FROM sales s JOIN regions r ON s.region = r.region`, "ambiguous"},
+		{`SELECT region, COUNT(*) FROM sales`, "GROUP BY"},
+		{`SELECT * FROM sales s RIGHT JOIN regions r ON s.region = r.region`, "RIGHT JOIN"},
+		{`SELECT (SELECT region FROM regions)`, "more than one row"},
+	}
+	for _, tt := range tests {
+		_, _, err := runQuery(d, tt.sql)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want it to contain %q", tt.sql, err, tt.want)
+		}
+	}
+
+	conn := sql.OpenDB(d.Connector())
+	defer conn.Close()
+	if _, err := conn.Exec(`UPDATE sales SET units = 1`); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Exec error = %v", err)
+	}
+}
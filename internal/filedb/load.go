@@ -0,0 +1,448 @@
+package filedb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats maps supported file extensions to formats; text files may also be gzip-compressed (.csv.gz)
+var formats = map[string]string{
+	".csv":     "csv",
+	".tsv":     "tsv",
+	".tab":     "tsv",
+	".json":    "json",
+	".jsonl":   "json",
+	".ndjson":  "json",
+	".parquet": "parquet",
+}
+
+func formatOf(name string) (string, bool) {
+	name = strings.ToLower(name)
+	name = strings.TrimSuffix(name, ".gz")
+	format, ok := formats[filepath.Ext(name)]
+	return format, ok
+}
+
+// loadFile reads a file into a table with inferred column types
+func loadFile(path string) (*Table, error) {
+	format, ok := formatOf(path)
+	if !ok {
+		return nil, fmt.Errorf("failed to load %s: unsupported file type (supported: .csv, .tsv, .json, .jsonl, .ndjson, optionally .gz, and .parquet)", path)
+	}
+	gzipped := strings.EqualFold(filepath.Ext(path), ".gz")
+	if format == "parquet" && gzipped {
+		return nil, fmt.Errorf("failed to load %s: Parquet files are compressed internally; decompress the file first", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if info.Size() > MaxFileBytes {
+		return nil, fmt.Errorf("failed to load %s: file is larger than %d MB", path, MaxFileBytes>>20)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if format == "parquet" {
+		columns, rows, err := readParquet(f, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("failed to load %s: no columns found", path)
+		}
+		return &Table{Path: path, Format: format, Columns: columns, Rows: rows}, nil
+	}
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var header []string
+	var records [][]*string
+	switch format {
+	case "json":
+		header, records, err = readJSON(r)
+	case "tsv":
+		header, records, err = readDelimited(bufio.NewReader(r), '\t')
+	default:
+		br := bufio.NewReader(r)
+		header, records, err = readDelimited(br, sniffDelimiter(br))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	if len(header) == 0 {
+		return nil, fmt.Errorf("failed to load %s: no columns found", path)
+	}
+
+	t := &Table{Path: path, Format: format}
+	t.Columns, t.Rows = infer(header, records)
+	return t, nil
+}
+
+// sniffDelimiter picks the delimiter of a .csv file from its first line: comma, semicolon, tab or pipe
+func sniffDelimiter(r *bufio.Reader) rune {
+	line, _ := r.Peek(64 << 10)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	best, bestCount := ',', 0
+	for _, delim := range []rune{',', ';', '\t', '|'} {
+		count, quoted := 0, false
+		for _, ch := range string(line) {
+			if ch == '"' {
+				quoted = !quoted
+			} else if ch == delim && !quoted {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = delim, count
+		}
+	}
+	return best
+}
+
+// readDelimited reads a CSV or TSV file whose first record is the header; empty cells are NULL
+func readDelimited(r io.Reader, delim rune) ([]string, [][]*string, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delim
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	header = columnNames(header)
+
+	var records [][]*string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		// Extra cells get generated column names; missing cells are NULL
+		for len(record) > len(header) {
+			header = append(header, fmt.Sprintf("column%d", len(header)+1))
+		}
+		row := make([]*string, len(header))
+		for i, cell := range record {
+			if cell != "" {
+				value := cell
+				row[i] = &value
+			}
+		}
+		records = append(records, row)
+	}
+	return header, records, nil
+}
+
+// columnNames fills in empty header names and makes duplicates unique
+func columnNames(header []string) []string {
+	used := map[string]bool{}
+	names := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		candidate := name
+		for n := 2; used[strings.ToLower(candidate)]; n++ {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[strings.ToLower(candidate)] = true
+		names[i] = candidate
+	}
+	return names
+}
+
+// readJSON reads a JSON array of objects, newline-delimited objects (JSON Lines), or an object holding
+// one array of objects (e.g. {"data": [...]}); columns appear in the order keys are first seen
+// Nested objects and arrays are kept as JSON text
+func readJSON(r io.Reader) ([]string, [][]*string, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objects [][]field
+	switch tok {
+	case json.Delim('['):
+		objects, err = readArray(dec)
+		if err != nil {
+			return nil, nil, err
+		}
+	case json.Delim('{'):
+		first, err := readObject(dec)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = [][]field{first}
+		if dec.More() {
+			// JSON Lines: more objects follow
+			for dec.More() {
+				if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+					return nil, nil, fmt.Errorf("expected one JSON object per line")
+				}
+				obj, err := readObject(dec)
+				if err != nil {
+					return nil, nil, err
+				}
+				objects = append(objects, obj)
+			}
+		} else if len(first) == 1 && strings.HasPrefix(first[0].raw, "[") {
+			inner := json.NewDecoder(strings.NewReader(first[0].raw))
+			inner.UseNumber()
+			inner.Token()
+			if objects, err = readArray(inner); err != nil {
+				return nil, nil, err
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("expected a JSON array or object")
+	}
+
+	var header []string
+	index := map[string]int{}
+	for _, obj := range objects {
+		for _, f := range obj {
+			if _, ok := index[f.key]; !ok {
+				index[f.key] = len(header)
+				header = append(header, f.key)
+			}
+		}
+	}
+	records := make([][]*string, len(objects))
+	for i, obj := range objects {
+		records[i] = make([]*string, len(header))
+		for _, f := range obj {
+			records[i][index[f.key]] = jsonCell(f.raw)
+		}
+	}
+	return header, records, nil
+}
+
+// field is a key of a JSON object with its raw value
+type field struct {
+	key string
+	raw string
+}
+
+// readArray reads the elements of an array whose [ was consumed; elements that are not objects
+// become rows with a single value column
+func readArray(dec *json.Decoder) ([][]field, error) {
+	var objects [][]field
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		trimmed := strings.TrimSpace(string(raw))
+		if !strings.HasPrefix(trimmed, "{") {
+			objects = append(objects, []field{{key: "value", raw: trimmed}})
+			continue
+		}
+		inner := json.NewDecoder(strings.NewReader(trimmed))
+		inner.UseNumber()
+		inner.Token()
+		obj, err := readObject(inner)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// readObject reads the fields of an object whose { was consumed, keeping their order
+func readObject(dec *json.Decoder) ([]field, error) {
+	var fields []field
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{key: key, raw: strings.TrimSpace(string(raw))})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// jsonCell converts a raw JSON value to cell text: strings are unquoted, null is NULL and
+// objects and arrays stay JSON
+func jsonCell(raw string) *string {
+	if raw == "null" || raw == "" {
+		return nil
+	}
+	value := raw
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if json.Unmarshal([]byte(raw), &s) == nil {
+			value = s
+		}
+	} else if strings.HasPrefix(raw, "{") || strings.HasPrefix(raw, "[") {
+		var compact bytes.Buffer
+		if json.Compact(&compact, []byte(raw)) == nil {
+			value = compact.String()
+		}
+	}
+	return &value
+}
+
+// infer picks the narrowest type holding every value of each column and converts the cells
+func infer(header []string, records [][]*string) ([]Column, [][]interface{}) {
+	columns := make([]Column, len(header))
+	for c, name := range header {
+		columns[c] = Column{Name: name, Type: inferType(records, c)}
+		for _, record := range records {
+			if c >= len(record) || record[c] == nil {
+				columns[c].Nullable = true
+				break
+			}
+		}
+	}
+
+	rows := make([][]interface{}, len(records))
+	for r, record := range records {
+		row := make([]interface{}, len(columns))
+		for c := range columns {
+			if c < len(record) && record[c] != nil {
+				row[c] = convert(*record[c], columns[c].Type)
+			}
+		}
+		rows[r] = row
+	}
+	return columns, rows
+}
+
+func inferType(records [][]*string, c int) string {
+	candidates := []string{TypeBigint, TypeDouble, TypeBoolean, TypeDate, TypeTimestamp}
+	seen := false
+	for _, record := range records {
+		if c >= len(record) || record[c] == nil {
+			continue
+		}
+		seen = true
+		value := strings.TrimSpace(*record[c])
+		kept := candidates[:0]
+		for _, typ := range candidates {
+			if matchesType(value, typ) {
+				kept = append(kept, typ)
+			}
+		}
+		candidates = kept
+		if len(candidates) == 0 {
+			return TypeVarchar
+		}
+	}
+	if !seen {
+		return TypeVarchar
+	}
+	return candidates[0]
+}
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+func matchesType(value, typ string) bool {
+	switch typ {
+	case TypeBigint:
+		// Leading zeros (zip codes, IDs) are kept as text
+		if len(value) > 1 && (value[0] == '0' || strings.HasPrefix(value, "-0") || value[0] == '+') {
+			return false
+		}
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case TypeDouble:
+		if !strings.ContainsAny(value, "0123456789") || (len(value) > 1 && value[0] == '0' && value[1] != '.') {
+			return false
+		}
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case TypeBoolean:
+		return strings.EqualFold(value, "true") || strings.EqualFold(value, "false")
+	case TypeDate:
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case TypeTimestamp:
+		for _, layout := range timestampLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func convert(value, typ string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	switch typ {
+	case TypeBigint:
+		n, _ := strconv.ParseInt(trimmed, 10, 64)
+		return n
+	case TypeDouble:
+		f, _ := strconv.ParseFloat(trimmed, 64)
+		return f
+	case TypeBoolean:
+		return strings.EqualFold(trimmed, "true")
+	case TypeDate, TypeTimestamp:
+		return trimmed
+	default:
+		return value
+	}
+}
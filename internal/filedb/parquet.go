@@ -0,0 +1,285 @@
+package filedb
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// julianUnixEpoch is the Julian day number of 1970-01-01, used by legacy INT96 timestamps
+const julianUnixEpoch = 2440588
+
+// readParquet reads a Parquet file; unlike text formats, column types come from the file's schema
+// Nested columns (lists, maps and groups) are kept as JSON text
+func readParquet(f *os.File, size int64) (columns []Column, rows [][]interface{}, err error) {
+	// The Parquet reader panics on some malformed files instead of returning an error
+	defer func() {
+		if r := recover(); r != nil {
+			columns, rows, err = nil, nil, fmt.Errorf("invalid Parquet file: %v", r)
+		}
+	}()
+
+	file, err := parquet.OpenFile(f, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := file.Schema().Fields()
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name()
+	}
+	names = columnNames(names)
+	columns = make([]Column, len(fields))
+	for i, field := range fields {
+		columns[i] = Column{Name: names[i], Type: parquetType(field)}
+	}
+
+	reader := parquet.NewReader(file, parquet.NewSchema(file.Schema().Name(), plainGroup(file.Schema())))
+	defer reader.Close()
+	rows = make([][]interface{}, 0, file.NumRows())
+	for {
+		values := map[string]interface{}{}
+		if err := reader.Read(&values); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		row := make([]interface{}, len(fields))
+		for i, field := range fields {
+			row[i] = parquetCell(parquetValue(field, values[field.Name()]))
+			if row[i] == nil {
+				columns[i].Nullable = true
+			}
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// plainGroup returns the fields of a group without MAP annotations, which the Parquet reader cannot
+// read into untyped maps; parquetGroup turns them back into maps using the file's schema
+func plainGroup(node parquet.Node) parquet.Group {
+	group := parquet.Group{}
+	for _, field := range node.Fields() {
+		group[field.Name()] = plainNode(field)
+	}
+	return group
+}
+
+func plainNode(node parquet.Node) parquet.Node {
+	if node.Leaf() {
+		return node
+	}
+	var plain parquet.Node = plainGroup(node)
+	switch {
+	case node.Repeated():
+		plain = parquet.Repeated(plain)
+	case node.Optional():
+		plain = parquet.Optional(plain)
+	}
+	return plain
+}
+
+// parquetType maps the type of a top-level Parquet field to a column type
+func parquetType(node parquet.Node) string {
+	if !node.Leaf() || node.Repeated() {
+		return TypeVarchar
+	}
+	if lt := node.Type().LogicalType(); lt != nil {
+		switch {
+		case lt.Date != nil:
+			return TypeDate
+		case lt.Timestamp != nil:
+			return TypeTimestamp
+		case lt.Decimal != nil:
+			return TypeDouble
+		case lt.Integer != nil:
+			if lt.Integer.BitWidth == 64 && !lt.Integer.IsSigned {
+				// UINT_64 values may not fit a BIGINT
+				return TypeDouble
+			}
+			return TypeBigint
+		}
+		return TypeVarchar
+	}
+	switch node.Type().Kind() {
+	case parquet.Boolean:
+		return TypeBoolean
+	case parquet.Int32, parquet.Int64:
+		return TypeBigint
+	case parquet.Float, parquet.Double:
+		return TypeDouble
+	case parquet.Int96:
+		return TypeTimestamp
+	}
+	return TypeVarchar
+}
+
+// parquetCell converts a value returned by parquetValue to a table value; nested values become JSON text
+func parquetCell(v interface{}) interface{} {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return v
+}
+
+// parquetValue converts a value read from a Parquet file to int64, float64, bool or string for leaf fields,
+// using the field's logical type, and to slices and maps of those for nested fields
+func parquetValue(node parquet.Node, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if values, ok := v.([]interface{}); ok && node.Repeated() {
+		converted := make([]interface{}, len(values))
+		for i, value := range values {
+			converted[i] = parquetValue(parquet.Required(node), value)
+		}
+		return converted
+	}
+	if !node.Leaf() {
+		return parquetGroup(node, v)
+	}
+
+	lt := node.Type().LogicalType()
+	switch {
+	case lt != nil && lt.Date != nil:
+		if days, ok := asInt64(v); ok {
+			return time.Unix(days*86400, 0).UTC().Format("2006-01-02")
+		}
+	case lt != nil && lt.Timestamp != nil:
+		if n, ok := asInt64(v); ok {
+			return unitTime(n, lt.Timestamp.Unit).Format("2006-01-02 15:04:05.999999999")
+		}
+	case lt != nil && lt.Time != nil:
+		if n, ok := asInt64(v); ok {
+			return unitTime(n, lt.Time.Unit).Format("15:04:05.999999999")
+		}
+	case lt != nil && lt.Decimal != nil:
+		return decimalValue(v, lt.Decimal)
+	}
+
+	switch x := v.(type) {
+	case int32:
+		return int64(x)
+	case int64:
+		if lt != nil && lt.Integer != nil && lt.Integer.BitWidth == 64 && !lt.Integer.IsSigned {
+			return float64(uint64(x))
+		}
+		return x
+	case uint32:
+		return int64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case float64, bool, string:
+		return x
+	case deprecated.Int96:
+		// Nanoseconds of the day in the low 64 bits, Julian day in the high 32 bits
+		nanos := int64(uint64(x[1])<<32 | uint64(x[0]))
+		days := int64(x[2]) - julianUnixEpoch
+		return time.Unix(days*86400, nanos).UTC().Format("2006-01-02 15:04:05.999999999")
+	case []byte:
+		if lt != nil && lt.UUID != nil && len(x) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", x[0:4], x[4:6], x[6:8], x[8:10], x[10:])
+		}
+		if utf8.Valid(x) {
+			return string(x)
+		}
+		return "0x" + hex.EncodeToString(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// parquetGroup converts a group value: lists become slices, maps become maps and other groups objects
+// Lists are recognized by their layout (a repeated group holding one element field), since files written by
+// some tools do not keep the LIST annotation
+func parquetGroup(node parquet.Node, v interface{}) interface{} {
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	children := node.Fields()
+	if len(children) == 1 && children[0].Repeated() && !children[0].Leaf() {
+		repeated := children[0]
+		entries, _ := fields[repeated.Name()].([]interface{})
+		inner := repeated.Fields()
+		lt := node.Type().LogicalType()
+		switch {
+		case len(inner) == 2 && lt != nil && lt.Map != nil:
+			// MAP: a repeated group of key and value fields
+			converted := make(map[string]interface{}, len(entries))
+			for _, entry := range entries {
+				if m, ok := entry.(map[string]interface{}); ok {
+					converted[fmt.Sprint(parquetValue(inner[0], m[inner[0].Name()]))] = parquetValue(inner[1], m[inner[1].Name()])
+				}
+			}
+			return converted
+		case len(inner) == 1:
+			list := make([]interface{}, 0, len(entries))
+			for _, entry := range entries {
+				if m, ok := entry.(map[string]interface{}); ok {
+					list = append(list, parquetValue(inner[0], m[inner[0].Name()]))
+				}
+			}
+			return list
+		}
+	}
+	converted := make(map[string]interface{}, len(children))
+	for _, child := range children {
+		converted[child.Name()] = parquetValue(child, fields[child.Name()])
+	}
+	return converted
+}
+
+// unitTime converts a count of the given time unit since the Unix epoch (or midnight) to a UTC time
+func unitTime(n int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(n).UTC()
+	case unit.Micros != nil:
+		return time.UnixMicro(n).UTC()
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// decimalValue converts a DECIMAL stored as an integer or big-endian two's complement bytes to a float
+func decimalValue(v interface{}, decimal *format.DecimalType) interface{} {
+	unscaled := new(big.Int)
+	if n, ok := asInt64(v); ok {
+		unscaled.SetInt64(n)
+	} else if b, ok := v.([]byte); ok {
+		unscaled.SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+	} else {
+		return fmt.Sprint(v)
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimal.Scale)), nil))).Float64()
+	return f
+}
+
+func asInt64(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	}
+	return 0, false
+}
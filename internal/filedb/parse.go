@@ -0,0 +1,1167 @@
+package filedb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// query is a parsed SELECT statement: one or more SELECT cores combined with UNION, with the
+// ORDER BY and LIMIT that apply to the combined rows
+type query struct {
+	with     []cte
+	cores    []*selectCore
+	unionAll []bool // unionAll[i] combines cores[i+1] with UNION ALL (else UNION)
+	orderBy  []orderItem
+	limit    int // -1 when absent
+	offset   int
+}
+
+type cte struct {
+	name  string
+	query *query
+}
+
+type selectCore struct {
+	distinct bool
+	items    []selectItem
+	from     []tableRef
+	where    expr
+	groupBy  []expr
+	having   expr
+}
+
+type selectItem struct {
+	expr      expr
+	alias     string
+	text      string // Source text, the column name when there is no alias
+	star      bool   // * or table.*
+	starTable string
+}
+
+// tableRef is a table in FROM; join is "" for the first table, else inner, left or cross
+type tableRef struct {
+	name     string
+	subquery *query
+	alias    string
+	join     string
+	on       expr
+}
+
+type orderItem struct {
+	expr       expr
+	desc       bool
+	nullsFirst *bool
+}
+
+// Expression nodes
+type (
+	expr interface{}
+
+	literal struct{ value interface{} }
+	param   struct{ index int }
+	colRef  struct {
+		table, name string
+		// Cached resolution (see scope.lookup)
+		scope *scope
+		index int
+	}
+	unary struct {
+		op      string // - or NOT
+		operand expr
+	}
+	binary struct {
+		op          string // OR AND = != < <= > >= + - * / % || LIKE ILIKE
+		left, right expr
+		not         bool // NOT LIKE
+	}
+	isNull struct {
+		operand expr
+		not     bool
+	}
+	inList struct {
+		operand expr
+		list    []expr
+		sub     *subquery
+		not     bool
+	}
+	between struct {
+		operand, low, high expr
+		not                bool
+	}
+	caseExpr struct {
+		operand expr
+		whens   []caseWhen
+		orElse  expr
+	}
+	cast struct {
+		operand expr
+		typ     string
+	}
+	call struct {
+		name     string // Upper case
+		args     []expr
+		star     bool // COUNT(*)
+		distinct bool
+	}
+	subquery struct {
+		query *query
+		// Uncorrelated: evaluated once (see executor.subqueryRows)
+		rows [][]interface{}
+		done bool
+	}
+)
+
+type caseWhen struct {
+	cond, result expr
+}
+
+// aggregates are the aggregate functions
+var aggregates = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true, "STRING_AGG": true, "GROUP_CONCAT": true,
+}
+
+// reserved words end an expression or table reference, so they are never taken as implicit aliases
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true, "OFFSET": true,
+	"UNION": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true, "OUTER": true,
+	"ON": true, "USING": true, "AS": true, "AND": true, "OR": true, "NOT": true, "BY": true, "ASC": true, "DESC": true,
+	"WITH": true, "IS": true, "IN": true, "BETWEEN": true, "LIKE": true, "ILIKE": true, "CASE": true, "WHEN": true,
+	"THEN": true, "ELSE": true, "END": true, "NULLS": true, "WINDOW": true, "NATURAL": true, "FETCH": true,
+	"INTERSECT": true, "EXCEPT": true, "OVER": true,
+}
+
+type parser struct {
+	src    []rune
+	toks   []sqlparse.Token
+	pos    int
+	params int
+}
+
+// parse parses a single SELECT statement (optionally with WITH), as written for PostgreSQL-like
+// dialects: identifiers are quoted with double quotes and strings with single quotes
+func parse(sql string) (*query, error) {
+	p := &parser{src: []rune(sql), toks: sqlparse.Tokenize(sql, sqlparse.DialectPostgres)}
+	for len(p.toks) > 0 && p.toks[len(p.toks)-1].Kind == sqlparse.TokenSemicolon {
+		p.toks = p.toks[:len(p.toks)-1]
+	}
+	if len(p.toks) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	first := p.toks[0]
+	if !first.IsWord("SELECT") && !first.IsWord("WITH") {
+		return nil, fmt.Errorf("file sources are read-only: only SELECT queries are supported, not %s", first.Upper)
+	}
+	q, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		if p.toks[p.pos].Kind == sqlparse.TokenSemicolon {
+			return nil, fmt.Errorf("only one statement can be run at a time")
+		}
+		return nil, p.errorf("unexpected %s", p.describe())
+	}
+	return q, nil
+}
+
+func (p *parser) peek() sqlparse.Token {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return sqlparse.Token{Kind: sqlparse.TokenSemicolon}
+}
+
+func (p *parser) peekAt(offset int) sqlparse.Token {
+	if p.pos+offset < len(p.toks) {
+		return p.toks[p.pos+offset]
+	}
+	return sqlparse.Token{Kind: sqlparse.TokenSemicolon}
+}
+
+func (p *parser) atEnd() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) word(keyword string) bool {
+	if p.peek().IsWord(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) symbol(symbol string) bool {
+	if p.peek().IsSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectWord(keyword string) error {
+	if !p.word(keyword) {
+		return p.errorf("expected %s, found %s", keyword, p.describe())
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.symbol(symbol) {
+		return p.errorf("expected %s, found %s", symbol, p.describe())
+	}
+	return nil
+}
+
+func (p *parser) describe() string {
+	if p.atEnd() {
+		return "end of query"
+	}
+	return "'" + p.peek().Text + "'"
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error: "+format, args...)
+}
+
+// adjacent reports whether the next two tokens touch (e.g. < followed by =)
+func (p *parser) adjacent() bool {
+	return p.pos+1 < len(p.toks) && p.toks[p.pos].End == p.toks[p.pos+1].Start
+}
+
+// identifier reads a plain or quoted identifier
+func (p *parser) identifier() (string, bool) {
+	tok := p.peek()
+	if tok.Kind == sqlparse.TokenQuotedIdent || (tok.Kind == sqlparse.TokenWord && !reserved[tok.Upper]) {
+		p.pos++
+		return tok.Text, true
+	}
+	return "", false
+}
+
+func (p *parser) query() (*query, error) {
+	q := &query{limit: -1}
+	if p.word("WITH") {
+		p.word("RECURSIVE")
+		for {
+			name, ok := p.identifier()
+			if !ok {
+				return nil, p.errorf("expected a name in WITH, found %s", p.describe())
+			}
+			if p.symbol("(") {
+				return nil, fmt.Errorf("column lists in WITH are not supported; alias the columns in the query instead")
+			}
+			if err := p.expectWord("AS"); err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			sub, err := p.query()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			q.with = append(q.with, cte{name: name, query: sub})
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	for {
+		core, err := p.selectCore()
+		if err != nil {
+			return nil, err
+		}
+		q.cores = append(q.cores, core)
+		if !p.word("UNION") {
+			break
+		}
+		all := p.word("ALL")
+		if !all {
+			p.word("DISTINCT")
+		}
+		q.unionAll = append(q.unionAll, all)
+	}
+	if p.peek().IsWord("INTERSECT") || p.peek().IsWord("EXCEPT") {
+		return nil, fmt.Errorf("%s is not supported; use a join or IN instead", p.peek().Upper)
+	}
+
+	if p.word("ORDER") {
+		if err := p.expectWord("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			item := orderItem{}
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item.expr = e
+			if p.word("DESC") {
+				item.desc = true
+			} else {
+				p.word("ASC")
+			}
+			if p.word("NULLS") {
+				first := p.word("FIRST")
+				if !first {
+					if err := p.expectWord("LAST"); err != nil {
+						return nil, err
+					}
+				}
+				item.nullsFirst = &first
+			}
+			q.orderBy = append(q.orderBy, item)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.word("LIMIT") {
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		q.limit = n
+		if p.symbol(",") {
+			// MySQL LIMIT offset, count
+			if q.limit, err = p.integer(); err != nil {
+				return nil, err
+			}
+			q.offset = n
+		}
+	}
+	if p.word("OFFSET") {
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		q.offset = n
+		p.word("ROWS")
+	}
+	if p.word("FETCH") {
+		if !p.word("FIRST") {
+			p.word("NEXT")
+		}
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		q.limit = n
+		if !p.word("ROWS") {
+			p.word("ROW")
+		}
+		p.word("ONLY")
+	}
+	return q, nil
+}
+
+func (p *parser) integer() (int, error) {
+	tok := p.peek()
+	n, err := strconv.Atoi(tok.Text)
+	if tok.Kind != sqlparse.TokenNumber || err != nil || n < 0 {
+		return 0, p.errorf("expected a number, found %s", p.describe())
+	}
+	p.pos++
+	return n, nil
+}
+
+func (p *parser) selectCore() (*selectCore, error) {
+	if err := p.expectWord("SELECT"); err != nil {
+		return nil, err
+	}
+	core := &selectCore{}
+	if p.word("DISTINCT") {
+		core.distinct = true
+	} else {
+		p.word("ALL")
+	}
+
+	for {
+		item, err := p.selectItem()
+		if err != nil {
+			return nil, err
+		}
+		core.items = append(core.items, item)
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if p.word("FROM") {
+		if err := p.from(core); err != nil {
+			return nil, err
+		}
+	}
+	if p.word("WHERE") {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		core.where = e
+	}
+	if p.word("GROUP") {
+		if err := p.expectWord("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			core.groupBy = append(core.groupBy, e)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.word("HAVING") {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		core.having = e
+	}
+	if p.peek().IsWord("WINDOW") {
+		return nil, fmt.Errorf("window functions are not supported on file sources")
+	}
+	return core, nil
+}
+
+func (p *parser) selectItem() (selectItem, error) {
+	if p.symbol("*") {
+		return selectItem{star: true}, nil
+	}
+	// table.*
+	if tok := p.peek(); (tok.Kind == sqlparse.TokenWord || tok.Kind == sqlparse.TokenQuotedIdent) &&
+		p.peekAt(1).IsSymbol(".") && p.peekAt(2).IsSymbol("*") {
+		p.pos += 3
+		return selectItem{star: true, starTable: tok.Text}, nil
+	}
+
+	start := p.pos
+	e, err := p.expr()
+	if err != nil {
+		return selectItem{}, err
+	}
+	item := selectItem{expr: e, text: p.text(start, p.pos)}
+	if ref, ok := e.(*colRef); ok {
+		item.text = ref.name
+	}
+	if p.word("AS") {
+		alias, ok := p.alias()
+		if !ok {
+			return selectItem{}, p.errorf("expected an alias after AS, found %s", p.describe())
+		}
+		item.alias = alias
+	} else if alias, ok := p.alias(); ok {
+		item.alias = alias
+	}
+	return item, nil
+}
+
+// alias reads an alias: an identifier or, as MySQL allows, a string
+func (p *parser) alias() (string, bool) {
+	if tok := p.peek(); tok.Kind == sqlparse.TokenString {
+		p.pos++
+		return tok.Text, true
+	}
+	return p.identifier()
+}
+
+// text returns the source text of tokens [from, to)
+func (p *parser) text(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	return string(p.src[p.toks[from].Start:p.toks[to-1].End])
+}
+
+func (p *parser) from(core *selectCore) error {
+	ref, err := p.tableRef()
+	if err != nil {
+		return err
+	}
+	core.from = append(core.from, ref)
+	for {
+		join := ""
+		switch {
+		case p.symbol(","):
+			join = "cross"
+		case p.word("JOIN"):
+			join = "inner"
+		case p.peek().IsWord("INNER") && p.peekAt(1).IsWord("JOIN"):
+			p.pos += 2
+			join = "inner"
+		case p.peek().IsWord("LEFT"):
+			p.pos++
+			p.word("OUTER")
+			if err := p.expectWord("JOIN"); err != nil {
+				return err
+			}
+			join = "left"
+		case p.peek().IsWord("CROSS"):
+			p.pos++
+			if err := p.expectWord("JOIN"); err != nil {
+				return err
+			}
+			join = "cross"
+		case p.peek().IsWord("RIGHT") || p.peek().IsWord("FULL"):
+			return fmt.Errorf("%s JOIN is not supported; swap the tables and use LEFT JOIN", p.peek().Upper)
+		case p.peek().IsWord("NATURAL"):
+			return fmt.Errorf("NATURAL JOIN is not supported; use JOIN ... ON")
+		default:
+			return nil
+		}
+
+		ref, err := p.tableRef()
+		if err != nil {
+			return err
+		}
+		ref.join = join
+		if join != "cross" {
+			if p.word("ON") {
+				if ref.on, err = p.expr(); err != nil {
+					return err
+				}
+			} else if p.word("USING") {
+				if ref.on, err = p.using(core.from, ref); err != nil {
+					return err
+				}
+			} else {
+				return p.errorf("expected ON or USING after JOIN %s", ref.label())
+			}
+		}
+		core.from = append(core.from, ref)
+	}
+}
+
+// using turns JOIN ... USING (a, b) into the equivalent ON condition
+func (p *parser) using(left []tableRef, right tableRef) (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var on expr
+	for {
+		name, ok := p.identifier()
+		if !ok {
+			return nil, p.errorf("expected a column in USING, found %s", p.describe())
+		}
+		// The column is taken from the nearest table on the left that has it (resolved at run time)
+		cond := &binary{op: "=", left: &colRef{name: name, table: usingLeft}, right: &colRef{table: right.label(), name: name}}
+		if on == nil {
+			on = cond
+		} else {
+			on = &binary{op: "AND", left: on, right: cond}
+		}
+		if !p.symbol(",") {
+			break
+		}
+	}
+	return on, p.expectSymbol(")")
+}
+
+// usingLeft marks the left column of a USING condition, which may come from any table joined so far
+const usingLeft = "\x00using"
+
+func (r tableRef) label() string {
+	if r.alias != "" {
+		return r.alias
+	}
+	return r.name
+}
+
+func (p *parser) tableRef() (tableRef, error) {
+	ref := tableRef{}
+	if p.symbol("(") {
+		sub, err := p.query()
+		if err != nil {
+			return ref, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return ref, err
+		}
+		ref.subquery = sub
+	} else {
+		name, ok := p.identifier()
+		if !ok {
+			return ref, p.errorf("expected a table name, found %s", p.describe())
+		}
+		// A schema qualifier (e.g. main.sales) is accepted and ignored: all tables are in one namespace
+		if p.symbol(".") {
+			if name, ok = p.identifier(); !ok {
+				return ref, p.errorf("expected a table name, found %s", p.describe())
+			}
+		}
+		ref.name = name
+	}
+	if p.word("AS") {
+		alias, ok := p.identifier()
+		if !ok {
+			return ref, p.errorf("expected an alias after AS, found %s", p.describe())
+		}
+		ref.alias = alias
+	} else if alias, ok := p.identifier(); ok {
+		ref.alias = alias
+	}
+	if ref.subquery != nil && ref.alias == "" {
+		ref.alias = "subquery"
+	}
+	return ref, nil
+}
+
+// Expressions, lowest precedence first
+
+func (p *parser) expr() (expr, error) {
+	return p.or()
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.word("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.word("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.word("NOT") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: "NOT", operand: operand}, nil
+	}
+	return p.comparison()
+}
+
+// comparisonOp reads a comparison operator, joining two-character operators split by the tokenizer
+func (p *parser) comparisonOp() string {
+	tok := p.peek()
+	if tok.Kind != sqlparse.TokenSymbol {
+		return ""
+	}
+	switch tok.Text {
+	case "=":
+		p.pos++
+		if p.peek().IsSymbol("=") {
+			p.pos++
+		}
+		return "="
+	case "<", ">", "!":
+		if p.adjacent() {
+			next := p.peekAt(1).Text
+			op := tok.Text + next
+			if op == "<=" || op == ">=" || op == "<>" || op == "!=" {
+				p.pos += 2
+				if op == "<>" {
+					op = "!="
+				}
+				return op
+			}
+		}
+		if tok.Text == "!" {
+			return ""
+		}
+		p.pos++
+		return tok.Text
+	}
+	return ""
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if op := p.comparisonOp(); op != "" {
+			right, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			left = &binary{op: op, left: left, right: right}
+			continue
+		}
+		if p.word("IS") {
+			not := p.word("NOT")
+			switch {
+			case p.word("NULL"):
+				left = &isNull{operand: left, not: not}
+			case p.word("TRUE"), p.word("FALSE"):
+				value := p.toks[p.pos-1].Upper == "TRUE"
+				var e expr = &binary{op: "=", left: left, right: &literal{value: value}}
+				if not {
+					e = &unary{op: "NOT", operand: e}
+				}
+				left = e
+			default:
+				return nil, p.errorf("expected NULL after IS, found %s", p.describe())
+			}
+			continue
+		}
+
+		not := false
+		if p.peek().IsWord("NOT") && (p.peekAt(1).IsWord("IN") || p.peekAt(1).IsWord("BETWEEN") || p.peekAt(1).IsWord("LIKE") || p.peekAt(1).IsWord("ILIKE")) {
+			p.pos++
+			not = true
+		}
+		switch {
+		case p.word("IN"):
+			in, err := p.in(left, not)
+			if err != nil {
+				return nil, err
+			}
+			left = in
+		case p.word("BETWEEN"):
+			low, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectWord("AND"); err != nil {
+				return nil, err
+			}
+			high, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			left = &between{operand: left, low: low, high: high, not: not}
+		case p.word("LIKE"), p.word("ILIKE"):
+			right, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			left = &binary{op: "LIKE", left: left, right: right, not: not}
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) in(operand expr, not bool) (expr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	in := &inList{operand: operand, not: not}
+	if p.peek().IsWord("SELECT") || p.peek().IsWord("WITH") {
+		sub, err := p.query()
+		if err != nil {
+			return nil, err
+		}
+		in.sub = &subquery{query: sub}
+	} else {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	return in, p.expectSymbol(")")
+}
+
+func (p *parser) concat() (expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol("|") && p.adjacent() && p.peekAt(1).IsSymbol("|") {
+		p.pos += 2
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol("+") || p.peek().IsSymbol("-") {
+		op := p.peek().Text
+		p.pos++
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol("*") || p.peek().IsSymbol("/") || p.peek().IsSymbol("%") {
+		op := p.peek().Text
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.symbol("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := operand.(*literal); ok {
+			switch v := lit.value.(type) {
+			case int64:
+				return &literal{value: -v}, nil
+			case float64:
+				return &literal{value: -v}, nil
+			}
+		}
+		return &unary{op: "-", operand: operand}, nil
+	}
+	if p.symbol("+") {
+		return p.unary()
+	}
+	return p.postfix()
+}
+
+// postfix handles PostgreSQL casts (expr::type)
+func (p *parser) postfix() (expr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().IsSymbol(":") && p.adjacent() && p.peekAt(1).IsSymbol(":") {
+		p.pos += 2
+		typ, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		e = &cast{operand: e, typ: typ}
+	}
+	return e, nil
+}
+
+// typeName reads a type name, skipping length and precision (e.g. DECIMAL(10, 2), DOUBLE PRECISION)
+func (p *parser) typeName() (string, error) {
+	tok := p.peek()
+	if tok.Kind != sqlparse.TokenWord {
+		return "", p.errorf("expected a type name, found %s", p.describe())
+	}
+	p.pos++
+	typ := tok.Upper
+	if typ == "DOUBLE" {
+		p.word("PRECISION")
+	}
+	if typ == "CHARACTER" {
+		p.word("VARYING")
+	}
+	if p.symbol("(") {
+		for !p.atEnd() && !p.symbol(")") {
+			p.pos++
+		}
+	}
+	return typ, nil
+}
+
+func (p *parser) primary() (expr, error) {
+	tok := p.peek()
+	switch tok.Kind {
+	case sqlparse.TokenNumber:
+		p.pos++
+		if n, err := strconv.ParseInt(tok.Text, 10, 64); err == nil {
+			return &literal{value: n}, nil
+		}
+		f, err := strconv.ParseFloat(tok.Text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.Text)
+		}
+		return &literal{value: f}, nil
+	case sqlparse.TokenString:
+		p.pos++
+		return &literal{value: tok.Text}, nil
+	case sqlparse.TokenQuotedIdent:
+		return p.columnRef()
+	case sqlparse.TokenSymbol:
+		switch tok.Text {
+		case "(":
+			p.pos++
+			if p.peek().IsWord("SELECT") || p.peek().IsWord("WITH") {
+				sub, err := p.query()
+				if err != nil {
+					return nil, err
+				}
+				return &subquery{query: sub}, p.expectSymbol(")")
+			}
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectSymbol(")")
+		case "?":
+			p.pos++
+			p.params++
+			return &param{index: p.params - 1}, nil
+		case "$":
+			// PostgreSQL positional parameter $n
+			if p.adjacent() && p.peekAt(1).Kind == sqlparse.TokenNumber {
+				n, err := strconv.Atoi(p.peekAt(1).Text)
+				if err == nil && n > 0 {
+					p.pos += 2
+					return &param{index: n - 1}, nil
+				}
+			}
+		}
+		return nil, p.errorf("unexpected %s", p.describe())
+	case sqlparse.TokenWord:
+		switch tok.Upper {
+		case "NULL":
+			p.pos++
+			return &literal{}, nil
+		case "TRUE", "FALSE":
+			p.pos++
+			return &literal{value: tok.Upper == "TRUE"}, nil
+		case "CASE":
+			p.pos++
+			return p.caseExpr()
+		case "CAST", "TRY_CAST":
+			if p.peekAt(1).IsSymbol("(") {
+				p.pos += 2
+				operand, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectWord("AS"); err != nil {
+					return nil, err
+				}
+				typ, err := p.typeName()
+				if err != nil {
+					return nil, err
+				}
+				return &cast{operand: operand, typ: typ}, p.expectSymbol(")")
+			}
+		case "DATE", "TIMESTAMP":
+			// Typed literals: DATE '2024-01-31'
+			if p.peekAt(1).Kind == sqlparse.TokenString {
+				p.pos += 2
+				return &literal{value: p.toks[p.pos-1].Text}, nil
+			}
+		case "EXTRACT":
+			if p.peekAt(1).IsSymbol("(") {
+				p.pos += 2
+				part := p.peek()
+				if part.Kind != sqlparse.TokenWord {
+					return nil, p.errorf("expected a date part in EXTRACT, found %s", p.describe())
+				}
+				p.pos++
+				if err := p.expectWord("FROM"); err != nil {
+					return nil, err
+				}
+				operand, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				return &call{name: "EXTRACT", args: []expr{&literal{value: part.Upper}, operand}}, p.expectSymbol(")")
+			}
+		case "CURRENT_DATE", "CURRENT_TIMESTAMP":
+			if !p.peekAt(1).IsSymbol("(") {
+				p.pos++
+				return &call{name: tok.Upper}, nil
+			}
+		case "EXISTS":
+			return nil, fmt.Errorf("EXISTS is not supported on file sources; use IN (SELECT ...) or a join")
+		case "INTERVAL":
+			return nil, fmt.Errorf("INTERVAL is not supported on file sources; compare dates as 'YYYY-MM-DD' text")
+		}
+		// LEFT and RIGHT are also string functions
+		if p.peekAt(1).IsSymbol("(") && (!reserved[tok.Upper] || tok.Upper == "LEFT" || tok.Upper == "RIGHT") {
+			return p.call()
+		}
+		if reserved[tok.Upper] {
+			return nil, p.errorf("unexpected %s", tok.Upper)
+		}
+		return p.columnRef()
+	}
+	return nil, p.errorf("unexpected %s", p.describe())
+}
+
+func (p *parser) columnRef() (expr, error) {
+	name, ok := p.identifier()
+	if !ok {
+		return nil, p.errorf("expected a column, found %s", p.describe())
+	}
+	if p.peek().IsSymbol(".") && !p.peekAt(1).IsSymbol("*") {
+		p.pos++
+		column, ok := p.identifier()
+		if !ok {
+			return nil, p.errorf("expected a column after %s., found %s", name, p.describe())
+		}
+		return &colRef{table: name, name: column}, nil
+	}
+	return &colRef{name: name}, nil
+}
+
+func (p *parser) call() (expr, error) {
+	c := &call{name: p.peek().Upper}
+	p.pos += 2
+	if p.symbol("*") {
+		c.star = true
+	} else if !p.peek().IsSymbol(")") {
+		c.distinct = p.word("DISTINCT")
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+			// GROUP_CONCAT(x SEPARATOR ', ')
+			if c.name == "GROUP_CONCAT" && p.word("SEPARATOR") {
+				sep, err := p.primary()
+				if err != nil {
+					return nil, err
+				}
+				c.args = append(c.args, sep)
+			}
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.word("ORDER") {
+		return nil, fmt.Errorf("ORDER BY inside %s() is not supported on file sources", c.name)
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if p.peek().IsWord("OVER") {
+		return nil, fmt.Errorf("window functions (OVER) are not supported on file sources")
+	}
+	if p.peek().IsWord("FILTER") {
+		return nil, fmt.Errorf("aggregate FILTER is not supported on file sources; use CASE WHEN inside the aggregate")
+	}
+	return c, nil
+}
+
+func (p *parser) caseExpr() (expr, error) {
+	c := &caseExpr{}
+	if !p.peek().IsWord("WHEN") {
+		operand, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.operand = operand
+	}
+	for p.word("WHEN") {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectWord("THEN"); err != nil {
+			return nil, err
+		}
+		result, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.whens = append(c.whens, caseWhen{cond: cond, result: result})
+	}
+	if len(c.whens) == 0 {
+		return nil, p.errorf("expected WHEN in CASE, found %s", p.describe())
+	}
+	if p.word("ELSE") {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.orElse = e
+	}
+	return c, p.expectWord("END")
+}
+
+// hasAggregate reports whether an expression contains an aggregate call (outside subqueries)
+func hasAggregate(e expr) bool {
+	found := false
+	walk(e, func(e expr) {
+		if c, ok := e.(*call); ok && aggregates[c.name] {
+			found = true
+		}
+	})
+	return found
+}
+
+// walk visits an expression tree, not descending into subqueries
+func walk(e expr, visit func(expr)) {
+	if e == nil {
+		return
+	}
+	visit(e)
+	switch n := e.(type) {
+	case *unary:
+		walk(n.operand, visit)
+	case *binary:
+		walk(n.left, visit)
+		walk(n.right, visit)
+	case *isNull:
+		walk(n.operand, visit)
+	case *inList:
+		walk(n.operand, visit)
+		for _, item := range n.list {
+			walk(item, visit)
+		}
+	case *between:
+		walk(n.operand, visit)
+		walk(n.low, visit)
+		walk(n.high, visit)
+	case *caseExpr:
+		walk(n.operand, visit)
+		for _, w := range n.whens {
+			walk(w.cond, visit)
+			walk(w.result, visit)
+		}
+		walk(n.orElse, visit)
+	case *cast:
+		walk(n.operand, visit)
+	case *call:
+		for _, arg := range n.args {
+			walk(arg, visit)
+		}
+	}
+}
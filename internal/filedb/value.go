@@ -0,0 +1,258 @@
+package filedb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// number converts a value to int64 or float64; text is parsed, booleans are 1 and 0
+func number(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case int64, float64:
+		return x, true
+	case bool:
+		if x {
+			return int64(1), true
+		}
+		return int64(0), true
+	case string:
+		s := strings.TrimSpace(x)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s, "0123456789") {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	n, ok := number(v)
+	if !ok {
+		return 0, false
+	}
+	if i, isInt := n.(int64); isInt {
+		return float64(i), true
+	}
+	return n.(float64), true
+}
+
+// text converts a non-NULL value to its text form
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		if x {
+			return "true"
+		}
+		return "false"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// compare orders two non-NULL values: numerically when both are numbers (or text holding one
+// compared to a number), otherwise as text
+func compare(a, b interface{}) int {
+	_, aText := a.(string)
+	_, bText := b.(string)
+	if !aText || !bText {
+		if x, ok := toFloat(a); ok {
+			if y, ok := toFloat(b); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+	return strings.Compare(text(a), text(b))
+}
+
+// sortCompare orders values for ORDER BY, MIN and MAX; NULL sorts first
+func sortCompare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compare(a, b)
+}
+
+// truth evaluates a value as a condition; NULL is neither true nor false
+func truth(v interface{}) (value bool, null bool) {
+	switch x := v.(type) {
+	case nil:
+		return false, true
+	case bool:
+		return x, false
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0, false
+	}
+	return false, false
+}
+
+// key encodes a value for grouping, DISTINCT and hash joins: equal values (1 and 1.0) get equal keys
+func key(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "\x00"
+	case string:
+		return "s" + x
+	case bool:
+		if x {
+			return "n1"
+		}
+		return "n0"
+	}
+	f, _ := toFloat(v)
+	return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// rowKey encodes a list of values (see key)
+func rowKey(values []interface{}) string {
+	var b strings.Builder
+	for _, v := range values {
+		b.WriteString(key(v))
+		b.WriteByte(0x01)
+	}
+	return b.String()
+}
+
+// arith applies an arithmetic operator; NULL operands and division by zero give NULL
+// Integer results that would overflow BIGINT are computed as DOUBLE instead of wrapping around
+func arith(op string, a, b interface{}) (interface{}, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	x, ok := number(a)
+	if !ok {
+		return nil, fmt.Errorf("cannot use '%s' in arithmetic: not a number", text(a))
+	}
+	y, ok := number(b)
+	if !ok {
+		return nil, fmt.Errorf("cannot use '%s' in arithmetic: not a number", text(b))
+	}
+	xi, xInt := x.(int64)
+	yi, yInt := y.(int64)
+	if xInt && yInt && op != "/" {
+		switch op {
+		case "+":
+			if sum, ok := addInt(xi, yi); ok {
+				return sum, nil
+			}
+		case "-":
+			if diff, ok := subInt(xi, yi); ok {
+				return diff, nil
+			}
+		case "*":
+			if product, ok := mulInt(xi, yi); ok {
+				return product, nil
+			}
+		case "%":
+			if yi == 0 {
+				return nil, nil
+			}
+			return xi % yi, nil
+		}
+	}
+	xf, _ := toFloat(x)
+	yf, _ := toFloat(y)
+	switch op {
+	case "+":
+		return xf + yf, nil
+	case "-":
+		return xf - yf, nil
+	case "*":
+		return xf * yf, nil
+	case "/":
+		if yf == 0 {
+			return nil, nil
+		}
+		return xf / yf, nil
+	case "%":
+		if yf == 0 {
+			return nil, nil
+		}
+		return math.Mod(xf, yf), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+// addInt returns x + y and whether it fits in an int64
+func addInt(x, y int64) (int64, bool) {
+	sum := x + y
+	return sum, (sum > x) == (y > 0)
+}
+
+// subInt returns x - y and whether it fits in an int64
+func subInt(x, y int64) (int64, bool) {
+	diff := x - y
+	return diff, (diff < x) == (y > 0)
+}
+
+// mulInt returns x * y and whether it fits in an int64
+func mulInt(x, y int64) (int64, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	product := x * y
+	if (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return product, false
+	}
+	return product, product/y == x
+}
+
+// like matches SQL LIKE patterns (% and _), case-insensitively
+func like(s, pattern string) bool {
+	return likeMatch([]rune(strings.ToLower(s)), []rune(strings.ToLower(pattern)))
+}
+
+func likeMatch(s, p []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '%':
+			for len(p) > 0 && p[0] == '%' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if likeMatch(s[i:], p) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(p) > 1 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != p[0] {
+				return false
+			}
+		}
+		s, p = s[1:], p[1:]
+	}
+	return len(s) == 0
+}
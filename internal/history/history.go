@@ -0,0 +1,262 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// DefaultMaxEntries is the number of entries kept per source
+const DefaultMaxEntries = 1000
+
+// freeModeName names the history file used without a source
+const freeModeName = "_free"
+
+// compactLines is the least number of lines in a history file before it is compacted
+const compactLines = 100
+
+// History is the input history of the chat prompt for one source, stored as JSON lines so that
+// multi-line entries stay a single item
+// Entries are unique: adding an entry again moves it to the end
+//
+// Sessions on the same source share the file: Add appends one line under a file lock, and the file is only
+// rewritten, merged with what other sessions appended, once it holds twice as many lines as entries kept
+type History struct {
+	path        string
+	maxEntries  int
+	keepSecrets bool
+
+	mu      sync.Mutex
+	entries []string // Oldest first
+	lines   int      // Lines in the file when it was last read or written, plus those appended since
+}
+
+// Load reads a history file; a missing file is an empty history
+// Unreadable lines are skipped, and duplicates and entries over maxEntries are dropped
+func Load(path string, maxEntries int, keepSecrets bool) (*History, error) {
+	h := &History{path: path, maxEntries: maxEntries, keepSecrets: keepSecrets}
+	if err := h.read(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// read replaces the entries with those of the history file
+func (h *History) read() error {
+	h.entries, h.lines = nil, 0
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		h.lines++
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		h.push(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	return nil
+}
+
+// ForSource loads the history of a source (empty name for free mode) using the history section of config.yaml
+// It returns nil when history is disabled
+func ForSource(name string) (*History, error) {
+	maxEntries, keepSecrets := DefaultMaxEntries, false
+	if cfg, err := config.Load(); err == nil {
+		if cfg.History.Disabled {
+			return nil, nil
+		}
+		if cfg.History.MaxEntries > 0 {
+			maxEntries = cfg.History.MaxEntries
+		}
+		keepSecrets = cfg.History.KeepSecrets
+	}
+	dir, err := config.GetHistoryDir()
+	if err != nil {
+		return nil, err
+	}
+	return Load(filepath.Join(dir, fileName(name)), maxEntries, keepSecrets)
+}
+
+// fileName maps a source name to a history file name, replacing characters unsafe in file names
+func fileName(source string) string {
+	if source == "" {
+		return freeModeName + ".jsonl"
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, source)
+	return safe + ".jsonl"
+}
+
+// MaxEntries returns the number of entries kept
+func (h *History) MaxEntries() int {
+	return h.maxEntries
+}
+
+// Entries returns the entries, oldest first
+func (h *History) Entries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.entries...)
+}
+
+// Add appends an entry and saves the history
+// Blank entries and, unless keepSecrets is set, entries that look like they contain secrets are not saved;
+// the result reports whether the entry was added
+func (h *History) Add(entry string) (bool, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || (!h.keepSecrets && LooksSecret(entry)) {
+		return false, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.push(entry)
+	return true, h.append(entry)
+}
+
+// push appends an entry, removing an earlier copy and the oldest entries over the limit
+func (h *History) push(entry string) {
+	for i, e := range h.entries {
+		if e == entry {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, entry)
+	if h.maxEntries > 0 && len(h.entries) > h.maxEntries {
+		h.entries = h.entries[len(h.entries)-h.maxEntries:]
+	}
+}
+
+// append adds an entry to the end of the history file, compacting the file once it has grown too large
+// Entries may hold queries on sensitive data, so only the user can read the file
+func (h *History) append(entry string) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	// The lock is a separate file because compaction replaces the history file
+	lock, err := os.OpenFile(h.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history lock file: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock history: %w", err)
+	}
+	defer unlockFile(lock)
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	h.lines++
+	if h.lines > compactLines && h.lines > 2*len(h.entries) {
+		return h.compact()
+	}
+	return nil
+}
+
+// compact rewrites the history file with one line per entry kept
+// The file is read again first, so entries other sessions appended are merged in; the caller holds the lock
+func (h *History) compact() error {
+	if err := h.read(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, entry := range h.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+// secretPatterns match credentials typed into the prompt: assignments to password-like names,
+// SQL that sets passwords and well-known token formats
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key)\s*[:=]\s*\S`),
+	regexp.MustCompile(`(?i)\bidentified\s+(by|with)\b`),
+	regexp.MustCompile(`(?i)\bpassword\s*(\(\s*)?'`),
+	regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9._~+/=-]{16,}`),
+	regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`),                            // Credentials in a URL
+	regexp.MustCompile(`\b(sk|pk|rk)-[A-Za-z0-9_-]{20,}`),                                          // API keys (OpenAI style)
+	regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),                                              // AWS access key IDs
+	regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr|github_pat|glpat|xox[abpr])[-_][A-Za-z0-9_-]{10,}`), // GitHub, GitLab and Slack tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.`),                           // JWTs
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+}
+
+// LooksSecret reports whether an entry looks like it contains a password, key or token
+func LooksSecret(entry string) bool {
+	for _, pattern := range secretPatterns {
+		if pattern.MatchString(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recall returns an entry as it is offered when browsing history at the prompt, which holds a single line
+// The lines of a multi-line entry are joined with spaces unless a line comment would then swallow the rest
+func Recall(entry string) string {
+	lines := strings.Split(entry, "\n")
+	if len(lines) == 1 {
+		return entry
+	}
+	for _, line := range lines[:len(lines)-1] {
+		if strings.Contains(line, "--") || strings.Contains(line, "#") {
+			return entry
+		}
+	}
+	parts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package history
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking; writers are only serialized within the process
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locking
+func unlockFile(f *os.File) error {
+	return nil
+}
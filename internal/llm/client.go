@@ -136,12 +136,20 @@ type ChatResponse struct {
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"` // "stop", "tool_calls", etc.
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"` // Token counts, when the provider reports them
 	Error *struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 	} `json:"error,omitempty"`
 }
 
+// Usage is the token accounting of a chat API response
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
 // ChatResponseType indicates the type of response from LLM
 type ChatResponseType int
 
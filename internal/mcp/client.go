@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aiq/aiq/internal/version"
+)
+
+// maxToolPages bounds tools/list pagination in case a server keeps returning cursors
+const maxToolPages = 100
+
+// Client is a session with one MCP server
+type Client struct {
+	t      transport
+	nextID atomic.Int64
+	info   initializeResult
+}
+
+// answer builds the client's reply to a request sent by the server
+// Only ping is supported; the client declares no other capabilities
+func answer(req *message) *message {
+	reply := &message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+	return reply
+}
+
+// request sends a request and decodes its result into result
+func (c *Client) request(ctx context.Context, method string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	resp, err := c.t.call(ctx, &message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: data})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s failed: %w", method, resp.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// initialize performs the MCP handshake
+func (c *Client) initialize(ctx context.Context) error {
+	params := initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "aiq", Version: version.Version},
+	}
+	if err := c.request(ctx, "initialize", params, &c.info); err != nil {
+		return err
+	}
+	return c.t.notify(ctx, &message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// ServerInfo returns the name and version the server reported
+func (c *Client) ServerInfo() Implementation {
+	return c.info.ServerInfo
+}
+
+// ListTools returns all tools offered by the server
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < maxToolPages; page++ {
+		var result listToolsResult
+		if err := c.request(ctx, "tools/list", listToolsParams{Cursor: cursor}, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// CallTool invokes a tool; tool-level failures are reported in the result (IsError), not as an error
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result CallToolResult
+	if err := c.request(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close ends the session and stops a stdio server
+func (c *Client) Close() error {
+	return c.t.close()
+}
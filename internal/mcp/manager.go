@@ -0,0 +1,360 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/secrets"
+)
+
+const (
+	// connectTimeout bounds launching a server, the handshake and listing its tools
+	connectTimeout = 30 * time.Second
+	// defaultCallTimeout is the per-call timeout when the server config does not set one
+	defaultCallTimeout = 60 * time.Second
+	// maxResultChars is the size after which tool output returned to the LLM is truncated
+	maxResultChars = 100000
+	// nameSeparator joins the server and tool names in the function name shown to the LLM
+	nameSeparator = "__"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Server is a configured MCP server and, once connected, its tools
+type Server struct {
+	Name    string
+	Config  config.MCPServerConfig
+	Info    Implementation // Reported by the server during initialization
+	Tools   []*Binding
+	Err     error    // Why the server could not be connected
+	Skipped []string // Tools that could not be offered, with the reason
+
+	client *Client
+}
+
+// Risk returns the risk level configured for the server's tools (low or high)
+func (s *Server) Risk() string {
+	if strings.EqualFold(s.Config.Risk, "low") {
+		return "low"
+	}
+	return "high"
+}
+
+// Binding ties the function name offered to the LLM to a tool of a server
+type Binding struct {
+	Name   string // Function name offered to the LLM (<server>__<tool>)
+	Server *Server
+	Tool   Tool
+}
+
+// LowRisk reports whether calls can run without confirmation according to the server config
+func (b *Binding) LowRisk() bool {
+	return b.Server.Risk() == "low" || (b.Server.Config.TrustReadOnlyHint && b.Tool.ReadOnly())
+}
+
+// Definition returns the LLM function definition for the tool
+func (b *Binding) Definition() map[string]interface{} {
+	description := b.Tool.Description
+	if description == "" {
+		description = b.Tool.Title
+	}
+	schema := b.Tool.InputSchema
+	if schema == nil {
+		schema = map[string]interface{}{}
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        b.Name,
+			"description": fmt.Sprintf("[MCP server %s] %s", b.Server.Name, description),
+			"parameters":  schema,
+		},
+	}
+}
+
+// Manager holds the connected MCP servers
+type Manager struct {
+	servers  []*Server
+	bindings map[string]*Binding
+}
+
+// functionName builds the function name for a tool; LLM APIs accept at most 64 letters, digits, '_' and '-'
+func functionName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server, "_") + nameSeparator + invalidNameChars.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// Connect launches or contacts every enabled server in cfg concurrently
+// Servers that fail are kept with Err set so callers can report them
+func Connect(ctx context.Context, cfg config.MCPConfig) *Manager {
+	names := make([]string, 0, len(cfg.Servers))
+	for name, server := range cfg.Servers {
+		if !server.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	m := &Manager{bindings: make(map[string]*Binding)}
+	var wg sync.WaitGroup
+	for _, name := range names {
+		s := &Server{Name: name, Config: cfg.Servers[name]}
+		m.servers = append(m.servers, s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Err = s.connect(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range m.servers {
+		tools := s.Tools[:0]
+		for _, b := range s.Tools {
+			if existing, ok := m.bindings[b.Name]; ok {
+				s.Skipped = append(s.Skipped, fmt.Sprintf("tool %s has the same function name as %s from server %s", b.Tool.Name, existing.Tool.Name, existing.Server.Name))
+				continue
+			}
+			m.bindings[b.Name] = b
+			tools = append(tools, b)
+		}
+		s.Tools = tools
+	}
+	return m
+}
+
+func (s *Server) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	var t transport
+	switch {
+	case s.Config.Command != "" && s.Config.URL != "":
+		return fmt.Errorf("set either command or url, not both")
+	case s.Config.Command != "":
+		env := os.Environ()
+		for name, value := range s.Config.Env {
+			expanded, _, err := secrets.Expand(value)
+			if err != nil {
+				return fmt.Errorf("failed to expand env %s: %w", name, err)
+			}
+			env = append(env, name+"="+expanded)
+		}
+		stdio, err := startStdio(s.Config.Command, s.Config.Args, env, s.Config.Cwd)
+		if err != nil {
+			return err
+		}
+		t = stdio
+	case s.Config.URL != "":
+		headers := make(map[string]string, len(s.Config.Headers))
+		for name, value := range s.Config.Headers {
+			expanded, _, err := secrets.Expand(value)
+			if err != nil {
+				return fmt.Errorf("failed to expand header %s: %w", name, err)
+			}
+			headers[name] = expanded
+		}
+		t = newHTTPTransport(s.Config.URL, headers)
+	default:
+		return fmt.Errorf("command or url is required")
+	}
+
+	client := &Client{t: t}
+	if err := client.initialize(ctx); err != nil {
+		client.Close()
+		return err
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	s.client = client
+	s.Info = client.ServerInfo()
+
+	allowed := make(map[string]bool, len(s.Config.Tools))
+	for _, name := range s.Config.Tools {
+		allowed[name] = true
+	}
+	for _, tool := range tools {
+		if len(allowed) > 0 && !allowed[tool.Name] {
+			continue
+		}
+		s.Tools = append(s.Tools, &Binding{Name: functionName(s.Name, tool.Name), Server: s, Tool: tool})
+	}
+	return nil
+}
+
+// Servers returns the configured servers, sorted by name
+func (m *Manager) Servers() []*Server {
+	return m.servers
+}
+
+// Lookup returns the binding for a function name, or nil
+func (m *Manager) Lookup(name string) *Binding {
+	return m.bindings[name]
+}
+
+// Call invokes a tool and converts the result for the LLM
+// Tool-level failures (isError) are returned as a result with status "error"
+func (m *Manager) Call(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	b := m.Lookup(name)
+	if b == nil {
+		return nil, fmt.Errorf("unknown MCP tool: %s", name)
+	}
+	timeout := defaultCallTimeout
+	if b.Server.Config.Timeout > 0 {
+		timeout = time.Duration(b.Server.Config.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Hints for the confirmation flow are not part of the tool's schema
+	callArgs := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k == "risk_level" || k == "task_type" || k == "output_mode" {
+			if _, declared := schemaProperty(b.Tool.InputSchema, k); !declared {
+				continue
+			}
+		}
+		callArgs[k] = v
+	}
+
+	result, err := b.Server.client.CallTool(ctx, b.Tool.Name, callArgs)
+	if err != nil {
+		return nil, fmt.Errorf("MCP server %s: %w", b.Server.Name, err)
+	}
+	return formatResult(b.Server.Name, result), nil
+}
+
+func schemaProperty(schema map[string]interface{}, name string) (interface{}, bool) {
+	props, _ := schema["properties"].(map[string]interface{})
+	prop, ok := props[name]
+	return prop, ok
+}
+
+// formatResult flattens MCP content into text for the LLM
+func formatResult(server string, result *CallToolResult) map[string]interface{} {
+	var parts []string
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "resource":
+			if c.Resource != nil && c.Resource.Text != "" {
+				parts = append(parts, c.Resource.Text)
+			} else if c.Resource != nil {
+				parts = append(parts, fmt.Sprintf("[resource %s]", c.Resource.URI))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource link %s %s]", c.Name, c.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content %s omitted]", c.Type, c.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+
+	output := map[string]interface{}{"status": "success", "server": server}
+	if len(text) > maxResultChars {
+		text = text[:maxResultChars]
+		output["truncated"] = true
+	}
+	if result.IsError {
+		output["status"] = "error"
+		output["error"] = text
+	} else {
+		output["content"] = text
+	}
+	if result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil && len(data) <= maxResultChars {
+			output["structured_content"] = result.StructuredContent
+		}
+	}
+	return output
+}
+
+// Close ends all server sessions
+func (m *Manager) Close() {
+	var wg sync.WaitGroup
+	for _, s := range m.servers {
+		if s.client == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			c.Close()
+		}(s.client)
+	}
+	wg.Wait()
+}
+
+var (
+	current   *Manager
+	currentMu sync.Mutex
+)
+
+// Start connects the configured servers and makes them the active set, closing any previous set
+func Start(ctx context.Context, cfg config.MCPConfig) *Manager {
+	m := Connect(ctx, cfg)
+	currentMu.Lock()
+	previous := current
+	current = m
+	currentMu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return m
+}
+
+// Stop closes the active servers
+func Stop() {
+	currentMu.Lock()
+	m := current
+	current = nil
+	currentMu.Unlock()
+	if m != nil {
+		m.Close()
+	}
+}
+
+// Current returns the active manager, or nil when no servers were started
+func Current() *Manager {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	return current
+}
+
+// Lookup returns the binding for a function name in the active manager, or nil
+func Lookup(name string) *Binding {
+	if m := Current(); m != nil {
+		return m.Lookup(name)
+	}
+	return nil
+}
+
+// Call invokes a tool of the active manager
+func Call(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	m := Current()
+	if m == nil {
+		return nil, fmt.Errorf("unknown MCP tool: %s", name)
+	}
+	return m.Call(ctx, name, args)
+}
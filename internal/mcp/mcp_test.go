@@ -0,0 +1,219 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// fakeServerEnv makes the test binary act as a stdio MCP server
+const fakeServerEnv = "AIQ_MCP_FAKE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		serveFake(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeHandle answers one request of the fake server
+func fakeHandle(msg *message) *message {
+	reply := &message{JSONRPC: "2.0", ID: msg.ID}
+	var result interface{}
+	switch msg.Method {
+	case "initialize":
+		result = initializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "fake", Version: "1.0"}}
+	case "tools/list":
+		var params listToolsParams
+		json.Unmarshal(msg.Params, &params)
+		readOnly := true
+		if params.Cursor == "" {
+			result = listToolsResult{
+				Tools: []Tool{{
+					Name:        "echo",
+					Description: "Echo the text",
+					InputSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}}},
+					Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly},
+				}},
+				NextCursor: "page2",
+			}
+		} else {
+			result = listToolsResult{Tools: []Tool{{Name: "fail", Description: "Always fails"}, {Name: "hidden.tool"}}}
+		}
+	case "tools/call":
+		var params callToolParams
+		json.Unmarshal(msg.Params, &params)
+		if params.Name == "fail" {
+			result = CallToolResult{Content: []Content{{Type: "text", Text: "something broke"}}, IsError: true}
+		} else {
+			data, _ := json.Marshal(params.Arguments)
+			result = CallToolResult{Content: []Content{{Type: "text", Text: string(data)}, {Type: "image", MimeType: "image/png"}}}
+		}
+	default:
+		reply.Error = &RPCError{Code: codeMethodNotFound, Message: "unknown method"}
+		return reply
+	}
+	reply.Result, _ = json.Marshal(result)
+	return reply
+}
+
+// serveFake runs the fake server over newline-delimited JSON, pinging the client before each tool call
+func serveFake(in io.Reader, out io.Writer) {
+	reader := bufio.NewReader(in)
+	encoder := json.NewEncoder(out)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var msg message
+		if json.Unmarshal(line, &msg) != nil || !msg.isRequest() {
+			continue
+		}
+		if msg.Method == "tools/call" {
+			encoder.Encode(&message{JSONRPC: "2.0", ID: json.RawMessage(`"srv-1"`), Method: "ping"})
+			encoder.Encode(&message{JSONRPC: "2.0", Method: "notifications/message", Params: json.RawMessage(`{"level":"info"}`)})
+			// Wait for the ping reply before answering
+			if _, err := reader.ReadBytes('\n'); err != nil {
+				return
+			}
+		}
+		encoder.Encode(fakeHandle(&msg))
+	}
+}
+
+func checkManager(t *testing.T, m *Manager) {
+	t.Helper()
+	server := m.Servers()[0]
+	if server.Err != nil {
+		t.Fatalf("Connect failed: %v", server.Err)
+	}
+	if server.Info.Name != "fake" {
+		t.Errorf("Expected server info from initialize, got %+v", server.Info)
+	}
+	var names []string
+	for _, b := range server.Tools {
+		names = append(names, b.Name)
+	}
+	if strings.Join(names, ",") != "fake__echo,fake__fail,fake__hidden_tool" {
+		t.Errorf("Expected tools from both pages with sanitized names, got %v", names)
+	}
+
+	result, err := m.Call(context.Background(), "fake__echo", map[string]interface{}{"text": "hi", "risk_level": "low"})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	output := result.(map[string]interface{})
+	if output["status"] != "success" || output["content"] != "{\"text\":\"hi\"}\n[image content image/png omitted]" {
+		t.Errorf("Unexpected result %v", output)
+	}
+
+	result, err = m.Call(context.Background(), "fake__fail", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if output := result.(map[string]interface{}); output["status"] != "error" || output["error"] != "something broke" {
+		t.Errorf("Expected a tool error result, got %v", output)
+	}
+
+	definition := m.Lookup("fake__echo").Definition()["function"].(map[string]interface{})
+	if !strings.HasPrefix(definition["description"].(string), "[MCP server fake]") {
+		t.Errorf("Unexpected definition %v", definition)
+	}
+	if m.Lookup("fake__fail").Definition()["function"].(map[string]interface{})["parameters"].(map[string]interface{})["type"] != "object" {
+		t.Error("Expected an object schema for a tool without inputSchema")
+	}
+}
+
+// TestStdio tests a server launched over stdio
+func TestStdio(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Connect(context.Background(), config.MCPConfig{Servers: map[string]config.MCPServerConfig{
+		"fake":     {Command: executable, Env: map[string]string{fakeServerEnv: "1"}},
+		"disabled": {Command: "missing-binary", Disabled: true},
+	}})
+	defer m.Close()
+	if len(m.Servers()) != 1 {
+		t.Fatalf("Expected disabled servers to be skipped, got %d servers", len(m.Servers()))
+	}
+	checkManager(t, m)
+
+	broken := Connect(context.Background(), config.MCPConfig{Servers: map[string]config.MCPServerConfig{
+		"broken": {Command: "aiq-missing-mcp-server"},
+	}})
+	if broken.Servers()[0].Err == nil {
+		t.Error("Expected an error for a server that cannot be started")
+	}
+}
+
+// TestHTTP tests a streamable HTTP server answering with JSON and with event streams
+func TestHTTP(t *testing.T) {
+	var sessionHeaders []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var msg message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sessionHeaders = append(sessionHeaders, r.Header.Get("Mcp-Session-Id"))
+		if !msg.isRequest() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if msg.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		}
+		reply, _ := json.Marshal(fakeHandle(&msg))
+		if msg.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "data: %s\n\n", reply)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
+	}))
+	defer srv.Close()
+
+	t.Setenv("AIQ_SECRET_FAKE_TOKEN", "token")
+	m := Connect(context.Background(), config.MCPConfig{Servers: map[string]config.MCPServerConfig{
+		"fake": {URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer ${secret:fake_token}"}},
+	}})
+	checkManager(t, m)
+	m.Close()
+
+	if sessionHeaders[0] != "" || sessionHeaders[len(sessionHeaders)-1] != "session-1" {
+		t.Errorf("Expected the session id to be sent after initialize, got %v", sessionHeaders)
+	}
+}
+
+// TestFunctionName tests names offered to the LLM
+func TestFunctionName(t *testing.T) {
+	if got := functionName("jira cloud", "search.issues"); got != "jira_cloud__search_issues" {
+		t.Errorf("Unexpected name %q", got)
+	}
+	if got := functionName("s", strings.Repeat("x", 100)); len(got) != 64 {
+		t.Errorf("Expected names to be cut at 64 characters, got %d", len(got))
+	}
+}
@@ -0,0 +1,368 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// JSON-RPC error codes returned by the server
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// maxRequestLine bounds a single message read from the client
+const maxRequestLine = 16 * 1024 * 1024
+
+// supportedVersions are the protocol revisions the server accepts, newest first
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ErrConfirmationUnavailable is returned by Confirm when the client cannot ask the user
+var ErrConfirmationUnavailable = errors.New("the MCP client does not support confirmation prompts (elicitation)")
+
+// ToolHandler runs a tool call; a returned error is reported to the client as a tool error
+type ToolHandler func(ctx context.Context, session *Session, args map[string]interface{}) (*CallToolResult, error)
+
+// ToolServer serves tools to a single MCP client over newline-delimited JSON
+type ToolServer struct {
+	Info         Implementation
+	Instructions string
+
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+// NewToolServer creates a server reporting info during initialization
+func NewToolServer(info Implementation, instructions string) *ToolServer {
+	return &ToolServer{Info: info, Instructions: instructions, handlers: make(map[string]ToolHandler)}
+}
+
+// AddTool registers a tool; tools are listed in the order they are added
+func (s *ToolServer) AddTool(tool Tool, handler ToolHandler) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if _, ok := s.handlers[tool.Name]; !ok {
+		s.tools = append(s.tools, tool)
+	}
+	s.handlers[tool.Name] = handler
+}
+
+// Session is the connection to the client, passed to tool handlers
+type Session struct {
+	server *ToolServer
+	out    io.Writer
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu           sync.Mutex
+	capabilities map[string]interface{} // Declared by the client during initialization
+	clientInfo   Implementation
+	pending      map[string]chan *message // Requests sent to the client
+	inflight     map[string]context.CancelFunc
+}
+
+// Serve handles messages from in until it is closed or ctx is done
+// Requests are handled concurrently so a tool can wait for the client's answer to a confirmation prompt
+func (s *ToolServer) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session := &Session{
+		server:   s,
+		out:      out,
+		pending:  make(map[string]chan *message),
+		inflight: make(map[string]context.CancelFunc),
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestLine)
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to read from client: %w", err)
+			}
+			return nil
+		case line := <-lines:
+			if strings.TrimSpace(string(line)) == "" {
+				continue
+			}
+			var msg message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				session.write(&message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: codeParseError, Message: "invalid JSON"}})
+				continue
+			}
+			switch {
+			case msg.isResponse():
+				session.deliver(&msg)
+			case msg.isRequest():
+				reqCtx, reqCancel := context.WithCancel(ctx)
+				session.mu.Lock()
+				session.inflight[string(msg.ID)] = reqCancel
+				session.mu.Unlock()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer session.finish(string(msg.ID))
+					session.write(session.handle(reqCtx, &msg))
+				}()
+			case msg.Method != "":
+				session.notification(&msg)
+			}
+		}
+	}
+}
+
+func (ss *Session) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	ss.writeMu.Lock()
+	defer ss.writeMu.Unlock()
+	if _, err := ss.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to client: %w", err)
+	}
+	return nil
+}
+
+func (ss *Session) finish(id string) {
+	ss.mu.Lock()
+	cancel := ss.inflight[id]
+	delete(ss.inflight, id)
+	ss.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// deliver passes a client response to the request waiting for it
+func (ss *Session) deliver(msg *message) {
+	ss.mu.Lock()
+	ch, ok := ss.pending[string(msg.ID)]
+	delete(ss.pending, string(msg.ID))
+	ss.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+}
+
+func (ss *Session) notification(msg *message) {
+	if msg.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		return
+	}
+	ss.mu.Lock()
+	cancel := ss.inflight[string(params.RequestID)]
+	ss.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// handle answers a request from the client
+func (ss *Session) handle(ctx context.Context, req *message) *message {
+	reply := &message{JSONRPC: "2.0", ID: req.ID}
+	result, rpcErr := ss.dispatch(ctx, req)
+	if rpcErr != nil {
+		reply.Error = rpcErr
+		return reply
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		reply.Error = &RPCError{Code: codeInternalError, Message: fmt.Sprintf("failed to marshal result: %v", err)}
+		return reply
+	}
+	reply.Result = data
+	return reply
+}
+
+func (ss *Session) dispatch(ctx context.Context, req *message) (interface{}, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "invalid initialize params"}
+		}
+		ss.mu.Lock()
+		ss.capabilities = params.Capabilities
+		ss.clientInfo = params.ClientInfo
+		ss.mu.Unlock()
+		return initializeResult{
+			ProtocolVersion: negotiateVersion(params.ProtocolVersion),
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			ServerInfo:      ss.server.Info,
+			Instructions:    ss.server.Instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return listToolsResult{Tools: ss.server.tools}, nil
+	case "tools/call":
+		var params callToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+		}
+		handler, ok := ss.server.handlers[params.Name]
+		if !ok {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]interface{}{}
+		}
+		result, err := handler(ctx, ss, params.Arguments)
+		if err != nil {
+			return ErrorResult(err.Error()), nil
+		}
+		return result, nil
+	case "":
+		return nil, &RPCError{Code: codeInvalidRequest, Message: "missing method"}
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+}
+
+// negotiateVersion answers with the requested revision when supported, otherwise the latest one
+func negotiateVersion(requested string) string {
+	for _, v := range supportedVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return ProtocolVersion
+}
+
+// ClientInfo returns the name and version the client reported
+func (ss *Session) ClientInfo() Implementation {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.clientInfo
+}
+
+// CanConfirm reports whether the client declared the elicitation capability
+func (ss *Session) CanConfirm() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	_, ok := ss.capabilities["elicitation"]
+	return ok
+}
+
+// Confirm asks the user through the client whether to go ahead
+// Returns ErrConfirmationUnavailable when the client does not support elicitation
+func (ss *Session) Confirm(ctx context.Context, prompt string) (bool, error) {
+	if !ss.CanConfirm() {
+		return false, ErrConfirmationUnavailable
+	}
+	params := map[string]interface{}{
+		"message": prompt,
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"confirm": map[string]interface{}{"type": "boolean", "title": "Execute", "description": "Run the operation"},
+			},
+			"required": []string{"confirm"},
+		},
+	}
+	var result struct {
+		Action  string                 `json:"action"`
+		Content map[string]interface{} `json:"content"`
+	}
+	if err := ss.request(ctx, "elicitation/create", params, &result); err != nil {
+		return false, err
+	}
+	confirmed, _ := result.Content["confirm"].(bool)
+	return result.Action == "accept" && confirmed, nil
+}
+
+// request sends a request to the client and decodes its result into result
+func (ss *Session) request(ctx context.Context, method string, params, result interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+	id := strconv.Quote("aiq-" + strconv.FormatInt(ss.nextID.Add(1), 10))
+	ch := make(chan *message, 1)
+	ss.mu.Lock()
+	ss.pending[id] = ch
+	ss.mu.Unlock()
+	defer func() {
+		ss.mu.Lock()
+		delete(ss.pending, id)
+		ss.mu.Unlock()
+	}()
+
+	if err := ss.write(&message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: data}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s failed: %w", method, resp.Error)
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TextResult returns a successful result carrying text
+func TextResult(text string) *CallToolResult {
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// JSONResult returns a successful result carrying v as JSON text and as structured content
+func JSONResult(v interface{}) (*CallToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	result := TextResult(string(data))
+	if _, ok := v.(map[string]interface{}); ok {
+		result.StructuredContent = v
+	}
+	return result, nil
+}
+
+// ErrorResult returns a tool error result the client shows to the model
+func ErrorResult(text string) *CallToolResult {
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}, IsError: true}
+}
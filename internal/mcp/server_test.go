@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+// testClient drives a ToolServer over pipes
+type testClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	reader *bufio.Reader
+	done   chan error
+}
+
+func startTestServer(t *testing.T, server *ToolServer) *testClient {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &testClient{t: t, in: clientOut, reader: bufio.NewReader(clientIn), done: make(chan error, 1)}
+	go func() {
+		c.done <- server.Serve(context.Background(), serverIn, serverOut)
+		serverOut.Close()
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		if err := <-c.done; err != nil {
+			t.Errorf("Serve failed: %v", err)
+		}
+	})
+	return c
+}
+
+func (c *testClient) send(msg *message) {
+	c.t.Helper()
+	data, _ := json.Marshal(msg)
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("write failed: %v", err)
+	}
+}
+
+func (c *testClient) receive() *message {
+	c.t.Helper()
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("read failed: %v", err)
+	}
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		c.t.Fatalf("invalid message %s: %v", line, err)
+	}
+	return &msg
+}
+
+func (c *testClient) request(id, method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	c.send(&message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: data})
+}
+
+func newTestToolServer() *ToolServer {
+	server := NewToolServer(Implementation{Name: "aiq", Version: "test"}, "test instructions")
+	server.AddTool(Tool{Name: "echo"}, func(ctx context.Context, session *Session, args map[string]interface{}) (*CallToolResult, error) {
+		return JSONResult(args)
+	})
+	server.AddTool(Tool{Name: "write"}, func(ctx context.Context, session *Session, args map[string]interface{}) (*CallToolResult, error) {
+		confirmed, err := session.Confirm(ctx, "Run it?")
+		if err != nil {
+			return nil, err
+		}
+		if !confirmed {
+			return ErrorResult("declined"), nil
+		}
+		return TextResult("done"), nil
+	})
+	return server
+}
+
+// TestToolServer tests the handshake, listing and calling tools, and confirmation through elicitation
+func TestToolServer(t *testing.T) {
+	c := startTestServer(t, newTestToolServer())
+
+	c.request("1", "initialize", initializeParams{ProtocolVersion: "2025-03-26", Capabilities: map[string]interface{}{"elicitation": map[string]interface{}{}}})
+	var init initializeResult
+	resp := c.receive()
+	json.Unmarshal(resp.Result, &init)
+	if init.ProtocolVersion != "2025-03-26" || init.ServerInfo.Name != "aiq" || init.Instructions != "test instructions" {
+		t.Errorf("Unexpected initialize result %s", resp.Result)
+	}
+	c.send(&message{JSONRPC: "2.0", Method: "notifications/initialized"})
+
+	c.request("2", "tools/list", nil)
+	var list listToolsResult
+	json.Unmarshal(c.receive().Result, &list)
+	if len(list.Tools) != 2 || list.Tools[0].Name != "echo" || list.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("Unexpected tools %+v", list.Tools)
+	}
+
+	c.request("3", "tools/call", callToolParams{Name: "echo", Arguments: map[string]interface{}{"text": "hi"}})
+	var result CallToolResult
+	json.Unmarshal(c.receive().Result, &result)
+	if result.IsError || result.StructuredContent.(map[string]interface{})["text"] != "hi" {
+		t.Errorf("Unexpected echo result %+v", result)
+	}
+
+	for _, tc := range []struct {
+		action string
+		want   string
+	}{{"accept", "done"}, {"decline", "declined"}} {
+		c.request("4", "tools/call", callToolParams{Name: "write"})
+		elicit := c.receive()
+		if elicit.Method != "elicitation/create" {
+			t.Fatalf("Expected an elicitation request, got %+v", elicit)
+		}
+		content, _ := json.Marshal(map[string]interface{}{"action": tc.action, "content": map[string]interface{}{"confirm": true}})
+		c.send(&message{JSONRPC: "2.0", ID: elicit.ID, Result: content})
+		var result CallToolResult
+		json.Unmarshal(c.receive().Result, &result)
+		if len(result.Content) != 1 || result.Content[0].Text != tc.want {
+			t.Errorf("Expected %q after %s, got %+v", tc.want, tc.action, result)
+		}
+	}
+
+	c.request("5", "resources/list", nil)
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %+v", resp)
+	}
+	c.request("6", "tools/call", callToolParams{Name: "missing"})
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != codeInvalidParams {
+		t.Errorf("Expected invalid params for an unknown tool, got %+v", resp)
+	}
+}
+
+// TestToolServer_NoElicitation tests that confirmation fails when the client cannot ask the user
+func TestToolServer_NoElicitation(t *testing.T) {
+	c := startTestServer(t, newTestToolServer())
+
+	c.request("1", "initialize", initializeParams{ProtocolVersion: "1999-01-01", Capabilities: map[string]interface{}{}})
+	var init initializeResult
+	json.Unmarshal(c.receive().Result, &init)
+	if init.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Expected the latest version for an unsupported request, got %s", init.ProtocolVersion)
+	}
+
+	c.request("2", "tools/call", callToolParams{Name: "write"})
+	var result CallToolResult
+	json.Unmarshal(c.receive().Result, &result)
+	if !result.IsError || result.Content[0].Text != ErrConfirmationUnavailable.Error() {
+		t.Errorf("Expected a tool error without elicitation, got %+v", result)
+	}
+}
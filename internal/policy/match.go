@@ -0,0 +1,517 @@
+package policy
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// call holds the tool call arguments relevant to rule matching
+type call struct {
+	tool      string
+	sql       string
+	command   string
+	host      string
+	method    string
+	path      string
+	operation string
+	table     string
+
+	hasSQL, hasCommand, hasURL, hasMethod, hasPath, hasOperation, hasTable bool
+}
+
+// newCall extracts matchable fields from tool arguments
+// Fields are read by argument name, so declarative and external tools using the same names are covered too
+func newCall(toolName string, args map[string]interface{}) *call {
+	c := &call{tool: toolName}
+	if s, ok := args["sql"].(string); ok {
+		c.sql, c.hasSQL = s, true
+	}
+	if s, ok := args["command"].(string); ok {
+		c.command, c.hasCommand = s, true
+	}
+	if s, ok := args["url"].(string); ok {
+		c.hasURL = true
+		if u, err := url.Parse(s); err == nil {
+			c.host = strings.ToLower(u.Hostname())
+		}
+	}
+	if s, ok := args["method"].(string); ok && s != "" {
+		c.method, c.hasMethod = strings.ToUpper(s), true
+	} else if c.hasURL {
+		// http_request defaults to GET
+		c.method, c.hasMethod = "GET", true
+	}
+	if s, ok := args["path"].(string); ok {
+		c.path, c.hasPath = normalizePath(s), true
+	}
+	if s, ok := args["operation"].(string); ok {
+		c.operation, c.hasOperation = strings.ToLower(s), true
+	}
+	if s, ok := args["table"].(string); ok && strings.TrimSpace(s) != "" {
+		c.table, c.hasTable = strings.ToLower(strings.TrimSpace(s)), true
+	}
+	return c
+}
+
+// matches reports whether every criterion set on the rule matches the call
+// Multi-part inputs (several SQL statements, command pipelines, several tables) are matched
+// conservatively: allow rules must match every part, confirm and deny rules match if any part matches
+func (r *Rule) matches(c *call) bool {
+	requireAll := r.Action == Allow
+
+	if len(r.Tools) > 0 && !matchAny(r.Tools, c.tool, true) {
+		return false
+	}
+
+	if len(r.SQLClasses) > 0 || len(r.Statements) > 0 || len(r.Tables) > 0 {
+		var statements []sqlStatement
+		switch {
+		case c.hasSQL:
+			statements = analyzeSQL(c.sql)
+		case c.hasTable:
+			// Tools that take a table argument (sample_rows, column_values, ...) read that table
+			statements = []sqlStatement{{words: []string{"SELECT"}, class: "read", tables: []string{c.table}}}
+		default:
+			return false
+		}
+		if len(statements) == 0 || !matchParts(len(statements), requireAll, func(i int) bool {
+			return r.matchStatement(&statements[i], requireAll)
+		}) {
+			return false
+		}
+	}
+
+	if len(r.Commands) > 0 {
+		if !c.hasCommand {
+			return false
+		}
+		segments, substituted := commandSegments(c.command)
+		segments, writes := runSegments(segments)
+		// Command substitution hides what actually runs, and redirections and find actions write files,
+		// so allow rules never match them
+		if len(segments) == 0 || (requireAll && (substituted || writes)) {
+			return false
+		}
+		if !matchParts(len(segments), requireAll, func(i int) bool {
+			return matchCommand(r.Commands, segments[i])
+		}) {
+			return false
+		}
+	}
+
+	if len(r.Hosts) > 0 && (!c.hasURL || c.host == "" || !matchAny(r.Hosts, c.host, true)) {
+		return false
+	}
+	if len(r.Methods) > 0 && (!c.hasMethod || !matchAny(r.Methods, c.method, true)) {
+		return false
+	}
+	if len(r.Paths) > 0 && (!c.hasPath || !matchPath(r.Paths, c.path)) {
+		return false
+	}
+	if len(r.Operations) > 0 && (!c.hasOperation || !matchAny(r.Operations, c.operation, true)) {
+		return false
+	}
+	return true
+}
+
+// matchStatement matches the SQL criteria of a rule against one statement
+func (r *Rule) matchStatement(st *sqlStatement, requireAll bool) bool {
+	if len(r.SQLClasses) > 0 && !matchAny(r.SQLClasses, st.class, true) {
+		return false
+	}
+	if len(r.Statements) > 0 {
+		matched := false
+		for _, pattern := range r.Statements {
+			if st.startsWith(pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.Tables) > 0 {
+		if len(st.tables) == 0 {
+			return false
+		}
+		return matchParts(len(st.tables), requireAll, func(i int) bool {
+			return matchTable(r.Tables, st.tables[i])
+		})
+	}
+	return true
+}
+
+// matchParts returns whether all (requireAll) or any of n parts match
+func matchParts(n int, requireAll bool, match func(i int) bool) bool {
+	for i := 0; i < n; i++ {
+		if match(i) != requireAll {
+			return !requireAll
+		}
+	}
+	return requireAll
+}
+
+// matchAny reports whether value matches any glob pattern
+func matchAny(patterns []string, value string, fold bool) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, value, fold) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTable matches a table name against patterns, accepting both qualified (db.table) and bare names
+func matchTable(patterns []string, table string) bool {
+	if matchAny(patterns, table, true) {
+		return true
+	}
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		return matchAny(patterns, table[idx+1:], true)
+	}
+	return false
+}
+
+// matchCommand matches one command segment
+// Plain words match the command name; patterns with spaces or wildcards match the whole segment
+func matchCommand(patterns []string, segment string) bool {
+	name := commandName(segment)
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, " *?") {
+			if name == pattern {
+				return true
+			}
+			continue
+		}
+		if globMatch(pattern, segment, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches a normalized path; patterns without wildcards also match everything below them
+func matchPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		pattern = normalizePath(pattern)
+		if !strings.ContainsAny(pattern, "*?") {
+			if path == pattern || strings.HasPrefix(path, strings.TrimSuffix(pattern, string(filepath.Separator))+string(filepath.Separator)) {
+				return true
+			}
+			continue
+		}
+		if globMatch(pattern, path, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePath expands ~ and returns an absolute, cleaned path (wildcards are preserved)
+func normalizePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+var globCache sync.Map
+
+// globMatch matches value against a pattern where * matches any characters and ? one character
+func globMatch(pattern, value string, fold bool) bool {
+	key := pattern
+	if fold {
+		key = "(?i)" + pattern
+	}
+	if cached, ok := globCache.Load(key); ok {
+		return cached.(*regexp.Regexp).MatchString(value)
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	expr = "^" + expr + "$"
+	if fold {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+	globCache.Store(key, re)
+	return re.MatchString(value)
+}
+
+var substitutionPattern = regexp.MustCompile("\\$\\(([^()]*)\\)|`([^`]*)`")
+
+// commandSegments splits a shell command line into the simple commands it runs
+// (separated by ;, &&, ||, | and newlines), including the contents of $(...) and backtick substitutions
+// The second result reports whether any command substitution was present
+func commandSegments(command string) ([]string, bool) {
+	var segments []string
+	substituted := false
+	for _, m := range substitutionPattern.FindAllStringSubmatch(command, -1) {
+		substituted = true
+		inner := m[1]
+		if inner == "" {
+			inner = m[2]
+		}
+		innerSegments, _ := commandSegments(inner)
+		segments = append(segments, innerSegments...)
+	}
+
+	var current strings.Builder
+	var quote rune
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			segments = append(segments, s)
+		}
+		current.Reset()
+	}
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if quote != 0 {
+			if ch == quote {
+				quote = 0
+			}
+			current.WriteRune(ch)
+			continue
+		}
+		switch ch {
+		case '\\':
+			// An escaped character (e.g. the \; ending find -exec) is part of the word
+			current.WriteRune(ch)
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			}
+		case '\'', '"':
+			quote = ch
+			current.WriteRune(ch)
+		case ';', '\n', '|', '&':
+			// & in a redirection (2>&1, &>file) does not separate commands
+			if ch == '&' && ((i > 0 && (runes[i-1] == '>' || runes[i-1] == '<')) || (i+1 < len(runes) && runes[i+1] == '>')) {
+				current.WriteRune(ch)
+				continue
+			}
+			// && and || are two-character separators; a single & backgrounds the command
+			if (ch == '|' || ch == '&') && i+1 < len(runes) && runes[i+1] == ch {
+				i++
+			}
+			flush()
+		default:
+			current.WriteRune(ch)
+		}
+	}
+	flush()
+	return segments, substituted
+}
+
+// commandName returns the executable name of a simple command, skipping VAR=value assignments and path prefixes
+func commandName(segment string) string {
+	for _, part := range strings.Fields(segment) {
+		if strings.Contains(part, "=") && !strings.HasPrefix(part, "-") {
+			continue
+		}
+		part = strings.Trim(part, "(){}")
+		if idx := strings.LastIndex(part, "/"); idx >= 0 {
+			part = part[idx+1:]
+		}
+		return part
+	}
+	return ""
+}
+
+// findActions are the find actions that delete or write files
+var findActions = map[string]bool{
+	"-delete": true, "-fprint": true, "-fprint0": true, "-fprintf": true, "-fls": true,
+}
+
+// findExecs are the find actions that run a command, ended by ; or +
+var findExecs = map[string]bool{
+	"-exec": true, "-execdir": true, "-ok": true, "-okdir": true,
+}
+
+// runSegments returns the simple commands the segments run: a command run through env is matched in its
+// place, and the commands find runs with -exec and the like are added
+// The second result reports whether a segment writes files: output redirection to anything but /dev/null,
+// or a find action that deletes or writes files
+func runSegments(segments []string) ([]string, bool) {
+	var run []string
+	writes := false
+	for _, segment := range segments {
+		writes = writes || redirectsOutput(segment)
+		words := unwrapEnv(shellWords(segment))
+		if len(words) == 0 {
+			continue
+		}
+		run = append(run, strings.Join(words, " "))
+		if commandName(strings.Join(words, " ")) != "find" {
+			continue
+		}
+		for i := 1; i < len(words); i++ {
+			if findActions[words[i]] {
+				writes = true
+			}
+			if !findExecs[words[i]] {
+				continue
+			}
+			end := i + 1
+			for end < len(words) && words[end] != ";" && words[end] != "+" {
+				end++
+			}
+			if end > i+1 {
+				inner, innerWrites := runSegments([]string{strings.Join(words[i+1:end], " ")})
+				run = append(run, inner...)
+				writes = writes || innerWrites
+			}
+			i = end
+		}
+	}
+	return run, writes
+}
+
+// unwrapEnv returns the words of the command env runs, skipping env's options and VAR=value assignments
+// env without a command (which prints the environment) is returned as is
+func unwrapEnv(words []string) []string {
+	for {
+		i := 0
+		for i < len(words) && strings.Contains(words[i], "=") && !strings.HasPrefix(words[i], "-") {
+			i++
+		}
+		if i == len(words) || commandName(words[i]) != "env" {
+			return words
+		}
+		i++
+	options:
+		for i < len(words) {
+			word := words[i]
+			switch {
+			case word == "-u" || word == "--unset" || word == "-C" || word == "--chdir":
+				i += 2
+			case word == "-S" || word == "--split-string":
+				if i+1 < len(words) {
+					return unwrapEnv(append(shellWords(words[i+1]), words[i+2:]...))
+				}
+				i++
+			case strings.HasPrefix(word, "--split-string="):
+				return unwrapEnv(append(shellWords(strings.TrimPrefix(word, "--split-string=")), words[i+1:]...))
+			case strings.HasPrefix(word, "-S"):
+				return unwrapEnv(append(shellWords(word[2:]), words[i+1:]...))
+			case word == "--":
+				i++
+				break options
+			case strings.HasPrefix(word, "-") || (strings.Contains(word, "=") && !strings.HasPrefix(word, "=")):
+				i++
+			default:
+				break options
+			}
+		}
+		if i >= len(words) {
+			return words
+		}
+		words = words[i:]
+	}
+}
+
+// shellWords splits a simple command into words, removing quotes and backslash escapes
+func shellWords(segment string) []string {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	runes := []rune(segment)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				current.WriteRune(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote, inWord = ch, true
+		case ch == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inWord = true
+		case ch == ' ' || ch == '\t':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+// redirectsOutput reports whether a simple command redirects output to a file
+// Duplicating a descriptor (2>&1) and discarding output (>/dev/null) do not write files
+func redirectsOutput(segment string) bool {
+	var quote rune
+	runes := []rune(segment)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '\'' || ch == '"':
+			quote = ch
+			continue
+		case ch == '\\':
+			i++
+			continue
+		case ch != '>':
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && (runes[j] == '>' || runes[j] == '|') {
+			j++
+		}
+		if j < len(runes) && runes[j] == '&' {
+			// >&2 duplicates a descriptor; >&file writes to the file
+			j++
+			k := j
+			for k < len(runes) && (runes[k] >= '0' && runes[k] <= '9' || runes[k] == '-') {
+				k++
+			}
+			if k > j && (k == len(runes) || runes[k] == ' ' || runes[k] == '\t') {
+				i = k
+				continue
+			}
+		}
+		for j < len(runes) && (runes[j] == ' ' || runes[j] == '\t') {
+			j++
+		}
+		k := j
+		for k < len(runes) && runes[k] != ' ' && runes[k] != '\t' {
+			k++
+		}
+		if target := strings.Trim(string(runes[j:k]), `'"`); target != "/dev/null" {
+			return true
+		}
+		i = k
+	}
+	return false
+}
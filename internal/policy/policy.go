@@ -0,0 +1,397 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// Action is what a matching rule does with a tool call
+type Action string
+
+const (
+	// Allow executes the tool call without asking (low risk)
+	Allow Action = "allow"
+	// Confirm asks the user before executing the tool call (high risk)
+	Confirm Action = "confirm"
+	// Deny refuses the tool call; the reason is reported back to the LLM
+	Deny Action = "deny"
+)
+
+// Origin identifies which part of the policy a matching rule came from
+type Origin string
+
+const (
+	// OriginSource marks rules from the per-source section of policy.yaml
+	OriginSource Origin = "source"
+	// OriginGlobal marks rules from the top-level rules of policy.yaml
+	OriginGlobal Origin = "global"
+	// OriginDefault marks the built-in default rules
+	OriginDefault Origin = "default"
+	// OriginNone means no rule matched
+	OriginNone Origin = "none"
+)
+
+// Rule matches tool calls and assigns an action
+// All criteria that are set must match (AND); values within one criterion are alternatives (OR)
+// Patterns support * (any characters, including /) and ? (one character)
+type Rule struct {
+	Name   string `yaml:"name,omitempty"`
+	Action Action `yaml:"action"`
+
+	// Tools limits the rule to tool names (e.g. execute_sql, execute_command)
+	Tools []string `yaml:"tools,omitempty"`
+
+	// SQL criteria (execute_sql): statement class (read, dml, ddl, dcl, other),
+	// leading statement keywords (e.g. "SELECT", "CREATE TABLE") and referenced table names
+	// Tools with a table argument (describe_table, sample_rows, column_values) match as a SELECT reading that table
+	SQLClasses []string `yaml:"sql_class,omitempty"`
+	Statements []string `yaml:"statements,omitempty"`
+	Tables     []string `yaml:"tables,omitempty"`
+
+	// Commands matches execute_command; a pattern without spaces or wildcards matches the command name,
+	// otherwise the whole command line of each pipeline/list segment
+	Commands []string `yaml:"commands,omitempty"`
+
+	// HTTP criteria (http_request): URL host names and methods
+	Hosts   []string `yaml:"hosts,omitempty"`
+	Methods []string `yaml:"methods,omitempty"`
+
+	// File criteria (file_operations): paths (~ is expanded) and operations (read, write, list, exists)
+	// Operations also match the transaction tool (begin, commit, rollback, status)
+	Paths      []string `yaml:"paths,omitempty"`
+	Operations []string `yaml:"operations,omitempty"`
+
+	// Reason is shown to the user and the LLM when the rule denies or requires confirmation
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// RuleSet is a list of rules plus whether the built-in defaults still apply after them
+type RuleSet struct {
+	// IncludeDefaults controls whether built-in default rules are evaluated after user rules (default true)
+	IncludeDefaults *bool  `yaml:"include_defaults,omitempty"`
+	Rules           []Rule `yaml:"rules,omitempty"`
+}
+
+// File is the on-disk format of ~/.aiq/config/policy.yaml
+type File struct {
+	RuleSet `yaml:",inline"`
+
+	// Sources holds per-source overrides, evaluated before the top-level rules
+	Sources map[string]RuleSet `yaml:"sources,omitempty"`
+}
+
+// Decision is the result of evaluating a tool call against a policy
+type Decision struct {
+	Action Action
+	Rule   string // Name (or index) of the matching rule, empty when no rule matched
+	Reason string
+	Origin Origin
+}
+
+// Matched reports whether a rule matched the call
+func (d Decision) Matched() bool {
+	return d.Origin != OriginNone
+}
+
+// UserDefined reports whether the decision came from a user-configured rule (not a built-in default)
+func (d Decision) UserDefined() bool {
+	return d.Origin == OriginSource || d.Origin == OriginGlobal
+}
+
+// String describes the decision for display and logs
+func (d Decision) String() string {
+	if !d.Matched() {
+		return fmt.Sprintf("%s (no rule matched)", d.Action)
+	}
+	s := fmt.Sprintf("%s (%s rule %q)", d.Action, d.Origin, d.Rule)
+	if d.Reason != "" {
+		s += ": " + d.Reason
+	}
+	return s
+}
+
+type scopedRule struct {
+	rule   Rule
+	name   string
+	origin Origin
+}
+
+// Policy is an ordered list of rules; the first matching rule decides
+type Policy struct {
+	source     string
+	rules      []scopedRule
+	file       *File // The file the policy was built from, for the policies of attached sources
+	restricted bool  // Built by restrictive, so attached sources are restricted too
+
+	mu     sync.Mutex
+	others map[string]*Policy // Policies of attached sources, by source name
+}
+
+// New builds the effective policy for a source from a policy file
+// Order: rules for the source, top-level rules, then built-in defaults (unless disabled)
+// file may be nil, in which case only the defaults apply
+func New(file *File, sourceName string) (*Policy, error) {
+	p := &Policy{source: sourceName, file: file}
+	includeDefaults := true
+
+	if file != nil {
+		if sourceName != "" {
+			if set, ok := file.Sources[sourceName]; ok {
+				if err := p.addRules(set.Rules, OriginSource, "source "+sourceName); err != nil {
+					return nil, err
+				}
+				if set.IncludeDefaults != nil {
+					includeDefaults = *set.IncludeDefaults
+				}
+			}
+		}
+		if err := p.addRules(file.Rules, OriginGlobal, "rules"); err != nil {
+			return nil, err
+		}
+		if file.IncludeDefaults != nil && !*file.IncludeDefaults {
+			includeDefaults = false
+		}
+	}
+
+	if includeDefaults {
+		if err := p.addRules(DefaultRules(), OriginDefault, "defaults"); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// addRules validates and appends rules with the given origin
+func (p *Policy) addRules(rules []Rule, origin Origin, section string) error {
+	for i, rule := range rules {
+		rule.Action = Action(strings.ToLower(string(rule.Action)))
+		switch rule.Action {
+		case Allow, Confirm, Deny:
+		default:
+			return fmt.Errorf("%s rule %d: invalid action %q (expected allow, confirm or deny)", section, i+1, rule.Action)
+		}
+		for _, class := range rule.SQLClasses {
+			if !isValidSQLClass(class) {
+				return fmt.Errorf("%s rule %d: invalid sql_class %q (expected read, dml, ddl, dcl or other)", section, i+1, class)
+			}
+		}
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("%s#%d", section, i+1)
+		}
+		p.rules = append(p.rules, scopedRule{rule: rule, name: name, origin: origin})
+	}
+	return nil
+}
+
+// Source returns the source name the policy was built for
+func (p *Policy) Source() string {
+	return p.source
+}
+
+// Rules returns the effective rules in evaluation order with their names and origins
+func (p *Policy) Rules() []RuleInfo {
+	infos := make([]RuleInfo, 0, len(p.rules))
+	for _, r := range p.rules {
+		infos = append(infos, RuleInfo{Rule: r.rule, Name: r.name, Origin: r.origin})
+	}
+	return infos
+}
+
+// RuleInfo describes an effective rule
+type RuleInfo struct {
+	Rule   Rule
+	Name   string
+	Origin Origin
+}
+
+// Evaluate returns the decision of the first rule matching the tool call
+// Calls that match no rule require confirmation (conservative default)
+// Calls naming another source in a source argument (an attached source) are evaluated with that source's overrides
+func (p *Policy) Evaluate(toolName string, args map[string]interface{}) Decision {
+	if name, ok := args["source"].(string); ok && name != "" && name != p.source {
+		return p.sourcePolicy(name).Evaluate(toolName, args)
+	}
+	call := newCall(toolName, args)
+	for _, r := range p.rules {
+		if r.rule.matches(call) {
+			return Decision{Action: r.rule.Action, Rule: r.name, Reason: r.rule.Reason, Origin: r.origin}
+		}
+	}
+	return Decision{Action: Confirm, Origin: OriginNone}
+}
+
+// sourcePolicy returns the policy for calls on another source, built from the same file
+func (p *Policy) sourcePolicy(name string) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if other, ok := p.others[name]; ok {
+		return other
+	}
+	other := restrictive(name)
+	if !p.restricted {
+		if built, err := New(p.file, name); err == nil {
+			other = built
+		}
+	}
+	if p.others == nil {
+		p.others = make(map[string]*Policy)
+	}
+	p.others[name] = other
+	return other
+}
+
+// DefaultRules returns the built-in rules used when policy.yaml does not disable them
+// They reproduce the historical behavior: dangerous commands are blocked, read-only operations run automatically
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:     "block-dangerous-commands",
+			Action:   Deny,
+			Tools:    []string{"execute_command"},
+			Commands: []string{"rm", "sudo", "dd", "mkfs", "fdisk", "shutdown", "reboot", "halt", "poweroff", "init", "killall", "kill"},
+			Reason:   "command is blocked for security reasons",
+		},
+		{
+			Name:       "allow-read-only-sql",
+			Action:     Allow,
+			Tools:      []string{"execute_sql"},
+			SQLClasses: []string{"read"},
+		},
+		{
+			// CREATE TABLE only adds a new table without modifying existing data
+			Name:       "allow-create-table",
+			Action:     Allow,
+			Tools:      []string{"execute_sql"},
+			Statements: []string{"CREATE TABLE"},
+		},
+		{
+			Name:   "allow-read-only-commands",
+			Action: Allow,
+			Tools:  []string{"execute_command"},
+			Commands: []string{
+				"ls", "cat", "pwd", "echo", "grep", "head", "tail", "wc", "find", "which", "type",
+				"whereis", "locate", "stat", "file", "date", "uptime", "whoami", "id", "env", "printenv",
+			},
+		},
+		{
+			Name:       "allow-read-only-file-operations",
+			Action:     Allow,
+			Tools:      []string{"file_operations"},
+			Operations: []string{"read", "list", "exists"},
+		},
+		{
+			// Committing makes the transaction's changes permanent, so only commit asks first
+			Name:       "allow-transaction-control",
+			Action:     Allow,
+			Tools:      []string{"transaction"},
+			Operations: []string{"begin", "rollback", "status"},
+		},
+		{
+			Name:    "allow-safe-http-methods",
+			Action:  Allow,
+			Tools:   []string{"http_request"},
+			Methods: []string{"GET", "HEAD", "OPTIONS"},
+		},
+	}
+}
+
+// restrictive returns a policy that only blocks dangerous commands and confirms everything else
+// It is used when policy.yaml cannot be loaded, so a broken file never loosens confirmation
+func restrictive(sourceName string) *Policy {
+	p := &Policy{source: sourceName, restricted: true}
+	for _, rule := range DefaultRules() {
+		if rule.Action == Deny {
+			p.rules = append(p.rules, scopedRule{rule: rule, name: rule.Name, origin: OriginDefault})
+		}
+	}
+	return p
+}
+
+// LoadFile reads and parses a policy file; a missing file returns nil without error
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return &file, nil
+}
+
+// Load builds the effective policy for a source from ~/.aiq/config/policy.yaml
+func Load(sourceName string) (*Policy, error) {
+	path, err := config.GetPolicyFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy file path: %w", err)
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := New(file, sourceName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+var (
+	current   *Policy
+	currentMu sync.Mutex
+	loadErr   error
+)
+
+// Current returns the active policy, loading the global policy on first use
+// If policy.yaml is invalid, a restrictive fallback is returned and LoadError reports why
+func Current() *Policy {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	if current == nil {
+		current, loadErr = loadOrRestrict("")
+	}
+	return current
+}
+
+// SetSource reloads the active policy with the overrides for the given source
+// On error the restrictive fallback becomes active and the error is returned
+func SetSource(sourceName string) error {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current, loadErr = loadOrRestrict(sourceName)
+	return loadErr
+}
+
+// SetCurrent replaces the active policy (used by tests and callers that build policies directly)
+func SetCurrent(p *Policy) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = p
+	loadErr = nil
+}
+
+// LoadError returns the error from the last attempt to load policy.yaml, if any
+func LoadError() error {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	return loadErr
+}
+
+func loadOrRestrict(sourceName string) (*Policy, error) {
+	p, err := Load(sourceName)
+	if err != nil {
+		return restrictive(sourceName), err
+	}
+	return p, nil
+}
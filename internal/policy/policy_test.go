@@ -0,0 +1,253 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// TestDefaultRules tests that built-in defaults reproduce the historical whitelists and blocklist
+func TestDefaultRules(t *testing.T) {
+	p, err := New(nil, "")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]interface{}
+		expected Action
+	}{
+		{"SELECT allowed", "execute_sql", map[string]interface{}{"sql": "SELECT * FROM users"}, Allow},
+		{"CREATE TABLE allowed", "execute_sql", map[string]interface{}{"sql": "CREATE TABLE t (id INT)"}, Allow},
+		{"DELETE needs confirmation", "execute_sql", map[string]interface{}{"sql": "DELETE FROM users"}, Confirm},
+		{"SELECT followed by DROP needs confirmation", "execute_sql", map[string]interface{}{"sql": "SELECT 1; DROP TABLE users"}, Confirm},
+		{"comment before SELECT allowed", "execute_sql", map[string]interface{}{"sql": "/* report */ SELECT 1"}, Allow},
+		{"ls allowed", "execute_command", map[string]interface{}{"command": "ls -la"}, Allow},
+		{"ls with env and path allowed", "execute_command", map[string]interface{}{"command": "LC_ALL=C /bin/ls"}, Allow},
+		{"pipeline of read-only commands allowed", "execute_command", map[string]interface{}{"command": "cat a.txt | grep x | wc -l"}, Allow},
+		{"rm denied", "execute_command", map[string]interface{}{"command": "rm -rf /tmp/x"}, Deny},
+		{"rm after ls denied", "execute_command", map[string]interface{}{"command": "ls && rm x"}, Deny},
+		{"rm inside substitution denied", "execute_command", map[string]interface{}{"command": "echo $(rm x)"}, Deny},
+		{"substitution never allowed", "execute_command", map[string]interface{}{"command": "echo $(whoami)"}, Confirm},
+		{"separator inside quotes ignored", "execute_command", map[string]interface{}{"command": "echo 'a; rm x'"}, Allow},
+		{"unknown command needs confirmation", "execute_command", map[string]interface{}{"command": "make build"}, Confirm},
+		{"find allowed", "execute_command", map[string]interface{}{"command": "find . -name '*.go'"}, Allow},
+		{"find -delete needs confirmation", "execute_command", map[string]interface{}{"command": "find / -name x -delete"}, Confirm},
+		{"find -fprint needs confirmation", "execute_command", map[string]interface{}{"command": "find . -fprint out.txt"}, Confirm},
+		{"find -exec rm denied", "execute_command", map[string]interface{}{"command": "find . -exec rm {} +"}, Deny},
+		{"find -exec rm with escaped semicolon denied", "execute_command", map[string]interface{}{"command": `find . -name '*.log' -exec rm -f {} \;`}, Deny},
+		{"find -ok rm denied", "execute_command", map[string]interface{}{"command": `find . -ok /bin/rm {} \;`}, Deny},
+		{"find -execdir of unknown command needs confirmation", "execute_command", map[string]interface{}{"command": `find . -execdir mv {} old \;`}, Confirm},
+		{"find -exec of read-only command allowed", "execute_command", map[string]interface{}{"command": `find . -type f -exec cat {} \;`}, Allow},
+		{"env alone allowed", "execute_command", map[string]interface{}{"command": "env"}, Allow},
+		{"env running read-only command allowed", "execute_command", map[string]interface{}{"command": "env LC_ALL=C ls"}, Allow},
+		{"env rm denied", "execute_command", map[string]interface{}{"command": "env rm -rf ~"}, Deny},
+		{"env with options rm denied", "execute_command", map[string]interface{}{"command": "env -i -u HOME FOO=1 /bin/rm -rf ~"}, Deny},
+		{"env split string rm denied", "execute_command", map[string]interface{}{"command": "env -S 'rm -rf ~'"}, Deny},
+		{"env after assignment needs confirmation", "execute_command", map[string]interface{}{"command": "FOO=1 env make build"}, Confirm},
+		{"env inside find -exec denied", "execute_command", map[string]interface{}{"command": "find . -exec env rm {} +"}, Deny},
+		{"echo redirected to file needs confirmation", "execute_command", map[string]interface{}{"command": "echo x > ~/.bashrc"}, Confirm},
+		{"printenv appended to file needs confirmation", "execute_command", map[string]interface{}{"command": "printenv >>env.txt"}, Confirm},
+		{"output and errors redirected to file need confirmation", "execute_command", map[string]interface{}{"command": "cat a &> out"}, Confirm},
+		{"redirect to descriptor file needs confirmation", "execute_command", map[string]interface{}{"command": "ls >&out"}, Confirm},
+		{"redirect inside quotes ignored", "execute_command", map[string]interface{}{"command": "echo 'a > b'"}, Allow},
+		{"discarded errors allowed", "execute_command", map[string]interface{}{"command": "ls missing 2>/dev/null"}, Allow},
+		{"duplicated descriptor allowed", "execute_command", map[string]interface{}{"command": "ls 2>&1 | grep x"}, Allow},
+		{"file read allowed", "file_operations", map[string]interface{}{"operation": "read", "path": "a.txt"}, Allow},
+		{"file write needs confirmation", "file_operations", map[string]interface{}{"operation": "write", "path": "a.txt"}, Confirm},
+		{"HTTP GET by default allowed", "http_request", map[string]interface{}{"url": "https://example.com"}, Allow},
+		{"HTTP POST needs confirmation", "http_request", map[string]interface{}{"url": "https://example.com", "method": "post"}, Confirm},
+		{"transaction begin allowed", "transaction", map[string]interface{}{"operation": "begin"}, Allow},
+		{"transaction commit needs confirmation", "transaction", map[string]interface{}{"operation": "commit"}, Confirm},
+		{"unknown tool needs confirmation", "custom_tool", map[string]interface{}{}, Confirm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := p.Evaluate(tt.tool, tt.args)
+			if decision.Action != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, decision)
+			}
+		})
+	}
+}
+
+// TestUserRules tests rule ordering, source overrides and the criteria types
+func TestUserRules(t *testing.T) {
+	file := &File{
+		RuleSet: RuleSet{Rules: []Rule{
+			{Name: "no-drop", Action: Deny, SQLClasses: []string{"ddl"}, Statements: []string{"DROP", "TRUNCATE"}, Reason: "schema changes go through migrations"},
+			{Name: "audit-tables", Action: Confirm, Tables: []string{"audit_*"}},
+			{Name: "public-reads", Action: Allow, SQLClasses: []string{"read"}, Tables: []string{"public_*"}},
+			{Name: "internal-api", Action: Allow, Hosts: []string{"*.internal.example.com"}, Methods: []string{"POST"}},
+			{Name: "ssh-keys", Action: Deny, Paths: []string{"~/.ssh"}},
+			{Name: "kubectl-delete", Action: Deny, Commands: []string{"kubectl delete *"}},
+		}},
+		Sources: map[string]RuleSet{
+			"prod": {Rules: []Rule{
+				{Name: "prod-writes", Action: Deny, SQLClasses: []string{"dml"}},
+			}},
+		},
+	}
+	home, _ := os.UserHomeDir()
+
+	tests := []struct {
+		name         string
+		source       string
+		tool         string
+		args         map[string]interface{}
+		expected     Action
+		expectedRule string
+	}{
+		{"deny DROP", "", "execute_sql", map[string]interface{}{"sql": "drop table users"}, Deny, "no-drop"},
+		{"confirm read of audit table", "", "execute_sql", map[string]interface{}{"sql": "SELECT * FROM audit_log"}, Confirm, "audit-tables"},
+		{"confirm join touching audit table", "", "execute_sql", map[string]interface{}{"sql": "SELECT * FROM public_a JOIN audit_log ON 1=1"}, Confirm, "audit-tables"},
+		{"allow read of public tables", "", "execute_sql", map[string]interface{}{"sql": "SELECT * FROM shop.public_a a JOIN `public_b` b ON a.id = b.id"}, Allow, "public-reads"},
+		{"table argument matches table rules", "", "sample_rows", map[string]interface{}{"table": "Audit_Log"}, Confirm, "audit-tables"},
+		{"qualified table argument matches", "", "column_values", map[string]interface{}{"table": "shop.public_a", "column": "status"}, Allow, "public-reads"},
+		{"allow rule requires every table", "", "execute_sql", map[string]interface{}{"sql": "SELECT * FROM public_a JOIN secrets ON 1=1"}, Allow, "allow-read-only-sql"},
+		{"host and method match", "", "http_request", map[string]interface{}{"url": "https://api.internal.example.com/x", "method": "POST"}, Allow, "internal-api"},
+		{"other host falls through", "", "http_request", map[string]interface{}{"url": "https://example.com/x", "method": "POST"}, Confirm, ""},
+		{"path below directory denied", "", "file_operations", map[string]interface{}{"operation": "read", "path": filepath.Join(home, ".ssh", "id_rsa")}, Deny, "ssh-keys"},
+		{"command pattern denied", "", "execute_command", map[string]interface{}{"command": "kubectl get pods; kubectl delete pod x"}, Deny, "kubectl-delete"},
+		{"source override applies", "prod", "execute_sql", map[string]interface{}{"sql": "UPDATE users SET a = 1"}, Deny, "prod-writes"},
+		{"source override only for its source", "dev", "execute_sql", map[string]interface{}{"sql": "UPDATE users SET a = 1"}, Confirm, ""},
+		{"attached source override applies", "dev", "execute_sql", map[string]interface{}{"sql": "UPDATE users SET a = 1", "source": "prod"}, Deny, "prod-writes"},
+		{"naming the session source changes nothing", "prod", "execute_sql", map[string]interface{}{"sql": "SELECT 1", "source": "prod"}, Allow, "allow-read-only-sql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(file, tt.source)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			decision := p.Evaluate(tt.tool, tt.args)
+			if decision.Action != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, decision)
+			}
+			if tt.expectedRule != "" && decision.Rule != tt.expectedRule {
+				t.Errorf("Expected rule %q, got %q", tt.expectedRule, decision.Rule)
+			}
+		})
+	}
+}
+
+// TestTableRuleStatementForms tests that a table rule matches every statement form naming the table
+func TestTableRuleStatementForms(t *testing.T) {
+	file := &File{RuleSet: RuleSet{Rules: []Rule{
+		{Name: "protect-orders", Action: Deny, Tables: []string{"orders"}},
+	}}}
+	p, err := New(file, "")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	for _, sql := range []string{
+		"TRUNCATE TABLE orders",
+		"TRUNCATE orders",
+		"CREATE INDEX i ON orders(a)",
+		"CREATE UNIQUE INDEX i ON orders (a)",
+		"LOCK TABLES orders WRITE",
+	} {
+		if d := p.Evaluate("execute_sql", map[string]interface{}{"sql": sql}); d.Action != Deny || d.Rule != "protect-orders" {
+			t.Errorf("%s: expected deny by protect-orders, got %s", sql, d)
+		}
+	}
+}
+
+// TestIncludeDefaults tests disabling built-in rules
+func TestIncludeDefaults(t *testing.T) {
+	file := &File{RuleSet: RuleSet{IncludeDefaults: boolPtr(false)}}
+	p, err := New(file, "")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if d := p.Evaluate("execute_sql", map[string]interface{}{"sql": "SELECT 1"}); d.Action != Confirm || d.Matched() {
+		t.Errorf("Expected unmatched confirm without defaults, got %s", d)
+	}
+}
+
+// TestNew_InvalidRules tests validation of rule actions and SQL classes
+func TestNew_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"invalid action", Rule{Action: "block"}},
+		{"invalid sql class", Rule{Action: Deny, SQLClasses: []string{"writes"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&File{RuleSet: RuleSet{Rules: []Rule{tt.rule}}}, "")
+			if err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+// TestLoadFile tests parsing policy.yaml
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("missing file", func(t *testing.T) {
+		file, err := LoadFile(filepath.Join(dir, "missing.yaml"))
+		if err != nil || file != nil {
+			t.Errorf("Expected nil file and no error, got %v, %v", file, err)
+		}
+	})
+
+	t.Run("parses rules and sources", func(t *testing.T) {
+		path := filepath.Join(dir, "policy.yaml")
+		content := `
+rules:
+  - name: no-truncate
+    action: DENY
+    statements: [TRUNCATE]
+sources:
+  prod:
+    include_defaults: false
+    rules:
+      - action: confirm
+        tools: [execute_sql]
+`
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write policy file: %v", err)
+		}
+		file, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() failed: %v", err)
+		}
+		p, err := New(file, "prod")
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if d := p.Evaluate("execute_sql", map[string]interface{}{"sql": "TRUNCATE t"}); d.Action != Confirm || d.Origin != OriginSource {
+			t.Errorf("Expected source rule to match first, got %s", d)
+		}
+		if len(p.Rules()) != 2 {
+			t.Errorf("Expected defaults to be excluded for prod, got %d rules", len(p.Rules()))
+		}
+		p, err = New(file, "")
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if d := p.Evaluate("execute_sql", map[string]interface{}{"sql": "TRUNCATE t"}); d.Action != Deny {
+			t.Errorf("Expected uppercase action to be accepted, got %s", d)
+		}
+	})
+}
+
+// TestRestrictiveAttachedSource tests that a broken policy file restricts attached sources too
+func TestRestrictiveAttachedSource(t *testing.T) {
+	decision := restrictive("dev").Evaluate("execute_sql", map[string]interface{}{"sql": "SELECT 1", "source": "prod"})
+	if decision.Action != Confirm {
+		t.Errorf("Expected confirm, got %s", decision)
+	}
+}
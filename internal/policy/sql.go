@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+func isValidSQLClass(class string) bool {
+	switch sqlparse.Class(strings.ToLower(class)) {
+	case sqlparse.ClassRead, sqlparse.ClassDML, sqlparse.ClassDDL, sqlparse.ClassDCL, sqlparse.ClassOther:
+		return true
+	}
+	return false
+}
+
+// sqlStatement is the rule-matching view of one SQL statement
+type sqlStatement struct {
+	words  []string // Leading keywords of the main statement, uppercased
+	class  string
+	tables []string
+}
+
+// startsWith reports whether the statement begins with the given keywords (e.g. "CREATE TABLE")
+func (s *sqlStatement) startsWith(pattern string) bool {
+	want := strings.Fields(strings.ToUpper(pattern))
+	if len(want) == 0 || len(want) > len(s.words) {
+		return false
+	}
+	for i, w := range want {
+		if !globMatch(w, s.words[i], false) {
+			return false
+		}
+	}
+	return true
+}
+
+// analyzeSQL parses SQL under every supported dialect, since the policy does not know the target database
+// Allow rules must then match the statements seen by every dialect and deny/confirm rules any of them
+func analyzeSQL(sql string) []sqlStatement {
+	parsed := sqlparse.ParseConservative(sql)
+	statements := make([]sqlStatement, 0, len(parsed))
+	for _, st := range parsed {
+		statements = append(statements, sqlStatement{words: st.Keywords, class: string(st.Class), tables: st.Tables})
+	}
+	return statements
+}
@@ -23,6 +23,7 @@ const (
 	MySQLPatchFile      = "mysql.md"
 	PostgreSQLPatchFile = "postgresql.md"
 	SeekDBPatchFile     = "seekdb.md"
+	FilesPatchFile      = "files.md"
 )
 
 // Loader manages loading and initialization of prompt templates
@@ -144,6 +145,7 @@ func (l *Loader) loadPrompts() error {
 		MySQLPatchFile,
 		PostgreSQLPatchFile,
 		SeekDBPatchFile,
+		FilesPatchFile,
 	}
 
 	for _, filename := range patchFiles {
@@ -249,7 +251,7 @@ func (l *Loader) GetDatabaseModeBasePrompt(databaseType, schemaContext string) s
 	prompt = strings.ReplaceAll(prompt, "{{SCHEMA_CONTEXT}}", schemaContext)
 
 	// Append database-specific syntax patch based on database type
-	// Note: databaseType comes from Source.GetDatabaseType() which returns "MySQL", "PostgreSQL", "seekdb"
+	// Note: databaseType comes from Source.GetDatabaseType() which returns "MySQL", "PostgreSQL", "seekdb", "Files"
 	var patchFile string
 	switch strings.ToLower(databaseType) {
 	case "mysql":
@@ -258,6 +260,8 @@ func (l *Loader) GetDatabaseModeBasePrompt(databaseType, schemaContext string) s
 		patchFile = PostgreSQLPatchFile
 	case "seekdb":
 		patchFile = SeekDBPatchFile
+	case "files":
+		patchFile = FilesPatchFile
 	default:
 		// Default to MySQL patch for unknown types (backward compatibility)
 		patchFile = MySQLPatchFile
@@ -425,10 +429,11 @@ You are a helpful AI assistant for database queries and related tasks.
 
 <POLICY>
 - Use execute_sql for database queries. Do not use execute_command to run mysql/psql.
+- The schema context lists table names only. Use describe_table (and sample_rows or column_values when values matter) before writing SQL against tables whose columns you do not know.
 - Respect engine-specific syntax differences. Database-specific syntax guidance is provided in separate sections.
 - If a request is not a database query, use the appropriate non-SQL tools.
 - When unsure about syntax, rely on schema context or ask a clarifying question.
-- **CRITICAL**: Before generating new SQL queries, check conversation history for recent query results. If the user requests visualization (chart/table) and recent query results are available, use render_chart or render_table with the existing data instead of generating new SQL.
+- **CRITICAL**: Before generating new SQL queries, check conversation history for recent query results. If the user requests visualization (chart/table) and recent query results are available, call render_chart or render_table with the result_id of that execute_sql result instead of generating new SQL or copying rows. For follow-ups on fetched results (top N, filter, group, pivot, computed columns, joining two results), use transform_result instead of querying the database again.
 - Only generate new SQL queries if the user explicitly requests different data or if no recent query results are available.
 - **CRITICAL**: You must determine whether the user's request requires tool execution or just text response. If the user's request requires executing database operations (querying, modifying data, creating/deleting tables, etc.), you MUST call execute_sql tool. Do NOT describe what you will do in text - actually call the tool. Do NOT say "I will execute", "Let me verify", "I'll first check", or "Stand by while I execute" - just call the tool directly. Do NOT pre-verify or check state before executing - execute first, handle errors if they occur.
 - **CRITICAL**: Do NOT claim operations succeeded unless you actually called execute_sql tool and received success status. Do NOT return text saying "successfully dropped" or "completed" without actually calling the tool. You MUST call execute_sql tool to execute database operations - describing actions in text is NOT execution.
@@ -563,8 +568,14 @@ When a tool execution fails (AFTER execution, not before):
 
 <TOOLS>
 - execute_sql: **MANDATORY TOOL CALL**: Execute SQL queries against the database. When user requests database operations (SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, SHOW, etc.), you MUST call this tool. Do NOT describe actions in text - call the tool directly.
-- render_table: Format query results as a table. **PRIORITY**: Check conversation history for recent query results first.
-- render_chart: **MANDATORY**: When user requests chart visualization, you MUST call this tool. Do NOT return text descriptions or JSON. Check conversation history for recent query results first.
+- list_tables: List tables and views (with a name filter on large databases).
+- describe_table: Columns, keys, indexes and comments of a table.
+- sample_rows: A few rows of a table, with sensitive values masked.
+- column_values: Distinct values of a column with their counts.
+- render_table: Format query results as a table. **PRIORITY**: Pass the result_id of a recent execute_sql result (with optional columns, sort and limit) instead of copying rows.
+- render_chart: **MANDATORY**: When user requests chart visualization, you MUST call this tool. Do NOT return text descriptions or JSON. Pass the result_id of a recent execute_sql result instead of copying rows.
+- transform_result: Filter, sort, limit, group, pivot/unpivot, compute columns or join stored results locally by result_id. The outcome is displayed and stored under a new result_id.
+- join_results: Run a SELECT with joins, filters and aggregates across stored results by result_id, e.g. fetched from different sources; each result is a table.
 - execute_command: System operations (install, setup, configuration). Not for database queries.
 - http_request: Make HTTP requests.
 - file_operations: Read/write files.
@@ -608,6 +619,20 @@ usage: "Appended to database-base.md when database type is seekdb"
 - Use SHOW TABLES; or SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE();
 - Use DATABASE() function to get current database name.
 </SEEKDB_SYNTAX>
+`
+
+	// File source patch: the embedded engine only supports a SELECT subset
+	filesPatch := `---
+description: "File source syntax guidance patch"
+usage: "Appended to database-base.md when the source is a set of local data files"
+---
+
+<FILES_SYNTAX>
+- Each table is a local CSV, TSV, JSON or Parquet file loaded in memory; column types (BIGINT, DOUBLE, BOOLEAN, DATE, TIMESTAMP, VARCHAR) are inferred from the data or taken from the Parquet schema, and nested Parquet columns are JSON text. Dates and timestamps are ISO text.
+- The data is read-only: only SELECT (with WITH, JOIN, LEFT JOIN, GROUP BY, HAVING, ORDER BY, LIMIT/OFFSET, UNION, subqueries) is supported. There is no information_schema; use list_tables and describe_table.
+- Quote identifiers with double quotes ("order date"). Table names are lower-case file names with other characters replaced by underscores.
+- Not supported: RIGHT/FULL JOIN, window functions (OVER), EXISTS, correlated subqueries, INTERVAL. Use DATE_TRUNC('month', col), EXTRACT(YEAR FROM col), DATEDIFF(a, b) or TO_CHAR(col, 'YYYY-MM') for dates.
+</FILES_SYNTAX>
 `
 
 	// Default common prompt with YAML frontmatter (used by both modes)
@@ -639,6 +664,7 @@ When tool execution fails:
 		MySQLPatchFile:         mysqlPatch,
 		PostgreSQLPatchFile:    postgresqlPatch,
 		SeekDBPatchFile:        seekdbPatch,
+		FilesPatchFile:         filesPatch,
 	}
 }
 
@@ -679,6 +705,7 @@ func (l *Loader) getUserPromptHashes() map[string]string {
 		MySQLPatchFile,
 		PostgreSQLPatchFile,
 		SeekDBPatchFile,
+		FilesPatchFile,
 	}
 
 	for _, filename := range files {
@@ -0,0 +1,178 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// protectedAssignments are variables a command line may not set in allowlist-only mode,
+// since they change which binary runs or inject code into it
+var protectedAssignments = map[string]bool{
+	"PATH": true, "ENV": true, "BASH_ENV": true, "IFS": true, "SHELLOPTS": true, "BASHOPTS": true, "CDPATH": true,
+}
+
+// Check verifies a command line against the allowlist when allowlist-only mode is on
+// The command line may be a list of simple commands joined by |, &&, || or ;, each starting with an allowed
+// command name; substitutions, redirections, subshells and background jobs are refused because they could
+// run or write things the allowlist does not cover
+func (s Settings) Check(commandLine string) error {
+	if !s.AllowlistOnly {
+		return nil
+	}
+	if len(s.AllowedCommands) == 0 {
+		return &DeniedError{Reason: "allowlist-only mode is on and sandbox.allowed_commands is empty"}
+	}
+	allowed := make(map[string]bool, len(s.AllowedCommands))
+	for _, name := range s.AllowedCommands {
+		allowed[name] = true
+	}
+
+	commands, err := splitCommandLine(commandLine)
+	if err != nil {
+		return &DeniedError{Reason: err.Error()}
+	}
+	if len(commands) == 0 {
+		return &DeniedError{Reason: "empty command"}
+	}
+	for _, words := range commands {
+		i := 0
+		for ; i < len(words); i++ {
+			name, _, ok := strings.Cut(words[i], "=")
+			if !ok || !isVariableName(name) {
+				break
+			}
+			if protectedAssignments[name] || strings.HasPrefix(name, "LD_") || strings.HasPrefix(name, "DYLD_") {
+				return &DeniedError{Reason: fmt.Sprintf("setting %s is not allowed in allowlist-only mode", name)}
+			}
+		}
+		if i == len(words) {
+			continue
+		}
+		name := words[i]
+		if strings.Contains(name, "/") {
+			return &DeniedError{Reason: fmt.Sprintf("'%s' is a path; allowlist-only mode runs commands by name only", name)}
+		}
+		if strings.ContainsAny(name, "*?[$~") {
+			return &DeniedError{Reason: fmt.Sprintf("command name '%s' contains expansion characters", name)}
+		}
+		if !allowed[name] {
+			return &DeniedError{Reason: fmt.Sprintf("'%s' is not in sandbox.allowed_commands (allowed: %s)", name, strings.Join(s.AllowedCommands, ", "))}
+		}
+	}
+	return nil
+}
+
+// splitCommandLine splits a shell command line into the words of each simple command, removing quotes
+// It returns an error for shell constructs that allowlist-only mode refuses
+func splitCommandLine(line string) ([][]string, error) {
+	var commands [][]string
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch {
+				case runes[i] == '`' || (runes[i] == '$' && i+1 < len(runes) && runes[i+1] == '('):
+					return nil, fmt.Errorf("command substitution is not allowed in allowlist-only mode")
+				case runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]):
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					inWord = true
+					word.WriteRune(runes[i])
+				}
+			}
+		case r == '`' || (r == '$' && i+1 < len(runes) && runes[i+1] == '('):
+			return nil, fmt.Errorf("command substitution is not allowed in allowlist-only mode")
+		case r == '<' || r == '>':
+			return nil, fmt.Errorf("redirection is not allowed in allowlist-only mode")
+		case r == '(' || r == ')':
+			return nil, fmt.Errorf("subshells are not allowed in allowlist-only mode")
+		case r == '&':
+			if i+1 < len(runes) && runes[i+1] == '&' {
+				i++
+				endCommand()
+				continue
+			}
+			return nil, fmt.Errorf("background jobs are not allowed in allowlist-only mode")
+		case r == '|' || r == ';' || r == '\n':
+			if r == '|' && i+1 < len(runes) && runes[i+1] == '|' {
+				i++
+			}
+			endCommand()
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '#' && !inWord:
+			// Comment until end of line
+			end := indexRune(runes, i, '\n')
+			if end < 0 {
+				end = len(runes)
+			}
+			i = end - 1
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	endCommand()
+	return commands, nil
+}
+
+func indexRune(runes []rune, from int, target rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+	return -1
+}
+
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
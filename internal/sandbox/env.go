@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"strings"
+)
+
+// secretPrefixes are prefixes of cloud provider and API variables removed from the sandbox environment
+var secretPrefixes = []string{
+	"AWS_", "AZURE_", "GOOGLE_", "GCP_", "GCLOUD_", "ALIBABA_CLOUD_", "ALICLOUD_", "OCI_",
+	"OPENAI_", "ANTHROPIC_", "DEEPSEEK_", "DASHSCOPE_", "HF_", "HUGGINGFACE_",
+	"GITHUB_", "GH_", "GITLAB_", "VAULT_", "NPM_", "DIGITALOCEAN_", "HEROKU_", "CLOUDFLARE_",
+}
+
+// secretMarkers are name fragments of variables that typically hold credentials
+var secretMarkers = []string{
+	"API_KEY", "APIKEY", "ACCESS_KEY", "SECRET", "TOKEN", "PASSWORD", "PASSWD", "CREDENTIAL",
+	"PRIVATE_KEY", "AUTH",
+}
+
+// secretNames are exact names of variables removed from the sandbox environment
+var secretNames = map[string]bool{
+	"MYSQL_PWD":      true,
+	"PGPASSFILE":     true,
+	"PGSERVICEFILE":  true,
+	"DATABASE_URL":   true,
+	"KUBECONFIG":     true,
+	"SSH_AUTH_SOCK":  true,
+	"SSH_ASKPASS":    true,
+	"GIT_ASKPASS":    true,
+	"GPG_AGENT_INFO": true,
+	"DOCKER_CONFIG":  true,
+	"NETRC":          true,
+}
+
+// isSecret reports whether an environment variable name looks like it holds a credential
+func isSecret(name string) bool {
+	upper := strings.ToUpper(name)
+	if secretNames[upper] {
+		return true
+	}
+	for _, prefix := range secretPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	for _, marker := range secretMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return strings.HasSuffix(upper, "_KEY") || strings.HasSuffix(upper, "_PWD")
+}
+
+// scrubEnv removes variables that look like credentials, except those listed in passthrough
+func scrubEnv(environ []string, passthrough []string) []string {
+	keep := make(map[string]bool, len(passthrough))
+	for _, name := range passthrough {
+		keep[name] = true
+	}
+	result := make([]string, 0, len(environ))
+	for _, entry := range environ {
+		name, _, ok := strings.Cut(entry, "=")
+		if !ok || (isSecret(name) && !keep[name]) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// setEnv replaces or appends a variable
+func setEnv(environ []string, name, value string) []string {
+	for i, entry := range environ {
+		if strings.HasPrefix(entry, name+"=") {
+			environ[i] = name + "=" + value
+			return environ
+		}
+	}
+	return append(environ, name+"="+value)
+}
@@ -0,0 +1,248 @@
+// Package sandbox confines commands run by the execute_command tool: an optional command allowlist,
+// a scrubbed environment, a confined working directory, resource limits and, on Linux,
+// namespaces and Landlock filesystem rules
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+const (
+	// DefaultCPUSeconds is the CPU time limit of a sandboxed command
+	DefaultCPUSeconds = 300
+
+	// DefaultMemoryMB is the address space limit of each sandboxed process
+	DefaultMemoryMB = 4096
+
+	// DefaultMaxOutputKB is the output size after which a sandboxed command is killed
+	DefaultMaxOutputKB = 1024
+
+	// DefaultMaxProcesses is the process limit of a sandboxed command
+	DefaultMaxProcesses = 256
+)
+
+// helperArg makes the aiq binary act as the sandbox helper (see Main)
+const helperArg = "__aiq_sandbox_exec"
+
+// Settings are the effective sandbox settings
+type Settings struct {
+	Enabled         bool
+	AllowlistOnly   bool
+	AllowedCommands []string
+	EnvPassthrough  []string
+	WorkDir         string
+	ReadPaths       []string
+	WritePaths      []string
+	CPUSeconds      int
+	MemoryMB        int
+	MaxOutputBytes  int
+	MaxProcesses    int
+	IsolateNetwork  bool
+	Namespaces      bool
+	Landlock        bool
+}
+
+// Load reads the sandbox section of config.yaml, using defaults for unset values
+func Load() Settings {
+	cfg, err := config.Load()
+	if err != nil {
+		return FromConfig(config.SandboxConfig{})
+	}
+	return FromConfig(cfg.Sandbox)
+}
+
+// FromConfig converts the sandbox section of config.yaml to settings
+func FromConfig(c config.SandboxConfig) Settings {
+	s := Settings{
+		Enabled:         c.Enabled,
+		AllowlistOnly:   c.AllowlistOnly,
+		AllowedCommands: c.AllowedCommands,
+		EnvPassthrough:  c.EnvPassthrough,
+		WorkDir:         c.WorkDir,
+		ReadPaths:       c.ReadPaths,
+		WritePaths:      c.WritePaths,
+		CPUSeconds:      DefaultCPUSeconds,
+		MemoryMB:        DefaultMemoryMB,
+		MaxOutputBytes:  DefaultMaxOutputKB * 1024,
+		MaxProcesses:    DefaultMaxProcesses,
+		IsolateNetwork:  c.IsolateNetwork,
+		Namespaces:      !c.DisableNamespaces,
+		Landlock:        !c.DisableLandlock,
+	}
+	if c.CPUSeconds > 0 {
+		s.CPUSeconds = c.CPUSeconds
+	}
+	if c.MemoryMB > 0 {
+		s.MemoryMB = c.MemoryMB
+	}
+	if c.MaxOutputKB > 0 {
+		s.MaxOutputBytes = c.MaxOutputKB * 1024
+	}
+	if c.MaxProcesses > 0 {
+		s.MaxProcesses = c.MaxProcesses
+	}
+	return s
+}
+
+// DeniedError is returned for commands the sandbox refuses to run
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("command denied by sandbox: %s (sandbox settings are in the sandbox section of ~/.aiq/config/config.yaml)", e.Reason)
+}
+
+// Report describes the protections applied to a command; it is included in the tool result
+// so the LLM can tell sandbox restrictions from ordinary failures
+type Report struct {
+	WorkDir         string   `json:"work_dir"`
+	AllowlistOnly   bool     `json:"allowlist_only"`
+	Landlock        bool     `json:"landlock"`   // Writes confined to the work dir, home directory not readable
+	Namespaces      bool     `json:"namespaces"` // Separate user, PID, IPC and UTS namespaces
+	NetworkIsolated bool     `json:"network_isolated"`
+	Limits          string   `json:"limits"`
+	Unavailable     []string `json:"unavailable,omitempty"` // Protections that could not be applied, with the reason
+}
+
+// helperSpec is passed from Command to the helper process as its first argument
+type helperSpec struct {
+	CPUSeconds   int      `json:"cpu_seconds"`
+	MemoryBytes  uint64   `json:"memory_bytes"`
+	MaxProcesses int      `json:"max_processes"`
+	Landlock     bool     `json:"landlock"`
+	ReadPaths    []string `json:"read_paths,omitempty"`
+	WritePaths   []string `json:"write_paths,omitempty"`
+}
+
+// Command prepares a command that runs script with shell inside the sandbox
+// workingDir must resolve to the sandbox work dir or a directory below it; relative paths are taken from the work dir
+func (s Settings) Command(shell, script, workingDir string) (*exec.Cmd, *Report, error) {
+	root, err := s.workDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir, err := confine(root, workingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to locate the aiq executable for the sandbox helper: %w", err)
+	}
+
+	report := &Report{
+		WorkDir:       root,
+		AllowlistOnly: s.AllowlistOnly,
+		Limits: fmt.Sprintf("cpu %ds, memory %d MB, output %d KB, processes %d",
+			s.CPUSeconds, s.MemoryMB, s.MaxOutputBytes/1024, s.MaxProcesses),
+	}
+	spec := helperSpec{
+		CPUSeconds:   s.CPUSeconds,
+		MemoryBytes:  uint64(s.MemoryMB) * 1024 * 1024,
+		MaxProcesses: s.MaxProcesses,
+	}
+
+	tmpDir := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create sandbox temp directory: %w", err)
+	}
+	env := scrubEnv(os.Environ(), s.EnvPassthrough)
+	env = setEnv(env, "HOME", root)
+	env = setEnv(env, "TMPDIR", tmpDir)
+
+	cmd := exec.Command(self, helperArg, "", shell, "-c", script)
+	cmd.Dir = dir
+	cmd.Env = env
+	if err := s.isolate(cmd, root, shell, &spec, report); err != nil {
+		return nil, nil, err
+	}
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal sandbox spec: %w", err)
+	}
+	cmd.Args[2] = string(specJSON)
+	return cmd, report, nil
+}
+
+// workDir returns the sandbox work dir with symlinks resolved, creating it if needed
+func (s Settings) workDir() (string, error) {
+	root := s.WorkDir
+	if root == "" {
+		var err error
+		root, err = config.GetSandboxDir()
+		if err != nil {
+			return "", err
+		}
+	}
+	root = expandHome(root)
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve sandbox directory: %w", err)
+	}
+	return resolved, nil
+}
+
+// confine resolves a requested working directory and checks that it stays inside root
+func confine(root, requested string) (string, error) {
+	if requested == "" {
+		return root, nil
+	}
+	path := expandHome(requested)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", &DeniedError{Reason: fmt.Sprintf("working directory %s does not exist inside the sandbox directory %s", requested, root)}
+	}
+	if !within(root, resolved) {
+		return "", &DeniedError{Reason: fmt.Sprintf("working directory %s is outside the sandbox directory %s", requested, root)}
+	}
+	return resolved, nil
+}
+
+// within reports whether path is root or below it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// Main runs the sandbox helper when the process was started as one and never returns in that case
+// It must be called at the start of main, before any other initialization
+func Main() {
+	if len(os.Args) < 3 || os.Args[1] != helperArg {
+		return
+	}
+	var spec helperSpec
+	if err := json.Unmarshal([]byte(os.Args[2]), &spec); err != nil || len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "aiq sandbox: invalid helper arguments")
+		os.Exit(126)
+	}
+	// runHelper only returns when the command could not be started
+	err := runHelper(spec, os.Args[3:])
+	fmt.Fprintf(os.Stderr, "aiq sandbox: %v\n", err)
+	os.Exit(126)
+}
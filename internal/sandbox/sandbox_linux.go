@@ -0,0 +1,218 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// rlimitNproc is RLIMIT_NPROC on the common Linux architectures (not defined by the syscall package)
+const rlimitNproc = 6
+
+// Landlock system calls and flags (include/uapi/linux/landlock.h); the syscall numbers are the same on all architectures
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1
+	landlockRulePathBeneath      = 1
+
+	accessExecute    = 1 << 0
+	accessWriteFile  = 1 << 1
+	accessReadFile   = 1 << 2
+	accessReadDir    = 1 << 3
+	accessRemoveDir  = 1 << 4
+	accessRemoveFile = 1 << 5
+	accessMakeChar   = 1 << 6
+	accessMakeDir    = 1 << 7
+	accessMakeReg    = 1 << 8
+	accessMakeSock   = 1 << 9
+	accessMakeFifo   = 1 << 10
+	accessMakeBlock  = 1 << 11
+	accessMakeSym    = 1 << 12
+	accessRefer      = 1 << 13 // ABI 2
+	accessTruncate   = 1 << 14 // ABI 3
+
+	accessABI1 = accessExecute | accessWriteFile | accessReadFile | accessReadDir | accessRemoveDir | accessRemoveFile |
+		accessMakeChar | accessMakeDir | accessMakeReg | accessMakeSock | accessMakeFifo | accessMakeBlock | accessMakeSym
+	accessRead = accessExecute | accessReadFile | accessReadDir
+	accessFile = accessExecute | accessWriteFile | accessReadFile | accessTruncate
+
+	prSetNoNewPrivs = 38
+	oPath           = 0x200000
+)
+
+// systemReadPaths are readable (and executable) under Landlock; the home directory is deliberately not included
+var systemReadPaths = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32", "/etc", "/opt", "/srv",
+	"/proc", "/sys", "/dev", "/run", "/var", "/tmp", "/nix", "/snap",
+}
+
+const namespaceFlags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+var (
+	namespaceOnce  sync.Once
+	namespaceError error
+)
+
+// isolate configures namespaces for the helper process and the Landlock rules it applies
+func (s Settings) isolate(cmd *exec.Cmd, root, shell string, spec *helperSpec, report *Report) error {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if s.Namespaces {
+		if err := probeNamespaces(shell); err != nil {
+			report.Unavailable = append(report.Unavailable, fmt.Sprintf("namespaces: %v", err))
+		} else {
+			// Map the user to itself so files keep their ownership and the command gains no capabilities
+			attr.Cloneflags = namespaceFlags
+			if s.IsolateNetwork {
+				attr.Cloneflags |= syscall.CLONE_NEWNET
+			}
+			attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+			attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+			attr.GidMappingsEnableSetgroups = false
+			report.Namespaces = true
+			report.NetworkIsolated = s.IsolateNetwork
+		}
+	}
+	if s.IsolateNetwork && !report.NetworkIsolated {
+		return &DeniedError{Reason: "sandbox.isolate_network is set but network namespaces are not available on this system"}
+	}
+	cmd.SysProcAttr = attr
+
+	if s.Landlock {
+		if landlockABI() < 1 {
+			report.Unavailable = append(report.Unavailable, "landlock: not supported or not enabled in this kernel")
+		} else {
+			spec.Landlock = true
+			spec.ReadPaths = readPaths(s.ReadPaths)
+			spec.WritePaths = append([]string{root, "/dev"}, expandPaths(s.WritePaths)...)
+			report.Landlock = true
+		}
+	}
+	return nil
+}
+
+// probeNamespaces checks once whether unprivileged user namespaces can be created (they are often disabled)
+func probeNamespaces(shell string) error {
+	namespaceOnce.Do(func() {
+		cmd := exec.Command(shell, "-c", "exit 0")
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:                 namespaceFlags | syscall.CLONE_NEWNET,
+			UidMappings:                []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+			GidMappings:                []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+			GidMappingsEnableSetgroups: false,
+		}
+		if err := cmd.Run(); err != nil {
+			namespaceError = fmt.Errorf("unprivileged user namespaces are not available (%v)", err)
+		}
+	})
+	return namespaceError
+}
+
+// readPaths returns the system paths, the PATH directories outside the home directory and the configured extra paths
+func readPaths(extra []string) []string {
+	paths := append([]string{}, systemReadPaths...)
+	home, _ := os.UserHomeDir()
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.IsAbs(dir) && dir != "/" && dir != home {
+			paths = append(paths, dir)
+		}
+	}
+	return append(paths, expandPaths(extra)...)
+}
+
+func expandPaths(paths []string) []string {
+	result := make([]string, len(paths))
+	for i, path := range paths {
+		result[i] = expandHome(path)
+	}
+	return result
+}
+
+// landlockABI returns the Landlock ABI version of the running kernel, or 0 if Landlock is unavailable
+func landlockABI() int {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// landlockPathBeneathAttr matches the packed kernel struct: the kernel reads only the first 12 bytes
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// restrict sets no_new_privs and applies the Landlock rules: read and execute on the read paths, full access
+// on the write paths, nothing elsewhere
+func restrict(spec helperSpec) error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to set no_new_privs: %w", errno)
+	}
+	if !spec.Landlock {
+		return nil
+	}
+
+	abi := landlockABI()
+	handled := uint64(accessABI1)
+	if abi >= 2 {
+		handled |= accessRefer
+	}
+	if abi >= 3 {
+		handled |= accessTruncate
+	}
+	attr := landlockRulesetAttr{handledAccessFS: handled}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create Landlock ruleset: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	for _, path := range spec.ReadPaths {
+		if err := addLandlockRule(int(fd), path, accessRead&handled); err != nil {
+			return err
+		}
+	}
+	for _, path := range spec.WritePaths {
+		if err := addLandlockRule(int(fd), path, handled); err != nil {
+			return err
+		}
+	}
+	if _, _, errno := syscall.Syscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to apply Landlock rules: %w", errno)
+	}
+	return nil
+}
+
+// addLandlockRule grants access below path; missing paths are skipped
+func addLandlockRule(rulesetFd int, path string, access uint64) error {
+	pathFd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil
+	}
+	defer syscall.Close(pathFd)
+
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(pathFd, &stat); err != nil {
+		return nil
+	}
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		// Directory rights are rejected for files
+		access &= accessFile
+	}
+	rule := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(pathFd)}
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to add Landlock rule for %s: %w", path, errno)
+	}
+	return nil
+}
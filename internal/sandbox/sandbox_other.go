@@ -0,0 +1,30 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// isolate refuses to run commands: the sandbox relies on rlimits and exec, which this platform does not have
+func (s Settings) isolate(cmd *exec.Cmd, root, shell string, spec *helperSpec, report *Report) error {
+	return &DeniedError{Reason: "the sandbox is not supported on this platform; disable sandbox.enabled to run commands"}
+}
+
+func runHelper(spec helperSpec, argv []string) error {
+	return fmt.Errorf("the sandbox is not supported on this platform")
+}
+
+// Kill stops a sandboxed command
+func Kill(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// LimitExceeded describes the resource limit that ended a command, or returns "" if none did
+func (s Settings) LimitExceeded(err error) string {
+	return ""
+}
@@ -0,0 +1,32 @@
+//go:build unix && !linux
+
+package sandbox
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// rlimitNproc is 0 because the process limit is only applied on Linux
+const rlimitNproc = 0
+
+// isolate runs the helper in its own process group; namespaces and Landlock are Linux-only
+func (s Settings) isolate(cmd *exec.Cmd, root, shell string, spec *helperSpec, report *Report) error {
+	if s.IsolateNetwork {
+		return &DeniedError{Reason: "sandbox.isolate_network requires Linux network namespaces"}
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if s.Namespaces {
+		report.Unavailable = append(report.Unavailable, "namespaces: Linux only")
+	}
+	if s.Landlock {
+		report.Unavailable = append(report.Unavailable, "landlock: Linux only")
+	}
+	report.Unavailable = append(report.Unavailable, "process limit: Linux only")
+	return nil
+}
+
+// restrict has nothing to apply outside Linux
+func restrict(spec helperSpec) error {
+	return nil
+}
@@ -0,0 +1,152 @@
+package sandbox
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// TestMain lets the test binary act as the sandbox helper, as the aiq binary does
+func TestMain(m *testing.M) {
+	Main()
+	os.Exit(m.Run())
+}
+
+// TestCheck tests allowlist-only mode
+func TestCheck(t *testing.T) {
+	s := Settings{AllowlistOnly: true, AllowedCommands: []string{"ls", "grep", "echo"}}
+	tests := []struct {
+		name    string
+		command string
+		allowed bool
+	}{
+		{"allowed command", "ls -la", true},
+		{"pipeline of allowed commands", "ls | grep foo && echo done", true},
+		{"quoted arguments", `grep "a;b|c" 'x > y'`, true},
+		{"env assignment", "LC_ALL=C ls", true},
+		{"quotes removed from name", `l"s"`, true},
+		{"command not listed", "rm -rf /", false},
+		{"second command not listed", "ls; python3 -c 'print(1)'", false},
+		{"absolute path", "/bin/ls", false},
+		{"command substitution", "echo $(rm x)", false},
+		{"backticks in double quotes", "echo \"`id`\"", false},
+		{"redirection", "echo x > file", false},
+		{"subshell", "(rm x)", false},
+		{"background job", "ls & rm x", false},
+		{"PATH override", "PATH=/tmp ls", false},
+		{"LD_PRELOAD", "LD_PRELOAD=/tmp/x.so ls", false},
+		{"variable as command", "$SHELL -c id", false},
+		{"unterminated quote", "echo 'x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Check(tt.command)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected %q to be allowed, got %v", tt.command, err)
+			}
+			var denied *DeniedError
+			if !tt.allowed && !errors.As(err, &denied) {
+				t.Errorf("Expected %q to be denied, got %v", tt.command, err)
+			}
+		})
+	}
+
+	if err := (Settings{}).Check("rm -rf /"); err != nil {
+		t.Errorf("Expected no allowlist check when allowlist-only is off, got %v", err)
+	}
+	if err := (Settings{AllowlistOnly: true}).Check("ls"); err == nil {
+		t.Error("Expected denial with an empty allowlist")
+	}
+}
+
+// TestScrubEnv tests that credentials are removed from the environment
+func TestScrubEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin", "LANG=C", "AWS_SECRET_ACCESS_KEY=x", "OPENAI_API_KEY=x", "GITHUB_TOKEN=x",
+		"DB_PASSWORD=x", "MYSQL_PWD=x", "STRIPE_KEY=x", "SSH_AUTH_SOCK=/tmp/agent", "MY_TOKEN=keep",
+	}
+	got := scrubEnv(environ, []string{"MY_TOKEN"})
+	expected := []string{"PATH=/usr/bin", "LANG=C", "MY_TOKEN=keep"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+// TestConfine tests that working directories stay inside the sandbox directory
+func TestConfine(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	if dir, err := confine(root, "sub"); err != nil || dir != filepath.Join(root, "sub") {
+		t.Errorf("Expected relative path inside root to be allowed, got %q, %v", dir, err)
+	}
+	if dir, err := confine(root, ""); err != nil || dir != root {
+		t.Errorf("Expected the root by default, got %q, %v", dir, err)
+	}
+	for _, requested := range []string{outside, "..", "escape", "missing"} {
+		var denied *DeniedError
+		if _, err := confine(root, requested); !errors.As(err, &denied) {
+			t.Errorf("Expected %q to be denied, got %v", requested, err)
+		}
+	}
+}
+
+// TestCommand runs commands through the helper
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sandbox is not supported on Windows")
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-value")
+	root := t.TempDir()
+	s := FromConfig(config.SandboxConfig{Enabled: true, WorkDir: root})
+
+	run := func(script string) (string, error) {
+		cmd, _, err := s.Command("/bin/sh", script, "")
+		if err != nil {
+			t.Fatalf("Command() failed: %v", err)
+		}
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		err = cmd.Run()
+		return out.String(), err
+	}
+
+	out, err := run(`pwd; echo "key=$AWS_SECRET_ACCESS_KEY"; echo "home=$HOME"`)
+	if err != nil {
+		t.Fatalf("Command failed: %v: %s", err, out)
+	}
+	resolvedRoot, _ := filepath.EvalSymlinks(root)
+	if !strings.Contains(out, resolvedRoot+"\n") || !strings.Contains(out, "key=\n") || !strings.Contains(out, "home="+resolvedRoot) {
+		t.Errorf("Unexpected sandbox environment: %s", out)
+	}
+
+	_, report, err := s.Command("/bin/sh", "true", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Landlock {
+		outside := filepath.Join(t.TempDir(), "file")
+		if out, err := run("touch " + outside); err == nil {
+			t.Errorf("Expected writing outside the work dir to fail under Landlock: %s", out)
+		}
+		if out, err := run("touch inside"); err != nil {
+			t.Errorf("Expected writing inside the work dir to succeed: %v: %s", err, out)
+		}
+	}
+}
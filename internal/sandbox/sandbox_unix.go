@@ -0,0 +1,99 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// runHelper applies resource limits and platform restrictions to the helper process, then replaces it with the command
+// The thread is locked because Landlock and no_new_privs apply to the calling thread, which must be the one that execs
+func runHelper(spec helperSpec, argv []string) error {
+	runtime.LockOSThread()
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", argv[0], err)
+	}
+	if err := setLimits(spec); err != nil {
+		return err
+	}
+	if err := restrict(spec); err != nil {
+		return err
+	}
+	return fmt.Errorf("failed to start %s: %w", path, syscall.Exec(path, argv, os.Environ()))
+}
+
+// setLimits sets the CPU, address space and process rlimits (never above the current hard limits)
+func setLimits(spec helperSpec) error {
+	if spec.CPUSeconds > 0 {
+		// SIGXCPU at the soft limit, SIGKILL at the hard limit
+		cpu := uint64(spec.CPUSeconds)
+		if err := setLimit(syscall.RLIMIT_CPU, cpu, cpu+2); err != nil {
+			return fmt.Errorf("failed to set CPU limit: %w", err)
+		}
+	}
+	if spec.MemoryBytes > 0 {
+		if err := setLimit(syscall.RLIMIT_AS, spec.MemoryBytes, spec.MemoryBytes); err != nil {
+			return fmt.Errorf("failed to set memory limit: %w", err)
+		}
+	}
+	if spec.MaxProcesses > 0 && rlimitNproc != 0 {
+		if err := setLimit(rlimitNproc, uint64(spec.MaxProcesses), uint64(spec.MaxProcesses)); err != nil {
+			return fmt.Errorf("failed to set process limit: %w", err)
+		}
+	}
+	return nil
+}
+
+func setLimit(resource int, soft, hard uint64) error {
+	var current syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &current); err != nil {
+		return err
+	}
+	if hard > current.Max {
+		hard = current.Max
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
+
+// Kill stops a sandboxed command and every process it started
+func Kill(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	// The helper runs in its own process group (and PID namespace when available)
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// LimitExceeded describes the resource limit that ended a command, or returns "" if none did
+func (s Settings) LimitExceeded(err error) string {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+	// The shell reports a child killed by a signal as 128+signal; a process that ignores SIGXCPU
+	// (PID 1 in a namespace does) is killed at the hard limit instead
+	cpuTime := exitErr.UserTime() + exitErr.SystemTime()
+	if (status.Signaled() && status.Signal() == syscall.SIGXCPU) ||
+		(status.Exited() && status.ExitStatus() == 128+int(syscall.SIGXCPU)) ||
+		(status.Signaled() && status.Signal() == syscall.SIGKILL && s.CPUSeconds > 0 && cpuTime >= time.Duration(s.CPUSeconds)*time.Second) {
+		return fmt.Sprintf("CPU time limit of %d seconds exceeded (sandbox.cpu_seconds)", s.CPUSeconds)
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+// Package secrets stores named credentials (API tokens, passwords) used by tools without exposing them to the LLM
+// Secrets live in ~/.aiq/config/secrets.yaml, readable only by the user
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// EnvPrefix lets a secret be supplied by an environment variable (AIQ_SECRET_<NAME>), which takes precedence over the file
+const EnvPrefix = "AIQ_SECRET_"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// referencePattern matches ${secret:name} references in configuration values
+var referencePattern = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.-]+)\}`)
+
+// ValidateName checks that a secret name only uses letters, digits, '_', '.' and '-'
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '_', '.' or '-'", name)
+	}
+	return nil
+}
+
+func load() (map[string]string, string, error) {
+	path, err := config.GetSecretsFilePath()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get secrets file path: %w", err)
+	}
+	values := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, path, nil
+		}
+		return nil, "", fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, "", fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	return values, path, nil
+}
+
+func save(path string, values map[string]string) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// envName returns the environment variable that can override a secret
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// Get returns a secret value
+func Get(name string) (string, error) {
+	if value, ok := os.LookupEnv(envName(name)); ok {
+		return value, nil
+	}
+	values, _, err := load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q is not set (use: aiq secret set %s)", name, name)
+	}
+	return value, nil
+}
+
+// Set stores a secret value
+func Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	values, path, err := load()
+	if err != nil {
+		return err
+	}
+	values[name] = value
+	return save(path, values)
+}
+
+// Delete removes a secret
+func Delete(name string) error {
+	values, path, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[name]; !ok {
+		return fmt.Errorf("secret %q is not set", name)
+	}
+	delete(values, name)
+	return save(path, values)
+}
+
+// List returns the names of stored secrets, sorted
+func List() ([]string, error) {
+	values, _, err := load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Expand replaces ${secret:name} references in s and returns the secret values it used
+func Expand(s string) (string, []string, error) {
+	var used []string
+	var firstErr error
+	expanded := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := referencePattern.FindStringSubmatch(ref)[1]
+		value, err := Get(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ""
+		}
+		used = append(used, value)
+		return value
+	})
+	if firstErr != nil {
+		return "", nil, firstErr
+	}
+	return expanded, used, nil
+}
@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// TestStore tests setting, reading, listing and deleting secrets
+func TestStore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := Set("github_token", "ghp_123"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := Set("jira", "j-456"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := Set("bad name", "x"); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+
+	path, _ := config.GetSecretsFilePath()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected secrets file with mode 0600, got %v, %v", info, err)
+	}
+
+	if value, err := Get("github_token"); err != nil || value != "ghp_123" {
+		t.Errorf("Expected ghp_123, got %q, %v", value, err)
+	}
+	t.Setenv("AIQ_SECRET_GITHUB_TOKEN", "from-env")
+	if value, _ := Get("github_token"); value != "from-env" {
+		t.Errorf("Expected the environment to override the file, got %q", value)
+	}
+
+	names, err := List()
+	if err != nil || !reflect.DeepEqual(names, []string{"github_token", "jira"}) {
+		t.Errorf("Unexpected names %v, %v", names, err)
+	}
+	if err := Delete("jira"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := Get("jira"); err == nil {
+		t.Error("Expected an error for a deleted secret")
+	}
+}
+
+// TestExpand tests ${secret:name} references
+func TestExpand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := Set("token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	expanded, used, err := Expand("Bearer ${secret:token}")
+	if err != nil || expanded != "Bearer abc" || !reflect.DeepEqual(used, []string{"abc"}) {
+		t.Errorf("Unexpected expansion %q, %v, %v", expanded, used, err)
+	}
+	if _, _, err := Expand("${secret:missing}"); err == nil {
+		t.Error("Expected an error for a missing secret")
+	}
+	if expanded, _, _ := Expand("plain"); expanded != "plain" {
+		t.Errorf("Expected plain values unchanged, got %q", expanded)
+	}
+}
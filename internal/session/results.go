@@ -0,0 +1,167 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultResultStoreBytes is the default size limit of the result store (cell data, approximately)
+	DefaultResultStoreBytes = 2 << 20
+	// DefaultResultStoreEntries is the default maximum number of results kept
+	DefaultResultStoreEntries = 50
+)
+
+// StoredResult is a query result kept in the session so tools can refer to it by ID
+type StoredResult struct {
+	ID        string     `json:"id"`
+	Query     string     `json:"query,omitempty"`
+	Columns   []string   `json:"columns"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated,omitempty"` // Rows beyond the size limit were dropped
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// size approximates the memory a result uses by its cell data
+func (r *StoredResult) size() int {
+	n := len(r.Query)
+	for _, col := range r.Columns {
+		n += len(col)
+	}
+	for _, row := range r.Rows {
+		for _, cell := range row {
+			n += len(cell)
+		}
+	}
+	return n
+}
+
+// ResultStore keeps recent query results of a session, bounded by size and count
+// The oldest results are evicted first; it is safe for concurrent use
+type ResultStore struct {
+	mu         sync.Mutex
+	maxBytes   int
+	maxEntries int
+	next       int
+	entries    []*StoredResult
+}
+
+// NewResultStore creates an empty result store with the default limits
+func NewResultStore() *ResultStore {
+	return &ResultStore{maxBytes: DefaultResultStoreBytes, maxEntries: DefaultResultStoreEntries, next: 1}
+}
+
+// Add stores a result and returns its ID
+// A result larger than the whole store keeps only the rows that fit and is marked truncated
+func (s *ResultStore) Add(query string, columns []string, rows [][]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &StoredResult{
+		ID:        "r" + strconv.Itoa(s.next),
+		Query:     query,
+		Columns:   columns,
+		Rows:      rows,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.next++
+	if r.size() > s.maxBytes {
+		r.Rows = nil
+		size := r.size()
+		for _, row := range rows {
+			for _, cell := range row {
+				size += len(cell)
+			}
+			if size > s.maxBytes {
+				break
+			}
+			r.Rows = append(r.Rows, row)
+		}
+		r.Truncated = true
+	}
+	s.entries = append(s.entries, r)
+	s.evict()
+	return r.ID
+}
+
+// evict drops the oldest results until the store is within its limits; the newest result is always kept
+func (s *ResultStore) evict() {
+	total := 0
+	for _, r := range s.entries {
+		total += r.size()
+	}
+	for len(s.entries) > 1 && (total > s.maxBytes || len(s.entries) > s.maxEntries) {
+		total -= s.entries[0].size()
+		s.entries = s.entries[1:]
+	}
+}
+
+// Get returns the result with the given ID
+func (s *ResultStore) Get(id string) (*StoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id = strings.TrimSpace(id)
+	for _, r := range s.entries {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("result '%s' not found (it may have been evicted; run the query again)", id)
+}
+
+// Len returns the number of stored results
+func (s *ResultStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+type resultStoreJSON struct {
+	Next    int             `json:"next"`
+	Entries []*StoredResult `json:"entries"`
+}
+
+// MarshalJSON saves the stored results with the session
+func (s *ResultStore) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(resultStoreJSON{Next: s.next, Entries: s.entries})
+}
+
+// UnmarshalJSON restores the stored results of a saved session with the default limits
+func (s *ResultStore) UnmarshalJSON(data []byte) error {
+	var saved resultStoreJSON
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxBytes = DefaultResultStoreBytes
+	s.maxEntries = DefaultResultStoreEntries
+	s.entries = saved.Entries
+	s.next = saved.Next
+	// IDs are never reused, even if the saved counter is missing
+	for _, r := range s.entries {
+		if n, err := strconv.Atoi(strings.TrimPrefix(r.ID, "r")); err == nil && n >= s.next {
+			s.next = n + 1
+		}
+	}
+	if s.next < 1 {
+		s.next = 1
+	}
+	s.evict()
+	return nil
+}
+
+// GetResults returns the session's result store, creating it for sessions saved without one
+func (s *Session) GetResults() *ResultStore {
+	if s.Results == nil {
+		s.Results = NewResultStore()
+	}
+	return s.Results
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResultStoreAddGet(t *testing.T) {
+	store := NewResultStore()
+	id1 := store.Add("SELECT 1", []string{"a"}, [][]string{{"1"}})
+	id2 := store.Add("SELECT 2", []string{"b"}, [][]string{{"2"}})
+	if id1 == id2 {
+		t.Fatalf("Expected distinct IDs, got %s twice", id1)
+	}
+
+	r, err := store.Get(id2)
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", id2, err)
+	}
+	if r.Query != "SELECT 2" || r.Rows[0][0] != "2" {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+	if _, err := store.Get("r99"); err == nil {
+		t.Error("Expected an error for an unknown ID")
+	}
+}
+
+func TestResultStoreEviction(t *testing.T) {
+	store := NewResultStore()
+	store.maxBytes = 100
+	store.maxEntries = 3
+
+	first := store.Add("", []string{"c"}, [][]string{{strings.Repeat("x", 40)}})
+	store.Add("", []string{"c"}, [][]string{{strings.Repeat("x", 40)}})
+	last := store.Add("", []string{"c"}, [][]string{{strings.Repeat("x", 40)}})
+	if _, err := store.Get(first); err == nil {
+		t.Error("Expected the oldest result to be evicted when over the size limit")
+	}
+	if _, err := store.Get(last); err != nil {
+		t.Errorf("Expected the newest result to be kept: %v", err)
+	}
+
+	// A result larger than the store keeps the rows that fit
+	big := make([][]string, 10)
+	for i := range big {
+		big[i] = []string{strings.Repeat("y", 30)}
+	}
+	id := store.Add("", []string{"c"}, big)
+	r, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Expected the oversized result to be kept: %v", err)
+	}
+	if !r.Truncated || len(r.Rows) != 3 {
+		t.Errorf("Expected 3 rows and truncated, got %d rows, truncated=%v", len(r.Rows), r.Truncated)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected only the oversized result to remain, got %d", store.Len())
+	}
+}
+
+func TestResultStorePersistence(t *testing.T) {
+	sess := NewSession("test-source", "mysql")
+	id := sess.GetResults().Add("SELECT name FROM users", []string{"name"}, [][]string{{"alice"}, {"bob"}})
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := SaveSession(sess, path); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	loaded, err := LoadSession(path)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	r, err := loaded.GetResults().Get(id)
+	if err != nil {
+		t.Fatalf("Expected %s to be restored: %v", id, err)
+	}
+	if len(r.Rows) != 2 || r.Rows[1][0] != "bob" {
+		t.Errorf("Unexpected restored rows: %v", r.Rows)
+	}
+	// IDs continue after the restored ones
+	if next := loaded.GetResults().Add("", []string{"x"}, nil); next == id {
+		t.Errorf("Expected a new ID after restore, got %s again", next)
+	}
+}
+
+func TestResultStoreMissingInOldSessions(t *testing.T) {
+	var sess Session
+	if err := json.Unmarshal([]byte(`{"metadata":{"data_source":"s","database_type":"mysql"}}`), &sess); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if sess.GetResults() == nil || sess.GetResults().Len() != 0 {
+		t.Error("Expected an empty result store for sessions saved without one")
+	}
+}
@@ -18,6 +18,17 @@ type SessionMetadata struct {
 	LastUpdated  time.Time `json:"last_updated"`
 	DataSource   string    `json:"data_source"`
 	DatabaseType string    `json:"database_type"`
+	// AttachedSources are sources queried alongside DataSource (see /attach)
+	AttachedSources []string `json:"attached_sources,omitempty"`
+	// SourceHistory lists the sources and databases the session used, oldest first (see /source and /use)
+	SourceHistory []SourceChange `json:"source_history,omitempty"`
+}
+
+// SourceChange records a source and database the session started with or switched to
+type SourceChange struct {
+	Source   string    `json:"source"`
+	Database string    `json:"database,omitempty"`
+	At       time.Time `json:"at"`
 }
 
 // Session represents a conversation session
@@ -25,6 +36,7 @@ type Session struct {
 	Metadata    SessionMetadata   `json:"metadata"`
 	Messages    []Message         `json:"messages,omitempty"`     // Legacy format, for backward compatibility
 	RawMessages []json.RawMessage `json:"raw_messages,omitempty"` // Complete messages array (includes tool calls and results)
+	Results     *ResultStore      `json:"results,omitempty"`      // Query results tools refer to by result_id
 }
 
 // NewSession creates a new session with the given data source and database type
@@ -38,6 +50,7 @@ func NewSession(dataSource, databaseType string) *Session {
 			DatabaseType: databaseType,
 		},
 		Messages: make([]Message, 0),
+		Results:  NewResultStore(),
 	}
 }
 
@@ -46,6 +59,51 @@ func (s *Session) UpdateLastUpdated() {
 	s.Metadata.LastUpdated = time.Now().UTC()
 }
 
+// AttachSource records a source attached to the session
+func (s *Session) AttachSource(name string) {
+	for _, attached := range s.Metadata.AttachedSources {
+		if attached == name {
+			return
+		}
+	}
+	s.Metadata.AttachedSources = append(s.Metadata.AttachedSources, name)
+	s.UpdateLastUpdated()
+}
+
+// DetachSource removes a source from the attached sources
+func (s *Session) DetachSource(name string) {
+	for i, attached := range s.Metadata.AttachedSources {
+		if attached == name {
+			s.Metadata.AttachedSources = append(s.Metadata.AttachedSources[:i], s.Metadata.AttachedSources[i+1:]...)
+			s.UpdateLastUpdated()
+			return
+		}
+	}
+}
+
+// RecordSource makes name the session's source and adds it to the source history,
+// unless the source and database are already the current ones
+func (s *Session) RecordSource(name, databaseType, database string) {
+	s.Metadata.DataSource = name
+	s.Metadata.DatabaseType = databaseType
+	if n := len(s.Metadata.SourceHistory); n > 0 {
+		last := s.Metadata.SourceHistory[n-1]
+		if last.Source == name && last.Database == database {
+			return
+		}
+	}
+	s.Metadata.SourceHistory = append(s.Metadata.SourceHistory, SourceChange{Source: name, Database: database, At: time.Now().UTC()})
+	s.UpdateLastUpdated()
+}
+
+// LastDatabase returns the database the session last used on its source, or "" when the history does not say
+func (s *Session) LastDatabase() string {
+	if n := len(s.Metadata.SourceHistory); n > 0 && s.Metadata.SourceHistory[n-1].Source == s.Metadata.DataSource {
+		return s.Metadata.SourceHistory[n-1].Database
+	}
+	return ""
+}
+
 // GetTimestamp generates a timestamp string for session file naming
 // Format: YYYYMMDDHHMMSS (UTC)
 func GetTimestamp() string {
@@ -67,3 +67,42 @@ func TestGetTimestamp(t *testing.T) {
 		t.Errorf("Timestamp format invalid: %v", err)
 	}
 }
+
+func TestAttachSource(t *testing.T) {
+	sess := NewSession("primary", "mysql")
+	sess.AttachSource("warehouse")
+	sess.AttachSource("crm")
+	sess.AttachSource("warehouse")
+	if len(sess.Metadata.AttachedSources) != 2 {
+		t.Fatalf("Expected 2 attached sources without duplicates, got %v", sess.Metadata.AttachedSources)
+	}
+
+	sess.DetachSource("warehouse")
+	sess.DetachSource("missing")
+	if len(sess.Metadata.AttachedSources) != 1 || sess.Metadata.AttachedSources[0] != "crm" {
+		t.Errorf("Expected only crm after detaching, got %v", sess.Metadata.AttachedSources)
+	}
+}
+
+func TestRecordSource(t *testing.T) {
+	sess := NewSession("prod", "mysql")
+	sess.RecordSource("prod", "mysql", "sales")
+	sess.RecordSource("prod", "mysql", "sales")
+	sess.RecordSource("prod", "mysql", "billing")
+	sess.RecordSource("warehouse", "postgresql", "dw")
+	if len(sess.Metadata.SourceHistory) != 3 {
+		t.Fatalf("Expected 3 source changes without repeats, got %v", sess.Metadata.SourceHistory)
+	}
+	if sess.Metadata.DataSource != "warehouse" || sess.Metadata.DatabaseType != "postgresql" {
+		t.Errorf("Expected warehouse (postgresql) as current source, got %s (%s)", sess.Metadata.DataSource, sess.Metadata.DatabaseType)
+	}
+	if got := sess.LastDatabase(); got != "dw" {
+		t.Errorf("Expected last database dw, got %q", got)
+	}
+
+	// A source chosen without recording it (e.g. after the recorded one was removed) has no known database
+	sess.Metadata.DataSource = "other"
+	if got := sess.LastDatabase(); got != "" {
+		t.Errorf("Expected no last database for an unrecorded source, got %q", got)
+	}
+}
@@ -3,6 +3,9 @@ package source
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -124,37 +127,27 @@ func GetSource(name string) (*Source, error) {
 // GenerateUniqueSourceName generates a unique source name based on host, port, and user
 // Format: {host}-{port}-{user}, with numeric suffix if collision occurs
 func GenerateUniqueSourceName(host string, port int, user string) (string, error) {
-	baseName := fmt.Sprintf("%s-%d-%s", host, port, user)
-	
 	sources, err := LoadSources()
 	if err != nil {
 		return "", fmt.Errorf("failed to load sources: %w", err)
 	}
+	return uniqueSourceName(fmt.Sprintf("%s-%d-%s", host, port, user), sources)
+}
 
-	// Check if base name exists
-	nameExists := false
+// uniqueSourceName returns baseName, or baseName with a numeric suffix when a source already uses it
+func uniqueSourceName(baseName string, sources []*Source) (string, error) {
+	used := make(map[string]bool, len(sources))
 	for _, s := range sources {
-		if s.Name == baseName {
-			nameExists = true
-			break
-		}
+		used[s.Name] = true
 	}
-
-	if !nameExists {
+	if !used[baseName] {
 		return baseName, nil
 	}
 
 	// Find unique name by appending numeric suffix
 	for i := 2; i < 1000; i++ {
 		candidateName := fmt.Sprintf("%s-%d", baseName, i)
-		exists := false
-		for _, s := range sources {
-			if s.Name == candidateName {
-				exists = true
-				break
-			}
-		}
-		if !exists {
+		if !used[candidateName] {
 			return candidateName, nil
 		}
 	}
@@ -171,7 +164,7 @@ func FindExistingSourceByConnection(host string, port int, username string) (str
 	}
 
 	for _, s := range sources {
-		if s.Host == host && s.Port == port && s.Username == username {
+		if s.Type != DatabaseTypeFile && s.Host == host && s.Port == port && s.Username == username {
 			return s.Name, nil
 		}
 	}
@@ -201,6 +194,46 @@ func AddSourceWithAutoName(source *Source) (string, error) {
 	return name, AddSource(source)
 }
 
+// AddFileSource returns the file source for a set of files and directories, creating it when none exists
+// Paths are stored absolute; a new source is named after the first file (e.g. sales for sales.csv)
+func AddFileSource(paths []string) (string, error) {
+	files := make([]string, len(paths))
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return "", fmt.Errorf("cannot read %s: %w", path, err)
+		}
+		files[i] = abs
+	}
+
+	sources, err := LoadSources()
+	if err != nil {
+		return "", fmt.Errorf("failed to load sources: %w", err)
+	}
+	for _, s := range sources {
+		if s.Type == DatabaseTypeFile && reflect.DeepEqual(s.Files, files) {
+			return s.Name, nil
+		}
+	}
+
+	base := filepath.Base(files[0])
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+	name, err := uniqueSourceName(base, sources)
+	if err != nil {
+		return "", err
+	}
+	src := &Source{Name: name, Type: DatabaseTypeFile, Files: files}
+	if err := Validate(src); err != nil {
+		return "", err
+	}
+	return name, SaveSources(append(sources, src))
+}
+
 // UpdateSource updates an existing source by name
 func UpdateSource(name string, updated *Source) error {
 	sources, err := LoadSources()
@@ -241,14 +274,13 @@ func UpdateSource(name string, updated *Source) error {
 				continue
 			}
 			// Check if another source has the same connection params
-			if s.Host == updated.Host && s.Port == updated.Port && s.Username == updated.Username {
+			if s.Type != DatabaseTypeFile && s.Host == updated.Host && s.Port == updated.Port && s.Username == updated.Username {
 				return fmt.Errorf("source with connection '%s:%d@%s' already exists (name: '%s')", updated.Host, updated.Port, updated.Username, s.Name)
 			}
 		}
 	}
 
 	// Update the source
-	updated.Name = updated.Name // Ensure name is set
 	for i, s := range sources {
 		if s.Name == name {
 			sources[i] = updated
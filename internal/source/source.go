@@ -1,6 +1,10 @@
 package source
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"strings"
+)
 
 // DatabaseType represents the type of database
 type DatabaseType string
@@ -9,6 +13,7 @@ const (
 	DatabaseTypeMySQL     DatabaseType = "mysql"
 	DatabaseTypePostgreSQL DatabaseType = "postgresql"
 	DatabaseTypeSeekDB    DatabaseType = "seekdb"
+	DatabaseTypeFile      DatabaseType = "file"
 )
 
 // Source represents a database connection configuration
@@ -20,6 +25,7 @@ type Source struct {
 	Database string       `yaml:"database"`
 	Username string       `yaml:"username"`
 	Password string       `yaml:"password"`
+	Files    []string     `yaml:"files,omitempty"` // Data files and directories of a file source
 }
 
 // DSN returns the Data Source Name for the database driver
@@ -31,6 +37,8 @@ func (s *Source) DSN() string {
 	case DatabaseTypePostgreSQL:
 		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 			s.Host, s.Port, s.Username, s.Password, s.Database)
+	case DatabaseTypeFile:
+		return strings.Join(s.Files, string(os.PathListSeparator))
 	case DatabaseTypeSeekDB:
 		// SeekDB uses MySQL-compatible protocol, so use MySQL DSN format
 		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
@@ -51,7 +59,17 @@ func (s *Source) GetDatabaseType() string {
 		return "PostgreSQL"
 	case DatabaseTypeSeekDB:
 		return "seekdb"
+	case DatabaseTypeFile:
+		return "Files"
 	default:
 		return "MySQL"
 	}
 }
+
+// Location describes where a source's data lives for listings: host:port/database, or the files of a file source
+func (s *Source) Location() string {
+	if s.Type == DatabaseTypeFile {
+		return strings.Join(s.Files, ", ")
+	}
+	return fmt.Sprintf("%s:%d/%s", s.Host, s.Port, s.Database)
+}
@@ -27,8 +27,21 @@ func Validate(source *Source) error {
 	if source.Type == "" {
 		return fmt.Errorf("database type is required")
 	}
-	if source.Type != DatabaseTypeMySQL && source.Type != DatabaseTypePostgreSQL && source.Type != DatabaseTypeSeekDB {
-		return fmt.Errorf("invalid database type: %s (must be mysql, postgresql, or seekdb)", source.Type)
+	if source.Type != DatabaseTypeMySQL && source.Type != DatabaseTypePostgreSQL && source.Type != DatabaseTypeSeekDB && source.Type != DatabaseTypeFile {
+		return fmt.Errorf("invalid database type: %s (must be mysql, postgresql, seekdb, or file)", source.Type)
+	}
+
+	// File sources only need their files
+	if source.Type == DatabaseTypeFile {
+		if len(source.Files) == 0 {
+			return fmt.Errorf("at least one file or directory is required")
+		}
+		for _, file := range source.Files {
+			if strings.TrimSpace(file) == "" {
+				return fmt.Errorf("file path cannot be empty")
+			}
+		}
+		return nil
 	}
 
 	// Validate host
@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aiq/aiq/internal/audit"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// auditStatement returns the statement text recorded in the audit log for a tool call
+// Tools record their own statement (SQL and commands verbatim), others a compact JSON of their arguments
+func auditStatement(toolName string, args map[string]interface{}) string {
+	if t := tool.Lookup(toolName); t != nil && t.Statement != nil {
+		if statement := t.Statement(args); statement != "" {
+			return statement
+		}
+	}
+
+	// Drop display-only hints so the record shows what was actually requested
+	filtered := tool.DisplayArgs(args)
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return fmt.Sprintf("%v", filtered)
+	}
+	return string(data)
+}
+
+// auditApproval maps a risk level and the user's answer to an audit approval value
+func auditApproval(riskLevel tool.RiskLevel, confirmed bool) audit.Approval {
+	if riskLevel == tool.RiskLow {
+		return audit.ApprovalAuto
+	}
+	if confirmed {
+		return audit.ApprovalConfirmed
+	}
+	return audit.ApprovalRejected
+}
+
+// recordAudit writes an audit record for a tool call
+// toolResult is inspected for row counts and errors; execErr takes precedence when set
+// Audit failures are shown as warnings and never block the tool call loop
+func (h *ToolHandler) recordAudit(toolName string, args map[string]interface{}, riskLevel tool.RiskLevel, approval audit.Approval, duration time.Duration, toolResult json.RawMessage, execErr error) {
+	rec := audit.Record{
+		Source:     h.sourceName,
+		Database:   h.databaseName,
+		Tool:       toolName,
+		Statement:  auditStatement(toolName, args),
+		RiskLevel:  riskLevel.String(),
+		Approval:   approval,
+		DurationMs: duration.Milliseconds(),
+	}
+
+	if execErr != nil {
+		rec.Error = execErr.Error()
+	} else if len(toolResult) > 0 {
+		var resultData map[string]interface{}
+		if json.Unmarshal(toolResult, &resultData) == nil {
+			if errMsg, ok := resultData["error"].(string); ok && errMsg != "" {
+				rec.Error = errMsg
+			}
+			if n, ok := resultData["row_count"].(float64); ok {
+				rows := int64(n)
+				rec.RowsReturned = &rows
+			}
+			if n, ok := resultData["rows_affected"].(float64); ok {
+				rows := int64(n)
+				rec.RowsAffected = &rows
+			}
+		}
+	}
+
+	if err := audit.Log(rec); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to write audit log: %v", err))
+	}
+}
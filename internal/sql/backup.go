@@ -0,0 +1,169 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/backup"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// captureBackup saves a before-image of the rows a confirmed UPDATE, DELETE or TRUNCATE will change
+// (~/.aiq/backups) and returns it, or nil when no backup applies or it was skipped (with a warning)
+// q must see the same data the statement will run against (the preview transaction, if any)
+func (h *ToolHandler) captureBackup(ctx context.Context, q backup.Querier, statement string) *backup.Snapshot {
+	limits, enabled := backup.LoadLimits()
+	if !enabled {
+		return nil
+	}
+	snap, err := backup.Capture(ctx, q, statement, limits)
+	if errors.Is(err, backup.ErrNotApplicable) {
+		return nil
+	}
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Backup skipped: %v. This change cannot be undone with /restore.", err))
+		return nil
+	}
+	snap.Source = h.sourceName
+	snap.Database = h.databaseName
+
+	dir, err := backup.GetBackupDir()
+	if err == nil {
+		err = backup.Save(dir, snap, limits.MaxSnapshots)
+	}
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Backup skipped: %v. This change cannot be undone with /restore.", err))
+		return nil
+	}
+	ui.ShowInfo(fmt.Sprintf("Backup %s saved (%d row(s)). Use /restore %s to undo.", snap.ID, len(snap.Rows), snap.ID))
+	return snap
+}
+
+// discardBackup removes a snapshot whose statement did not run or was rolled back
+func discardBackup(snap *backup.Snapshot) {
+	if snap == nil {
+		return
+	}
+	if dir, err := backup.GetBackupDir(); err == nil {
+		backup.Delete(dir, snap.ID)
+	}
+}
+
+// isSuccessResult reports whether a tool result has status "success"
+func isSuccessResult(result json.RawMessage) bool {
+	var data map[string]interface{}
+	if json.Unmarshal(result, &data) != nil {
+		return false
+	}
+	status, _ := data["status"].(string)
+	return status == "success"
+}
+
+// handleRestoreCommand runs /restore [id]: it shows the compensating statements of a snapshot and runs them after confirmation
+// Without an id the recent snapshots are listed for selection
+func handleRestoreCommand(ctx context.Context, conn *db.Connection, sourceName string, args string) {
+	if conn == nil {
+		ui.ShowWarning("Restore is not available in free mode.")
+		return
+	}
+	dir, err := backup.GetBackupDir()
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("Failed to get backup directory: %v", err))
+		return
+	}
+
+	id := strings.TrimSpace(args)
+	if id == "" {
+		snapshots, err := backup.List(dir)
+		if err != nil {
+			ui.ShowError(err.Error())
+			return
+		}
+		var items []ui.MenuItem
+		for _, snap := range snapshots {
+			if snap.Source != sourceName || snap.RestoredAt != nil {
+				continue
+			}
+			items = append(items, ui.MenuItem{
+				Label: fmt.Sprintf("%s  %s  %d row(s)  %s", snap.ID, snap.Verb, len(snap.Rows), truncateStatement(snap.Statement, 60)),
+				Value: snap.ID,
+			})
+			if len(items) == 20 {
+				break
+			}
+		}
+		if len(items) == 0 {
+			ui.ShowInfo("No backups to restore for this source.")
+			return
+		}
+		id, err = ui.ShowMenu("Select a backup to restore", items)
+		if err != nil {
+			return
+		}
+	}
+
+	snap, err := backup.Load(dir, id)
+	if err != nil {
+		ui.ShowError(err.Error())
+		return
+	}
+	if snap.Source != sourceName {
+		ui.ShowWarning(fmt.Sprintf("Backup %s was taken on source '%s', not the current source '%s'.", snap.ID, snap.Source, sourceName))
+		return
+	}
+	if snap.RestoredAt != nil {
+		ui.ShowWarning(fmt.Sprintf("Backup %s was already restored at %s.", snap.ID, snap.RestoredAt.Format(time.RFC3339)))
+	}
+	statements, err := snap.CompensatingStatements()
+	if err != nil {
+		ui.ShowError(err.Error())
+		return
+	}
+	if len(statements) == 0 {
+		ui.ShowInfo("The backup contains no rows; nothing to restore.")
+		return
+	}
+
+	fmt.Println()
+	ui.ShowInfo(fmt.Sprintf("Backup %s of: %s", snap.ID, snap.Statement))
+	ui.ShowInfo(fmt.Sprintf("%d compensating statement(s):", len(statements)))
+	for i, statement := range statements {
+		if i == 5 {
+			fmt.Println(ui.HintText(fmt.Sprintf("... and %d more", len(statements)-i)))
+			break
+		}
+		fmt.Println(ui.HighlightSQL(statement))
+	}
+	if snap.Verb == "UPDATE" {
+		fmt.Println(ui.HintText("Rows are set back to their captured values, overwriting any later changes."))
+	}
+	fmt.Println()
+	confirm, err := ui.ShowConfirm("Run these statements?")
+	if err != nil || !confirm {
+		ui.ShowWarning("Restore cancelled.")
+		return
+	}
+
+	rows, err := backup.Restore(ctx, conn, snap)
+	if err != nil {
+		ui.ShowError(err.Error())
+		return
+	}
+	if err := backup.MarkRestored(dir, snap); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to mark backup as restored: %v", err))
+	}
+	ui.ShowSuccess(fmt.Sprintf("Backup %s restored (%d row(s) written).", snap.ID, rows))
+}
+
+func truncateStatement(statement string, max int) string {
+	statement = strings.Join(strings.Fields(statement), " ")
+	if len(statement) <= max {
+		return statement
+	}
+	return statement[:max-3] + "..."
+}
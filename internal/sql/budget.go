@@ -0,0 +1,182 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/llm"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// Defaults of the per-request budgets of the tool calling loop (tools section of config.yaml)
+const (
+	defaultMaxIterations    = 10
+	defaultMaxTurnSeconds   = 300
+	defaultMaxRepeatedCalls = 3
+)
+
+// loopBudget limits the work the tool calling loop does for one request; zero limits are unlimited
+type loopBudget struct {
+	maxIterations int
+	maxDuration   time.Duration
+	maxTokens     int
+	maxRepeated   int
+}
+
+// newLoopBudget returns the budget configured in config.yaml, with defaults for unset limits
+func newLoopBudget(cfg config.ToolsConfig) loopBudget {
+	b := loopBudget{
+		maxIterations: cfg.MaxIterations,
+		maxDuration:   time.Duration(cfg.MaxTurnSeconds) * time.Second,
+		maxTokens:     cfg.MaxTurnTokens,
+		maxRepeated:   cfg.MaxRepeatedCalls,
+	}
+	if b.maxIterations <= 0 {
+		b.maxIterations = defaultMaxIterations
+	}
+	if b.maxDuration <= 0 {
+		b.maxDuration = defaultMaxTurnSeconds * time.Second
+	}
+	if b.maxRepeated <= 0 {
+		b.maxRepeated = defaultMaxRepeatedCalls
+	}
+	return b
+}
+
+// turnUsage tracks what a request has used against its budget
+type turnUsage struct {
+	budget     loopBudget
+	iterations int
+	start      time.Time
+	tokens     int
+	calls      map[string]int // Calls made so far, by callKey
+
+	// Limits, raised each time the user chooses to go on
+	iterationLimit int
+	deadline       time.Time
+	tokenLimit     int
+}
+
+func newTurnUsage(budget loopBudget) *turnUsage {
+	now := time.Now()
+	return &turnUsage{
+		budget:         budget,
+		start:          now,
+		calls:          make(map[string]int),
+		iterationLimit: budget.maxIterations,
+		deadline:       now.Add(budget.maxDuration),
+		tokenLimit:     budget.maxTokens,
+	}
+}
+
+// addResponse records an LLM round trip and the tokens it used
+func (u *turnUsage) addResponse(usage *llm.Usage) {
+	u.iterations++
+	if usage != nil {
+		u.tokens += usage.TotalTokens
+	}
+}
+
+// exhausted describes the budget that ran out, or returns "" while the request may go on
+func (u *turnUsage) exhausted() string {
+	switch {
+	case u.iterationLimit > 0 && u.iterations >= u.iterationLimit:
+		return fmt.Sprintf("The assistant has made %d LLM calls for this request (limit %d, tools.max_iterations).", u.iterations, u.iterationLimit)
+	case time.Now().After(u.deadline):
+		return fmt.Sprintf("This request has been running for %s (tools.max_turn_seconds allows %s at a time).", time.Since(u.start).Round(time.Second), u.budget.maxDuration)
+	case u.tokenLimit > 0 && u.tokens >= u.tokenLimit:
+		return fmt.Sprintf("This request has used %d tokens (limit %d, tools.max_turn_tokens).", u.tokens, u.tokenLimit)
+	}
+	return ""
+}
+
+// extend grants the request another budget of each kind that ran out
+func (u *turnUsage) extend() {
+	if u.iterationLimit > 0 && u.iterations >= u.iterationLimit {
+		u.iterationLimit = u.iterations + u.budget.maxIterations
+	}
+	if time.Now().After(u.deadline) {
+		u.deadline = time.Now().Add(u.budget.maxDuration)
+	}
+	if u.tokenLimit > 0 && u.tokens >= u.tokenLimit {
+		u.tokenLimit = u.tokens + u.budget.maxTokens
+	}
+}
+
+// repeated records a call and reports how many times it has been made, and whether that exceeds the budget
+// A nil turnUsage (calls the user makes directly) counts nothing
+func (u *turnUsage) repeated(name string, args map[string]interface{}) (int, bool) {
+	if u == nil {
+		return 0, false
+	}
+	key := callKey(name, args)
+	u.calls[key]++
+	return u.calls[key], u.calls[key] > u.budget.maxRepeated
+}
+
+// wouldRepeat reports whether making a call extra more times would exceed the budget, without recording it
+func (u *turnUsage) wouldRepeat(name string, args map[string]interface{}, extra int) bool {
+	return u.calls[callKey(name, args)]+extra > u.budget.maxRepeated
+}
+
+// callKey identifies a call by tool name and normalized arguments: display hints dropped and
+// whitespace in string values collapsed, so reformatted retries of the same SQL count as the same call
+func callKey(name string, args map[string]interface{}) string {
+	data, err := json.Marshal(normalizeArg(tool.DisplayArgs(args)))
+	if err != nil {
+		return name + " " + fmt.Sprintf("%v", args)
+	}
+	return name + " " + string(data)
+}
+
+func normalizeArg(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSuffix(strings.Join(strings.Fields(v), " "), ";")
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			normalized[k] = normalizeArg(value)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, value := range v {
+			normalized[i] = normalizeArg(value)
+		}
+		return normalized
+	default:
+		return v
+	}
+}
+
+// askToContinue asks the user whether to go on after a budget ran out
+// Returns false to stop, or true with optional guidance to pass on to the assistant
+func askToContinue(reason string) (bool, string) {
+	fmt.Println()
+	ui.ShowWarning(reason)
+	choice, err := ui.ShowMenu("Continue this request", []ui.MenuItem{
+		{Label: "Continue", Value: "continue"},
+		{Label: "Give guidance", Value: "guidance"},
+		{Label: "Stop", Value: "stop"},
+	})
+	if err != nil {
+		return false, ""
+	}
+	switch choice {
+	case "continue":
+		return true, ""
+	case "guidance":
+		guidance, err := ui.ShowInput("Guidance for the assistant", "")
+		if err != nil {
+			return false, ""
+		}
+		return true, strings.TrimSpace(guidance)
+	default:
+		return false, ""
+	}
+}
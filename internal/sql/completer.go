@@ -0,0 +1,254 @@
+package sql
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// completionTimeout bounds the schema lookups made while completing, since Tab blocks the prompt
+const completionTimeout = 3 * time.Second
+
+// chatCompleter completes the chat prompt depending on where the cursor is: commands at the start of the line,
+// sources after /source and /attach, databases after /use and \c, tables after \d and after FROM, JOIN and the
+// other keywords that name a table, and otherwise table names, columns after "table." and skill names
+// SQL (in SQL mode or after /sql) completes keywords instead of skill names
+// Table names come from the table list read on connecting; columns and databases are looked up on first use
+// and kept until the session switches source or database
+type chatCompleter struct {
+	commands []string              // Slash and backslash commands
+	skills   []string              // Names of the loaded skills
+	sources  func() []string       // Names of the configured sources
+	attached func() []string       // Names of the attached sources
+	conn     func() *db.Connection // Current connection; nil in free mode
+	sqlMode  func() bool           // Whether input runs as SQL (SQL mode)
+
+	mu        sync.Mutex
+	schema    *db.Schema      // Tables of the current database, with the columns looked up so far
+	loaded    map[string]bool // Tables whose columns were looked up
+	databases []string        // Databases of the current source, nil until looked up
+}
+
+// SetTables replaces the schema completions come from, after connecting or switching source or database
+func (c *chatCompleter) SetTables(tables []db.TableSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schema = &db.Schema{Tables: make([]db.TableInfo, 0, len(tables))}
+	for _, t := range tables {
+		c.schema.Tables = append(c.schema.Tables, db.TableInfo{Name: t.Name})
+	}
+	c.loaded = map[string]bool{}
+	c.databases = nil
+}
+
+// Do implements readline.AutoCompleter: it returns the candidates' remaining characters and the length of
+// the word they complete
+func (c *chatCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	text := string(line[:pos])
+	fields := strings.Fields(text)
+	atWordStart := len(text) == 0 || strings.ContainsRune(" \t", rune(text[len(text)-1]))
+
+	// The first word of a command line is the command
+	if len(fields) == 1 && !atWordStart && (strings.HasPrefix(fields[0], "/") || isMetaCommand(fields[0])) {
+		return complete(fields[0], c.commands, " ")
+	}
+
+	word := currentWord(text)
+	sql := c.sqlMode != nil && c.sqlMode()
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "/") || isMetaCommand(fields[0])) {
+		command := strings.ToLower(fields[0])
+		firstArg := len(fields) == 1 || (len(fields) == 2 && !atWordStart)
+		switch {
+		case command == "/sql" || command == "/nocache":
+			// Followed by SQL or a question
+			sql = command == "/sql"
+		case !firstArg:
+			return nil, 0
+		case command == "/source" || command == "/attach":
+			return complete(word, c.sources(), " ")
+		case command == "/detach":
+			return complete(word, c.attached(), " ")
+		case command == "/use" || command == `\c`:
+			return complete(word, c.databaseNames(), " ")
+		case command == `\d` || command == `\dt` || command == `\dv` || command == `\di`:
+			return complete(word, c.tableNames(), " ")
+		default:
+			return nil, 0
+		}
+	}
+
+	// Only tables follow FROM and JOIN; a qualified name names a table of another database
+	if tableKeywords[strings.ToUpper(previousWord(text[:len(text)-len(word)]))] {
+		if strings.Contains(word, ".") {
+			return nil, 0
+		}
+		return complete(word, c.tableNames(), "")
+	}
+
+	// table.column
+	if dot := strings.LastIndex(word, "."); dot > 0 {
+		table := word[:dot]
+		if i := strings.LastIndex(table, "."); i >= 0 {
+			table = table[i+1:]
+		}
+		columns := c.columnNames(table)
+		candidates := make([]string, 0, len(columns))
+		for _, column := range columns {
+			candidates = append(candidates, word[:dot+1]+column)
+		}
+		return complete(word, candidates, "")
+	}
+	if word == "" {
+		return nil, 0
+	}
+	if sql {
+		return complete(word, append(c.tableNames(), keywordCandidates(word)...), "")
+	}
+	return complete(word, append(c.tableNames(), c.skills...), "")
+}
+
+// sqlKeywords are the keywords completed in SQL
+var sqlKeywords = []string{
+	"ALL", "AND", "AS", "ASC", "AVG", "BETWEEN", "BY", "CASE", "CAST", "COALESCE", "COUNT", "CREATE", "CROSS",
+	"DELETE", "DESC", "DESCRIBE", "DISTINCT", "ELSE", "END", "EXCEPT", "EXISTS", "EXPLAIN", "FALSE", "FROM",
+	"FULL", "GROUP", "HAVING", "IN", "INNER", "INSERT", "INTERSECT", "INTO", "IS", "JOIN", "LEFT", "LIKE",
+	"LIMIT", "MAX", "MIN", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER", "OVER", "PARTITION", "RIGHT",
+	"SELECT", "SET", "SHOW", "SUM", "TABLE", "THEN", "TRUE", "UNION", "UPDATE", "USING", "VALUES", "WHEN",
+	"WHERE", "WITH",
+}
+
+// tableKeywords are the keywords followed by a table name
+var tableKeywords = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true, "DESCRIBE": true,
+}
+
+// keywordCandidates returns the keywords starting with word, ignoring case, written in lower case when word is
+func keywordCandidates(word string) []string {
+	upper := strings.ToUpper(word)
+	if len(upper) != len(word) {
+		return nil
+	}
+	lower := word == strings.ToLower(word)
+	var candidates []string
+	for _, keyword := range sqlKeywords {
+		if !strings.HasPrefix(keyword, upper) {
+			continue
+		}
+		rest := keyword[len(word):]
+		if lower {
+			rest = strings.ToLower(rest)
+		}
+		candidates = append(candidates, word+rest)
+	}
+	return candidates
+}
+
+// previousWord returns the last word of text when text ends with whitespace, the word before the cursor's
+func previousWord(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.ContainsRune(" \t", rune(text[len(text)-1])) {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// currentWord returns the identifier ending at the end of text, including dots of qualified names
+func currentWord(text string) string {
+	start := len(text)
+	for start > 0 {
+		ch := text[start-1]
+		if ch != '_' && ch != '.' && ch != '$' && ch != '-' && !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && !(ch >= '0' && ch <= '9') && ch < 0x80 {
+			break
+		}
+		start--
+	}
+	return text[start:]
+}
+
+// complete returns the remaining characters of the candidates starting with word, sorted and without repeats,
+// each followed by suffix
+func complete(word string, candidates []string, suffix string) ([][]rune, int) {
+	seen := map[string]bool{}
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	result := make([][]rune, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, []rune(match[len(word):]+suffix))
+	}
+	return result, len([]rune(word))
+}
+
+// tableNames returns the table names of the current database
+func (c *chatCompleter) tableNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schema == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.schema.Tables))
+	for _, t := range c.schema.Tables {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// columnNames returns the columns of a table of the current database, looking them up the first time
+func (c *chatCompleter) columnNames(table string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schema == nil {
+		return nil
+	}
+	for i := range c.schema.Tables {
+		info := &c.schema.Tables[i]
+		if !strings.EqualFold(info.Name, table) {
+			continue
+		}
+		if !c.loaded[info.Name] {
+			c.loaded[info.Name] = true
+			if conn := c.conn(); conn != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+				desc, err := conn.DescribeTable(ctx, "", info.Name)
+				cancel()
+				if err == nil {
+					for _, col := range desc.Columns {
+						info.Columns = append(info.Columns, db.ColumnInfo{Name: col.Name, DataType: col.Type})
+					}
+				}
+			}
+		}
+		names := make([]string, 0, len(info.Columns))
+		for _, col := range info.Columns {
+			names = append(names, col.Name)
+		}
+		return names
+	}
+	return nil
+}
+
+// databaseNames returns the databases of the current source, looking them up the first time
+func (c *chatCompleter) databaseNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.databases == nil {
+		c.databases = []string{}
+		if conn := c.conn(); conn != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+			if databases, err := conn.ListDatabases(ctx); err == nil {
+				c.databases = databases
+			}
+			cancel()
+		}
+	}
+	return c.databases
+}
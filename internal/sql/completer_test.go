@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// newTestCompleter returns a completer over conn with the given tables; conn may be nil, as in free mode
+func newTestCompleter(conn *db.Connection, sqlMode bool, tables ...string) *chatCompleter {
+	c := &chatCompleter{
+		commands: []string{"/source", "/sql", "/use", `\d`, `\dt`},
+		skills:   []string{"report-builder", "sales-analysis"},
+		sources:  func() []string { return []string{"prod", "staging"} },
+		attached: func() []string { return nil },
+		conn:     func() *db.Connection { return conn },
+		sqlMode:  func() bool { return sqlMode },
+	}
+	summaries := make([]db.TableSummary, len(tables))
+	for i, name := range tables {
+		summaries[i] = db.TableSummary{Name: name, Type: "TABLE"}
+	}
+	c.SetTables(summaries)
+	return c
+}
+
+// completions returns the words Tab would complete line to
+func completions(c *chatCompleter, line string) []string {
+	candidates, length := c.Do([]rune(line), len([]rune(line)))
+	if len(candidates) == 0 {
+		return nil
+	}
+	runes := []rune(line)
+	typed := string(runes[len(runes)-length:])
+	words := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		words[i] = typed + string(candidate)
+	}
+	return words
+}
+
+func checkCompletions(t *testing.T, c *chatCompleter, tests []struct {
+	line     string
+	expected []string
+}) {
+	t.Helper()
+	for _, tt := range tests {
+		if got := completions(c, tt.line); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.expected, got)
+		}
+	}
+}
+
+// TestChatCompleter_Keywords tests that SQL completes keywords in the case they are typed in
+func TestChatCompleter_Keywords(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{line: "sel", expected: []string{"select"}},
+		{line: "SEL", expected: []string{"SELECT"}},
+		{line: "Sel", expected: []string{"SelECT"}},
+		{line: "SELECT * FROM orders WHE", expected: []string{"WHEN", "WHERE"}},
+		{line: "select id from orders order b", expected: []string{"between", "by"}},
+		{line: "SELECT * FROM orders w", expected: []string{"warehouses", "when", "where", "with"}},
+		{line: "select ri", expected: []string{"right"}},
+		{line: "SELECT ", expected: nil},
+	}
+	checkCompletions(t, newTestCompleter(nil, true, "orders", "warehouses"), tests)
+
+	t.Run("after /sql", func(t *testing.T) {
+		checkCompletions(t, newTestCompleter(nil, false, "orders"), []struct {
+			line     string
+			expected []string
+		}{
+			{line: "/sql sel", expected: []string{"select"}},
+			{line: "/sql SELECT COUNT(*) FROM orders GR", expected: []string{"GROUP"}},
+		})
+	})
+
+	t.Run("questions complete skills instead", func(t *testing.T) {
+		checkCompletions(t, newTestCompleter(nil, false, "orders"), []struct {
+			line     string
+			expected []string
+		}{
+			{line: "sel", expected: nil},
+			{line: "use the rep", expected: []string{"report-builder"}},
+			{line: "/nocache sel", expected: nil},
+		})
+	})
+}
+
+// TestChatCompleter_Tables tests that only table names follow FROM, JOIN and the other table keywords
+func TestChatCompleter_Tables(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{line: "SELECT * FROM ", expected: []string{"customers", "orders", "warehouses"}},
+		{line: "select * from or", expected: []string{"orders"}},
+		{line: "SELECT * FROM orders o JOIN c", expected: []string{"customers"}},
+		{line: "SELECT * FROM orders o LEFT JOIN w", expected: []string{"warehouses"}},
+		{line: "INSERT INTO ", expected: []string{"customers", "orders", "warehouses"}},
+		{line: "UPDATE cu", expected: []string{"customers"}},
+		{line: "SELECT * FROM w", expected: []string{"warehouses"}},
+		{line: "SELECT * FROM shop.or", expected: nil},
+		{line: "SELECT * FROM x", expected: nil},
+		{line: "SELECT * FROM orders ", expected: nil},
+		{line: `\d or`, expected: []string{"orders "}},
+	}
+	checkCompletions(t, newTestCompleter(nil, true, "orders", "customers", "warehouses"), tests)
+
+	t.Run("questions", func(t *testing.T) {
+		checkCompletions(t, newTestCompleter(nil, false, "orders", "customers"), []struct {
+			line     string
+			expected []string
+		}{
+			{line: "count the rows from ", expected: []string{"customers", "orders"}},
+			{line: "how many or", expected: []string{"orders"}},
+		})
+	})
+}
+
+// TestChatCompleter_Columns tests that columns complete after "table." and are looked up once
+func TestChatCompleter_Columns(t *testing.T) {
+	c := newTestCompleter(newFileConn(t), true, "orders", "customers")
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{line: "SELECT orders.", expected: []string{"orders.customer_id", "orders.id", "orders.total"}},
+		{line: "SELECT orders.cu", expected: []string{"orders.customer_id"}},
+		{line: "select ORDERS.t", expected: []string{"ORDERS.total"}},
+		{line: "SELECT o.id FROM orders o WHERE customers.n", expected: []string{"customers.name"}},
+		{line: "SELECT shop.orders.i", expected: []string{"shop.orders.id"}},
+		{line: "SELECT missing.", expected: nil},
+	}
+	checkCompletions(t, c, tests)
+	if !c.loaded["orders"] || !c.loaded["customers"] {
+		t.Errorf("Expected the columns of both tables to be looked up, got %v", c.loaded)
+	}
+}
+
+// TestChatCompleter_NoSchema tests completion without a schema: in free mode and before tables are set
+func TestChatCompleter_NoSchema(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{line: "SELECT * FROM ", expected: nil},
+		{line: "SELECT * FROM or", expected: nil},
+		{line: "SELECT orders.", expected: nil},
+		{line: "sel", expected: []string{"select"}},
+		{line: "/so", expected: []string{"/source "}},
+		{line: "/source st", expected: []string{"staging "}},
+		{line: `\d `, expected: nil},
+		{line: "", expected: nil},
+	}
+	checkCompletions(t, newTestCompleter(nil, true), tests)
+
+	t.Run("tables not set", func(t *testing.T) {
+		c := &chatCompleter{conn: func() *db.Connection { return nil }}
+		for _, line := range []string{"SELECT * FROM ", "orders.", "or", "/use "} {
+			if got := completions(c, line); got != nil {
+				t.Errorf("%q: expected no completions, got %q", line, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aiq/aiq/internal/audit"
+	"github.com/aiq/aiq/internal/backup"
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/llm"
+	"github.com/aiq/aiq/internal/mcp"
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/session"
+	"github.com/aiq/aiq/internal/source"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+	"github.com/aiq/aiq/internal/version"
+)
+
+// MCPServeOptions configures aiq mcp serve
+type MCPServeOptions struct {
+	Database string // Overrides the source's database
+	ReadOnly bool   // Refuse every call that would need confirmation instead of asking the client
+}
+
+// mcpDatabaseServer runs the database tools offered by aiq mcp serve
+// Calls go through the same policy, risk assessment, backup and audit steps as the chat tool call loop
+type mcpDatabaseServer struct {
+	h        *ToolHandler
+	database string
+	readOnly bool
+}
+
+// ServeMCP serves execute_sql, list_tables, describe_table and render_chart for src over MCP stdio (in/out)
+// UI messages (warnings, backup notices) are printed to stdout, so callers must keep out separate from os.Stdout
+func ServeMCP(ctx context.Context, src *source.Source, opts MCPServeOptions, in io.Reader, out io.Writer) error {
+	actualSource := *src
+	if opts.Database != "" {
+		actualSource.Database = opts.Database
+	}
+	conn, err := db.NewConnection(actualSource.DSN(), string(actualSource.Type))
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	// Load the risk policy with overrides for this source (~/.aiq/config/policy.yaml)
+	if err := policy.SetSource(src.Name); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to load risk policy: %v. All operations except blocked ones will require confirmation.", err))
+	}
+
+	// Tools disabled in config.yaml are not served either
+	if cfg, err := config.Load(); err == nil {
+		tool.Default().Configure(cfg.Tools)
+	}
+
+	// Results live as long as the server, so clients can chart execute_sql results by result_id
+	h := &ToolHandler{conn: conn, results: session.NewResultStore()}
+	h.SetSourceInfo(src.Name, actualSource.Database)
+	s := &mcpDatabaseServer{h: h, database: actualSource.Database, readOnly: opts.ReadOnly}
+
+	instructions := fmt.Sprintf("Database tools for the aiq source %s (%s database %s).", src.Name, actualSource.Type, actualSource.Database)
+	if opts.ReadOnly {
+		instructions += " The server is read-only: statements that modify data are refused."
+	} else {
+		instructions += " Statements that modify data need the user's confirmation."
+	}
+	server := mcp.NewToolServer(mcp.Implementation{Name: "aiq", Version: version.Version}, instructions)
+	s.register(server, string(actualSource.Type))
+	return server.Serve(ctx, in, out)
+}
+
+func (s *mcpDatabaseServer) register(server *mcp.ToolServer, databaseType string) {
+	readOnly, notReadOnly := true, false
+	addTool := func(t mcp.Tool, handler mcp.ToolHandler) {
+		if !tool.Default().IsDisabled(t.Name) {
+			server.AddTool(t, handler)
+		}
+	}
+	addTool(mcp.Tool{
+		Name:        "execute_sql",
+		Title:       "Execute SQL",
+		Description: fmt.Sprintf("Execute a SQL statement against the %s database %s and return the result rows or the number of affected rows. Reads run directly; writes and DDL require the user's confirmation and may be refused by policy.", databaseType, s.database),
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"sql": map[string]interface{}{"type": "string", "description": "The SQL statement to execute"},
+			},
+			"required": []string{"sql"},
+		},
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: &notReadOnly, DestructiveHint: &notReadOnly},
+	}, s.executeSQL)
+
+	// Schema exploration tools are the ones offered to the LLM in chat mode
+	for _, explore := range []struct{ name, title string }{
+		{"list_tables", "List tables"},
+		{"describe_table", "Describe table"},
+		{"sample_rows", "Sample rows"},
+		{"column_values", "Column values"},
+	} {
+		t := tool.Lookup(explore.name)
+		addTool(mcp.Tool{
+			Name:        t.Name,
+			Title:       explore.title,
+			Description: t.Description,
+			InputSchema: t.Parameters,
+			Annotations: &mcp.ToolAnnotations{ReadOnlyHint: &readOnly},
+		}, s.exploreTool(t.Name))
+	}
+
+	addTool(mcp.Tool{
+		Name:        "render_chart",
+		Title:       "Render chart",
+		Description: "Render query results as a text chart (bar, line, pie or scatter). Pass the result_id returned by execute_sql, or columns and rows",
+		InputSchema: tool.Lookup("render_chart").Parameters,
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: &readOnly},
+	}, s.renderChart)
+}
+
+// authorize applies the policy and asks the client for confirmation when the call is high risk
+// Refused calls are audited and returned as errors for the client
+func (s *mcpDatabaseServer) authorize(ctx context.Context, session *mcp.Session, toolName string, args map[string]interface{}, assess bool) (tool.RiskLevel, audit.Approval, error) {
+	if decision, denied := tool.CheckPolicy(toolName, args); denied {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by policy"
+		}
+		err := fmt.Errorf("blocked by policy rule '%s': %s", decision.Rule, reason)
+		s.h.recordAudit(toolName, args, tool.RiskHigh, audit.ApprovalDenied, 0, nil, err)
+		return tool.RiskHigh, audit.ApprovalDenied, err
+	}
+	if !assess {
+		return tool.RiskLow, audit.ApprovalAuto, nil
+	}
+
+	riskLevel := tool.GetRiskAssessor(toolName).AssessRisk(toolName, args)
+	tool.LogRiskAssessment("MCP serve: Tool: %s, RiskLevel: %v", toolName, riskLevel)
+	if riskLevel == tool.RiskLow {
+		return riskLevel, audit.ApprovalAuto, nil
+	}
+
+	var err error
+	if s.readOnly {
+		err = fmt.Errorf("refused: the aiq MCP server is read-only and this statement requires confirmation")
+	} else {
+		confirmed, confirmErr := session.Confirm(ctx, fmt.Sprintf("Execute on %s (%s)?\n\n%s", s.h.sourceName, s.database, auditStatement(toolName, args)))
+		switch {
+		case errors.Is(confirmErr, mcp.ErrConfirmationUnavailable):
+			err = fmt.Errorf("refused: this statement requires confirmation and %v. Run it from aiq chat or allow it with a policy rule", confirmErr)
+		case confirmErr != nil:
+			err = fmt.Errorf("refused: confirmation failed: %v", confirmErr)
+		case !confirmed:
+			err = fmt.Errorf("refused: the user declined to execute this statement")
+		}
+	}
+	if err != nil {
+		s.h.recordAudit(toolName, args, riskLevel, audit.ApprovalRejected, 0, nil, err)
+		return riskLevel, audit.ApprovalRejected, err
+	}
+	return riskLevel, audit.ApprovalConfirmed, nil
+}
+
+func (s *mcpDatabaseServer) executeSQL(ctx context.Context, session *mcp.Session, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	sqlText, ok := args["sql"].(string)
+	if !ok || sqlText == "" {
+		return nil, fmt.Errorf("invalid sql parameter")
+	}
+	// Only the statement is passed on: a client-supplied risk_level must not lower the assessed risk
+	args = map[string]interface{}{"sql": sqlText}
+
+	riskLevel, approval, err := s.authorize(ctx, session, "execute_sql", args, true)
+	if err != nil {
+		return nil, err
+	}
+	// Before-image backup of the rows a confirmed UPDATE/DELETE/TRUNCATE changes, as in chat mode
+	var snap *backup.Snapshot
+	if approval == audit.ApprovalConfirmed {
+		snap = s.h.captureBackup(ctx, s.h.conn, sqlText)
+	}
+	return s.run(ctx, "execute_sql", args, riskLevel, approval, func(result json.RawMessage) {
+		if !isSuccessResult(result) {
+			discardBackup(snap)
+		}
+	})
+}
+
+func (s *mcpDatabaseServer) renderChart(ctx context.Context, session *mcp.Session, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	riskLevel, approval, err := s.authorize(ctx, session, "render_chart", args, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, "render_chart", args, riskLevel, approval, nil)
+}
+
+// run executes a tool through ToolHandler.ExecuteTool, audits it and converts the result for the client
+func (s *mcpDatabaseServer) run(ctx context.Context, toolName string, args map[string]interface{}, riskLevel tool.RiskLevel, approval audit.Approval, after func(json.RawMessage)) (*mcp.CallToolResult, error) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal arguments: %w", err)
+	}
+	toolCall := llm.ToolCall{Type: "function"}
+	toolCall.Function.Name = toolName
+	toolCall.Function.Arguments = string(argsJSON)
+
+	start := time.Now()
+	result, execErr := s.h.ExecuteTool(ctx, toolCall)
+	s.h.recordAudit(toolName, args, riskLevel, approval, time.Since(start), result, execErr)
+	if after != nil {
+		after(result)
+	}
+	if execErr != nil {
+		return nil, execErr
+	}
+	return toolResult(result), nil
+}
+
+// exploreTool serves a read-only schema exploration tool of the registry
+// Calls run without confirmation unless a policy rule requires it
+func (s *mcpDatabaseServer) exploreTool(name string) mcp.ToolHandler {
+	return func(ctx context.Context, session *mcp.Session, args map[string]interface{}) (*mcp.CallToolResult, error) {
+		riskLevel, approval, err := s.authorize(ctx, session, name, args, true)
+		if err != nil {
+			return nil, err
+		}
+		return s.run(ctx, name, args, riskLevel, approval, nil)
+	}
+}
+
+// toolResult converts a ToolHandler result to an MCP result; results with status "error" become tool errors
+func toolResult(result json.RawMessage) *mcp.CallToolResult {
+	var data map[string]interface{}
+	if json.Unmarshal(result, &data) != nil {
+		return mcp.TextResult(string(result))
+	}
+	if errMsg, ok := data["error"].(string); ok && errMsg != "" {
+		return mcp.ErrorResult(string(result))
+	}
+	if output, ok := data["output"].(string); ok && data["format"] == "chart" {
+		return mcp.TextResult(output)
+	}
+	converted, err := mcp.JSONResult(data)
+	if err != nil {
+		return mcp.TextResult(string(result))
+	}
+	return converted
+}
@@ -0,0 +1,315 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// metaCommand is a backslash command of the chat prompt, named after its psql/mysql client equivalent
+type metaCommand struct {
+	name        string
+	args        string
+	description string
+}
+
+// metaCommands lists the backslash commands for help and completion
+var metaCommands = []metaCommand{
+	{`\dt`, "[pattern]", "List tables"},
+	{`\dv`, "[pattern]", "List views"},
+	{`\di`, "[pattern]", "List indexes"},
+	{`\d`, "[table]", "Describe a table; without a name, list tables and views"},
+	{`\l`, "", "List databases"},
+	{`\c`, "[database]", "Connect to another database of the source"},
+	{`\x`, "[on|off]", "Toggle expanded output (one line per column)"},
+	{`\timing`, "[on|off]", "Toggle showing how long each query takes"},
+	{`\i`, "<file>", "Run the SQL statements in a file"},
+	{`\?`, "", "Show the backslash commands"},
+}
+
+// metaEnv is the chat session state backslash commands read and change
+type metaEnv struct {
+	conn     *db.Connection
+	database string
+	timing   *bool
+	runSQL   func(statement string) bool // Runs a statement the way /sql does and reports success
+	connect  func(database string) error // Switches the session to another database of the source
+}
+
+// isMetaCommand reports whether input is a backslash command
+func isMetaCommand(input string) bool {
+	return strings.HasPrefix(input, `\`)
+}
+
+// parseMetaCommand splits a backslash command into its name and argument
+func parseMetaCommand(input string) (name, arg string) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.TrimSpace(strings.TrimSpace(input)[len(fields[0]):])
+}
+
+// handleMetaCommand runs a backslash command
+func handleMetaCommand(ctx context.Context, env metaEnv, input string) {
+	name, arg := parseMetaCommand(input)
+
+	// Display settings work without a source
+	switch name {
+	case `\?`:
+		showMetaHelp()
+		return
+	case `\x`:
+		on, err := parseToggle(arg, tool.ExpandedOutput())
+		if err != nil {
+			ui.ShowWarning(fmt.Sprintf(`\x: %v`, err))
+			return
+		}
+		tool.SetExpandedOutput(on)
+		ui.ShowInfo(fmt.Sprintf("Expanded display is %s.", onOff(on)))
+		return
+	case `\timing`:
+		on, err := parseToggle(arg, *env.timing)
+		if err != nil {
+			ui.ShowWarning(fmt.Sprintf(`\timing: %v`, err))
+			return
+		}
+		*env.timing = on
+		ui.ShowInfo(fmt.Sprintf("Timing is %s.", onOff(on)))
+		return
+	}
+
+	known := false
+	for _, cmd := range metaCommands {
+		known = known || cmd.name == name
+	}
+	if !known {
+		ui.ShowWarning(fmt.Sprintf(`Unknown command %s. Use \? to list the backslash commands.`, name))
+		return
+	}
+	if env.conn == nil {
+		ui.ShowWarning(fmt.Sprintf("%s needs a database source; select one with /source <name>.", name))
+		return
+	}
+
+	var err error
+	switch name {
+	case `\dt`:
+		err = showRelations(ctx, env.conn, arg, "TABLE")
+	case `\dv`:
+		err = showRelations(ctx, env.conn, arg, "VIEW")
+	case `\d`:
+		if arg == "" {
+			err = showRelations(ctx, env.conn, "", "")
+		} else {
+			err = showTable(ctx, env.conn, arg)
+		}
+	case `\di`:
+		err = showIndexes(ctx, env.conn, arg)
+	case `\l`:
+		err = showDatabases(ctx, env.conn, env.database)
+	case `\c`:
+		if arg == "" {
+			ui.ShowInfo(fmt.Sprintf("You are connected to database %s.", ui.HighlightText(env.database)))
+			return
+		}
+		err = env.connect(arg)
+	case `\i`:
+		err = runSQLFile(env, arg)
+	}
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("%s failed: %v", name, err))
+	}
+}
+
+// showMetaHelp lists the backslash commands
+func showMetaHelp() {
+	ui.ShowInfo("Backslash commands:")
+	fmt.Println()
+	for _, cmd := range metaCommands {
+		fmt.Printf("  %-20s - %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.description)
+	}
+}
+
+// parseToggle returns the new state of a setting: on or off as given, otherwise the opposite of current
+func parseToggle(arg string, current bool) (bool, error) {
+	switch strings.ToLower(arg) {
+	case "":
+		return !current, nil
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+	return current, fmt.Errorf("expected on or off, got %q", arg)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// showRelations lists the tables and views matching pattern; kind TABLE or VIEW limits the list to one kind
+func showRelations(ctx context.Context, conn *db.Connection, pattern, kind string) error {
+	tables, err := conn.SearchTables(ctx, "", pattern)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, t := range tables {
+		if kind != "" && t.Type != kind {
+			continue
+		}
+		estimated := ""
+		if t.Rows.Valid {
+			estimated = fmt.Sprintf("%d", t.Rows.Int64)
+		}
+		rows = append(rows, []string{t.Name, strings.ToLower(t.Type), estimated, t.Comment})
+	}
+	if len(rows) == 0 {
+		ui.ShowInfo("No matching relations found.")
+		return nil
+	}
+	ui.PrintTable([]string{"Name", "Type", "Rows (estimated)", "Comment"}, rows)
+	fmt.Printf("%d relation(s)\n", len(rows))
+	return nil
+}
+
+// showTable prints the columns, keys and indexes of a table
+func showTable(ctx context.Context, conn *db.Connection, table string) error {
+	desc, err := conn.DescribeTable(ctx, "", table)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(desc.Columns))
+	for _, col := range desc.Columns {
+		nullable := "NO"
+		if col.Nullable {
+			nullable = "YES"
+		}
+		def := ""
+		if col.Default.Valid {
+			def = col.Default.String
+		}
+		rows = append(rows, []string{col.Name, col.Type, nullable, def, col.Extra, col.Comment})
+	}
+	ui.ShowInfo(fmt.Sprintf("Table %s", ui.HighlightText(desc.Name)))
+	if desc.Comment != "" {
+		fmt.Println(ui.HintText(desc.Comment))
+	}
+	ui.PrintTable([]string{"Column", "Type", "Nullable", "Default", "Extra", "Comment"}, rows)
+	if len(desc.PrimaryKey) > 0 {
+		fmt.Printf("Primary key: (%s)\n", strings.Join(desc.PrimaryKey, ", "))
+	}
+	var indexes []string
+	for _, index := range desc.Indexes {
+		if index.Primary {
+			continue
+		}
+		unique := ""
+		if index.Unique {
+			unique = " UNIQUE"
+		}
+		indexes = append(indexes, fmt.Sprintf("    %s%s (%s)", index.Name, unique, strings.Join(index.Columns, ", ")))
+	}
+	if len(indexes) > 0 {
+		fmt.Println("Indexes:")
+		fmt.Println(strings.Join(indexes, "\n"))
+	}
+	if len(desc.ForeignKeys) > 0 {
+		fmt.Println("Foreign keys:")
+		for _, fk := range desc.ForeignKeys {
+			fmt.Printf("    %s (%s) REFERENCES %s(%s)\n", fk.Name, strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "))
+		}
+	}
+	return nil
+}
+
+// showIndexes lists the indexes whose name or table matches pattern
+func showIndexes(ctx context.Context, conn *db.Connection, pattern string) error {
+	indexes, err := conn.ListIndexes(ctx, "", pattern)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		ui.ShowInfo("No matching indexes found.")
+		return nil
+	}
+	rows := make([][]string, 0, len(indexes))
+	for _, index := range indexes {
+		kind := "index"
+		if index.Primary {
+			kind = "primary key"
+		} else if index.Unique {
+			kind = "unique"
+		}
+		rows = append(rows, []string{index.Table, index.Name, strings.Join(index.Columns, ", "), kind})
+	}
+	ui.PrintTable([]string{"Table", "Index", "Columns", "Kind"}, rows)
+	fmt.Printf("%d index(es)\n", len(rows))
+	return nil
+}
+
+// showDatabases lists the databases on the server, marking the current one
+func showDatabases(ctx context.Context, conn *db.Connection, current string) error {
+	databases, err := conn.ListDatabases(ctx)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(databases))
+	for _, name := range databases {
+		mark := ""
+		if name == current {
+			mark = "*"
+		}
+		rows = append(rows, []string{name, mark})
+	}
+	ui.PrintTable([]string{"Database", "Current"}, rows)
+	fmt.Printf("%d database(s)\n", len(rows))
+	return nil
+}
+
+// runSQLFile runs the statements of a SQL file one by one, stopping at the first that fails or is cancelled
+// Each statement is checked by the risk policy and confirmed like one typed with /sql
+func runSQLFile(env metaEnv, path string) error {
+	if path == "" {
+		return fmt.Errorf("file name is required")
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var statements []string
+	for _, st := range sqlparse.Parse(string(content), sqlparse.DialectForDatabaseType(env.conn.DatabaseType())) {
+		if text := strings.TrimSpace(st.Text()); text != "" {
+			statements = append(statements, text)
+		}
+	}
+	if len(statements) == 0 {
+		ui.ShowInfo(fmt.Sprintf("No SQL statements in %s.", path))
+		return nil
+	}
+	for i, statement := range statements {
+		if !env.runSQL(statement) {
+			return fmt.Errorf("stopped at statement %d of %d", i+1, len(statements))
+		}
+	}
+	fmt.Println()
+	ui.ShowSuccess(fmt.Sprintf("Ran %d statement(s) from %s", len(statements), path))
+	return nil
+}
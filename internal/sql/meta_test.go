@@ -0,0 +1,298 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// captureOutput returns what fn prints to stdout
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	// Restored and closed even when fn stops the test
+	defer func() { os.Stdout = stdout }()
+	defer w.Close()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// writeDataDir writes data files to a temporary directory and returns it
+func writeDataDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// newFileConn opens a file source over an orders and a customers CSV file
+func newFileConn(t *testing.T) *db.Connection {
+	t.Helper()
+	dir := writeDataDir(t, map[string]string{
+		"orders.csv":    "id,customer_id,total\n1,10,9.5\n2,11,20\n",
+		"customers.csv": "id,name\n10,Ada\n11,Grace\n",
+	})
+	conn, err := db.NewConnection(dir, "file")
+	if err != nil {
+		t.Fatalf("Failed to open file source: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// metaRecorder records the statements and database switches a backslash command asks for
+type metaRecorder struct {
+	statements []string
+	databases  []string
+	failAt     int // 1-based statement that fails; 0 runs every statement
+	connectErr error
+}
+
+func (r *metaRecorder) env(conn *db.Connection) metaEnv {
+	timing := false
+	return metaEnv{
+		conn:     conn,
+		database: "main",
+		timing:   &timing,
+		runSQL: func(statement string) bool {
+			r.statements = append(r.statements, statement)
+			return len(r.statements) != r.failAt
+		},
+		connect: func(database string) error {
+			r.databases = append(r.databases, database)
+			return r.connectErr
+		},
+	}
+}
+
+// TestParseMetaCommand tests splitting backslash commands into name and argument
+func TestParseMetaCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		arg   string
+	}{
+		{input: `\dt`, name: `\dt`},
+		{input: `\dt ord*`, name: `\dt`, arg: "ord*"},
+		{input: `\d   orders  `, name: `\d`, arg: "orders"},
+		{input: `  \i /tmp/my file.sql`, name: `\i`, arg: "/tmp/my file.sql"},
+		{input: "\\c\tanalytics", name: `\c`, arg: "analytics"},
+		{input: `\`, name: `\`},
+		{input: "   "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, arg := parseMetaCommand(tt.input)
+			if name != tt.name || arg != tt.arg {
+				t.Errorf("Expected (%q, %q), got (%q, %q)", tt.name, tt.arg, name, arg)
+			}
+		})
+	}
+}
+
+// TestIsMetaCommand tests which inputs are backslash commands
+func TestIsMetaCommand(t *testing.T) {
+	for input, expected := range map[string]bool{
+		`\dt`:          true,
+		`\?`:           true,
+		"/help":        false,
+		"SELECT 1":     false,
+		"what is \\dt": false,
+		"":             false,
+	} {
+		if got := isMetaCommand(input); got != expected {
+			t.Errorf("isMetaCommand(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
+
+// TestParseToggle tests on/off arguments of \x and \timing
+func TestParseToggle(t *testing.T) {
+	tests := []struct {
+		arg      string
+		current  bool
+		expected bool
+		wantErr  bool
+	}{
+		{arg: "", current: false, expected: true},
+		{arg: "", current: true, expected: false},
+		{arg: "on", expected: true},
+		{arg: "ON", current: false, expected: true},
+		{arg: "off", current: true, expected: false},
+		{arg: "1", expected: true},
+		{arg: "false", current: true, expected: false},
+		{arg: "maybe", current: true, expected: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseToggle(tt.arg, tt.current)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseToggle(%q, %v) error = %v, wantErr %v", tt.arg, tt.current, err, tt.wantErr)
+		}
+		if got != tt.expected {
+			t.Errorf("parseToggle(%q, %v) = %v, expected %v", tt.arg, tt.current, got, tt.expected)
+		}
+	}
+}
+
+// TestHandleMetaCommand_Unknown tests that unknown commands are reported without touching the session
+func TestHandleMetaCommand_Unknown(t *testing.T) {
+	recorder := &metaRecorder{}
+	output := captureOutput(t, func() {
+		handleMetaCommand(context.Background(), recorder.env(newFileConn(t)), `\zz orders`)
+	})
+	if !strings.Contains(output, `Unknown command \zz`) {
+		t.Errorf("Expected unknown command warning, got %q", output)
+	}
+	if len(recorder.statements) > 0 || len(recorder.databases) > 0 {
+		t.Errorf("Expected no statements or database switches, got %v and %v", recorder.statements, recorder.databases)
+	}
+}
+
+// TestHandleMetaCommand_NoSource tests that schema commands need a source while display settings do not
+func TestHandleMetaCommand_NoSource(t *testing.T) {
+	recorder := &metaRecorder{}
+	env := recorder.env(nil)
+	for _, input := range []string{`\dt`, `\d orders`, `\i queries.sql`, `\c other`} {
+		output := captureOutput(t, func() { handleMetaCommand(context.Background(), env, input) })
+		name, _ := parseMetaCommand(input)
+		if !strings.Contains(output, name+" needs a database source") {
+			t.Errorf("%s: expected a source warning, got %q", input, output)
+		}
+	}
+	if len(recorder.statements) > 0 || len(recorder.databases) > 0 {
+		t.Errorf("Expected no statements or database switches, got %v and %v", recorder.statements, recorder.databases)
+	}
+
+	output := captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\timing on`) })
+	if !*env.timing || !strings.Contains(output, "Timing is on") {
+		t.Errorf(`Expected \timing to work without a source, got timing=%v and %q`, *env.timing, output)
+	}
+}
+
+// TestHandleMetaCommand_ListTables tests \dt with and without a pattern
+func TestHandleMetaCommand_ListTables(t *testing.T) {
+	env := (&metaRecorder{}).env(newFileConn(t))
+
+	output := captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\dt`) })
+	if !strings.Contains(output, "orders") || !strings.Contains(output, "customers") || !strings.Contains(output, "2 relation(s)") {
+		t.Errorf("Expected both tables, got %q", output)
+	}
+
+	output = captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\dt ord*`) })
+	if !strings.Contains(output, "orders") || strings.Contains(output, "customers") {
+		t.Errorf("Expected only orders, got %q", output)
+	}
+}
+
+// TestHandleMetaCommand_DescribeTable tests \d with a table name
+func TestHandleMetaCommand_DescribeTable(t *testing.T) {
+	env := (&metaRecorder{}).env(newFileConn(t))
+
+	output := captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\d orders`) })
+	for _, want := range []string{"Table orders", "customer_id", "total"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got %q", want, output)
+		}
+	}
+
+	output = captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\d missing`) })
+	if !strings.Contains(output, `\d failed`) {
+		t.Errorf("Expected an error for a missing table, got %q", output)
+	}
+}
+
+// TestHandleMetaCommand_Connect tests that \c switches databases through the session
+func TestHandleMetaCommand_Connect(t *testing.T) {
+	recorder := &metaRecorder{}
+	env := recorder.env(newFileConn(t))
+
+	output := captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\c`) })
+	if !strings.Contains(output, "main") || len(recorder.databases) > 0 {
+		t.Errorf("Expected the current database without a switch, got %q and %v", output, recorder.databases)
+	}
+
+	recorder.connectErr = errors.New("access denied")
+	output = captureOutput(t, func() { handleMetaCommand(context.Background(), env, `\c analytics`) })
+	if !reflect.DeepEqual(recorder.databases, []string{"analytics"}) {
+		t.Errorf("Expected a switch to analytics, got %v", recorder.databases)
+	}
+	if !strings.Contains(output, `\c failed: access denied`) {
+		t.Errorf("Expected the switch error, got %q", output)
+	}
+}
+
+// TestHandleMetaCommand_RunFile tests that \i runs each statement of a file and stops at the first failure
+func TestHandleMetaCommand_RunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.sql")
+	content := "-- totals\nSELECT count(*) FROM orders;\n\nSELECT name FROM customers WHERE name = 'a;b';\nSELECT 1;\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write SQL file: %v", err)
+	}
+	conn := newFileConn(t)
+
+	t.Run("all statements", func(t *testing.T) {
+		recorder := &metaRecorder{}
+		output := captureOutput(t, func() { handleMetaCommand(context.Background(), recorder.env(conn), `\i `+path) })
+		if len(recorder.statements) != 3 {
+			t.Fatalf("Expected 3 statements, got %d: %v", len(recorder.statements), recorder.statements)
+		}
+		if !strings.Contains(recorder.statements[1], "'a;b'") {
+			t.Errorf("Expected the quoted semicolon to stay in the statement, got %q", recorder.statements[1])
+		}
+		if !strings.Contains(output, "Ran 3 statement(s)") {
+			t.Errorf("Expected a success message, got %q", output)
+		}
+	})
+
+	t.Run("stops at failure", func(t *testing.T) {
+		recorder := &metaRecorder{failAt: 2}
+		output := captureOutput(t, func() { handleMetaCommand(context.Background(), recorder.env(conn), `\i `+path) })
+		if len(recorder.statements) != 2 {
+			t.Errorf("Expected 2 statements to run, got %d", len(recorder.statements))
+		}
+		if !strings.Contains(output, "stopped at statement 2 of 3") {
+			t.Errorf("Expected a stop message, got %q", output)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		recorder := &metaRecorder{}
+		output := captureOutput(t, func() {
+			handleMetaCommand(context.Background(), recorder.env(conn), `\i `+filepath.Join(t.TempDir(), "missing.sql"))
+		})
+		if !strings.Contains(output, "failed to read file") || len(recorder.statements) > 0 {
+			t.Errorf("Expected a read error and no statements, got %q and %v", output, recorder.statements)
+		}
+	})
+
+	t.Run("no file name", func(t *testing.T) {
+		output := captureOutput(t, func() { handleMetaCommand(context.Background(), (&metaRecorder{}).env(conn), `\i`) })
+		if !strings.Contains(output, "file name is required") {
+			t.Errorf("Expected a missing file name error, got %q", output)
+		}
+	})
+}
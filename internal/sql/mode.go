@@ -9,14 +9,19 @@ import (
 
 	"github.com/chzyer/readline"
 
+	"github.com/aiq/aiq/internal/cache"
 	"github.com/aiq/aiq/internal/chart"
 	"github.com/aiq/aiq/internal/config"
 	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/history"
 	"github.com/aiq/aiq/internal/llm"
+	"github.com/aiq/aiq/internal/mcp"
+	"github.com/aiq/aiq/internal/policy"
 	"github.com/aiq/aiq/internal/session"
 	"github.com/aiq/aiq/internal/skills"
 	"github.com/aiq/aiq/internal/source"
 	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/tool/custom"
 	"github.com/aiq/aiq/internal/ui"
 )
 
@@ -85,6 +90,45 @@ func readlineWithHint(rl *readline.Instance, buildPrompt func() string, commands
 	return line, nil
 }
 
+// loadInputHistory fills the readline history with the saved input history, replacing what it held
+func loadInputHistory(rl *readline.Instance, h *history.History) {
+	if h == nil {
+		return
+	}
+	rl.ResetHistory()
+	for _, entry := range h.Entries() {
+		rl.SaveHistory(history.Recall(entry))
+	}
+}
+
+// rememberInput saves a submitted input to the input history and adds it to the readline history
+func rememberInput(rl *readline.Instance, h *history.History, input string) {
+	if h == nil {
+		return
+	}
+	added, err := h.Add(input)
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to save input history: %v", err))
+	}
+	if added {
+		rl.SaveHistory(history.Recall(strings.TrimSpace(input)))
+	} else if err == nil {
+		fmt.Println(ui.HintText("  (not saved to input history: it looks like it contains a secret)"))
+	}
+}
+
+// directSQL returns the SQL statement to run without the LLM: input after /sql, or any input in SQL mode
+// that is not a / command
+func directSQL(input string, sqlMode bool) (string, bool) {
+	if fields := strings.Fields(input); len(fields) > 1 && strings.ToLower(fields[0]) == "/sql" {
+		return strings.TrimSpace(input[len(fields[0]):]), true
+	}
+	if sqlMode && !strings.HasPrefix(input, "/") {
+		return input, true
+	}
+	return "", false
+}
+
 // InputMode represents the input mode (single-line or multi-line)
 type InputMode int
 
@@ -107,8 +151,8 @@ func readMultiLineInput(rl *readline.Instance, buildPrompt func() string, comman
 
 	trimmed := strings.TrimSpace(firstLine)
 
-	// System commands are always single line, execute immediately
-	if strings.HasPrefix(trimmed, "/") {
+	// System and backslash commands are always single line, execute immediately
+	if strings.HasPrefix(trimmed, "/") || isMetaCommand(trimmed) {
 		return firstLine, nil
 	}
 
@@ -194,7 +238,7 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 			// Build menu items with sources and skip option
 			items := make([]ui.MenuItem, 0, len(sources)+1)
 			for _, s := range sources {
-				label := fmt.Sprintf("%s (%s/%s:%d/%s)", s.Name, s.Type, s.Host, s.Port, s.Database)
+				label := fmt.Sprintf("%s (%s/%s)", s.Name, s.Type, s.Location())
 				items = append(items, ui.MenuItem{Label: label, Value: s.Name})
 			}
 			items = append(items, ui.MenuItem{Label: "Skip (free mode) - General conversation and Skills only", Value: "__free_mode__"})
@@ -235,7 +279,7 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 				} else {
 					items := make([]ui.MenuItem, 0, len(sources)+1)
 					for _, s := range sources {
-						label := fmt.Sprintf("%s (%s/%s:%d/%s)", s.Name, s.Type, s.Host, s.Port, s.Database)
+						label := fmt.Sprintf("%s (%s/%s)", s.Name, s.Type, s.Location())
 						items = append(items, ui.MenuItem{Label: label, Value: s.Name})
 					}
 					items = append(items, ui.MenuItem{Label: "Skip (free mode) - General conversation and Skills only", Value: "__free_mode__"})
@@ -274,11 +318,23 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	// Tools disabled in config.yaml are neither offered to the LLM nor executed
+	tool.Default().Configure(cfg.Tools)
+
+	// A restored session continues on the database it last used (see /use)
+	if overrideDatabase == "" && src != nil && src.Name == sess.Metadata.DataSource {
+		overrideDatabase = sess.LastDatabase()
+	}
 
 	// Create database connection only if source exists
-	var conn *db.Connection
-	var schema *db.Schema
+	// /source, /use and \c replace the connection, so the deferred call closes the current one
+	cur := &chatSource{src: src, connect: connectSource}
 	ctx := context.Background() // Create context for use throughout the function
+	defer func() {
+		if cur.conn != nil {
+			cur.conn.Close()
+		}
+	}()
 	if src != nil {
 		var err error
 		// If overrideDatabase is provided, create a temporary source copy with overridden database
@@ -289,20 +345,26 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 			tempSource.Database = overrideDatabase
 			actualSource = &tempSource
 		}
-		conn, err = db.NewConnection(actualSource.DSN(), string(actualSource.Type))
+		cur.database = actualSource.Database
+		cur.conn, err = connectSource(actualSource)
 		if err != nil {
 			return fmt.Errorf("failed to connect to database: %w", err)
 		}
-		defer conn.Close()
 
-		// Fetch schema for context (use actualSource.Database which may be overridden)
-		schema, err = conn.GetSchema(ctx, actualSource.Database)
+		// Fetch the table list for context (use actualSource.Database which may be overridden)
+		cur.tables, err = cur.conn.SearchTables(ctx, actualSource.Database, "")
 		if err != nil {
 			ui.ShowWarning(fmt.Sprintf("Failed to fetch schema: %v. Continuing without schema context.", err))
-			schema = &db.Schema{}
 		}
 	}
 
+	// Sources attached to the session with /attach, reconnected when a session is restored
+	var attached []*attachedSource
+	if src != nil {
+		attached = reattachSources(ctx, sess, src.Name)
+	}
+	defer func() { closeAttached(attached) }()
+
 	// Initialize Skills manager
 	skillsManager := skills.NewManager()
 	if err := skillsManager.Initialize(); err != nil {
@@ -348,61 +410,99 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 		fmt.Print(ui.HintText("No skills found"))
 		fmt.Println()
 	}
+	// Show user-defined tools from ~/.aiq/tools
+	customTools := custom.Loaded()
+	if tools := customTools.List(); len(tools) > 0 {
+		toolNames := make([]string, 0, len(tools))
+		for _, t := range tools {
+			toolNames = append(toolNames, ui.HighlightText(t.Name))
+		}
+		fmt.Print(ui.InfoText("Tools: "))
+		fmt.Print(strings.Join(toolNames, ", "))
+		fmt.Println()
+	}
+	for _, err := range customTools.Errors() {
+		ui.ShowWarning(fmt.Sprintf("Skipping tool manifest %v", err))
+	}
+	// Connect MCP servers from the mcp section of config.yaml
+	if len(cfg.MCP.Servers) > 0 {
+		stopLoading := ui.ShowLoading("Connecting MCP servers...")
+		mcpManager := mcp.Start(ctx, cfg.MCP)
+		stopLoading()
+		defer mcp.Stop()
+		var connected []string
+		for _, server := range mcpManager.Servers() {
+			if server.Err != nil {
+				ui.ShowWarning(fmt.Sprintf("MCP server %s is unavailable: %v", server.Name, server.Err))
+				continue
+			}
+			connected = append(connected, fmt.Sprintf("%s (%d tools)", ui.HighlightText(server.Name), len(server.Tools)))
+			for _, reason := range server.Skipped {
+				ui.ShowWarning(fmt.Sprintf("MCP server %s: skipping %s", server.Name, reason))
+			}
+		}
+		if len(connected) > 0 {
+			fmt.Print(ui.InfoText("MCP: "))
+			fmt.Print(strings.Join(connected, ", "))
+			fmt.Println()
+		}
+	}
 	fmt.Print(ui.HintText("Tip: Use '/help' for commands, ask questions in natural language"))
 	fmt.Println()
 	fmt.Println()
 
-	// Store last generated SQL for execute command
-	var lastGeneratedSQL string
+	// Load the risk policy with overrides for this source (~/.aiq/config/policy.yaml)
+	policySource := ""
+	if src != nil {
+		policySource = src.Name
+	}
+	if err := policy.SetSource(policySource); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to load risk policy: %v. All operations except blocked ones will require confirmation.", err))
+	}
 
-	// Determine actual database being used (may be overridden)
-	actualDatabase := ""
+	// Last successful call that ran SQL, by the LLM or typed directly, re-run by /execute
+	var lastSQLCall *llm.ToolCall
+
+	// The source history of the session records where it started
 	if src != nil {
-		if overrideDatabase != "" {
-			actualDatabase = overrideDatabase
-		} else {
-			actualDatabase = src.Database
-		}
+		sess.RecordSource(src.Name, string(src.Type), cur.database)
 	}
 
+	// Set by /nocache: the next request runs its queries against the database instead of the result cache
+	noCache := false
+
+	// SQL mode (/sql): input is run as SQL directly instead of being sent to the LLM
+	sqlMode := false
+
+	// Set by \timing: show how long each query takes
+	timing := false
+
 	// Input mode: default is single-line mode
 	inputMode := InputModeSingleLine
 
 	// Build dynamic prompt based on source availability and actual database
 	// Use different separators/colors to distinguish source and database
-	// Include mode indicator for multi-line mode
-	var buildPrompt func() string
-	if src != nil {
-		if actualDatabase != "" {
-			buildPrompt = func() string {
-				modeIndicator := ""
-				if inputMode == InputModeMultiLine {
-					modeIndicator = ui.HintText("[multi-line] ")
-				}
-				// Use @ to separate source and database for better distinction
-				return modeIndicator + ui.InfoText(fmt.Sprintf("aiq[%s@%s]> ", src.Name, actualDatabase))
-			}
-		} else {
-			buildPrompt = func() string {
-				modeIndicator := ""
-				if inputMode == InputModeMultiLine {
-					modeIndicator = ui.HintText("[multi-line] ")
-				}
-				return modeIndicator + ui.InfoText(fmt.Sprintf("aiq[%s]> ", src.Name))
-			}
+	// Include mode indicator for multi-line mode and an indicator while a transaction is open
+	promptPrefix := func() string {
+		prefix := ""
+		if cur.conn != nil && cur.conn.InTransaction() {
+			prefix += ui.WarningText("[tx] ")
 		}
-	} else {
-		buildPrompt = func() string {
-			modeIndicator := ""
-			if inputMode == InputModeMultiLine {
-				modeIndicator = ui.HintText("[multi-line] ")
-			}
-			return modeIndicator + ui.InfoText("aiq> ")
+		if inputMode == InputModeMultiLine {
+			prefix += ui.HintText("[multi-line] ")
 		}
+		if sqlMode {
+			prefix += ui.HintText("[sql] ")
+		}
+		return prefix
+	}
+	// The database is read on every call since \c switches it
+	buildPrompt := func() string {
+		return promptPrefix() + ui.InfoText(cur.prompt())
 	}
 
 	// Define available commands for hint display
-	commands := []string{"/exit", "/help", "/history", "/clear", "/paste", "/multiline", "/singleline"}
+	commands := []string{"/exit", "/help", "/history", "/clear", "/paste", "/multiline", "/singleline", "/begin", "/commit", "/rollback", "/restore", "/attach", "/detach", "/nocache", "/sql", "/execute", "/source", "/use"}
 	commandDescriptions := map[string]string{
 		"/exit":       "Exit chat mode",
 		"/help":       "Show help",
@@ -411,39 +511,116 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 		"/paste":      "Enter paste mode for multi-line SQL",
 		"/multiline":  "Switch to multi-line input mode (Enter continues, empty line submits)",
 		"/singleline": "Switch to single-line input mode (Enter executes immediately)",
+		"/begin":      "Start a transaction",
+		"/commit":     "Commit the open transaction",
+		"/rollback":   "Roll back the open transaction",
+		"/restore":    "Undo a backed-up UPDATE/DELETE/TRUNCATE",
+		"/attach":     "Attach another source to query alongside this one",
+		"/detach":     "Detach an attached source",
+		"/nocache":    "Run the next question against the database, not cached results",
+		"/sql":        "Toggle SQL mode: input runs as SQL without the AI (/sql <statement> runs one)",
+		"/execute":    "Run the last executed SQL again",
+		"/source":     "Switch to another source, keeping the conversation",
+		"/use":        "Switch to another database of the source",
 	}
 
-	// Define command completer for Tab completion (only for / commands)
-	// Create completer with descriptions for better UX
-	completer := readline.NewPrefixCompleter()
-	for _, cmd := range commands {
-		desc := commandDescriptions[cmd]
-		if desc != "" {
-			// Add description as a child item for better hint display
-			completer.Children = append(completer.Children, readline.PcItem(cmd, readline.PcItem(desc)))
-		} else {
-			completer.Children = append(completer.Children, readline.PcItem(cmd))
-		}
+	// Tab completes commands and their arguments, table and column names, skill names and SQL keywords
+	completer := &chatCompleter{
+		commands: append([]string(nil), commands...),
+		sources: func() []string {
+			sources, _ := source.LoadSources()
+			names := make([]string, 0, len(sources))
+			for _, s := range sources {
+				names = append(names, s.Name)
+			}
+			return names
+		},
+		attached: func() []string {
+			names := make([]string, 0, len(attached))
+			for _, a := range attached {
+				names = append(names, a.src.Name)
+			}
+			return names
+		},
+		conn:    func() *db.Connection { return cur.conn },
+		sqlMode: func() bool { return sqlMode },
+	}
+	for _, cmd := range metaCommands {
+		completer.commands = append(completer.commands, cmd.name)
+	}
+	for _, md := range skillsManager.GetMetadata() {
+		completer.skills = append(completer.skills, md.Name)
+	}
+	completer.SetTables(cur.tables)
+
+	// Input history is kept per source in ~/.aiq/history rather than in a readline history file,
+	// so that a multi-line input is saved as one entry and lines that look like secrets are left out
+	historySource := ""
+	if src != nil {
+		historySource = src.Name
+	}
+	inputHistory, err := history.ForSource(historySource)
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to load input history: %v. Continuing without saved history.", err))
+	}
+	historyLimit := history.DefaultMaxEntries
+	if inputHistory != nil {
+		historyLimit = inputHistory.MaxEntries()
 	}
 
 	// Use readline for better Unicode/Chinese character support
+	// Up/Down browse the input history and Ctrl+R searches it
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          buildPrompt(),
-		HistoryFile:     "",
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
-		AutoComplete:    completer,
+		Prompt:                 buildPrompt(),
+		HistoryFile:            "",
+		HistoryLimit:           historyLimit,
+		DisableAutoSaveHistory: true,
+		HistorySearchFold:      true,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "exit",
+		AutoComplete:           completer,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize readline: %w", err)
 	}
 	defer rl.Close()
-
-	// Update prompt dynamically (readline doesn't support dynamic prompts directly,
-	// but we can recreate it if source changes in future)
-	// For now, prompt is set once at initialization
+	loadInputHistory(rl, inputHistory)
+
+	// A switch (/source, /use, \c) also updates the completer and, for another source, the attached sources,
+	// risk policy and input history
+	cur.onSwitch = func(sourceChanged bool) {
+		completer.SetTables(cur.tables)
+		// SQL of the previous source or database may mean something else here
+		lastSQLCall = nil
+		if !sourceChanged {
+			return
+		}
+		// The new source cannot also be attached to itself
+		for i, a := range attached {
+			if a.src.Name == cur.src.Name {
+				a.conn.Close()
+				attached = append(attached[:i], attached[i+1:]...)
+				sess.DetachSource(cur.src.Name)
+				break
+			}
+		}
+		if err := policy.SetSource(cur.src.Name); err != nil {
+			ui.ShowWarning(fmt.Sprintf("Failed to load risk policy: %v. All operations except blocked ones will require confirmation.", err))
+		}
+		if inputHistory, err = history.ForSource(cur.src.Name); err != nil {
+			ui.ShowWarning(fmt.Sprintf("Failed to load input history: %v. Continuing without saved history.", err))
+		}
+		if inputHistory != nil {
+			loadInputHistory(rl, inputHistory)
+		} else {
+			rl.ResetHistory()
+		}
+	}
 
 	for {
+		// Refresh the prompt so mode and transaction indicators stay current
+		rl.SetPrompt(buildPrompt())
+
 		// Read input based on current mode
 		query, err := readMultiLineInput(rl, buildPrompt, commands, commandDescriptions, inputMode)
 		if err != nil {
@@ -455,6 +632,7 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 			// EOF (Ctrl+D) - exit chat mode (only if no input collected)
 			if query == "" {
 				fmt.Println()
+				rollbackOnExit(cur.conn)
 				// Save session before exiting
 				timestamp := session.GetTimestamp()
 				sessionPath, err := session.GetSessionFilePath(timestamp)
@@ -480,11 +658,20 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 		if query == "" {
 			continue
 		}
+		// /paste saves the pasted text instead once it is read
+		if strings.ToLower(query) != "/paste" {
+			rememberInput(rl, inputHistory, query)
+		}
 
 		// Handle special commands - check /exit and /help first, then text commands
 		if strings.HasPrefix(query, "/") {
 			// Handle /exit command
 			if strings.ToLower(query) == "/exit" {
+				if !confirmExitWithTransaction(cur.conn) {
+					fmt.Println()
+					continue
+				}
+				rollbackOnExit(cur.conn)
 				// Save session before exiting
 				timestamp := session.GetTimestamp()
 				sessionPath, err := session.GetSessionFilePath(timestamp)
@@ -512,12 +699,28 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 				fmt.Println("  /paste      - Enter paste mode for multi-line SQL (press Ctrl+D to finish)")
 				fmt.Println("  /multiline  - Switch to multi-line input mode (Enter continues, empty line submits)")
 				fmt.Println("  /singleline - Switch to single-line input mode (Enter executes immediately)")
+				fmt.Println("  /begin      - Start a transaction (statements run in it until /commit or /rollback)")
+				fmt.Println("  /commit     - Commit the open transaction")
+				fmt.Println("  /rollback   - Roll back the open transaction")
+				fmt.Println("  /restore    - Undo a backed-up UPDATE/DELETE/TRUNCATE (/restore <id>, or pick from a list)")
+				fmt.Println("  /attach     - Attach another source to query alongside this one (/attach <source>; no name lists them)")
+				fmt.Println("  /detach     - Detach an attached source (/detach <source>)")
+				fmt.Println("  /nocache    - Run a question against the database, not cached results (/nocache <question>, or alone for the next one)")
+				fmt.Println("  /sql        - Toggle SQL mode, where input runs as SQL without the AI (/sql <statement> runs one statement)")
+				fmt.Println("  /execute    - Run the last executed SQL again")
+				fmt.Println("  /source     - Switch to another source, keeping the conversation (/source <name>; no name lists them)")
+				fmt.Println("  /use        - Switch to another database of the source (/use <database>)")
+				fmt.Println()
+				fmt.Println("Backslash commands as in psql and the mysql client: \\dt, \\d <table>, \\di, \\dv, \\l, \\c <database>, \\x, \\timing, \\i <file> (\\? lists them)")
 				fmt.Println()
 				modeText := "single-line"
 				if inputMode == InputModeMultiLine {
 					modeText = "multi-line"
 				}
 				fmt.Printf("Current input mode: %s\n", ui.HighlightText(modeText))
+				if sqlMode {
+					fmt.Printf("SQL mode is %s: input runs as SQL; /sql switches back to questions\n", ui.HighlightText("on"))
+				}
 				fmt.Println()
 				fmt.Println("You can also ask questions in natural language to query the database.")
 				fmt.Println()
@@ -544,6 +747,102 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 				continue
 			}
 
+			// Handle /begin, /commit and /rollback - explicit transaction control
+			if lower := strings.ToLower(query); lower == "/begin" || lower == "/commit" || lower == "/rollback" {
+				handleTransactionCommand(cur.conn, lower)
+				fmt.Println()
+				continue
+			}
+
+			// Handle /restore [id] - run compensating statements from a backup snapshot
+			if fields := strings.Fields(query); strings.ToLower(fields[0]) == "/restore" {
+				restoreSource := ""
+				if cur.src != nil {
+					restoreSource = cur.src.Name
+				}
+				handleRestoreCommand(ctx, cur.conn, restoreSource, strings.Join(fields[1:], " "))
+				fmt.Println()
+				continue
+			}
+
+			// Handle /attach [source] and /detach <source> - sources queried alongside the session's own
+			if fields := strings.Fields(query); strings.ToLower(fields[0]) == "/attach" || strings.ToLower(fields[0]) == "/detach" {
+				name := strings.Join(fields[1:], " ")
+				if strings.ToLower(fields[0]) == "/attach" {
+					handleAttachCommand(ctx, &attached, sess, cur.src, name)
+				} else {
+					handleDetachCommand(&attached, sess, name)
+				}
+				fmt.Println()
+				continue
+			}
+
+			// Handle /source [name] and /use [database] - switch what the session is connected to
+			if fields := strings.Fields(query); strings.ToLower(fields[0]) == "/source" || strings.ToLower(fields[0]) == "/use" {
+				arg := strings.TrimSpace(query[len(fields[0]):])
+				fmt.Println()
+				switch {
+				case strings.ToLower(fields[0]) == "/use" && arg == "":
+					if cur.src == nil || cur.database == "" {
+						ui.ShowWarning("Usage: /use <database>")
+					} else {
+						ui.ShowInfo(fmt.Sprintf("Using database %s. Switch with /use <database>; \\l lists the databases.", ui.SuccessText(cur.database)))
+					}
+				case strings.ToLower(fields[0]) == "/use":
+					if err := cur.useDatabase(ctx, sess, arg); err != nil {
+						ui.ShowError(fmt.Sprintf("Failed to switch database: %v", err))
+					}
+				case arg == "":
+					handleSourceList(cur.src)
+				default:
+					if err := cur.useSource(ctx, sess, arg); err != nil {
+						ui.ShowError(fmt.Sprintf("Failed to switch source: %v", err))
+					}
+				}
+				fmt.Println()
+				continue
+			}
+
+			// Handle /nocache [question] - bypass the result cache for one request
+			if fields := strings.Fields(query); strings.ToLower(fields[0]) == "/nocache" {
+				noCache = true
+				query = strings.TrimSpace(query[len(fields[0]):])
+				if query == "" {
+					fmt.Println()
+					if cache.Default() == nil {
+						ui.ShowInfo("The query result cache is off (cache.enabled in config.yaml), so questions always run against the database.")
+						fmt.Println()
+						noCache = false
+						continue
+					}
+					ui.ShowInfo("The next question runs its queries against the database; fresh results replace cached ones.")
+					fmt.Println()
+					continue
+				}
+			}
+
+			// Handle /sql [statement] - toggle SQL mode, or run one statement without the LLM
+			if fields := strings.Fields(query); strings.ToLower(fields[0]) == "/sql" {
+				if cur.conn == nil {
+					ui.ShowWarning("SQL mode needs a database source; select one with /source <name>.")
+					fmt.Println()
+					continue
+				}
+				statement := strings.TrimSpace(query[len(fields[0]):])
+				if statement == "" {
+					sqlMode = !sqlMode
+					fmt.Println()
+					if sqlMode {
+						ui.ShowInfo("SQL mode on: input runs as SQL directly, checked by the risk policy. /sql switches back to questions.")
+					} else {
+						ui.ShowInfo("SQL mode off: input is sent to the AI.")
+					}
+					fmt.Println()
+					continue
+				}
+				query = "/sql " + statement
+			}
+
 			// Handle /paste command - enter multi-line paste mode
 			if strings.ToLower(query) == "/paste" {
 				fmt.Println()
@@ -595,6 +894,9 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 				// If we collected paste content, continue to process it
 				if len(pasteLines) > 0 {
 					// query is already set above, continue to process it
+					if query != "/paste" {
+						rememberInput(rl, inputHistory, query)
+					}
 				} else {
 					continue
 				}
@@ -643,40 +945,67 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 			continue
 		}
 
-		// Handle execute command - execute last generated SQL
-		if strings.ToLower(query) == "execute" || strings.ToLower(query) == "/execute" {
-			if lastGeneratedSQL == "" {
-				ui.ShowWarning("No SQL query to execute. Please generate a query first.")
-				fmt.Println()
-				continue
-			}
-
-			// Execute the last generated SQL
-			stopLoading := ui.ShowLoading("Calling tool [execute_sql]...")
-			result, err := tool.ExecuteSQL(ctx, conn, lastGeneratedSQL)
-			stopLoading()
+		// After /nocache the queries of this request bypass the result cache
+		reqCtx := ctx
+		if noCache {
+			reqCtx = db.WithoutCache(ctx)
+			noCache = false
+		}
 
-			if err != nil {
-				// Error already displayed by tool
-				ui.ShowInfo("You can modify the query and try again.")
-				fmt.Println()
-				continue
+		// toolHandler creates the handler that runs tool calls for this request
+		newToolHandler := func() *ToolHandler {
+			h := NewToolHandler(cur.conn, skillsManager, llmClient)
+			h.SetBudget(cfg.Tools)
+			h.SetResultStore(sess.GetResults())
+			h.SetAttachedSources(attached)
+			h.SetTiming(timing)
+			if cur.src != nil {
+				h.SetSourceInfo(cur.src.Name, cur.database)
 			}
-			// Tool success message is displayed by tool.ExecuteSQL
+			return h
+		}
 
-			// Display results
+		// Backslash commands (psql/mysql client style) inspect the schema and change display settings
+		if isMetaCommand(query) {
+			handleMetaCommand(reqCtx, metaEnv{
+				conn:     cur.conn,
+				database: cur.database,
+				timing:   &timing,
+				runSQL: func(statement string) bool {
+					toolHandler := newToolHandler()
+					ok := toolHandler.RunSQL(reqCtx, statement)
+					if call := toolHandler.LastSQLCall(); call != nil {
+						lastSQLCall = call
+					}
+					return ok
+				},
+				connect: func(database string) error {
+					return cur.useDatabase(reqCtx, sess, database)
+				},
+			}, query)
 			fmt.Println()
-			if len(result.Rows) == 0 {
-				ui.ShowInfo("Query executed successfully. No rows returned.")
+			continue
+		}
+
+		// Handle execute command - run the last executed SQL again, checked like any other call
+		if strings.ToLower(query) == "execute" || strings.ToLower(query) == "/execute" {
+			if lastSQLCall == nil {
+				ui.ShowWarning("No SQL to execute yet. Ask a question or run SQL with /sql first.")
 				fmt.Println()
 				continue
 			}
-
-			ui.ShowSuccess(fmt.Sprintf("Query executed successfully. %d row(s) returned.", len(result.Rows)))
+			newToolHandler().RunToolCall(reqCtx, *lastSQLCall)
 			fmt.Println()
+			continue
+		}
 
-			// Automatically display as table for execute command
-			ui.PrintTable(result.Columns, result.Rows)
+		// SQL in SQL mode or after /sql runs directly, without an LLM round trip
+		if statement, direct := directSQL(query, sqlMode); direct {
+			toolHandler := newToolHandler()
+			toolHandler.RunSQL(reqCtx, statement)
+			if call := toolHandler.LastSQLCall(); call != nil {
+				lastSQLCall = call
+			}
 			fmt.Println()
 			continue
 		}
@@ -745,30 +1074,28 @@ func RunSQLModeWithSource(providedSourceName string, sessionFile string, overrid
 		// Prepare schema context (empty for free mode)
 		var schemaContext string
 		var databaseType string
-		if src != nil && schema != nil {
-			schemaContext = schema.FormatSchema()
-			if schemaContext == "" {
-				schemaContext = fmt.Sprintf("Currently connected to database: %s\nNo schema information available yet.", src.Database)
-			} else {
-				schemaContext = fmt.Sprintf("Currently connected to database: %s\n\n%s", src.Database, schemaContext)
-			}
-			databaseType = src.GetDatabaseType()
+		if cur.src != nil {
+			schemaContext = schemaOverview(cur.database, cur.tables) + sourcesOverview(cur.src, attached)
+			databaseType = cur.src.GetDatabaseType()
 		} else {
 			// Free mode: no schema context
 			schemaContext = ""
 			databaseType = ""
 		}
 
-		// Get tool definitions (including built-in tools)
-		tools := tool.GetLLMFunctionsWithBuiltin(conn)
+		// Get tool definitions of the tools available in the current mode
+		tools := tool.Default().Functions(tool.ModeFor(cur.conn))
 
 		// Create tool handler
-		toolHandler := NewToolHandler(conn, skillsManager, llmClient)
+		toolHandler := newToolHandler()
 
 		// Use tool calling loop - LLM decides which tools to call
 		// Note: "Thinking..." and "Waiting..." messages are handled inside HandleToolCallLoop
-		finalResponse, queryResult, completeMessages, err := toolHandler.HandleToolCallLoop(ctx, llmClient, query, schemaContext, databaseType, conversationHistory, tools, rawMessages)
+		finalResponse, queryResult, completeMessages, err := toolHandler.HandleToolCallLoop(reqCtx, llmClient, query, schemaContext, databaseType, conversationHistory, tools, rawMessages)
 
+		if call := toolHandler.LastSQLCall(); call != nil {
+			lastSQLCall = call
+		}
 		if err != nil {
 			ui.ShowError(fmt.Sprintf("Failed to process request: %v", err))
 			ui.ShowInfo("Please check your LLM configuration and try again.")
@@ -916,3 +1243,41 @@ func getChartTypeLabel(ct chart.ChartType) string {
 		return "Unknown chart type"
 	}
 }
+
+// maxPromptTables bounds the table names listed in the prompt
+const maxPromptTables = 200
+
+// schemaOverview returns the schema context of the prompt: the table names only, since the LLM
+// looks up columns, keys and data with the schema exploration tools when it needs them
+func schemaOverview(database string, tables []db.TableSummary) string {
+	var builder strings.Builder
+	if database != "" {
+		builder.WriteString(fmt.Sprintf("Currently connected to database: %s\n", database))
+	} else {
+		// File sources have no database: each loaded file is a table
+		builder.WriteString("Currently connected to local data files, loaded as one table per file\n")
+	}
+	if len(tables) == 0 {
+		builder.WriteString("No tables found (or the table list could not be read).\n")
+	} else {
+		builder.WriteString(tableList(tables) + "\n")
+	}
+	builder.WriteString("Column details are not included: use describe_table before writing SQL against a table, sample_rows to see example data and column_values to learn the values a column uses.\n")
+	return builder.String()
+}
+
+// tableList lists table names for the prompt, at most maxPromptTables of them
+func tableList(tables []db.TableSummary) string {
+	names := make([]string, 0, len(tables))
+	for i, t := range tables {
+		if i == maxPromptTables {
+			break
+		}
+		names = append(names, t.Name)
+	}
+	list := fmt.Sprintf("Tables (%d): %s", len(tables), strings.Join(names, ", "))
+	if len(tables) > maxPromptTables {
+		list += fmt.Sprintf(", ... and %d more (use list_tables with a filter)", len(tables)-maxPromptTables)
+	}
+	return list
+}
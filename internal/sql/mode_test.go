@@ -0,0 +1,39 @@
+package sql
+
+import "testing"
+
+// TestDirectSQL tests which inputs bypass the LLM and run as SQL
+func TestDirectSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		sqlMode   bool
+		statement string
+		direct    bool
+	}{
+		{name: "sql command", input: "/sql SELECT 1", statement: "SELECT 1", direct: true},
+		{name: "sql command is case-insensitive", input: "/SQL  SELECT * FROM orders", statement: "SELECT * FROM orders", direct: true},
+		{name: "sql command with tab", input: "/sql\tSELECT 1", statement: "SELECT 1", direct: true},
+		{name: "sql command keeps the rest of the line", input: "/sql SELECT 'a  b' ", statement: "SELECT 'a  b'", direct: true},
+		{name: "sql command in sql mode", input: "/sql SELECT 1", sqlMode: true, statement: "SELECT 1", direct: true},
+		{name: "sql command without statement", input: "/sql"},
+		{name: "sql command without statement in sql mode", input: "/sql", sqlMode: true},
+		{name: "other command with sql prefix", input: "/sqlite SELECT 1"},
+		{name: "plain input", input: "show me the top customers"},
+		{name: "plain input in sql mode", input: "SELECT count(*) FROM orders", sqlMode: true, statement: "SELECT count(*) FROM orders", direct: true},
+		{name: "command in sql mode", input: "/help", sqlMode: true},
+		{name: "command with arguments in sql mode", input: "/source prod", sqlMode: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, direct := directSQL(tt.input, tt.sqlMode)
+			if direct != tt.direct {
+				t.Fatalf("Expected direct=%v, got %v", tt.direct, direct)
+			}
+			if statement != tt.statement {
+				t.Errorf("Expected statement %q, got %q", tt.statement, statement)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aiq/aiq/internal/llm"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// maxParallelToolCalls bounds the tool calls of one LLM response that run at the same time
+// Queries run on separate connections of the pool (see db.NewConnection), which stays below its limit
+const maxParallelToolCalls = 4
+
+// parallelResult is the outcome of a tool call executed ahead of the tool call loop
+type parallelResult struct {
+	result   json.RawMessage
+	err      error
+	duration time.Duration
+}
+
+// runParallel concurrently executes the consecutive independent calls starting at toolCalls[start] and
+// returns their outcomes by call index, or nil when fewer than two calls qualify
+// Only low-risk read-only calls that need no confirmation and print nothing while running qualify; the loop
+// displays their outcome in call order and runs everything else serially, so no call runs before an earlier
+// write of the same response. Nothing runs ahead while a transaction is open, since its statements share one connection
+func (h *ToolHandler) runParallel(ctx context.Context, toolCalls []llm.ToolCall, start int, usage *turnUsage) map[int]*parallelResult {
+	if h.conn != nil && h.conn.InTransaction() {
+		return nil
+	}
+
+	type parallelCall struct {
+		index   int
+		tool    *tool.Tool
+		args    map[string]interface{}
+		handler *ToolHandler // Scoped to the source the call names
+	}
+	var calls []parallelCall
+	pending := make(map[string]int) // Calls of the run, by callKey
+	for i := start; i < len(toolCalls); i++ {
+		t, args, ch, ok := h.parallelizable(toolCalls[i])
+		// Calls the loop will skip as repeated are not run ahead either
+		key := callKey(toolCalls[i].Function.Name, args)
+		if !ok || usage.wouldRepeat(toolCalls[i].Function.Name, args, pending[key]+1) {
+			break
+		}
+		pending[key]++
+		calls = append(calls, parallelCall{index: i, tool: t, args: args, handler: ch})
+	}
+	if len(calls) < 2 {
+		return nil
+	}
+
+	results := make([]parallelResult, len(calls))
+	stopWaiting := ui.ShowLoading(fmt.Sprintf("Running %d tool calls in parallel...", len(calls)))
+	sem := make(chan struct{}, maxParallelToolCalls)
+	var wg sync.WaitGroup
+	for j, call := range calls {
+		wg.Add(1)
+		go func(j int, call parallelCall) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			result, err := call.tool.Run(ctx, call.handler.env(call.args), call.args)
+			results[j] = parallelResult{result: result, err: err, duration: time.Since(start)}
+		}(j, call)
+	}
+	wg.Wait()
+	stopWaiting()
+
+	outcomes := make(map[int]*parallelResult, len(calls))
+	for j, call := range calls {
+		outcomes[call.index] = &results[j]
+	}
+	return outcomes
+}
+
+// parallelizable reports whether a call may run ahead of the loop: an available read-only tool,
+// not denied by policy and assessed low risk, so the loop will neither refuse it nor ask for confirmation
+// The returned handler is the one the call runs with (see forCall)
+func (h *ToolHandler) parallelizable(toolCall llm.ToolCall) (*tool.Tool, map[string]interface{}, *ToolHandler, bool) {
+	args, err := toolCall.ParseArguments()
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	name := toolCall.Function.Name
+	t, err := tool.Default().Get(name, tool.ModeFor(h.conn))
+	if err != nil || t.StreamsOutput || t.ReadOnly == nil || !t.ReadOnly(args) {
+		return nil, nil, nil, false
+	}
+	ch, err := h.forCall(t, args)
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	if _, denied := tool.CheckPolicy(name, args); denied {
+		return nil, nil, nil, false
+	}
+	if tool.GetRiskAssessor(name).AssessRisk(name, args) != tool.RiskLow {
+		return nil, nil, nil, false
+	}
+	return t, args, ch, true
+}
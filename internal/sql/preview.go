@@ -0,0 +1,229 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/audit"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// previewSampleRows is the number of before/after rows shown in a write preview
+const previewSampleRows = 5
+
+// previewOutcome is the result of a previewed UPDATE/DELETE
+type previewOutcome struct {
+	result   json.RawMessage // Tool result sent to the LLM
+	approval audit.Approval  // User confirmed (committed or failed) or rejected (rolled back)
+	duration time.Duration
+	err      error
+}
+
+// previewWrite runs a single-table UPDATE or DELETE inside a transaction, shows the exact number of affected rows,
+// the EXPLAIN plan and a sample of the rows before and after the change, then commits or rolls back
+// based on the user's answer
+// It returns false when the statement cannot be previewed; the caller then asks for a plain confirmation
+func (h *ToolHandler) previewWrite(ctx context.Context, sqlText string) (previewOutcome, bool) {
+	if h.conn == nil {
+		return previewOutcome{}, false
+	}
+	dialect := sqlparse.DialectForDatabaseType(h.conn.DatabaseType())
+	statements := sqlparse.Parse(sqlText, dialect)
+	if len(statements) != 1 || statements[0].Returning {
+		return previewOutcome{}, false
+	}
+	target, ok := statements[0].WriteTarget()
+	if !ok || target.Verb == "TRUNCATE" {
+		return previewOutcome{}, false
+	}
+
+	tx, err := h.conn.BeginTx(ctx)
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Preview unavailable: %v", err))
+		return previewOutcome{}, false
+	}
+	if transactional, err := tx.IsTransactional(ctx, target.TableParts); err == nil && !transactional {
+		tx.Rollback()
+		ui.ShowWarning(fmt.Sprintf("Preview unavailable: table %s does not support transactions, changes could not be rolled back", target.Name()))
+		return previewOutcome{}, false
+	}
+
+	// Preview queries must not abort the transaction if they fail, so they run under savepoints
+	explain, explainErr := tx.QueryIsolated(ctx, "EXPLAIN "+sqlText)
+	before, beforeErr := tx.QueryIsolated(ctx, buildSampleSelect(target))
+	var primaryKey []string
+	if target.Verb == "UPDATE" {
+		primaryKey, _ = tx.PrimaryKey(ctx, target.TableParts)
+	}
+
+	// Back up the affected rows as seen by the transaction; the snapshot is dropped if the change is rolled back
+	snap := h.captureBackup(ctx, tx, sqlText)
+
+	startTime := time.Now()
+	rowsAffected, execErr := tx.ExecuteNonQuery(ctx, sqlText)
+	if execErr != nil {
+		tx.Rollback()
+		discardBackup(snap)
+		ui.ShowError(fmt.Sprintf("Tool [execute_sql] failed: %v", execErr))
+		return previewOutcome{
+			result:   tool.ErrorResult(execErr),
+			approval: audit.ApprovalConfirmed,
+			duration: time.Since(startTime),
+			err:      execErr,
+		}, true
+	}
+
+	var after *db.QueryResult
+	var afterErr error
+	if target.Verb == "UPDATE" && beforeErr == nil && len(before.Rows) > 0 {
+		if afterSQL, ok := buildAfterSelect(target, dialect, primaryKey, before); ok {
+			after, afterErr = tx.QueryIsolated(ctx, afterSQL)
+		}
+	}
+	duration := time.Since(startTime)
+
+	fmt.Println()
+	if tx.IsNested() {
+		ui.ShowInfo("Preview (running under a savepoint of the open transaction):")
+	} else {
+		ui.ShowInfo("Preview (running in a transaction, nothing is committed yet):")
+	}
+	if explainErr == nil && len(explain.Columns) > 0 {
+		fmt.Println(ui.HintText("EXPLAIN:"))
+		printPreviewTable(explain)
+	}
+	if beforeErr != nil {
+		fmt.Println(ui.HintText(fmt.Sprintf("Before: sample unavailable (%v)", beforeErr)))
+	} else {
+		fmt.Println(ui.HintText(fmt.Sprintf("Before (up to %d matching rows):", previewSampleRows)))
+		printPreviewTable(before)
+	}
+	switch {
+	case target.Verb == "DELETE":
+		fmt.Println(ui.HintText("After: the rows above are deleted"))
+	case after != nil:
+		fmt.Println(ui.HintText("After:"))
+		printPreviewTable(after)
+	case afterErr != nil:
+		fmt.Println(ui.HintText(fmt.Sprintf("After: sample unavailable (%v)", afterErr)))
+	case beforeErr == nil && len(before.Rows) > 0 && len(primaryKey) == 0:
+		fmt.Println(ui.HintText("After: sample unavailable (table has no primary key)"))
+	}
+	fmt.Printf("%d row(s) affected\n", rowsAffected)
+	fmt.Println(ui.HintText("Affected rows stay locked until you answer."))
+	fmt.Println()
+
+	confirm, err := ui.ShowConfirm("Commit these changes?")
+	if err != nil || !confirm {
+		if err != nil {
+			fmt.Println()
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			ui.ShowError(rbErr.Error())
+		}
+		discardBackup(snap)
+		ui.ShowWarning("Changes rolled back.")
+		result, _ := json.Marshal(map[string]interface{}{
+			"status":  "cancelled",
+			"message": fmt.Sprintf("statement previewed (%d row(s) affected) and rolled back by user", rowsAffected),
+		})
+		return previewOutcome{result: result, approval: audit.ApprovalRejected, duration: duration}, true
+	}
+
+	if err := tx.Commit(); err != nil {
+		discardBackup(snap)
+		ui.ShowError(fmt.Sprintf("Tool [execute_sql] failed: %v", err))
+		return previewOutcome{result: tool.ErrorResult(err), approval: audit.ApprovalConfirmed, duration: duration, err: err}, true
+	}
+	fmt.Println()
+	fmt.Printf("Query OK, %d row(s) affected\n", rowsAffected)
+	if tx.IsNested() {
+		// Previewed inside a session transaction: the changes stay part of it until /commit or /rollback
+		ui.ShowSuccess("Changes kept in the open transaction (use /commit to make them permanent)")
+	} else {
+		ui.ShowSuccess("Changes committed")
+	}
+	result, _ := json.Marshal(map[string]interface{}{
+		"status":        "success",
+		"rows_affected": rowsAffected,
+		"committed":     !tx.IsNested(),
+		"displayed":     true,
+		"instruction":   "CRITICAL: The affected row count is already displayed to the user. Do NOT repeat this information. Return finish_reason='stop' with empty content (no text output) unless further steps are required.",
+	})
+	return previewOutcome{result: result, approval: audit.ApprovalConfirmed, duration: duration}, true
+}
+
+// buildSampleSelect selects the rows an UPDATE/DELETE will touch, using its own WHERE, ORDER BY and LIMIT
+func buildSampleSelect(target *sqlparse.WriteTarget) string {
+	var b strings.Builder
+	b.WriteString("SELECT * FROM ")
+	b.WriteString(target.Table)
+	if target.Alias != "" {
+		b.WriteString(" " + target.Alias)
+	}
+	if target.Where != "" {
+		b.WriteString(" WHERE " + target.Where)
+	}
+	if target.OrderBy != "" {
+		b.WriteString(" ORDER BY " + target.OrderBy)
+	}
+	// Never show rows past the statement's own LIMIT (MySQL only allows a literal row count there)
+	limit := previewSampleRows
+	if n, err := strconv.Atoi(strings.TrimSpace(target.Limit)); err == nil && n < limit {
+		limit = n
+	}
+	b.WriteString(fmt.Sprintf(" LIMIT %d", limit))
+	return b.String()
+}
+
+// buildAfterSelect re-reads the sampled rows by primary key, since an UPDATE may change the columns its WHERE tests
+func buildAfterSelect(target *sqlparse.WriteTarget, dialect sqlparse.Dialect, primaryKey []string, before *db.QueryResult) (string, bool) {
+	if len(primaryKey) == 0 {
+		return "", false
+	}
+	keyIndexes := make([]int, len(primaryKey))
+	for i, key := range primaryKey {
+		keyIndexes[i] = -1
+		for j, column := range before.Columns {
+			if strings.EqualFold(column, key) {
+				keyIndexes[i] = j
+				break
+			}
+		}
+		if keyIndexes[i] < 0 {
+			return "", false
+		}
+	}
+
+	var conditions []string
+	for _, row := range before.Rows {
+		parts := make([]string, len(primaryKey))
+		for i, key := range primaryKey {
+			value := row[keyIndexes[i]]
+			if value == "NULL" {
+				return "", false
+			}
+			parts[i] = fmt.Sprintf("%s = %s", sqlparse.QuoteIdent(key, dialect), sqlparse.QuoteLiteral(value, dialect))
+		}
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return fmt.Sprintf("SELECT * FROM %s WHERE %s", target.Table, strings.Join(conditions, " OR ")), true
+}
+
+// printPreviewTable renders a preview result set (mysql client style)
+func printPreviewTable(result *db.QueryResult) {
+	if len(result.Rows) == 0 {
+		fmt.Println("Empty set")
+		return
+	}
+	if output, err := tool.RenderTableString(result.Columns, result.Rows); err == nil {
+		fmt.Println(output)
+	}
+}
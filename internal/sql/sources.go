@@ -0,0 +1,340 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/cache"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/session"
+	"github.com/aiq/aiq/internal/source"
+	"github.com/aiq/aiq/internal/tool"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// attachedSource is a source attached to a chat session besides the session's own source
+// Tools with a source argument (execute_sql and the schema tools) run on it when they name it
+type attachedSource struct {
+	src    *source.Source
+	conn   *db.Connection
+	tables []db.TableSummary // Table list for the prompt
+}
+
+// attachSource connects to a configured source and reads its table list
+func attachSource(ctx context.Context, name string) (*attachedSource, error) {
+	src, err := source.GetSource(name)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.NewConnection(src.DSN(), string(src.Type))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	conn.SetCache(cache.Default(), src.Name+"/"+src.Database)
+	tables, err := conn.SearchTables(ctx, src.Database, "")
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to fetch schema of %s: %v. Continuing without its table list.", name, err))
+	}
+	return &attachedSource{src: src, conn: conn, tables: tables}, nil
+}
+
+// reattachSources connects the sources attached to a restored session; unavailable ones are detached
+func reattachSources(ctx context.Context, sess *session.Session, primary string) []*attachedSource {
+	var attached []*attachedSource
+	for _, name := range sess.Metadata.AttachedSources {
+		if name == primary {
+			continue
+		}
+		a, err := attachSource(ctx, name)
+		if err != nil {
+			ui.ShowWarning(fmt.Sprintf("Detaching source %s: %v", name, err))
+			sess.DetachSource(name)
+			continue
+		}
+		attached = append(attached, a)
+	}
+	return attached
+}
+
+// closeAttached closes the connections of attached sources
+func closeAttached(attached []*attachedSource) {
+	for _, a := range attached {
+		a.conn.Close()
+	}
+}
+
+// handleAttachCommand handles /attach [name]: without a name it lists the attached sources
+func handleAttachCommand(ctx context.Context, attached *[]*attachedSource, sess *session.Session, primary *source.Source, name string) {
+	if primary == nil {
+		ui.ShowWarning("Attaching sources needs a session source; select one with /source <name>.")
+		return
+	}
+	if name == "" {
+		if len(*attached) == 0 {
+			ui.ShowInfo("No sources attached. Use /attach <source> to query another source in this session.")
+			return
+		}
+		ui.ShowInfo("Attached sources:")
+		for _, a := range *attached {
+			fmt.Printf("  %s (%s/%s)\n", ui.HighlightText(a.src.Name), a.src.Type, a.src.Location())
+		}
+		return
+	}
+	if name == primary.Name {
+		ui.ShowInfo(fmt.Sprintf("%s is the session's source.", name))
+		return
+	}
+	for _, a := range *attached {
+		if a.src.Name == name {
+			ui.ShowInfo(fmt.Sprintf("%s is already attached.", name))
+			return
+		}
+	}
+
+	stopLoading := ui.ShowLoading(fmt.Sprintf("Connecting to %s...", name))
+	a, err := attachSource(ctx, name)
+	stopLoading()
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("Failed to attach source: %v", err))
+		return
+	}
+	*attached = append(*attached, a)
+	sess.AttachSource(name)
+	ui.ShowSuccess(fmt.Sprintf("Attached %s (%s, %d tables). Ask questions across sources; results are combined locally.", name, sourceKind(a.src), len(a.tables)))
+}
+
+// handleDetachCommand handles /detach <name>
+func handleDetachCommand(attached *[]*attachedSource, sess *session.Session, name string) {
+	if name == "" {
+		ui.ShowWarning("Usage: /detach <source>")
+		return
+	}
+	for i, a := range *attached {
+		if a.src.Name == name {
+			a.conn.Close()
+			*attached = append((*attached)[:i], (*attached)[i+1:]...)
+			sess.DetachSource(name)
+			ui.ShowInfo(fmt.Sprintf("Detached %s.", name))
+			return
+		}
+	}
+	ui.ShowWarning(fmt.Sprintf("%s is not attached.", name))
+}
+
+// sourcesOverview returns the prompt section listing the connected sources with their tables
+// Empty when no source is attached, so single-source prompts are unchanged
+func sourcesOverview(primary *source.Source, attached []*attachedSource) string {
+	if primary == nil || len(attached) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("\nConnected sources: %s (%s, the default)", primary.Name, primary.GetDatabaseType()))
+	for _, a := range attached {
+		builder.WriteString(fmt.Sprintf(", %s (%s)", a.src.Name, a.src.GetDatabaseType()))
+	}
+	builder.WriteString("\nTo query an attached source, pass its name as source to execute_sql, list_tables, describe_table, sample_rows or column_values, and write SQL in that source's dialect. " +
+		"A query cannot span sources: fetch from each source (filtered as far as possible), then combine the results with join_results.\n")
+	for _, a := range attached {
+		builder.WriteString(fmt.Sprintf("Source %s (%s): ", a.src.Name, sourceKind(a.src)))
+		if len(a.tables) == 0 {
+			builder.WriteString("no tables found (or the table list could not be read)\n")
+		} else {
+			builder.WriteString(tableList(a.tables) + "\n")
+		}
+	}
+	return builder.String()
+}
+
+// sourceKind describes a source by type and database; file sources have no database
+func sourceKind(src *source.Source) string {
+	if src.Database == "" {
+		return src.GetDatabaseType()
+	}
+	return fmt.Sprintf("%s, database %s", src.GetDatabaseType(), src.Database)
+}
+
+// sourceLabel describes the source and database a session uses, for messages and context notes
+func sourceLabel(src *source.Source, database string) string {
+	if src == nil {
+		return "free mode (no source)"
+	}
+	target := *src
+	target.Database = database
+	return fmt.Sprintf("source %s (%s)", src.Name, sourceKind(&target))
+}
+
+// handleSourceList handles /source without a name: it lists the configured sources, marking the current one
+func handleSourceList(current *source.Source) {
+	sources, err := source.LoadSources()
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("Failed to load sources: %v", err))
+		return
+	}
+	if len(sources) == 0 {
+		ui.ShowInfo("No data sources configured. Add one with source → add in the main menu.")
+		return
+	}
+	ui.ShowInfo("Sources (switch with /source <name>):")
+	for _, s := range sources {
+		mark := " "
+		if current != nil && s.Name == current.Name {
+			mark = "*"
+		}
+		fmt.Printf("%s %s (%s/%s)\n", mark, ui.HighlightText(s.Name), s.Type, s.Location())
+	}
+}
+
+// chatSource is the source and database a chat session is connected to; /source, /use and \c switch it
+type chatSource struct {
+	src      *source.Source // nil in free mode
+	database string         // Database in use, which /use and \c change without changing src
+	conn     *db.Connection
+	tables   []db.TableSummary // Table list for the prompt; columns are looked up with describe_table
+
+	connect  func(target *source.Source) (*db.Connection, error) // Opens the connection of a switch (connectSource)
+	onSwitch func(sourceChanged bool)                            // Updates the rest of the session after a switch
+}
+
+// connectSource connects to a source with the result cache, keyed by source and database
+func connectSource(target *source.Source) (*db.Connection, error) {
+	conn, err := db.NewConnection(target.DSN(), string(target.Type))
+	if err != nil {
+		return nil, err
+	}
+	// Identical read-only queries reuse stored results until the TTL expires or the data is changed
+	conn.SetCache(cache.Default(), target.Name+"/"+target.Database)
+	return conn, nil
+}
+
+// prompt returns the chat prompt naming the source and database, without mode indicators
+func (c *chatSource) prompt() string {
+	if c.src == nil {
+		return "aiq> "
+	}
+	if c.database != "" {
+		// Use @ to separate source and database for better distinction
+		return fmt.Sprintf("aiq[%s@%s]> ", c.src.Name, c.database)
+	}
+	return fmt.Sprintf("aiq[%s]> ", c.src.Name)
+}
+
+// label describes the source and database for messages and context notes
+func (c *chatSource) label() string {
+	return sourceLabel(c.src, c.database)
+}
+
+// useSource switches the session to the configured source name (/source)
+func (c *chatSource) useSource(ctx context.Context, sess *session.Session, name string) error {
+	if c.src != nil && c.src.Name == name {
+		ui.ShowInfo(fmt.Sprintf("Already using %s.", name))
+		return nil
+	}
+	target, err := source.GetSource(name)
+	if err != nil {
+		return err
+	}
+	return c.switchTo(ctx, sess, target)
+}
+
+// useDatabase switches the session to another database of its source (/use, \c)
+func (c *chatSource) useDatabase(ctx context.Context, sess *session.Session, database string) error {
+	if c.src == nil {
+		return fmt.Errorf("no source selected; use /source <name> first")
+	}
+	if c.src.Type == source.DatabaseTypeFile {
+		return fmt.Errorf("file sources have no databases: each loaded file is a table")
+	}
+	if database == c.database {
+		ui.ShowInfo(fmt.Sprintf("Already using database %s.", database))
+		return nil
+	}
+	target := *c.src
+	target.Database = database
+	return c.switchTo(ctx, sess, &target)
+}
+
+// switchTo reconnects the session to another source or database and reloads the table list; the session
+// records the new source and, once the conversation has started, a note that the context changed
+// The current connection is kept when the new one fails
+func (c *chatSource) switchTo(ctx context.Context, sess *session.Session, target *source.Source) error {
+	if c.conn != nil && c.conn.InTransaction() {
+		return fmt.Errorf("a transaction is open; /commit or /rollback it first")
+	}
+	stopLoading := ui.ShowLoading(fmt.Sprintf("Connecting to %s...", target.Name))
+	newConn, err := c.connect(target)
+	stopLoading()
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", target.Name, err)
+	}
+	newTables, err := newConn.SearchTables(ctx, target.Database, "")
+	if err != nil {
+		ui.ShowWarning(fmt.Sprintf("Failed to fetch schema: %v. Continuing without schema context.", err))
+	}
+
+	from := c.label()
+	sourceChanged := c.src == nil || c.src.Name != target.Name
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.src, c.database, c.conn, c.tables = target, target.Database, newConn, newTables
+	sess.RecordSource(target.Name, string(target.Type), target.Database)
+	if c.onSwitch != nil {
+		c.onSwitch(sourceChanged)
+	}
+
+	// Earlier messages stay in the conversation; the note tells the LLM they refer to the previous context
+	if rawMsgs := sess.GetRawMessages(); len(rawMsgs) > 0 {
+		sess.SetRawMessages(append(rawMsgs, contextChangeNote(from, c.label())))
+	}
+	ui.ShowSuccess(fmt.Sprintf("Now using %s (%d tables).", c.label(), len(c.tables)))
+	return nil
+}
+
+// contextNotePrefix starts the system notes left in the message history when the session switches source or
+// database; unlike the system messages of earlier requests they are sent to the LLM again
+const contextNotePrefix = "Context changed: "
+
+// contextChangeNote returns the system note recording that the session moved from one source or database to another
+func contextChangeNote(from, to string) json.RawMessage {
+	note, _ := json.Marshal(map[string]interface{}{
+		"role": "system",
+		"content": contextNotePrefix + fmt.Sprintf("the session switched from %s to %s. "+
+			"Messages before this note refer to %s; their tables, columns and SQL may not exist now. "+
+			"Use the current schema and look tables up again before querying them.", from, to, from),
+	})
+	return note
+}
+
+// isContextNote reports whether a message content is a note left by contextChangeNote
+func isContextNote(content interface{}) bool {
+	text, ok := content.(string)
+	return ok && strings.HasPrefix(text, contextNotePrefix)
+}
+
+// SetAttachedSources sets the sources calls may name in their source argument
+func (h *ToolHandler) SetAttachedSources(attached []*attachedSource) {
+	h.attached = attached
+}
+
+// forCall returns the handler a call runs with: for a MultiSource tool naming an attached source, a copy
+// using that source's connection, so previews, backups and audit records apply to it; otherwise h
+func (h *ToolHandler) forCall(t *tool.Tool, args map[string]interface{}) (*ToolHandler, error) {
+	name, _ := args["source"].(string)
+	if !t.MultiSource || name == "" || name == h.sourceName {
+		return h, nil
+	}
+	names := []string{h.sourceName}
+	for _, a := range h.attached {
+		if a.src.Name == name {
+			scoped := *h
+			scoped.conn = a.conn
+			scoped.sourceName = a.src.Name
+			scoped.databaseName = a.src.Database
+			return &scoped, nil
+		}
+		names = append(names, a.src.Name)
+	}
+	return nil, fmt.Errorf("source '%s' is not connected to this session (connected: %s)", name, strings.Join(names, ", "))
+}
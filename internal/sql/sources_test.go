@@ -0,0 +1,245 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/session"
+	"github.com/aiq/aiq/internal/source"
+)
+
+// switchRecorder records the onSwitch calls of a chatSource
+type switchRecorder struct {
+	calls []bool
+}
+
+func (r *switchRecorder) onSwitch(sourceChanged bool) {
+	r.calls = append(r.calls, sourceChanged)
+}
+
+func tableNames(tables []db.TableSummary) []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// saveFileSources configures file sources in a temporary home directory, one per name
+func saveFileSources(t *testing.T, files map[string]map[string]string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	var sources []*source.Source
+	for name, data := range files {
+		sources = append(sources, &source.Source{Name: name, Type: source.DatabaseTypeFile, Files: []string{writeDataDir(t, data)}})
+	}
+	if err := source.SaveSources(sources); err != nil {
+		t.Fatalf("Failed to save sources: %v", err)
+	}
+}
+
+// TestChatSource_UseSource tests that /source rebuilds the prompt and table list and notes the switch
+func TestChatSource_UseSource(t *testing.T) {
+	saveFileSources(t, map[string]map[string]string{
+		"sales": {"orders.csv": "id,total\n1,9.5\n", "customers.csv": "id,name\n1,Ada\n"},
+		"logs":  {"events.csv": "id,kind\n1,login\n"},
+	})
+	recorder := &switchRecorder{}
+	cur := &chatSource{connect: connectSource, onSwitch: recorder.onSwitch}
+	t.Cleanup(func() { cur.conn.Close() })
+	sess := session.NewSession("", "")
+	ctx := context.Background()
+
+	if cur.prompt() != "aiq> " {
+		t.Errorf("Expected the free mode prompt, got %q", cur.prompt())
+	}
+	captureOutput(t, func() {
+		if err := cur.useSource(ctx, sess, "sales"); err != nil {
+			t.Fatalf("Failed to switch to sales: %v", err)
+		}
+	})
+	if cur.prompt() != "aiq[sales]> " {
+		t.Errorf("Expected the sales prompt, got %q", cur.prompt())
+	}
+	if names := tableNames(cur.tables); !reflect.DeepEqual(names, []string{"customers", "orders"}) {
+		t.Errorf("Expected the sales tables, got %v", names)
+	}
+	if len(sess.GetRawMessages()) != 0 {
+		t.Errorf("Expected no context note before the conversation starts, got %d messages", len(sess.GetRawMessages()))
+	}
+
+	sess.SetRawMessages([]json.RawMessage{json.RawMessage(`{"role":"user","content":"how many orders?"}`)})
+	salesConn := cur.conn
+	captureOutput(t, func() {
+		if err := cur.useSource(ctx, sess, "logs"); err != nil {
+			t.Fatalf("Failed to switch to logs: %v", err)
+		}
+	})
+	if cur.prompt() != "aiq[logs]> " {
+		t.Errorf("Expected the logs prompt, got %q", cur.prompt())
+	}
+	if names := tableNames(cur.tables); !reflect.DeepEqual(names, []string{"events"}) {
+		t.Errorf("Expected the logs tables, got %v", names)
+	}
+	if err := salesConn.Ping(ctx); err == nil {
+		t.Error("Expected the previous connection to be closed")
+	}
+	if sess.Metadata.DataSource != "logs" {
+		t.Errorf("Expected the session source to be logs, got %q", sess.Metadata.DataSource)
+	}
+	rawMsgs := sess.GetRawMessages()
+	var note map[string]interface{}
+	if len(rawMsgs) != 2 || json.Unmarshal(rawMsgs[1], &note) != nil || !isContextNote(note["content"]) {
+		t.Fatalf("Expected a context note after the conversation, got %d messages", len(rawMsgs))
+	}
+	if !strings.Contains(note["content"].(string), "from source sales") {
+		t.Errorf("Expected the note to name the previous source, got %q", note["content"])
+	}
+
+	output := captureOutput(t, func() {
+		if err := cur.useSource(ctx, sess, "logs"); err != nil {
+			t.Errorf("Expected no error for the current source, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "Already using logs") {
+		t.Errorf("Expected an already using message, got %q", output)
+	}
+	if !reflect.DeepEqual(recorder.calls, []bool{true, true}) {
+		t.Errorf("Expected two source changes, got %v", recorder.calls)
+	}
+}
+
+// TestChatSource_UseSourceUnknown tests that an unknown source name leaves the session as it was
+func TestChatSource_UseSourceUnknown(t *testing.T) {
+	saveFileSources(t, map[string]map[string]string{
+		"sales": {"orders.csv": "id,total\n1,9.5\n"},
+	})
+	recorder := &switchRecorder{}
+	src, _ := source.GetSource("sales")
+	conn := newFileConn(t)
+	tables := []db.TableSummary{{Name: "orders", Type: "TABLE"}}
+	cur := &chatSource{src: src, conn: conn, tables: tables, connect: connectSource, onSwitch: recorder.onSwitch}
+	sess := session.NewSession("sales", "file")
+
+	err := cur.useSource(context.Background(), sess, "missing")
+	if err == nil {
+		t.Fatal("Expected an error for an unknown source")
+	}
+	if cur.src != src || cur.conn != conn || !reflect.DeepEqual(cur.tables, tables) || cur.prompt() != "aiq[sales]> " {
+		t.Errorf("Expected the session to stay on sales, got %s", cur.label())
+	}
+	if err := conn.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the connection to stay open, got %v", err)
+	}
+	if len(recorder.calls) > 0 || len(sess.Metadata.SourceHistory) > 0 {
+		t.Errorf("Expected no switch, got %v and %v", recorder.calls, sess.Metadata.SourceHistory)
+	}
+}
+
+// TestChatSource_UseDatabase tests that /use and \c reconnect to another database of the source
+func TestChatSource_UseDatabase(t *testing.T) {
+	databases := map[string]string{
+		"shop":      writeDataDir(t, map[string]string{"orders.csv": "id\n1\n"}),
+		"analytics": writeDataDir(t, map[string]string{"daily.csv": "day,total\n2024-01-01,3\n", "weekly.csv": "week,total\n1,3\n"}),
+	}
+	var targets []source.Source
+	connect := func(target *source.Source) (*db.Connection, error) {
+		targets = append(targets, *target)
+		return db.NewConnection(databases[target.Database], "file")
+	}
+	prod := &source.Source{Name: "prod", Type: source.DatabaseTypeMySQL, Database: "shop"}
+	conn, err := connect(prod)
+	if err != nil {
+		t.Fatalf("Failed to open shop: %v", err)
+	}
+	targets = nil
+	recorder := &switchRecorder{}
+	cur := &chatSource{src: prod, database: "shop", conn: conn, connect: connect, onSwitch: recorder.onSwitch}
+	t.Cleanup(func() { cur.conn.Close() })
+	sess := session.NewSession("prod", "mysql")
+
+	captureOutput(t, func() {
+		if err := cur.useDatabase(context.Background(), sess, "analytics"); err != nil {
+			t.Fatalf("Failed to switch database: %v", err)
+		}
+	})
+	if cur.prompt() != "aiq[prod@analytics]> " {
+		t.Errorf("Expected the analytics prompt, got %q", cur.prompt())
+	}
+	if names := tableNames(cur.tables); !reflect.DeepEqual(names, []string{"daily", "weekly"}) {
+		t.Errorf("Expected the analytics tables, got %v", names)
+	}
+	if len(targets) != 1 || targets[0].Name != "prod" || targets[0].Database != "analytics" {
+		t.Errorf("Expected one connection to prod/analytics, got %v", targets)
+	}
+	if prod.Database != "shop" {
+		t.Errorf("Expected the configured source to keep its database, got %q", prod.Database)
+	}
+	if sess.LastDatabase() != "analytics" {
+		t.Errorf("Expected the session to record analytics, got %q", sess.LastDatabase())
+	}
+
+	output := captureOutput(t, func() {
+		if err := cur.useDatabase(context.Background(), sess, "analytics"); err != nil {
+			t.Errorf("Expected no error for the current database, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "Already using database analytics") || len(targets) != 1 {
+		t.Errorf("Expected no reconnect for the current database, got %q and %d connections", output, len(targets))
+	}
+	if !reflect.DeepEqual(recorder.calls, []bool{false}) {
+		t.Errorf("Expected one database switch without a source change, got %v", recorder.calls)
+	}
+}
+
+// TestChatSource_UseDatabaseFailure tests that a failed database switch keeps the current connection
+func TestChatSource_UseDatabaseFailure(t *testing.T) {
+	conn := newFileConn(t)
+	tables := []db.TableSummary{{Name: "orders", Type: "TABLE"}}
+	recorder := &switchRecorder{}
+	cur := &chatSource{
+		src:      &source.Source{Name: "prod", Type: source.DatabaseTypePostgreSQL, Database: "shop"},
+		database: "shop",
+		conn:     conn,
+		tables:   tables,
+		connect: func(target *source.Source) (*db.Connection, error) {
+			return nil, errors.New(`database "nope" does not exist`)
+		},
+		onSwitch: recorder.onSwitch,
+	}
+	sess := session.NewSession("prod", "postgresql")
+
+	var err error
+	captureOutput(t, func() { err = cur.useDatabase(context.Background(), sess, "nope") })
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to prod") || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected a connection error, got %v", err)
+	}
+	if cur.conn != conn || cur.database != "shop" || !reflect.DeepEqual(cur.tables, tables) || cur.prompt() != "aiq[prod@shop]> " {
+		t.Errorf("Expected the session to stay on shop, got %s", cur.label())
+	}
+	if err := conn.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the connection to stay open, got %v", err)
+	}
+	if len(recorder.calls) > 0 || len(sess.Metadata.SourceHistory) > 0 {
+		t.Errorf("Expected no switch, got %v and %v", recorder.calls, sess.Metadata.SourceHistory)
+	}
+
+	t.Run("file source", func(t *testing.T) {
+		files := &chatSource{src: &source.Source{Name: "sales", Type: source.DatabaseTypeFile}, conn: conn}
+		if err := files.useDatabase(context.Background(), sess, "other"); err == nil || !strings.Contains(err.Error(), "file sources have no databases") {
+			t.Errorf("Expected a file source error, got %v", err)
+		}
+	})
+
+	t.Run("free mode", func(t *testing.T) {
+		free := &chatSource{}
+		if err := free.useDatabase(context.Background(), sess, "other"); err == nil || !strings.Contains(err.Error(), "no source selected") {
+			t.Errorf("Expected a missing source error, got %v", err)
+		}
+	})
+}
@@ -7,12 +7,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aiq/aiq/internal/audit"
+	"github.com/aiq/aiq/internal/backup"
+	"github.com/aiq/aiq/internal/config"
 	"github.com/aiq/aiq/internal/db"
 	"github.com/aiq/aiq/internal/llm"
 	"github.com/aiq/aiq/internal/prompt"
+	"github.com/aiq/aiq/internal/session"
 	"github.com/aiq/aiq/internal/skills"
 	"github.com/aiq/aiq/internal/tool"
-	"github.com/aiq/aiq/internal/tool/builtin"
 	"github.com/aiq/aiq/internal/ui"
 )
 
@@ -24,6 +27,13 @@ type ToolHandler struct {
 	promptBuilder *prompt.Builder
 	compressor    *prompt.Compressor
 	promptLoader  *prompt.Loader
+	sourceName    string // Recorded in audit log entries
+	databaseName  string // Recorded in audit log entries
+	budget        loopBudget
+	results       *session.ResultStore // Query results tools refer to by result_id; nil without a session
+	attached      []*attachedSource    // Sources besides the session's, named by the source argument
+	lastSQLCall   *llm.ToolCall        // Last successful call that ran SQL (see LastSQLCall)
+	timing        bool                 // Show how long SQL took to run (\timing)
 }
 
 // NewToolHandler creates a new tool handler
@@ -51,69 +61,49 @@ func NewToolHandler(conn *db.Connection, skillsManager *skills.Manager, llmClien
 		promptBuilder: prompt.NewBuilder(""), // Will be set in HandleToolCallLoop
 		compressor:    compressor,
 		promptLoader:  promptLoader,
+		budget:        newLoopBudget(config.ToolsConfig{}),
 	}
 }
 
+// SetResultStore sets the session's result store, so execute_sql results can be rendered by result_id
+func (h *ToolHandler) SetResultStore(results *session.ResultStore) {
+	h.results = results
+}
+
+// env returns the environment tools run in for a call with args
+func (h *ToolHandler) env(args map[string]interface{}) *tool.Env {
+	return &tool.Env{Conn: h.conn, OutputMode: tool.OutputMode(args), Results: h.results}
+}
+
+// SetBudget sets the per-request limits of the tool calling loop from the tools section of config.yaml
+func (h *ToolHandler) SetBudget(cfg config.ToolsConfig) {
+	h.budget = newLoopBudget(cfg)
+}
+
+// SetTiming makes the handler show how long each SQL call took to run
+func (h *ToolHandler) SetTiming(on bool) {
+	h.timing = on
+}
+
+// SetSourceInfo sets the source and database names recorded in audit log entries
+func (h *ToolHandler) SetSourceInfo(sourceName, databaseName string) {
+	h.sourceName = sourceName
+	h.databaseName = databaseName
+}
+
 // formatToolCall formats a tool call for display, truncating long arguments
+// Registered tools describe their own calls; other calls show the arguments
 func (h *ToolHandler) formatToolCall(toolCall llm.ToolCall) string {
 	toolName := toolCall.Function.Name
 	argsStr := toolCall.Function.Arguments
 
 	// Try to parse arguments to format them nicely
-	args, err := toolCall.ParseArguments()
-	if err != nil {
-		// If parsing fails, just show the raw arguments (truncated)
-		return h.formatToolCallWithRawArgs(toolName, argsStr)
-	}
-
-	// Format arguments based on tool type
-	switch toolName {
-	case "execute_sql":
-		if sql, ok := args["sql"].(string); ok {
-			return fmt.Sprintf("Calling tool [%s] with SQL: %s", toolName, h.truncateString(sql, 80))
-		}
-	case "execute_command":
-		if cmd, ok := args["command"].(string); ok {
-			return fmt.Sprintf("Calling tool [%s] with command: %s", toolName, h.truncateString(cmd, 80))
-		}
-	case "http_request":
-		if url, ok := args["url"].(string); ok {
-			method := "GET"
-			if m, ok := args["method"].(string); ok {
-				method = m
-			}
-			return fmt.Sprintf("Calling tool [%s] %s %s", toolName, method, h.truncateString(url, 60))
-		}
-	case "file_operations":
-		if op, ok := args["operation"].(string); ok {
-			if path, ok := args["path"].(string); ok {
-				return fmt.Sprintf("Calling tool [%s] %s: %s", toolName, op, h.truncateString(path, 60))
-			}
-			return fmt.Sprintf("Calling tool [%s] %s", toolName, op)
-		}
-	case "render_table", "render_chart":
-		if rows, ok := args["rows"].([]interface{}); ok {
-			rowCount := len(rows)
-			return fmt.Sprintf("Calling tool [%s] with %d row(s)", toolName, rowCount)
-		}
-	}
-
-	// Default: show tool name with truncated arguments (cleaned up for display)
-	displayStr := argsStr
-
-	// If it's a quoted JSON string, unquote it for cleaner display
-	if len(displayStr) >= 2 && displayStr[0] == '"' && displayStr[len(displayStr)-1] == '"' {
-		var unquoted string
-		if err := json.Unmarshal([]byte(displayStr), &unquoted); err == nil {
-			displayStr = unquoted
+	if args, err := toolCall.ParseArguments(); err == nil {
+		if t := tool.Lookup(toolName); t != nil && t.Describe != nil {
+			return fmt.Sprintf("Calling tool [%s] %s", toolName, t.Describe(args))
 		}
 	}
 
-	return fmt.Sprintf("Calling tool [%s] with args: %s", toolName, h.truncateString(displayStr, 60))
-}
-
-// formatToolCallWithRawArgs formats tool call when arguments can't be parsed
-func (h *ToolHandler) formatToolCallWithRawArgs(toolName, argsStr string) string {
 	// Clean up the JSON string for display - remove outer quotes and unescape
 	displayStr := argsStr
 
@@ -168,22 +158,12 @@ func formatQueryResultSummary(result *db.QueryResult) string {
 	return fmt.Sprintf("Query executed successfully. Returned %d row(s) with columns: %s. Sample data: %s", rowCount, columnsStr, sampleDataStr)
 }
 
-// ExecuteTool executes a tool call and returns the result
+// ExecuteTool executes a tool call through the tool registry and returns the result for the LLM
+// Tool failures are returned as structured error results; an error means the call could not be made
 func (h *ToolHandler) ExecuteTool(ctx context.Context, toolCall llm.ToolCall) (json.RawMessage, error) {
-	toolName := toolCall.Function.Name
-
-	// Check if execute_sql is called in free mode (no connection)
-	if toolName == "execute_sql" && h.conn == nil {
-		errorJSON := map[string]interface{}{
-			"status": "error",
-			"error":  "SQL execution is not available in free mode. Please select a database source to enable SQL queries.",
-		}
-		jsonData, err := json.Marshal(errorJSON)
-		if err != nil {
-			errorMsg := `{"status":"error","error":"SQL execution is not available in free mode. Please select a database source to enable SQL queries."}`
-			return json.RawMessage(errorMsg), nil
-		}
-		return json.RawMessage(jsonData), nil
+	t, err := tool.Default().Get(toolCall.Function.Name, tool.ModeFor(h.conn))
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse arguments from JSON string
@@ -192,293 +172,11 @@ func (h *ToolHandler) ExecuteTool(ctx context.Context, toolCall llm.ToolCall) (j
 	if err != nil {
 		return nil, err
 	}
-
-	switch toolName {
-	case "execute_sql":
-		sql, ok := args["sql"].(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid sql parameter")
-		}
-
-		// Execute SQL - this does NOT print anything, only returns data
-		result, err := tool.ExecuteSQL(ctx, h.conn, sql)
-		if err != nil {
-			// Extract structured error information
-			errorInfo := tool.ExtractErrorInfo(err)
-			errorMessage := err.Error()
-
-			// Build structured error JSON with backward compatibility
-			errorJSON := map[string]interface{}{
-				"status": "error",
-				"error":  errorMessage, // Keep original error message for backward compatibility
-			}
-
-			// Add structured error fields if available
-			if errorInfo.ErrorCode != "" {
-				errorJSON["error_code"] = errorInfo.ErrorCode
-			}
-			if errorInfo.ErrorType != "" && errorInfo.ErrorType != "unknown" {
-				errorJSON["error_type"] = errorInfo.ErrorType
-			}
-			if len(errorInfo.AffectedResources) > 0 {
-				errorJSON["affected_resources"] = errorInfo.AffectedResources
-			}
-			if len(errorInfo.Dependencies) > 0 {
-				errorJSON["dependencies"] = errorInfo.Dependencies
-			}
-			if len(errorInfo.SuggestedActions) > 0 {
-				errorJSON["suggested_actions"] = errorInfo.SuggestedActions
-			}
-
-			jsonData, jsonErr := json.Marshal(errorJSON)
-			if jsonErr != nil {
-				// Fallback if JSON encoding fails
-				errorMsg := fmt.Sprintf(`{"status":"error","error":"%s"}`, strings.ReplaceAll(errorMessage, `"`, `\"`))
-				return json.RawMessage(errorMsg), nil
-			}
-			return json.RawMessage(jsonData), nil
-		}
-
-		// Convert result to JSON and return to LLM
-		// LLM will decide how to display this (via render_table or text description)
-		resultJSON := map[string]interface{}{
-			"status":    "success",
-			"columns":   result.Columns,
-			"rows":      result.Rows,
-			"row_count": len(result.Rows),
-		}
-
-		// For operations with no data returned, add completion message
-		// Let LLM decide whether task is complete based on task type (definitive vs exploratory)
-		if len(result.Rows) == 0 {
-			resultJSON["status"] = "success"
-			// Don't add specific instruction - let LLM decide based on task context
-			// LLM will determine if this is definitive (complete) or exploratory (needs continuation)
-		}
-
-		jsonData, err := json.Marshal(resultJSON)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		return json.RawMessage(jsonData), nil
-
-	case "render_table":
-		columnsInterface, ok := args["columns"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid columns parameter")
-		}
-		rowsInterface, ok := args["rows"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid rows parameter")
-		}
-
-		// Convert to string slices
-		columns := make([]string, len(columnsInterface))
-		for i, col := range columnsInterface {
-			columns[i] = fmt.Sprintf("%v", col)
-		}
-
-		rows := make([][]string, len(rowsInterface))
-		for i, rowInterface := range rowsInterface {
-			rowArray, ok := rowInterface.([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("invalid row format")
-			}
-			rows[i] = make([]string, len(rowArray))
-			for j, val := range rowArray {
-				rows[i][j] = fmt.Sprintf("%v", val)
-			}
-		}
-
-		// Format the table as a string (do not print)
-		tableOutput, err := tool.RenderTableString(columns, rows)
-		if err != nil {
-			errorMsg := fmt.Sprintf(`{"error": "%s"}`, err.Error())
-			return json.RawMessage(errorMsg), nil
-		}
-
-		// Return formatted table to LLM
-		resultJSON := map[string]interface{}{
-			"status":    "success",
-			"format":    "table",
-			"output":    tableOutput,
-			"row_count": len(rows),
-		}
-		jsonData, err := json.Marshal(resultJSON)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		return json.RawMessage(jsonData), nil
-
-	case "render_chart":
-		columnsInterface, ok := args["columns"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid columns parameter")
-		}
-		rowsInterface, ok := args["rows"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid rows parameter")
-		}
-		chartTypeStr, ok := args["chart_type"].(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid chart_type parameter")
-		}
-
-		// Convert to string slices
-		columns := make([]string, len(columnsInterface))
-		for i, col := range columnsInterface {
-			columns[i] = fmt.Sprintf("%v", col)
-		}
-
-		rows := make([][]string, len(rowsInterface))
-		for i, rowInterface := range rowsInterface {
-			rowArray, ok := rowInterface.([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("invalid row format")
-			}
-			rows[i] = make([]string, len(rowArray))
-			for j, val := range rowArray {
-				rows[i][j] = fmt.Sprintf("%v", val)
-			}
-		}
-
-		// Create QueryResult
-		result := &db.QueryResult{
-			Columns: columns,
-			Rows:    rows,
-		}
-
-		chartOutput, err := tool.RenderChartString(result, chartTypeStr)
-		if err != nil {
-			errorMsg := fmt.Sprintf(`{"error": "%s"}`, err.Error())
-			return json.RawMessage(errorMsg), nil
-		}
-
-		// Return formatted chart to LLM
-		resultJSON := map[string]interface{}{
-			"status":     "success",
-			"format":     "chart",
-			"output":     chartOutput,
-			"chart_type": chartTypeStr,
-			"row_count":  len(result.Rows),
-		}
-		jsonData, err := json.Marshal(resultJSON)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		return json.RawMessage(jsonData), nil
-
-	default:
-		// Try built-in tools - use ExecuteBuiltinTool (no callback support yet)
-		result, err := builtin.ExecuteBuiltinTool(ctx, toolName, args, h.conn)
-		if err != nil {
-			// Check if it's truly an unknown tool or an execution error
-			if strings.Contains(err.Error(), "unknown built-in tool") {
-				return nil, fmt.Errorf("unknown tool: %s", toolName)
-			}
-			// For execution errors, extract structured error information
-			errorInfo := tool.ExtractErrorInfo(err)
-			errorMessage := err.Error()
-
-			// Build structured error JSON with backward compatibility
-			errorJSON := map[string]interface{}{
-				"status": "error",
-				"error":  errorMessage, // Keep original error message for backward compatibility
-			}
-
-			// Add structured error fields if available
-			if errorInfo.ErrorCode != "" {
-				errorJSON["error_code"] = errorInfo.ErrorCode
-			}
-			if errorInfo.ErrorType != "" && errorInfo.ErrorType != "unknown" {
-				errorJSON["error_type"] = errorInfo.ErrorType
-			}
-			if len(errorInfo.AffectedResources) > 0 {
-				errorJSON["affected_resources"] = errorInfo.AffectedResources
-			}
-			if len(errorInfo.Dependencies) > 0 {
-				errorJSON["dependencies"] = errorInfo.Dependencies
-			}
-			if len(errorInfo.SuggestedActions) > 0 {
-				errorJSON["suggested_actions"] = errorInfo.SuggestedActions
-			}
-
-			jsonData, jsonErr := json.Marshal(errorJSON)
-			if jsonErr != nil {
-				// Fallback if JSON encoding fails
-				errorMsg := fmt.Sprintf(`{"status":"error","error":"%s"}`, strings.ReplaceAll(errorMessage, `"`, `\"`))
-				return json.RawMessage(errorMsg), nil
-			}
-			return json.RawMessage(jsonData), nil
-		}
-		// Convert result to JSON
-		// For execute_command, use truncated output for LLM and add status field
-		if toolName == "execute_command" {
-			// First marshal to JSON, then unmarshal to map to add status and use truncated output
-			jsonBytes, err := json.Marshal(result)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal result: %w", err)
-			}
-
-			var resultMap map[string]interface{}
-			if err := json.Unmarshal(jsonBytes, &resultMap); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal result: %w", err)
-			}
-
-			// Check exit_code to determine status (simple rule: 0 = success, non-zero = error)
-			exitCode := 0
-			if ec, ok := resultMap["exit_code"].(float64); ok {
-				exitCode = int(ec)
-			} else if ec, ok := resultMap["exit_code"].(int); ok {
-				exitCode = ec
-			}
-
-			// Save full output for display (before truncation)
-			fullStdout, _ := resultMap["stdout"].(string)
-			fullStderr, _ := resultMap["stderr"].(string)
-
-			// Use truncated output for LLM (if available)
-			if truncatedStdout, ok := resultMap["truncated_stdout"].(string); ok && truncatedStdout != "" {
-				// Keep full output in a separate field for display
-				resultMap["_full_stdout"] = fullStdout
-				resultMap["stdout"] = truncatedStdout
-			}
-			if truncatedStderr, ok := resultMap["truncated_stderr"].(string); ok && truncatedStderr != "" {
-				// Keep full output in a separate field for display
-				resultMap["_full_stderr"] = fullStderr
-				resultMap["stderr"] = truncatedStderr
-			}
-
-			// Remove truncated fields from JSON (they're only for internal use)
-			delete(resultMap, "truncated_stdout")
-			delete(resultMap, "truncated_stderr")
-
-			// Add explicit status field based on exit_code
-			if exitCode == 0 {
-				resultMap["status"] = "success"
-			} else {
-				resultMap["status"] = "error"
-				resultMap["error"] = fmt.Sprintf("Command exited with code %d", exitCode)
-			}
-
-			// Note: Keep _full_stdout and _full_stderr for display in tool_handler
-			// They will be removed before sending to LLM in the tool call loop
-
-			// Marshal back to JSON
-			jsonData, err := json.Marshal(resultMap)
-			if err != nil {
-				return nil, fmt.Errorf("failed to marshal result: %w", err)
-			}
-			return json.RawMessage(jsonData), nil
-		}
-
-		// For other tools, convert result to JSON as-is
-		resultJSON, err := json.Marshal(result)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal result: %w", err)
-		}
-		return json.RawMessage(resultJSON), nil
+	ch, err := h.forCall(t, args)
+	if err != nil {
+		return nil, err
 	}
+	return t.Run(ctx, ch.env(args), args)
 }
 
 // HandleToolCallLoop handles the complete tool calling loop
@@ -542,17 +240,24 @@ You are a helpful AI assistant for database queries and related tasks.
 
 <POLICY>
 - Use execute_sql for database queries. Do not use execute_command to run mysql/psql.
+- The schema context lists table names only. Use describe_table (and sample_rows or column_values when values matter) before writing SQL against tables whose columns you do not know.
 - Respect engine-specific syntax. If unsure, ask a clarifying question or rely on schema context.
 - If a request is not a database query, use the appropriate non-SQL tools.
-- **CRITICAL**: Before generating new SQL queries, check conversation history for recent query results. If the user requests visualization (chart/table) and recent query results are available, use render_chart or render_table with the existing data instead of generating new SQL.
+- **CRITICAL**: Before generating new SQL queries, check conversation history for recent query results. If the user requests visualization (chart/table) and recent query results are available, call render_chart or render_table with the result_id of that execute_sql result instead of generating new SQL or copying rows. For follow-ups on fetched results (top N, filter, group, pivot, computed columns, joining two results), use transform_result instead of querying the database again.
 - Only generate new SQL queries if the user explicitly requests different data or if no recent query results are available.
 - **MANDATORY**: When user requests database operations, you MUST call execute_sql tool. Do NOT describe what you will do in text - actually call the tool. Do NOT say "I will execute" or "Stand by while I execute" - just call the tool directly.
 </POLICY>
 
 <TOOLS>
 - execute_sql: **MANDATORY TOOL CALL**: Execute SQL queries against the database. When user requests database operations (SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, SHOW, etc.), you MUST call this tool. Do NOT describe actions in text - call the tool directly.
-- render_table: Format query results as a table. **PRIORITY**: Check conversation history for recent query results first.
-- render_chart: **MANDATORY**: When user requests chart visualization, you MUST call this tool. Do NOT return text descriptions or JSON. Check conversation history for recent query results first.
+- list_tables: List tables and views (with a name filter on large databases).
+- describe_table: Columns, keys, indexes and comments of a table.
+- sample_rows: A few rows of a table, with sensitive values masked.
+- column_values: Distinct values of a column with their counts.
+- render_table: Format query results as a table. **PRIORITY**: Pass the result_id of a recent execute_sql result (with optional columns, sort and limit) instead of copying rows.
+- render_chart: **MANDATORY**: When user requests chart visualization, you MUST call this tool. Do NOT return text descriptions or JSON. Pass the result_id of a recent execute_sql result instead of copying rows.
+- transform_result: Filter, sort, limit, group, pivot/unpivot, compute columns or join stored results locally by result_id. The outcome is displayed and stored under a new result_id.
+- join_results: Run a SELECT with joins, filters and aggregates across stored results by result_id, e.g. fetched from different sources; each result is a table.
 - execute_command: System operations (install, setup, configuration). Not for database queries.
 - http_request: Make HTTP requests.
 - file_operations: Read/write files.
@@ -674,15 +379,15 @@ You are a helpful AI assistant for database queries and related tasks.
 		// Skip system message if it exists in rawMessages (we'll use the new one)
 		// Also normalize message format to ensure compatibility with LLM API
 		for _, msg := range rawMessages {
-			// Skip system messages (we'll use the new one)
+			// Skip system messages (we'll use the new one), except notes that the source or database changed
 			if msgMap, ok := msg.(map[string]interface{}); ok {
-				if role, ok := msgMap["role"].(string); ok && role == "system" {
+				if role, ok := msgMap["role"].(string); ok && role == "system" && !isContextNote(msgMap["content"]) {
 					continue // Skip old system message
 				}
 				// Content field is already normalized in mode.go when loading from Session
 				messages = append(messages, msgMap)
 			} else if chatMsg, ok := msg.(llm.ChatMessage); ok {
-				if chatMsg.Role == "system" {
+				if chatMsg.Role == "system" && !isContextNote(chatMsg.Content) {
 					continue // Skip old system message
 				}
 				// Convert ChatMessage to map to ensure proper serialization
@@ -712,9 +417,25 @@ You are a helpful AI assistant for database queries and related tasks.
 
 	var lastQueryResult *db.QueryResult
 	var hasSuccessfulToolExecution bool // Track if any tool executed successfully in this request
-	maxIterations := 10                 // Prevent infinite loops
+	usage := newTurnUsage(h.budget)     // Iterations, time and tokens used by this request
+
+	for {
+		// Ask the user before going past the budget of the request
+		if reason := usage.exhausted(); reason != "" {
+			proceed, guidance := askToContinue(reason)
+			if !proceed {
+				ui.ShowInfo("Request stopped.")
+				return "", lastQueryResult, messages, nil
+			}
+			usage.extend()
+			if guidance != "" {
+				messages = append(messages, map[string]interface{}{
+					"role":    "user",
+					"content": guidance,
+				})
+			}
+		}
 
-	for i := 0; i < maxIterations; i++ {
 		// Messages array already contains full conversation history including tool calls and results
 		// If messages are too long, compression logic will handle it
 
@@ -725,6 +446,7 @@ You are a helpful AI assistant for database queries and related tasks.
 		if err != nil {
 			return "", nil, nil, fmt.Errorf("LLM call failed: %w", err)
 		}
+		usage.addResponse(response.Usage)
 
 		if len(response.Choices) == 0 {
 			return "", nil, nil, fmt.Errorf("no choices in response")
@@ -826,7 +548,7 @@ You are a helpful AI assistant for database queries and related tasks.
 
 			// Case 3: No tools executed and LLM returned empty - this is an error
 			// But only if this is the first iteration (user's initial request)
-			if i == 0 {
+			if usage.iterations == 1 {
 				return "", nil, messages, fmt.Errorf("empty response from LLM")
 			}
 
@@ -835,454 +557,351 @@ You are a helpful AI assistant for database queries and related tasks.
 		}
 
 		// Execute tool calls
-		for _, toolCall := range message.ToolCalls {
-			// Parse arguments for risk assessment
-			args, parseErr := toolCall.ParseArguments()
-			if parseErr != nil {
-				ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, parseErr))
-				errorMsg := fmt.Sprintf(`{"error": "%s"}`, parseErr.Error())
-				toolResult := json.RawMessage(errorMsg)
-				toolMsg := map[string]interface{}{
-					"role":         "tool",
-					"content":      string(toolResult),
-					"tool_call_id": toolCall.ID,
+		parallelResults := make(map[int]*parallelResult)
+		for i, toolCall := range message.ToolCalls {
+			// Run the independent read-only calls starting here concurrently; their outcomes are displayed in order
+			if _, ok := parallelResults[i]; !ok {
+				for index, result := range h.runParallel(ctx, message.ToolCalls, i, usage) {
+					parallelResults[index] = result
 				}
-				messages = append(messages, toolMsg)
-				continue
 			}
 
-			// Assess risk for tool execution
-			riskAssessor := tool.GetRiskAssessor(toolCall.Function.Name)
-			riskLevel := riskAssessor.AssessRisk(toolCall.Function.Name, args)
-			// Log risk assessment result (written to ~/.aiq/logs/risk_assessment.log)
-			tool.LogRiskAssessment("Tool: %s, RiskLevel: %v", toolCall.Function.Name, riskLevel)
-
-			// For execute_sql, handle confirmation based on risk level
-			if toolCall.Function.Name == "execute_sql" {
-				sql, ok := args["sql"].(string)
-				if !ok {
-					err := fmt.Errorf("invalid sql parameter")
-					ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, err))
-					errorMsg := fmt.Sprintf(`{"error": "%s"}`, err.Error())
-					toolResult := json.RawMessage(errorMsg)
-					toolMsg := map[string]interface{}{
-						"role":         "tool",
-						"content":      string(toolResult),
-						"tool_call_id": toolCall.ID,
-					}
-					messages = append(messages, toolMsg)
-					continue
-				}
-
-				// Only show SQL and ask for confirmation if high-risk
-				if riskLevel == tool.RiskHigh {
-					fmt.Println()
-					ui.ShowInfo("Generated SQL:")
-					fmt.Println(ui.HighlightSQL(sql))
-					fmt.Println()
+			outcome := h.processToolCall(ctx, toolCall, parallelResults[i], usage)
+			if outcome.queryResult != nil {
+				lastQueryResult = outcome.queryResult
+			}
+			if outcome.succeeded {
+				hasSuccessfulToolExecution = true
+			}
 
-					confirm, err := ui.ShowConfirm("Execute this query?")
-					if err != nil {
-						fmt.Println()
-						// Treat as cancelled
-						ui.ShowWarning("Query execution cancelled.")
-						toolResult := json.RawMessage(`{"status":"cancelled","message":"query execution cancelled by user"}`)
-						toolMsg := map[string]interface{}{
-							"role":         "tool",
-							"content":      string(toolResult),
-							"tool_call_id": toolCall.ID,
-						}
-						messages = append(messages, toolMsg)
-						continue
-					}
-					if !confirm {
-						ui.ShowWarning("Query execution cancelled.")
-						toolResult := json.RawMessage(`{"status":"cancelled","message":"query execution cancelled by user"}`)
-						toolMsg := map[string]interface{}{
-							"role":         "tool",
-							"content":      string(toolResult),
-							"tool_call_id": toolCall.ID,
-						}
-						messages = append(messages, toolMsg)
-						continue
-					}
-				}
-				// For low-risk SQL, execute automatically without confirmation
+			// Always return tool result to LLM for decision-making
+			// LLM will decide based on task_type and execution status:
+			// - task_type="definitive" + all succeeded → return finish_reason="stop" with minimal output
+			// - task_type="definitive" + any failed → analyze errors and retry/alternative
+			// - task_type="exploratory" + any result → plan next steps
+			toolMsg := map[string]interface{}{
+				"role":         "tool",
+				"content":      outcome.content,
+				"tool_call_id": toolCall.ID,
 			}
+			messages = append(messages, toolMsg)
+		}
 
-			// For other tools (execute_command, file_operations, http_request), handle confirmation based on risk level
-			if toolCall.Function.Name == "execute_command" || toolCall.Function.Name == "file_operations" || toolCall.Function.Name == "http_request" {
-				if riskLevel == tool.RiskHigh {
-					// Show tool call details and ask for confirmation
-					toolCallDisplay := h.formatToolCall(toolCall)
-					fmt.Println()
-					ui.ShowInfo("Tool call:")
-					fmt.Println(toolCallDisplay)
-					fmt.Println()
-
-					confirm, err := ui.ShowConfirm("Execute this operation?")
-					if err != nil {
-						fmt.Println()
-						ui.ShowWarning("Operation cancelled.")
-						toolResult := json.RawMessage(`{"status":"cancelled","message":"operation cancelled by user"}`)
-						toolMsg := map[string]interface{}{
-							"role":         "tool",
-							"content":      string(toolResult),
-							"tool_call_id": toolCall.ID,
-						}
-						messages = append(messages, toolMsg)
-						continue
-					}
-					if !confirm {
-						ui.ShowWarning("Operation cancelled.")
-						toolResult := json.RawMessage(`{"status":"cancelled","message":"operation cancelled by user"}`)
-						toolMsg := map[string]interface{}{
-							"role":         "tool",
-							"content":      string(toolResult),
-							"tool_call_id": toolCall.ID,
-						}
-						messages = append(messages, toolMsg)
-						continue
-					}
+		// After processing all tool calls, continue loop to let LLM process results
+		// LLM will decide next action based on task_type and execution status:
+		// - task_type="definitive" + all succeeded → return finish_reason="stop" with minimal output
+		// - task_type="definitive" + any failed → analyze errors and retry/alternative
+		// - task_type="exploratory" + any result → plan next steps
+		// Note: We always continue the loop here - LLM will decide whether to continue or finish
+	}
+}
+
+// toolCallOutcome is the result of processing one tool call
+type toolCallOutcome struct {
+	content     string          // Content of the tool message sent to the LLM
+	succeeded   bool            // The call ran and reported success
+	queryResult *db.QueryResult // Rows displayed to the user, if any
+}
+
+// processToolCall checks a tool call against the risk policy, asks for confirmation when it is risky,
+// runs it (with preview and backup for SQL writes), displays its result and records it in the audit log
+// pre is the outcome of the call when it already ran ahead in parallel; usage counts repeated calls
+// of the request and is nil for calls made by the user
+func (h *ToolHandler) processToolCall(ctx context.Context, toolCall llm.ToolCall, pre *parallelResult, usage *turnUsage) toolCallOutcome {
+	// Parse arguments for risk assessment
+	args, parseErr := toolCall.ParseArguments()
+	if parseErr != nil {
+		ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, parseErr))
+		errorMsg := fmt.Sprintf(`{"error": "%s"}`, parseErr.Error())
+		toolResult := json.RawMessage(errorMsg)
+		return toolCallOutcome{content: string(toolResult)}
+	}
+
+	// Refuse calls denied by the risk policy before assessing risk or asking for confirmation
+	if decision, denied := tool.CheckPolicy(toolCall.Function.Name, args); denied {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by policy"
+		}
+		ui.ShowError(fmt.Sprintf("Tool [%s] blocked by policy rule '%s': %s", toolCall.Function.Name, decision.Rule, reason))
+		h.recordAudit(toolCall.Function.Name, args, tool.RiskHigh, audit.ApprovalDenied, 0, nil, fmt.Errorf("blocked by policy rule '%s': %s", decision.Rule, reason))
+		deniedJSON, _ := json.Marshal(map[string]interface{}{
+			"status": "denied",
+			"error":  fmt.Sprintf("blocked by policy rule '%s': %s. Do not retry this call; choose a different approach or ask the user", decision.Rule, reason),
+		})
+		return toolCallOutcome{content: string(deniedJSON)}
+	}
+
+	// Unknown, disabled and unavailable tools are reported to the LLM without running anything
+	t, lookupErr := tool.Default().Get(toolCall.Function.Name, tool.ModeFor(h.conn))
+	if lookupErr != nil {
+		ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, lookupErr))
+		h.recordAudit(toolCall.Function.Name, args, tool.RiskHigh, audit.ApprovalDenied, 0, nil, lookupErr)
+		errorMsg := fmt.Sprintf(`{"error": "%s"}`, strings.ReplaceAll(lookupErr.Error(), `"`, `\"`))
+		return toolCallOutcome{content: errorMsg}
+	}
+
+	// Calls naming an attached source run on its connection
+	ch, sourceErr := h.forCall(t, args)
+	if sourceErr != nil {
+		ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, sourceErr))
+		h.recordAudit(toolCall.Function.Name, args, tool.RiskHigh, audit.ApprovalDenied, 0, nil, sourceErr)
+		errorMsg := fmt.Sprintf(`{"error": "%s"}`, strings.ReplaceAll(sourceErr.Error(), `"`, `\"`))
+		return toolCallOutcome{content: errorMsg}
+	}
+
+	// Identical calls past the budget are not run again; the LLM is asked to change its approach
+	if count, repeated := usage.repeated(toolCall.Function.Name, args); repeated {
+		ui.ShowWarning(fmt.Sprintf("Tool [%s] skipped: the same call was already made %d times in this request", toolCall.Function.Name, count-1))
+		skippedJSON, _ := json.Marshal(map[string]interface{}{
+			"status": "skipped",
+			"error":  fmt.Sprintf("this exact call was already made %d times in this request and was not run again. Do not repeat it; change the approach or explain the problem to the user", count-1),
+		})
+		return toolCallOutcome{content: string(skippedJSON)}
+	}
+
+	// Assess risk for tool execution
+	riskAssessor := tool.GetRiskAssessor(toolCall.Function.Name)
+	riskLevel := riskAssessor.AssessRisk(toolCall.Function.Name, args)
+	// Log risk assessment result (written to ~/.aiq/logs/risk_assessment.log)
+	tool.LogRiskAssessment("Tool: %s, RiskLevel: %v", toolCall.Function.Name, riskLevel)
+	// High-risk calls only reach execution after the user confirms them
+	approval := auditApproval(riskLevel, true)
+
+	// Before-image backup of the rows a confirmed UPDATE/DELETE/TRUNCATE changes (~/.aiq/backups)
+	var backupSnap *backup.Snapshot
+
+	// Calls that run SQL on the connection are confirmed with the highlighted statement, previewed and backed up
+	if t.Query != nil {
+		sql, ok := t.Query(args)
+		if !ok {
+			err := fmt.Errorf("invalid sql parameter")
+			ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, err))
+			errorMsg := fmt.Sprintf(`{"error": "%s"}`, err.Error())
+			toolResult := json.RawMessage(errorMsg)
+			return toolCallOutcome{content: string(toolResult)}
+		}
+
+		// File sources cannot change data, so writes fail without asking for confirmation
+		if ch.conn != nil && ch.conn.DatabaseType() == "file" && (t.ReadOnly == nil || !t.ReadOnly(args)) {
+			err := fmt.Errorf("%s is a file source and read-only: only SELECT queries are supported", ch.sourceName)
+			ui.ShowError(fmt.Sprintf("Tool [%s] failed: %v", toolCall.Function.Name, err))
+			ch.recordAudit(toolCall.Function.Name, args, riskLevel, audit.ApprovalDenied, 0, nil, err)
+			return toolCallOutcome{content: string(tool.ErrorResult(err))}
+		}
+
+		// Only show SQL and ask for confirmation if high-risk
+		if riskLevel == tool.RiskHigh && t.Confirm {
+			fmt.Println()
+			if ch != h {
+				ui.ShowInfo(fmt.Sprintf("Generated SQL (on %s):", ch.sourceName))
+			} else {
+				ui.ShowInfo("Generated SQL:")
+			}
+			fmt.Println(ui.HighlightSQL(sql))
+			fmt.Println()
+
+			// UPDATE/DELETE on a single table are run in a transaction first so the user can review the effect
+			if outcome, previewed := ch.previewWrite(ctx, sql); previewed {
+				ch.recordAudit(toolCall.Function.Name, args, riskLevel, outcome.approval, outcome.duration, outcome.result, outcome.err)
+				succeeded := outcome.approval == audit.ApprovalConfirmed && outcome.err == nil
+				if succeeded {
+					call := toolCall
+					h.lastSQLCall = &call
 				}
-				// For low-risk operations, execute automatically without confirmation
+				return toolCallOutcome{content: string(outcome.result), succeeded: succeeded}
 			}
 
-			// Extract output_mode from tool call arguments (before execution)
-			outputMode := ""
-			if outputModeStr, ok := args["output_mode"].(string); ok {
-				outputMode = strings.ToLower(outputModeStr)
-			}
-			// Infer output_mode from task_type if not provided
-			if outputMode == "" {
-				if taskTypeStr, ok := args["task_type"].(string); ok {
-					taskType := strings.ToLower(taskTypeStr)
-					if taskType == "definitive" {
-						outputMode = "full"
-					} else if taskType == "exploratory" {
-						outputMode = "streaming"
-					}
-				}
+			confirm, err := ui.ShowConfirm("Execute this query?")
+			if err != nil {
+				fmt.Println()
+				// Treat as cancelled
+				ui.ShowWarning("Query execution cancelled.")
+				ch.recordAudit(toolCall.Function.Name, args, riskLevel, audit.ApprovalRejected, 0, nil, nil)
+				toolResult := json.RawMessage(`{"status":"cancelled","message":"query execution cancelled by user"}`)
+				return toolCallOutcome{content: string(toolResult)}
 			}
-			// Default: streaming (conservative for long-running processes)
-			if outputMode == "" {
-				outputMode = "streaming"
+			if !confirm {
+				ui.ShowWarning("Query execution cancelled.")
+				ch.recordAudit(toolCall.Function.Name, args, riskLevel, audit.ApprovalRejected, 0, nil, nil)
+				toolResult := json.RawMessage(`{"status":"cancelled","message":"query execution cancelled by user"}`)
+				return toolCallOutcome{content: string(toolResult)}
 			}
 
-			// Format and display tool call with arguments
-			toolCallDisplay := h.formatToolCall(toolCall)
-			var startTime time.Time
-			var toolResult json.RawMessage
-			var err error
-
-			// Special handling for execute_command: track time and output based on output_mode
-			if toolCall.Function.Name == "execute_command" {
-				// Display tool call with loading icon (normal color for main command)
-				fmt.Println("⏳ " + toolCallDisplay)
-				startTime = time.Now()
-
-				// Use already parsed args (from risk assessment above)
-				// args and parseErr are already available from above
-				if parseErr != nil {
-					err = parseErr
-					toolResult = nil
-				} else {
-					if outputMode == "full" {
-						// Full output mode: display all output without truncation
-						result, execErr := builtin.ExecuteBuiltinToolWithCallback(ctx, "execute_command", args, h.conn, func(line string) {
-							// Print each line immediately (full output)
-							fmt.Println(line)
-						})
-
-						if execErr != nil {
-							err = execErr
-							toolResult = nil
-						} else {
-							// Convert result to JSON (full output already displayed)
-							jsonBytes, marshalErr := json.Marshal(result)
-							if marshalErr != nil {
-								err = fmt.Errorf("failed to marshal result: %w", marshalErr)
-								toolResult = nil
-							} else {
-								// Process result for LLM (full output, no truncation)
-								var resultMap map[string]interface{}
-								if unmarshalErr := json.Unmarshal(jsonBytes, &resultMap); unmarshalErr == nil {
-									exitCode := 0
-									if ec, ok := resultMap["exit_code"].(float64); ok {
-										exitCode = int(ec)
-									} else if ec, ok := resultMap["exit_code"].(int); ok {
-										exitCode = ec
-									}
-
-									// Add status
-									if exitCode == 0 {
-										resultMap["status"] = "success"
-									} else {
-										resultMap["status"] = "error"
-										resultMap["error"] = fmt.Sprintf("Command exited with code %d", exitCode)
-									}
-
-									// Remove truncation fields (not used in full mode)
-									delete(resultMap, "truncated_stdout")
-									delete(resultMap, "truncated_stderr")
-
-									jsonData, _ := json.Marshal(resultMap)
-									toolResult = json.RawMessage(jsonData)
-								}
-							}
-						}
-					} else {
-						// Streaming output mode: rolling window display
-						rollingOutput := ui.NewRollingOutput(3)
-
-						// Execute with callback for streaming output - rolling window display
-						result, execErr := builtin.ExecuteBuiltinToolWithCallback(ctx, "execute_command", args, h.conn, func(line string) {
-							// AddLine handles the rolling display (clears old lines, prints new ones)
-							rollingOutput.AddLine(line)
-						})
-
-						// Show summary after command completes
-						rollingOutput.Finish()
-
-						if execErr != nil {
-							err = execErr
-							toolResult = nil
-						} else {
-							// Convert result to JSON
-							jsonBytes, marshalErr := json.Marshal(result)
-							if marshalErr != nil {
-								err = fmt.Errorf("failed to marshal result: %w", marshalErr)
-								toolResult = nil
-							} else {
-								// Process result for LLM (truncation, status, etc.)
-								var resultMap map[string]interface{}
-								if unmarshalErr := json.Unmarshal(jsonBytes, &resultMap); unmarshalErr == nil {
-									exitCode := 0
-									if ec, ok := resultMap["exit_code"].(float64); ok {
-										exitCode = int(ec)
-									} else if ec, ok := resultMap["exit_code"].(int); ok {
-										exitCode = ec
-									}
-
-									// Save full output for display
-									fullStdout, _ := resultMap["stdout"].(string)
-									fullStderr, _ := resultMap["stderr"].(string)
-
-									// Use truncated output for LLM
-									if truncatedStdout, ok := resultMap["truncated_stdout"].(string); ok && truncatedStdout != "" {
-										resultMap["_full_stdout"] = fullStdout
-										resultMap["stdout"] = truncatedStdout
-									}
-									if truncatedStderr, ok := resultMap["truncated_stderr"].(string); ok && truncatedStderr != "" {
-										resultMap["_full_stderr"] = fullStderr
-										resultMap["stderr"] = truncatedStderr
-									}
-
-									delete(resultMap, "truncated_stdout")
-									delete(resultMap, "truncated_stderr")
-
-									// Add status
-									if exitCode == 0 {
-										resultMap["status"] = "success"
-									} else {
-										resultMap["status"] = "error"
-										resultMap["error"] = fmt.Sprintf("Command exited with code %d", exitCode)
-									}
-
-									jsonData, _ := json.Marshal(resultMap)
-									toolResult = json.RawMessage(jsonData)
-								}
-							}
-						}
-					}
-				}
-			} else {
-				// For other tools, use normal display
-				ui.ShowInfo(toolCallDisplay)
-
-				waitingMsg := "Waiting..."
-				if toolCall.Function.Name == "execute_sql" {
-					waitingMsg = "Executing SQL..."
-				} else if toolCall.Function.Name == "http_request" {
-					waitingMsg = "Waiting for HTTP response..."
-				}
-				stopWaiting := ui.ShowLoading(waitingMsg)
-				toolResult, err = h.ExecuteTool(ctx, toolCall)
-				stopWaiting()
+			if ch.conn != nil {
+				backupSnap = ch.captureBackup(ctx, ch.conn, sql)
 			}
+		}
+		// For low-risk SQL, execute automatically without confirmation
+	}
+
+	// Other tools that require confirmation show the call and ask before running high-risk calls
+	if t.Query == nil && t.Confirm {
+		if riskLevel == tool.RiskHigh {
+			// Show tool call details and ask for confirmation
+			toolCallDisplay := ch.formatToolCall(toolCall)
+			fmt.Println()
+			ui.ShowInfo("Tool call:")
+			fmt.Println(toolCallDisplay)
+			fmt.Println()
+
+			confirm, err := ui.ShowConfirm("Execute this operation?")
 			if err != nil {
-				// Format error message for LLM
-				errorMsg := fmt.Sprintf(`{"error": "%s"}`, strings.ReplaceAll(err.Error(), `"`, `\"`))
-				toolResult = json.RawMessage(errorMsg)
-				// Show user-friendly error message with duration for execute_command
-				if toolCall.Function.Name == "execute_command" {
-					duration := time.Since(startTime)
-					ui.ShowError(fmt.Sprintf("Tool [execute_command] failed: %s (%.1fs)", err.Error(), duration.Seconds()))
-				} else {
-					ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s", toolCall.Function.Name, err.Error()))
-				}
-			} else {
-				// Check if tool result contains an error (even if ExecuteTool returned nil error)
-				var resultData map[string]interface{}
-				if jsonErr := json.Unmarshal(toolResult, &resultData); jsonErr == nil {
-					// Special handling for execute_command: display status (output already printed in real-time)
-					if toolCall.Function.Name == "execute_command" {
-						exitCode := 0
-						if ec, ok := resultData["exit_code"].(float64); ok {
-							exitCode = int(ec)
-						} else if ec, ok := resultData["exit_code"].(int); ok {
-							exitCode = ec
-						}
+				fmt.Println()
+				ui.ShowWarning("Operation cancelled.")
+				ch.recordAudit(toolCall.Function.Name, args, riskLevel, audit.ApprovalRejected, 0, nil, nil)
+				toolResult := json.RawMessage(`{"status":"cancelled","message":"operation cancelled by user"}`)
+				return toolCallOutcome{content: string(toolResult)}
+			}
+			if !confirm {
+				ui.ShowWarning("Operation cancelled.")
+				ch.recordAudit(toolCall.Function.Name, args, riskLevel, audit.ApprovalRejected, 0, nil, nil)
+				toolResult := json.RawMessage(`{"status":"cancelled","message":"operation cancelled by user"}`)
+				return toolCallOutcome{content: string(toolResult)}
+			}
+		}
+		// For low-risk operations, execute automatically without confirmation
+	}
 
-						// Calculate execution time
-						duration := time.Since(startTime)
+	// Format and display tool call with arguments
+	toolCallDisplay := ch.formatToolCall(toolCall)
+	var displayed *db.QueryResult
+	startTime := time.Now()
+	env := ch.env(args)
+	var toolResult json.RawMessage
+	var err error
+
+	if t.StreamsOutput {
+		// Display tool call with loading icon, then its output as it is produced
+		fmt.Println("⏳ " + toolCallDisplay)
+		if env.OutputMode == tool.OutputFull {
+			// Full output mode: display all output without truncation
+			env.Output = func(line string) {
+				fmt.Println(line)
+			}
+			toolResult, err = t.Run(ctx, env, args)
+		} else {
+			// Streaming output mode: rolling window display
+			rollingOutput := ui.NewRollingOutput(3)
+			env.Output = rollingOutput.AddLine
+			toolResult, err = t.Run(ctx, env, args)
+			// Show summary after the tool completes
+			rollingOutput.Finish()
+		}
+	} else if pre != nil {
+		ui.ShowInfo(toolCallDisplay)
+		toolResult, err = pre.result, pre.err
+	} else {
+		ui.ShowInfo(toolCallDisplay)
 
-						// Display status with icon and duration
-						if exitCode == 0 {
-							ui.ShowSuccess(fmt.Sprintf("Tool [execute_command] completed (%.1fs)", duration.Seconds()))
-						} else {
-							ui.ShowError(fmt.Sprintf("Tool [execute_command] failed with exit code %d (%.1fs)", exitCode, duration.Seconds()))
-						}
-						fmt.Println()
+		waitingMsg := t.Waiting
+		if waitingMsg == "" {
+			waitingMsg = "Waiting..."
+		}
+		stopWaiting := ui.ShowLoading(waitingMsg)
+		toolResult, err = t.Run(ctx, env, args)
+		stopWaiting()
+	}
+	duration := time.Since(startTime)
+	if pre != nil {
+		duration = pre.duration
+	}
 
-						// Remove internal fields before sending to LLM
-						delete(resultData, "_full_stdout")
-						delete(resultData, "_full_stderr")
-						// Re-marshal toolResult without internal fields
-						jsonData, _ := json.Marshal(resultData)
-						toolResult = json.RawMessage(jsonData)
-					} else {
-						// For other tools, use existing display logic
-						if errorMsg, hasError := resultData["error"].(string); hasError && errorMsg != "" {
-							ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s", toolCall.Function.Name, errorMsg))
-						} else {
-							ui.ShowSuccess(fmt.Sprintf("Tool [%s] executed successfully", toolCall.Function.Name))
-						}
-					}
-				} else {
-					ui.ShowSuccess(fmt.Sprintf("Tool [%s] executed successfully", toolCall.Function.Name))
+	if err != nil {
+		// Format error message for LLM
+		errorMsg := fmt.Sprintf(`{"error": "%s"}`, strings.ReplaceAll(err.Error(), `"`, `\"`))
+		toolResult = json.RawMessage(errorMsg)
+		if t.StreamsOutput {
+			ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s (%.1fs)", toolCall.Function.Name, err.Error(), duration.Seconds()))
+		} else {
+			ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s", toolCall.Function.Name, err.Error()))
+		}
+	} else {
+		// Check if tool result contains an error (tool failures are results with an error field)
+		var resultData map[string]interface{}
+		if jsonErr := json.Unmarshal(toolResult, &resultData); jsonErr == nil {
+			errorMsg, _ := resultData["error"].(string)
+			switch {
+			case errorMsg != "" && t.StreamsOutput:
+				ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s (%.1fs)", toolCall.Function.Name, errorMsg, duration.Seconds()))
+			case errorMsg != "":
+				ui.ShowError(fmt.Sprintf("Tool [%s] failed: %s", toolCall.Function.Name, errorMsg))
+			case t.StreamsOutput:
+				ui.ShowSuccess(fmt.Sprintf("Tool [%s] completed (%.1fs)", toolCall.Function.Name, duration.Seconds()))
+			default:
+				ui.ShowSuccess(fmt.Sprintf("Tool [%s] executed successfully", toolCall.Function.Name))
+			}
+			if t.StreamsOutput {
+				fmt.Println()
+			}
+
+			// Tools that display their result to the user send the LLM a summary instead of the data
+			if t.Present != nil && errorMsg == "" {
+				presented, queryResult := t.Present(resultData)
+				if queryResult != nil {
+					displayed = queryResult
 				}
-			}
-
-			// For render_chart: directly display chart output to user
-			if toolCall.Function.Name == "render_chart" && err == nil {
-				var resultData map[string]interface{}
-				if err := json.Unmarshal(toolResult, &resultData); err == nil {
-					if output, ok := resultData["output"].(string); ok && output != "" {
-						chartType, _ := resultData["chart_type"].(string)
-						rowCount := 0
-						if rc, ok := resultData["row_count"].(float64); ok {
-							rowCount = int(rc)
-						} else if rc, ok := resultData["row_count"].(int); ok {
-							rowCount = rc
-						}
-						title := fmt.Sprintf("Chart (%d rows)", rowCount)
-						ui.DisplayChart(output, chartType, title)
-
-						// Simplify result for LLM - chart already displayed
-						simplifiedResult := map[string]interface{}{
-							"status":      "success",
-							"displayed":   true,
-							"chart_type":  chartType,
-							"row_count":   rowCount,
-							"instruction": "Chart already displayed to user. Task completed. Return finish_reason='stop' with no content and no tool_calls to finish.",
-						}
-						simplifiedJSON, _ := json.Marshal(simplifiedResult)
-						toolResult = json.RawMessage(simplifiedJSON)
-					}
+				if presentedJSON, marshalErr := json.Marshal(presented); marshalErr == nil {
+					toolResult = json.RawMessage(presentedJSON)
 				}
 			}
+		} else {
+			ui.ShowSuccess(fmt.Sprintf("Tool [%s] executed successfully", toolCall.Function.Name))
+		}
+	}
 
-			// For execute_sql: directly render table output (mysql client style)
-			// and simplify the result sent to LLM
-			if toolCall.Function.Name == "execute_sql" && err == nil {
-				var resultData map[string]interface{}
-				if err := json.Unmarshal(toolResult, &resultData); err == nil {
-					if columns, ok := resultData["columns"].([]interface{}); ok {
-						if rows, ok := resultData["rows"].([]interface{}); ok {
-							// Convert to QueryResult
-							cols := make([]string, len(columns))
-							for i, col := range columns {
-								cols[i] = fmt.Sprintf("%v", col)
-							}
-							rowsData := make([][]string, len(rows))
-							for i, rowInterface := range rows {
-								rowArray, ok := rowInterface.([]interface{})
-								if ok {
-									rowsData[i] = make([]string, len(rowArray))
-									for j, val := range rowArray {
-										rowsData[i][j] = fmt.Sprintf("%v", val)
-									}
-								}
-							}
-							queryResult := &db.QueryResult{
-								Columns: cols,
-								Rows:    rowsData,
-							}
-							lastQueryResult = queryResult
-
-							// Directly render table output (mysql client style)
-							fmt.Println()
-							if len(rowsData) > 0 {
-								tableOutput, tableErr := tool.RenderTableString(cols, rowsData)
-								if tableErr == nil {
-									fmt.Println(tableOutput)
-								}
-							}
-							// Always show row count, even for empty results (MySQL-style)
-							fmt.Printf("%d row(s) in set\n", len(rowsData))
-
-							// Simplify result for LLM - results are already displayed to user
-							// Tell LLM to return minimal response (no content) since results are already shown
-							var instruction string
-							if len(rowsData) > 0 {
-								instruction = "CRITICAL: Results are already displayed to the user in table format. Do NOT repeat the results in your response. Return finish_reason='stop' with empty content (no text output). The user can see the results above."
-							} else {
-								instruction = "CRITICAL: Query executed successfully with 0 rows returned. The row count (0 row(s) in set) is already displayed to the user. Do NOT repeat this information. Return finish_reason='stop' with empty content (no text output)."
-							}
-							simplifiedResult := map[string]interface{}{
-								"status":      "success",
-								"row_count":   len(rowsData),
-								"displayed":   true,
-								"instruction": instruction,
-							}
-							simplifiedJSON, _ := json.Marshal(simplifiedResult)
-							toolResult = json.RawMessage(simplifiedJSON)
-						}
-					}
-				}
-			}
+	// Show how long the SQL took to run (\timing), after its result
+	if h.timing && t.Query != nil && err == nil {
+		fmt.Println(ui.HintText(fmt.Sprintf("Time: %.3f ms", float64(duration.Microseconds())/1000)))
+	}
 
-			// Track execution status
-			if err == nil {
-				var resultData map[string]interface{}
-				if json.Unmarshal(toolResult, &resultData) == nil {
-					if status, ok := resultData["status"].(string); ok && status == "success" {
-						hasSuccessfulToolExecution = true
-					}
-				}
-			}
+	// Drop the backup if the statement did not run
+	if backupSnap != nil && (err != nil || !isSuccessResult(toolResult)) {
+		discardBackup(backupSnap)
+	}
 
-			// Always return tool result to LLM for decision-making
-			// LLM will decide based on task_type and execution status:
-			// - task_type="definitive" + all succeeded → return finish_reason="stop" with minimal output
-			// - task_type="definitive" + any failed → analyze errors and retry/alternative
-			// - task_type="exploratory" + any result → plan next steps
-			toolMsg := map[string]interface{}{
-				"role":         "tool",
-				"content":      string(toolResult),
-				"tool_call_id": toolCall.ID,
+	// Record the executed call in the audit log (~/.aiq/logs/audit.log)
+	ch.recordAudit(toolCall.Function.Name, args, riskLevel, approval, duration, toolResult, err)
+
+	// Track execution status
+	succeeded := false
+	if err == nil {
+		var resultData map[string]interface{}
+		if json.Unmarshal(toolResult, &resultData) == nil {
+			if status, ok := resultData["status"].(string); ok && status == "success" {
+				succeeded = true
 			}
-			messages = append(messages, toolMsg)
 		}
+	}
 
-		// After processing all tool calls, continue loop to let LLM process results
-		// LLM will decide next action based on task_type and execution status:
-		// - task_type="definitive" + all succeeded → return finish_reason="stop" with minimal output
-		// - task_type="definitive" + any failed → analyze errors and retry/alternative
-		// - task_type="exploratory" + any result → plan next steps
-		// Note: We always continue the loop here - LLM will decide whether to continue or finish
+	// Record the last SQL call, so /execute can run it again
+	if succeeded && t.Query != nil {
+		call := toolCall
+		h.lastSQLCall = &call
 	}
+	return toolCallOutcome{content: string(toolResult), succeeded: succeeded, queryResult: displayed}
+}
+
+// RunSQL runs a statement the user typed as an execute_sql call, without the LLM
+// It is checked, confirmed, previewed, backed up and audited like calls the LLM makes; the result reports success
+func (h *ToolHandler) RunSQL(ctx context.Context, sql string) bool {
+	args, err := json.Marshal(map[string]interface{}{"sql": sql})
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("Failed to run SQL: %v", err))
+		return false
+	}
+	call := llm.ToolCall{ID: "user", Type: "function"}
+	call.Function.Name = "execute_sql"
+	call.Function.Arguments = string(args)
+	return h.RunToolCall(ctx, call)
+}
+
+// RunToolCall runs a tool call made by the user (e.g. one repeated with /execute) and reports whether it succeeded
+func (h *ToolHandler) RunToolCall(ctx context.Context, call llm.ToolCall) bool {
+	return h.processToolCall(ctx, call, nil, nil).succeeded
+}
 
-	return "", nil, nil, fmt.Errorf("max iterations reached")
+// LastSQLCall returns the last successful call that ran SQL, or nil when none did
+func (h *ToolHandler) LastSQLCall() *llm.ToolCall {
+	return h.lastSQLCall
 }
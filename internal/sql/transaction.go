@@ -0,0 +1,61 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// handleTransactionCommand runs the /begin, /commit and /rollback chat commands
+func handleTransactionCommand(conn *db.Connection, command string) {
+	if conn == nil {
+		ui.ShowWarning("Transactions are not available in free mode.")
+		return
+	}
+	switch command {
+	case "/begin":
+		if err := conn.Begin(); err != nil {
+			ui.ShowError(fmt.Sprintf("Failed to begin transaction: %v", err))
+			return
+		}
+		ui.ShowSuccess("Transaction started. Statements run inside it until /commit or /rollback.")
+	case "/commit":
+		if err := conn.Commit(); err != nil {
+			ui.ShowError(fmt.Sprintf("Failed to commit: %v", err))
+			return
+		}
+		ui.ShowSuccess("Transaction committed.")
+	case "/rollback":
+		if err := conn.Rollback(); err != nil {
+			ui.ShowError(fmt.Sprintf("Failed to roll back: %v", err))
+			return
+		}
+		ui.ShowSuccess("Transaction rolled back.")
+	}
+}
+
+// confirmExitWithTransaction warns that leaving chat mode rolls back the open transaction
+// It returns false when the user chooses to stay
+func confirmExitWithTransaction(conn *db.Connection) bool {
+	if conn == nil || !conn.InTransaction() {
+		return true
+	}
+	tx := conn.Transaction()
+	ui.ShowWarning(fmt.Sprintf("A transaction has been open for %s and will be rolled back on exit. Use /commit to keep its changes.", time.Since(tx.StartedAt()).Round(time.Second)))
+	confirm, err := ui.ShowConfirm("Roll back and exit?")
+	return err == nil && confirm
+}
+
+// rollbackOnExit rolls back an open transaction when leaving chat mode
+func rollbackOnExit(conn *db.Connection) {
+	if conn == nil || !conn.InTransaction() {
+		return
+	}
+	if err := conn.Rollback(); err != nil {
+		ui.ShowError(fmt.Sprintf("Failed to roll back open transaction: %v", err))
+		return
+	}
+	ui.ShowWarning("Open transaction rolled back.")
+}
@@ -0,0 +1,509 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Class is the broad category of a SQL statement
+type Class string
+
+const (
+	// ClassRead is a pure read (SELECT, SHOW, DESCRIBE, EXPLAIN without ANALYZE)
+	ClassRead Class = "read"
+	// ClassDML modifies data (INSERT, UPDATE, DELETE, REPLACE, MERGE, LOAD, COPY)
+	ClassDML Class = "dml"
+	// ClassDDL changes schema (CREATE, DROP, ALTER, TRUNCATE, RENAME, SELECT ... INTO new_table)
+	ClassDDL Class = "ddl"
+	// ClassDCL changes privileges (GRANT, REVOKE)
+	ClassDCL Class = "dcl"
+	// ClassOther covers everything else, including locking reads, file exports, SET, CALL and transaction control
+	ClassOther Class = "other"
+)
+
+// Statement is the analysis of a single SQL statement
+type Statement struct {
+	Tokens []Token
+
+	// Verb is the main statement keyword; for WITH queries it is the keyword after the CTE list
+	Verb string
+	// Keywords are the leading words of the main statement (up to 3, uppercased), e.g. [CREATE TABLE T]
+	Keywords []string
+	Class    Class
+
+	// Tables are referenced table names (qualified as written, unquoted identifiers lowercased)
+	// CTE names are excluded
+	Tables []string
+
+	HasWhere  bool // Top-level WHERE clause present (meaningful for UPDATE/DELETE)
+	Returning bool // PostgreSQL RETURNING clause present
+	CTEWrite  bool // A CTE contains a data-modifying statement
+	IntoFile  bool // SELECT ... INTO OUTFILE/DUMPFILE
+	Locking   bool // SELECT ... FOR UPDATE/SHARE or LOCK IN SHARE MODE
+	Analyzed  bool // EXPLAIN ANALYZE, which executes the explained statement
+
+	unfilteredWrite bool
+	source          []rune // Input the token offsets refer to
+}
+
+// UnfilteredWrite reports whether the statement runs an UPDATE or DELETE without a WHERE clause,
+// directly, inside a CTE or under EXPLAIN ANALYZE
+func (s *Statement) UnfilteredWrite() bool {
+	return s.unfilteredWrite
+}
+
+// IsReadOnly reports whether the statement only reads data
+func (s *Statement) IsReadOnly() bool {
+	return s.Class == ClassRead
+}
+
+// ReturnsRows reports whether executing the statement produces a result set
+func (s *Statement) ReturnsRows() bool {
+	if s.Returning {
+		return true
+	}
+	switch s.Verb {
+	case "SELECT", "VALUES", "TABLE":
+		// SELECT ... INTO OUTFILE and SELECT ... INTO new_table return no rows
+		return !s.IntoFile && s.Class != ClassDDL
+	case "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "HELP":
+		return true
+	default:
+		return false
+	}
+}
+
+// Text returns the original SQL text of the statement, without the trailing semicolon
+func (s *Statement) Text() string {
+	if len(s.Tokens) == 0 {
+		return ""
+	}
+	return s.textRange(0, len(s.Tokens))
+}
+
+// textRange returns the original text spanning tokens[from:to], including comments between them
+func (s *Statement) textRange(from, to int) string {
+	if from >= to || s.source == nil {
+		return ""
+	}
+	start, end := s.Tokens[from].Start, s.Tokens[to-1].End
+	if start < 0 || end > len(s.source) || start > end {
+		return ""
+	}
+	return string(s.source[start:end])
+}
+
+// Parse splits SQL into statements and analyzes each one using the given dialect
+func Parse(sql string, dialect Dialect) []Statement {
+	return parseTokens([]rune(sql), Tokenize(sql, dialect))
+}
+
+// ParseConservative analyzes SQL under every supported dialect and lexical mode and returns all results
+// Callers that do not know the dialect should treat the input as safe only if every returned statement is safe,
+// so input crafted to hide a statement under one set of quoting rules is still seen under another
+func ParseConservative(sql string) []Statement {
+	var statements []Statement
+	for _, v := range lexicalVariants {
+		statements = append(statements, parseTokens([]rune(sql), tokenize(sql, v.dialect, v.backslashEscapes))...)
+	}
+	return statements
+}
+
+// CountStatements returns the largest number of statements sql splits into under the dialects and lexical
+// modes of ParseConservative, so a statement hidden from one of them is still counted
+func CountStatements(sql string) int {
+	count := 0
+	for _, v := range lexicalVariants {
+		if n := len(parseTokens([]rune(sql), tokenize(sql, v.dialect, v.backslashEscapes))); n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+// lexicalVariants are the tokenizer configurations considered by ParseConservative
+// MySQL's NO_BACKSLASH_ESCAPES mode changes where strings end, so both modes are included
+var lexicalVariants = []struct {
+	dialect          Dialect
+	backslashEscapes bool
+}{
+	{DialectMySQL, true},
+	{DialectMySQL, false},
+	{DialectPostgres, false},
+}
+
+// parseTokens splits tokens on semicolons and analyzes each non-empty statement
+func parseTokens(source []rune, tokens []Token) []Statement {
+	var statements []Statement
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i == len(tokens) || tokens[i].Kind == TokenSemicolon {
+			if i > start {
+				st := analyze(tokens[start:i])
+				st.source = source
+				statements = append(statements, st)
+			}
+			start = i + 1
+		}
+	}
+	return statements
+}
+
+// analyze classifies one statement
+func analyze(tokens []Token) Statement {
+	st := Statement{Tokens: tokens}
+
+	i := 0
+	// Parenthesized queries: (SELECT ...) UNION (SELECT ...)
+	for i < len(tokens) && tokens[i].IsSymbol("(") {
+		i++
+	}
+
+	var cteNames map[string]bool
+	if i < len(tokens) && tokens[i].IsWord("WITH") {
+		i, cteNames = st.parseCTEs(tokens, i+1)
+	}
+
+	main := tokens[i:]
+	for _, tok := range main {
+		if tok.Kind != TokenWord || len(st.Keywords) == 3 {
+			break
+		}
+		st.Keywords = append(st.Keywords, tok.Upper)
+	}
+	if len(st.Keywords) > 0 {
+		st.Verb = st.Keywords[0]
+	}
+
+	st.classify(main)
+	for _, table := range extractTables(main) {
+		if !cteNames[strings.ToLower(table)] {
+			st.Tables = appendUnique(st.Tables, table)
+		}
+	}
+	return st
+}
+
+// parseCTEs walks a WITH list starting after WITH and returns the index of the main statement
+// Each CTE body is analyzed recursively so data-modifying CTEs and their tables are recorded
+func (st *Statement) parseCTEs(tokens []Token, i int) (int, map[string]bool) {
+	names := make(map[string]bool)
+	if i < len(tokens) && tokens[i].IsWord("RECURSIVE") {
+		i++
+	}
+	for i < len(tokens) {
+		if tokens[i].Kind == TokenWord || tokens[i].Kind == TokenQuotedIdent {
+			names[strings.ToLower(tokens[i].Text)] = true
+			i++
+		}
+		// Optional column list
+		if i < len(tokens) && tokens[i].IsSymbol("(") {
+			i = matchingParen(tokens, i) + 1
+		}
+		for i < len(tokens) && (tokens[i].IsWord("AS") || tokens[i].IsWord("NOT") || tokens[i].IsWord("MATERIALIZED")) {
+			i++
+		}
+		if i >= len(tokens) || !tokens[i].IsSymbol("(") {
+			return i, names
+		}
+		end := matchingParen(tokens, i)
+		body := analyze(tokens[i+1 : end])
+		if body.Class != ClassRead {
+			st.CTEWrite = true
+		}
+		if body.unfilteredWrite {
+			st.unfilteredWrite = true
+		}
+		st.Tables = append(st.Tables, body.Tables...)
+		i = end + 1
+		if i < len(tokens) && tokens[i].IsSymbol(",") {
+			i++
+			continue
+		}
+		return i, names
+	}
+	return i, names
+}
+
+// classify sets Class and the clause flags from the main statement tokens
+func (st *Statement) classify(main []Token) {
+	switch st.Verb {
+	case "SELECT", "VALUES", "TABLE":
+		st.Class = ClassRead
+		st.classifySelect(main)
+	case "SHOW", "HELP":
+		st.Class = ClassRead
+	case "EXPLAIN", "DESCRIBE", "DESC":
+		st.Class = ClassRead
+		st.classifyExplain(main)
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE", "UPSERT", "LOAD", "COPY":
+		st.Class = ClassDML
+	case "CREATE", "DROP", "ALTER", "TRUNCATE", "RENAME", "COMMENT":
+		st.Class = ClassDDL
+	case "GRANT", "REVOKE":
+		st.Class = ClassDCL
+	default:
+		st.Class = ClassOther
+	}
+
+	depth := 0
+	for _, tok := range main {
+		switch {
+		case tok.IsSymbol("("):
+			depth++
+		case tok.IsSymbol(")"):
+			depth--
+		case depth == 0 && tok.IsWord("WHERE"):
+			st.HasWhere = true
+		case depth == 0 && tok.IsWord("RETURNING"):
+			st.Returning = true
+		}
+	}
+
+	if (st.Verb == "UPDATE" || st.Verb == "DELETE") && !st.HasWhere {
+		st.unfilteredWrite = true
+	}
+	// A read that wraps a data-modifying CTE is a write
+	if st.CTEWrite && st.Class == ClassRead {
+		st.Class = ClassDML
+	}
+}
+
+// classifySelect detects SELECT variants that are not pure reads
+func (st *Statement) classifySelect(main []Token) {
+	depth := 0
+	for i, tok := range main {
+		switch {
+		case tok.IsSymbol("("):
+			depth++
+		case tok.IsSymbol(")"):
+			depth--
+		case depth == 0 && tok.IsWord("INTO") && i+1 < len(main):
+			next := main[i+1]
+			switch {
+			case next.IsWord("OUTFILE") || next.IsWord("DUMPFILE"):
+				// Writes a file on the database server
+				st.IntoFile = true
+				st.Class = ClassOther
+			case next.IsSymbol("@"):
+				// MySQL user variable assignment, still a read
+			default:
+				// PostgreSQL SELECT ... INTO new_table creates a table
+				st.Class = ClassDDL
+			}
+		case tok.IsWord("FOR") && i+1 < len(main):
+			next := main[i+1]
+			if next.IsWord("UPDATE") || next.IsWord("SHARE") || next.IsWord("NO") || next.IsWord("KEY") {
+				st.Locking = true
+			}
+		case tok.IsWord("LOCK") && i+2 < len(main) && main[i+1].IsWord("IN") && main[i+2].IsWord("SHARE"):
+			st.Locking = true
+		}
+	}
+	if st.Locking && st.Class == ClassRead {
+		st.Class = ClassOther
+	}
+}
+
+// classifyExplain detects EXPLAIN ANALYZE, which executes the explained statement,
+// and takes the classification of the inner statement in that case
+func (st *Statement) classifyExplain(main []Token) {
+	i := 1
+	analyzeFound := false
+	for i < len(main) {
+		tok := main[i]
+		switch {
+		case tok.IsWord("ANALYZE") || tok.IsWord("ANALYSE"):
+			analyzeFound = true
+			i++
+		case tok.IsSymbol("("):
+			// PostgreSQL option list: EXPLAIN (ANALYZE, BUFFERS) ...
+			end := matchingParen(main, i)
+			for _, opt := range main[i+1 : end] {
+				if opt.IsWord("ANALYZE") || opt.IsWord("ANALYSE") {
+					analyzeFound = true
+				}
+			}
+			i = end + 1
+		case tok.IsWord("VERBOSE") || tok.IsWord("EXTENDED") || tok.IsWord("PARTITIONS") || tok.IsWord("FORMAT") || tok.IsSymbol("=") ||
+			tok.IsWord("TRADITIONAL") || tok.IsWord("JSON") || tok.IsWord("TREE"):
+			i++
+		default:
+			if analyzeFound && i < len(main) {
+				inner := analyze(main[i:])
+				st.Analyzed = true
+				st.Class = inner.Class
+				st.CTEWrite = inner.CTEWrite
+				st.IntoFile = inner.IntoFile
+				st.Locking = inner.Locking
+				st.unfilteredWrite = inner.unfilteredWrite
+			}
+			return
+		}
+	}
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open (len(tokens) if unterminated)
+func matchingParen(tokens []Token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		if tokens[i].IsSymbol("(") {
+			depth++
+		} else if tokens[i].IsSymbol(")") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// tableIntroducers are keywords followed by table references
+// TRUNCATE, TABLES and ON only introduce tables in some statements (see introducesTable)
+var tableIntroducers = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true, "USING": true,
+	"DESCRIBE": true, "DESC": true, "STRAIGHT_JOIN": true, "TRUNCATE": true, "TABLES": true, "ON": true,
+}
+
+// tableListIntroducers allow comma-separated table lists
+var tableListIntroducers = map[string]bool{
+	"FROM": true, "UPDATE": true, "TABLE": true, "USING": true, "TRUNCATE": true, "TABLES": true,
+}
+
+// lockModes follow a table in LOCK TABLES
+var lockModes = map[string]bool{
+	"READ": true, "WRITE": true, "LOCAL": true, "LOW_PRIORITY": true,
+}
+
+// tableModifiers may appear between an introducer and the table name
+var tableModifiers = map[string]bool{
+	"IF": true, "NOT": true, "EXISTS": true, "ONLY": true, "LATERAL": true, "IGNORE": true,
+	"LOW_PRIORITY": true, "QUICK": true, "TEMPORARY": true,
+}
+
+// clauseWords end a table reference (an identifier not in this set after a table name is an alias)
+var clauseWords = map[string]bool{
+	"WHERE": true, "ON": true, "USING": true, "SET": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true, "GROUP": true, "ORDER": true, "LIMIT": true,
+	"HAVING": true, "UNION": true, "VALUES": true, "VALUE": true, "SELECT": true, "WINDOW": true, "RETURNING": true,
+	"FOR": true, "LOCK": true, "INTO": true, "OFFSET": true, "FETCH": true, "EXCEPT": true, "INTERSECT": true,
+	"PARTITION": true, "DEFAULT": true, "ADD": true, "DROP": true, "MODIFY": true, "CHANGE": true, "RENAME": true,
+	"ALTER": true, "TO": true, "AS": true, "LIKE": true, "CASCADE": true, "RESTRICT": true, "WITH": true,
+	"OUTER": true, "USE": true, "FORCE": true, "ENGINE": true, "OUTFILE": true, "DUMPFILE": true,
+}
+
+// extractTables returns table names referenced after FROM, JOIN, INTO, UPDATE, TABLE, USING and DESCRIBE
+func extractTables(tokens []Token) []string {
+	var tables []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind != TokenWord || !tableIntroducers[tok.Upper] {
+			continue
+		}
+		// FOR UPDATE and ON DUPLICATE KEY UPDATE are not table references
+		if tok.Upper == "UPDATE" && i > 0 && (tokens[i-1].IsWord("FOR") || tokens[i-1].IsWord("KEY") || tokens[i-1].IsWord("NO")) {
+			continue
+		}
+		if !introducesTable(tokens, i) {
+			continue
+		}
+		j := i + 1
+		for {
+			for j < len(tokens) && tokens[j].Kind == TokenWord && tableModifiers[tokens[j].Upper] {
+				j++
+			}
+			name, next := readQualifiedName(tokens, j)
+			if name == "" {
+				break
+			}
+			// name(...) is a function call (e.g. generate_series), not a table
+			if next < len(tokens) && tokens[next].IsSymbol("(") && tok.Upper != "INTO" && tok.Upper != "TABLE" && tok.Upper != "ON" {
+				break
+			}
+			tables = appendUnique(tables, name)
+			j = skipAlias(tokens, next)
+			for tok.Upper == "TABLES" && j < len(tokens) && tokens[j].Kind == TokenWord && lockModes[tokens[j].Upper] {
+				j++
+			}
+			if !tableListIntroducers[tok.Upper] || j >= len(tokens) || !tokens[j].IsSymbol(",") {
+				break
+			}
+			j++
+		}
+	}
+	return tables
+}
+
+// introducesTable reports whether the introducer at i is followed by a table reference in its statement
+// DESCRIBE, DESC and TRUNCATE (without TABLE) name a table when they start the statement, TABLES after LOCK
+// and ON in CREATE [UNIQUE] INDEX ... ON
+func introducesTable(tokens []Token, i int) bool {
+	switch tokens[i].Upper {
+	case "DESCRIBE", "DESC":
+		return i == 0
+	case "TRUNCATE":
+		return i == 0 && (i+1 >= len(tokens) || !tokens[i+1].IsWord("TABLE"))
+	case "TABLES":
+		return i == 1 && tokens[0].IsWord("LOCK")
+	case "ON":
+		if !tokens[0].IsWord("CREATE") {
+			return false
+		}
+		for k := 1; k < i; k++ {
+			if tokens[k].IsWord("ON") {
+				return false
+			}
+			if tokens[k].IsWord("INDEX") {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// readQualifiedName reads ident(.ident)* starting at i
+func readQualifiedName(tokens []Token, i int) (string, int) {
+	var parts []string
+	for i < len(tokens) {
+		tok := tokens[i]
+		switch tok.Kind {
+		case TokenWord:
+			if len(parts) == 0 && clauseWords[tok.Upper] {
+				return "", i
+			}
+			parts = append(parts, strings.ToLower(tok.Text))
+		case TokenQuotedIdent:
+			parts = append(parts, tok.Text)
+		default:
+			return strings.Join(parts, "."), i
+		}
+		i++
+		if i < len(tokens) && tokens[i].IsSymbol(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), i
+}
+
+// skipAlias skips an optional [AS] alias after a table reference
+func skipAlias(tokens []Token, i int) int {
+	if i < len(tokens) && tokens[i].IsWord("AS") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].Kind == TokenQuotedIdent || (tokens[i].Kind == TokenWord && !clauseWords[tokens[i].Upper])) {
+		i++
+	}
+	return i
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,205 @@
+package sqlparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParse_Classification tests statement classes across statement shapes
+func TestParse_Classification(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  Dialect
+		expected []Class
+	}{
+		{"simple select", "SELECT * FROM users", DialectMySQL, []Class{ClassRead}},
+		{"lowercase select", "select 1", DialectMySQL, []Class{ClassRead}},
+		{"show", "SHOW TABLES", DialectMySQL, []Class{ClassRead}},
+		{"describe", "DESCRIBE users", DialectMySQL, []Class{ClassRead}},
+		{"explain", "EXPLAIN SELECT * FROM users", DialectMySQL, []Class{ClassRead}},
+		{"explain analyze delete", "EXPLAIN ANALYZE DELETE FROM users WHERE id = 1", DialectPostgres, []Class{ClassDML}},
+		{"explain option list analyze", "EXPLAIN (ANALYZE, BUFFERS) UPDATE users SET a = 1 WHERE id = 1", DialectPostgres, []Class{ClassDML}},
+		{"leading line comment", "-- fetch users\nSELECT * FROM users", DialectMySQL, []Class{ClassRead}},
+		{"leading block comment", "/* report */ DELETE FROM users WHERE id = 1", DialectMySQL, []Class{ClassDML}},
+		{"hash comment", "# note\nDROP TABLE users", DialectMySQL, []Class{ClassDDL}},
+		{"multiple statements", "SELECT 1; DROP TABLE t", DialectMySQL, []Class{ClassRead, ClassDDL}},
+		{"semicolon inside string", "SELECT 'a; DROP TABLE t'", DialectMySQL, []Class{ClassRead}},
+		{"cte read", "WITH x AS (SELECT 1) SELECT * FROM x", DialectPostgres, []Class{ClassRead}},
+		{"cte followed by delete", "WITH x AS (SELECT id FROM a) DELETE FROM b WHERE id IN (SELECT id FROM x)", DialectPostgres, []Class{ClassDML}},
+		{"data-modifying cte", "WITH d AS (DELETE FROM logs WHERE ts < now() RETURNING *) SELECT count(*) FROM d", DialectPostgres, []Class{ClassDML}},
+		{"into outfile", "SELECT * FROM users INTO OUTFILE '/tmp/u.csv'", DialectMySQL, []Class{ClassOther}},
+		{"into user variable", "SELECT count(*) INTO @n FROM users", DialectMySQL, []Class{ClassRead}},
+		{"select into new table", "SELECT * INTO backup_users FROM users", DialectPostgres, []Class{ClassDDL}},
+		{"for update", "SELECT * FROM users WHERE id = 1 FOR UPDATE", DialectMySQL, []Class{ClassOther}},
+		{"lock in share mode", "SELECT * FROM users LOCK IN SHARE MODE", DialectMySQL, []Class{ClassOther}},
+		{"parenthesized union", "(SELECT 1) UNION (SELECT 2)", DialectMySQL, []Class{ClassRead}},
+		{"grant", "GRANT SELECT ON db.* TO 'u'@'%'", DialectMySQL, []Class{ClassDCL}},
+		{"set", "SET NAMES utf8mb4", DialectMySQL, []Class{ClassOther}},
+		{"executable comment", "SELECT 1 /*!50000 ; DROP TABLE t */", DialectMySQL, []Class{ClassRead, ClassDDL}},
+		{"dollar quoted body", "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", DialectPostgres, []Class{ClassDDL}},
+		{"mysql double dash without space is arithmetic", "SELECT 1--1; DROP TABLE t", DialectMySQL, []Class{ClassRead, ClassDDL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := Parse(tt.sql, tt.dialect)
+			var got []Class
+			for _, st := range statements {
+				got = append(got, st.Class)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestParse_UnfilteredWrite tests detection of UPDATE/DELETE without WHERE
+func TestParse_UnfilteredWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected bool
+	}{
+		{"delete without where", "DELETE FROM users", true},
+		{"update without where", "UPDATE users SET active = 0", true},
+		{"delete with where", "DELETE FROM users WHERE id = 1", false},
+		{"update with where", "UPDATE users SET active = 0 WHERE id = 1", false},
+		{"where only in subquery", "DELETE FROM users WHERE id IN (SELECT id FROM banned)", false},
+		{"subquery where does not count", "UPDATE users SET a = (SELECT max(x) FROM t WHERE y = 1)", true},
+		{"cte delete without where", "WITH d AS (DELETE FROM logs RETURNING *) SELECT count(*) FROM d", true},
+		{"explain analyze delete without where", "EXPLAIN ANALYZE DELETE FROM logs", true},
+		{"plain explain is not executed", "EXPLAIN DELETE FROM logs", false},
+		{"select", "SELECT * FROM users", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := Parse(tt.sql, DialectPostgres)
+			if len(statements) != 1 {
+				t.Fatalf("Expected 1 statement, got %d", len(statements))
+			}
+			if got := statements[0].UnfilteredWrite(); got != tt.expected {
+				t.Errorf("Expected UnfilteredWrite=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestParse_Tables tests table extraction
+func TestParse_Tables(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  Dialect
+		expected []string
+	}{
+		{"select with join and aliases", "SELECT * FROM users u JOIN orders AS o ON u.id = o.user_id", DialectMySQL, []string{"users", "orders"}},
+		{"comma list", "SELECT * FROM a, b x WHERE a.id = x.id", DialectMySQL, []string{"a", "b"}},
+		{"qualified and quoted", "SELECT * FROM shop.`Order Items`", DialectMySQL, []string{"shop.Order Items"}},
+		{"insert select", "INSERT INTO archive SELECT * FROM events", DialectMySQL, []string{"archive", "events"}},
+		{"update", "UPDATE users SET a = 1 WHERE id = 2", DialectMySQL, []string{"users"}},
+		{"create if not exists", "CREATE TABLE IF NOT EXISTS t1 (id INT)", DialectMySQL, []string{"t1"}},
+		{"cte names excluded", "WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", DialectPostgres, []string{"orders"}},
+		{"function in from ignored", "SELECT * FROM generate_series(1, 3)", DialectPostgres, nil},
+		{"for update is not a table", "SELECT * FROM users FOR UPDATE", DialectMySQL, []string{"users"}},
+		{"describe", "DESC users", DialectMySQL, []string{"users"}},
+		{"truncate without TABLE", "TRUNCATE orders", DialectMySQL, []string{"orders"}},
+		{"truncate with TABLE", "TRUNCATE TABLE orders", DialectMySQL, []string{"orders"}},
+		{"truncate list", "TRUNCATE ONLY orders, order_items CASCADE", DialectPostgres, []string{"orders", "order_items"}},
+		{"create index", "CREATE INDEX i ON orders(a)", DialectMySQL, []string{"orders"}},
+		{"create unique index", "CREATE UNIQUE INDEX CONCURRENTLY i ON shop.orders USING btree (a, b)", DialectPostgres, []string{"shop.orders"}},
+		{"lock tables", "LOCK TABLES orders WRITE, users AS u READ LOCAL", DialectMySQL, []string{"orders", "users"}},
+		{"join condition is not a table", "SELECT * FROM a JOIN b ON a.id = b.id", DialectMySQL, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := Parse(tt.sql, tt.dialect)
+			if len(statements) != 1 {
+				t.Fatalf("Expected 1 statement, got %d", len(statements))
+			}
+			if !reflect.DeepEqual(statements[0].Tables, tt.expected) {
+				t.Errorf("Expected tables %v, got %v", tt.expected, statements[0].Tables)
+			}
+		})
+	}
+}
+
+// TestParseConservative tests that statements hidden by dialect-specific quoting are still found
+func TestParseConservative(t *testing.T) {
+	t.Run("backslash escape mode", func(t *testing.T) {
+		// With NO_BACKSLASH_ESCAPES the string ends after the backslash and DROP runs
+		statements := ParseConservative(`SELECT 'a\'; DROP TABLE t; -- '`)
+		found := false
+		for _, st := range statements {
+			if st.Verb == "DROP" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("Expected DROP to be found under some lexical variant")
+		}
+	})
+
+	t.Run("hash is not a comment in PostgreSQL", func(t *testing.T) {
+		statements := ParseConservative("SELECT 1 # 2; DROP TABLE t")
+		found := false
+		for _, st := range statements {
+			if st.Verb == "DROP" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("Expected DROP to be found under the PostgreSQL variant")
+		}
+	})
+}
+
+// TestCountStatements tests that the count is per input, not per lexical variant
+func TestCountStatements(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected int
+	}{
+		{"SELECT 1", 1},
+		{"SELECT 1;", 1},
+		{"SELECT 1; SELECT 2", 2},
+		{"SELECT 'a;b'", 1},
+		{`SELECT 'a\'; DROP TABLE t; -- '`, 2},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if n := CountStatements(tt.sql); n != tt.expected {
+			t.Errorf("CountStatements(%q) = %d, expected %d", tt.sql, n, tt.expected)
+		}
+	}
+}
+
+// TestStatement_ReturnsRows tests result set detection used to choose Query vs Exec
+func TestStatement_ReturnsRows(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected bool
+	}{
+		{"SELECT 1", true},
+		{"SHOW TABLES", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"INSERT INTO t VALUES (1)", false},
+		{"DELETE FROM t WHERE id = 1 RETURNING id", true},
+		{"SELECT * INTO OUTFILE '/tmp/x' FROM t", false},
+		{"CREATE TABLE t (id INT)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			statements := Parse(tt.sql, DialectPostgres)
+			if len(statements) == 0 {
+				t.Fatal("Expected a statement")
+			}
+			if got := statements[0].ReturnsRows(); got != tt.expected {
+				t.Errorf("Expected ReturnsRows=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// WriteTarget describes the table and row filter of a single-table UPDATE, DELETE or TRUNCATE
+// The clause fields hold the original SQL text so they can be reused to build a SELECT over the same rows
+type WriteTarget struct {
+	Verb string // UPDATE, DELETE or TRUNCATE
+
+	// Table is the table reference as written (quoting kept); TableParts are its unquoted name parts
+	Table      string
+	TableParts []string
+	Alias      string
+
+	Set     string // UPDATE assignments (text after SET), empty for DELETE
+	Where   string // Condition after WHERE, empty when the statement has no WHERE clause
+	OrderBy string // Expression list after ORDER BY (MySQL)
+	Limit   string // Row count after LIMIT (MySQL)
+}
+
+// Name returns the unquoted, dot-joined table name
+func (t *WriteTarget) Name() string {
+	return strings.Join(t.TableParts, ".")
+}
+
+// WriteTarget extracts the target of a plain single-table UPDATE, DELETE or TRUNCATE
+// Multi-table forms (joins, DELETE ... USING, comma lists), CTEs and EXPLAIN return false,
+// since the affected rows cannot be selected from one table with the statement's WHERE clause
+func (s *Statement) WriteTarget() (*WriteTarget, bool) {
+	tokens := s.Tokens
+	if len(tokens) == 0 || s.source == nil || s.CTEWrite {
+		return nil, false
+	}
+	verb := tokens[0]
+	if !verb.IsWord("UPDATE") && !verb.IsWord("DELETE") && !verb.IsWord("TRUNCATE") {
+		return nil, false
+	}
+	target := &WriteTarget{Verb: verb.Upper}
+
+	i := 1
+	if target.Verb == "TRUNCATE" && i < len(tokens) && tokens[i].IsWord("TABLE") {
+		i++
+	}
+	for i < len(tokens) && tokens[i].Kind == TokenWord && writeModifiers[tokens[i].Upper] {
+		i++
+	}
+	if target.Verb == "DELETE" {
+		if i >= len(tokens) || !tokens[i].IsWord("FROM") {
+			// MySQL multi-table DELETE t1, t2 FROM ...
+			return nil, false
+		}
+		i++
+	}
+	if i < len(tokens) && tokens[i].IsWord("ONLY") {
+		i++
+	}
+
+	nameStart := i
+	parts, next := readNameParts(tokens, i)
+	if len(parts) == 0 {
+		return nil, false
+	}
+	target.Table = s.textRange(nameStart, next)
+	target.TableParts = parts
+	i = next
+	if target.Verb == "TRUNCATE" {
+		// Only options such as RESTART IDENTITY or CASCADE may follow the table
+		for _, tok := range tokens[i:] {
+			if tok.Kind != TokenWord {
+				return nil, false
+			}
+		}
+		return target, true
+	}
+	if i < len(tokens) && tokens[i].IsSymbol("*") {
+		// PostgreSQL inheritance marker: UPDATE parent * SET ...
+		return nil, false
+	}
+	if i < len(tokens) && tokens[i].IsWord("AS") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].Kind == TokenQuotedIdent || (tokens[i].Kind == TokenWord && !clauseWords[tokens[i].Upper])) {
+		target.Alias = s.textRange(i, i+1)
+		i++
+	}
+
+	// Anything but the first clause keyword here (a comma, JOIN, USING) means a multi-table statement
+	if target.Verb == "UPDATE" {
+		if i >= len(tokens) || !tokens[i].IsWord("SET") {
+			return nil, false
+		}
+	} else if i < len(tokens) {
+		switch tokens[i].Upper {
+		case "WHERE", "ORDER", "LIMIT", "RETURNING":
+		default:
+			return nil, false
+		}
+	}
+	clauses := topLevelClauses(tokens, i)
+	if _, ok := clauses["FROM"]; ok {
+		// PostgreSQL UPDATE ... FROM other_table
+		return nil, false
+	}
+
+	ends := []int{len(tokens)}
+	for _, idx := range clauses {
+		ends = append(ends, idx)
+	}
+	clauseEnd := func(start int) int {
+		end := len(tokens)
+		for _, idx := range ends {
+			if idx > start && idx < end {
+				end = idx
+			}
+		}
+		return end
+	}
+
+	if idx, ok := clauses["SET"]; ok {
+		target.Set = s.textRange(idx+1, clauseEnd(idx))
+	}
+	if idx, ok := clauses["WHERE"]; ok {
+		target.Where = s.textRange(idx+1, clauseEnd(idx))
+	}
+	if idx, ok := clauses["ORDER"]; ok && idx+1 < len(tokens) && tokens[idx+1].IsWord("BY") {
+		target.OrderBy = s.textRange(idx+2, clauseEnd(idx))
+	}
+	if idx, ok := clauses["LIMIT"]; ok {
+		target.Limit = s.textRange(idx+1, clauseEnd(idx))
+	}
+	return target, true
+}
+
+// writeModifiers are MySQL options between UPDATE/DELETE and the table
+var writeModifiers = map[string]bool{
+	"LOW_PRIORITY": true, "QUICK": true, "IGNORE": true,
+}
+
+// writeClauses are the top-level clauses of UPDATE and DELETE
+var writeClauses = map[string]bool{
+	"SET": true, "FROM": true, "WHERE": true, "ORDER": true, "LIMIT": true, "RETURNING": true,
+}
+
+// topLevelClauses returns the index of the first occurrence of each write clause keyword outside parentheses
+func topLevelClauses(tokens []Token, start int) map[string]int {
+	clauses := make(map[string]int)
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.IsSymbol("("):
+			depth++
+		case tok.IsSymbol(")"):
+			depth--
+		case depth == 0 && tok.Kind == TokenWord && writeClauses[tok.Upper]:
+			if _, seen := clauses[tok.Upper]; !seen {
+				clauses[tok.Upper] = i
+			}
+		}
+	}
+	return clauses
+}
+
+// readNameParts reads ident(.ident)* starting at i and returns the unquoted parts
+func readNameParts(tokens []Token, i int) ([]string, int) {
+	var parts []string
+	for i < len(tokens) {
+		tok := tokens[i]
+		switch {
+		case tok.Kind == TokenQuotedIdent:
+			parts = append(parts, tok.Text)
+		case tok.Kind == TokenWord && !(len(parts) == 0 && clauseWords[tok.Upper]):
+			parts = append(parts, tok.Text)
+		default:
+			return parts, i
+		}
+		i++
+		if i < len(tokens) && tokens[i].IsSymbol(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return parts, i
+}
+
+// QuoteIdent quotes an identifier for the dialect (backticks for MySQL, double quotes for PostgreSQL)
+func QuoteIdent(name string, dialect Dialect) string {
+	if dialect == DialectPostgres {
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteQualified quotes each part of a qualified name and joins them with dots
+func QuoteQualified(parts []string, dialect Dialect) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = QuoteIdent(part, dialect)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// QuoteLiteral quotes a string literal for the dialect
+// Backslashes are doubled for MySQL, which treats them as escapes unless NO_BACKSLASH_ESCAPES is set
+func QuoteLiteral(value string, dialect Dialect) string {
+	escaped := strings.ReplaceAll(value, "'", "''")
+	if dialect == DialectMySQL {
+		escaped = strings.ReplaceAll(escaped, `\`, `\\`)
+	}
+	return "'" + escaped + "'"
+}
@@ -0,0 +1,65 @@
+package sqlparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestStatement_WriteTarget tests extraction of the table and filter of UPDATE/DELETE
+func TestStatement_WriteTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  Dialect
+		ok       bool
+		expected WriteTarget
+	}{
+		{
+			name: "update with where", sql: "UPDATE users SET active = 0 WHERE last_login < '2020-01-01'", dialect: DialectMySQL, ok: true,
+			expected: WriteTarget{Verb: "UPDATE", Table: "users", TableParts: []string{"users"}, Set: "active = 0", Where: "last_login < '2020-01-01'"},
+		},
+		{
+			name: "delete with alias order and limit", sql: "DELETE FROM `shop`.`orders` AS o WHERE o.status = 'x' ORDER BY o.id LIMIT 10", dialect: DialectMySQL, ok: true,
+			expected: WriteTarget{Verb: "DELETE", Table: "`shop`.`orders`", TableParts: []string{"shop", "orders"}, Alias: "o", Where: "o.status = 'x'", OrderBy: "o.id", Limit: "10"},
+		},
+		{
+			name: "where with subquery keeps nested clauses", sql: "DELETE FROM logs WHERE id IN (SELECT id FROM old ORDER BY id LIMIT 5)", dialect: DialectMySQL, ok: true,
+			expected: WriteTarget{Verb: "DELETE", Table: "logs", TableParts: []string{"logs"}, Where: "id IN (SELECT id FROM old ORDER BY id LIMIT 5)"},
+		},
+		{
+			name: "delete without where", sql: "DELETE FROM logs", dialect: DialectMySQL, ok: true,
+			expected: WriteTarget{Verb: "DELETE", Table: "logs", TableParts: []string{"logs"}},
+		},
+		{
+			name: "postgres returning", sql: `UPDATE ONLY "Users" SET a = 1 WHERE id = 2 RETURNING *`, dialect: DialectPostgres, ok: true,
+			expected: WriteTarget{Verb: "UPDATE", Table: `"Users"`, TableParts: []string{"Users"}, Set: "a = 1", Where: "id = 2"},
+		},
+		{
+			name: "truncate", sql: "TRUNCATE TABLE audit.events RESTART IDENTITY", dialect: DialectPostgres, ok: true,
+			expected: WriteTarget{Verb: "TRUNCATE", Table: "audit.events", TableParts: []string{"audit", "events"}},
+		},
+		{name: "truncate several tables", sql: "TRUNCATE a, b", dialect: DialectPostgres},
+		{name: "multi-table update", sql: "UPDATE a JOIN b ON a.id = b.id SET a.x = b.x", dialect: DialectMySQL},
+		{name: "comma update", sql: "UPDATE a, b SET a.x = b.x WHERE a.id = b.id", dialect: DialectMySQL},
+		{name: "multi-table delete", sql: "DELETE a FROM a JOIN b ON a.id = b.id", dialect: DialectMySQL},
+		{name: "delete using", sql: "DELETE FROM a USING b WHERE a.id = b.id", dialect: DialectPostgres},
+		{name: "update from", sql: "UPDATE a SET x = b.x FROM b WHERE a.id = b.id", dialect: DialectPostgres},
+		{name: "insert", sql: "INSERT INTO a VALUES (1)", dialect: DialectMySQL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := Parse(tt.sql, tt.dialect)
+			if len(statements) != 1 {
+				t.Fatalf("Expected 1 statement, got %d", len(statements))
+			}
+			target, ok := statements[0].WriteTarget()
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && !reflect.DeepEqual(*target, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *target)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package sqlparse
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect selects dialect-specific lexical rules
+type Dialect int
+
+const (
+	// DialectMySQL covers MySQL and MySQL-compatible engines (seekdb, OceanBase MySQL mode)
+	// # starts a comment, backticks quote identifiers and /*! ... */ executable comments are code
+	DialectMySQL Dialect = iota
+	// DialectPostgres covers PostgreSQL
+	// Block comments nest, $tag$ ... $tag$ quotes strings and # is an operator
+	DialectPostgres
+)
+
+// Dialects lists every supported dialect
+var Dialects = []Dialect{DialectMySQL, DialectPostgres}
+
+// String returns the dialect name
+func (d Dialect) String() string {
+	switch d {
+	case DialectMySQL:
+		return "mysql"
+	case DialectPostgres:
+		return "postgres"
+	default:
+		return "unknown"
+	}
+}
+
+// DialectForDatabaseType maps a source database type (mysql, postgresql, seekdb, file) to a dialect
+// File sources quote identifiers with double quotes, like PostgreSQL
+func DialectForDatabaseType(dbType string) Dialect {
+	switch strings.ToLower(dbType) {
+	case "postgresql", "postgres", "file":
+		return DialectPostgres
+	default:
+		return DialectMySQL
+	}
+}
+
+// TokenKind classifies a token
+type TokenKind int
+
+const (
+	// TokenWord is an unquoted keyword or identifier
+	TokenWord TokenKind = iota
+	// TokenQuotedIdent is an identifier quoted with backticks (MySQL) or double quotes (PostgreSQL)
+	TokenQuotedIdent
+	// TokenString is a string literal
+	TokenString
+	// TokenNumber is a numeric literal
+	TokenNumber
+	// TokenSymbol is an operator or punctuation character
+	TokenSymbol
+	// TokenSemicolon separates statements
+	TokenSemicolon
+)
+
+// Token is a lexical token; comments and whitespace are dropped
+type Token struct {
+	Kind  TokenKind
+	Text  string // Original text (identifiers are unquoted)
+	Upper string // Uppercased text for word tokens
+	Start int    // Offset of the first rune in the input (in runes)
+	End   int    // Offset just past the last rune in the input (in runes)
+}
+
+// IsWord reports whether the token is the given keyword (case-insensitive, word tokens only)
+func (t Token) IsWord(keyword string) bool {
+	return t.Kind == TokenWord && t.Upper == keyword
+}
+
+// IsSymbol reports whether the token is the given symbol
+func (t Token) IsSymbol(symbol string) bool {
+	return t.Kind == TokenSymbol && t.Text == symbol
+}
+
+// Tokenize splits SQL into tokens, dropping comments
+// Unterminated quotes and comments extend to the end of input, which keeps classification conservative
+// Backslash escapes in strings follow the dialect default (on for MySQL, off for PostgreSQL)
+func Tokenize(sql string, dialect Dialect) []Token {
+	return tokenize(sql, dialect, dialect == DialectMySQL)
+}
+
+func tokenize(sql string, dialect Dialect, backslashEscapes bool) []Token {
+	lx := &lexer{src: []rune(sql), dialect: dialect, backslashEscapes: backslashEscapes}
+	lx.run()
+	return lx.tokens
+}
+
+type lexer struct {
+	src              []rune
+	pos              int
+	dialect          Dialect
+	backslashEscapes bool
+	tokens           []Token
+}
+
+func (lx *lexer) peek(offset int) rune {
+	if lx.pos+offset < len(lx.src) {
+		return lx.src[lx.pos+offset]
+	}
+	return 0
+}
+
+func (lx *lexer) emit(kind TokenKind, text string, start int) {
+	tok := Token{Kind: kind, Text: text, Start: start, End: lx.pos}
+	if kind == TokenWord {
+		tok.Upper = strings.ToUpper(text)
+	}
+	lx.tokens = append(lx.tokens, tok)
+}
+
+func (lx *lexer) run() {
+	for lx.pos < len(lx.src) {
+		ch := lx.src[lx.pos]
+		start := lx.pos
+		switch {
+		case unicode.IsSpace(ch):
+			lx.pos++
+		case ch == '-' && lx.peek(1) == '-' && lx.isLineComment():
+			lx.skipLine()
+		case ch == '#' && lx.dialect == DialectMySQL:
+			lx.skipLine()
+		case ch == '/' && lx.peek(1) == '*':
+			lx.blockComment()
+		case ch == '\'':
+			lx.emit(TokenString, lx.quoted('\''), start)
+		case ch == '"':
+			// Double quotes are strings in MySQL (default sql_mode) and identifiers in PostgreSQL
+			if lx.dialect == DialectPostgres {
+				lx.emit(TokenQuotedIdent, lx.quoted('"'), start)
+			} else {
+				lx.emit(TokenString, lx.quoted('"'), start)
+			}
+		case ch == '`' && lx.dialect == DialectMySQL:
+			lx.emit(TokenQuotedIdent, lx.quoted('`'), start)
+		case ch == '$' && lx.dialect == DialectPostgres && lx.dollarQuoted():
+		case ch == ';':
+			lx.pos++
+			lx.emit(TokenSemicolon, ";", start)
+		case isWordStart(ch):
+			for lx.pos < len(lx.src) && isWordPart(lx.src[lx.pos]) {
+				lx.pos++
+			}
+			lx.emit(TokenWord, string(lx.src[start:lx.pos]), start)
+		case unicode.IsDigit(ch):
+			for lx.pos < len(lx.src) && (unicode.IsDigit(lx.src[lx.pos]) || lx.src[lx.pos] == '.' || unicode.IsLetter(lx.src[lx.pos])) {
+				lx.pos++
+			}
+			lx.emit(TokenNumber, string(lx.src[start:lx.pos]), start)
+		default:
+			lx.pos++
+			lx.emit(TokenSymbol, string(ch), start)
+		}
+	}
+}
+
+func isWordStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
+func isWordPart(ch rune) bool {
+	return ch == '_' || ch == '$' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+// isLineComment reports whether -- at the current position starts a comment
+// MySQL requires whitespace (or end of input) after --, so 1--1 is arithmetic there
+func (lx *lexer) isLineComment() bool {
+	if lx.dialect != DialectMySQL {
+		return true
+	}
+	next := lx.peek(2)
+	return next == 0 || unicode.IsSpace(next) || unicode.IsControl(next)
+}
+
+func (lx *lexer) skipLine() {
+	for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+		lx.pos++
+	}
+}
+
+// blockComment skips /* ... */ comments
+// MySQL executable comments (/*! ... */ and /*!50001 ... */) are run by the server, so their content is tokenized
+// PostgreSQL block comments nest
+func (lx *lexer) blockComment() {
+	if lx.dialect == DialectMySQL && lx.peek(2) == '!' {
+		lx.pos += 3
+		for lx.pos < len(lx.src) && unicode.IsDigit(lx.src[lx.pos]) {
+			lx.pos++
+		}
+		end := lx.pos
+		for end < len(lx.src) && !(lx.src[end] == '*' && end+1 < len(lx.src) && lx.src[end+1] == '/') {
+			end++
+		}
+		inner := tokenize(string(lx.src[lx.pos:end]), lx.dialect, lx.backslashEscapes)
+		for _, tok := range inner {
+			tok.Start += lx.pos
+			tok.End += lx.pos
+			lx.tokens = append(lx.tokens, tok)
+		}
+		lx.pos = end + 2
+		return
+	}
+
+	depth := 0
+	for lx.pos < len(lx.src) {
+		if lx.src[lx.pos] == '/' && lx.peek(1) == '*' {
+			depth++
+			lx.pos += 2
+			if lx.dialect != DialectPostgres && depth > 1 {
+				depth = 1
+			}
+			continue
+		}
+		if lx.src[lx.pos] == '*' && lx.peek(1) == '/' {
+			depth--
+			lx.pos += 2
+			if depth == 0 {
+				return
+			}
+			continue
+		}
+		lx.pos++
+	}
+}
+
+// quoted reads a quoted string or identifier and returns its unquoted content
+// A doubled quote is an escaped quote; with backslashEscapes a backslash escapes the next character
+func (lx *lexer) quoted(quote rune) string {
+	lx.pos++
+	var b strings.Builder
+	for lx.pos < len(lx.src) {
+		ch := lx.src[lx.pos]
+		if ch == '\\' && quote != '`' && lx.backslashEscapes && lx.pos+1 < len(lx.src) {
+			b.WriteRune(lx.src[lx.pos+1])
+			lx.pos += 2
+			continue
+		}
+		if ch == quote {
+			if lx.peek(1) == quote {
+				b.WriteRune(quote)
+				lx.pos += 2
+				continue
+			}
+			lx.pos++
+			return b.String()
+		}
+		b.WriteRune(ch)
+		lx.pos++
+	}
+	return b.String()
+}
+
+// dollarQuoted reads a PostgreSQL $tag$ ... $tag$ string; returns false if the $ does not start one
+// (e.g. a $1 positional parameter)
+func (lx *lexer) dollarQuoted() bool {
+	end := lx.pos + 1
+	for end < len(lx.src) && (lx.src[end] == '_' || unicode.IsLetter(lx.src[end]) || (end > lx.pos+1 && unicode.IsDigit(lx.src[end]))) {
+		end++
+	}
+	if end >= len(lx.src) || lx.src[end] != '$' {
+		return false
+	}
+	start := lx.pos
+	tag := string(lx.src[lx.pos : end+1])
+	rest := string(lx.src[end+1:])
+	closeIdx := strings.Index(rest, tag)
+	if closeIdx < 0 {
+		lx.pos = len(lx.src)
+		lx.emit(TokenString, rest, start)
+		return true
+	}
+	content := rest[:closeIdx]
+	lx.pos = end + 1 + utf8.RuneCountInString(content) + utf8.RuneCountInString(tag)
+	lx.emit(TokenString, content, start)
+	return true
+}
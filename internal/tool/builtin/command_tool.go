@@ -9,36 +9,23 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/sandbox"
 	"github.com/aiq/aiq/internal/ui"
 )
 
 // CommandTool handles command execution
 type CommandTool struct {
-	blockedCommands     map[string]bool // Blocklist of dangerous commands
-	interactiveCommands map[string]bool // Commands that require interactive input
+	interactiveCommands map[string]bool         // Commands that require interactive input
+	loadSandbox         func() sandbox.Settings // Reads the sandbox settings for each command
 }
 
-// NewCommandTool creates a new command tool with security blocklist
-// By default, all commands are allowed except those in the blocklist
+// NewCommandTool creates a new command tool
+// Dangerous commands are blocked by deny rules in the risk policy (~/.aiq/config/policy.yaml)
 func NewCommandTool() *CommandTool {
-	// Default blocklist: dangerous commands that should be blocked
-	blocked := map[string]bool{
-		"rm":       true, // Remove files/directories - dangerous
-		"sudo":     true, // Execute as superuser - dangerous
-		"dd":       true, // Disk dump - can destroy data
-		"mkfs":     true, // Make filesystem - can destroy data
-		"fdisk":    true, // Disk partitioning - can destroy data
-		"shutdown": true, // Shutdown system - dangerous
-		"reboot":   true, // Reboot system - dangerous
-		"halt":     true, // Halt system - dangerous
-		"poweroff": true, // Power off system - dangerous
-		"init":     true, // Init system - dangerous
-		"killall":  true, // Kill all processes - dangerous
-		"kill":     true, // Kill processes - dangerous (especially kill -9)
-	}
-
 	// Commands that require interactive input (cannot be executed non-interactively)
 	interactive := map[string]bool{
 		"mysql_secure_installation": true,
@@ -55,16 +42,8 @@ func NewCommandTool() *CommandTool {
 	}
 
 	return &CommandTool{
-		blockedCommands:     blocked,
 		interactiveCommands: interactive,
-	}
-}
-
-// SetBlockedCommands sets the blocklist of blocked commands
-func (t *CommandTool) SetBlockedCommands(commands []string) {
-	t.blockedCommands = make(map[string]bool)
-	for _, cmd := range commands {
-		t.blockedCommands[cmd] = true
+		loadSandbox:         sandbox.Load,
 	}
 }
 
@@ -86,6 +65,9 @@ type CommandResult struct {
 	TruncatedStdout string `json:"truncated_stdout"` // Truncated stdout for LLM (last N lines)
 	TruncatedStderr string `json:"truncated_stderr"` // Truncated stderr for LLM (last N lines)
 	ExitCode        int    `json:"exit_code"`
+
+	LimitExceeded string          `json:"limit_exceeded,omitempty"` // Sandbox limit that stopped the command
+	Sandbox       *sandbox.Report `json:"sandbox,omitempty"`        // Protections applied when the sandbox is enabled
 }
 
 // truncateOutput truncates output to last N lines
@@ -162,12 +144,19 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 		}
 	}
 
-	// Check if first command (after env vars) is blocked
+	// Check the command against policy deny rules (also enforced by the tool call loop, checked again here
+	// so direct callers of the tool cannot bypass the policy)
+	if decision := policy.Current().Evaluate("execute_command", map[string]interface{}{"command": cmdParams.Command}); decision.Action == policy.Deny {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "denied by policy"
+		}
+		return nil, fmt.Errorf("command is blocked by policy rule '%s': %s", decision.Rule, reason)
+	}
+
+	// Check if first command (after env vars) requires interactive input
 	if len(parts) > len(envVars) {
 		firstCmd := parts[len(envVars)]
-		if t.blockedCommands[firstCmd] {
-			return nil, fmt.Errorf("command '%s' is blocked for security reasons. Blocked commands: %v", firstCmd, t.getBlockedCommandList())
-		}
 		if t.interactiveCommands[firstCmd] {
 			return nil, fmt.Errorf("command '%s' requires interactive input and cannot be executed non-interactively. Please run this command manually in your terminal, or use a non-interactive alternative if available", firstCmd)
 		}
@@ -188,22 +177,38 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 		idleTimeout = time.Duration(cmdParams.Timeout) * time.Second
 	}
 
-	// Create command (no fixed context timeout - we use idle timeout instead)
-	cmd := exec.Command(cmdName, cmdArgs...)
-
-	// Set environment variables if any were specified
-	if len(envVars) > 0 {
-		// Start with current environment
-		cmd.Env = os.Environ()
-		// Add or override with specified environment variables
-		for _, envVar := range envVars {
-			cmd.Env = append(cmd.Env, envVar)
+	maxOutputSize := 10 * 1024 * 1024 // 10MB limit
+	settings := t.loadSandbox()
+	var cmd *exec.Cmd
+	var report *sandbox.Report
+	if settings.Enabled {
+		// Denials (allowlist, working directory) are returned as errors so the LLM sees the reason
+		if err := settings.Check(cmdParams.Command); err != nil {
+			return nil, err
+		}
+		cmd, report, err = settings.Command(shell, cmdParams.Command, cmdParams.WorkingDir)
+		if err != nil {
+			return nil, err
+		}
+		maxOutputSize = settings.MaxOutputBytes
+	} else {
+		// Create command (no fixed context timeout - we use idle timeout instead)
+		cmd = exec.Command(cmdName, cmdArgs...)
+
+		// Set environment variables if any were specified
+		if len(envVars) > 0 {
+			// Start with current environment
+			cmd.Env = os.Environ()
+			// Add or override with specified environment variables
+			for _, envVar := range envVars {
+				cmd.Env = append(cmd.Env, envVar)
+			}
 		}
-	}
 
-	// Set working directory
-	if cmdParams.WorkingDir != "" {
-		cmd.Dir = cmdParams.WorkingDir
+		// Set working directory
+		if cmdParams.WorkingDir != "" {
+			cmd.Dir = cmdParams.WorkingDir
+		}
 	}
 
 	// Use separate pipes for stdout and stderr to enable streaming
@@ -221,9 +226,33 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 		return nil, fmt.Errorf("failed to start command: %w", err)
 	}
 
+	// Sandboxed commands are killed with their whole process group
+	kill := func() { cmd.Process.Kill() }
+	if report != nil {
+		kill = func() { sandbox.Kill(cmd) }
+	}
+
 	// Buffers to collect output
 	var stdoutBuilder, stderrBuilder strings.Builder
-	maxOutputSize := 10 * 1024 * 1024 // 10MB limit
+	var outputBytes atomic.Int64
+	var outputExceeded atomic.Bool
+	store := func(builder *strings.Builder, line string) bool {
+		if report == nil {
+			if builder.Len()+len(line)+1 <= maxOutputSize {
+				builder.WriteString(line + "\n")
+			}
+			return true
+		}
+		// Sandboxed commands are stopped once their combined output exceeds the limit
+		if outputBytes.Add(int64(len(line)+1)) > int64(maxOutputSize) {
+			if outputExceeded.CompareAndSwap(false, true) {
+				kill()
+			}
+			return false
+		}
+		builder.WriteString(line + "\n")
+		return true
+	}
 
 	// Channels for coordination
 	done := make(chan error, 1)
@@ -244,14 +273,10 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 			case activity <- struct{}{}:
 			default:
 			}
-			// Call callback for real-time display
-			if callback != nil {
+			// Store in buffer and call callback for real-time display
+			if store(&stdoutBuilder, line) && callback != nil {
 				callback(line)
 			}
-			// Store in buffer
-			if stdoutBuilder.Len()+len(line)+1 <= maxOutputSize {
-				stdoutBuilder.WriteString(line + "\n")
-			}
 		}
 	}()
 
@@ -266,14 +291,10 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 			case activity <- struct{}{}:
 			default:
 			}
-			// Call callback for real-time display
-			if callback != nil {
+			// Store in buffer and call callback for real-time display
+			if store(&stderrBuilder, line) && callback != nil {
 				callback(line)
 			}
-			// Store in buffer
-			if stderrBuilder.Len()+len(line)+1 <= maxOutputSize {
-				stderrBuilder.WriteString(line + "\n")
-			}
 		}
 	}()
 
@@ -325,6 +346,12 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 				TruncatedStdout: truncatedStdout,
 				TruncatedStderr: truncatedStderr,
 				ExitCode:        exitCode,
+				Sandbox:         report,
+			}
+			if outputExceeded.Load() {
+				result.LimitExceeded = fmt.Sprintf("output exceeded %d KB and the command was killed (sandbox.max_output_kb)", maxOutputSize/1024)
+			} else if report != nil {
+				result.LimitExceeded = settings.LimitExceeded(err)
 			}
 
 			return result, nil
@@ -346,12 +373,12 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 				fmt.Sprintf("Command has been idle for %v. Continue waiting?", idleTimeout))
 			if err != nil {
 				// User interrupted (Ctrl+C), treat as cancellation
-				cmd.Process.Kill()
+				kill()
 				return nil, fmt.Errorf("command execution cancelled by user")
 			}
 			if !continueWaiting {
 				// User chose not to continue
-				cmd.Process.Kill()
+				kill()
 				return nil, fmt.Errorf("command execution timeout: no output for %v", idleTimeout)
 			}
 			// User chose to continue, reset the timer
@@ -359,28 +386,19 @@ func (t *CommandTool) ExecuteWithCallback(ctx context.Context, params map[string
 
 		case <-ctx.Done():
 			// Parent context cancelled
-			cmd.Process.Kill()
+			kill()
 			return nil, fmt.Errorf("command execution cancelled: %w", ctx.Err())
 		}
 	}
 }
 
-// getBlockedCommandList returns list of blocked commands
-func (t *CommandTool) getBlockedCommandList() []string {
-	var list []string
-	for cmd := range t.blockedCommands {
-		list = append(list, cmd)
-	}
-	return list
-}
-
 // GetDefinition returns the tool definition for LLM
 func (t *CommandTool) GetDefinition() map[string]interface{} {
 	return map[string]interface{}{
 		"type": "function",
 		"function": map[string]interface{}{
 			"name":        "execute_command",
-			"description": "Execute shell commands for system operations (installation, setup, configuration). Use for system operations, NOT for database queries. Most commands are allowed, but dangerous commands (like rm, sudo, dd) are blocked for security.",
+			"description": "Execute shell commands for system operations (installation, setup, configuration). Use for system operations, NOT for database queries. Most commands are allowed, but dangerous commands (like rm, sudo, dd) are blocked by the risk policy. When the sandbox is enabled, commands run confined to a work directory with resource limits (see the sandbox field of the result), and denied commands return an error explaining why.",
 			"parameters": map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -2,10 +2,22 @@ package builtin
 
 import (
 	"context"
+	"errors"
+	"os"
+	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/sandbox"
 )
 
+// TestMain lets the test binary act as the sandbox helper, as the aiq binary does
+func TestMain(m *testing.M) {
+	sandbox.Main()
+	os.Exit(m.Run())
+}
+
 func TestCommandTool_ExecuteSimpleCommand(t *testing.T) {
 	// Basic test to verify command execution works
 	tool := NewCommandTool()
@@ -173,6 +185,49 @@ func TestCommandTool_ErrorScenarios(t *testing.T) {
 	})
 }
 
+func TestCommandTool_Sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sandbox is not supported on Windows")
+	}
+	newTool := func(c config.SandboxConfig) *CommandTool {
+		c.Enabled = true
+		c.WorkDir = t.TempDir()
+		tool := NewCommandTool()
+		tool.loadSandbox = func() sandbox.Settings { return sandbox.FromConfig(c) }
+		return tool
+	}
+
+	t.Run("denies commands outside the allowlist", func(t *testing.T) {
+		tool := newTool(config.SandboxConfig{AllowlistOnly: true, AllowedCommands: []string{"echo"}})
+		_, err := tool.Execute(context.Background(), map[string]interface{}{"command": "echo ok | python3 -c 'print(1)'"})
+		var denied *sandbox.DeniedError
+		if !errors.As(err, &denied) || !strings.Contains(err.Error(), "python3") {
+			t.Errorf("Expected a sandbox denial naming python3, got %v", err)
+		}
+	})
+
+	t.Run("denies working directories outside the sandbox", func(t *testing.T) {
+		tool := newTool(config.SandboxConfig{})
+		_, err := tool.Execute(context.Background(), map[string]interface{}{"command": "pwd", "working_dir": "/"})
+		var denied *sandbox.DeniedError
+		if !errors.As(err, &denied) {
+			t.Errorf("Expected a sandbox denial, got %v", err)
+		}
+	})
+
+	t.Run("kills commands exceeding the output limit", func(t *testing.T) {
+		tool := newTool(config.SandboxConfig{MaxOutputKB: 1})
+		result, err := tool.Execute(context.Background(), map[string]interface{}{"command": "while :; do echo 0123456789; done"})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		cmdResult := result.(CommandResult)
+		if cmdResult.LimitExceeded == "" || cmdResult.Sandbox == nil || len(cmdResult.Stdout) > 1024 {
+			t.Errorf("Expected the output limit to stop the command, got %+v", cmdResult)
+		}
+	})
+}
+
 // Note: Testing the user prompt functionality (tasks 4.1-4.5) requires:
 // 1. Mocking ui.ShowConfirm() - complex, requires dependency injection or interface
 // 2. Simulating idle timeout scenarios - requires controlling time and command output
@@ -0,0 +1,114 @@
+package builtin
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+var (
+	// sharedAddressSpace is carrier-grade NAT space (RFC 6598), not covered by netip.Addr.IsPrivate
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	// thisNetwork is 0.0.0.0/8, which some systems route to the local host
+	thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+	// nat64Prefix embeds IPv4 addresses in IPv6 (RFC 6052); the embedded address is checked too
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// blockedError is returned for requests refused by the host lists or address checks
+type blockedError struct {
+	reason string
+}
+
+func (e *blockedError) Error() string {
+	return "request blocked: " + e.reason
+}
+
+// httpGuard enforces the host allowlist/denylist and blocks private, loopback and link-local addresses
+// Addresses are checked when connecting, after DNS resolution, so a public name pointing at an internal
+// address (or re-resolving to one) is refused as well
+type httpGuard struct {
+	allowHosts      []string
+	denyHosts       []string
+	allowedNetworks []netip.Prefix
+}
+
+func newHTTPGuard(cfg config.HTTPConfig) (*httpGuard, error) {
+	g := &httpGuard{allowHosts: cfg.AllowHosts, denyHosts: cfg.DenyHosts}
+	for _, cidr := range cfg.AllowedNetworks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http.allowed_networks entry %q: %w", cidr, err)
+		}
+		g.allowedNetworks = append(g.allowedNetworks, prefix.Masked())
+	}
+	return g, nil
+}
+
+// checkHost applies the host lists to a URL host name
+func (g *httpGuard) checkHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchHost(g.denyHosts, host) {
+		return &blockedError{reason: fmt.Sprintf("host %s is in http.deny_hosts", host)}
+	}
+	if len(g.allowHosts) > 0 && !matchHost(g.allowHosts, host) {
+		return &blockedError{reason: fmt.Sprintf("host %s is not in http.allow_hosts (%s)", host, strings.Join(g.allowHosts, ", "))}
+	}
+	return nil
+}
+
+// checkAddr refuses internal addresses unless they are in http.allowed_networks
+func (g *httpGuard) checkAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if !isInternalAddr(ip) {
+		return nil
+	}
+	for _, prefix := range g.allowedNetworks {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	return &blockedError{reason: fmt.Sprintf("%s is a private, loopback or link-local address (add it to http.allowed_networks to allow it)", ip)}
+}
+
+// dialControl runs for every connection attempt with the resolved address
+func (g *httpGuard) dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return &blockedError{reason: fmt.Sprintf("invalid address %s", address)}
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return &blockedError{reason: fmt.Sprintf("invalid address %s", address)}
+	}
+	return g.checkAddr(ip)
+}
+
+// isInternalAddr reports addresses that must not be reachable by default (cloud metadata, LAN, localhost)
+func isInternalAddr(ip netip.Addr) bool {
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		ip = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) || thisNetwork.Contains(ip)
+}
+
+// matchHost matches a host name against patterns; "*.example.com" matches subdomains, "*" any host
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		switch {
+		case pattern == "*" || pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			return true
+		}
+	}
+	return false
+}
@@ -4,28 +4,49 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/secrets"
 )
 
+// defaultMaxResponseKB is the response body size after which the body is truncated
+const defaultMaxResponseKB = 1024
+
 // HTTPTool handles HTTP requests
-type HTTPTool struct{}
+type HTTPTool struct {
+	loadConfig func() config.HTTPConfig // Reads the http section of config.yaml for each request
+}
 
 // NewHTTPTool creates a new HTTP tool
 func NewHTTPTool() *HTTPTool {
-	return &HTTPTool{}
+	return &HTTPTool{loadConfig: loadHTTPConfig}
+}
+
+func loadHTTPConfig() config.HTTPConfig {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.HTTPConfig{}
+	}
+	return cfg.HTTP
 }
 
 // HTTPRequestParams represents parameters for HTTP request
 type HTTPRequestParams struct {
-	Method  string            `json:"method"` // GET, POST, PUT, DELETE
-	URL     string            `json:"url"`
-	Headers map[string]string `json:"headers,omitempty"`
-	Body    string            `json:"body,omitempty"`
-	Timeout int               `json:"timeout,omitempty"` // Timeout in seconds, default 30
+	Method      string            `json:"method"` // GET, HEAD, POST, PUT, PATCH, DELETE
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	Timeout     int               `json:"timeout,omitempty"`      // Timeout in seconds, default 30
+	AuthProfile string            `json:"auth_profile,omitempty"` // Name of a configured auth profile
 }
 
 // HTTPResponse represents HTTP response
@@ -34,6 +55,7 @@ type HTTPResponse struct {
 	StatusText string            `json:"status_text"`
 	Headers    map[string]string `json:"headers"`
 	Body       string            `json:"body"`
+	Truncated  bool              `json:"truncated,omitempty"` // Body was cut at http.max_response_kb
 }
 
 // Execute executes an HTTP request
@@ -49,12 +71,12 @@ func (t *HTTPTool) Execute(ctx context.Context, params map[string]interface{}) (
 	}
 
 	// Validate method
-	method := httpParams.Method
+	method := strings.ToUpper(httpParams.Method)
 	if method == "" {
 		method = "GET"
 	}
 	switch method {
-	case "GET", "POST", "PUT", "DELETE":
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE":
 		// Valid method
 	default:
 		return nil, fmt.Errorf("unsupported HTTP method: %s", method)
@@ -64,10 +86,46 @@ func (t *HTTPTool) Execute(ctx context.Context, params map[string]interface{}) (
 	if httpParams.URL == "" {
 		return nil, fmt.Errorf("URL is required")
 	}
-	_, err = url.Parse(httpParams.URL)
+	target, err := url.Parse(httpParams.URL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return nil, fmt.Errorf("invalid URL: %s (an http or https URL with a host is required)", httpParams.URL)
+	}
+
+	cfg := t.loadConfig()
+	guard, err := newHTTPGuard(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := guard.checkHost(target.Hostname()); err != nil {
+		return nil, err
+	}
+
+	// Resolve the auth profile; its secret values are injected here and never returned to the LLM
+	var profile *config.HTTPAuthProfile
+	var profileHeaders map[string]string
+	var secretValues []string
+	if httpParams.AuthProfile != "" {
+		p, ok := cfg.AuthProfiles[httpParams.AuthProfile]
+		if !ok {
+			return nil, fmt.Errorf("unknown auth profile %q (configured: %s)", httpParams.AuthProfile, strings.Join(authProfileNames(cfg), ", "))
+		}
+		if !matchHost(p.Hosts, strings.ToLower(target.Hostname())) {
+			return nil, &blockedError{reason: fmt.Sprintf("auth profile %q may not be sent to %s (allowed hosts: %s)", httpParams.AuthProfile, target.Hostname(), strings.Join(p.Hosts, ", "))}
+		}
+		profile = &p
+		profileHeaders = make(map[string]string, len(p.Headers))
+		for key, value := range p.Headers {
+			expanded, used, err := secrets.Expand(value)
+			if err != nil {
+				return nil, fmt.Errorf("auth profile %q: %w", httpParams.AuthProfile, err)
+			}
+			profileHeaders[key] = expanded
+			secretValues = append(secretValues, used...)
+		}
+	}
 
 	// Set timeout
 	timeout := 30 * time.Second
@@ -77,7 +135,7 @@ func (t *HTTPTool) Execute(ctx context.Context, params map[string]interface{}) (
 
 	// Create request
 	var bodyReader io.Reader
-	if httpParams.Body != "" && (method == "POST" || method == "PUT") {
+	if httpParams.Body != "" && (method == "POST" || method == "PUT" || method == "PATCH") {
 		bodyReader = bytes.NewReader([]byte(httpParams.Body))
 	}
 
@@ -86,64 +144,118 @@ func (t *HTTPTool) Execute(ctx context.Context, params map[string]interface{}) (
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set headers
+	// Set headers (auth profile headers take precedence over headers from the LLM)
 	for key, value := range httpParams.Headers {
 		req.Header.Set(key, value)
 	}
+	for key, value := range profileHeaders {
+		req.Header.Set(key, value)
+	}
 
 	// Set default Content-Type if body is provided and not set
 	if bodyReader != nil && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	// Execute request
+	// Execute request; no proxy, so the address check applies to the actual destination
+	dialer := &net.Dialer{Timeout: timeout, Control: guard.dialControl}
 	client := &http.Client{
 		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			ForceAttemptHTTP2:   true,
+		},
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if err := guard.checkHost(next.URL.Hostname()); err != nil {
+				return err
+			}
+			if profile != nil && !matchHost(profile.Hosts, strings.ToLower(next.URL.Hostname())) {
+				return &blockedError{reason: fmt.Sprintf("redirect to %s would send auth profile %q outside its hosts", next.URL.Hostname(), httpParams.AuthProfile)}
+			}
+			return nil
+		},
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
+		var blocked *blockedError
+		if errors.As(err, &blocked) {
+			return nil, blocked
+		}
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
+	// Read response body up to the size limit
+	maxBytes := int64(defaultMaxResponseKB) * 1024
+	if cfg.MaxResponseKB > 0 {
+		maxBytes = int64(cfg.MaxResponseKB) * 1024
+	}
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	truncated := int64(len(bodyBytes)) > maxBytes
+	if truncated {
+		bodyBytes = bodyBytes[:maxBytes]
+	}
 
 	// Build response
 	response := HTTPResponse{
 		Status:     resp.StatusCode,
 		StatusText: resp.Status,
 		Headers:    make(map[string]string),
-		Body:       string(bodyBytes),
+		Body:       redactSecrets(string(bodyBytes), secretValues),
+		Truncated:  truncated,
 	}
 
 	// Copy headers
 	for key, values := range resp.Header {
 		if len(values) > 0 {
-			response.Headers[key] = values[0]
+			response.Headers[key] = redactSecrets(values[0], secretValues)
 		}
 	}
 
 	return response, nil
 }
 
+// redactSecrets hides injected secret values that an endpoint echoes back
+func redactSecrets(s string, values []string) string {
+	for _, value := range values {
+		if len(value) >= 4 {
+			s = strings.ReplaceAll(s, value, "[REDACTED]")
+		}
+	}
+	return s
+}
+
+// authProfileNames returns the configured auth profile names, sorted
+func authProfileNames(cfg config.HTTPConfig) []string {
+	names := make([]string, 0, len(cfg.AuthProfiles))
+	for name := range cfg.AuthProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetDefinition returns the tool definition for LLM
 func (t *HTTPTool) GetDefinition() map[string]interface{} {
-	return map[string]interface{}{
+	definition := map[string]interface{}{
 		"type": "function",
 		"function": map[string]interface{}{
 			"name":        "http_request",
-			"description": "Make HTTP requests (GET, POST, PUT, DELETE). Use this to fetch data from APIs or send data to endpoints.",
+			"description": "Make HTTP requests (GET, HEAD, POST, PUT, PATCH, DELETE). Use this to fetch data from APIs or send data to endpoints. Private, loopback and link-local addresses are blocked unless configured, and large response bodies are truncated.",
 			"parameters": map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"method": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"GET", "POST", "PUT", "DELETE"},
+						"enum":        []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
 						"description": "HTTP method",
 						"default":     "GET",
 					},
@@ -157,7 +269,7 @@ func (t *HTTPTool) GetDefinition() map[string]interface{} {
 					},
 					"body": map[string]interface{}{
 						"type":        "string",
-						"description": "Request body (for POST/PUT)",
+						"description": "Request body (for POST/PUT/PATCH)",
 					},
 					"timeout": map[string]interface{}{
 						"type":        "integer",
@@ -183,4 +295,15 @@ func (t *HTTPTool) GetDefinition() map[string]interface{} {
 			},
 		},
 	}
+
+	// Offer the configured auth profiles by name only
+	if names := authProfileNames(t.loadConfig()); len(names) > 0 {
+		properties := definition["function"].(map[string]interface{})["parameters"].(map[string]interface{})["properties"].(map[string]interface{})
+		properties["auth_profile"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        names,
+			"description": "Optional: Named auth profile configured by the user. Its credentials are added to the request headers automatically; never ask for or send tokens yourself.",
+		}
+	}
+	return definition
 }
@@ -2,7 +2,15 @@ package builtin
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+
+	"github.com/aiq/aiq/internal/config"
 )
 
 // TestHTTPTool_GETRequests tests HTTP tool GET requests
@@ -115,3 +123,111 @@ func TestHTTPTool_ErrorHandling(t *testing.T) {
 		}
 	})
 }
+
+// TestHTTPTool_Guard tests host lists, private address blocking, size limits and auth profiles
+func TestHTTPTool_Guard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("x", 4096))
+		case "/redirect":
+			http.Redirect(w, r, "http://localhost"+strings.TrimPrefix(r.Host, "127.0.0.1")+"/echo", http.StatusFound)
+		default:
+			w.Header().Set("X-Echo-Auth", r.Header.Get("Authorization"))
+			fmt.Fprintf(w, "%s auth=%s", r.Method, r.Header.Get("Authorization"))
+		}
+	}))
+	defer server.Close()
+
+	newTool := func(cfg config.HTTPConfig) *HTTPTool {
+		return &HTTPTool{loadConfig: func() config.HTTPConfig { return cfg }}
+	}
+	execute := func(tool *HTTPTool, params map[string]interface{}) (HTTPResponse, error) {
+		result, err := tool.Execute(context.Background(), params)
+		if err != nil {
+			return HTTPResponse{}, err
+		}
+		return result.(HTTPResponse), nil
+	}
+	local := config.HTTPConfig{AllowedNetworks: []string{"127.0.0.0/8"}}
+
+	t.Run("blocks loopback addresses by default", func(t *testing.T) {
+		_, err := execute(newTool(config.HTTPConfig{}), map[string]interface{}{"url": server.URL})
+		var blocked *blockedError
+		if !errors.As(err, &blocked) {
+			t.Errorf("Expected the request to be blocked, got %v", err)
+		}
+	})
+
+	t.Run("allows configured networks and PATCH/HEAD", func(t *testing.T) {
+		tool := newTool(local)
+		response, err := execute(tool, map[string]interface{}{"url": server.URL, "method": "patch", "body": "{}"})
+		if err != nil || !strings.HasPrefix(response.Body, "PATCH") {
+			t.Errorf("Expected PATCH to succeed, got %+v, %v", response, err)
+		}
+		response, err = execute(tool, map[string]interface{}{"url": server.URL, "method": "HEAD"})
+		if err != nil || response.Status != http.StatusOK || response.Body != "" {
+			t.Errorf("Expected HEAD to succeed without a body, got %+v, %v", response, err)
+		}
+	})
+
+	t.Run("applies host lists", func(t *testing.T) {
+		cfg := local
+		cfg.DenyHosts = []string{"127.0.0.1"}
+		if _, err := execute(newTool(cfg), map[string]interface{}{"url": server.URL}); err == nil || !strings.Contains(err.Error(), "deny_hosts") {
+			t.Errorf("Expected a deny_hosts error, got %v", err)
+		}
+		cfg = local
+		cfg.AllowHosts = []string{"*.example.com"}
+		if _, err := execute(newTool(cfg), map[string]interface{}{"url": server.URL}); err == nil || !strings.Contains(err.Error(), "allow_hosts") {
+			t.Errorf("Expected an allow_hosts error, got %v", err)
+		}
+	})
+
+	t.Run("truncates large responses", func(t *testing.T) {
+		cfg := local
+		cfg.MaxResponseKB = 1
+		response, err := execute(newTool(cfg), map[string]interface{}{"url": server.URL + "/large"})
+		if err != nil || !response.Truncated || len(response.Body) != 1024 {
+			t.Errorf("Expected a 1 KB truncated body, got %d bytes, %v", len(response.Body), err)
+		}
+	})
+
+	t.Run("injects auth profile headers without returning them", func(t *testing.T) {
+		t.Setenv("AIQ_SECRET_TEST_TOKEN", "s3cr3t-token")
+		cfg := local
+		cfg.AuthProfiles = map[string]config.HTTPAuthProfile{
+			"test": {Hosts: []string{"127.0.0.1"}, Headers: map[string]string{"Authorization": "Bearer ${secret:test_token}"}},
+		}
+		tool := newTool(cfg)
+		response, err := execute(tool, map[string]interface{}{"url": server.URL, "auth_profile": "test"})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if response.Body != "GET auth=Bearer [REDACTED]" || response.Headers["X-Echo-Auth"] != "Bearer [REDACTED]" {
+			t.Errorf("Expected the token to be sent and redacted, got %+v", response)
+		}
+		if _, err := execute(tool, map[string]interface{}{"url": server.URL + "/redirect", "auth_profile": "test"}); err == nil {
+			t.Error("Expected a redirect outside the profile hosts to be refused")
+		}
+		if _, err := execute(tool, map[string]interface{}{"url": server.URL, "auth_profile": "missing"}); err == nil {
+			t.Error("Expected an error for an unknown profile")
+		}
+	})
+}
+
+// TestIsInternalAddr tests the address classification used for SSRF protection
+func TestIsInternalAddr(t *testing.T) {
+	internal := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00:ec2::254", "fe80::1", "64:ff9b::a00:1"}
+	public := []string{"93.184.216.34", "8.8.8.8", "2606:4700:4700::1111"}
+	for _, s := range internal {
+		if !isInternalAddr(netip.MustParseAddr(s)) {
+			t.Errorf("Expected %s to be internal", s)
+		}
+	}
+	for _, s := range public {
+		if isInternalAddr(netip.MustParseAddr(s)) {
+			t.Errorf("Expected %s to be public", s)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// TransactionTool lets the LLM group several execute_sql calls into one atomic transaction
+type TransactionTool struct {
+	conn *db.Connection
+}
+
+// NewTransactionTool creates a transaction tool for a database connection
+func NewTransactionTool(conn *db.Connection) *TransactionTool {
+	return &TransactionTool{conn: conn}
+}
+
+// TransactionResult represents the state of the session transaction after an operation
+type TransactionResult struct {
+	Success       bool   `json:"success"`
+	Message       string `json:"message"`
+	InTransaction bool   `json:"in_transaction"`
+	OpenSeconds   int    `json:"open_seconds,omitempty"`
+}
+
+// Execute begins, commits or rolls back the session transaction, or reports its status
+func (t *TransactionTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	if t.conn == nil {
+		return nil, fmt.Errorf("transactions are not available in free mode")
+	}
+	operation, ok := params["operation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("operation is required (begin, commit, rollback, status)")
+	}
+
+	var message string
+	switch operation {
+	case "begin":
+		if err := t.conn.Begin(); err != nil {
+			return nil, err
+		}
+		message = "Transaction started. Subsequent execute_sql calls run inside it until commit or rollback."
+	case "commit":
+		if err := t.conn.Commit(); err != nil {
+			return nil, err
+		}
+		message = "Transaction committed."
+	case "rollback":
+		if err := t.conn.Rollback(); err != nil {
+			return nil, err
+		}
+		message = "Transaction rolled back."
+	case "status":
+		message = "No transaction is open."
+		if t.conn.InTransaction() {
+			message = "A transaction is open."
+		}
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
+	}
+
+	result := &TransactionResult{Success: true, Message: message}
+	if tx := t.conn.Transaction(); tx != nil {
+		result.InTransaction = true
+		result.OpenSeconds = int(time.Since(tx.StartedAt()).Seconds())
+	}
+	return result, nil
+}
+
+// GetDefinition returns the tool definition for LLM
+func (t *TransactionTool) GetDefinition() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "transaction",
+			"description": "Control the database transaction of this session. Use 'begin' before a multi-step change that must be atomic (e.g. moving rows and updating totals), run the statements with execute_sql, then 'commit' once all steps succeeded or 'rollback' if any failed. Do not send BEGIN/COMMIT through execute_sql.",
+			"parameters": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"operation": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"begin", "commit", "rollback", "status"},
+						"description": "Operation to perform",
+					},
+				},
+				"required": []string{"operation"},
+			},
+		},
+	}
+}
@@ -0,0 +1,557 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
+	"github.com/aiq/aiq/internal/tool/builtin"
+	"github.com/aiq/aiq/internal/ui"
+)
+
+// registerBuiltinTools registers the tools that ship with aiq
+func registerBuiltinTools(r *Registry) {
+	r.Register(&Tool{
+		Name:        "execute_sql",
+		Description: "**MANDATORY TOOL CALL**: Execute a SQL query against the database and return the results. Available ONLY in database mode when a database source is selected. **CRITICAL**: When the user requests database operations (SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, SHOW, etc.), you MUST call this tool. Do NOT describe what you will do in text - actually call the tool. Do NOT say 'I will execute' or 'Stand by while I execute' - just call the tool directly.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"sql": map[string]interface{}{
+					"type":        "string",
+					"description": "The SQL query to execute",
+				},
+				"risk_level": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"low", "medium", "high"},
+					"description": "Optional: Risk level assessment for this operation. 'low' = safe to execute automatically (e.g., SELECT, SHOW), 'medium'/'high' = requires user confirmation (e.g., DROP, TRUNCATE). If not provided, system will assess risk conservatively.",
+				},
+				"task_type": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"definitive", "exploratory"},
+					"description": "Optional: Task type classification. 'definitive' = task is clear and complete, 'exploratory' = task requires information gathering or multi-step process. If not provided, system will infer from context.",
+				},
+				"output_mode": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"full", "streaming"},
+					"description": "**REQUIRED**: Output display mode. Classify tool call as process-oriented or result-oriented: 'full' = result-oriented (tool output IS the final goal, e.g., 'show tables'), 'streaming' = process-oriented (tool output is intermediate step, e.g., 'analyze sales trends'). **MANDATORY**: Always explicitly set this parameter.",
+				},
+			},
+			"required": []string{"sql"},
+		},
+		Modes:       ModeDatabase,
+		Execute:     executeSQLTool,
+		Risk:        NewSQLRiskAssessor(),
+		Confirm:     true,
+		Query:       sqlArg,
+		MultiSource: true,
+		ReadOnly:    readOnlySQL,
+		Waiting:     "Executing SQL...",
+		Describe:    describeSQL,
+		Statement:   sqlStatement,
+		Present:     presentSQLResult,
+	})
+
+	r.Register(&Tool{
+		Name:        "render_table",
+		Description: "Format query results as a table string. Use this when you want to show data in a tabular format. **IMPORTANT**: Pass the result_id returned by execute_sql instead of copying rows; select, sort and limit with columns, sort and limit. Only generate new SQL queries if the user explicitly requests different data.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": resultParameters("Column names", "Row data, each row is an array of string values"),
+		},
+		Execute:  executeRenderTable,
+		ReadOnly: alwaysReadOnly,
+		Describe: describeRows,
+	})
+
+	r.Register(&Tool{
+		Name:        "render_chart",
+		Description: "**MANDATORY TOOL CALL**: When the user requests chart visualization (pie chart, bar chart, line chart, etc.), you MUST call this tool. Do NOT return text descriptions or JSON data. The chart will be automatically displayed in the terminal. **CRITICAL**: Pass the result_id of a recent execute_sql result instead of copying its rows; pick the label and value columns with columns. Only generate new SQL queries if the user explicitly requests different data or no recent results are available.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": withProperty(resultParameters(
+				"Column names from query results (e.g., [\"category\", \"total_revenue\"])",
+				"Row data from query results, each row is an array of string values (e.g., [[\"Appliances\", \"159.98\"], [\"Electronics\", \"2699.95\"]])",
+			), "chart_type", map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"bar", "line", "pie", "scatter"},
+				"description": "Type of chart: 'pie' for pie charts, 'bar' for bar charts, 'line' for line charts, 'scatter' for scatter plots",
+			}),
+			"required": []string{"chart_type"},
+		},
+		Execute:  executeRenderChart,
+		ReadOnly: alwaysReadOnly,
+		Describe: describeRows,
+		Present:  presentChart,
+	})
+
+	r.Register(&Tool{
+		Name:        "transform_result",
+		Description: "Refine a stored result locally without querying the database again: filter, sort, top N, group with aggregates, pivot/unpivot, computed columns, or join two stored results. Use this for follow-ups on results already fetched (e.g. \"only the top 5\", \"pivot that by month\"). The transformed result is displayed and stored under a new result_id.",
+		Parameters:  transformResultParameters,
+		Execute:     executeTransformResult,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		ReadOnly:    alwaysReadOnly,
+		Waiting:     "Transforming result...",
+		Describe:    describeTransform,
+		Statement:   transformStatement,
+		Present:     presentSQLResult,
+	})
+
+	r.Register(&Tool{
+		Name:        "join_results",
+		Description: "Run a SELECT across stored results locally, e.g. rows fetched with execute_sql from different sources. Fetch from each source first (filtered as far as possible), then list the result_ids, each loaded as a table (named after the result_id or the given name), and query them with joins, filters, GROUP BY and aggregates. The outcome is displayed and stored under a new result_id.",
+		Parameters:  joinResultsParameters,
+		Execute:     executeJoinResults,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		ReadOnly:    alwaysReadOnly,
+		Waiting:     "Querying results...",
+		Describe:    describeJoin,
+		Statement:   joinStatement,
+		Present:     presentSQLResult,
+	})
+
+	registerDefinition(r, builtin.NewHTTPTool().GetDefinition(), &Tool{
+		Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+			return builtin.NewHTTPTool().Execute(ctx, args)
+		},
+		Risk:      NewHTTPRequestRiskAssessor(),
+		Confirm:   true,
+		ReadOnly:  readOnlyHTTPRequest,
+		Waiting:   "Waiting for HTTP response...",
+		Describe:  describeHTTPRequest,
+		Statement: httpRequestStatement,
+	})
+
+	registerDefinition(r, builtin.NewCommandTool().GetDefinition(), &Tool{
+		Execute:       executeCommandTool,
+		Risk:          NewCommandRiskAssessor(),
+		Confirm:       true,
+		StreamsOutput: true,
+		Describe:      describeCommand,
+		Statement:     commandStatement,
+	})
+
+	// The file tool is only offered when its base directory can be set up
+	if fileTool, err := builtin.NewFileTool(); err == nil {
+		registerDefinition(r, fileTool.GetDefinition(), &Tool{
+			Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+				fileTool, err := builtin.NewFileTool()
+				if err != nil {
+					return nil, err
+				}
+				return fileTool.Execute(ctx, args)
+			},
+			Risk:      NewFileOperationRiskAssessor(),
+			Confirm:   true,
+			ReadOnly:  readOnlyFileOperation,
+			Describe:  describeFileOperation,
+			Statement: fileOperationStatement,
+		})
+	}
+
+	registerDefinition(r, builtin.NewTransactionTool(nil).GetDefinition(), &Tool{
+		Modes: ModeDatabase,
+		Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+			return builtin.NewTransactionTool(env.Conn).Execute(ctx, args)
+		},
+		Confirm: true,
+	})
+}
+
+// registerDefinition registers t with the name, description and parameters of an LLM function definition
+func registerDefinition(r *Registry, definition map[string]interface{}, t *Tool) {
+	fn, _ := definition["function"].(map[string]interface{})
+	t.Name, _ = fn["name"].(string)
+	t.Description, _ = fn["description"].(string)
+	t.Parameters, _ = fn["parameters"].(map[string]interface{})
+	r.Register(t)
+}
+
+func sqlArg(args map[string]interface{}) (string, bool) {
+	sql, ok := args["sql"].(string)
+	return sql, ok
+}
+
+// readOnlySQL reports whether every statement reads only, under every dialect's quoting rules
+func readOnlySQL(args map[string]interface{}) bool {
+	sql, _ := sqlArg(args)
+	statements := sqlparse.ParseConservative(sql)
+	if len(statements) == 0 {
+		return false
+	}
+	for i := range statements {
+		if !statements[i].IsReadOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+func alwaysReadOnly(args map[string]interface{}) bool {
+	return true
+}
+
+func readOnlyHTTPRequest(args map[string]interface{}) bool {
+	method, _ := args["method"].(string)
+	switch strings.ToUpper(method) {
+	case "", "GET", "HEAD":
+		return true
+	}
+	return false
+}
+
+func readOnlyFileOperation(args map[string]interface{}) bool {
+	switch op, _ := args["operation"].(string); op {
+	case "read", "list", "exists":
+		return true
+	}
+	return false
+}
+
+func describeSQL(args map[string]interface{}) string {
+	sql, _ := sqlArg(args)
+	if source, ok := args["source"].(string); ok && source != "" {
+		return "on " + source + " with SQL: " + truncate(sql, 80)
+	}
+	return "with SQL: " + truncate(sql, 80)
+}
+
+func sqlStatement(args map[string]interface{}) string {
+	sql, _ := sqlArg(args)
+	return sql
+}
+
+func describeCommand(args map[string]interface{}) string {
+	cmd, _ := args["command"].(string)
+	return "with command: " + truncate(cmd, 80)
+}
+
+func commandStatement(args map[string]interface{}) string {
+	cmd, _ := args["command"].(string)
+	return cmd
+}
+
+func describeRows(args map[string]interface{}) string {
+	if resultID, ok := args["result_id"].(string); ok && resultID != "" {
+		return "with result " + resultID
+	}
+	rows, _ := args["rows"].([]interface{})
+	return fmt.Sprintf("with %d row(s)", len(rows))
+}
+
+func describeHTTPRequest(args map[string]interface{}) string {
+	url, _ := args["url"].(string)
+	method := "GET"
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = m
+	}
+	return method + " " + truncate(url, 60)
+}
+
+func httpRequestStatement(args map[string]interface{}) string {
+	url, _ := args["url"].(string)
+	method := "GET"
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+	}
+	return method + " " + url
+}
+
+func describeFileOperation(args map[string]interface{}) string {
+	op, _ := args["operation"].(string)
+	if path, ok := args["path"].(string); ok {
+		return op + ": " + truncate(path, 60)
+	}
+	return op
+}
+
+func fileOperationStatement(args map[string]interface{}) string {
+	op, _ := args["operation"].(string)
+	path, _ := args["path"].(string)
+	return strings.TrimSpace(op + " " + path)
+}
+
+// executeSQLTool runs execute_sql; DML and DDL report the affected row count instead of a result set
+func executeSQLTool(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	if env.Conn == nil {
+		return nil, fmt.Errorf("SQL execution is not available in free mode. Please select a database source to enable SQL queries.")
+	}
+	sql, ok := sqlArg(args)
+	if !ok {
+		return nil, fmt.Errorf("invalid sql parameter")
+	}
+
+	result, err := ExecuteSQL(ctx, env.Conn, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	// Non-query statements (INSERT, UPDATE, DELETE, DDL) have no result set, only an affected row count
+	if result.Columns == nil {
+		return map[string]interface{}{
+			"status":        "success",
+			"rows_affected": result.RowsAffected,
+		}, nil
+	}
+
+	// The LLM decides how to display the rows (via render_table or a text description)
+	resultData := map[string]interface{}{
+		"status":    "success",
+		"columns":   result.Columns,
+		"rows":      result.Rows,
+		"row_count": len(result.Rows),
+	}
+	// Render tools take the stored result by ID, so the LLM never has to copy rows back
+	if env.Results != nil {
+		resultData["result_id"] = env.Results.Add(sql, result.Columns, result.Rows)
+	}
+	markCached(resultData, result.CachedAt)
+	return resultData, nil
+}
+
+// markCached marks a tool result read from the result cache, which may be older than the data;
+// the user can re-run with /nocache
+func markCached(resultData map[string]interface{}, cachedAt time.Time) {
+	if cachedAt.IsZero() {
+		return
+	}
+	resultData["cached"] = true
+	resultData["cached_at"] = cachedAt.Format(time.RFC3339)
+	resultData["cache_note"] = cacheNote
+}
+
+// rowsArgs reads the columns and rows arguments of render_table and render_chart
+func rowsArgs(args map[string]interface{}) ([]string, [][]string, error) {
+	columnsInterface, ok := args["columns"].([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid columns parameter")
+	}
+	rowsInterface, ok := args["rows"].([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid rows parameter")
+	}
+
+	columns := make([]string, len(columnsInterface))
+	for i, col := range columnsInterface {
+		columns[i] = fmt.Sprintf("%v", col)
+	}
+
+	rows := make([][]string, len(rowsInterface))
+	for i, rowInterface := range rowsInterface {
+		rowArray, ok := rowInterface.([]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid row format")
+		}
+		rows[i] = make([]string, len(rowArray))
+		for j, val := range rowArray {
+			rows[i][j] = fmt.Sprintf("%v", val)
+		}
+	}
+	return columns, rows, nil
+}
+
+// executeRenderTable formats rows as a table string for the LLM (nothing is printed)
+func executeRenderTable(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	columns, rows, err := resultArgs(env, args)
+	if err != nil {
+		return nil, err
+	}
+	tableOutput, err := RenderTableString(columns, rows)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"status":    "success",
+		"format":    "table",
+		"output":    tableOutput,
+		"row_count": len(rows),
+	}, nil
+}
+
+// executeRenderChart renders rows as a chart string; presentChart displays it
+func executeRenderChart(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	columns, rows, err := resultArgs(env, args)
+	if err != nil {
+		return nil, err
+	}
+	chartType, ok := args["chart_type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid chart_type parameter")
+	}
+	chartOutput, err := RenderChartString(&db.QueryResult{Columns: columns, Rows: rows}, chartType)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"status":     "success",
+		"format":     "chart",
+		"output":     chartOutput,
+		"chart_type": chartType,
+		"row_count":  len(rows),
+	}, nil
+}
+
+// executeCommandTool runs execute_command, streaming lines to env.Output
+// In streaming mode the LLM receives the truncated tail of the output; in full mode the whole output
+func executeCommandTool(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	result, err := builtin.NewCommandTool().ExecuteWithCallback(ctx, args, env.Output)
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	var resultMap map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &resultMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	if env.OutputMode != OutputFull {
+		if truncatedStdout, ok := resultMap["truncated_stdout"].(string); ok && truncatedStdout != "" {
+			resultMap["stdout"] = truncatedStdout
+		}
+		if truncatedStderr, ok := resultMap["truncated_stderr"].(string); ok && truncatedStderr != "" {
+			resultMap["stderr"] = truncatedStderr
+		}
+	}
+	delete(resultMap, "truncated_stdout")
+	delete(resultMap, "truncated_stderr")
+
+	// Status follows the exit code: 0 = success, non-zero = error
+	exitCode, _ := resultMap["exit_code"].(float64)
+	if exitCode == 0 {
+		resultMap["status"] = "success"
+	} else {
+		resultMap["status"] = "error"
+		resultMap["error"] = fmt.Sprintf("Command exited with code %d", int(exitCode))
+	}
+	return resultMap, nil
+}
+
+// presentSQLResult prints execute_sql results mysql client style and tells the LLM they are displayed
+func presentSQLResult(resultData map[string]interface{}) (map[string]interface{}, *db.QueryResult) {
+	if rowsAffected, ok := resultData["rows_affected"].(float64); ok {
+		// Non-query statement: show affected row count (MySQL-style)
+		fmt.Println()
+		fmt.Printf("Query OK, %d row(s) affected\n", int64(rowsAffected))
+		return map[string]interface{}{
+			"status":        "success",
+			"rows_affected": int64(rowsAffected),
+			"displayed":     true,
+			"instruction":   "CRITICAL: The affected row count is already displayed to the user. Do NOT repeat this information. Return finish_reason='stop' with empty content (no text output) unless further steps are required.",
+		}, nil
+	}
+
+	columns, ok := resultData["columns"].([]interface{})
+	if !ok {
+		return resultData, nil
+	}
+	rows, ok := resultData["rows"].([]interface{})
+	if !ok {
+		return resultData, nil
+	}
+	cols := make([]string, len(columns))
+	for i, col := range columns {
+		cols[i] = fmt.Sprintf("%v", col)
+	}
+	rowsData := make([][]string, len(rows))
+	for i, rowInterface := range rows {
+		if rowArray, ok := rowInterface.([]interface{}); ok {
+			rowsData[i] = make([]string, len(rowArray))
+			for j, val := range rowArray {
+				rowsData[i][j] = fmt.Sprintf("%v", val)
+			}
+		}
+	}
+
+	// Directly render table output (mysql client style)
+	fmt.Println()
+	if len(rowsData) > 0 {
+		if tableOutput, err := RenderTableString(cols, rowsData); err == nil {
+			fmt.Println(tableOutput)
+		}
+	}
+	// Always show row count, even for empty results (MySQL-style)
+	cachedAt, cached := cachedTime(resultData)
+	if cached {
+		fmt.Printf("%d row(s) in set %s\n", len(rowsData), ui.HintText(fmt.Sprintf("(cached %s ago; /nocache to re-run)", FormatAge(time.Since(cachedAt)))))
+	} else {
+		fmt.Printf("%d row(s) in set\n", len(rowsData))
+	}
+
+	// Results are already displayed, so the LLM should not repeat them
+	instruction := "CRITICAL: Query executed successfully with 0 rows returned. The row count (0 row(s) in set) is already displayed to the user. Do NOT repeat this information. Return finish_reason='stop' with empty content (no text output)."
+	if len(rowsData) > 0 {
+		instruction = "CRITICAL: Results are already displayed to the user in table format. Do NOT repeat the results in your response. Return finish_reason='stop' with empty content (no text output). The user can see the results above."
+	}
+	presented := map[string]interface{}{
+		"status":      "success",
+		"columns":     cols,
+		"row_count":   len(rowsData),
+		"displayed":   true,
+		"instruction": instruction,
+	}
+	if resultID, ok := resultData["result_id"].(string); ok {
+		presented["result_id"] = resultID
+	}
+	if cached {
+		presented["cached"] = true
+		presented["cached_at"] = resultData["cached_at"]
+		presented["cache_note"] = cacheNote
+	}
+	return presented, &db.QueryResult{Columns: cols, Rows: rowsData, CachedAt: cachedAt}
+}
+
+// cacheNote tells the LLM that a result came from the result cache
+const cacheNote = "This result was served from the query result cache and may not reflect changes made since cached_at. Tell the user it is a cached result; if they need current data, they can re-run the question with /nocache."
+
+// cachedTime returns when a cached execute_sql result was stored
+func cachedTime(resultData map[string]interface{}) (time.Time, bool) {
+	if cached, _ := resultData["cached"].(bool); !cached {
+		return time.Time{}, false
+	}
+	value, _ := resultData["cached_at"].(string)
+	cachedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cachedAt, true
+}
+
+// FormatAge formats how long ago a result was cached, e.g. "45s" or "3m"
+func FormatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+}
+
+// presentChart displays a rendered chart and tells the LLM it is displayed
+func presentChart(resultData map[string]interface{}) (map[string]interface{}, *db.QueryResult) {
+	output, ok := resultData["output"].(string)
+	if !ok || output == "" {
+		return resultData, nil
+	}
+	chartType, _ := resultData["chart_type"].(string)
+	rowCount, _ := resultData["row_count"].(float64)
+	ui.DisplayChart(output, chartType, fmt.Sprintf("Chart (%d rows)", int(rowCount)))
+	return map[string]interface{}{
+		"status":      "success",
+		"displayed":   true,
+		"chart_type":  chartType,
+		"row_count":   int(rowCount),
+		"instruction": "Chart already displayed to user. Task completed. Return finish_reason='stop' with no content and no tool_calls to finish.",
+	}, nil
+}
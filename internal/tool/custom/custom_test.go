@@ -0,0 +1,187 @@
+package custom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/sandbox"
+	"github.com/aiq/aiq/internal/tool/builtin"
+)
+
+// TestMain lets the test binary act as the sandbox helper, as the aiq binary does
+func TestMain(m *testing.M) {
+	sandbox.Main()
+	os.Exit(m.Run())
+}
+
+func writeManifest(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadDir tests loading valid manifests and reporting invalid ones
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "grep_logs.yaml", `
+name: grep_logs
+description: Search the application log
+risk: low
+parameters:
+  type: object
+  properties:
+    pattern:
+      type: string
+  required: [pattern]
+command:
+  run: grep -n {{pattern}} /var/log/app.log
+`)
+	writeManifest(t, dir, "issue.json", `{
+  "name": "get_issue",
+  "description": "Fetch an issue",
+  "parameters": {"type": "object", "properties": {"id": {"type": "integer"}}, "required": ["id"]},
+  "http": {"url": "https://tracker.example.com/issues/{{id}}", "auth_profile": "tracker"}
+}`)
+	writeManifest(t, dir, "orders.yml", `
+name: customer_orders
+description: Orders of a customer
+parameters:
+  properties:
+    email: {type: string}
+sql:
+  query: SELECT o.* FROM orders o JOIN customers c ON c.id = o.customer_id WHERE c.email = {{email}}
+`)
+	writeManifest(t, dir, "notes.txt", "not a manifest")
+	writeManifest(t, dir, "reserved.yaml", "name: execute_sql\ndescription: x\ncommand: {run: ls}\n")
+	writeManifest(t, dir, "two_backends.yaml", "name: two\ndescription: x\ncommand: {run: ls}\nsql: {query: SELECT 1}\n")
+	writeManifest(t, dir, "unknown_placeholder.yaml", "name: unknown\ndescription: x\ncommand: {run: 'ls {{path}}'}\n")
+	writeManifest(t, dir, "multi.yaml", "name: multi\ndescription: x\nsql: {query: 'SELECT 1; DROP TABLE t'}\n")
+	writeManifest(t, dir, "zz_duplicate.yaml", "name: grep_logs\ndescription: x\ncommand: {run: ls}\n")
+
+	set := LoadDir(dir)
+	var names []string
+	for _, tool := range set.List() {
+		names = append(names, tool.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"customer_orders", "get_issue", "grep_logs"}) {
+		t.Errorf("Unexpected tools %v", names)
+	}
+	if len(set.Errors()) != 5 {
+		t.Errorf("Expected 5 load errors, got %v", set.Errors())
+	}
+
+	if tool := set.Lookup("get_issue"); tool.Risk != RiskHigh || tool.Backend() != BackendHTTP {
+		t.Errorf("Expected high risk HTTP tool by default, got %s %s", tool.Risk, tool.Backend())
+	}
+	if tool := set.Lookup("customer_orders"); !tool.ReadOnly() || tool.Parameters["type"] != "object" {
+		t.Errorf("Expected a read-only SQL tool with an object schema, got %+v", tool)
+	}
+	if set := LoadDir(filepath.Join(dir, "missing")); len(set.List()) != 0 || len(set.Errors()) != 0 {
+		t.Error("Expected a missing directory to load no tools and no errors")
+	}
+}
+
+// TestValidateArgs tests required, type and enum checks
+func TestValidateArgs(t *testing.T) {
+	tool := &Tool{Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"limit": map[string]interface{}{"type": "integer"},
+			"level": map[string]interface{}{"type": "string", "enum": []interface{}{"info", "error"}},
+		},
+		"required": []interface{}{"level"},
+	}}
+	tests := []struct {
+		name  string
+		args  map[string]interface{}
+		valid bool
+	}{
+		{"valid", map[string]interface{}{"level": "error", "limit": float64(10)}, true},
+		{"missing required", map[string]interface{}{"limit": float64(10)}, false},
+		{"wrong type", map[string]interface{}{"level": "error", "limit": "10"}, false},
+		{"fraction for integer", map[string]interface{}{"level": "error", "limit": 1.5}, false},
+		{"not in enum", map[string]interface{}{"level": "debug"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.ValidateArgs(tt.args)
+			if tt.valid != (err == nil) {
+				t.Errorf("Expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+// TestRender tests that values cannot escape their position in the templates
+func TestRender(t *testing.T) {
+	args := map[string]interface{}{"q": "it's; rm -rf /", "n": float64(5), "tag": "a&b=c/d"}
+
+	cmd := &Tool{Command: &CommandBackend{Run: "grep -c {{q}} file | head -n {{n}}"}}
+	if got, expected := cmd.RenderCommand(args), `grep -c 'it'\''s; rm -rf /' file | head -n '5'`; got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	req := &Tool{HTTP: &HTTPBackend{
+		URL:     "https://api.example.com/tags/{{tag}}?q={{q}}",
+		Body:    `{"query": {{q}}, "limit": {{n}}, "missing": {{absent}}}`,
+		Headers: map[string]string{"X-Tag": "{{q}}"},
+	}}
+	if got, expected := req.RenderURL(args), "https://api.example.com/tags/a&b=c%2Fd?q=it%27s%3B+rm+-rf+%2F"; got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got, expected := req.RenderBody(args), `{"query": "it's; rm -rf /", "limit": 5, "missing": null}`; got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got := req.RenderHeaders(map[string]interface{}{"q": "x\r\nX-Injected: 1"}); got["X-Tag"] != "xX-Injected: 1" {
+		t.Errorf("Expected line breaks to be removed, got %q", got["X-Tag"])
+	}
+
+	query := &Tool{SQL: &SQLBackend{Query: "SELECT * FROM t WHERE a = {{q}} AND b > {{n}}"}}
+	sql, bound := query.RenderSQL(args, func(n int) string { return "$" + string(rune('0'+n)) })
+	if sql != "SELECT * FROM t WHERE a = $1 AND b > $2" || !reflect.DeepEqual(bound, []interface{}{"it's; rm -rf /", float64(5)}) {
+		t.Errorf("Unexpected SQL %q with %v", sql, bound)
+	}
+}
+
+// TestExecute_Command runs a command tool through execute_command
+func TestExecute_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("command templates use a POSIX shell")
+	}
+	t.Setenv("HOME", t.TempDir())
+	tool := &Tool{
+		Name:        "echo_twice",
+		Description: "Echo a value twice",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{"value": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"value"},
+		},
+		Command: &CommandBackend{Run: "echo {{value}} {{value}}"},
+	}
+	if err := tool.validate(); err != nil {
+		t.Fatalf("validate() failed: %v", err)
+	}
+
+	result, err := Execute(context.Background(), tool, map[string]interface{}{"value": "$(id)"}, nil)
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	output := result.(builtin.CommandResult).Stdout
+	if strings.TrimSpace(output) != "$(id) $(id)" {
+		t.Errorf("Expected the value to be passed literally, got %q", output)
+	}
+
+	if _, err := Execute(context.Background(), tool, map[string]interface{}{}, nil); err == nil {
+		t.Error("Expected an error for a missing required parameter")
+	}
+	sqlTool := &Tool{Name: "q", SQL: &SQLBackend{Query: "SELECT 1"}}
+	if _, err := Execute(context.Background(), sqlTool, map[string]interface{}{}, nil); err == nil {
+		t.Error("Expected an error for a SQL tool in free mode")
+	}
+}
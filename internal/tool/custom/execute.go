@@ -0,0 +1,326 @@
+package custom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/sqlparse"
+	"github.com/aiq/aiq/internal/tool/builtin"
+)
+
+// Definition returns the LLM function definition for the tool
+func (t *Tool) Definition() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        t.Name,
+			"description": t.Description,
+			"parameters":  t.Parameters,
+		},
+	}
+}
+
+// ReadOnly reports whether the tool only reads data; only SQL backends can be classified
+func (t *Tool) ReadOnly() bool {
+	if t.SQL == nil {
+		return false
+	}
+	statements := sqlparse.ParseConservative(t.SQL.Query)
+	if len(statements) == 0 {
+		return false
+	}
+	for i := range statements {
+		if !statements[i].IsReadOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateArgs checks tool arguments against the declared parameters (required, type and enum)
+func (t *Tool) ValidateArgs(args map[string]interface{}) error {
+	for _, name := range t.required() {
+		if v, ok := args[name]; !ok || v == nil {
+			return fmt.Errorf("missing required parameter: %s", name)
+		}
+	}
+	props := t.properties()
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := args[name]
+		if !ok || value == nil {
+			continue
+		}
+		schema, _ := props[name].(map[string]interface{})
+		if typ, ok := schema["type"].(string); ok && !matchesType(value, typ) {
+			return fmt.Errorf("parameter %s must be of type %s", name, typ)
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+			found := false
+			for _, allowed := range enum {
+				if fmt.Sprint(allowed) == fmt.Sprint(value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("parameter %s must be one of %v", name, enum)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType checks a decoded JSON value against a JSON schema type
+func matchesType(value interface{}, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// text converts an argument to the text substituted into command and URL templates
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// render replaces {{name}} placeholders using encode for each argument value
+func render(tmpl string, args map[string]interface{}, encode func(name string, value interface{}, offset int) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(tmpl, -1) {
+		b.WriteString(tmpl[last:m[0]])
+		name := tmpl[m[2]:m[3]]
+		b.WriteString(encode(name, args[name], m[0]))
+		last = m[1]
+	}
+	b.WriteString(tmpl[last:])
+	return b.String()
+}
+
+// shellQuote quotes a value as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RenderCommand fills in the command template, quoting every value so it stays a single shell word
+func (t *Tool) RenderCommand(args map[string]interface{}) string {
+	return render(t.Command.Run, args, func(_ string, value interface{}, _ int) string {
+		return shellQuote(text(value))
+	})
+}
+
+// RenderURL fills in the URL template, path-escaping values before '?' and query-escaping them after it
+func (t *Tool) RenderURL(args map[string]interface{}) string {
+	query := strings.Index(t.HTTP.URL, "?")
+	return render(t.HTTP.URL, args, func(_ string, value interface{}, offset int) string {
+		if query >= 0 && offset > query {
+			return url.QueryEscape(text(value))
+		}
+		return url.PathEscape(text(value))
+	})
+}
+
+// RenderBody fills in the body template with JSON-encoded values (strings are quoted, missing values are null)
+func (t *Tool) RenderBody(args map[string]interface{}) string {
+	return render(t.HTTP.Body, args, func(_ string, value interface{}, _ int) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "null"
+		}
+		return string(data)
+	})
+}
+
+// RenderHeaders fills in header templates; line breaks are removed so values cannot add headers
+func (t *Tool) RenderHeaders(args map[string]interface{}) map[string]string {
+	if len(t.HTTP.Headers) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(t.HTTP.Headers))
+	strip := strings.NewReplacer("\r", "", "\n", "")
+	for name, tmpl := range t.HTTP.Headers {
+		headers[name] = render(tmpl, args, func(_ string, value interface{}, _ int) string {
+			return strip.Replace(text(value))
+		})
+	}
+	return headers
+}
+
+// RenderSQL replaces placeholders with bind placeholders and returns the arguments in order
+// placeholder returns the driver syntax for the n-th argument (see db.Connection.Placeholder)
+func (t *Tool) RenderSQL(args map[string]interface{}, placeholder func(n int) string) (string, []interface{}) {
+	var bound []interface{}
+	query := render(t.SQL.Query, args, func(_ string, value interface{}, _ int) string {
+		switch v := value.(type) {
+		case []interface{}, map[string]interface{}:
+			bound = append(bound, text(v))
+		default:
+			bound = append(bound, v)
+		}
+		return placeholder(len(bound))
+	})
+	return query, bound
+}
+
+// Summary describes what a call would run (the rendered command, request or statement), for confirmation prompts
+func (t *Tool) Summary(args map[string]interface{}) string {
+	switch t.Backend() {
+	case BackendCommand:
+		return "command: " + t.RenderCommand(args)
+	case BackendHTTP:
+		method := strings.ToUpper(t.HTTP.Method)
+		if method == "" {
+			method = "GET"
+		}
+		return method + " " + t.RenderURL(args)
+	default:
+		query, bound := t.RenderSQL(args, func(int) string { return "?" })
+		if len(bound) == 0 {
+			return "SQL: " + query
+		}
+		values := make([]string, len(bound))
+		for i, v := range bound {
+			values[i] = text(v)
+		}
+		return fmt.Sprintf("SQL: %s with [%s]", query, strings.Join(values, ", "))
+	}
+}
+
+// Execute runs a user-defined tool
+// Backends go through the same checks as the built-in tools: commands through execute_command (policy and
+// sandbox), requests through http_request (host lists, address blocking, auth profiles), and SQL statements
+// are checked against the execute_sql policy
+func Execute(ctx context.Context, t *Tool, args map[string]interface{}, dbConn *db.Connection) (interface{}, error) {
+	if err := t.ValidateArgs(args); err != nil {
+		return nil, err
+	}
+
+	switch t.Backend() {
+	case BackendCommand:
+		params := map[string]interface{}{"command": t.RenderCommand(args)}
+		if t.Command.Timeout > 0 {
+			params["timeout"] = t.Command.Timeout
+		}
+		if t.Command.WorkingDir != "" {
+			params["working_dir"] = t.Command.WorkingDir
+		}
+		return builtin.NewCommandTool().Execute(ctx, params)
+
+	case BackendHTTP:
+		method := strings.ToUpper(t.HTTP.Method)
+		if method == "" {
+			method = "GET"
+		}
+		params := map[string]interface{}{"method": method, "url": t.RenderURL(args)}
+		if decision := policy.Current().Evaluate("http_request", params); decision.Action == policy.Deny {
+			return nil, deniedError(decision)
+		}
+		if headers := t.RenderHeaders(args); headers != nil {
+			params["headers"] = headers
+		}
+		if t.HTTP.Body != "" {
+			params["body"] = t.RenderBody(args)
+		}
+		if t.HTTP.AuthProfile != "" {
+			params["auth_profile"] = t.HTTP.AuthProfile
+		}
+		if t.HTTP.Timeout > 0 {
+			params["timeout"] = t.HTTP.Timeout
+		}
+		return builtin.NewHTTPTool().Execute(ctx, params)
+
+	default:
+		if dbConn == nil {
+			return nil, fmt.Errorf("tool %s needs a database source and is not available in free mode", t.Name)
+		}
+		if decision := policy.Current().Evaluate("execute_sql", map[string]interface{}{"sql": t.SQL.Query}); decision.Action == policy.Deny {
+			return nil, deniedError(decision)
+		}
+		query, bound := t.RenderSQL(args, dbConn.Placeholder)
+		if isNonQuery(query, dbConn.DatabaseType()) {
+			rowsAffected, err := dbConn.ExecuteNonQueryArgs(ctx, query, bound...)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"status": "success", "rows_affected": rowsAffected}, nil
+		}
+		result, err := dbConn.ExecuteQueryArgs(ctx, query, bound...)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"status":    "success",
+			"columns":   result.Columns,
+			"rows":      result.Rows,
+			"row_count": len(result.Rows),
+		}, nil
+	}
+}
+
+// isNonQuery reports statements without a result set, which run through ExecContext (as in tool.ExecuteSQL)
+func isNonQuery(query, databaseType string) bool {
+	statements := sqlparse.Parse(query, sqlparse.DialectForDatabaseType(databaseType))
+	if len(statements) == 0 {
+		return false
+	}
+	switch statements[0].Class {
+	case sqlparse.ClassDML, sqlparse.ClassDDL, sqlparse.ClassDCL:
+		return !statements[0].ReturnsRows()
+	default:
+		return false
+	}
+}
+
+func deniedError(decision policy.Decision) error {
+	reason := decision.Reason
+	if reason == "" {
+		reason = "denied by policy"
+	}
+	return fmt.Errorf("blocked by policy rule '%s': %s", decision.Rule, reason)
+}
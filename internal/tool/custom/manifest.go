@@ -0,0 +1,339 @@
+// Package custom loads user-defined tools declared in ~/.aiq/tools
+// Each manifest (YAML or JSON) declares a name, description, JSON-schema parameters, a risk level and one
+// backend: a command template, an HTTP request template or a parameterized SQL statement
+package custom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/sqlparse"
+)
+
+// Risk levels a manifest can declare
+const (
+	RiskLow  = "low"
+	RiskHigh = "high"
+)
+
+// Backend kinds
+const (
+	BackendCommand = "command"
+	BackendHTTP    = "http"
+	BackendSQL     = "sql"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// placeholderPattern matches {{name}} placeholders in backend templates
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// reservedNames are built-in tools that user-defined tools cannot replace
+var reservedNames = map[string]bool{
+	"execute_sql":      true,
+	"render_table":     true,
+	"render_chart":     true,
+	"transform_result": true,
+	"join_results":     true,
+	"http_request":     true,
+	"execute_command":  true,
+	"file_operations":  true,
+	"transaction":      true,
+	"list_tables":      true,
+	"describe_table":   true,
+	"sample_rows":      true,
+	"column_values":    true,
+}
+
+// CommandBackend runs a shell command; placeholders are substituted as single-quoted shell words
+type CommandBackend struct {
+	Run        string `yaml:"run" json:"run"`
+	Timeout    int    `yaml:"timeout,omitempty" json:"timeout,omitempty"` // Idle timeout in seconds
+	WorkingDir string `yaml:"working_dir,omitempty" json:"working_dir,omitempty"`
+}
+
+// HTTPBackend sends an HTTP request; placeholders are URL-escaped in the URL and JSON-encoded in the body
+type HTTPBackend struct {
+	Method      string            `yaml:"method,omitempty" json:"method,omitempty"`
+	URL         string            `yaml:"url" json:"url"`
+	Headers     map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body        string            `yaml:"body,omitempty" json:"body,omitempty"`
+	AuthProfile string            `yaml:"auth_profile,omitempty" json:"auth_profile,omitempty"` // Name in http.auth_profiles
+	Timeout     int               `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// SQLBackend runs a SQL statement against the current source; placeholders become bound parameters
+type SQLBackend struct {
+	Query string `yaml:"query" json:"query"`
+}
+
+// Tool is a user-defined tool loaded from a manifest
+type Tool struct {
+	Name        string                 `yaml:"name" json:"name"`
+	Description string                 `yaml:"description" json:"description"`
+	Parameters  map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"` // JSON schema (type: object)
+	Risk        string                 `yaml:"risk,omitempty" json:"risk,omitempty"`             // low or high (default)
+	Command     *CommandBackend        `yaml:"command,omitempty" json:"command,omitempty"`
+	HTTP        *HTTPBackend           `yaml:"http,omitempty" json:"http,omitempty"`
+	SQL         *SQLBackend            `yaml:"sql,omitempty" json:"sql,omitempty"`
+
+	Path string `yaml:"-" json:"-"` // Manifest file the tool was loaded from
+}
+
+// Backend returns the kind of backend the tool uses
+func (t *Tool) Backend() string {
+	switch {
+	case t.Command != nil:
+		return BackendCommand
+	case t.HTTP != nil:
+		return BackendHTTP
+	default:
+		return BackendSQL
+	}
+}
+
+// properties returns the parameter schemas by name
+func (t *Tool) properties() map[string]interface{} {
+	props, _ := t.Parameters["properties"].(map[string]interface{})
+	return props
+}
+
+// required returns the names of required parameters
+func (t *Tool) required() []string {
+	var names []string
+	switch list := t.Parameters["required"].(type) {
+	case []interface{}:
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				names = append(names, s)
+			}
+		}
+	case []string:
+		names = list
+	}
+	return names
+}
+
+// templates returns the backend templates whose placeholders must name declared parameters
+func (t *Tool) templates() []string {
+	switch {
+	case t.Command != nil:
+		return []string{t.Command.Run}
+	case t.HTTP != nil:
+		templates := []string{t.HTTP.URL, t.HTTP.Body}
+		for _, v := range t.HTTP.Headers {
+			templates = append(templates, v)
+		}
+		return templates
+	case t.SQL != nil:
+		return []string{t.SQL.Query}
+	}
+	return nil
+}
+
+// validate checks a manifest and fills in defaults
+func (t *Tool) validate() error {
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid tool name %q: use up to 64 letters, digits, '_' or '-'", t.Name)
+	}
+	if reservedNames[t.Name] {
+		return fmt.Errorf("tool name %q is reserved for a built-in tool", t.Name)
+	}
+	if strings.TrimSpace(t.Description) == "" {
+		return fmt.Errorf("description is required")
+	}
+
+	switch strings.ToLower(t.Risk) {
+	case "", RiskHigh, "medium":
+		t.Risk = RiskHigh
+	case RiskLow:
+		t.Risk = RiskLow
+	default:
+		return fmt.Errorf("invalid risk %q: use low or high", t.Risk)
+	}
+
+	backends := 0
+	for _, set := range []bool{t.Command != nil, t.HTTP != nil, t.SQL != nil} {
+		if set {
+			backends++
+		}
+	}
+	if backends != 1 {
+		return fmt.Errorf("exactly one backend (command, http or sql) is required, found %d", backends)
+	}
+	switch {
+	case t.Command != nil && strings.TrimSpace(t.Command.Run) == "":
+		return fmt.Errorf("command.run is required")
+	case t.HTTP != nil && t.HTTP.URL == "":
+		return fmt.Errorf("http.url is required")
+	case t.SQL != nil && strings.TrimSpace(t.SQL.Query) == "":
+		return fmt.Errorf("sql.query is required")
+	case t.SQL != nil && (len(sqlparse.Parse(t.SQL.Query, sqlparse.DialectMySQL)) > 1 || len(sqlparse.Parse(t.SQL.Query, sqlparse.DialectPostgres)) > 1):
+		return fmt.Errorf("sql.query must be a single statement")
+	}
+
+	if t.Parameters == nil {
+		t.Parameters = map[string]interface{}{}
+	}
+	if typ, ok := t.Parameters["type"]; ok && typ != "object" {
+		return fmt.Errorf("parameters.type must be object")
+	}
+	t.Parameters["type"] = "object"
+	if _, ok := t.Parameters["properties"]; !ok {
+		t.Parameters["properties"] = map[string]interface{}{}
+	}
+	props := t.properties()
+	if props == nil {
+		return fmt.Errorf("parameters.properties must be an object")
+	}
+	for name, schema := range props {
+		if _, ok := schema.(map[string]interface{}); !ok {
+			return fmt.Errorf("parameter %q must be a JSON schema object", name)
+		}
+	}
+	for _, name := range t.required() {
+		if _, ok := props[name]; !ok {
+			return fmt.Errorf("required parameter %q is not declared in parameters.properties", name)
+		}
+	}
+	for _, tmpl := range t.templates() {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(tmpl, -1) {
+			if _, ok := props[m[1]]; !ok {
+				return fmt.Errorf("placeholder {{%s}} does not match a declared parameter", m[1])
+			}
+		}
+	}
+	return nil
+}
+
+// Set is the collection of tools loaded from a directory
+type Set struct {
+	tools  map[string]*Tool
+	names  []string
+	errors []error
+}
+
+// Lookup returns the tool with the given name, or nil
+func (s *Set) Lookup(name string) *Tool {
+	return s.tools[name]
+}
+
+// List returns the loaded tools sorted by name
+func (s *Set) List() []*Tool {
+	tools := make([]*Tool, 0, len(s.names))
+	for _, name := range s.names {
+		tools = append(tools, s.tools[name])
+	}
+	return tools
+}
+
+// Errors returns the manifests that could not be loaded
+func (s *Set) Errors() []error {
+	return s.errors
+}
+
+// LoadFile reads and validates a single manifest
+func LoadFile(path string) (*Tool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool manifest: %w", err)
+	}
+	// JSON manifests parse as YAML as well
+	var t Tool
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse tool manifest: %w", err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	t.Path = path
+	return &t, nil
+}
+
+// LoadDir loads every *.yaml, *.yml and *.json manifest in a directory
+// Invalid manifests and duplicate names are reported in Errors and skipped
+func LoadDir(dir string) *Set {
+	set := &Set{tools: make(map[string]*Tool)}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			set.errors = append(set.errors, fmt.Errorf("failed to read tools directory: %w", err))
+		}
+		return set
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		t, err := LoadFile(path)
+		if err != nil {
+			set.errors = append(set.errors, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		if existing, ok := set.tools[t.Name]; ok {
+			set.errors = append(set.errors, fmt.Errorf("%s: tool %q is already defined in %s", entry.Name(), t.Name, filepath.Base(existing.Path)))
+			continue
+		}
+		set.tools[t.Name] = t
+		set.names = append(set.names, t.Name)
+	}
+	sort.Strings(set.names)
+	return set
+}
+
+var (
+	loaded   *Set
+	loadedMu sync.Mutex
+)
+
+// Loaded returns the tools from ~/.aiq/tools, loading them on first use
+func Loaded() *Set {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	if loaded == nil {
+		loaded = load()
+	}
+	return loaded
+}
+
+// Reload re-reads ~/.aiq/tools and returns the new set
+func Reload() *Set {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loaded = load()
+	return loaded
+}
+
+// SetLoaded replaces the active tool set (used by tests)
+func SetLoaded(s *Set) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loaded = s
+}
+
+func load() *Set {
+	dir, err := config.GetToolsDir()
+	if err != nil {
+		return &Set{tools: make(map[string]*Tool), errors: []error{fmt.Errorf("failed to get tools directory: %w", err)}}
+	}
+	return LoadDir(dir)
+}
+
+// Lookup returns the loaded user-defined tool with the given name, or nil
+func Lookup(name string) *Tool {
+	return Loaded().Lookup(name)
+}
@@ -0,0 +1,105 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aiq/aiq/internal/mcp"
+	"github.com/aiq/aiq/internal/tool/custom"
+)
+
+// customProvider offers the user-defined tools loaded from ~/.aiq/tools
+type customProvider struct{}
+
+func (customProvider) Lookup(name string) *Tool {
+	if t := custom.Lookup(name); t != nil {
+		return customTool(t)
+	}
+	return nil
+}
+
+func (customProvider) Tools() []*Tool {
+	list := custom.Loaded().List()
+	tools := make([]*Tool, 0, len(list))
+	for _, t := range list {
+		tools = append(tools, customTool(t))
+	}
+	return tools
+}
+
+// customTool wraps a manifest; SQL tools are only offered in database mode
+func customTool(t *custom.Tool) *Tool {
+	modes := ModeAll
+	if t.SQL != nil {
+		modes = ModeDatabase
+	}
+	var readOnly func(args map[string]interface{}) bool
+	if t.ReadOnly() {
+		readOnly = alwaysReadOnly
+	}
+	return &Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+		Modes:       modes,
+		Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+			return custom.Execute(ctx, t, args, env.Conn)
+		},
+		Risk:     NewCustomToolRiskAssessor(t),
+		Confirm:  true,
+		ReadOnly: readOnly,
+		Describe: func(args map[string]interface{}) string {
+			return truncate(t.Summary(args), 200)
+		},
+		Statement: t.Summary,
+	}
+}
+
+// mcpProvider offers the tools of the connected MCP servers
+type mcpProvider struct{}
+
+func (mcpProvider) Lookup(name string) *Tool {
+	if b := mcp.Lookup(name); b != nil {
+		return mcpTool(b)
+	}
+	return nil
+}
+
+func (mcpProvider) Tools() []*Tool {
+	m := mcp.Current()
+	if m == nil {
+		return nil
+	}
+	var tools []*Tool
+	for _, s := range m.Servers() {
+		for _, b := range s.Tools {
+			tools = append(tools, mcpTool(b))
+		}
+	}
+	return tools
+}
+
+// mcpTool wraps a tool of an MCP server
+func mcpTool(b *mcp.Binding) *Tool {
+	fn := b.Definition()["function"].(map[string]interface{})
+	var readOnly func(args map[string]interface{}) bool
+	if b.Tool.ReadOnly() {
+		readOnly = alwaysReadOnly
+	}
+	return &Tool{
+		Name:        b.Name,
+		Description: fn["description"].(string),
+		Parameters:  fn["parameters"].(map[string]interface{}),
+		Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+			return mcp.Call(ctx, b.Name, args)
+		},
+		Risk:     NewMCPRiskAssessor(b),
+		Confirm:  true,
+		ReadOnly: readOnly,
+		Describe: func(args map[string]interface{}) string {
+			argsJSON, _ := json.Marshal(DisplayArgs(args))
+			return fmt.Sprintf("of MCP server %s with args: %s", b.Server.Name, truncate(string(argsJSON), 200))
+		},
+	}
+}
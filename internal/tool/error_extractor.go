@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -15,6 +16,44 @@ type ErrorInfo struct {
 	SuggestedActions  []string // Suggested actions to resolve the error
 }
 
+// ErrorResult builds the structured JSON error result returned to the LLM for a failed tool call
+func ErrorResult(err error) json.RawMessage {
+	// Extract structured error information
+	errorInfo := ExtractErrorInfo(err)
+	errorMessage := err.Error()
+
+	// Build structured error JSON with backward compatibility
+	errorJSON := map[string]interface{}{
+		"status": "error",
+		"error":  errorMessage, // Keep original error message for backward compatibility
+	}
+
+	// Add structured error fields if available
+	if errorInfo.ErrorCode != "" {
+		errorJSON["error_code"] = errorInfo.ErrorCode
+	}
+	if errorInfo.ErrorType != "" && errorInfo.ErrorType != "unknown" {
+		errorJSON["error_type"] = errorInfo.ErrorType
+	}
+	if len(errorInfo.AffectedResources) > 0 {
+		errorJSON["affected_resources"] = errorInfo.AffectedResources
+	}
+	if len(errorInfo.Dependencies) > 0 {
+		errorJSON["dependencies"] = errorInfo.Dependencies
+	}
+	if len(errorInfo.SuggestedActions) > 0 {
+		errorJSON["suggested_actions"] = errorInfo.SuggestedActions
+	}
+
+	jsonData, jsonErr := json.Marshal(errorJSON)
+	if jsonErr != nil {
+		// Fallback if JSON encoding fails
+		errorMsg := fmt.Sprintf(`{"status":"error","error":"%s"}`, strings.ReplaceAll(errorMessage, `"`, `\"`))
+		return json.RawMessage(errorMsg)
+	}
+	return json.RawMessage(jsonData)
+}
+
 // ExtractErrorInfo extracts structured error information from an error
 func ExtractErrorInfo(err error) ErrorInfo {
 	if err == nil {
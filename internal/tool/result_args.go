@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/transform"
+)
+
+// maxResultLimit caps the rows a render tool takes from a stored result
+const maxResultLimit = 10000
+
+// resultParameters returns the argument schema of tools that take a stored result (result_id)
+// or, for data that did not come from a query, literal columns and rows
+func resultParameters(columnsDescription, rowsDescription string) map[string]interface{} {
+	return map[string]interface{}{
+		"result_id": map[string]interface{}{
+			"type":        "string",
+			"description": "ID of a result returned by execute_sql (e.g. \"r3\"). Preferred over passing rows",
+		},
+		"columns": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": columnsDescription + ". With result_id: the columns to include, in order (default: all)",
+		},
+		"rows": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"description": rowsDescription + ". Only when there is no result_id",
+		},
+		"sort": map[string]interface{}{
+			"type":        "string",
+			"description": "Optional, with result_id: column to sort by, followed by ' desc' for descending order (e.g. \"total desc\")",
+		},
+		"limit": map[string]interface{}{
+			"type":        "integer",
+			"description": "Optional, with result_id: maximum number of rows, taken after sorting",
+		},
+	}
+}
+
+// withProperty adds a property to an argument schema
+func withProperty(properties map[string]interface{}, name string, schema map[string]interface{}) map[string]interface{} {
+	properties[name] = schema
+	return properties
+}
+
+// resultArgs returns the columns and rows a render tool works on: the stored result named by result_id
+// with columns, sort and limit applied, or the literal columns and rows arguments
+func resultArgs(env *Env, args map[string]interface{}) ([]string, [][]string, error) {
+	resultID, _ := args["result_id"].(string)
+	if resultID == "" {
+		return rowsArgs(args)
+	}
+	if env.Results == nil {
+		return nil, nil, fmt.Errorf("result_id is not available here; pass columns and rows instead")
+	}
+	stored, err := env.Results.Get(resultID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Column selection, by name (case-insensitive)
+	indexes := make([]int, len(stored.Columns))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if selected, ok := args["columns"].([]interface{}); ok && len(selected) > 0 {
+		indexes = indexes[:0]
+		for _, col := range selected {
+			i, err := columnIndex(stored.Columns, fmt.Sprintf("%v", col))
+			if err != nil {
+				return nil, nil, err
+			}
+			indexes = append(indexes, i)
+		}
+	}
+
+	rows := stored.Rows
+	if spec, ok := args["sort"].(string); ok && strings.TrimSpace(spec) != "" {
+		sorted, err := transform.Sort(&db.QueryResult{Columns: stored.Columns, Rows: rows}, spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = sorted.Rows
+	}
+	if limit := intArg(args, "limit", maxResultLimit, maxResultLimit); limit < len(rows) {
+		rows = rows[:limit]
+	}
+
+	columns := make([]string, len(indexes))
+	for i, index := range indexes {
+		columns[i] = stored.Columns[index]
+	}
+	projected := make([][]string, len(rows))
+	for r, row := range rows {
+		projected[r] = make([]string, len(indexes))
+		for i, index := range indexes {
+			if index < len(row) {
+				projected[r][i] = row[index]
+			}
+		}
+	}
+	return columns, projected, nil
+}
+
+func columnIndex(columns []string, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	for i, col := range columns {
+		if strings.EqualFold(col, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column '%s' is not in the result (columns: %s)", name, strings.Join(columns, ", "))
+}
@@ -0,0 +1,92 @@
+package tool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aiq/aiq/internal/session"
+)
+
+func TestResultArgs(t *testing.T) {
+	env := &Env{Results: session.NewResultStore()}
+	id := env.Results.Add("SELECT category, total FROM sales", []string{"category", "total"}, [][]string{
+		{"Books", "9.5"},
+		{"Electronics", "120"},
+		{"Appliances", "80"},
+	})
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		columns []string
+		rows    [][]string
+	}{
+		{
+			name:    "whole result",
+			args:    map[string]interface{}{"result_id": id},
+			columns: []string{"category", "total"},
+			rows:    [][]string{{"Books", "9.5"}, {"Electronics", "120"}, {"Appliances", "80"}},
+		},
+		{
+			name:    "numeric sort descending with limit",
+			args:    map[string]interface{}{"result_id": id, "sort": "total desc", "limit": float64(2)},
+			columns: []string{"category", "total"},
+			rows:    [][]string{{"Electronics", "120"}, {"Appliances", "80"}},
+		},
+		{
+			name:    "column selection",
+			args:    map[string]interface{}{"result_id": id, "columns": []interface{}{"TOTAL"}, "sort": "category"},
+			columns: []string{"total"},
+			rows:    [][]string{{"80"}, {"9.5"}, {"120"}},
+		},
+		{
+			name:    "literal rows",
+			args:    map[string]interface{}{"columns": []interface{}{"x"}, "rows": []interface{}{[]interface{}{"1"}}},
+			columns: []string{"x"},
+			rows:    [][]string{{"1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := resultArgs(env, tt.args)
+			if err != nil {
+				t.Fatalf("resultArgs failed: %v", err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) || !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("Got %v %v, expected %v %v", columns, rows, tt.columns, tt.rows)
+			}
+		})
+	}
+
+	// The stored result is not changed by sorting
+	stored, _ := env.Results.Get(id)
+	if stored.Rows[0][0] != "Books" {
+		t.Errorf("Expected the stored rows to keep their order, got %v", stored.Rows)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"result_id": "r404"},
+		{"result_id": id, "columns": []interface{}{"missing"}},
+		{"result_id": id, "sort": "missing desc"},
+	} {
+		if _, _, err := resultArgs(env, args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+	if _, _, err := resultArgs(&Env{}, map[string]interface{}{"result_id": id}); err == nil {
+		t.Error("Expected an error without a result store")
+	}
+}
+
+func TestExecuteRenderTableByResultID(t *testing.T) {
+	env := &Env{Results: session.NewResultStore()}
+	id := env.Results.Add("", []string{"name"}, [][]string{{"alice"}, {"bob"}})
+	result, err := executeRenderTable(context.Background(), env, map[string]interface{}{"result_id": id, "limit": float64(1)})
+	if err != nil {
+		t.Fatalf("executeRenderTable failed: %v", err)
+	}
+	if count := result.(map[string]interface{})["row_count"]; count != 1 {
+		t.Errorf("Expected 1 row, got %v", count)
+	}
+}
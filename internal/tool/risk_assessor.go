@@ -1,8 +1,13 @@
 package tool
 
 import (
-	"regexp"
+	"fmt"
 	"strings"
+
+	"github.com/aiq/aiq/internal/mcp"
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/sqlparse"
+	"github.com/aiq/aiq/internal/tool/custom"
 )
 
 // RiskLevel represents the risk level of a tool operation
@@ -15,11 +20,23 @@ const (
 	RiskHigh
 )
 
+// String returns the lowercase name of the risk level (used in logs and audit records)
+func (r RiskLevel) String() string {
+	switch r {
+	case RiskLow:
+		return "low"
+	case RiskHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
 // RiskAssessor interface for assessing risk of tool operations
 // Each tool type can implement its own risk assessor
 type RiskAssessor interface {
 	// AssessRisk evaluates the risk level of a tool operation
-	// Priority: (1) LLM-provided risk_level, (2) Code whitelist, (3) Conservative default (require confirmation)
+	// Priority: (1) User policy rules, (2) LLM-provided risk_level, (3) Built-in default rules, (4) Conservative default (require confirmation)
 	AssessRisk(toolName string, args map[string]interface{}) RiskLevel
 }
 
@@ -46,77 +63,109 @@ func assessRiskFromLLM(riskLevelStr string) RiskLevel {
 	}
 }
 
-// SQLRiskAssessor assesses risk for SQL operations
-type SQLRiskAssessor struct{}
+// assessPolicyRisk evaluates a tool call against the active policy (~/.aiq/config/policy.yaml)
+// Priority: (1) User-configured policy rules, (2) Tool-specific hazards (mustConfirm), (3) LLM-provided risk_level,
+// (4) Built-in default rules, (5) Conservative default (require confirmation)
+// Deny decisions are reported as RiskHigh here; the tool call loop refuses them before asking for confirmation
+// mustConfirm, when non-empty, explains why the call requires confirmation regardless of what the LLM says
+func assessPolicyRisk(label string, toolName string, args map[string]interface{}, mustConfirm string) RiskLevel {
+	decision := policy.Current().Evaluate(toolName, args)
+
+	// Priority 1: User-configured rules cannot be overridden by the LLM
+	if decision.UserDefined() {
+		LogRiskAssessment("%s: Policy decision %s", label, decision)
+		return riskFromDecision(decision)
+	}
 
-// NewSQLRiskAssessor creates a new SQL risk assessor
-func NewSQLRiskAssessor() *SQLRiskAssessor {
-	return &SQLRiskAssessor{}
-}
+	// Priority 2: Hazards detected by the tool-specific assessor cannot be downgraded by the LLM
+	if mustConfirm != "" {
+		LogRiskAssessment("%s: %s (requires confirmation)", label, mustConfirm)
+		return RiskHigh
+	}
 
-// AssessRisk evaluates SQL operation risk
-// Priority: (1) LLM-provided risk_level, (2) Code whitelist (SELECT, SHOW, DESCRIBE, EXPLAIN), (3) Require confirmation
-func (r *SQLRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
-	// Priority 1: Check LLM-provided risk_level
+	// Priority 3: Check LLM-provided risk_level
 	if riskLevelStr, ok := extractRiskLevel(args); ok {
-		LogRiskAssessment("SQL: LLM provided risk_level=%s", riskLevelStr)
+		LogRiskAssessment("%s: LLM provided risk_level=%s", label, riskLevelStr)
 		return assessRiskFromLLM(riskLevelStr)
 	}
 
-	// Priority 2: Check code whitelist
-	if sql, ok := args["sql"].(string); ok {
-		if isSQLWhitelisted(sql) {
-			LogRiskAssessment("SQL: Whitelisted operation (low risk), SQL starts with: %s", getSQLFirstKeyword(sql))
-			return RiskLow
-		}
-		LogRiskAssessment("SQL: Not whitelisted, SQL starts with: %s", getSQLFirstKeyword(sql))
+	// Priority 4: Built-in default rules
+	if decision.Matched() {
+		LogRiskAssessment("%s: Policy decision %s", label, decision)
+		return riskFromDecision(decision)
 	}
 
-	// Priority 3: Conservative default (require confirmation)
-	LogRiskAssessment("SQL: Default to high risk (requires confirmation)")
+	// Priority 5: Conservative default (require confirmation)
+	LogRiskAssessment("%s: No policy rule matched, default to high risk (requires confirmation)", label)
 	return RiskHigh
 }
 
-// getSQLFirstKeyword extracts the first SQL keyword for logging
-func getSQLFirstKeyword(sql string) string {
-	sql = strings.TrimSpace(sql)
-	parts := strings.Fields(sql)
-	if len(parts) > 0 {
-		return strings.ToUpper(parts[0])
+// riskFromDecision converts a policy decision to a risk level (only allow is low risk)
+func riskFromDecision(decision policy.Decision) RiskLevel {
+	if decision.Action == policy.Allow {
+		return RiskLow
 	}
-	return "unknown"
+	return RiskHigh
 }
 
-// isSQLWhitelisted checks if SQL statement matches whitelist patterns (SELECT, SHOW, DESCRIBE, EXPLAIN, CREATE TABLE)
-// CREATE TABLE is considered low-risk as it only creates new tables without modifying existing data
-func isSQLWhitelisted(sql string) bool {
-	// Normalize SQL: trim whitespace and convert to uppercase for comparison
-	normalized := strings.TrimSpace(strings.ToUpper(sql))
+// SQLRiskAssessor assesses risk for SQL operations
+type SQLRiskAssessor struct{}
 
-	// Check for CREATE TABLE first (two-word pattern)
-	if strings.HasPrefix(normalized, "CREATE TABLE") {
-		return true
-	}
+// NewSQLRiskAssessor creates a new SQL risk assessor
+func NewSQLRiskAssessor() *SQLRiskAssessor {
+	return &SQLRiskAssessor{}
+}
 
-	// Check for single-word patterns
-	whitelistPatterns := []string{
-		"SELECT",
-		"SHOW",
-		"DESCRIBE",
-		"DESC",
-		"EXPLAIN",
+// AssessRisk evaluates SQL operation risk against the policy
+// Every statement in the input is parsed and classified; built-in defaults allow pure reads (SELECT, SHOW,
+// DESCRIBE, EXPLAIN without ANALYZE) and CREATE TABLE, everything else requires confirmation
+// UPDATE/DELETE without a WHERE clause, several statements in one call, writes hidden in CTEs and
+// SELECT ... INTO OUTFILE or locking clauses always require confirmation unless a user policy rule allows them
+func (r *SQLRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
+	mustConfirm := ""
+	if sql, ok := args["sql"].(string); ok {
+		LogRiskAssessment("SQL: Assessing %s", describeStatements(sql))
+		mustConfirm = sqlHazard(sql)
 	}
+	return assessPolicyRisk("SQL", toolName, args, mustConfirm)
+}
 
-	for _, pattern := range whitelistPatterns {
-		// Use regex to match at start of statement (after optional whitespace/comments)
-		// Match pattern followed by space, semicolon, or end of string
-		regex := regexp.MustCompile(`(?i)^\s*` + regexp.QuoteMeta(pattern) + `\s`)
-		if regex.MatchString(sql) {
-			return true
+// sqlHazard returns why SQL must be confirmed whatever the LLM's risk_level, or "" when nothing forces it
+func sqlHazard(sql string) string {
+	statements := sqlparse.ParseConservative(sql)
+	for _, st := range statements {
+		if st.UnfilteredWrite() {
+			return "UPDATE/DELETE without WHERE clause affects every row"
+		}
+	}
+	if n := sqlparse.CountStatements(sql); n > 1 {
+		return fmt.Sprintf("%d statements in one call", n)
+	}
+	for _, st := range statements {
+		switch {
+		case st.CTEWrite:
+			return "a CTE modifies data"
+		case st.IntoFile:
+			return "SELECT ... INTO OUTFILE writes a file on the database server"
+		case st.Locking:
+			return "locking read holds row locks"
 		}
 	}
+	return ""
+}
 
-	return false
+// describeStatements summarizes the statements in a SQL string for logging (e.g. "2 statement(s): SELECT [read], DROP [ddl]")
+func describeStatements(sql string) string {
+	statements := sqlparse.Parse(sql, sqlparse.DialectMySQL)
+	parts := make([]string, 0, len(statements))
+	for _, st := range statements {
+		verb := st.Verb
+		if verb == "" {
+			verb = "unknown"
+		}
+		parts = append(parts, fmt.Sprintf("%s [%s]", verb, st.Class))
+	}
+	return fmt.Sprintf("%d statement(s): %s", len(statements), strings.Join(parts, ", "))
 }
 
 // CommandRiskAssessor assesses risk for command operations
@@ -127,101 +176,10 @@ func NewCommandRiskAssessor() *CommandRiskAssessor {
 	return &CommandRiskAssessor{}
 }
 
-// AssessRisk evaluates command operation risk
-// Priority: (1) LLM-provided risk_level, (2) Code whitelist (ls, cat, pwd, echo, grep, etc.), (3) Require confirmation
+// AssessRisk evaluates command operation risk against the policy
+// Built-in defaults allow read-only commands (ls, cat, pwd, echo, grep, etc.) and block dangerous ones (rm, sudo, dd, etc.)
 func (r *CommandRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
-	// Priority 1: Check LLM-provided risk_level
-	if riskLevelStr, ok := extractRiskLevel(args); ok {
-		LogRiskAssessment("Command: LLM provided risk_level=%s", riskLevelStr)
-		return assessRiskFromLLM(riskLevelStr)
-	}
-
-	// Priority 2: Check code whitelist
-	if command, ok := args["command"].(string); ok {
-		if isCommandWhitelisted(command) {
-			LogRiskAssessment("Command: Whitelisted operation (low risk), command: %s", getCommandName(command))
-			return RiskLow
-		}
-		LogRiskAssessment("Command: Not whitelisted, command: %s", getCommandName(command))
-	}
-
-	// Priority 3: Conservative default (require confirmation)
-	LogRiskAssessment("Command: Default to high risk (requires confirmation)")
-	return RiskHigh
-}
-
-// getCommandName extracts command name for logging
-func getCommandName(command string) string {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return "unknown"
-	}
-	// Skip environment variable assignments
-	firstCmd := parts[0]
-	if strings.Contains(firstCmd, "=") {
-		if len(parts) > 1 {
-			firstCmd = parts[1]
-		} else {
-			return "unknown"
-		}
-	}
-	// Remove path prefix
-	if idx := strings.LastIndex(firstCmd, "/"); idx >= 0 {
-		return firstCmd[idx+1:]
-	}
-	return firstCmd
-}
-
-// isCommandWhitelisted checks if command is in whitelist (read-only safe commands)
-func isCommandWhitelisted(command string) bool {
-	// Extract first command (handle env vars like VAR=value command)
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return false
-	}
-
-	// Skip environment variable assignments (VAR=value)
-	firstCmd := parts[0]
-	if strings.Contains(firstCmd, "=") {
-		if len(parts) > 1 {
-			firstCmd = parts[1]
-		} else {
-			return false
-		}
-	}
-
-	// Remove path prefix (e.g., /usr/bin/ls -> ls)
-	cmdName := firstCmd
-	if idx := strings.LastIndex(firstCmd, "/"); idx >= 0 {
-		cmdName = firstCmd[idx+1:]
-	}
-
-	// Whitelist of safe read-only commands
-	whitelist := map[string]bool{
-		"ls":       true,
-		"cat":      true,
-		"pwd":      true,
-		"echo":     true,
-		"grep":     true,
-		"head":     true,
-		"tail":     true,
-		"wc":       true,
-		"find":     true, // read-only find (without -delete)
-		"which":    true,
-		"type":     true,
-		"whereis":  true,
-		"locate":   true,
-		"stat":     true,
-		"file":     true,
-		"date":     true,
-		"uptime":   true,
-		"whoami":   true,
-		"id":       true,
-		"env":      true,
-		"printenv": true,
-	}
-
-	return whitelist[cmdName]
+	return assessPolicyRisk("Command", toolName, args, "")
 }
 
 // FileOperationRiskAssessor assesses risk for file operations
@@ -232,37 +190,10 @@ func NewFileOperationRiskAssessor() *FileOperationRiskAssessor {
 	return &FileOperationRiskAssessor{}
 }
 
-// AssessRisk evaluates file operation risk
-// Priority: (1) LLM-provided risk_level, (2) Code whitelist (read, list, exists), (3) Require confirmation
+// AssessRisk evaluates file operation risk against the policy
+// Built-in defaults allow read, list and exists
 func (r *FileOperationRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
-	// Priority 1: Check LLM-provided risk_level
-	if riskLevelStr, ok := extractRiskLevel(args); ok {
-		LogRiskAssessment("FileOperation: LLM provided risk_level=%s", riskLevelStr)
-		return assessRiskFromLLM(riskLevelStr)
-	}
-
-	// Priority 2: Check code whitelist
-	if operation, ok := args["operation"].(string); ok {
-		if isFileOperationWhitelisted(operation) {
-			LogRiskAssessment("FileOperation: Whitelisted operation (low risk), operation: %s", operation)
-			return RiskLow
-		}
-		LogRiskAssessment("FileOperation: Not whitelisted, operation: %s", operation)
-	}
-
-	// Priority 3: Conservative default (require confirmation)
-	LogRiskAssessment("FileOperation: Default to high risk (requires confirmation)")
-	return RiskHigh
-}
-
-// isFileOperationWhitelisted checks if file operation is in whitelist (read-only operations)
-func isFileOperationWhitelisted(operation string) bool {
-	whitelist := map[string]bool{
-		"read":   true,
-		"list":   true,
-		"exists": true,
-	}
-	return whitelist[strings.ToLower(operation)]
+	return assessPolicyRisk("FileOperation", toolName, args, "")
 }
 
 // HTTPRequestRiskAssessor assesses risk for HTTP request operations
@@ -273,69 +204,115 @@ func NewHTTPRequestRiskAssessor() *HTTPRequestRiskAssessor {
 	return &HTTPRequestRiskAssessor{}
 }
 
-// AssessRisk evaluates HTTP request risk
-// Priority: (1) LLM-provided risk_level, (2) Code whitelist (GET, HEAD, OPTIONS), (3) Require confirmation
+// AssessRisk evaluates HTTP request risk against the policy
+// Built-in defaults allow GET, HEAD and OPTIONS
 func (r *HTTPRequestRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
-	// Priority 1: Check LLM-provided risk_level
-	if riskLevelStr, ok := extractRiskLevel(args); ok {
-		LogRiskAssessment("HTTPRequest: LLM provided risk_level=%s", riskLevelStr)
-		return assessRiskFromLLM(riskLevelStr)
+	// The tool defaults to GET when no method is given
+	if _, ok := args["method"]; !ok {
+		withMethod := make(map[string]interface{}, len(args)+1)
+		for k, v := range args {
+			withMethod[k] = v
+		}
+		withMethod["method"] = "GET"
+		args = withMethod
 	}
+	return assessPolicyRisk("HTTPRequest", toolName, args, "")
+}
+
+// CustomToolRiskAssessor assesses risk for user-defined tools from ~/.aiq/tools
+type CustomToolRiskAssessor struct {
+	tool *custom.Tool
+}
 
-	// Priority 2: Check code whitelist
-	method := "GET" // Default method
-	if m, ok := args["method"].(string); ok {
-		method = strings.ToUpper(m)
+// NewCustomToolRiskAssessor creates a risk assessor for a user-defined tool
+func NewCustomToolRiskAssessor(t *custom.Tool) *CustomToolRiskAssessor {
+	return &CustomToolRiskAssessor{tool: t}
+}
+
+// AssessRisk evaluates a user-defined tool call against the policy and the risk declared in its manifest
+// User policy rules for the tool name win; tools declared "risk: high" and SQL tools whose statement writes
+// always require confirmation; tools declared "risk: low" run without it
+func (r *CustomToolRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
+	label := "Tool " + toolName
+	switch {
+	case r.tool.Risk != custom.RiskLow:
+		return assessPolicyRisk(label, toolName, args, fmt.Sprintf("declared risk: %s in %s", r.tool.Risk, r.tool.Path))
+	case r.tool.SQL != nil && !r.tool.ReadOnly():
+		return assessPolicyRisk(label, toolName, args, "SQL statement modifies data")
 	}
-	if isHTTPMethodWhitelisted(method) {
-		LogRiskAssessment("HTTPRequest: Whitelisted method (low risk), method: %s", method)
-		return RiskLow
+	return assessDeclaredLowRisk(label, toolName, args, "declared risk: low in "+r.tool.Path)
+}
+
+// MCPRiskAssessor assesses risk for tools of MCP servers
+type MCPRiskAssessor struct {
+	binding *mcp.Binding
+}
+
+// NewMCPRiskAssessor creates a risk assessor for an MCP tool
+func NewMCPRiskAssessor(b *mcp.Binding) *MCPRiskAssessor {
+	return &MCPRiskAssessor{binding: b}
+}
+
+// AssessRisk evaluates an MCP tool call against the policy and the server's risk settings
+// User policy rules win; tools of servers configured "risk: low", and tools annotated read-only by servers
+// with trust_read_only_hint, run without confirmation; everything else requires it
+func (r *MCPRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
+	label := "MCP " + toolName
+	if r.binding.LowRisk() {
+		return assessDeclaredLowRisk(label, toolName, args, fmt.Sprintf("server %s allows the tool without confirmation", r.binding.Server.Name))
 	}
-	LogRiskAssessment("HTTPRequest: Not whitelisted, method: %s", method)
+	return assessPolicyRisk(label, toolName, args, fmt.Sprintf("tool of MCP server %s", r.binding.Server.Name))
+}
 
-	// Priority 3: Conservative default (require confirmation)
-	LogRiskAssessment("HTTPRequest: Default to high risk (requires confirmation)")
-	return RiskHigh
+// ReadOnlyToolRiskAssessor assesses built-in tools that only read (schema exploration)
+type ReadOnlyToolRiskAssessor struct{}
+
+// NewReadOnlyToolRiskAssessor creates a risk assessor for read-only built-in tools
+func NewReadOnlyToolRiskAssessor() *ReadOnlyToolRiskAssessor {
+	return &ReadOnlyToolRiskAssessor{}
+}
+
+// AssessRisk lets read-only tools run without confirmation unless a user policy rule says otherwise
+func (r *ReadOnlyToolRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
+	return assessDeclaredLowRisk("Tool "+toolName, toolName, args, "read-only tool")
 }
 
-// isHTTPMethodWhitelisted checks if HTTP method is in whitelist (safe read-only methods)
-func isHTTPMethodWhitelisted(method string) bool {
-	whitelist := map[string]bool{
-		"GET":     true,
-		"HEAD":    true,
-		"OPTIONS": true,
+// assessDeclaredLowRisk returns RiskLow for a tool configured as low risk unless a user policy rule says otherwise
+func assessDeclaredLowRisk(label string, toolName string, args map[string]interface{}, reason string) RiskLevel {
+	decision := policy.Current().Evaluate(toolName, args)
+	if decision.UserDefined() {
+		LogRiskAssessment("%s: Policy decision %s", label, decision)
+		return riskFromDecision(decision)
 	}
-	return whitelist[strings.ToUpper(method)]
+	LogRiskAssessment("%s: %s", label, reason)
+	return RiskLow
 }
 
-// GetRiskAssessor returns the appropriate risk assessor for a tool
+// GetRiskAssessor returns the risk assessor registered for a tool
+// All assessors evaluate the same policy; they differ only in logging and argument defaults
 func GetRiskAssessor(toolName string) RiskAssessor {
-	switch toolName {
-	case "execute_sql":
-		return NewSQLRiskAssessor()
-	case "execute_command":
-		return NewCommandRiskAssessor()
-	case "file_operations":
-		return NewFileOperationRiskAssessor()
-	case "http_request":
-		return NewHTTPRequestRiskAssessor()
-	default:
-		// Default: conservative assessor that always requires confirmation
-		return &DefaultRiskAssessor{}
+	if t := Lookup(toolName); t != nil && t.Risk != nil {
+		return t.Risk
+	}
+	// Default: policy rules, then LLM risk_level, then require confirmation
+	return &DefaultRiskAssessor{}
+}
+
+// CheckPolicy returns the policy decision for a tool call when it is denied
+// The tool call loop uses it to refuse denied calls before risk assessment and confirmation
+func CheckPolicy(toolName string, args map[string]interface{}) (policy.Decision, bool) {
+	decision := policy.Current().Evaluate(toolName, args)
+	if decision.Action == policy.Deny {
+		LogRiskAssessment("Policy: Denied tool %s, %s", toolName, decision)
+		return decision, true
 	}
+	return decision, false
 }
 
 // DefaultRiskAssessor is a conservative risk assessor that requires confirmation for unknown tools
 type DefaultRiskAssessor struct{}
 
-// AssessRisk always requires confirmation for unknown tools (conservative default)
+// AssessRisk requires confirmation for unknown tools unless a policy rule or the LLM says otherwise
 func (r *DefaultRiskAssessor) AssessRisk(toolName string, args map[string]interface{}) RiskLevel {
-	// Check if LLM provided risk_level
-	if riskLevelStr, ok := extractRiskLevel(args); ok {
-		LogRiskAssessment("UnknownTool: LLM provided risk_level=%s, tool: %s", riskLevelStr, toolName)
-		return assessRiskFromLLM(riskLevelStr)
-	}
-	// Unknown tool without LLM risk_level -> require confirmation
-	LogRiskAssessment("UnknownTool: Default to high risk (requires confirmation), tool: %s", toolName)
-	return RiskHigh
+	return assessPolicyRisk("Tool "+toolName, toolName, args, "")
 }
@@ -1,9 +1,42 @@
 package tool
 
 import (
+	"os"
 	"testing"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/mcp"
+	"github.com/aiq/aiq/internal/policy"
+	"github.com/aiq/aiq/internal/tool/custom"
 )
 
+// TestMain runs the package tests against the built-in rules only, never the policy.yaml of the machine running them
+func TestMain(m *testing.M) {
+	policy.SetCurrent(defaultTestPolicy())
+	os.Exit(m.Run())
+}
+
+// defaultTestPolicy returns an in-memory policy holding the built-in rules
+func defaultTestPolicy() *policy.Policy {
+	includeDefaults := true
+	p, err := policy.New(&policy.File{RuleSet: policy.RuleSet{IncludeDefaults: &includeDefaults}}, "")
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// usePolicy makes rules, followed by the built-in rules, the active policy for the rest of the test
+func usePolicy(t *testing.T, rules ...policy.Rule) {
+	t.Helper()
+	p, err := policy.New(&policy.File{RuleSet: policy.RuleSet{Rules: rules}}, "")
+	if err != nil {
+		t.Fatalf("policy.New() failed: %v", err)
+	}
+	policy.SetCurrent(p)
+	t.Cleanup(func() { policy.SetCurrent(defaultTestPolicy()) })
+}
+
 // TestRiskAssessor_LLMRiskLevel tests LLM-provided risk level handling
 func TestRiskAssessor_LLMRiskLevel(t *testing.T) {
 	t.Run("low risk level executes automatically", func(t *testing.T) {
@@ -414,3 +447,137 @@ func TestRiskAssessor_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+// TestRiskAssessor_UserPolicy tests that user policy rules take priority over LLM-provided risk_level
+func TestRiskAssessor_UserPolicy(t *testing.T) {
+	usePolicy(t,
+		policy.Rule{Name: "confirm-orders", Action: policy.Confirm, Tables: []string{"orders"}},
+		policy.Rule{Name: "allow-deploy", Action: policy.Allow, Commands: []string{"./deploy.sh *"}},
+		policy.Rule{Name: "no-truncate", Action: policy.Deny, Statements: []string{"TRUNCATE"}},
+	)
+
+	t.Run("user confirm rule overrides LLM low risk", func(t *testing.T) {
+		args := map[string]interface{}{"sql": "SELECT * FROM orders", "risk_level": "low"}
+		if risk := NewSQLRiskAssessor().AssessRisk("execute_sql", args); risk != RiskHigh {
+			t.Errorf("Expected RiskHigh, got %v", risk)
+		}
+	})
+
+	t.Run("user allow rule auto-approves", func(t *testing.T) {
+		args := map[string]interface{}{"command": "./deploy.sh staging"}
+		if risk := NewCommandRiskAssessor().AssessRisk("execute_command", args); risk != RiskLow {
+			t.Errorf("Expected RiskLow, got %v", risk)
+		}
+	})
+
+	t.Run("deny rule is reported by CheckPolicy", func(t *testing.T) {
+		args := map[string]interface{}{"sql": "TRUNCATE TABLE users", "risk_level": "low"}
+		decision, denied := CheckPolicy("execute_sql", args)
+		if !denied {
+			t.Fatalf("Expected call to be denied")
+		}
+		if decision.Rule != "no-truncate" {
+			t.Errorf("Expected rule 'no-truncate', got %q", decision.Rule)
+		}
+	})
+
+	t.Run("defaults still apply after user rules", func(t *testing.T) {
+		if _, denied := CheckPolicy("execute_command", map[string]interface{}{"command": "sudo reboot"}); !denied {
+			t.Error("Expected default deny rule to block sudo")
+		}
+	})
+}
+
+// TestSQLRiskAssessor_ParsedStatements tests classification of statements the first keyword does not reveal
+func TestSQLRiskAssessor_ParsedStatements(t *testing.T) {
+	usePolicy(t)
+
+	sqlAssessor := NewSQLRiskAssessor()
+	tests := []struct {
+		name      string
+		sql       string
+		riskLevel string
+		expected  RiskLevel
+	}{
+		{"comment before SELECT", "/* dashboard */ SELECT * FROM users", "", RiskLow},
+		{"write hidden in CTE", "WITH d AS (DELETE FROM logs WHERE id < 10 RETURNING *) SELECT * FROM d", "", RiskHigh},
+		{"CTE followed by DELETE", "WITH x AS (SELECT 1) DELETE FROM t WHERE id IN (SELECT * FROM x)", "", RiskHigh},
+		{"SELECT INTO OUTFILE", "SELECT * FROM users INTO OUTFILE '/tmp/users.csv'", "", RiskHigh},
+		{"SELECT FOR UPDATE", "SELECT * FROM accounts WHERE id = 1 FOR UPDATE", "", RiskHigh},
+		{"second statement DROP", "SELECT 1; DROP TABLE t", "", RiskHigh},
+		{"EXPLAIN ANALYZE runs the statement", "EXPLAIN ANALYZE UPDATE t SET a = 1 WHERE id = 1", "", RiskHigh},
+		{"DELETE without WHERE ignores LLM low risk", "DELETE FROM users", "low", RiskHigh},
+		{"UPDATE without WHERE ignores LLM low risk", "UPDATE users SET active = 0", "low", RiskHigh},
+		{"DELETE with WHERE follows LLM low risk", "DELETE FROM users WHERE id = 1", "low", RiskLow},
+		{"multiple statements ignore LLM low risk", "SELECT 1; DROP TABLE t", "low", RiskHigh},
+		{"multiple reads ignore LLM low risk", "SELECT 1; SELECT 2", "low", RiskHigh},
+		{"data-modifying CTE ignores LLM low risk", "WITH d AS (DELETE FROM logs WHERE id < 10 RETURNING *) SELECT * FROM d", "low", RiskHigh},
+		{"SELECT INTO OUTFILE ignores LLM low risk", "SELECT * FROM users INTO OUTFILE '/tmp/users.csv'", "low", RiskHigh},
+		{"SELECT FOR UPDATE ignores LLM low risk", "SELECT * FROM users WHERE id = 1 FOR UPDATE", "low", RiskHigh},
+		{"LOCK IN SHARE MODE ignores LLM low risk", "SELECT * FROM users WHERE id = 1 LOCK IN SHARE MODE", "low", RiskHigh},
+		{"single read follows LLM low risk", "SELECT * FROM users WHERE id = 1", "low", RiskLow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{"sql": tt.sql}
+			if tt.riskLevel != "" {
+				args["risk_level"] = tt.riskLevel
+			}
+			if risk := sqlAssessor.AssessRisk("execute_sql", args); risk != tt.expected {
+				t.Errorf("Expected %v for SQL '%s', got %v", tt.expected, tt.sql, risk)
+			}
+		})
+	}
+}
+
+// TestCustomToolRiskAssessor tests the risk declared by user-defined tool manifests
+func TestCustomToolRiskAssessor(t *testing.T) {
+	usePolicy(t)
+
+	low := &custom.Tool{Name: "grep_logs", Risk: custom.RiskLow, Command: &custom.CommandBackend{Run: "grep x log"}}
+	high := &custom.Tool{Name: "deploy", Risk: custom.RiskHigh, Command: &custom.CommandBackend{Run: "./deploy.sh"}}
+	write := &custom.Tool{Name: "close_order", Risk: custom.RiskLow, SQL: &custom.SQLBackend{Query: "UPDATE orders SET status = 'closed' WHERE id = {{id}}"}}
+
+	if risk := NewCustomToolRiskAssessor(low).AssessRisk("grep_logs", map[string]interface{}{}); risk != RiskLow {
+		t.Errorf("Expected RiskLow for a tool declared low, got %v", risk)
+	}
+	if risk := NewCustomToolRiskAssessor(high).AssessRisk("deploy", map[string]interface{}{"risk_level": "low"}); risk != RiskHigh {
+		t.Errorf("Expected RiskHigh for a tool declared high, got %v", risk)
+	}
+	if risk := NewCustomToolRiskAssessor(write).AssessRisk("close_order", map[string]interface{}{"id": float64(1)}); risk != RiskHigh {
+		t.Errorf("Expected RiskHigh for a SQL tool that writes, got %v", risk)
+	}
+
+	usePolicy(t, policy.Rule{Name: "confirm-grep", Action: policy.Confirm, Tools: []string{"grep_logs"}})
+	if risk := NewCustomToolRiskAssessor(low).AssessRisk("grep_logs", map[string]interface{}{}); risk != RiskHigh {
+		t.Errorf("Expected a user policy rule to override the declared risk, got %v", risk)
+	}
+}
+
+// TestMCPRiskAssessor tests the risk settings of MCP servers
+func TestMCPRiskAssessor(t *testing.T) {
+	usePolicy(t)
+
+	readOnly := true
+	annotated := mcp.Tool{Name: "search", Annotations: &mcp.ToolAnnotations{ReadOnlyHint: &readOnly}}
+	tests := []struct {
+		name     string
+		config   config.MCPServerConfig
+		tool     mcp.Tool
+		expected RiskLevel
+	}{
+		{"default requires confirmation", config.MCPServerConfig{}, annotated, RiskHigh},
+		{"low risk server", config.MCPServerConfig{Risk: "low"}, mcp.Tool{Name: "create"}, RiskLow},
+		{"trusted read-only hint", config.MCPServerConfig{TrustReadOnlyHint: true}, annotated, RiskLow},
+		{"trusted hint without annotation", config.MCPServerConfig{TrustReadOnlyHint: true}, mcp.Tool{Name: "create"}, RiskHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &mcp.Binding{Name: "tickets__" + tt.tool.Name, Server: &mcp.Server{Name: "tickets", Config: tt.config}, Tool: tt.tool}
+			if risk := NewMCPRiskAssessor(b).AssessRisk(b.Name, map[string]interface{}{"risk_level": "low"}); risk != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, risk)
+			}
+		})
+	}
+}
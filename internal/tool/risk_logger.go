@@ -22,12 +22,11 @@ func initRiskLogger() error {
 	}
 
 	// Get log directory (~/.aiq/logs)
-	baseDir, err := config.GetBaseConfigDir()
+	logDir, err := config.GetLogsDir()
 	if err != nil {
-		return fmt.Errorf("failed to get base config dir: %w", err)
+		return fmt.Errorf("failed to get log dir: %w", err)
 	}
 
-	logDir := filepath.Join(baseDir, "logs")
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
 	}
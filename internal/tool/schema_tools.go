@@ -0,0 +1,403 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// Limits of the schema exploration tools
+const (
+	maxListedTables     = 200
+	defaultSampleRows   = 5
+	maxSampleRows       = 50
+	defaultColumnValues = 20
+	maxColumnValues     = 100
+)
+
+// registerSchemaTools registers the tools the LLM uses to look up the schema and data on demand
+func registerSchemaTools(r *Registry) {
+	r.Register(&Tool{
+		Name:        "list_tables",
+		Description: "List the tables and views of the current database with their estimated row count and comment. Use a filter on large databases.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional: case-insensitive part of the table name, or a pattern with * and ? wildcards (e.g. 'order', 'fact_*')",
+				},
+			},
+		},
+		Modes:       ModeDatabase,
+		Execute:     executeListTables,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		MultiSource: true,
+		ReadOnly:    alwaysReadOnly,
+		Describe: func(args map[string]interface{}) string {
+			if filter, _ := args["filter"].(string); filter != "" {
+				return "matching " + truncate(filter, 60)
+			}
+			return ""
+		},
+	})
+
+	r.Register(&Tool{
+		Name:        "describe_table",
+		Description: "Describe a table: columns with full type, nullability, default and comment, primary key, indexes and foreign keys. Call it before writing SQL against a table whose columns you do not know.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"table": tableParameter,
+			},
+			"required": []string{"table"},
+		},
+		Modes:       ModeDatabase,
+		Execute:     executeDescribeTable,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		MultiSource: true,
+		ReadOnly:    alwaysReadOnly,
+		Describe:    describeTableArg,
+	})
+
+	r.Register(&Tool{
+		Name:        "sample_rows",
+		Description: fmt.Sprintf("Return a few rows of a table to see what its data looks like. Values of columns that look sensitive (passwords, tokens, emails, phone and card numbers) are masked. Default %d rows, at most %d.", defaultSampleRows, maxSampleRows),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"table": tableParameter,
+				"columns": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Optional: columns to return (default all)",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Optional: number of rows (default %d, at most %d)", defaultSampleRows, maxSampleRows),
+				},
+			},
+			"required": []string{"table"},
+		},
+		Modes:       ModeDatabase,
+		Execute:     executeSampleRows,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		MultiSource: true,
+		ReadOnly:    alwaysReadOnly,
+		Describe:    describeTableArg,
+	})
+
+	r.Register(&Tool{
+		Name:        "column_values",
+		Description: fmt.Sprintf("Return the number of distinct and NULL values of a column and its most frequent values with their counts, e.g. to learn the codes a status column uses before filtering on it. Default %d values, at most %d.", defaultColumnValues, maxColumnValues),
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"table": tableParameter,
+				"column": map[string]interface{}{
+					"type":        "string",
+					"description": "Column name",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Optional: number of values (default %d, at most %d)", defaultColumnValues, maxColumnValues),
+				},
+			},
+			"required": []string{"table", "column"},
+		},
+		Modes:       ModeDatabase,
+		Execute:     executeColumnValues,
+		Risk:        NewReadOnlyToolRiskAssessor(),
+		Confirm:     true,
+		MultiSource: true,
+		ReadOnly:    alwaysReadOnly,
+		Describe: func(args map[string]interface{}) string {
+			table, _ := args["table"].(string)
+			column, _ := args["column"].(string)
+			return "of " + truncate(table+"."+column, 80)
+		},
+	})
+}
+
+var tableParameter = map[string]interface{}{
+	"type":        "string",
+	"description": "Table name, optionally qualified with the schema (schema.table)",
+}
+
+func describeTableArg(args map[string]interface{}) string {
+	table, _ := args["table"].(string)
+	return "of table " + truncate(table, 80)
+}
+
+func executeListTables(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	if env.Conn == nil {
+		return nil, fmt.Errorf("list_tables is not available in free mode. Please select a database source to enable it")
+	}
+	filter, _ := args["filter"].(string)
+	tables, err := env.Conn.SearchTables(ctx, "", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make([]map[string]interface{}, 0, len(tables))
+	for i, t := range tables {
+		if i == maxListedTables {
+			break
+		}
+		entry := map[string]interface{}{"name": t.Name}
+		if t.Type != "TABLE" {
+			entry["type"] = strings.ToLower(t.Type)
+		}
+		if t.Rows.Valid {
+			entry["estimated_rows"] = t.Rows.Int64
+		}
+		if t.Comment != "" {
+			entry["comment"] = t.Comment
+		}
+		listed = append(listed, entry)
+	}
+	result := map[string]interface{}{"tables": listed, "count": len(tables)}
+	if len(tables) > maxListedTables {
+		result["truncated"] = true
+		result["hint"] = fmt.Sprintf("only the first %d tables are listed; use a filter to narrow the list", maxListedTables)
+	}
+	return result, nil
+}
+
+func executeDescribeTable(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	if env.Conn == nil {
+		return nil, fmt.Errorf("describe_table is not available in free mode. Please select a database source to enable it")
+	}
+	table, ok := args["table"].(string)
+	if !ok || strings.TrimSpace(table) == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	desc, err := env.Conn.DescribeTable(ctx, "", strings.TrimSpace(table))
+	if err != nil {
+		return nil, err
+	}
+	return tableDescriptionResult(desc), nil
+}
+
+// tableDescriptionResult converts a table description to the JSON result returned to the LLM, omitting empty fields
+func tableDescriptionResult(desc *db.TableDescription) map[string]interface{} {
+	columns := make([]map[string]interface{}, 0, len(desc.Columns))
+	for _, col := range desc.Columns {
+		column := map[string]interface{}{"name": col.Name, "type": col.Type, "nullable": col.Nullable}
+		if col.Default.Valid {
+			column["default"] = col.Default.String
+		}
+		if col.Extra != "" {
+			column["extra"] = col.Extra
+		}
+		if col.Comment != "" {
+			column["comment"] = col.Comment
+		}
+		columns = append(columns, column)
+	}
+	result := map[string]interface{}{"table": desc.Name, "columns": columns}
+	if desc.Comment != "" {
+		result["comment"] = desc.Comment
+	}
+	if len(desc.PrimaryKey) > 0 {
+		result["primary_key"] = desc.PrimaryKey
+	}
+	var indexes []map[string]interface{}
+	for _, index := range desc.Indexes {
+		if index.Primary {
+			continue
+		}
+		indexes = append(indexes, map[string]interface{}{"name": index.Name, "columns": index.Columns, "unique": index.Unique})
+	}
+	if len(indexes) > 0 {
+		result["indexes"] = indexes
+	}
+	var foreignKeys []map[string]interface{}
+	for _, fk := range desc.ForeignKeys {
+		foreignKeys = append(foreignKeys, map[string]interface{}{
+			"name":               fk.Name,
+			"columns":            fk.Columns,
+			"references_table":   fk.ReferencedTable,
+			"references_columns": fk.ReferencedColumns,
+		})
+	}
+	if len(foreignKeys) > 0 {
+		result["foreign_keys"] = foreignKeys
+	}
+	return result
+}
+
+func executeSampleRows(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	if env.Conn == nil {
+		return nil, fmt.Errorf("sample_rows is not available in free mode. Please select a database source to enable it")
+	}
+	table, ok := args["table"].(string)
+	if !ok || strings.TrimSpace(table) == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	var columns []string
+	if list, ok := args["columns"].([]interface{}); ok {
+		for _, c := range list {
+			if name, ok := c.(string); ok && strings.TrimSpace(name) != "" {
+				columns = append(columns, strings.TrimSpace(name))
+			}
+		}
+	}
+
+	result, err := env.Conn.SampleRows(ctx, strings.TrimSpace(table), columns, intArg(args, "limit", defaultSampleRows, maxSampleRows))
+	if err != nil {
+		return nil, err
+	}
+
+	var masked []string
+	for i, column := range result.Columns {
+		kind := sensitiveColumn(column)
+		if kind == "" {
+			continue
+		}
+		masked = append(masked, column)
+		for _, row := range result.Rows {
+			if i < len(row) {
+				row[i] = maskValue(kind, row[i])
+			}
+		}
+	}
+	response := map[string]interface{}{
+		"table":     table,
+		"columns":   result.Columns,
+		"rows":      result.Rows,
+		"row_count": len(result.Rows),
+	}
+	if len(masked) > 0 {
+		response["masked_columns"] = masked
+	}
+	markCached(response, result.CachedAt)
+	return response, nil
+}
+
+func executeColumnValues(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	if env.Conn == nil {
+		return nil, fmt.Errorf("column_values is not available in free mode. Please select a database source to enable it")
+	}
+	table, _ := args["table"].(string)
+	column, _ := args["column"].(string)
+	table, column = strings.TrimSpace(table), strings.TrimSpace(column)
+	if table == "" || column == "" {
+		return nil, fmt.Errorf("table and column are required")
+	}
+
+	stats, err := env.Conn.ColumnValues(ctx, table, column, intArg(args, "limit", defaultColumnValues, maxColumnValues))
+	if err != nil {
+		return nil, err
+	}
+	kind := sensitiveColumn(column)
+	values := make([]map[string]interface{}, 0, len(stats.Values))
+	for _, v := range stats.Values {
+		value := v.Value.String
+		if kind != "" {
+			value = maskValue(kind, value)
+		}
+		values = append(values, map[string]interface{}{"value": value, "count": v.Count})
+	}
+	response := map[string]interface{}{
+		"table":    table,
+		"column":   column,
+		"distinct": stats.Distinct,
+		"nulls":    stats.Nulls,
+		"values":   values,
+	}
+	if kind != "" {
+		response["masked"] = true
+	}
+	return response, nil
+}
+
+// intArg returns a positive integer argument, def when missing, capped at max
+func intArg(args map[string]interface{}, name string, def, max int) int {
+	n := def
+	switch v := args[name].(type) {
+	case float64:
+		n = int(v)
+	case int:
+		n = v
+	}
+	if n <= 0 {
+		n = def
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// Kinds of sensitive columns
+const (
+	sensitiveSecret   = "secret"   // Credentials: masked completely
+	sensitivePersonal = "personal" // Personal data: masked keeping the shape of the value
+)
+
+// Name fragments of columns whose values are masked; short markers must be a whole word of the name
+// (split at underscores and other separators) so that e.g. "classname" does not match "ssn"
+var (
+	secretColumnMarkers   = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "private_key"}
+	secretColumnWords     = []string{"pwd", "salt", "otp", "pin"}
+	personalColumnMarkers = []string{"email", "e_mail", "phone", "social_security", "card_number", "cardnumber", "credit_card", "cc_number", "passport", "tax_id"}
+	personalColumnWords   = []string{"mobile", "ssn", "cvv", "iban", "tin"}
+)
+
+// sensitiveColumn returns the kind of sensitive data a column name suggests, or ""
+func sensitiveColumn(name string) string {
+	lower := strings.ToLower(name)
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	hasWord := func(candidates []string) bool {
+		for _, w := range words {
+			for _, c := range candidates {
+				if w == c {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	hasMarker := func(markers []string) bool {
+		for _, marker := range markers {
+			if strings.Contains(lower, marker) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasMarker(secretColumnMarkers) || hasWord(secretColumnWords):
+		return sensitiveSecret
+	case hasMarker(personalColumnMarkers) || hasWord(personalColumnWords):
+		return sensitivePersonal
+	}
+	return ""
+}
+
+// maskValue hides a sensitive value; personal data keeps its first character, the domain of emails
+// and the last digits of numbers so the model still sees what the values look like
+func maskValue(kind, value string) string {
+	if value == "" || value == "NULL" {
+		return value
+	}
+	if kind == sensitiveSecret {
+		return "****"
+	}
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return value[:1] + "***" + value[at:]
+	}
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return "****"
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+}
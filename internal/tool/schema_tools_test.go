@@ -0,0 +1,81 @@
+package tool
+
+import "testing"
+
+// TestSensitiveColumn tests which column names are masked by sample_rows and column_values
+func TestSensitiveColumn(t *testing.T) {
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"password_hash", sensitiveSecret},
+		{"ApiKey", sensitiveSecret},
+		{"reset_token", sensitiveSecret},
+		{"user_pwd", sensitiveSecret},
+		{"email", sensitivePersonal},
+		{"contact_phone", sensitivePersonal},
+		{"customer_ssn", sensitivePersonal},
+		{"name", ""},
+		{"classname", ""},
+		{"footprint", ""},
+		{"status", ""},
+	}
+	for _, tt := range tests {
+		if got := sensitiveColumn(tt.column); got != tt.want {
+			t.Errorf("sensitiveColumn(%q) = %q, expected %q", tt.column, got, tt.want)
+		}
+	}
+}
+
+// TestMaskValue tests that masked values hide the data but keep their shape
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		kind  string
+		value string
+		want  string
+	}{
+		{sensitiveSecret, "hunter2", "****"},
+		{sensitivePersonal, "alice@example.com", "a***@example.com"},
+		{sensitivePersonal, "4111111111111111", "************1111"},
+		{sensitivePersonal, "123", "****"},
+		{sensitivePersonal, "NULL", "NULL"},
+		{sensitiveSecret, "", ""},
+	}
+	for _, tt := range tests {
+		if got := maskValue(tt.kind, tt.value); got != tt.want {
+			t.Errorf("maskValue(%s, %q) = %q, expected %q", tt.kind, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestIntArg tests limit defaults and caps
+func TestIntArg(t *testing.T) {
+	if n := intArg(map[string]interface{}{}, "limit", 5, 50); n != 5 {
+		t.Errorf("Expected default 5, got %d", n)
+	}
+	if n := intArg(map[string]interface{}{"limit": float64(500)}, "limit", 5, 50); n != 50 {
+		t.Errorf("Expected cap 50, got %d", n)
+	}
+	if n := intArg(map[string]interface{}{"limit": float64(-1)}, "limit", 5, 50); n != 5 {
+		t.Errorf("Expected default for a negative limit, got %d", n)
+	}
+}
+
+// TestSchemaToolsRisk tests that exploration tools run without confirmation and are read-only
+func TestSchemaToolsRisk(t *testing.T) {
+	for _, name := range []string{"list_tables", "describe_table", "sample_rows", "column_values"} {
+		tool, err := Default().Get(name, ModeDatabase)
+		if err != nil {
+			t.Fatalf("Expected %s in database mode: %v", name, err)
+		}
+		if _, err := Default().Get(name, ModeFree); err == nil {
+			t.Errorf("Expected %s to be unavailable in free mode", name)
+		}
+		if risk := GetRiskAssessor(name).AssessRisk(name, map[string]interface{}{"table": "users"}); risk != RiskLow {
+			t.Errorf("Expected %s to be low risk, got %v", name, risk)
+		}
+		if tool.ReadOnly == nil || !tool.ReadOnly(nil) {
+			t.Errorf("Expected %s to be read-only", name)
+		}
+	}
+}
@@ -5,12 +5,93 @@ import (
 	"fmt"
 
 	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
 )
 
+// isNonQuerySQL reports whether a statement should be executed without reading a result set
+// Data and schema changes are executed with Exec so the number of affected rows is available;
+// PostgreSQL's RETURNING clause turns DML into a row-returning statement
+func isNonQuerySQL(sql string, dialect sqlparse.Dialect) bool {
+	statements := sqlparse.Parse(sql, dialect)
+	if len(statements) == 0 {
+		return false
+	}
+	st := statements[0]
+	switch st.Class {
+	case sqlparse.ClassDML, sqlparse.ClassDDL, sqlparse.ClassDCL:
+		return !st.ReturnsRows()
+	default:
+		return false
+	}
+}
+
+// transactionControl returns "begin", "commit" or "rollback" when sql is a single transaction control statement
+// ROLLBACK TO SAVEPOINT and other savepoint statements are not transaction control here and return ""
+func transactionControl(sql string, dialect sqlparse.Dialect) string {
+	statements := sqlparse.Parse(sql, dialect)
+	if len(statements) != 1 {
+		return ""
+	}
+	keywords := statements[0].Keywords
+	if len(keywords) == 0 || len(keywords) != len(statements[0].Tokens) {
+		// Any non-keyword token (e.g. a savepoint name, an isolation level option) means a different statement
+		return ""
+	}
+	rest := keywords[1:]
+	plain := len(rest) == 0 || (len(rest) == 1 && (rest[0] == "WORK" || rest[0] == "TRANSACTION"))
+	switch keywords[0] {
+	case "BEGIN":
+		if plain {
+			return "begin"
+		}
+	case "START":
+		if len(rest) == 1 && rest[0] == "TRANSACTION" {
+			return "begin"
+		}
+	case "COMMIT", "END":
+		if plain {
+			return "commit"
+		}
+	case "ROLLBACK", "ABORT":
+		if plain {
+			return "rollback"
+		}
+	}
+	return ""
+}
+
 // ExecuteSQL executes a SQL query and returns results
 // This function does NOT print anything - it only returns data
 // The LLM will decide how to display the results (via render_table or text description)
+// Non-query statements return an empty result with RowsAffected set
+// BEGIN, COMMIT and ROLLBACK control the session transaction (see db.Connection.Begin), since on the
+// connection pool they would leave a pooled connection with an open transaction
 func ExecuteSQL(ctx context.Context, conn *db.Connection, sql string) (*db.QueryResult, error) {
+	dialect := sqlparse.DialectForDatabaseType(conn.DatabaseType())
+	if action := transactionControl(sql, dialect); action != "" {
+		var err error
+		switch action {
+		case "begin":
+			err = conn.Begin()
+		case "commit":
+			err = conn.Commit()
+		case "rollback":
+			err = conn.Rollback()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query execution failed: %w", err)
+		}
+		return &db.QueryResult{}, nil
+	}
+
+	if isNonQuerySQL(sql, dialect) {
+		rowsAffected, err := conn.ExecuteNonQuery(ctx, sql)
+		if err != nil {
+			return nil, fmt.Errorf("query execution failed: %w", err)
+		}
+		return &db.QueryResult{RowsAffected: rowsAffected}, nil
+	}
+
 	result, err := conn.ExecuteQuery(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %w", err)
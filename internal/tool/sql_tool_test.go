@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/sqlparse"
 )
 
 // TestSQLTool_SELECTQueries tests SQL tool SELECT queries (low risk, automatic execution)
@@ -161,3 +162,29 @@ func TestSQLTool_ResultFormatting(t *testing.T) {
 		}
 	})
 }
+
+// TestTransactionControl tests detection of statements that control the session transaction
+func TestTransactionControl(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"BEGIN", "begin"},
+		{"begin work;", "begin"},
+		{"START TRANSACTION", "begin"},
+		{"COMMIT", "commit"},
+		{"END", "commit"},
+		{"ROLLBACK WORK", "rollback"},
+		{"ROLLBACK TO SAVEPOINT sp1", ""},
+		{"SAVEPOINT sp1", ""},
+		{"COMMIT; DELETE FROM t", ""},
+		{"SELECT 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			if got := transactionControl(tt.sql, sqlparse.DialectPostgres); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,15 +1,33 @@
 package tool
 
 import (
+	"sync/atomic"
+
 	"github.com/aiq/aiq/internal/ui"
 )
 
-// RenderTableString formats query results as a table string
+// expandedOutput makes RenderTableString show one "column: value" line per column (toggled with \x)
+var expandedOutput atomic.Bool
+
+// SetExpandedOutput turns expanded output of query results on or off
+func SetExpandedOutput(on bool) {
+	expandedOutput.Store(on)
+}
+
+// ExpandedOutput reports whether query results are shown expanded
+func ExpandedOutput() bool {
+	return expandedOutput.Load()
+}
+
+// RenderTableString formats query results as a table string, or as one block per row with expanded output
 // This function does NOT print anything - it only returns the formatted string
 func RenderTableString(columns []string, rows [][]string) (string, error) {
 	table := ui.NewTable(columns)
 	for _, row := range rows {
 		table.AddRow(row)
 	}
+	if ExpandedOutput() {
+		return table.RenderVertical(), nil
+	}
 	return table.Render(), nil
 }
@@ -2,74 +2,328 @@ package tool
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aiq/aiq/internal/config"
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/llm"
+	"github.com/aiq/aiq/internal/session"
+)
+
+// Mode is a set of chat modes a tool is available in
+type Mode int
+
+const (
+	// ModeFree is chat without a database source
+	ModeFree Mode = 1 << iota
+	// ModeDatabase is chat with a database source selected
+	ModeDatabase
+	// ModeAll is every chat mode
+	ModeAll = ModeFree | ModeDatabase
+)
+
+// ModeFor returns the chat mode for a connection (nil in free mode)
+func ModeFor(conn *db.Connection) Mode {
+	if conn == nil {
+		return ModeFree
+	}
+	return ModeDatabase
+}
+
+// String returns the mode name shown in messages
+func (m Mode) String() string {
+	switch m {
+	case ModeFree:
+		return "free mode"
+	case ModeDatabase:
+		return "database mode"
+	default:
+		return "all modes"
+	}
+}
+
+// Output modes of tools that print their output while running
+const (
+	// OutputFull prints every line (the output is the result the user asked for)
+	OutputFull = "full"
+	// OutputStreaming shows a rolling window of the latest lines (the output is an intermediate step)
+	OutputStreaming = "streaming"
 )
 
-// Tool represents a callable tool/function
+// Env is what a tool execution may use besides its arguments
+type Env struct {
+	Conn       *db.Connection       // nil in free mode
+	OutputMode string               // OutputFull or OutputStreaming, for tools with StreamsOutput
+	Output     func(line string)    // Receives output lines as they are produced; may be nil
+	Results    *session.ResultStore // Query results of the session, referred to by result_id; may be nil
+}
+
+// sourceParameter is the argument MultiSource tools take to run on an attached source
+var sourceParameter = map[string]interface{}{
+	"type":        "string",
+	"description": "Optional: name of an attached source to run on (see the connected sources in the prompt). Default: the session's source",
+}
+
+// Tool is a tool offered to the LLM together with everything the tool call loop needs to run it
 type Tool struct {
 	Name        string
 	Description string
-	Parameters  map[string]interface{}
-	Execute     func(ctx context.Context, params map[string]interface{}) (interface{}, error)
+	Parameters  map[string]interface{} // JSON schema of the arguments
+	Modes       Mode                   // Chat modes the tool is offered in
+
+	// Execute runs the tool; the result is returned to the LLM as JSON
+	// A returned error is reported to the LLM as a structured error result
+	Execute func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error)
+	// Risk assesses calls; nil uses DefaultRiskAssessor
+	Risk RiskAssessor
+	// Confirm asks the user before running calls assessed high risk; tools without it always run
+	Confirm bool
+	// Query returns the SQL statement a call runs on the connection
+	// Such calls are confirmed with the highlighted statement, previewed and backed up
+	Query func(args map[string]interface{}) (string, bool)
+	// MultiSource tools run on the source named by their source argument (an attached source),
+	// the session's source by default; Register adds the argument
+	MultiSource bool
+	// ReadOnly reports whether a call only reads; read-only calls assessed low risk may run concurrently
+	// nil means calls may change something
+	ReadOnly func(args map[string]interface{}) bool
+	// StreamsOutput tools print output while running, fully or in a rolling window depending on output_mode
+	StreamsOutput bool
+	// Waiting is the spinner message shown while other tools run (default "Waiting...")
+	Waiting string
+	// Describe summarizes a call for display after "Calling tool [name]"; nil shows the arguments
+	Describe func(args map[string]interface{}) string
+	// Statement returns the text recorded in the audit log; nil records the arguments as JSON
+	Statement func(args map[string]interface{}) string
+	// Present shows a successful result to the user and returns what the LLM receives instead
+	// (e.g. a row count once the rows are displayed), plus the result set it displayed if any
+	Present func(result map[string]interface{}) (map[string]interface{}, *db.QueryResult)
 }
 
-// ToolCall represents a request to call a tool
-type ToolCall struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
+// Function returns the LLM function definition of the tool
+func (t *Tool) Function() llm.Function {
+	return llm.Function{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+}
+
+// Run executes the tool and returns its result as JSON for the LLM
+// Execution errors become structured error results; an error is returned only when the result cannot be encoded
+func (t *Tool) Run(ctx context.Context, env *Env, args map[string]interface{}) (json.RawMessage, error) {
+	result, err := t.Execute(ctx, env, args)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	if raw, ok := result.(json.RawMessage); ok {
+		return raw, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return json.RawMessage(data), nil
 }
 
-// ToolResult represents the result of a tool execution
-type ToolResult struct {
-	ToolName string
-	Result   interface{}
-	Error    error
+// Provider supplies tools that come and go at runtime (user-defined tools, MCP servers)
+type Provider interface {
+	// Lookup returns the tool with a name, or nil
+	Lookup(name string) *Tool
+	// Tools returns the current tools in display order
+	Tools() []*Tool
 }
 
-// Registry manages available tools
+// Registry holds the tools offered to the LLM
+// Built-in tools are registered once; providers add tools loaded at runtime
 type Registry struct {
-	tools map[string]*Tool
+	mu        sync.RWMutex
+	tools     map[string]*Tool
+	order     []string
+	providers []Provider
+	disabled  map[string]bool
 }
 
-// NewRegistry creates a new tool registry
+// NewRegistry creates an empty tool registry
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]*Tool),
+		tools:    make(map[string]*Tool),
+		disabled: make(map[string]bool),
+	}
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Registry) Register(t *Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.Modes == 0 {
+		t.Modes = ModeAll
+	}
+	if t.MultiSource {
+		if properties, ok := t.Parameters["properties"].(map[string]interface{}); ok {
+			properties["source"] = sourceParameter
+		}
+	}
+	if _, exists := r.tools[t.Name]; !exists {
+		r.order = append(r.order, t.Name)
+	}
+	r.tools[t.Name] = t
+}
+
+// AddProvider adds a source of runtime tools; registered tools win over provider tools with the same name
+func (r *Registry) AddProvider(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers = append(r.providers, p)
+}
+
+// Configure applies the tools section of config.yaml (disabled tools)
+func (r *Registry) Configure(cfg config.ToolsConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disabled = make(map[string]bool, len(cfg.Disabled))
+	for _, name := range cfg.Disabled {
+		r.disabled[strings.TrimSpace(name)] = true
 	}
 }
 
-// Register registers a tool in the registry
-func (r *Registry) Register(tool *Tool) {
-	r.tools[tool.Name] = tool
+// Lookup returns a tool by name regardless of mode and config, or nil
+func (r *Registry) Lookup(name string) *Tool {
+	r.mu.RLock()
+	t, ok := r.tools[name]
+	providers := r.providers
+	r.mu.RUnlock()
+	if ok {
+		return t
+	}
+	for _, p := range providers {
+		if t := p.Lookup(name); t != nil {
+			return t
+		}
+	}
+	return nil
 }
 
-// GetTool retrieves a tool by name
-func (r *Registry) GetTool(name string) (*Tool, error) {
-	tool, exists := r.tools[name]
-	if !exists {
-		return nil, fmt.Errorf("tool '%s' not found", name)
+// Get returns a tool that may be called in mode, or an error saying why it may not
+func (r *Registry) Get(name string, mode Mode) (*Tool, error) {
+	t := r.Lookup(name)
+	if t == nil {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+	if r.IsDisabled(name) {
+		return nil, fmt.Errorf("tool %s is disabled in config.yaml (tools.disabled)", name)
 	}
-	return tool, nil
+	if t.Modes&mode == 0 {
+		if mode == ModeFree {
+			return nil, fmt.Errorf("tool %s is not available in free mode. Please select a database source to enable it", name)
+		}
+		return nil, fmt.Errorf("tool %s is not available in %s", name, mode)
+	}
+	return t, nil
+}
+
+// IsDisabled reports whether config.yaml disables a tool (tools.disabled)
+func (r *Registry) IsDisabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.disabled[name]
 }
 
-// ListTools returns all registered tools
-func (r *Registry) ListTools() []*Tool {
-	tools := make([]*Tool, 0, len(r.tools))
-	for _, tool := range r.tools {
-		tools = append(tools, tool)
+// Tools returns the tools that may be called in mode: built-in tools in registration order, then provider tools
+func (r *Registry) Tools(mode Mode) []*Tool {
+	r.mu.RLock()
+	all := make([]*Tool, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.tools[name])
+	}
+	providers := r.providers
+	r.mu.RUnlock()
+	for _, p := range providers {
+		all = append(all, p.Tools()...)
+	}
+
+	tools := make([]*Tool, 0, len(all))
+	seen := make(map[string]bool, len(all))
+	for _, t := range all {
+		if seen[t.Name] || t.Modes&mode == 0 || r.IsDisabled(t.Name) {
+			continue
+		}
+		seen[t.Name] = true
+		tools = append(tools, t)
 	}
 	return tools
 }
 
-// GetToolDescriptions returns descriptions of all tools for LLM context
-func (r *Registry) GetToolDescriptions() string {
-	if len(r.tools) == 0 {
-		return "No tools available."
+// Functions returns the LLM function definitions of the tools available in mode
+func (r *Registry) Functions(mode Mode) []llm.Function {
+	tools := r.Tools(mode)
+	functions := make([]llm.Function, 0, len(tools))
+	for _, t := range tools {
+		functions = append(functions, t.Function())
+	}
+	return functions
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// Default returns the registry with the built-in tools, user-defined tools and MCP tools
+func Default() *Registry {
+	defaultRegistryOnce.Do(func() {
+		r := NewRegistry()
+		registerBuiltinTools(r)
+		registerSchemaTools(r)
+		r.AddProvider(customProvider{})
+		r.AddProvider(mcpProvider{})
+		defaultRegistry = r
+	})
+	return defaultRegistry
+}
+
+// Lookup returns a tool of the default registry by name, or nil
+func Lookup(name string) *Tool {
+	return Default().Lookup(name)
+}
+
+// OutputMode returns the output mode requested by a call's output_mode argument,
+// inferred from task_type when missing (definitive: full, exploratory: streaming), default streaming
+func OutputMode(args map[string]interface{}) string {
+	if mode, ok := args["output_mode"].(string); ok {
+		switch strings.ToLower(mode) {
+		case OutputFull:
+			return OutputFull
+		case OutputStreaming:
+			return OutputStreaming
+		}
+	}
+	if taskType, ok := args["task_type"].(string); ok {
+		switch strings.ToLower(taskType) {
+		case "definitive":
+			return OutputFull
+		case "exploratory":
+			return OutputStreaming
+		}
 	}
-	
-	descriptions := "Available tools:\n"
-	for _, tool := range r.tools {
-		descriptions += fmt.Sprintf("- %s: %s\n", tool.Name, tool.Description)
+	return OutputStreaming
+}
+
+// DisplayArgs removes the hints the LLM adds for the tool call loop (risk_level, task_type, output_mode)
+func DisplayArgs(args map[string]interface{}) map[string]interface{} {
+	filtered := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k == "risk_level" || k == "task_type" || k == "output_mode" {
+			continue
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
+
+// truncate shortens s to maxLen bytes, adding "..." when it was cut
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
 	}
-	return descriptions
+	return s[:maxLen] + "..."
 }
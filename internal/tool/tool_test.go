@@ -0,0 +1,166 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aiq/aiq/internal/config"
+)
+
+// testProvider offers a fixed set of runtime tools
+type testProvider []*Tool
+
+func (p testProvider) Lookup(name string) *Tool {
+	for _, t := range p {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (p testProvider) Tools() []*Tool { return p }
+
+func toolNames(tools []*Tool) string {
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// TestRegistry tests modes, disabled tools and provider tools
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&Tool{Name: "sql", Modes: ModeDatabase})
+	r.Register(&Tool{Name: "http"})
+	r.AddProvider(testProvider{{Name: "custom", Modes: ModeAll}, {Name: "http", Modes: ModeAll}})
+
+	if got := toolNames(r.Tools(ModeDatabase)); got != "sql,http,custom" {
+		t.Errorf("Expected sql,http,custom in database mode, got %s", got)
+	}
+	if got := toolNames(r.Tools(ModeFree)); got != "http,custom" {
+		t.Errorf("Expected http,custom in free mode, got %s", got)
+	}
+	if _, err := r.Get("sql", ModeFree); err == nil || !strings.Contains(err.Error(), "not available in free mode") {
+		t.Errorf("Expected sql to be unavailable in free mode, got %v", err)
+	}
+	if _, err := r.Get("missing", ModeDatabase); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("Expected an unknown tool error, got %v", err)
+	}
+	if tool, err := r.Get("custom", ModeFree); err != nil || tool.Name != "custom" {
+		t.Errorf("Expected the provider tool, got %v, %v", tool, err)
+	}
+
+	r.Configure(config.ToolsConfig{Disabled: []string{"http", " custom "}})
+	if got := toolNames(r.Tools(ModeDatabase)); got != "sql" {
+		t.Errorf("Expected only sql after disabling, got %s", got)
+	}
+	if _, err := r.Get("http", ModeDatabase); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("Expected a disabled error, got %v", err)
+	}
+	if functions := r.Functions(ModeDatabase); len(functions) != 1 || functions[0].Name != "sql" {
+		t.Errorf("Expected the sql function definition, got %+v", functions)
+	}
+}
+
+// TestToolRun tests that execution errors become structured error results
+func TestToolRun(t *testing.T) {
+	ok := &Tool{Name: "ok", Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"value": args["value"]}, nil
+	}}
+	result, err := ok.Run(context.Background(), &Env{}, map[string]interface{}{"value": "x"})
+	if err != nil || string(result) != `{"value":"x"}` {
+		t.Errorf("Unexpected result %s, %v", result, err)
+	}
+
+	failing := &Tool{Name: "failing", Execute: func(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("boom")
+	}}
+	result, err = failing.Run(context.Background(), &Env{}, nil)
+	var data map[string]interface{}
+	if err != nil || json.Unmarshal(result, &data) != nil || data["error"] == nil {
+		t.Errorf("Expected a structured error result, got %s, %v", result, err)
+	}
+}
+
+// TestDefaultRegistry tests that the built-in tools are registered for their modes
+func TestDefaultRegistry(t *testing.T) {
+	free := toolNames(Default().Tools(ModeFree))
+	if strings.Contains(free, "execute_sql") || strings.Contains(free, "transaction") {
+		t.Errorf("Expected no database tools in free mode, got %s", free)
+	}
+	database := toolNames(Default().Tools(ModeDatabase))
+	for _, name := range []string{"execute_sql", "render_table", "render_chart", "http_request", "execute_command", "transaction"} {
+		if !strings.Contains(database, name) {
+			t.Errorf("Expected %s in database mode, got %s", name, database)
+		}
+	}
+}
+
+// TestMultiSourceParameter tests that tools querying a source take a source argument
+func TestMultiSourceParameter(t *testing.T) {
+	for _, name := range []string{"execute_sql", "list_tables", "describe_table", "sample_rows", "column_values"} {
+		properties := Lookup(name).Parameters["properties"].(map[string]interface{})
+		if _, ok := properties["source"]; !ok {
+			t.Errorf("Expected a source argument on %s", name)
+		}
+	}
+	properties := Lookup("render_table").Parameters["properties"].(map[string]interface{})
+	if _, ok := properties["source"]; ok {
+		t.Error("Expected no source argument on render_table")
+	}
+}
+
+// TestBuiltinReadOnly tests which built-in tool calls may run concurrently
+func TestBuiltinReadOnly(t *testing.T) {
+	tests := []struct {
+		tool string
+		args map[string]interface{}
+		want bool
+	}{
+		{"execute_sql", map[string]interface{}{"sql": "SELECT * FROM users"}, true},
+		{"execute_sql", map[string]interface{}{"sql": "SELECT 1; DELETE FROM users"}, false},
+		{"execute_sql", map[string]interface{}{"sql": "SELECT * FROM users FOR UPDATE"}, false},
+		{"execute_sql", map[string]interface{}{"sql": ""}, false},
+		{"http_request", map[string]interface{}{"url": "https://example.com"}, true},
+		{"http_request", map[string]interface{}{"url": "https://example.com", "method": "post"}, false},
+		{"render_chart", map[string]interface{}{}, true},
+		{"execute_command", map[string]interface{}{"command": "ls"}, false},
+		{"transaction", map[string]interface{}{"action": "begin"}, false},
+	}
+	for _, tt := range tests {
+		tool := Lookup(tt.tool)
+		got := tool != nil && tool.ReadOnly != nil && tool.ReadOnly(tt.args)
+		if got != tt.want {
+			t.Errorf("%s %v: expected read-only %v, got %v", tt.tool, tt.args, tt.want, got)
+		}
+	}
+}
+
+// TestMarkCached tests that results read from the result cache are marked for the user and the LLM
+func TestMarkCached(t *testing.T) {
+	fresh := map[string]interface{}{"rows": [][]string{{"1"}}}
+	markCached(fresh, time.Time{})
+	if _, ok := fresh["cached"]; ok {
+		t.Error("Expected a fresh result not to be marked cached")
+	}
+
+	storedAt := time.Now().Add(-2 * time.Minute)
+	resultData := map[string]interface{}{"columns": []interface{}{"id"}, "rows": []interface{}{[]interface{}{"1"}}}
+	markCached(resultData, storedAt)
+	if resultData["cached"] != true || resultData["cache_note"] == nil {
+		t.Errorf("Expected cached marker and note, got %v", resultData)
+	}
+	presented, result := presentSQLResult(resultData)
+	if presented["cached"] != true || presented["cache_note"] == nil {
+		t.Errorf("Expected presented result to keep the cached marker, got %v", presented)
+	}
+	if result.CachedAt.IsZero() {
+		t.Error("Expected CachedAt to be set on the presented result")
+	}
+}
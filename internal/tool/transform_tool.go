@@ -0,0 +1,280 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+	"github.com/aiq/aiq/internal/filedb"
+	"github.com/aiq/aiq/internal/transform"
+)
+
+// transformStepSchema is the schema of one step of transform_result
+var transformStepSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"op": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"filter", "select", "sort", "limit", "distinct", "group", "pivot", "unpivot", "compute", "join"},
+			"description": "filter: where. select: columns (\"col\" or \"col AS name\"). sort: by (\"col\" or \"col desc\"). limit: limit, offset. distinct. " +
+				"group: by, aggregates. pivot: by (row keys), column (values become columns), value, function. unpivot: by (kept), columns, name, value. " +
+				"compute: name, expression. join: result_id, on, type",
+		},
+		"where": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"column":   map[string]interface{}{"type": "string"},
+					"operator": map[string]interface{}{"type": "string", "enum": []string{"=", "!=", "<", "<=", ">", ">=", "contains", "starts_with", "ends_with", "in", "not_in", "is_null", "not_null"}},
+					"value":    map[string]interface{}{"description": "Value to compare with; a list for in and not_in"},
+				},
+			},
+			"description": "filter: conditions that must all hold",
+		},
+		"columns": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "select: columns to keep; unpivot: columns turned into rows (default: all not in by)"},
+		"by":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "sort: sort keys; group and pivot: key columns; unpivot: columns kept on each row"},
+		"aggregates": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"function": map[string]interface{}{"type": "string", "enum": []string{"count", "count_distinct", "sum", "avg", "min", "max"}},
+					"column":   map[string]interface{}{"type": "string", "description": "Omit for count of rows"},
+					"as":       map[string]interface{}{"type": "string"},
+				},
+			},
+			"description": "group: aggregate columns",
+		},
+		"limit":      map[string]interface{}{"type": "integer"},
+		"offset":     map[string]interface{}{"type": "integer"},
+		"column":     map[string]interface{}{"type": "string", "description": "pivot: column whose values become columns"},
+		"value":      map[string]interface{}{"type": "string", "description": "pivot: column aggregated into the cells; unpivot: name of the value column"},
+		"function":   map[string]interface{}{"type": "string", "description": "pivot: aggregate function (default sum; count without value)"},
+		"name":       map[string]interface{}{"type": "string", "description": "compute: new column; unpivot: name of the column holding the former column names"},
+		"expression": map[string]interface{}{"type": "string", "description": "compute: e.g. \"revenue - cost\", \"round(total / orders, 2)\", \"first_name || ' ' || last_name\". Functions: round, abs, floor, ceil, upper, lower, length, concat, coalesce"},
+		"result_id":  map[string]interface{}{"type": "string", "description": "join: the result joined on the right"},
+		"on":         map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "join: \"col\" or \"left_col = right_col\""},
+		"type":       map[string]interface{}{"type": "string", "enum": []string{"inner", "left"}, "description": "join: join type (default inner)"},
+	},
+	"required": []string{"op"},
+}
+
+// transformResultParameters is the argument schema of transform_result
+var transformResultParameters = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"result_id": map[string]interface{}{
+			"type":        "string",
+			"description": "ID of the result to transform, as returned by execute_sql or an earlier transform_result (e.g. \"r3\")",
+		},
+		"steps": map[string]interface{}{
+			"type":        "array",
+			"description": "Transformations applied in order. Each step is an object with an op and its fields",
+			"items":       transformStepSchema,
+		},
+	},
+	"required": []string{"result_id", "steps"},
+}
+
+// joinResultsParameters is the argument schema of join_results
+var joinResultsParameters = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"results": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"result_id": map[string]interface{}{"type": "string", "description": "ID of a stored result (e.g. \"r3\")"},
+					"name":      map[string]interface{}{"type": "string", "description": "Table name used in sql (default: the result_id)"},
+				},
+				"required": []string{"result_id"},
+			},
+			"description": "Stored results to query, e.g. fetched from different sources; each becomes a table",
+		},
+		"sql": map[string]interface{}{
+			"type": "string",
+			"description": "SELECT over the result tables, with joins, filters, GROUP BY and aggregates " +
+				"(e.g. \"SELECT c.name, sum(o.amount) AS total FROM orders o JOIN customers c ON o.customer_id = c.id GROUP BY c.name\")",
+		},
+	},
+	"required": []string{"results", "sql"},
+}
+
+// executeTransformResult applies transform steps to a stored result and stores the outcome as a new result
+func executeTransformResult(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	resultID, _ := args["result_id"].(string)
+	if resultID == "" {
+		return nil, fmt.Errorf("invalid result_id parameter")
+	}
+	steps, err := transform.ParseSteps(args["steps"])
+	if err != nil {
+		return nil, err
+	}
+	return applySteps(env, resultID, steps, transformStatement(args))
+}
+
+// executeJoinResults loads stored results, typically fetched from different sources, as tables of the
+// in-memory file engine and runs a SELECT across them
+func executeJoinResults(ctx context.Context, env *Env, args map[string]interface{}) (interface{}, error) {
+	query, _ := args["sql"].(string)
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("invalid sql parameter")
+	}
+	refs, err := resultTables(args)
+	if err != nil {
+		return nil, err
+	}
+	if env.Results == nil {
+		return nil, fmt.Errorf("no stored results are available")
+	}
+
+	tables := make([]*filedb.Table, len(refs))
+	for i, ref := range refs {
+		stored, err := env.Results.Get(ref.id)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = filedb.FromRows(ref.name, stored.Columns, stored.Rows, "NULL")
+	}
+	conn := db.NewFileConnection(filedb.New(tables...))
+	defer conn.Close()
+	result, err := conn.ExecuteQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"status":    "success",
+		"columns":   result.Columns,
+		"rows":      result.Rows,
+		"row_count": len(result.Rows),
+		"result_id": env.Results.Add(joinStatement(args), result.Columns, result.Rows),
+	}, nil
+}
+
+// resultTable is a stored result queried by join_results under a table name
+type resultTable struct {
+	id   string
+	name string
+}
+
+// resultTables reads the results argument of join_results; names default to the result IDs
+func resultTables(args map[string]interface{}) ([]resultTable, error) {
+	items, _ := args["results"].([]interface{})
+	if len(items) == 0 {
+		return nil, fmt.Errorf("results must list at least one result_id")
+	}
+	var refs []resultTable
+	used := map[string]bool{}
+	for _, item := range items {
+		m, _ := item.(map[string]interface{})
+		id, _ := m["result_id"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("each entry of results needs a result_id")
+		}
+		name, _ := m["name"].(string)
+		if name == "" {
+			name = id
+		}
+		if !isIdentifier(name) {
+			return nil, fmt.Errorf("invalid table name %q: use letters, digits and underscores", name)
+		}
+		if used[strings.ToLower(name)] {
+			return nil, fmt.Errorf("table name %q is used twice", name)
+		}
+		used[strings.ToLower(name)] = true
+		refs = append(refs, resultTable{id: id, name: name})
+	}
+	return refs, nil
+}
+
+// isIdentifier reports whether name can be used in SQL without quoting
+func isIdentifier(name string) bool {
+	for i, ch := range name {
+		letter := ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !letter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// applySteps runs steps on the stored result resultID and stores the outcome under a new ID
+func applySteps(env *Env, resultID string, steps []transform.Step, statement string) (interface{}, error) {
+	if env.Results == nil {
+		return nil, fmt.Errorf("no stored results are available")
+	}
+	lookup := func(id string) (*db.QueryResult, error) {
+		stored, err := env.Results.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		return &db.QueryResult{Columns: stored.Columns, Rows: stored.Rows}, nil
+	}
+	source, err := lookup(resultID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := transform.Apply(source, steps, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"status":    "success",
+		"columns":   result.Columns,
+		"rows":      result.Rows,
+		"row_count": len(result.Rows),
+		"result_id": env.Results.Add(statement, result.Columns, result.Rows),
+	}, nil
+}
+
+// transformStatement describes a transform_result call, e.g. "transform r3: filter, group, sort"
+func transformStatement(args map[string]interface{}) string {
+	resultID, _ := args["result_id"].(string)
+	var ops []string
+	steps, _ := args["steps"].([]interface{})
+	for _, step := range steps {
+		if s, ok := step.(map[string]interface{}); ok {
+			op, _ := s["op"].(string)
+			if id, ok := s["result_id"].(string); ok && op == "join" {
+				op += " " + id
+			}
+			ops = append(ops, op)
+		}
+	}
+	return fmt.Sprintf("transform %s: %s", resultID, strings.Join(ops, ", "))
+}
+
+func describeTransform(args map[string]interface{}) string {
+	return "(" + transformStatement(args) + ")"
+}
+
+// joinStatement describes a join_results call by its tables and query, e.g.
+// "join r3 AS orders, r5 AS customers: SELECT ..."
+func joinStatement(args map[string]interface{}) string {
+	query, _ := args["sql"].(string)
+	return joinTables(args) + ": " + strings.TrimSpace(query)
+}
+
+// joinTables lists the results of a join_results call, e.g. "join r3 AS orders, r5"
+func joinTables(args map[string]interface{}) string {
+	var tables []string
+	items, _ := args["results"].([]interface{})
+	for _, item := range items {
+		m, _ := item.(map[string]interface{})
+		id, _ := m["result_id"].(string)
+		if name, _ := m["name"].(string); name != "" && name != id {
+			id += " AS " + name
+		}
+		tables = append(tables, id)
+	}
+	return "join " + strings.Join(tables, ", ")
+}
+
+func describeJoin(args map[string]interface{}) string {
+	return "(" + joinTables(args) + ")"
+}
@@ -0,0 +1,112 @@
+package tool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aiq/aiq/internal/session"
+)
+
+func TestExecuteTransformResult(t *testing.T) {
+	env := &Env{Results: session.NewResultStore()}
+	id := env.Results.Add("SELECT name, total FROM customers", []string{"name", "total"}, [][]string{
+		{"alice", "30"}, {"bob", "50"}, {"carol", "10"},
+	})
+
+	args := map[string]interface{}{
+		"result_id": id,
+		"steps": []interface{}{
+			map[string]interface{}{"op": "sort", "by": []interface{}{"total desc"}},
+			map[string]interface{}{"op": "limit", "limit": float64(2)},
+		},
+	}
+	result, err := executeTransformResult(context.Background(), env, args)
+	if err != nil {
+		t.Fatalf("executeTransformResult failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	if rows := data["rows"]; !reflect.DeepEqual(rows, [][]string{{"bob", "50"}, {"alice", "30"}}) {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+
+	// The outcome is stored under a new ID for further steps and rendering
+	newID, _ := data["result_id"].(string)
+	stored, err := env.Results.Get(newID)
+	if err != nil || newID == id {
+		t.Fatalf("Expected the transformed result to be stored under a new ID, got %q: %v", newID, err)
+	}
+	if stored.Query != "transform "+id+": sort, limit" {
+		t.Errorf("Unexpected stored description: %q", stored.Query)
+	}
+
+	if _, err := executeTransformResult(context.Background(), env, map[string]interface{}{"result_id": "r404", "steps": args["steps"]}); err == nil {
+		t.Error("Expected an error for an unknown result")
+	}
+}
+
+func TestExecuteJoinResults(t *testing.T) {
+	env := &Env{Results: session.NewResultStore()}
+	orders := env.Results.Add("SELECT customer_id, amount FROM orders", []string{"customer_id", "amount"}, [][]string{
+		{"1", "20"}, {"2", "5"}, {"1", "15"}, {"3", "7.5"}, {"NULL", "1"},
+	})
+	customers := env.Results.Add("SELECT id, name FROM customers", []string{"id", "name"}, [][]string{
+		{"1", "alice"}, {"2", "bob"}, {"3", "NULL"},
+	})
+
+	args := map[string]interface{}{
+		"results": []interface{}{
+			map[string]interface{}{"result_id": orders, "name": "orders"},
+			map[string]interface{}{"result_id": customers, "name": "customers"},
+		},
+		"sql": "SELECT c.name, sum(o.amount) AS total, count(*) AS orders FROM orders o JOIN customers c ON o.customer_id = c.id " +
+			"WHERE c.name IS NOT NULL GROUP BY c.name HAVING sum(o.amount) > 1 ORDER BY total DESC",
+	}
+	result, err := executeJoinResults(context.Background(), env, args)
+	if err != nil {
+		t.Fatalf("executeJoinResults failed: %v", err)
+	}
+	data := result.(map[string]interface{})
+	if columns := data["columns"]; !reflect.DeepEqual(columns, []string{"name", "total", "orders"}) {
+		t.Errorf("Unexpected columns: %v", columns)
+	}
+	if rows := data["rows"]; !reflect.DeepEqual(rows, [][]string{{"alice", "35", "2"}, {"bob", "5", "1"}}) {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+	stored, err := env.Results.Get(data["result_id"].(string))
+	if err != nil || stored.Query != "join "+orders+" AS orders, "+customers+" AS customers: "+args["sql"].(string) {
+		t.Errorf("Unexpected stored join: %+v, %v", stored, err)
+	}
+
+	// Without names the tables are named after the result IDs
+	result, err = executeJoinResults(context.Background(), env, map[string]interface{}{
+		"results": []interface{}{map[string]interface{}{"result_id": orders}},
+		"sql":     "SELECT count(*) AS n, sum(amount) AS total FROM " + orders + " WHERE customer_id IS NULL",
+	})
+	if err != nil {
+		t.Fatalf("executeJoinResults without names failed: %v", err)
+	}
+	if rows := result.(map[string]interface{})["rows"]; !reflect.DeepEqual(rows, [][]string{{"1", "1"}}) {
+		t.Errorf("Unexpected rows: %v", rows)
+	}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+	}{
+		{name: "no sql", args: map[string]interface{}{"results": args["results"]}},
+		{name: "no results", args: map[string]interface{}{"sql": "SELECT 1"}},
+		{name: "unknown result", args: map[string]interface{}{"results": []interface{}{map[string]interface{}{"result_id": "r404"}}, "sql": "SELECT * FROM r404"}},
+		{name: "invalid name", args: map[string]interface{}{"results": []interface{}{map[string]interface{}{"result_id": orders, "name": "my orders"}}, "sql": "SELECT 1"}},
+		{name: "duplicate name", args: map[string]interface{}{"results": []interface{}{
+			map[string]interface{}{"result_id": orders, "name": "t"},
+			map[string]interface{}{"result_id": customers, "name": "T"},
+		}, "sql": "SELECT 1"}},
+		{name: "write", args: map[string]interface{}{"results": args["results"], "sql": "DELETE FROM orders"}},
+	}
+	for _, tt := range tests {
+		if _, err := executeJoinResults(context.Background(), env, tt.args); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// aggregator accumulates the values of one aggregate; NULLs are ignored as in SQL
+type aggregator struct {
+	function string
+	count    int
+	numeric  int
+	sum      float64
+	min, max string
+	distinct map[string]bool
+}
+
+func newAggregator(function string) (*aggregator, error) {
+	function = strings.ToLower(strings.TrimSpace(function))
+	switch function {
+	case "count", "count_distinct", "sum", "avg", "min", "max":
+		return &aggregator{function: function, distinct: make(map[string]bool)}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate function '%s' (use count, count_distinct, sum, avg, min or max)", function)
+}
+
+func (a *aggregator) add(v string) {
+	if v == null {
+		return
+	}
+	a.count++
+	if a.function == "count_distinct" {
+		a.distinct[v] = true
+	}
+	if f, ok := number(v); ok {
+		a.numeric++
+		a.sum += f
+	}
+	if a.count == 1 || Compare(v, a.min) < 0 {
+		a.min = v
+	}
+	if a.count == 1 || Compare(v, a.max) > 0 {
+		a.max = v
+	}
+}
+
+func (a *aggregator) result() string {
+	switch a.function {
+	case "count":
+		return formatNumber(float64(a.count))
+	case "count_distinct":
+		return formatNumber(float64(len(a.distinct)))
+	}
+	if a.count == 0 {
+		return null
+	}
+	switch a.function {
+	case "sum":
+		if a.numeric == 0 {
+			return null
+		}
+		return formatNumber(a.sum)
+	case "avg":
+		if a.numeric == 0 {
+			return null
+		}
+		return formatNumber(a.sum / float64(a.numeric))
+	case "min":
+		return a.min
+	default:
+		return a.max
+	}
+}
+
+// aggregateColumn resolves the column an aggregate reads; -1 counts rows (count without a column, or count(*))
+func aggregateColumn(columns []string, function, column string) (int, error) {
+	if column == "" || column == "*" {
+		if strings.EqualFold(strings.TrimSpace(function), "count") {
+			return -1, nil
+		}
+		return 0, fmt.Errorf("%s needs a column", function)
+	}
+	return columnIndex(columns, column)
+}
+
+// group groups rows by the key columns and computes the aggregates of each group, in order of first appearance
+// Without key columns the whole result is one group
+func group(t *db.QueryResult, by []string, aggregates []Aggregate) (*db.QueryResult, error) {
+	if len(by) == 0 && len(aggregates) == 0 {
+		return nil, fmt.Errorf("no group columns or aggregates given")
+	}
+	keys, err := columnIndexes(t.Columns, by)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]int, len(aggregates))
+	out := &db.QueryResult{}
+	for _, k := range keys {
+		out.Columns = append(out.Columns, t.Columns[k])
+	}
+	for i, agg := range aggregates {
+		if _, err := newAggregator(agg.Function); err != nil {
+			return nil, err
+		}
+		if inputs[i], err = aggregateColumn(t.Columns, agg.Function, agg.Column); err != nil {
+			return nil, err
+		}
+		name := agg.As
+		if name == "" {
+			name = strings.ToLower(agg.Function)
+			if inputs[i] >= 0 {
+				name += "_" + t.Columns[inputs[i]]
+			}
+		}
+		out.Columns = append(out.Columns, name)
+	}
+
+	type groupState struct {
+		key  []string
+		aggs []*aggregator
+	}
+	var groups []*groupState
+	byKey := make(map[string]*groupState)
+	for _, row := range t.Rows {
+		key := make([]string, len(keys))
+		for i, k := range keys {
+			key[i] = cell(row, k)
+		}
+		g, ok := byKey[rowKey(key)]
+		if !ok {
+			g = &groupState{key: key}
+			for _, agg := range aggregates {
+				a, _ := newAggregator(agg.Function)
+				g.aggs = append(g.aggs, a)
+			}
+			byKey[rowKey(key)] = g
+			groups = append(groups, g)
+		}
+		for i, a := range g.aggs {
+			if inputs[i] < 0 {
+				a.add("1")
+			} else {
+				a.add(cell(row, inputs[i]))
+			}
+		}
+	}
+	// An aggregate over no rows still has one row, as in SQL
+	if len(groups) == 0 && len(keys) == 0 {
+		g := &groupState{}
+		for _, agg := range aggregates {
+			a, _ := newAggregator(agg.Function)
+			g.aggs = append(g.aggs, a)
+		}
+		groups = append(groups, g)
+	}
+
+	for _, g := range groups {
+		row := append([]string{}, g.key...)
+		for _, a := range g.aggs {
+			row = append(row, a.result())
+		}
+		out.Rows = append(out.Rows, row)
+	}
+	return out, nil
+}
+
+// pivot turns the values of column into columns, one row per combination of the by columns
+// Cells aggregate value with function; without value they count rows
+func pivot(t *db.QueryResult, by []string, column, value, function string) (*db.QueryResult, error) {
+	if column == "" {
+		return nil, fmt.Errorf("pivot needs the column whose values become columns")
+	}
+	keys, err := columnIndexes(t.Columns, by)
+	if err != nil {
+		return nil, err
+	}
+	pivotIndex, err := columnIndex(t.Columns, column)
+	if err != nil {
+		return nil, err
+	}
+	if function == "" {
+		function = "sum"
+		if value == "" {
+			function = "count"
+		}
+	}
+	if _, err := newAggregator(function); err != nil {
+		return nil, err
+	}
+	valueIndex, err := aggregateColumn(t.Columns, function, value)
+	if err != nil {
+		return nil, err
+	}
+
+	var headers []string
+	headerIndex := make(map[string]int)
+	type rowState struct {
+		key   []string
+		cells map[string]*aggregator
+	}
+	var rows []*rowState
+	byKey := make(map[string]*rowState)
+	for _, row := range t.Rows {
+		header := cell(row, pivotIndex)
+		if _, ok := headerIndex[header]; !ok {
+			headerIndex[header] = len(headers)
+			headers = append(headers, header)
+		}
+		key := make([]string, len(keys))
+		for i, k := range keys {
+			key[i] = cell(row, k)
+		}
+		r, ok := byKey[rowKey(key)]
+		if !ok {
+			r = &rowState{key: key, cells: make(map[string]*aggregator)}
+			byKey[rowKey(key)] = r
+			rows = append(rows, r)
+		}
+		a, ok := r.cells[header]
+		if !ok {
+			a, _ = newAggregator(function)
+			r.cells[header] = a
+		}
+		if valueIndex < 0 {
+			a.add("1")
+		} else {
+			a.add(cell(row, valueIndex))
+		}
+	}
+
+	out := &db.QueryResult{}
+	for _, k := range keys {
+		out.Columns = append(out.Columns, t.Columns[k])
+	}
+	out.Columns = append(out.Columns, headers...)
+	for _, r := range rows {
+		row := append([]string{}, r.key...)
+		for _, header := range headers {
+			if a, ok := r.cells[header]; ok {
+				row = append(row, a.result())
+			} else {
+				// Combinations without rows are empty, as an outer join would leave them
+				empty, _ := newAggregator(function)
+				row = append(row, empty.result())
+			}
+		}
+		out.Rows = append(out.Rows, row)
+	}
+	return out, nil
+}
+
+// unpivot turns columns into rows of (name, value), keeping the by columns on each row
+// Without columns, every column not kept is turned into rows
+func unpivot(t *db.QueryResult, by []string, columns []string, name, value string) (*db.QueryResult, error) {
+	keys, err := columnIndexes(t.Columns, by)
+	if err != nil {
+		return nil, err
+	}
+	var values []int
+	if len(columns) > 0 {
+		if values, err = columnIndexes(t.Columns, columns); err != nil {
+			return nil, err
+		}
+	} else {
+		kept := make(map[int]bool)
+		for _, k := range keys {
+			kept[k] = true
+		}
+		for i := range t.Columns {
+			if !kept[i] {
+				values = append(values, i)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no columns to unpivot")
+	}
+	if len(t.Rows)*len(values) > MaxRows {
+		return nil, fmt.Errorf("result would have more than %d rows", MaxRows)
+	}
+	if name == "" {
+		name = "column"
+	}
+	if value == "" {
+		value = "value"
+	}
+
+	out := &db.QueryResult{}
+	for _, k := range keys {
+		out.Columns = append(out.Columns, t.Columns[k])
+	}
+	out.Columns = append(out.Columns, name, value)
+	for _, row := range t.Rows {
+		for _, v := range values {
+			r := make([]string, 0, len(keys)+2)
+			for _, k := range keys {
+				r = append(r, cell(row, k))
+			}
+			out.Rows = append(out.Rows, append(r, t.Columns[v], cell(row, v)))
+		}
+	}
+	return out, nil
+}
+
+// join joins right (the result rightID) to t on pairs of columns
+// Right columns with the same name as a left column are prefixed with rightID; equal join columns appear once
+func join(t, right *db.QueryResult, rightID string, on []string, joinType string) (*db.QueryResult, error) {
+	if len(on) == 0 {
+		return nil, fmt.Errorf("join needs on columns")
+	}
+	joinType = strings.ToLower(strings.TrimSpace(joinType))
+	if joinType == "" {
+		joinType = "inner"
+	}
+	if joinType != "inner" && joinType != "left" {
+		return nil, fmt.Errorf("unknown join type '%s' (use inner or left)", joinType)
+	}
+
+	leftKeys := make([]int, len(on))
+	rightKeys := make([]int, len(on))
+	skip := make(map[int]bool) // Right columns not repeated in the output
+	for i, pair := range on {
+		leftName, rightName := pair, pair
+		if parts := strings.SplitN(pair, "=", 2); len(parts) == 2 {
+			leftName, rightName = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+		var err error
+		if leftKeys[i], err = columnIndex(t.Columns, leftName); err != nil {
+			return nil, err
+		}
+		if rightKeys[i], err = columnIndex(right.Columns, rightName); err != nil {
+			return nil, fmt.Errorf("%s: %w", rightID, err)
+		}
+		if strings.EqualFold(t.Columns[leftKeys[i]], right.Columns[rightKeys[i]]) {
+			skip[rightKeys[i]] = true
+		}
+	}
+
+	out := &db.QueryResult{Columns: append([]string{}, t.Columns...)}
+	var rightColumns []int
+	for i, col := range right.Columns {
+		if skip[i] {
+			continue
+		}
+		rightColumns = append(rightColumns, i)
+		if _, err := columnIndex(t.Columns, col); err == nil {
+			col = rightID + "." + col
+		}
+		out.Columns = append(out.Columns, col)
+	}
+
+	// Hash join; NULL keys match nothing
+	key := func(row []string, indexes []int) (string, bool) {
+		values := make([]string, len(indexes))
+		for i, index := range indexes {
+			values[i] = cell(row, index)
+			if values[i] == null {
+				return "", false
+			}
+			// 1 and 1.0 are the same key
+			if f, ok := number(values[i]); ok {
+				values[i] = formatNumber(f)
+			}
+		}
+		return rowKey(values), true
+	}
+	matches := make(map[string][][]string)
+	for _, row := range right.Rows {
+		if k, ok := key(row, rightKeys); ok {
+			matches[k] = append(matches[k], row)
+		}
+	}
+
+	for _, row := range t.Rows {
+		var found [][]string
+		if k, ok := key(row, leftKeys); ok {
+			found = matches[k]
+		}
+		if len(found) == 0 && joinType == "left" {
+			found = [][]string{nil}
+		}
+		for _, match := range found {
+			if len(out.Rows) >= MaxRows {
+				return nil, fmt.Errorf("result would have more than %d rows", MaxRows)
+			}
+			r := append(make([]string, 0, len(out.Columns)), row...)
+			for len(r) < len(t.Columns) {
+				r = append(r, null)
+			}
+			for _, i := range rightColumns {
+				r = append(r, cell(match, i))
+			}
+			out.Rows = append(out.Rows, r)
+		}
+	}
+	return out, nil
+}
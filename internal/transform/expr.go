@@ -0,0 +1,361 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// compute adds a column whose value is expression evaluated on each row
+// Expressions use columns, numbers, 'text', + - * / %, || (concatenation), parentheses and the functions
+// round, abs, floor, ceil, upper, lower, length, concat and coalesce
+// Arithmetic on NULL or text, and division by zero, give NULL
+func compute(t *db.QueryResult, name, expression string) (*db.QueryResult, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("compute needs a name for the new column")
+	}
+	eval, err := compileExpression(expression, t.Columns)
+	if err != nil {
+		return nil, err
+	}
+	out := &db.QueryResult{Columns: append(append([]string{}, t.Columns...), name)}
+	out.Rows = make([][]string, len(t.Rows))
+	for i, row := range t.Rows {
+		r := make([]string, len(t.Columns), len(t.Columns)+1)
+		for j := range r {
+			r[j] = cell(row, j)
+		}
+		out.Rows[i] = append(r, eval(row))
+	}
+	return out, nil
+}
+
+// evaluator computes an expression's value for a row
+type evaluator func(row []string) string
+
+type exprParser struct {
+	tokens  []string
+	pos     int
+	columns []string
+}
+
+func compileExpression(expression string, columns []string) (evaluator, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	p := &exprParser{tokens: tokens, columns: columns}
+	eval, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in expression", p.tokens[p.pos])
+	}
+	return eval, nil
+}
+
+// tokenize splits an expression into numbers, 'strings', "quoted" or `quoted` identifiers, names and operators
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c in expression", c)
+			}
+			tokens = append(tokens, s[i:i+end+2])
+			i += end + 2
+		case c == '|' && i+1 < len(s) && s[i+1] == '|':
+			tokens = append(tokens, "||")
+			i += 2
+		case strings.ContainsRune("+-*/%(),", c):
+			tokens = append(tokens, string(c))
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in expression", c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) concat() (evaluator, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row []string) string {
+			a, b := l(row), right(row)
+			if a == null || b == null {
+				return null
+			}
+			return a + b
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) sum() (evaluator, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) product() (evaluator, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" || p.peek() == "%" {
+		op := p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (evaluator, error) {
+	if p.peek() == "-" {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic("-", func([]string) string { return "0" }, operand), nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (evaluator, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		inner, err := p.concat()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in expression")
+		}
+		return inner, nil
+	case token[0] == '\'':
+		text := token[1 : len(token)-1]
+		return func([]string) string { return text }, nil
+	case token[0] == '"' || token[0] == '`':
+		return p.column(token[1 : len(token)-1])
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		f, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' in expression", token)
+		}
+		text := formatNumber(f)
+		return func([]string) string { return text }, nil
+	case strings.EqualFold(token, "null"):
+		return func([]string) string { return null }, nil
+	case p.peek() == "(":
+		return p.function(strings.ToLower(token))
+	default:
+		return p.column(token)
+	}
+}
+
+func (p *exprParser) column(name string) (evaluator, error) {
+	index, err := columnIndex(p.columns, name)
+	if err != nil {
+		return nil, err
+	}
+	return func(row []string) string { return cell(row, index) }, nil
+}
+
+func (p *exprParser) function(name string) (evaluator, error) {
+	p.next() // (
+	var args []evaluator
+	for p.peek() != ")" {
+		arg, err := p.concat()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if p.next() != ")" {
+		return nil, fmt.Errorf("missing ) after arguments of %s", name)
+	}
+
+	arity := func(min, max int) error {
+		if len(args) < min || len(args) > max {
+			return fmt.Errorf("wrong number of arguments to %s", name)
+		}
+		return nil
+	}
+	numeric := func(f func(x float64) float64) evaluator {
+		arg := args[0]
+		return func(row []string) string {
+			x, ok := number(arg(row))
+			if !ok {
+				return null
+			}
+			return formatNumber(f(x))
+		}
+	}
+	text := func(f func(s string) string) evaluator {
+		arg := args[0]
+		return func(row []string) string {
+			s := arg(row)
+			if s == null {
+				return null
+			}
+			return f(s)
+		}
+	}
+
+	switch name {
+	case "round":
+		if err := arity(1, 2); err != nil {
+			return nil, err
+		}
+		value, digits := args[0], evaluator(func([]string) string { return "0" })
+		if len(args) == 2 {
+			digits = args[1]
+		}
+		return func(row []string) string {
+			x, ok := number(value(row))
+			d, okDigits := number(digits(row))
+			if !ok || !okDigits {
+				return null
+			}
+			scale := math.Pow(10, math.Trunc(d))
+			return formatNumber(math.Round(x*scale) / scale)
+		}, nil
+	case "abs", "floor", "ceil":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return numeric(map[string]func(float64) float64{"abs": math.Abs, "floor": math.Floor, "ceil": math.Ceil}[name]), nil
+	case "upper", "lower", "length":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return text(map[string]func(string) string{
+			"upper":  strings.ToUpper,
+			"lower":  strings.ToLower,
+			"length": func(s string) string { return strconv.Itoa(len([]rune(s))) },
+		}[name]), nil
+	case "concat":
+		return func(row []string) string {
+			var b strings.Builder
+			for _, arg := range args {
+				if v := arg(row); v != null {
+					b.WriteString(v)
+				}
+			}
+			return b.String()
+		}, nil
+	case "coalesce":
+		if err := arity(1, len(args)); err != nil {
+			return nil, err
+		}
+		return func(row []string) string {
+			for _, arg := range args {
+				if v := arg(row); v != null {
+					return v
+				}
+			}
+			return null
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown function '%s' (use round, abs, floor, ceil, upper, lower, length, concat or coalesce)", name)
+}
+
+func arithmetic(op string, left, right evaluator) evaluator {
+	return func(row []string) string {
+		x, okX := number(left(row))
+		y, okY := number(right(row))
+		if !okX || !okY {
+			return null
+		}
+		var r float64
+		switch op {
+		case "+":
+			r = x + y
+		case "-":
+			r = x - y
+		case "*":
+			r = x * y
+		case "/":
+			if y == 0 {
+				return null
+			}
+			r = x / y
+		case "%":
+			if y == 0 {
+				return null
+			}
+			r = math.Mod(x, y)
+		}
+		return formatNumber(r)
+	}
+}
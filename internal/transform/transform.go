@@ -0,0 +1,442 @@
+// Package transform refines query results in process: filter, sort, group, pivot, computed columns
+// and joins over results already fetched, so follow-up questions need not query the database again
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+// MaxRows limits the rows a transformation may produce (joins and unpivots can multiply rows)
+const MaxRows = 100000
+
+// null is how query results represent NULL (see db.QueryResult)
+const null = "NULL"
+
+// Step is one transformation; Op selects which of the other fields apply
+type Step struct {
+	Op         string      `json:"op"`
+	Where      []Condition `json:"where,omitempty"`      // filter: conditions, all of which must hold
+	Columns    []string    `json:"columns,omitempty"`    // select: columns ("col" or "col AS name"); unpivot: columns turned into rows
+	By         []string    `json:"by,omitempty"`         // sort: "col" or "col desc"; group, pivot: key columns; unpivot: columns kept
+	Aggregates []Aggregate `json:"aggregates,omitempty"` // group
+	Limit      int         `json:"limit,omitempty"`      // limit
+	Offset     int         `json:"offset,omitempty"`     // limit
+	Column     string      `json:"column,omitempty"`     // pivot: column whose values become columns
+	Value      string      `json:"value,omitempty"`      // pivot: column aggregated into the cells; unpivot: name of the value column
+	Function   string      `json:"function,omitempty"`   // pivot: aggregate function (default sum, or count without value)
+	Name       string      `json:"name,omitempty"`       // compute: new column; unpivot: name of the column holding former column names
+	Expression string      `json:"expression,omitempty"` // compute
+	ResultID   string      `json:"result_id,omitempty"`  // join: the result joined on the right
+	On         []string    `json:"on,omitempty"`         // join: "col" or "left_col = right_col"
+	Type       string      `json:"type,omitempty"`       // join: inner (default) or left
+}
+
+// Condition compares a column with a value
+type Condition struct {
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"` // =, !=, <, <=, >, >=, contains, starts_with, ends_with, in, not_in, is_null, not_null
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// Aggregate is an aggregate column of a group step
+type Aggregate struct {
+	Function string `json:"function"` // count, count_distinct, sum, avg, min, max
+	Column   string `json:"column,omitempty"`
+	As       string `json:"as,omitempty"`
+}
+
+// ParseSteps reads steps from tool call arguments (a JSON array of step objects)
+func ParseSteps(v interface{}) ([]Step, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read steps: %w", err)
+	}
+	var steps []Step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps given")
+	}
+	return steps, nil
+}
+
+// Lookup returns another stored result by ID, for joins
+type Lookup func(id string) (*db.QueryResult, error)
+
+// Apply runs the steps in order on result and returns a new result; result itself is not changed
+func Apply(result *db.QueryResult, steps []Step, lookup Lookup) (*db.QueryResult, error) {
+	current := &db.QueryResult{Columns: result.Columns, Rows: result.Rows}
+	for i, step := range steps {
+		next, err := apply(current, step, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+		if len(next.Rows) > MaxRows {
+			return nil, fmt.Errorf("step %d (%s): result has more than %d rows", i+1, step.Op, MaxRows)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+func apply(t *db.QueryResult, step Step, lookup Lookup) (*db.QueryResult, error) {
+	switch strings.ToLower(step.Op) {
+	case "filter":
+		return filter(t, step.Where)
+	case "select":
+		return selectColumns(t, step.Columns)
+	case "sort":
+		return sortRows(t, step.By)
+	case "limit":
+		return limit(t, step.Limit, step.Offset)
+	case "distinct":
+		return distinct(t), nil
+	case "group":
+		return group(t, step.By, step.Aggregates)
+	case "pivot":
+		return pivot(t, step.By, step.Column, step.Value, step.Function)
+	case "unpivot":
+		return unpivot(t, step.By, step.Columns, step.Name, step.Value)
+	case "compute":
+		return compute(t, step.Name, step.Expression)
+	case "join":
+		if lookup == nil {
+			return nil, fmt.Errorf("no stored results to join with")
+		}
+		right, err := lookup(step.ResultID)
+		if err != nil {
+			return nil, err
+		}
+		return join(t, right, step.ResultID, step.On, step.Type)
+	case "":
+		return nil, fmt.Errorf("missing op")
+	default:
+		return nil, fmt.Errorf("unknown op (use filter, select, sort, limit, distinct, group, pivot, unpivot, compute or join)")
+	}
+}
+
+// columnIndex finds a column by name, case-insensitively
+func columnIndex(columns []string, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	for i, col := range columns {
+		if col == name {
+			return i, nil
+		}
+	}
+	for i, col := range columns {
+		if strings.EqualFold(col, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown column '%s' (columns: %s)", name, strings.Join(columns, ", "))
+}
+
+func columnIndexes(columns []string, names []string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		index, err := columnIndex(columns, name)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = index
+	}
+	return indexes, nil
+}
+
+func cell(row []string, index int) string {
+	if index < len(row) {
+		return row[index]
+	}
+	return null
+}
+
+// number parses a value as a number; NULL and text are not numbers
+func number(s string) (float64, bool) {
+	if s == null {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Compare orders two values: NULL first, numbers numerically, anything else as text
+func Compare(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == null:
+		return -1
+	case b == null:
+		return 1
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// valueString converts a JSON argument value to the string form of result cells
+func valueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return null
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func filter(t *db.QueryResult, where []Condition) (*db.QueryResult, error) {
+	type condition struct {
+		index    int
+		operator string
+		value    string
+		values   []string
+	}
+	conditions := make([]condition, len(where))
+	for i, w := range where {
+		index, err := columnIndex(t.Columns, w.Column)
+		if err != nil {
+			return nil, err
+		}
+		c := condition{index: index, operator: strings.ToLower(strings.TrimSpace(w.Operator)), value: valueString(w.Value)}
+		switch c.operator {
+		case "", "==":
+			c.operator = "="
+		case "<>":
+			c.operator = "!="
+		}
+		switch c.operator {
+		case "=", "!=", "<", "<=", ">", ">=", "contains", "starts_with", "ends_with", "is_null", "not_null":
+		case "in", "not_in":
+			list, ok := w.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s needs a list of values", c.operator)
+			}
+			for _, v := range list {
+				c.values = append(c.values, valueString(v))
+			}
+		default:
+			return nil, fmt.Errorf("unknown operator '%s'", w.Operator)
+		}
+		conditions[i] = c
+	}
+
+	matches := func(row []string, c condition) bool {
+		v := cell(row, c.index)
+		switch c.operator {
+		case "is_null":
+			return v == null
+		case "not_null":
+			return v != null
+		}
+		// As in SQL, NULL matches no comparison
+		if v == null {
+			return false
+		}
+		switch c.operator {
+		case "=":
+			return Compare(v, c.value) == 0
+		case "!=":
+			return Compare(v, c.value) != 0
+		case "<":
+			return Compare(v, c.value) < 0
+		case "<=":
+			return Compare(v, c.value) <= 0
+		case ">":
+			return Compare(v, c.value) > 0
+		case ">=":
+			return Compare(v, c.value) >= 0
+		case "contains":
+			return strings.Contains(strings.ToLower(v), strings.ToLower(c.value))
+		case "starts_with":
+			return strings.HasPrefix(strings.ToLower(v), strings.ToLower(c.value))
+		case "ends_with":
+			return strings.HasSuffix(strings.ToLower(v), strings.ToLower(c.value))
+		}
+		in := false
+		for _, value := range c.values {
+			if Compare(v, value) == 0 {
+				in = true
+				break
+			}
+		}
+		return in == (c.operator == "in")
+	}
+
+	out := &db.QueryResult{Columns: t.Columns}
+	for _, row := range t.Rows {
+		keep := true
+		for _, c := range conditions {
+			if !matches(row, c) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	return out, nil
+}
+
+func selectColumns(t *db.QueryResult, columns []string) (*db.QueryResult, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	out := &db.QueryResult{Columns: make([]string, len(columns))}
+	indexes := make([]int, len(columns))
+	for i, col := range columns {
+		name, alias := col, col
+		if parts := splitAlias(col); parts != nil {
+			name, alias = parts[0], parts[1]
+		}
+		index, err := columnIndex(t.Columns, name)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = index
+		if alias == name {
+			alias = t.Columns[index]
+		}
+		out.Columns[i] = strings.TrimSpace(alias)
+	}
+	out.Rows = project(t.Rows, indexes)
+	return out, nil
+}
+
+// splitAlias splits "col AS name" into the column and its new name
+func splitAlias(s string) []string {
+	fields := strings.Fields(s)
+	for i := len(fields) - 2; i > 0; i-- {
+		if strings.EqualFold(fields[i], "as") {
+			return []string{strings.Join(fields[:i], " "), strings.Join(fields[i+1:], " ")}
+		}
+	}
+	return nil
+}
+
+func project(rows [][]string, indexes []int) [][]string {
+	out := make([][]string, len(rows))
+	for r, row := range rows {
+		out[r] = make([]string, len(indexes))
+		for i, index := range indexes {
+			out[r][i] = cell(row, index)
+		}
+	}
+	return out
+}
+
+// Sort returns the rows of t sorted by the keys, each a column optionally followed by asc or desc
+func Sort(t *db.QueryResult, by ...string) (*db.QueryResult, error) {
+	return sortRows(t, by)
+}
+
+// sortRows sorts by each key in turn; a key is a column, optionally followed by asc or desc
+func sortRows(t *db.QueryResult, by []string) (*db.QueryResult, error) {
+	if len(by) == 0 {
+		return nil, fmt.Errorf("no sort columns given")
+	}
+	type key struct {
+		index      int
+		descending bool
+	}
+	keys := make([]key, len(by))
+	for i, spec := range by {
+		fields := strings.Fields(spec)
+		descending := false
+		if n := len(fields); n > 1 {
+			switch strings.ToLower(fields[n-1]) {
+			case "desc":
+				descending = true
+				fields = fields[:n-1]
+			case "asc":
+				fields = fields[:n-1]
+			}
+		}
+		index, err := columnIndex(t.Columns, strings.Join(fields, " "))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key{index, descending}
+	}
+
+	rows := make([][]string, len(t.Rows))
+	copy(rows, t.Rows)
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			c := Compare(cell(rows[i], k.index), cell(rows[j], k.index))
+			if c == 0 {
+				continue
+			}
+			if k.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return &db.QueryResult{Columns: t.Columns, Rows: rows}, nil
+}
+
+func limit(t *db.QueryResult, n, offset int) (*db.QueryResult, error) {
+	if n < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit and offset must not be negative")
+	}
+	rows := t.Rows
+	if offset >= len(rows) {
+		rows = nil
+	} else {
+		rows = rows[offset:]
+	}
+	if n > 0 && n < len(rows) {
+		rows = rows[:n]
+	}
+	return &db.QueryResult{Columns: t.Columns, Rows: rows}, nil
+}
+
+func distinct(t *db.QueryResult) *db.QueryResult {
+	out := &db.QueryResult{Columns: t.Columns}
+	seen := make(map[string]bool)
+	for _, row := range t.Rows {
+		k := rowKey(row)
+		if !seen[k] {
+			seen[k] = true
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	return out
+}
+
+// rowKey identifies a combination of values
+func rowKey(values []string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
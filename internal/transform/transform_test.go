@@ -0,0 +1,179 @@
+package transform
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aiq/aiq/internal/db"
+)
+
+var sales = &db.QueryResult{
+	Columns: []string{"month", "region", "revenue", "cost"},
+	Rows: [][]string{
+		{"2024-01", "north", "100", "60"},
+		{"2024-01", "south", "80", "50"},
+		{"2024-02", "north", "120", "70"},
+		{"2024-02", "south", "NULL", "40"},
+		{"2024-03", "north", "90", "95"},
+	},
+}
+
+var regions = &db.QueryResult{
+	Columns: []string{"region", "manager"},
+	Rows: [][]string{
+		{"north", "Ann"},
+		{"south", "Bob"},
+	},
+}
+
+func lookup(id string) (*db.QueryResult, error) {
+	if id == "r2" {
+		return regions, nil
+	}
+	return nil, fmt.Errorf("result '%s' not found", id)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []Step
+		columns []string
+		rows    [][]string
+	}{
+		{
+			name:    "filter and select",
+			steps:   []Step{{Op: "filter", Where: []Condition{{Column: "revenue", Operator: ">=", Value: float64(100)}}}, {Op: "select", Columns: []string{"month", "revenue AS rev"}}},
+			columns: []string{"month", "rev"},
+			rows:    [][]string{{"2024-01", "100"}, {"2024-02", "120"}},
+		},
+		{
+			name:    "filter in, null excluded from comparisons",
+			steps:   []Step{{Op: "filter", Where: []Condition{{Column: "region", Operator: "in", Value: []interface{}{"south"}}, {Column: "revenue", Operator: "!=", Value: "0"}}}},
+			columns: sales.Columns,
+			rows:    [][]string{{"2024-01", "south", "80", "50"}},
+		},
+		{
+			name:    "top N by numeric sort",
+			steps:   []Step{{Op: "sort", By: []string{"revenue desc"}}, {Op: "limit", Limit: 2}, {Op: "select", Columns: []string{"revenue"}}},
+			columns: []string{"revenue"},
+			rows:    [][]string{{"120"}, {"100"}},
+		},
+		{
+			name: "group with aggregates",
+			steps: []Step{{Op: "group", By: []string{"region"}, Aggregates: []Aggregate{
+				{Function: "sum", Column: "revenue", As: "total"},
+				{Function: "count"},
+				{Function: "avg", Column: "cost"},
+				{Function: "max", Column: "month"},
+			}}},
+			columns: []string{"region", "total", "count", "avg_cost", "max_month"},
+			rows:    [][]string{{"north", "310", "3", "75", "2024-03"}, {"south", "80", "2", "45", "2024-02"}},
+		},
+		{
+			name:    "pivot by month",
+			steps:   []Step{{Op: "pivot", By: []string{"region"}, Column: "month", Value: "revenue"}},
+			columns: []string{"region", "2024-01", "2024-02", "2024-03"},
+			rows:    [][]string{{"north", "100", "120", "90"}, {"south", "80", "NULL", "NULL"}},
+		},
+		{
+			name:    "unpivot",
+			steps:   []Step{{Op: "filter", Where: []Condition{{Column: "month", Value: "2024-03"}}}, {Op: "unpivot", By: []string{"month"}, Columns: []string{"revenue", "cost"}, Name: "measure"}},
+			columns: []string{"month", "measure", "value"},
+			rows:    [][]string{{"2024-03", "revenue", "90"}, {"2024-03", "cost", "95"}},
+		},
+		{
+			name:    "computed column",
+			steps:   []Step{{Op: "compute", Name: "margin", Expression: "round((revenue - cost) / revenue * 100, 1)"}, {Op: "select", Columns: []string{"margin"}}},
+			columns: []string{"margin"},
+			rows:    [][]string{{"40"}, {"37.5"}, {"41.7"}, {"NULL"}, {"-5.6"}},
+		},
+		{
+			name:    "join another result",
+			steps:   []Step{{Op: "distinct"}, {Op: "group", By: []string{"region"}, Aggregates: []Aggregate{{Function: "count"}}}, {Op: "join", ResultID: "r2", On: []string{"region"}}},
+			columns: []string{"region", "count", "manager"},
+			rows:    [][]string{{"north", "3", "Ann"}, {"south", "2", "Bob"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply(sales, tt.steps, lookup)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if !reflect.DeepEqual(result.Columns, tt.columns) {
+				t.Errorf("Columns = %v, expected %v", result.Columns, tt.columns)
+			}
+			if !reflect.DeepEqual(result.Rows, tt.rows) {
+				t.Errorf("Rows = %v, expected %v", result.Rows, tt.rows)
+			}
+		})
+	}
+
+	// The source result is never changed
+	if sales.Rows[0][2] != "100" || len(sales.Columns) != 4 {
+		t.Errorf("Source result was modified: %v", sales)
+	}
+}
+
+func TestLeftJoin(t *testing.T) {
+	left := &db.QueryResult{Columns: []string{"id", "region"}, Rows: [][]string{{"1", "north"}, {"2", "east"}}}
+	result, err := Apply(left, []Step{{Op: "join", ResultID: "r2", On: []string{"region = region"}, Type: "left"}}, lookup)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	expected := [][]string{{"1", "north", "Ann"}, {"2", "east", "NULL"}}
+	if !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("Rows = %v, expected %v", result.Rows, expected)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		step Step
+		want string
+	}{
+		{Step{Op: "explode"}, "unknown op"},
+		{Step{Op: "sort", By: []string{"profit"}}, "unknown column 'profit'"},
+		{Step{Op: "filter", Where: []Condition{{Column: "revenue", Operator: "~"}}}, "unknown operator"},
+		{Step{Op: "group", Aggregates: []Aggregate{{Function: "median", Column: "revenue"}}}, "unknown aggregate function"},
+		{Step{Op: "compute", Name: "x", Expression: "revenue +"}, "unexpected end of expression"},
+		{Step{Op: "compute", Name: "x", Expression: "sqrt(revenue)"}, "unknown function"},
+		{Step{Op: "join", ResultID: "r9", On: []string{"region"}}, "not found"},
+	}
+	for _, tt := range tests {
+		_, err := Apply(sales, []Step{tt.step}, lookup)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.step.Op, tt.want, err)
+		}
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	steps, err := ParseSteps([]interface{}{
+		map[string]interface{}{"op": "sort", "by": []interface{}{"revenue desc"}},
+		map[string]interface{}{"op": "limit", "limit": float64(5)},
+	})
+	if err != nil {
+		t.Fatalf("ParseSteps failed: %v", err)
+	}
+	if len(steps) != 2 || steps[0].By[0] != "revenue desc" || steps[1].Limit != 5 {
+		t.Errorf("Unexpected steps: %+v", steps)
+	}
+	if _, err := ParseSteps(nil); err == nil {
+		t.Error("Expected an error without steps")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if Compare("9", "10") >= 0 {
+		t.Error("Expected numbers to compare numerically")
+	}
+	if Compare("NULL", "0") >= 0 {
+		t.Error("Expected NULL to sort first")
+	}
+	if Compare("b", "a") <= 0 {
+		t.Error("Expected text to compare as text")
+	}
+}
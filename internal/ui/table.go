@@ -98,3 +98,26 @@ func PrintTable(headers []string, rows [][]string) {
 	}
 	fmt.Println(table.Render())
 }
+
+// RenderVertical renders each row as a block of "column: value" lines (mysql client \G style),
+// which keeps wide rows readable
+func (t *Table) RenderVertical() string {
+	width := 0
+	for _, header := range t.headers {
+		if len(header) > width {
+			width = len(header)
+		}
+	}
+
+	var builder strings.Builder
+	for i, row := range t.rows {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(fmt.Sprintf("%s %d. row %s", strings.Repeat("*", 27), i+1, strings.Repeat("*", 27)))
+		for j, header := range t.headers {
+			builder.WriteString(fmt.Sprintf("\n%*s: %s", width, header, row[j]))
+		}
+	}
+	return builder.String()
+}